@@ -0,0 +1,191 @@
+package paywall
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessGrantCookie is the name of the access grant cookie on plain HTTP; on
+// HTTPS it carries the __Host- prefix
+const accessGrantCookie = "paywall_access"
+
+// accessGrantPurpose binds access grant signatures to this token type
+const accessGrantPurpose = "access_grant/v1"
+
+var (
+	// ErrAccessGrantsDisabled is returned when issuing a grant while Config.AccessGrantTTL is zero
+	ErrAccessGrantsDisabled = errors.New("access grants are disabled (hint: set Config.AccessGrantTTL)")
+	// ErrInvalidAccessGrant is returned for malformed, tampered or expired grants
+	ErrInvalidAccessGrant = errors.New("invalid access grant")
+)
+
+// AccessGrant is a signed statement that a confirmed payment grants access
+// until ExpiresAt. Grants are verified with Config.SigningKey alone, without
+// reading the payment from the store.
+// Related: IssueAccessGrant, VerifyAccessGrant, Config.AccessGrantTTL
+type AccessGrant struct {
+	// PaymentID is the confirmed payment the grant was minted for
+	PaymentID string `json:"payment_id"`
+	// Route is the WithRoute name of the payment, "" for the paywall's
+	// default pricing; grants only open the route they were minted for
+	Route string `json:"route,omitempty"`
+	// ExpiresAt is when the grant stops granting access
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IssueAccessGrant mints a signed access grant for a confirmed payment, e.g.
+// to hand to a native app that stores it instead of the payment cookie. The
+// middleware mints grants by itself for visitors with a payment cookie.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed payment that grants access
+//
+// Returns:
+//   - string: Signed grant token
+//   - *AccessGrant: The grant the token stands for
+//   - error: If grants are disabled or the payment does not grant access
+//
+// The grant expires when the payment's access ends or after
+// Config.AccessGrantTTL, whichever comes first.
+//
+// Related: VerifyAccessGrant
+func (p *Paywall) IssueAccessGrant(paymentID string) (string, *AccessGrant, error) {
+	if p.accessGrantTTL <= 0 {
+		return "", nil, ErrAccessGrantsDisabled
+	}
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return "", nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return "", nil, fmt.Errorf("payment %s not found", paymentID)
+	}
+	if !payment.GrantsAccess(time.Now()) {
+		return "", nil, fmt.Errorf("payment %s does not grant access (status: %s)", paymentID, payment.Status)
+	}
+	token, grant := p.mintAccessGrant(payment, time.Now())
+	return token, grant, nil
+}
+
+// VerifyAccessGrant checks a grant token's signature and expiry. It does not
+// read the store, so a grant stays valid until it expires even if its payment
+// is refunded or deleted in the meantime.
+//
+// Parameters:
+//   - token: Grant token from IssueAccessGrant, the access grant cookie or a
+//     bearer token
+//
+// Returns:
+//   - *AccessGrant: The verified grant
+//   - error: ErrInvalidAccessGrant if the token is malformed, tampered or expired
+func (p *Paywall) VerifyAccessGrant(token string) (*AccessGrant, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return nil, ErrInvalidAccessGrant
+	}
+	encodedID, encodedRoute, exp, sig := parts[0], parts[1], parts[2], parts[3]
+	if !p.signer.verify(sig, accessGrantPurpose, encodedID, encodedRoute, exp) {
+		return nil, ErrInvalidAccessGrant
+	}
+	paymentID, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil {
+		return nil, ErrInvalidAccessGrant
+	}
+	route, err := base64.RawURLEncoding.DecodeString(encodedRoute)
+	if err != nil {
+		return nil, ErrInvalidAccessGrant
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !time.Now().Before(time.Unix(expUnix, 0)) {
+		return nil, ErrInvalidAccessGrant
+	}
+	return &AccessGrant{
+		PaymentID: string(paymentID),
+		Route:     string(route),
+		ExpiresAt: time.Unix(expUnix, 0),
+	}, nil
+}
+
+// mintAccessGrant signs a grant for payment, which must grant access at now
+func (p *Paywall) mintAccessGrant(payment *Payment, now time.Time) (string, *AccessGrant) {
+	expires := now.Add(p.accessGrantTTL)
+	if payment.AccessEnds().Before(expires) {
+		expires = payment.AccessEnds()
+	}
+	grant := &AccessGrant{
+		PaymentID: payment.ID,
+		Route:     payment.Route,
+		ExpiresAt: time.Unix(expires.Unix(), 0),
+	}
+	encodedID := base64.RawURLEncoding.EncodeToString([]byte(grant.PaymentID))
+	encodedRoute := base64.RawURLEncoding.EncodeToString([]byte(grant.Route))
+	exp := strconv.FormatInt(grant.ExpiresAt.Unix(), 10)
+	sig := p.signer.sign(accessGrantPurpose, encodedID, encodedRoute, exp)
+	return encodedID + "." + encodedRoute + "." + exp + "." + sig, grant
+}
+
+// accessGrantFromRequest returns the valid access grant in r's cookies, if any
+func (p *Paywall) accessGrantFromRequest(r *http.Request) (*AccessGrant, bool) {
+	cookie, err := r.Cookie("__Host-" + accessGrantCookie)
+	if err != nil {
+		cookie, err = r.Cookie(accessGrantCookie)
+	}
+	if err != nil {
+		return nil, false
+	}
+	grant, err := p.VerifyAccessGrant(cookie.Value)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelDebug,
+			Event:   "access_grant_rejected",
+			Message: fmt.Sprintf("Rejected access grant for %s: %v", r.URL.Path, err),
+		})
+		return nil, false
+	}
+	return grant, true
+}
+
+// setAccessGrantCookie mints a grant for payment and stores it in a cookie
+// that ends with the grant
+func (p *Paywall) setAccessGrantCookie(w http.ResponseWriter, r *http.Request, payment *Payment) {
+	token, grant := p.mintAccessGrant(payment, time.Now())
+	cookieName := accessGrantCookie
+	isSecure := false
+	if p.isSecureRequest(r) {
+		cookieName = "__Host-" + accessGrantCookie
+		isSecure = true
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   isSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  grant.ExpiresAt,
+	})
+}
+
+// paymentInfo describes the request a grant presented with method let through
+func (g *AccessGrant) paymentInfo(method AccessMethod) *PaymentInfo {
+	return &PaymentInfo{
+		PaymentID:    g.PaymentID,
+		Method:       method,
+		Route:        g.Route,
+		Partition:    PaymentPartition(g.PaymentID),
+		AccessEndsAt: g.ExpiresAt,
+	}
+}
+
+// check is the grantCheck of requests let through by the grant; it never
+// reads the store
+func (g *AccessGrant) check() grantCheck {
+	return func() (time.Time, bool) {
+		return g.ExpiresAt, time.Now().Before(g.ExpiresAt)
+	}
+}
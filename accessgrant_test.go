@@ -0,0 +1,125 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAccessGrantTestPaywall creates a paywall with access grants and a
+// confirmed payment granting 30 days of access
+func newAccessGrantTestPaywall(t *testing.T, ttl time.Duration) (*Paywall, *Payment) {
+	t.Helper()
+	pw := newTestPaywall(t, Config{
+		PaymentTimeout: 10 * time.Minute,
+		AccessDuration: 30 * 24 * time.Hour,
+		AccessGrantTTL: ttl,
+	})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	pw.markConfirmed(payment, time.Now())
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+	return pw, payment
+}
+
+func TestMiddleware_AccessGrant(t *testing.T) {
+	pw, payment := newAccessGrantTestPaywall(t, 7*24*time.Hour)
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := FromContext(r.Context())
+		w.Write([]byte("content via " + string(info.Method)))
+	}))
+	serve := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/article", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The payment cookie mints a grant
+	rec := serve(&http.Cookie{Name: "payment_id", Value: payment.ID})
+	if rec.Body.String() != "content via cookie" {
+		t.Fatalf("payment cookie response = %q", rec.Body.String())
+	}
+	var grant *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == accessGrantCookie {
+			grant = c
+		}
+	}
+	if grant == nil {
+		t.Fatal("no access grant cookie set")
+	}
+	if want := time.Now().Add(7 * 24 * time.Hour); grant.Expires.Before(want.Add(-time.Minute)) || grant.Expires.After(want.Add(time.Minute)) {
+		t.Errorf("grant cookie expires %v, want after AccessGrantTTL", grant.Expires)
+	}
+
+	// The grant alone keeps working without the payment in the store
+	if err := pw.Store.(*MemoryStore).DeletePayment(payment.ID); err != nil {
+		t.Fatalf("DeletePayment() error = %v", err)
+	}
+	if rec := serve(&http.Cookie{Name: accessGrantCookie, Value: grant.Value}); rec.Body.String() != "content via access_grant" {
+		t.Errorf("grant response = %q, want content", rec.Body.String())
+	}
+
+	tampered := strings.Replace(grant.Value, ".", ".x", 1)
+	if rec := serve(&http.Cookie{Name: accessGrantCookie, Value: tampered}); strings.HasPrefix(rec.Body.String(), "content") {
+		t.Error("tampered grant granted access")
+	}
+	other := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}), WithRoute(RouteConfig{Name: "video", PathPrefix: "/video", PriceInBTC: 0.01}))
+	req := httptest.NewRequest(http.MethodGet, "/video/1", nil)
+	req.AddCookie(&http.Cookie{Name: accessGrantCookie, Value: grant.Value})
+	rec = httptest.NewRecorder()
+	other.ServeHTTP(rec, req)
+	if rec.Body.String() == "content" {
+		t.Error("grant opened another route")
+	}
+}
+
+func TestIssueAccessGrant(t *testing.T) {
+	pw, payment := newAccessGrantTestPaywall(t, time.Hour)
+	token, grant, err := pw.IssueAccessGrant(payment.ID)
+	if err != nil {
+		t.Fatalf("IssueAccessGrant() error = %v", err)
+	}
+	if grant.PaymentID != payment.ID || grant.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("grant = %+v, want the payment's for at most AccessGrantTTL", grant)
+	}
+	verified, err := pw.VerifyAccessGrant(token)
+	if err != nil || *verified != *grant {
+		t.Errorf("VerifyAccessGrant() = %+v, %v, want %+v", verified, err, grant)
+	}
+
+	// A short access window caps the grant
+	payment.AccessExpiresAt = time.Now().Add(10 * time.Minute)
+	pw.Store.UpdatePayment(payment)
+	if _, grant, _ := pw.IssueAccessGrant(payment.ID); grant.ExpiresAt.After(payment.AccessExpiresAt) {
+		t.Errorf("grant expires %v after access ends %v", grant.ExpiresAt, payment.AccessExpiresAt)
+	}
+
+	pending, _ := pw.CreatePayment()
+	if _, _, err := pw.IssueAccessGrant(pending.ID); err == nil {
+		t.Error("IssueAccessGrant() of a pending payment succeeded")
+	}
+	for _, token := range []string{"", "a.b.c", "YQ..1.sig", token + "x"} {
+		if _, err := pw.VerifyAccessGrant(token); !errors.Is(err, ErrInvalidAccessGrant) {
+			t.Errorf("VerifyAccessGrant(%q) error = %v, want ErrInvalidAccessGrant", token, err)
+		}
+	}
+
+	disabled, payment := newAccessGrantTestPaywall(t, 0)
+	if _, _, err := disabled.IssueAccessGrant(payment.ID); !errors.Is(err, ErrAccessGrantsDisabled) {
+		t.Errorf("IssueAccessGrant() without AccessGrantTTL error = %v, want ErrAccessGrantsDisabled", err)
+	}
+}
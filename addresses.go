@@ -0,0 +1,93 @@
+package paywall
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// ValidatePaymentAddresses checks every address of a payment with its
+// currency's validator (wallet.ValidateAddress), including checksums. Stores
+// configured with ValidateAddresses call it before writing a payment.
+//
+// Parameters:
+//   - payment: The payment to check
+//
+// Returns:
+//   - error: For the first malformed address, in currency order; it wraps a
+//     *wallet.AddressError and wallet.ErrInvalidAddress
+//
+// Related: FileStoreConfig.ValidateAddresses, S3StoreConfig.ValidateAddresses
+func ValidatePaymentAddresses(payment *Payment) error {
+	currencies := make([]wallet.WalletType, 0, len(payment.Addresses))
+	for currency := range payment.Addresses {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+	for _, currency := range currencies {
+		if err := wallet.ValidateAddress(currency, payment.Addresses[currency]); err != nil {
+			return fmt.Errorf("payment %s: %w", payment.ID, err)
+		}
+	}
+	return nil
+}
+
+// addressSkipper is implemented by wallets that derive addresses from a
+// persistent seed and can continue after the addresses handed out before
+type addressSkipper interface {
+	SkipUsedAddresses(used map[string]bool) (uint32, error)
+}
+
+// restoreAddressIndexes advances wallets derived from a configured seed past
+// the addresses of stored payments, so a restart does not hand out an address
+// again. Failures are logged: the wallets then restart at their first address.
+//
+// Parameters:
+//   - config: Configuration with the Store, and the Logger if any
+//   - name: Name of the wallets in the log message, e.g. "Ethereum"
+//   - hdWallets: The wallets; those that are not addressSkippers are ignored
+func restoreAddressIndexes(config Config, name string, hdWallets map[wallet.WalletType]wallet.HDWallet) {
+	err := skipUsedAddresses(config.Store, hdWallets)
+	if err == nil {
+		return
+	}
+	if config.Logger != nil {
+		config.Logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "address_index_not_restored",
+			Message: fmt.Sprintf("%s address index not restored, addresses may be reused: %v", name, err),
+		})
+	} else {
+		log.Printf("WARNING: %s address index not restored, addresses may be reused: %v", name, err)
+	}
+}
+
+// skipUsedAddresses advances the wallets past the addresses of the payments in store
+func skipUsedAddresses(store PaymentStore, hdWallets map[wallet.WalletType]wallet.HDWallet) error {
+	lister, ok := store.(PaymentLister)
+	if !ok {
+		return fmt.Errorf("%T cannot list payments", store)
+	}
+	payments, err := lister.ListPayments()
+	if err != nil {
+		return fmt.Errorf("list payments: %w", err)
+	}
+	for walletType, hdWallet := range hdWallets {
+		skipper, ok := hdWallet.(addressSkipper)
+		if !ok {
+			continue
+		}
+		used := make(map[string]bool)
+		for _, payment := range payments {
+			if address := payment.Addresses[walletType]; address != "" {
+				used[address] = true
+			}
+		}
+		if _, err := skipper.SkipUsedAddresses(used); err != nil {
+			return fmt.Errorf("%s: %w", walletType, err)
+		}
+	}
+	return nil
+}
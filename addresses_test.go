@@ -0,0 +1,59 @@
+package paywall
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestValidateAddresses_Stores(t *testing.T) {
+	fileStore, err := NewFileStoreWithConfig(FileStoreConfig{DataDir: t.TempDir(), ValidateAddresses: true})
+	if err != nil {
+		t.Fatalf("NewFileStoreWithConfig() error = %v", err)
+	}
+	encryptedStore, err := NewFileStoreWithConfig(FileStoreConfig{DataDir: t.TempDir(), EncryptionKey: make([]byte, 32), ValidateAddresses: true})
+	if err != nil {
+		t.Fatalf("NewFileStoreWithConfig() error = %v", err)
+	}
+	s3Store, _ := newTestS3Store(t)
+	s3Store.validateAddresses = true
+
+	stores := map[string]PaymentStore{"file": fileStore, "encrypted": encryptedStore, "s3": s3Store}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			newPayment := func(id, address string) *Payment {
+				return &Payment{
+					ID:        id,
+					Addresses: map[wallet.WalletType]string{wallet.Bitcoin: address},
+					Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+					CreatedAt: time.Now(),
+					ExpiresAt: time.Now().Add(time.Hour),
+					Status:    StatusPending,
+				}
+			}
+
+			err := store.CreatePayment(newPayment("corrupt", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb"))
+			var addrErr *wallet.AddressError
+			if !errors.Is(err, wallet.ErrInvalidAddress) || !errors.As(err, &addrErr) || addrErr.Currency != wallet.Bitcoin {
+				t.Fatalf("CreatePayment(bad checksum) error = %v, want a Bitcoin *wallet.AddressError", err)
+			}
+			if payment, _ := store.GetPayment("corrupt"); payment != nil {
+				t.Error("rejected payment was stored")
+			}
+
+			if err := store.CreatePayment(newPayment("valid", "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn")); err != nil {
+				t.Fatalf("CreatePayment(valid) error = %v", err)
+			}
+			payment, _ := store.GetPayment("valid")
+			payment.Addresses[wallet.Bitcoin] = "hand-edited"
+			if err := store.UpdatePayment(payment); !errors.Is(err, wallet.ErrInvalidAddress) {
+				t.Errorf("UpdatePayment(garbage address) error = %v, want ErrInvalidAddress", err)
+			}
+			if stored, _ := store.GetPayment("valid"); stored.Addresses[wallet.Bitcoin] != "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn" || stored.Version != payment.Version {
+				t.Errorf("stored payment changed: %+v", stored)
+			}
+		})
+	}
+}
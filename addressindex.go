@@ -0,0 +1,117 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// AddressIndexReserver hands out the address indexes of a wallet shared by
+// several paywall instances, so that instances that keep no state between
+// requests (Config.ExternalMonitor) never give two payments the same address.
+// FileStore, SQLStore, RedisStore and S3Store implement it.
+type AddressIndexReserver interface {
+	// ReserveAddressIndex returns the next unreserved index of the wallet
+	// identified by key, at least floor, and reserves it for the caller.
+	// Reservations are atomic across every user of the store and never
+	// returned, so a payment that is not created leaves a gap.
+	//
+	// Parameters:
+	//   - ctx: Cancels the reservation
+	//   - key: Identifies the wallet, e.g. by its first address
+	//   - floor: Lowest index to hand out, the wallet's own next index, so
+	//     addresses used before the store counted them are skipped
+	//
+	// Returns:
+	//   - uint32: The reserved index
+	//   - error: If the store cannot be reached or updated
+	ReserveAddressIndex(ctx context.Context, key string, floor uint32) (uint32, error)
+}
+
+// indexedAddressWallet is a wallet that derives the address at any index,
+// which *wallet.BTCHDWallet and *wallet.BTCWatchWallet do
+type indexedAddressWallet interface {
+	wallet.HDWallet
+	AddressAt(index uint32) (string, error)
+	DeriveAddressAt(index uint32) (string, error)
+	GetNextIndex() uint32
+}
+
+// addressIndexReserverOf returns the AddressIndexReserver of store, looking
+// through a ReplicatedStore to its primary
+func addressIndexReserverOf(store PaymentStore) (AddressIndexReserver, bool) {
+	if replicated, ok := store.(*ReplicatedStore); ok {
+		store = replicated.primary
+	}
+	reserver, ok := store.(AddressIndexReserver)
+	return reserver, ok
+}
+
+// validateExternalMonitorWallet checks that instances created with
+// Config.ExternalMonitor derive Bitcoin addresses from keys that outlive them,
+// counted in the shared Store
+func validateExternalMonitorWallet(config Config) error {
+	if !config.ExternalMonitor || config.MultisigEnabled {
+		return nil
+	}
+	if len(config.BTCWallets) > 0 {
+		return fmt.Errorf("ExternalMonitor cannot be combined with BTCWallets (hint: rotation state is kept per instance; use BTCWatchKey)")
+	}
+	if config.BTCWatchKey == "" && config.BTCMnemonic == "" && config.BTCWalletStorage == nil {
+		return fmt.Errorf("ExternalMonitor requires BTCWatchKey, BTCMnemonic or BTCWalletStorage (hint: a wallet generated per instance disappears with it, and so do the funds paid to its addresses; an xpub in BTCWatchKey keeps spendable keys off the web tier)")
+	}
+	if _, ok := addressIndexReserverOf(config.Store); !ok {
+		return fmt.Errorf("ExternalMonitor requires a Store implementing AddressIndexReserver, got %T (hint: use NewSQLStore, NewRedisStore, NewS3Store or a FileStore on shared storage, so instances do not hand out the same address)", config.Store)
+	}
+	return nil
+}
+
+// initializeAddressIndexes makes the Bitcoin wallet reserve its address
+// indexes in the Store when the paywall runs with Config.ExternalMonitor. The
+// wallet is identified by its first address, so a different key or address
+// type gets its own count.
+func (p *Paywall) initializeAddressIndexes(config Config) error {
+	if !config.ExternalMonitor || config.MultisigEnabled {
+		return nil
+	}
+	reserver, ok := addressIndexReserverOf(config.Store)
+	if !ok {
+		return nil
+	}
+	indexed, ok := p.HDWallets[wallet.Bitcoin].(indexedAddressWallet)
+	if !ok {
+		return nil
+	}
+	first, err := indexed.AddressAt(0)
+	if err != nil {
+		return fmt.Errorf("derive first Bitcoin address: %w", err)
+	}
+	p.addressIndexes = reserver
+	p.addressIndexKey = string(wallet.Bitcoin) + ":" + first
+	return nil
+}
+
+// reservedAddress derives the address of hdWallet at an index reserved in the
+// Store. ok is false when indexes are not coordinated for the wallet, and the
+// caller derives the wallet's next address itself.
+func (p *Paywall) reservedAddress(ctx context.Context, walletType wallet.WalletType, hdWallet wallet.HDWallet) (address string, ok bool, err error) {
+	if p.addressIndexes == nil || walletType != wallet.Bitcoin {
+		return "", false, nil
+	}
+	indexed, isIndexed := hdWallet.(indexedAddressWallet)
+	if !isIndexed {
+		return "", false, nil
+	}
+	index, err := p.addressIndexes.ReserveAddressIndex(ctx, p.addressIndexKey, indexed.GetNextIndex())
+	if err != nil {
+		return "", true, fmt.Errorf("reserve address index: %w", err)
+	}
+	address, err = indexed.DeriveAddressAt(index)
+	return address, true, err
+}
+
+// errAddressIndexConflict is returned when an address index could not be
+// reserved because other instances kept reserving the same one
+var errAddressIndexConflict = errors.New("address index kept changing while it was reserved")
@@ -0,0 +1,169 @@
+package paywall
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestReserveAddressIndex(t *testing.T) {
+	stores := map[string]func(t *testing.T) AddressIndexReserver{
+		"file": func(t *testing.T) AddressIndexReserver { return NewFileStore(t.TempDir()) },
+		"sql": func(t *testing.T) AddressIndexReserver {
+			store, _ := newTestSQLStore(t, DialectPostgres)
+			return store
+		},
+		"redis": func(t *testing.T) AddressIndexReserver {
+			store, _ := newTestRedisStore(t)
+			return store
+		},
+		"s3": func(t *testing.T) AddressIndexReserver {
+			store, _ := newTestS3Store(t)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			for _, step := range []struct {
+				key   string
+				floor uint32
+				want  uint32
+			}{
+				{"BTC:a", 0, 0},
+				{"BTC:a", 0, 1},
+				{"BTC:a", 5, 5},
+				{"BTC:a", 2, 6},
+				{"BTC:b", 3, 3},
+				{"BTC:a", 0, 7},
+			} {
+				got, err := store.ReserveAddressIndex(ctx, step.key, step.floor)
+				if err != nil {
+					t.Fatalf("ReserveAddressIndex(%s, %d) error = %v", step.key, step.floor, err)
+				}
+				if got != step.want {
+					t.Errorf("ReserveAddressIndex(%s, %d) = %d, want %d", step.key, step.floor, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveAddressIndex_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	// Two stores on one directory, as two processes would open it
+	stores := []*FileStore{NewFileStore(dir), NewFileStore(dir)}
+	var mu sync.Mutex
+	seen := make(map[uint32]bool)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func(store *FileStore) {
+			defer wg.Done()
+			index, err := store.ReserveAddressIndex(context.Background(), "BTC:a", 0)
+			if err != nil {
+				t.Errorf("ReserveAddressIndex() error = %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[index] {
+				t.Errorf("index %d reserved twice", index)
+			}
+			seen[index] = true
+		}(stores[i%2])
+	}
+	wg.Wait()
+}
+
+func TestValidateExternalMonitorWallet(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	storage := &wallet.StorageConfig{DataDir: t.TempDir()}
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"without ExternalMonitor", Config{Store: store}, ""},
+		{"watch key", Config{ExternalMonitor: true, Store: store, BTCWatchKey: "tpub"}, ""},
+		{"mnemonic", Config{ExternalMonitor: true, Store: store, BTCMnemonic: "abandon"}, ""},
+		{"wallet storage", Config{ExternalMonitor: true, Store: store, BTCWalletStorage: storage}, ""},
+		{"multisig", Config{ExternalMonitor: true, Store: &S3Store{}, MultisigEnabled: true}, ""},
+		{"generated wallet", Config{ExternalMonitor: true, Store: store}, "requires BTCWatchKey, BTCMnemonic or BTCWalletStorage"},
+		{"rotation", Config{ExternalMonitor: true, Store: store, BTCWallets: []NamedWallet{{}}}, "cannot be combined with BTCWallets"},
+		{"store without counters", Config{ExternalMonitor: true, Store: NewMemoryStore(), BTCWatchKey: "tpub"}, "requires a Store implementing AddressIndexReserver"},
+		{"replicated store", Config{ExternalMonitor: true, Store: NewReplicatedStore(store, NewMemoryStore()), BTCWatchKey: "tpub"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExternalMonitorWallet(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateExternalMonitorWallet() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateExternalMonitorWallet() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPaywall_ExternalMonitorSharesAddressIndexes(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	config := Config{
+		PriceInBTC:      0.001,
+		PaymentTimeout:  time.Hour,
+		TestNet:         true,
+		Store:           store,
+		ExternalMonitor: true,
+		SigningKey:      make([]byte, minSigningKeyLength),
+		BTCWatchKey:     testWatchKey(t),
+	}
+	instances := make([]*Paywall, 2)
+	for i := range instances {
+		pw, err := NewPaywall(config)
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		defer pw.Close()
+		instances[i] = pw
+	}
+	reference, err := wallet.NewBTCWatchWallet(config.BTCWatchKey, nil, 1)
+	if err != nil {
+		t.Fatalf("NewBTCWatchWallet() error = %v", err)
+	}
+
+	// Instances that each start at index 0 take turns, as a load balancer
+	// would send them requests
+	for i := range 4 {
+		payment, err := instances[i%2].CreatePayment()
+		if err != nil {
+			t.Fatalf("CreatePayment() error = %v", err)
+		}
+		if want, _ := reference.DeriveNextAddress(); payment.Addresses[wallet.Bitcoin] != want {
+			t.Errorf("payment %d address = %s, want %s at index %d", i, payment.Addresses[wallet.Bitcoin], want, i)
+		}
+	}
+}
+
+func TestNewPaywall_ExternalMonitorNeedsExistingWallet(t *testing.T) {
+	config := Config{
+		PriceInBTC:       0.001,
+		PaymentTimeout:   time.Hour,
+		TestNet:          true,
+		Store:            NewFileStore(t.TempDir()),
+		ExternalMonitor:  true,
+		SigningKey:       make([]byte, minSigningKeyLength),
+		BTCWalletStorage: &wallet.StorageConfig{DataDir: t.TempDir(), EncryptionKey: make([]byte, 32)},
+	}
+	if _, err := NewPaywall(config); err == nil || !strings.Contains(err.Error(), "requires an existing wallet") {
+		t.Errorf("NewPaywall() error = %v, want an error for the missing wallet", err)
+	}
+}
@@ -0,0 +1,22 @@
+package paywall
+
+import "fmt"
+
+// validateAddressType checks Config.AddressType: a known type, for the
+// wallet NewPaywall derives single-signature addresses from
+func validateAddressType(config Config) error {
+	if config.AddressType == "" {
+		return nil
+	}
+	switch {
+	case !config.AddressType.Valid():
+		return fmt.Errorf("AddressType %q is not supported (hint: use wallet.AddressP2WPKH for bc1q or wallet.AddressP2TR for bc1p addresses)", config.AddressType)
+	case len(config.BTCWallets) > 0:
+		return fmt.Errorf("AddressType cannot be combined with BTCWallets (hint: call SetAddressType on each rotated *wallet.BTCHDWallet)")
+	case config.BTCWatchKey != "":
+		return fmt.Errorf("AddressType cannot be combined with BTCWatchKey (hint: the key's version selects the address type; export a zpub for bc1q addresses)")
+	case config.MultisigEnabled:
+		return fmt.Errorf("AddressType cannot be combined with MultisigEnabled (hint: multisig payments use P2SH addresses of ParticipantPubKeys)")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package paywall
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestValidateAddressType(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"not configured", Config{}, ""},
+		{"native segwit", Config{AddressType: wallet.AddressP2WPKH}, ""},
+		{"taproot with mnemonic", Config{AddressType: wallet.AddressP2TR, BTCMnemonic: testMnemonic}, ""},
+		{"unknown", Config{AddressType: "bech32"}, `AddressType "bech32" is not supported`},
+		{"with rotation", Config{AddressType: wallet.AddressP2WPKH, BTCWallets: []NamedWallet{{ID: "a"}}}, "cannot be combined with BTCWallets"},
+		{"with watch key", Config{AddressType: wallet.AddressP2WPKH, BTCWatchKey: "zpub"}, "cannot be combined with BTCWatchKey"},
+		{"with multisig", Config{AddressType: wallet.AddressP2TR, MultisigEnabled: true}, "cannot be combined with MultisigEnabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAddressType(tt.config)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateAddressType() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPaywall_AddressType(t *testing.T) {
+	// The restarts share one stored wallet, whose type is not saved with it
+	storage := &wallet.StorageConfig{DataDir: t.TempDir(), EncryptionKey: bytes.Repeat([]byte{1}, 32)}
+	tests := []struct {
+		addressType  wallet.BTCAddressType
+		wantPrefixes []string
+	}{
+		{"", []string{"m", "n"}},
+		{wallet.AddressP2SHP2WPKH, []string{"2"}},
+		{wallet.AddressP2WPKH, []string{"tb1q"}},
+		{wallet.AddressP2TR, []string{"tb1p"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.addressType), func(t *testing.T) {
+			pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), BTCWalletStorage: storage, AddressType: tt.addressType})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			defer pw.Close()
+
+			payment, err := pw.CreatePayment()
+			if err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			address := payment.Addresses[wallet.Bitcoin]
+			if !slices.ContainsFunc(tt.wantPrefixes, func(prefix string) bool { return strings.HasPrefix(address, prefix) }) {
+				t.Errorf("payment address = %s, want prefix %v", address, tt.wantPrefixes)
+			}
+			if valid, network := wallet.IsBitcoinAddress(address); !valid || network != "testnet" {
+				t.Errorf("IsBitcoinAddress(%s) = %v, %s", address, valid, network)
+			}
+		})
+	}
+}
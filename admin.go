@@ -0,0 +1,612 @@
+package paywall
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const (
+	// DefaultAdminPath is the suggested mount point of AdminHandler
+	DefaultAdminPath = "/paywall/admin/"
+	// minAdminTokenLength is the shortest Config.AdminToken accepted
+	minAdminTokenLength = 32
+	// minAdminPasswordLength is the shortest Config.AdminUsers password accepted
+	minAdminPasswordLength = 12
+	// defaultAdminListLimit is the number of payments listed when no limit is given
+	defaultAdminListLimit = 100
+	// maxAdminListLimit bounds the limit of a payment listing
+	maxAdminListLimit = 1000
+)
+
+var (
+	// ErrAdminActionNotAllowed is returned when a payment's status does not
+	// allow an admin action, e.g. confirming a confirmed payment
+	ErrAdminActionNotAllowed = errors.New("payment status does not allow this action")
+	// ErrInvalidAdminAction is returned for admin actions with a missing or
+	// unknown currency or an oversized note
+	ErrInvalidAdminAction = errors.New("invalid admin action")
+)
+
+// AdminAction describes a manual change made by support staff. Its note is
+// added to the payment's notes (see AnnotatePayment), so the payment records
+// who changed it and why.
+type AdminAction struct {
+	// Currency the customer paid in, for ForceConfirmPayment.
+	// Optional: defaults to the only currency of single-currency payments.
+	Currency wallet.WalletType `json:"currency,omitempty"`
+	// TxID is the paying transaction, for ForceConfirmPayment. Optional.
+	TxID string `json:"txid,omitempty"`
+	// Note explains the change, e.g. a support ticket number. Optional.
+	Note string `json:"note,omitempty"`
+	// Author identifies the operator. Optional.
+	Author string `json:"author,omitempty"`
+}
+
+// validateAdminAuth checks Config.AdminToken and Config.AdminUsers, if set
+func validateAdminAuth(config Config) error {
+	if config.AdminToken != "" && len(config.AdminToken) < minAdminTokenLength {
+		return fmt.Errorf("AdminToken must have at least %d characters, got: %d (hint: generate one with `openssl rand -hex 32`)", minAdminTokenLength, len(config.AdminToken))
+	}
+	for user, password := range config.AdminUsers {
+		if user == "" || strings.Contains(user, ":") {
+			return fmt.Errorf("AdminUsers has an invalid user name %q (hint: user names must not be empty or contain a colon)", user)
+		}
+		if utf8.RuneCountInString(password) < minAdminPasswordLength {
+			return fmt.Errorf("AdminUsers password of %q must have at least %d characters (hint: use a password manager's generator)", user, minAdminPasswordLength)
+		}
+	}
+	return nil
+}
+
+// ForceConfirmPayment confirms a payment by hand, e.g. after support staff
+// found its transaction on a block explorer while the monitor's backend lags.
+// Pending, detected and expired payments can be confirmed; the transition
+// passes through the payment hooks and is published as EventPaymentConfirmed
+// with "source": "admin".
+//
+// Parameters:
+//   - paymentID: Payment to confirm
+//   - action: Currency paid in (required for multi-currency payments),
+//     transaction ID, note and author
+//
+// Returns:
+//   - *Payment: The confirmed payment
+//   - error: ErrProofPaymentNotFound, ErrAdminActionNotAllowed,
+//     ErrInvalidAdminAction, ErrTransitionVetoed, ErrPaymentLocked, or
+//     storage errors
+//
+// Related: ExpirePayment, RecheckPayment, AdminHandler
+func (p *Paywall) ForceConfirmPayment(paymentID string, action AdminAction) (*Payment, error) {
+	if err := validateAdminNote(action); err != nil {
+		return nil, err
+	}
+	unlock, err := p.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if payment.Status != StatusPending && payment.Status != StatusDetected && payment.Status != StatusExpired {
+		return nil, fmt.Errorf("%w: payment is %s", ErrAdminActionNotAllowed, payment.Status)
+	}
+	currency := action.Currency
+	if currency == "" && len(payment.Addresses) == 1 {
+		for only := range payment.Addresses {
+			currency = only
+		}
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("%w: currency is required for payments offering several currencies", ErrInvalidAdminAction)
+	}
+	if _, offered := payment.Addresses[currency]; !offered {
+		return nil, fmt.Errorf("%w: payment does not offer %s", ErrInvalidAdminAction, currency)
+	}
+
+	text := "Confirmed manually"
+	if action.TxID != "" {
+		text += " (tx " + action.TxID + ")"
+	}
+	amount := payment.Amounts[currency]
+	confirmed := &PaymentTransition{
+		Event:    TransitionConfirm,
+		From:     payment.Status,
+		To:       StatusConfirmed,
+		Payment:  payment,
+		Currency: currency,
+		Amount:   amount,
+		TxID:     action.TxID,
+	}
+	err = p.transition(confirmed, func(t *PaymentTransition) error {
+		p.markConfirmed(t.Payment, time.Now())
+		t.Payment.PaidCurrency = currency
+		addAdminNote(t.Payment, text, action)
+		return p.Store.UpdatePayment(t.Payment)
+	})
+	if errors.Is(err, ErrTransitionVetoed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelWarn,
+		Event:     "payment_force_confirmed",
+		Message:   fmt.Sprintf("Payment confirmed manually by %q", action.Author),
+		PaymentID: payment.ID,
+		Currency:  currency,
+		Amount:    amount,
+	})
+	p.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentConfirmed,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"confirmations": payment.Confirmations,
+			"amount":        amount,
+			"currency":      currency,
+			"txid":          action.TxID,
+			"source":        "admin",
+		},
+	})
+	return payment, nil
+}
+
+// ExpirePayment expires a pending or detected payment by hand, e.g. when a
+// customer abandoned it. Like payments the monitor expires, it is still
+// watched for a late payment for 24 hours. The transition passes through the
+// payment hooks and is published as EventPaymentExpired with "source": "admin".
+//
+// Parameters:
+//   - paymentID: Payment to expire
+//   - action: Note and author; Currency and TxID are ignored
+//
+// Returns:
+//   - *Payment: The expired payment
+//   - error: ErrProofPaymentNotFound, ErrAdminActionNotAllowed,
+//     ErrInvalidAdminAction, ErrTransitionVetoed, ErrPaymentLocked, or
+//     storage errors
+//
+// Related: ForceConfirmPayment, AdminHandler
+func (p *Paywall) ExpirePayment(paymentID string, action AdminAction) (*Payment, error) {
+	if err := validateAdminNote(action); err != nil {
+		return nil, err
+	}
+	unlock, err := p.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if payment.Status != StatusPending && payment.Status != StatusDetected {
+		return nil, fmt.Errorf("%w: payment is %s", ErrAdminActionNotAllowed, payment.Status)
+	}
+
+	expired := &PaymentTransition{
+		Event:   TransitionExpire,
+		From:    payment.Status,
+		To:      StatusExpired,
+		Payment: payment,
+	}
+	err = p.transition(expired, func(t *PaymentTransition) error {
+		now := time.Now()
+		t.Payment.Status = StatusExpired
+		if t.Payment.ExpiresAt.After(now) {
+			// The late payment window starts now
+			t.Payment.ExpiresAt = now
+		}
+		addAdminNote(t.Payment, "Expired manually", action)
+		return p.Store.UpdatePayment(t.Payment)
+	})
+	if errors.Is(err, ErrTransitionVetoed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelWarn,
+		Event:     "payment_force_expired",
+		Message:   fmt.Sprintf("Payment expired manually by %q", action.Author),
+		PaymentID: payment.ID,
+	})
+	p.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentExpired,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"amounts":  payment.Amounts,
+			"received": payment.LastBalanceSeen,
+			"source":   "admin",
+		},
+	})
+	return payment, nil
+}
+
+// RecheckPayment has the monitor check an unpaid payment's chains now instead
+// of on its next cycle, so a payment whose confirmation is delayed confirms
+// as soon as the chain shows it. Expired payments are checked too, even after
+// the 24 hours the monitor watches them. With Config.ExternalMonitor the
+// payment's last check is reset instead, so the external monitor checks it on
+// its next cycle.
+//
+// Returns:
+//   - *Payment: The payment after the check
+//   - error: ErrProofPaymentNotFound, ErrAdminActionNotAllowed,
+//     ErrPaymentLocked, storage errors, or an error if a chain check failed
+//     (the failure is logged)
+//
+// Related: ForceConfirmPayment, AdminHandler
+func (p *Paywall) RecheckPayment(paymentID string) (*Payment, error) {
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if payment.Status != StatusPending && payment.Status != StatusDetected && payment.Status != StatusExpired {
+		return nil, fmt.Errorf("%w: payment is %s", ErrAdminActionNotAllowed, payment.Status)
+	}
+
+	if p.monitor == nil {
+		unlock, err := p.lockPayment(paymentID, paymentLockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if payment, err = p.Store.GetPayment(paymentID); err != nil {
+			return nil, fmt.Errorf("get payment: %w", err)
+		}
+		if payment == nil {
+			return nil, ErrProofPaymentNotFound
+		}
+		payment.LastCheckedAt = time.Time{}
+		if err := p.Store.UpdatePayment(payment); err != nil {
+			return nil, fmt.Errorf("update payment: %w", err)
+		}
+		return payment, nil
+	}
+
+	failed := p.monitor.checkPaymentLocked(payment)
+	checked, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if checked == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if failed {
+		return checked, fmt.Errorf("checking payment %s failed, see the monitor's logs", paymentID)
+	}
+	return checked, nil
+}
+
+// validateAdminNote checks the length of an admin action's note
+func validateAdminNote(action AdminAction) error {
+	if utf8.RuneCountInString(action.Note) > maxNoteLength {
+		return fmt.Errorf("%w: note longer than %d characters", ErrInvalidAdminAction, maxNoteLength)
+	}
+	return nil
+}
+
+// addAdminNote records an admin action in the payment's notes, unless the
+// payment already has as many notes as it may keep
+func addAdminNote(payment *Payment, text string, action AdminAction) {
+	if len(payment.Notes) >= maxNotesPerPayment {
+		return
+	}
+	if note := strings.TrimSpace(action.Note); note != "" {
+		text += ": " + note
+	}
+	payment.Notes = append(payment.Notes, PaymentNote{
+		Text:      text,
+		Author:    strings.TrimSpace(action.Author),
+		CreatedAt: time.Now(),
+	})
+}
+
+// AdminPaymentFilter selects the payments listed by ListAdminPayments
+type AdminPaymentFilter struct {
+	PaymentFilter
+	// Limit is the maximum number of payments returned.
+	// Optional: defaults to 100, at most 1000.
+	Limit int
+}
+
+// ListAdminPayments returns the newest payments matching filter, for support
+// staff looking for a customer's payment
+//
+// Returns:
+//   - []*Payment: Up to filter.Limit payments, newest first
+//   - bool: true if more payments matched
+//   - error: If the store cannot be streamed or listed
+func (p *Paywall) ListAdminPayments(filter AdminPaymentFilter) ([]*Payment, bool, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAdminListLimit
+	}
+	limit = min(limit, maxAdminListLimit)
+
+	newestFirst := func(a, b *Payment) int { return b.CreatedAt.Compare(a.CreatedAt) }
+	var payments []*Payment
+	more := false
+	err := StreamPayments(p.Store, filter.PaymentFilter, func(payment *Payment) error {
+		payments = append(payments, payment)
+		if len(payments) > 2*limit {
+			// Keep memory bounded on large stores
+			slices.SortFunc(payments, newestFirst)
+			payments, more = payments[:limit], true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("list payments: %w", err)
+	}
+	slices.SortFunc(payments, newestFirst)
+	if len(payments) > limit {
+		payments, more = payments[:limit], true
+	}
+	return payments, more, nil
+}
+
+// AdminPaymentsResponse is the JSON body of the admin API's payment listing
+type AdminPaymentsResponse struct {
+	// Payments are the matching payments, newest first
+	Payments []*Payment `json:"payments"`
+	// More is true when more payments matched than the limit
+	More bool `json:"more"`
+}
+
+// AdminHandler serves the admin dashboard and API, protected by
+// Config.AdminToken sent as "Authorization: Bearer <token>" or by HTTP basic
+// auth with one of Config.AdminUsers. Mount it with its prefix stripped:
+//
+//	mux.Handle(paywall.DefaultAdminPath, http.StripPrefix(strings.TrimSuffix(paywall.DefaultAdminPath, "/"), pw.AdminHandler()))
+//
+// Routes:
+//   - GET /: the dashboard page (see Dashboard), for browsers
+//   - GET /payments?status=&currency=&from=&to=&tag=&limit=: payments, newest
+//     first (status takes a comma-separated list, from and to RFC 3339 times
+//     or Unix milliseconds bounding CreatedAt)
+//   - GET /payments/{id}: one payment
+//   - POST /payments/{id}/confirm: ForceConfirmPayment with an AdminAction body
+//   - POST /payments/{id}/expire: ExpirePayment with an AdminAction body
+//   - POST /payments/{id}/recheck: RecheckPayment
+//   - GET /search, /notes, /mode, GET /stats/history, GET /metrics:
+//     HandlePaymentSearch, HandlePaymentNotes, HandleMode,
+//     HandleStatsHistory and MetricsHandler
+//
+// Action bodies may be JSON or form-encoded and may be empty. Form posts
+// authenticated with basic auth, as the dashboard sends them, must come from a
+// page of the same host (checked with the Referer header) and are answered
+// with a redirect back to it.
+//
+// Responses:
+//   - 200 with the payment, an AdminPaymentsResponse or the dashboard
+//   - 303 See Other back to the dashboard after form posts
+//   - 400 Bad Request for malformed filters or actions
+//   - 401 Unauthorized without valid credentials
+//   - 403 Forbidden for form posts from other sites
+//   - 404 Not Found for unknown payments, or for everything without
+//     Config.AdminToken and Config.AdminUsers
+//   - 409 Conflict when the payment's status does not allow the action
+//   - 422 Unprocessable Entity when a payment hook vetoed the change
+//   - 503 Service Unavailable if another operation holds the payment's lock
+//
+// Returns:
+//   - http.Handler: The admin API
+func (p *Paywall) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", p.handleDashboard)
+	mux.HandleFunc("GET /assets/admin.css", p.handleDashboardStyle)
+	mux.HandleFunc("GET /payments", p.handleAdminListPayments)
+	mux.HandleFunc("GET /payments/{id}", p.handleAdminGetPayment)
+	mux.HandleFunc("POST /payments/{id}/confirm", p.handleAdminAction(p.ForceConfirmPayment))
+	mux.HandleFunc("POST /payments/{id}/expire", p.handleAdminAction(p.ExpirePayment))
+	mux.HandleFunc("POST /payments/{id}/recheck", p.handleAdminAction(func(id string, _ AdminAction) (*Payment, error) {
+		return p.RecheckPayment(id)
+	}))
+	mux.HandleFunc("/search", p.HandlePaymentSearch)
+	mux.HandleFunc("/notes", p.HandlePaymentNotes)
+	mux.HandleFunc("/mode", p.HandleMode)
+	mux.HandleFunc("GET /stats/history", p.HandleStatsHistory)
+	mux.Handle("GET /metrics", p.MetricsHandler())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.adminToken == "" && len(p.adminUsers) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if !p.adminAuthorized(r) {
+			if len(p.adminUsers) > 0 {
+				// Browsers ask for a user name and password
+				w.Header().Set("WWW-Authenticate", `Basic realm="paywall admin", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="paywall admin"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// adminAuthorized reports whether r carries Config.AdminToken or the
+// credentials of one of Config.AdminUsers
+func (p *Paywall) adminAuthorized(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return p.adminToken != "" && secretsEqual(strings.TrimSpace(token), p.adminToken)
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	expected, known := p.adminUsers[user]
+	// Compared either way, so that the answer time does not reveal user names
+	return secretsEqual(password, expected) && known
+}
+
+// secretsEqual compares two secrets in constant time
+func secretsEqual(given, expected string) bool {
+	a, b := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+// handleAdminListPayments serves GET /payments of AdminHandler
+func (p *Paywall) handleAdminListPayments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter AdminPaymentFilter
+	if raw := query.Get("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			filter.Statuses = append(filter.Statuses, PaymentStatus(strings.TrimSpace(status)))
+		}
+	}
+	filter.Currency = wallet.WalletType(strings.ToUpper(query.Get("currency")))
+	filter.Tag = query.Get("tag")
+	var err error
+	if filter.CreatedFrom, err = parseStatsTime(query.Get("from"), time.Time{}); err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CreatedTo, err = parseStatsTime(query.Get("to"), time.Time{}); err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil || filter.Limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	payments, more, err := p.ListAdminPayments(filter)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "admin_list_payments_failed",
+			Message: fmt.Sprintf("Failed to list payments: %v", err),
+		})
+		http.Error(w, "Failed to list payments", http.StatusInternalServerError)
+		return
+	}
+	if payments == nil {
+		payments = []*Payment{}
+	}
+	writeAdminJSON(w, p, AdminPaymentsResponse{Payments: payments, More: more})
+}
+
+// handleAdminGetPayment serves GET /payments/{id} of AdminHandler
+func (p *Paywall) handleAdminGetPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := p.Store.GetPayment(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Failed to load payment", http.StatusInternalServerError)
+		return
+	}
+	if payment == nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(w, p, payment)
+}
+
+// handleAdminAction serves a POST /payments/{id}/... action of AdminHandler
+func (p *Paywall) handleAdminAction(act func(paymentID string, action AdminAction) (*Payment, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var action AdminAction
+		back := ""
+		if r.ContentLength != 0 {
+			form, ok := decodeRequestBody(w, r, p.requestLimits(), &action, true)
+			if !ok {
+				return
+			}
+			if form != nil {
+				// Browsers send basic auth credentials along with forms
+				// posted by any site
+				if _, _, basic := r.BasicAuth(); basic {
+					if back = sameOriginReferer(r); back == "" {
+						http.Error(w, "Form posts must come from the admin dashboard", http.StatusForbidden)
+						return
+					}
+				}
+				action = AdminAction{
+					Currency: wallet.WalletType(form.Get("currency")),
+					TxID:     form.Get("txid"),
+					Note:     form.Get("note"),
+					Author:   form.Get("author"),
+				}
+			}
+		}
+
+		payment, err := act(r.PathValue("id"), action)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrProofPaymentNotFound):
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrInvalidAdminAction):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, ErrAdminActionNotAllowed):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, ErrTransitionVetoed):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, ErrPaymentLocked):
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Payment is busy, please retry", http.StatusServiceUnavailable)
+			return
+		case payment != nil:
+			// A recheck that failed on some chain still returns the payment;
+			// the failure is logged
+		default:
+			http.Error(w, "Failed to update payment", http.StatusInternalServerError)
+			return
+		}
+		if back != "" {
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+		writeAdminJSON(w, p, payment)
+	}
+}
+
+// writeAdminJSON writes an admin API response
+func writeAdminJSON(w http.ResponseWriter, p *Paywall, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode admin response: %v", err),
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package paywall
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const testAdminToken = "0123456789abcdef0123456789abcdef"
+
+// newAdminTestPaywall creates a paywall with the admin API and without a
+// monitor of its own, so tests control monitor checks
+// adminTestConfig configures an instance without a monitor, whose payments
+// the tests change through the admin API only
+func adminTestConfig(t *testing.T) Config {
+	return Config{
+		Store:           NewFileStore(t.TempDir()),
+		Logger:          NewStructuredLogger(io.Discard, LogLevelError, true),
+		ExternalMonitor: true,
+		SigningKey:      testSigningKey,
+		BTCWatchKey:     testWatchKey(t),
+		AdminToken:      testAdminToken,
+	}
+}
+
+// adminRequest sends an authenticated request to pw's admin API
+func adminRequest(pw *Paywall, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	if strings.HasPrefix(body, "{") {
+		req.Header.Set("Content-Type", "application/json")
+	} else if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	rec := httptest.NewRecorder()
+	pw.AdminHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminHandler_Auth(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer " + strings.Repeat("x", 32), http.StatusUnauthorized},
+		{"basic auth", "Basic " + testAdminToken, http.StatusUnauthorized},
+		{"token", "Bearer " + testAdminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		pw.AdminHandler().ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+	}
+
+	disabled := newTestPaywall(t, Config{})
+	req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	disabled.AdminHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status without Config.AdminToken = %d, want 404", rec.Code)
+	}
+
+	_, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), AdminToken: "short"})
+	if err == nil || !strings.Contains(err.Error(), "AdminToken") {
+		t.Errorf("NewPaywall(AdminToken: short) error = %v, want an AdminToken error", err)
+	}
+}
+
+func TestAdminHandler_ListPayments(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	now := time.Now()
+	for _, p := range []*Payment{
+		{ID: "old-btc", Status: StatusConfirmed, PaidCurrency: wallet.Bitcoin, CreatedAt: now.Add(-72 * time.Hour)},
+		{ID: "paid-xmr", Status: StatusConfirmed, PaidCurrency: wallet.Monero, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "pending", Status: StatusPending, CreatedAt: now.Add(-time.Hour)},
+		{ID: "expired", Status: StatusExpired, CreatedAt: now.Add(-30 * time.Minute)},
+	} {
+		p.Addresses = map[wallet.WalletType]string{wallet.Bitcoin: "addr-" + p.ID, wallet.Monero: "xmr-" + p.ID}
+		p.Amounts = map[wallet.WalletType]float64{wallet.Bitcoin: 0.001, wallet.Monero: 0.1}
+		p.ExpiresAt = p.CreatedAt.Add(time.Hour)
+		pw.Store.CreatePayment(p)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+		more  bool
+	}{
+		{"", []string{"expired", "pending", "paid-xmr", "old-btc"}, false},
+		{"status=pending,expired", []string{"expired", "pending"}, false},
+		{"currency=btc", []string{"expired", "pending", "old-btc"}, false},
+		{"from=" + url.QueryEscape(now.Add(-3*time.Hour).Format(time.RFC3339)), []string{"expired", "pending", "paid-xmr"}, false},
+		{"to=" + url.QueryEscape(now.Add(-90*time.Minute).Format(time.RFC3339)), []string{"paid-xmr", "old-btc"}, false},
+		{"limit=2", []string{"expired", "pending"}, true},
+	}
+	for _, tt := range tests {
+		rec := adminRequest(pw, http.MethodGet, "/payments?"+tt.query, "")
+		var resp AdminPaymentsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%q: decode response: %v", tt.query, err)
+		}
+		var ids []string
+		for _, p := range resp.Payments {
+			ids = append(ids, p.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") || resp.More != tt.more {
+			t.Errorf("%q: payments = %v (more %v), want %v (more %v)", tt.query, ids, resp.More, tt.want, tt.more)
+		}
+	}
+
+	for query, want := range map[string]int{"from=yesterday": http.StatusBadRequest, "limit=0": http.StatusBadRequest} {
+		if rec := adminRequest(pw, http.MethodGet, "/payments?"+query, ""); rec.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+	if rec := adminRequest(pw, http.MethodGet, "/payments/pending", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":"pending"`) {
+		t.Errorf("GET /payments/pending = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := adminRequest(pw, http.MethodGet, "/payments/missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /payments/missing = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminHandler_Actions(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	rec := adminRequest(pw, http.MethodPost, "/payments/"+payment.ID+"/confirm", `{"txid": "abc123", "note": "ticket 42", "author": "alice"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.Bitcoin || stored.ConfirmedAt.IsZero() {
+		t.Errorf("confirmed payment = %s in %q, want confirmed in BTC", stored.Status, stored.PaidCurrency)
+	}
+	if len(stored.Notes) != 1 || stored.Notes[0].Text != "Confirmed manually (tx abc123): ticket 42" || stored.Notes[0].Author != "alice" {
+		t.Errorf("notes = %+v, want the admin note", stored.Notes)
+	}
+	if got := pw.metrics.revenue.Value("BTC"); got != 0.001 {
+		t.Errorf("revenue = %v, want the price", got)
+	}
+	if rec := adminRequest(pw, http.MethodPost, "/payments/"+payment.ID+"/confirm", ""); rec.Code != http.StatusConflict {
+		t.Errorf("second confirm status = %d, want 409", rec.Code)
+	}
+	if rec := adminRequest(pw, http.MethodPost, "/payments/"+payment.ID+"/expire", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expire of a confirmed payment status = %d, want 409", rec.Code)
+	}
+
+	unpaid, _ := pw.CreatePayment()
+	if rec := adminRequest(pw, http.MethodPost, "/payments/"+unpaid.ID+"/confirm", `{"currency": "XMR"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("confirm in a currency not offered status = %d, want 400", rec.Code)
+	}
+	rec = adminRequest(pw, http.MethodPost, "/payments/"+unpaid.ID+"/expire", url.Values{"note": {"abandoned"}}.Encode())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expire status = %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, _ = pw.Store.GetPayment(unpaid.ID)
+	if stored.Status != StatusExpired || stored.ExpiresAt.After(time.Now()) {
+		t.Errorf("expired payment = %s until %s, want expired now", stored.Status, stored.ExpiresAt)
+	}
+	if rec := adminRequest(pw, http.MethodPost, "/payments/missing/expire", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expire of an unknown payment status = %d, want 404", rec.Code)
+	}
+	if rec := adminRequest(pw, http.MethodGet, "/payments/"+unpaid.ID+"/expire", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET of an action status = %d, want 405", rec.Code)
+	}
+}
+
+func TestRecheckPayment(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	payment, _ := pw.CreatePayment()
+
+	// With an external monitor the payment is queued for its next cycle
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	stored.LastCheckedAt = time.Now()
+	pw.Store.UpdatePayment(stored)
+	rec := adminRequest(pw, http.MethodPost, "/payments/"+payment.ID+"/recheck", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recheck status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if stored, _ = pw.Store.GetPayment(payment.ID); !stored.LastCheckedAt.IsZero() {
+		t.Errorf("LastCheckedAt = %s, want reset", stored.LastCheckedAt)
+	}
+
+	// With a local monitor the chain is checked now
+	pw.monitor = &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin: &mockCryptoClient{balance: 0.001},
+	}}
+	checked, err := pw.RecheckPayment(payment.ID)
+	if err != nil || checked.Status != StatusConfirmed {
+		t.Fatalf("RecheckPayment() = %v, %v, want confirmed", checked, err)
+	}
+	if _, err := pw.RecheckPayment(payment.ID); err == nil {
+		t.Error("RecheckPayment() of a confirmed payment succeeded")
+	}
+}
@@ -0,0 +1,408 @@
+package paywall
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAPIKeyHeader is the request header carrying API keys
+	DefaultAPIKeyHeader = "X-API-Key"
+	// apiKeyPrefix starts every issued API key, making leaked keys easy to scan for
+	apiKeyPrefix = "pwk_"
+	// apiKeyUsageFlushInterval bounds how often usage of unlimited keys is persisted
+	apiKeyUsageFlushInterval = time.Minute
+)
+
+var (
+	// ErrAPIKeysDisabled is returned when API keys are used without Config.APIKeysEnabled
+	ErrAPIKeysDisabled = errors.New("API keys are not enabled")
+	// ErrInvalidAPIKey is returned for malformed, unknown, revoked or expired keys
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyRateLimited is returned when a key exceeds its per-minute rate limit
+	ErrAPIKeyRateLimited = errors.New("API key rate limit exceeded")
+	// ErrAPIKeyUsageExhausted is returned when a key has used up its total request quota
+	ErrAPIKeyUsageExhausted = errors.New("API key usage limit exhausted")
+	// ErrInsufficientCredits is returned when a metered key's balance cannot cover a request
+	ErrInsufficientCredits = errors.New("insufficient API key credits")
+)
+
+// APIKey is a long-lived credential for machine-to-machine access, issued for a
+// confirmed payment or created by the operator. Only a hash of the secret is stored.
+//
+// Related: Paywall.IssueAPIKey, Paywall.CreateAPIKey, APIKeyStore
+type APIKey struct {
+	// ID is the public key identifier (also embedded in the key string)
+	ID string `json:"id"`
+	// SecretHash is the hex-encoded SHA-256 of the key secret
+	SecretHash string `json:"secret_hash"`
+	// PaymentID links the key to the payment it was issued for, empty for operator keys
+	PaymentID string `json:"payment_id,omitempty"`
+	// Label is a free-form operator note (customer name, integration)
+	Label string `json:"label,omitempty"`
+	// CreatedAt is when the key was issued
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the key stops working, zero for never
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// RevokedAt is when the key was revoked, nil while active
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// RateLimit is the maximum number of requests per minute, 0 for unlimited
+	RateLimit int `json:"rate_limit,omitempty"`
+	// UsageLimit is the maximum total number of requests, 0 for unlimited
+	UsageLimit int64 `json:"usage_limit,omitempty"`
+	// Usage is the number of requests served with this key
+	Usage int64 `json:"usage"`
+	// LastUsedAt is when the key was last used
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	// Metered keys pay for each request from Credits (pay-per-call)
+	Metered bool `json:"metered,omitempty"`
+	// Credits is the remaining balance of a metered key
+	Credits int64 `json:"credits,omitempty"`
+}
+
+// Active reports whether the key is neither revoked nor expired at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// APIKeyOptions configures a newly issued API key
+type APIKeyOptions struct {
+	// Label is a free-form operator note
+	Label string
+	// TTL is how long the key is valid, 0 for no expiry
+	TTL time.Duration
+	// RateLimit is the maximum number of requests per minute, 0 for unlimited
+	RateLimit int
+	// UsageLimit is the maximum total number of requests, 0 for unlimited
+	UsageLimit int64
+	// Metered makes the key pay per request from a credit balance. Keys issued
+	// for a payment start with Config.CreditsPerPayment credits from it.
+	Metered bool
+	// Credits is an initial balance for metered keys (e.g. operator grants)
+	Credits int64
+}
+
+// apiKeyState holds in-memory rate limiting and usage accounting for API keys
+type apiKeyState struct {
+	mu      sync.Mutex
+	windows map[string]*apiKeyWindow
+}
+
+// apiKeyWindow is a key's fixed one-minute rate limit window and unpersisted usage
+type apiKeyWindow struct {
+	start     time.Time
+	count     int
+	pending   int64
+	lastFlush time.Time
+}
+
+func newAPIKeyState() *apiKeyState {
+	return &apiKeyState{windows: make(map[string]*apiKeyWindow)}
+}
+
+// apiKeyStore returns the Store as an APIKeyStore
+func (p *Paywall) apiKeyStore() (APIKeyStore, error) {
+	if p.apiKeys == nil {
+		return nil, ErrAPIKeysDisabled
+	}
+	store, ok := p.Store.(APIKeyStore)
+	if !ok {
+		return nil, fmt.Errorf("payment store %T does not support API keys", p.Store)
+	}
+	return store, nil
+}
+
+// IssueAPIKey creates an API key for a confirmed payment. The key keeps working
+// after the payment's access window, but stops if the payment leaves the
+// confirmed state or the key is revoked.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed payment
+//   - opts: Label, TTL and limits for the key
+//
+// Returns:
+//   - string: The full API key; it is shown once and cannot be recovered later
+//   - *APIKey: The stored key record
+//   - error: If API keys are disabled or the payment is not confirmed
+func (p *Paywall) IssueAPIKey(paymentID string, opts APIKeyOptions) (string, *APIKey, error) {
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return "", nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return "", nil, fmt.Errorf("payment %s not found", paymentID)
+	}
+	if payment.Status != StatusConfirmed {
+		return "", nil, fmt.Errorf("payment %s is not confirmed (status %s)", paymentID, payment.Status)
+	}
+	return p.newAPIKey(paymentID, opts)
+}
+
+// CreateAPIKey creates an operator API key that is not tied to any payment,
+// e.g. for partners or internal services.
+//
+// Returns:
+//   - string: The full API key; it is shown once and cannot be recovered later
+//   - *APIKey: The stored key record
+//   - error: If API keys are disabled or storage fails
+func (p *Paywall) CreateAPIKey(opts APIKeyOptions) (string, *APIKey, error) {
+	return p.newAPIKey("", opts)
+}
+
+func (p *Paywall) newAPIKey(paymentID string, opts APIKeyOptions) (string, *APIKey, error) {
+	store, err := p.apiKeyStore()
+	if err != nil {
+		return "", nil, err
+	}
+	if opts.TTL < 0 || opts.RateLimit < 0 || opts.UsageLimit < 0 || opts.Credits < 0 {
+		return "", nil, errors.New("API key TTL, limits and credits must not be negative")
+	}
+
+	idBytes := make([]byte, 8)
+	secret := make([]byte, 32)
+	if err := readRandom(p.rand, idBytes); err != nil {
+		return "", nil, fmt.Errorf("generate API key ID: %w", err)
+	}
+	if err := readRandom(p.rand, secret); err != nil {
+		return "", nil, fmt.Errorf("generate API key secret: %w", err)
+	}
+	secretStr := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	key := &APIKey{
+		ID:         hex.EncodeToString(idBytes),
+		SecretHash: hashAPIKeySecret(secretStr),
+		PaymentID:  paymentID,
+		Label:      opts.Label,
+		CreatedAt:  now,
+		RateLimit:  opts.RateLimit,
+		UsageLimit: opts.UsageLimit,
+		Metered:    opts.Metered || opts.Credits > 0,
+		Credits:    opts.Credits,
+	}
+	if opts.TTL > 0 {
+		key.ExpiresAt = now.Add(opts.TTL)
+	}
+	if err := store.SaveAPIKey(key); err != nil {
+		return "", nil, fmt.Errorf("save API key: %w", err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "api_key_issued",
+		Message:   fmt.Sprintf("Issued API key %s", key.ID),
+		PaymentID: paymentID,
+	})
+	if key.Metered && paymentID != "" && p.creditsPerPayment > 0 {
+		if key, err = p.TopUpAPIKey(key.ID, paymentID); err != nil {
+			return "", nil, fmt.Errorf("credit issuing payment: %w", err)
+		}
+	}
+	return apiKeyPrefix + key.ID + "_" + secretStr, key, nil
+}
+
+// RevokeAPIKey permanently disables an API key
+//
+// Returns:
+//   - error: If API keys are disabled, the key does not exist or storage fails
+func (p *Paywall) RevokeAPIKey(id string) error {
+	store, err := p.apiKeyStore()
+	if err != nil {
+		return err
+	}
+	key, err := store.GetAPIKey(id)
+	if err != nil {
+		return fmt.Errorf("get API key: %w", err)
+	}
+	if key == nil {
+		return fmt.Errorf("API key %s not found", id)
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := store.SaveAPIKey(key); err != nil {
+		return fmt.Errorf("save API key: %w", err)
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "api_key_revoked",
+		Message:   fmt.Sprintf("Revoked API key %s", id),
+		PaymentID: key.PaymentID,
+	})
+	return nil
+}
+
+// ListAPIKeys returns all API key records (secrets are never stored)
+func (p *Paywall) ListAPIKeys() ([]*APIKey, error) {
+	store, err := p.apiKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.ListAPIKeys()
+}
+
+// hashAPIKeySecret returns the hex-encoded SHA-256 of an API key secret
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKey splits "pwk_<id>_<secret>" into its ID and secret
+func parseAPIKey(raw string) (id, secret string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(raw), apiKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+	id, secret, found = strings.Cut(rest, "_")
+	if !found || len(id) != 16 || secret == "" {
+		return "", "", false
+	}
+	// IDs are hex; rejecting anything else keeps them safe to use as file names
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// authenticateAPIKey validates a presented key and accounts for its usage.
+//
+// Parameters:
+//   - raw: The presented key
+//   - cost: Credits charged to metered keys for this request
+//
+// Returns:
+//   - *APIKey: The authenticated key
+//   - time.Duration: When the rate limit window resets (only with ErrAPIKeyRateLimited)
+//   - error: ErrInvalidAPIKey, ErrAPIKeyRateLimited, ErrAPIKeyUsageExhausted,
+//     ErrInsufficientCredits or a storage error
+func (p *Paywall) authenticateAPIKey(raw string, cost int64) (*APIKey, time.Duration, error) {
+	store, err := p.apiKeyStore()
+	if err != nil {
+		return nil, 0, err
+	}
+	id, secret, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, 0, ErrInvalidAPIKey
+	}
+	key, err := store.GetAPIKey(id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get API key: %w", err)
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, 0, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, 0, ErrInvalidAPIKey
+	}
+	if key.PaymentID != "" {
+		payment, err := p.Store.GetPayment(key.PaymentID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("get payment: %w", err)
+		}
+		if payment == nil || payment.Status != StatusConfirmed {
+			return nil, 0, ErrInvalidAPIKey
+		}
+	}
+
+	p.apiKeys.mu.Lock()
+	defer p.apiKeys.mu.Unlock()
+
+	window, ok := p.apiKeys.windows[key.ID]
+	if !ok {
+		window = &apiKeyWindow{start: now}
+		p.apiKeys.windows[key.ID] = window
+	}
+	if now.Sub(window.start) >= time.Minute {
+		window.start, window.count = now, 0
+	}
+	if key.RateLimit > 0 && window.count >= key.RateLimit {
+		return nil, window.start.Add(time.Minute).Sub(now), ErrAPIKeyRateLimited
+	}
+
+	// Re-read under the lock so concurrent requests see each other's usage
+	if key.UsageLimit > 0 || key.Metered {
+		if latest, err := store.GetAPIKey(id); err == nil && latest != nil {
+			key = latest
+		}
+		if key.UsageLimit > 0 && key.Usage >= key.UsageLimit {
+			return nil, 0, ErrAPIKeyUsageExhausted
+		}
+		if key.Metered && key.Credits < cost {
+			return key, 0, ErrInsufficientCredits
+		}
+	}
+
+	window.count++
+	window.pending++
+	if key.Metered {
+		key.Credits -= cost
+	}
+	// Quota and metered keys are persisted on every request; others at most once per interval
+	if key.UsageLimit > 0 || key.Metered || now.Sub(window.lastFlush) >= apiKeyUsageFlushInterval {
+		key.Usage += window.pending
+		key.LastUsedAt = now
+		if err := store.SaveAPIKey(key); err != nil {
+			return nil, 0, fmt.Errorf("save API key usage: %w", err)
+		}
+		window.pending = 0
+		window.lastFlush = now
+	}
+	return key, 0, nil
+}
+
+// serveAPIKey handles a request presenting an API key. Machine clients get plain
+// HTTP status codes instead of the HTML payment page.
+//
+// Responses:
+//   - Calls next for valid keys
+//   - 401 Unauthorized for malformed, unknown, revoked or expired keys
+//   - 402 Payment Required when a metered key's credits cannot cover the request
+//   - 403 Forbidden when the key's usage limit is exhausted
+//   - 429 Too Many Requests (with Retry-After) when the rate limit is exceeded
+//   - 500 Internal Server Error on storage failures
+func (p *Paywall) serveAPIKey(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, raw string, next http.Handler) {
+	key, retryAfter, err := p.authenticateAPIKey(raw, cfg.cost(r))
+	if key != nil && key.Metered {
+		w.Header().Set(CreditsHeader, strconv.FormatInt(key.Credits, 10))
+	}
+	switch {
+	case err == nil:
+		info := &PaymentInfo{PaymentID: key.PaymentID, Method: AccessAPIKey, APIKeyID: key.ID}
+		p.forward(w, r, cfg, info, p.apiKeyGrant(key.ID), next)
+		return
+	case errors.Is(err, ErrInsufficientCredits):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, ErrAPIKeyRateLimited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, ErrAPIKeyUsageExhausted):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidAPIKey):
+		w.Header().Set("WWW-Authenticate", `APIKey header="`+p.apiKeyHeader+`"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "api_key_auth_failed",
+			Message: fmt.Sprintf("API key authentication failed: %v", err),
+		})
+		http.Error(w, "Failed to verify API key", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,228 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func apiKeyRequest(t *testing.T, handler http.Handler, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/data", nil)
+	if key != "" {
+		req.Header.Set(DefaultAPIKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeys_Middleware(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("paid content"))
+	}))
+
+	payment := confirmedTestPayment(t, pw)
+	key, record, err := pw.IssueAPIKey(payment.ID, APIKeyOptions{Label: "client"})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix+record.ID+"_") {
+		t.Errorf("key %q does not embed ID %s", key, record.ID)
+	}
+	if strings.Contains(record.SecretHash, strings.TrimPrefix(key, apiKeyPrefix+record.ID+"_")) {
+		t.Error("stored record must not contain the secret")
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"Valid", key, http.StatusOK},
+		{"WrongSecret", key[:len(key)-2] + "xx", http.StatusUnauthorized},
+		{"Malformed", "not-a-key", http.StatusUnauthorized},
+		{"PathTraversal", apiKeyPrefix + "../../../etc/pa_x", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apiKeyRequest(t, handler, tt.key)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("Revoked", func(t *testing.T) {
+		if err := pw.RevokeAPIKey(record.ID); err != nil {
+			t.Fatalf("RevokeAPIKey() error = %v", err)
+		}
+		if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401 after revocation", rec.Code)
+		}
+	})
+
+	t.Run("NoKeyFallsBackToPaymentPage", func(t *testing.T) {
+		rec := apiKeyRequest(t, handler, "")
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "paid content") {
+			t.Errorf("request without key should get the payment page, got %d", rec.Code)
+		}
+	})
+}
+
+func TestAPIKeys_Limits(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("RateLimit", func(t *testing.T) {
+		key, _, err := pw.CreateAPIKey(APIKeyOptions{RateLimit: 2})
+		if err != nil {
+			t.Fatalf("CreateAPIKey() error = %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusOK {
+				t.Fatalf("request %d status = %d, want 200", i, rec.Code)
+			}
+		}
+		rec := apiKeyRequest(t, handler, key)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("429 response missing Retry-After")
+		}
+	})
+
+	t.Run("UsageLimit", func(t *testing.T) {
+		key, record, err := pw.CreateAPIKey(APIKeyOptions{UsageLimit: 3})
+		if err != nil {
+			t.Fatalf("CreateAPIKey() error = %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusOK {
+				t.Fatalf("request %d status = %d, want 200", i, rec.Code)
+			}
+		}
+		if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403 after usage limit", rec.Code)
+		}
+		stored, _ := pw.Store.(APIKeyStore).GetAPIKey(record.ID)
+		if stored.Usage != 3 {
+			t.Errorf("stored Usage = %d, want 3", stored.Usage)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		key, record, _ := pw.CreateAPIKey(APIKeyOptions{TTL: time.Hour})
+		record.ExpiresAt = time.Now().Add(-time.Second)
+		pw.Store.(APIKeyStore).SaveAPIKey(record)
+		if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401 for expired key", rec.Code)
+		}
+	})
+}
+
+func TestAPIKeys_TiedToPayment(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, _, err := pw.IssueAPIKey(pending.ID, APIKeyOptions{}); err == nil {
+		t.Error("IssueAPIKey() should reject unconfirmed payments")
+	}
+
+	payment := confirmedTestPayment(t, pw)
+	key, _, err := pw.IssueAPIKey(payment.ID, APIKeyOptions{})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+
+	// The key outlives the payment's access window...
+	payment, _ = pw.Store.GetPayment(payment.ID)
+	payment.ExpiresAt = time.Now().Add(-time.Minute)
+	pw.Store.UpdatePayment(payment)
+	if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 after payment window", rec.Code)
+	}
+
+	// ...but not the payment leaving the confirmed state
+	payment, _ = pw.Store.GetPayment(payment.ID)
+	payment.Status = StatusExpired
+	pw.Store.UpdatePayment(payment)
+	if rec := apiKeyRequest(t, handler, key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401 for unconfirmed payment", rec.Code)
+	}
+}
+
+func TestAPIKeys_Disabled(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	if _, _, err := pw.CreateAPIKey(APIKeyOptions{}); !errors.Is(err, ErrAPIKeysDisabled) {
+		t.Errorf("CreateAPIKey() error = %v, want ErrAPIKeysDisabled", err)
+	}
+}
+
+func TestAPIKeyStores(t *testing.T) {
+	encrypted, err := NewEncryptedFileStore(t.TempDir()+"/store.key", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEncryptedFileStore() error = %v", err)
+	}
+	stores := map[string]APIKeyStore{
+		"MemoryStore":        NewMemoryStore(),
+		"FileStore":          NewFileStore(t.TempDir()),
+		"EncryptedFileStore": encrypted,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if key, err := store.GetAPIKey("0123456789abcdef"); err != nil || key != nil {
+				t.Fatalf("GetAPIKey(missing) = %v, %v; want nil, nil", key, err)
+			}
+			if keys, err := store.ListAPIKeys(); err != nil || len(keys) != 0 {
+				t.Fatalf("ListAPIKeys() on empty store = %v, %v", keys, err)
+			}
+
+			now := time.Now().UTC().Truncate(time.Second)
+			key := &APIKey{ID: "0123456789abcdef", SecretHash: "hash", Label: "a", CreatedAt: now, RevokedAt: &now}
+			if err := store.SaveAPIKey(key); err != nil {
+				t.Fatalf("SaveAPIKey() error = %v", err)
+			}
+			got, err := store.GetAPIKey(key.ID)
+			if err != nil || got == nil || got.Label != "a" || got.RevokedAt == nil || !got.RevokedAt.Equal(now) {
+				t.Fatalf("GetAPIKey() = %+v, %v", got, err)
+			}
+
+			key.Label = "b"
+			store.SaveAPIKey(key)
+			keys, _ := store.ListAPIKeys()
+			if len(keys) != 1 || keys[0].Label != "b" {
+				t.Errorf("ListAPIKeys() = %+v, want one updated key", keys)
+			}
+
+			// API keys must not show up as payments
+			if lister, ok := store.(PaymentLister); ok {
+				if payments, _ := lister.ListPayments(); len(payments) != 0 {
+					t.Errorf("ListPayments() returned %d records, want 0", len(payments))
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfig_APIKeysRequireStore(t *testing.T) {
+	_, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          &mockStore{},
+		APIKeysEnabled: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "APIKeyStore") {
+		t.Errorf("NewPaywall() error = %v, want APIKeyStore requirement", err)
+	}
+}
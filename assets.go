@@ -0,0 +1,124 @@
+package paywall
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assetCacheControl is sent with fingerprinted assets: their URL changes with
+// their content, so browsers may keep them forever
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// qrcodeScriptAsset is the name of the embedded QR code library
+const qrcodeScriptAsset = "qrcode.min.js"
+
+// staticAsset is an embedded file served by HandleAsset
+type staticAsset struct {
+	data        []byte
+	contentType string
+	// hash is the content fingerprint, part of the asset's URL
+	hash string
+	// fingerprinted is the file name with the hash, e.g. qrcode.1a2b3c4d5e6f7a8b.min.js
+	fingerprinted string
+}
+
+var (
+	// staticAssets maps plain and fingerprinted file names to the embedded assets
+	staticAssets     map[string]*staticAsset
+	staticAssetsOnce sync.Once
+)
+
+// loadStaticAssets fingerprints the files embedded in QrcodeJs once
+func loadStaticAssets() map[string]*staticAsset {
+	staticAssetsOnce.Do(func() {
+		staticAssets = make(map[string]*staticAsset)
+		fs.WalkDir(QrcodeJs, "static", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, err := QrcodeJs.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			base := path.Base(name)
+			asset := &staticAsset{
+				data:          data,
+				contentType:   mime.TypeByExtension(path.Ext(base)),
+				hash:          hex.EncodeToString(sum[:8]),
+				fingerprinted: base,
+			}
+			if stem, rest, ok := strings.Cut(base, "."); ok {
+				asset.fingerprinted = stem + "." + asset.hash + "." + rest
+			}
+			staticAssets[base] = asset
+			staticAssets[asset.fingerprinted] = asset
+			return nil
+		})
+	})
+	return staticAssets
+}
+
+// assetURL returns the fingerprinted URL of an embedded asset under
+// Config.AssetPath, empty if the asset does not exist
+func (p *Paywall) assetURL(name string) string {
+	asset, ok := loadStaticAssets()[name]
+	if !ok {
+		return ""
+	}
+	return p.assetPath + asset.fingerprinted
+}
+
+// HandleAsset serves the payment page's static files (the QR code library)
+// under fingerprinted names such as qrcode.1a2b3c4d5e6f7a8b.min.js, with a
+// one-year immutable cache lifetime. The page links to the fingerprint of the
+// running version, so an upgrade takes effect on the next page load while the
+// unchanged script is never downloaded twice.
+//
+// Mount it at Config.AssetPath, e.g. http.Handle("/paywall/assets/", http.HandlerFunc(pw.HandleAsset)).
+// A page rendered by an older version may ask for a fingerprint that no longer
+// exists; it gets the current file, which is not cached.
+//
+// Responses:
+//   - 200 with the asset
+//   - 404 Not Found for unknown files
+//   - 405 Method Not Allowed for non-GET requests
+func (p *Paywall) HandleAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Base(r.URL.Path)
+	assets := loadStaticAssets()
+	asset, ok := assets[name]
+	if !ok {
+		// Outdated fingerprint: look up the plain name
+		stem, rest, _ := strings.Cut(name, ".")
+		if _, plain, found := strings.Cut(rest, "."); found {
+			asset, ok = assets[stem+"."+plain]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	cacheControl := assetCacheControl
+	if name != asset.fingerprinted {
+		cacheControl = "no-cache"
+	}
+	w.Header().Set("Content-Type", asset.contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", `"`+asset.hash+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(asset.data))
+}
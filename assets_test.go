@@ -0,0 +1,81 @@
+package paywall
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleAsset(t *testing.T) {
+	pw := &Paywall{assetPath: "/paywall/assets/"}
+	scriptURL := pw.assetURL(qrcodeScriptAsset)
+	if !strings.HasPrefix(scriptURL, "/paywall/assets/qrcode.") || !strings.HasSuffix(scriptURL, ".min.js") || scriptURL == "/paywall/assets/qrcode.min.js" {
+		t.Fatalf("assetURL() = %q, want a fingerprinted qrcode URL", scriptURL)
+	}
+	asset := loadStaticAssets()[qrcodeScriptAsset]
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		ifNoneMatch  string
+		wantCode     int
+		wantCache    string
+		wantBodySize int
+	}{
+		{name: "fingerprinted", method: http.MethodGet, path: scriptURL, wantCode: http.StatusOK, wantCache: assetCacheControl, wantBodySize: len(asset.data)},
+		{name: "plain name", method: http.MethodGet, path: "/paywall/assets/qrcode.min.js", wantCode: http.StatusOK, wantCache: "no-cache", wantBodySize: len(asset.data)},
+		{name: "outdated fingerprint", method: http.MethodGet, path: "/paywall/assets/qrcode.0000000000000000.min.js", wantCode: http.StatusOK, wantCache: "no-cache", wantBodySize: len(asset.data)},
+		{name: "revalidation", method: http.MethodGet, path: scriptURL, ifNoneMatch: `"` + asset.hash + `"`, wantCode: http.StatusNotModified, wantCache: assetCacheControl},
+		{name: "unknown", method: http.MethodGet, path: "/paywall/assets/app.js", wantCode: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, path: scriptURL, wantCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			pw.HandleAsset(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Cache-Control"); tt.wantCache != "" && got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if rec.Body.Len() != tt.wantBodySize && tt.wantCode != http.StatusNotFound && tt.wantCode != http.StatusMethodNotAllowed {
+				t.Errorf("body size = %d, want %d", rec.Body.Len(), tt.wantBodySize)
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(rec.Header().Get("Content-Type"), "javascript") {
+				t.Errorf("Content-Type = %q, want JavaScript", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestPaymentPage_AssetPath(t *testing.T) {
+	pw := createTestPaywall()
+	pw.assetPath = "/paywall/assets/"
+	data := pw.paymentPageData(nil, createHandlerTestPayment())
+	if data.QrcodeScriptURL != pw.assetURL(qrcodeScriptAsset) {
+		t.Errorf("QrcodeScriptURL = %q, want %q", data.QrcodeScriptURL, pw.assetURL(qrcodeScriptAsset))
+	}
+	if data.QrcodeJs != "" {
+		t.Error("QR code script still inlined with AssetPath set")
+	}
+
+	tmpl, err := template.ParseFS(TemplateFS, "templates/payment.html")
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+	var page strings.Builder
+	if err := tmpl.Execute(&page, data); err != nil {
+		t.Fatalf("execute template: %v", err)
+	}
+	if !strings.Contains(page.String(), `<script id="qr" src="`+data.QrcodeScriptURL+`"></script>`) {
+		t.Error("payment page does not load the fingerprinted script")
+	}
+}
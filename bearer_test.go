@@ -0,0 +1,108 @@
+package paywall
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_BearerToken(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:          0.001,
+		PaymentTimeout:      10 * time.Minute,
+		AccessGrantTTL:      time.Hour,
+		BearerTokensEnabled: true,
+		StatusPath:          "/paywall/status",
+		JSONResponses:       true,
+		TestNet:             true,
+		Store:               NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := FromContext(r.Context())
+		w.Write([]byte("content via " + string(info.Method)))
+	}))
+	call := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/report", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	status := func(paymentID string) PaymentStatusResponse {
+		rec := httptest.NewRecorder()
+		pw.HandlePaymentStatus(rec, httptest.NewRequest(http.MethodGet, "/paywall/status/"+paymentID, nil))
+		var resp PaymentStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		return resp
+	}
+
+	// Without a token the client is asked to pay
+	rec := call("")
+	if rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("unpaid status = %d, want 402", rec.Code)
+	}
+	var required PaymentRequiredResponse
+	json.NewDecoder(rec.Body).Decode(&required)
+	if resp := status(required.PaymentID); resp.AccessToken != "" {
+		t.Errorf("pending payment status has an access token")
+	}
+
+	// Once confirmed, the status carries a token the middleware accepts
+	payment, _ := pw.Store.GetPayment(required.PaymentID)
+	pw.markConfirmed(payment, time.Now())
+	pw.Store.UpdatePayment(payment)
+	resp := status(payment.ID)
+	if resp.AccessToken == "" || resp.AccessTokenExpiresAt == nil || resp.AccessTokenExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("confirmed payment status = %+v, want an access token for at most AccessGrantTTL", resp)
+	}
+	if rec := call("Bearer " + resp.AccessToken); rec.Body.String() != "content via bearer_token" {
+		t.Errorf("bearer response = %d %q, want content", rec.Code, rec.Body.String())
+	}
+
+	expired, _ := pw.mintAccessGrant(payment, time.Now().Add(-2*time.Hour))
+	for _, authorization := range []string{"Bearer " + resp.AccessToken + "x", "Bearer " + expired} {
+		rec := call(authorization)
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+			t.Errorf("%q: status = %d, challenge = %q, want 401 invalid_token", authorization, rec.Code, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+	// Other schemes and bearer tokens that are not access grants, such as the
+	// application's own, are left to the cookie flow
+	for _, authorization := range []string{"Basic dXNlcjpwYXNz", "Bearer not-a-token", "Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln"} {
+		if rec := call(authorization); rec.Code != http.StatusPaymentRequired {
+			t.Errorf("%q: status = %d, want 402", authorization, rec.Code)
+		}
+	}
+}
+
+func TestBearerTokensConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"without access grants", Config{BearerTokensEnabled: true}, "AccessGrantTTL"},
+		{"API keys in Authorization", Config{BearerTokensEnabled: true, AccessGrantTTL: time.Hour, APIKeysEnabled: true, APIKeyHeader: "authorization"}, "APIKeyHeader"},
+	}
+	for _, tt := range tests {
+		tt.config.PriceInBTC = 0.001
+		tt.config.PaymentTimeout = time.Hour
+		tt.config.TestNet = true
+		tt.config.Store = NewMemoryStore()
+		_, err := NewPaywall(tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: NewPaywall() error = %v, want one mentioning %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -192,6 +193,75 @@ func (b *BTCBroadcaster) ValidateTransaction(txBytes []byte, payment *Payment) e
 	return nil
 }
 
+// GetRawTransaction retrieves a serialized transaction from the node's mempool or,
+// for mined transactions, its transaction index (bitcoind -txindex)
+// Parameters:
+//   - txID: Transaction hash in hex
+//
+// Returns:
+//   - []byte: Raw transaction bytes
+//   - error: If the hash is malformed or the node does not know the transaction
+func (b *BTCBroadcaster) GetRawTransaction(txID string) ([]byte, error) {
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction id: %w", err)
+	}
+	tx, err := b.client.GetRawTransaction(hash)
+	if err != nil {
+		return nil, fmt.Errorf("get raw transaction: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("serialize transaction: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// GetTransactionConfirmations returns the number of confirmations of a transaction
+// known to the node, 0 while it is in the mempool
+//
+// Returns:
+//   - int: Confirmations
+//   - error: If the hash is malformed or the node does not know the transaction
+func (b *BTCBroadcaster) GetTransactionConfirmations(txID string) (int, error) {
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid transaction id: %w", err)
+	}
+	result, err := b.client.GetRawTransactionVerbose(hash)
+	if err != nil {
+		return 0, fmt.Errorf("get raw transaction: %w", err)
+	}
+	return int(result.Confirmations), nil
+}
+
+// Refund implements RefundSigner by sending amount to address from the node's
+// wallet (sendtoaddress), which must hold enough funds and be unlocked. The
+// keys of the payment addresses are not used, so the refund comes from the
+// node's own balance.
+//
+// Returns:
+//   - string: The refund's transaction ID
+//   - error: For other currencies, addresses of another network or RPC failures
+func (b *BTCBroadcaster) Refund(currency wallet.WalletType, address string, amount float64) (string, error) {
+	if currency != wallet.Bitcoin {
+		return "", fmt.Errorf("BTCBroadcaster cannot refund %s", currency)
+	}
+	addr, err := btcutil.DecodeAddress(address, b.network)
+	if err != nil || !addr.IsForNet(b.network) {
+		return "", fmt.Errorf("invalid refund address %s for %s", address, b.network.Name)
+	}
+	sats, err := btcutil.NewAmount(amount)
+	if err != nil {
+		return "", fmt.Errorf("invalid refund amount: %w", err)
+	}
+	hash, err := b.client.SendToAddress(addr, sats)
+	if err != nil {
+		return "", fmt.Errorf("send to address: %w", err)
+	}
+	return hash.String(), nil
+}
+
 // GetLatestBlockTime retrieves the timestamp of the latest Bitcoin block
 func (b *BTCBroadcaster) GetLatestBlockTime() (time.Time, error) {
 	if b.client == nil {
@@ -0,0 +1,54 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// backendHealthInterval is how often the health of Config.BTCBackends is checked
+const backendHealthInterval = time.Minute
+
+// backendClient verifies Bitcoin payments with a wallet.BackendRegistry
+type backendClient struct {
+	*wallet.BackendRegistry
+	minConf int
+}
+
+// GetAddressBalance implements CryptoClient with the paywall's minimum confirmations
+func (c backendClient) GetAddressBalance(address string) (float64, error) {
+	return c.GetAddressBalanceMinConf(address, c.minConf)
+}
+
+// GetAddressBalanceContext implements ContextCryptoClient
+func (c backendClient) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	return c.GetAddressBalanceMinConfContext(ctx, address, c.minConf)
+}
+
+// initializeBitcoinClient returns the client Bitcoin payments are verified
+// with when Config.BTCBackends or Config.BTCExplorer is set, nil to verify
+// them with the Bitcoin wallet. The configuration is checked by validateConfig
+// and no request is made until the monitor runs.
+func initializeBitcoinClient(config Config) CryptoClient {
+	if config.BTCBackends != nil {
+		return backendClient{BackendRegistry: config.BTCBackends, minConf: config.MinConfirmations}
+	}
+	if config.BTCExplorer == nil {
+		return nil
+	}
+	explorer, err := wallet.NewEsploraClient(*config.BTCExplorer, config.TestNet, config.MinConfirmations)
+	if err != nil {
+		// Already validated; keep verifying with the node
+		if config.Logger != nil {
+			config.Logger.log(LogEntry{
+				Level:   LogLevelWarn,
+				Event:   "btc_explorer_init_failed",
+				Message: fmt.Sprintf("Bitcoin explorer configured but unusable: %v. Verifying with the node.", err),
+			})
+		}
+		return nil
+	}
+	return explorer
+}
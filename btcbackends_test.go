@@ -0,0 +1,68 @@
+package paywall
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestBTCExplorerConfig(t *testing.T) {
+	config := Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		BTCExplorer:    &wallet.EsploraConfig{URLs: []string{"mempool.space/api"}},
+	}
+	if _, err := NewPaywall(config); err == nil || !strings.Contains(err.Error(), "BTCExplorer") {
+		t.Errorf("NewPaywall() with a relative explorer URL error = %v, want a BTCExplorer error", err)
+	}
+
+	config.BTCExplorer = &wallet.EsploraConfig{URLs: []string{"http://127.0.0.1:3002"}}
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	if _, ok := pw.monitor.client[wallet.Bitcoin].(*wallet.EsploraClient); !ok {
+		t.Errorf("Bitcoin payments verified with %T, want the explorer", pw.monitor.client[wallet.Bitcoin])
+	}
+	if _, ok := pw.monitor.client[wallet.Bitcoin].(ConfirmationCountClient); !ok {
+		t.Error("explorer does not report confirmations")
+	}
+}
+
+func TestBTCBackendsConfig(t *testing.T) {
+	registry := wallet.NewBackendRegistry(wallet.BackendRegistryConfig{})
+	config := Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		BTCExplorer:    &wallet.EsploraConfig{},
+		BTCBackends:    registry,
+	}
+	if _, err := NewPaywall(config); err == nil || !strings.Contains(err.Error(), "BTCBackends") {
+		t.Errorf("NewPaywall() with BTCExplorer and BTCBackends error = %v", err)
+	}
+
+	config.BTCExplorer = nil
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	client, ok := pw.monitor.client[wallet.Bitcoin].(backendClient)
+	if !ok || client.BackendRegistry != registry {
+		t.Fatalf("Bitcoin payments verified with %T, want the registry", pw.monitor.client[wallet.Bitcoin])
+	}
+	// Backends registered later are used
+	explorer, _ := wallet.NewEsploraClient(wallet.EsploraConfig{URLs: []string{"http://127.0.0.1:1"}}, true, 1)
+	registry.Register(explorer, 0)
+	if _, err := client.GetAddressBalance("mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"); err == nil || errors.Is(err, wallet.ErrNoBackendAvailable) {
+		t.Errorf("GetAddressBalance() error = %v, want the explorer's", err)
+	}
+}
@@ -0,0 +1,347 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	// ErrInvalidTxSubmission is returned for missing or undecodable transactions
+	ErrInvalidTxSubmission = errors.New("invalid transaction submission")
+	// ErrTxRejected is returned when the node rejects or does not know a submitted transaction
+	ErrTxRejected = errors.New("transaction rejected by the bitcoin node")
+)
+
+// BitcoinTxSubmitter broadcasts payer-submitted transactions and looks up known
+// ones. *BTCBroadcaster implements it when Config.BTCRPCHost is set.
+type BitcoinTxSubmitter interface {
+	Broadcast(txBytes []byte) (string, error)
+	GetRawTransaction(txID string) ([]byte, error)
+}
+
+// BitcoinTxSubmission is a payer's Bitcoin transaction for a payment.
+// Provide RawTx (hex of the signed transaction, broadcast if the network does not
+// have it yet) or TxID (of an already broadcast transaction).
+type BitcoinTxSubmission struct {
+	// PaymentID selects the payment; HandleBitcoinTransaction falls back to the payment cookie
+	PaymentID string `json:"payment_id,omitempty"`
+	// RawTx is the hex-encoded signed transaction
+	RawTx string `json:"raw_tx,omitempty"`
+	// TxID is the transaction hash
+	TxID string `json:"txid,omitempty"`
+}
+
+// BitcoinTxResult reports the outcome of a transaction submission
+type BitcoinTxResult struct {
+	PaymentID string        `json:"payment_id"`
+	Status    PaymentStatus `json:"status"`
+	TxID      string        `json:"txid"`
+	Received  float64       `json:"received"`
+	Required  float64       `json:"required"`
+}
+
+// SubmitBitcoinTransaction validates that a payer's transaction pays the payment's
+// Bitcoin address and amount, broadcasts it when given as raw hex, and moves the
+// payment to StatusDetected immediately instead of waiting for public API polling.
+// The monitor still confirms the payment once the transaction has
+// Config.MinConfirmations; detection alone does not grant access.
+//
+// Parameters:
+//   - submission: Raw transaction or txid; PaymentID is required
+//
+// Returns:
+//   - *BitcoinTxResult: Transaction ID, paid amount and resulting status
+//   - error: ErrInvalidTxSubmission, ErrProofNotSupported, ErrProofPaymentNotFound,
+//     ErrProofPaymentNotPending, ErrProofInsufficient, ErrTxRejected,
+//     ErrTransitionVetoed, ErrPaymentLocked, or RPC/storage errors
+//
+// Related: HandleBitcoinTransaction, BitcoinTxSubmitter
+func (p *Paywall) SubmitBitcoinTransaction(submission BitcoinTxSubmission) (*BitcoinTxResult, error) {
+	submission.RawTx = strings.TrimSpace(submission.RawTx)
+	submission.TxID = strings.TrimSpace(submission.TxID)
+	if submission.RawTx == "" && submission.TxID == "" {
+		return nil, fmt.Errorf("%w: raw_tx or txid is required", ErrInvalidTxSubmission)
+	}
+
+	unlock, err := p.lockPayment(submission.PaymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(submission.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	address, ok := payment.Addresses[wallet.Bitcoin]
+	if !ok || address == "" {
+		return nil, fmt.Errorf("payment %s has no Bitcoin address: %w", payment.ID, ErrProofPaymentNotPending)
+	}
+
+	result := &BitcoinTxResult{
+		PaymentID: payment.ID,
+		Status:    payment.Status,
+		TxID:      payment.DetectedTxID,
+		Required:  payment.Amounts[wallet.Bitcoin],
+	}
+	if payment.Status == StatusConfirmed || payment.Status == StatusDetected {
+		return result, nil
+	}
+	if payment.Status != StatusPending || !time.Now().Before(payment.ExpiresAt) {
+		return nil, ErrProofPaymentNotPending
+	}
+	if p.btcTxSubmitter == nil {
+		return nil, ErrProofNotSupported
+	}
+
+	var txBytes []byte
+	if submission.RawTx != "" {
+		if txBytes, err = hex.DecodeString(submission.RawTx); err != nil {
+			return nil, fmt.Errorf("%w: raw transaction is not hex: %v", ErrInvalidTxSubmission, err)
+		}
+	} else {
+		if txBytes, err = p.btcTxSubmitter.GetRawTransaction(submission.TxID); err != nil {
+			return nil, classifyTxError(err)
+		}
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTxSubmission, err)
+	}
+	result.TxID = tx.TxHash().String()
+	if submission.TxID != "" && submission.RawTx == "" && result.TxID != submission.TxID {
+		return nil, fmt.Errorf("node returned transaction %s for %s: %w", result.TxID, submission.TxID, ErrTxRejected)
+	}
+
+	received, err := btcAmountPaidTo(tx, address)
+	if err != nil {
+		return nil, err
+	}
+	result.Received = received
+	if received < result.Required {
+		return result, ErrProofInsufficient
+	}
+
+	if submission.RawTx != "" {
+		if _, err := p.btcTxSubmitter.Broadcast(txBytes); err != nil {
+			// Payers often submit transactions their wallet already broadcast
+			if _, lookupErr := p.btcTxSubmitter.GetRawTransaction(result.TxID); lookupErr != nil {
+				return nil, classifyTxError(err)
+			}
+		}
+	}
+
+	detected := &PaymentTransition{
+		Event:    TransitionDetect,
+		From:     payment.Status,
+		To:       StatusDetected,
+		Payment:  payment,
+		Currency: wallet.Bitcoin,
+		Amount:   received,
+		TxID:     result.TxID,
+	}
+	err = p.transition(detected, func(t *PaymentTransition) error {
+		t.Payment.Status = StatusDetected
+		t.Payment.DetectedTxID = t.TxID
+		return p.Store.UpdatePayment(t.Payment)
+	})
+	if errors.Is(err, ErrTransitionVetoed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	result.Status = StatusDetected
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "payment_detected",
+		Message:   fmt.Sprintf("Payer submitted transaction %s, awaiting confirmations", result.TxID),
+		PaymentID: payment.ID,
+		Currency:  wallet.Bitcoin,
+		Amount:    received,
+	})
+	p.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentDetected,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"amount":   received,
+			"currency": wallet.Bitcoin,
+			"txid":     result.TxID,
+		},
+	})
+	return result, nil
+}
+
+// classifyTxError wraps node-side rejections (RPC errors, as opposed to
+// transport failures) with ErrTxRejected
+func classifyTxError(err error) error {
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		return fmt.Errorf("%w: %s", ErrTxRejected, rpcErr.Message)
+	}
+	return err
+}
+
+// btcAmountPaidTo sums the outputs of tx that pay address, in BTC
+func btcAmountPaidTo(tx *wire.MsgTx, address string) (float64, error) {
+	script, err := btcOutputScript(address)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, out := range tx.TxOut {
+		if bytes.Equal(out.PkScript, script) {
+			total += out.Value
+		}
+	}
+	return btcutil.Amount(total).ToBTC(), nil
+}
+
+// btcOutputScript returns the output script paying address. The script does not
+// depend on the network, which only selects the accepted address encodings.
+func btcOutputScript(address string) ([]byte, error) {
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params, &chaincfg.RegressionNetParams} {
+		if addr, err := btcutil.DecodeAddress(address, params); err == nil {
+			return txscript.PayToAddrScript(addr)
+		}
+	}
+	return nil, fmt.Errorf("invalid bitcoin address: %s", address)
+}
+
+// HandleBitcoinTransaction accepts a payer's signed raw transaction or txid so the
+// payment is marked detected without waiting for blockchain polling.
+// Mount it at Config.BTCTxSubmitPath to enable the payment page form.
+//
+// Requests are POSTs with either a JSON BitcoinTxSubmission or the form field "tx"
+// (a txid or raw transaction hex, as pasted into the payment page form). The
+// payment is taken from payment_id or else the payment cookie. Successful form
+// posts redirect back to the referring page; JSON requests get a BitcoinTxResult.
+//
+// Responses:
+//   - 200 with a BitcoinTxResult, or 303 back to the payment page for form posts
+//   - 400 Bad Request for malformed submissions
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 404 Not Found if the payment does not exist
+//   - 403 Forbidden if a payment hook refused the transaction
+//   - 409 Conflict if the payment expired or has no Bitcoin option
+//   - 422 Unprocessable Entity if the node rejects the transaction or the amount is short
+//   - 429 Too Many Requests (with Retry-After) beyond Config.RateLimits
+//   - 501 Not Implemented if no Bitcoin node RPC is configured
+//   - 502 Bad Gateway if the node is unreachable
+//   - 503 Service Unavailable if another operation holds the payment's lock
+func (p *Paywall) HandleBitcoinTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitSubmit) {
+		return
+	}
+
+	var submission BitcoinTxSubmission
+	form, ok := decodeRequestBody(w, r, p.requestLimits(), &submission, true)
+	if !ok {
+		return
+	}
+	isForm := form != nil
+	if isForm {
+		submission.PaymentID = form.Get("payment_id")
+		tx := strings.TrimSpace(form.Get("tx"))
+		if len(tx) == 64 {
+			submission.TxID = tx
+		} else {
+			submission.RawTx = tx
+		}
+	}
+	if submission.PaymentID == "" {
+		id, err := paymentIDFromCookie(r)
+		if err != nil {
+			http.Error(w, "payment_id is required", http.StatusBadRequest)
+			return
+		}
+		submission.PaymentID = id
+	}
+
+	result, err := p.SubmitBitcoinTransaction(submission)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrProofNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, ErrProofPaymentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrProofPaymentNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrTransitionVetoed):
+		http.Error(w, "Transaction not accepted for this payment", http.StatusForbidden)
+		return
+	case errors.Is(err, ErrPaymentLocked):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Payment is being updated, please try again", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, ErrProofInsufficient), errors.Is(err, ErrTxRejected):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, ErrInvalidTxSubmission):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		p.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "btc_tx_submission_failed",
+			Message:   fmt.Sprintf("Bitcoin transaction submission failed: %v", err),
+			PaymentID: submission.PaymentID,
+			Currency:  wallet.Bitcoin,
+		})
+		http.Error(w, "Transaction could not be checked with the Bitcoin node", http.StatusBadGateway)
+		return
+	}
+
+	if isForm {
+		if back := sameOriginReferer(r); back != "" {
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode Bitcoin transaction response: %v", err),
+		})
+	}
+}
+
+// sameOriginReferer returns the path and query of the Referer header when it
+// points at the request's own host, "" otherwise
+func sameOriginReferer(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Host != r.Host || !strings.HasPrefix(ref.Path, "/") {
+		return ""
+	}
+	return ref.RequestURI()
+}
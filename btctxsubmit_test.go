@@ -0,0 +1,229 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeTxSubmitter is an in-memory node that accepts broadcasts and serves known transactions
+type fakeTxSubmitter struct {
+	known        map[string][]byte
+	broadcastErr error
+	broadcasts   int
+}
+
+func (f *fakeTxSubmitter) Broadcast(txBytes []byte) (string, error) {
+	f.broadcasts++
+	if f.broadcastErr != nil {
+		return "", f.broadcastErr
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.Deserialize(bytes.NewReader(txBytes))
+	f.known[tx.TxHash().String()] = txBytes
+	return tx.TxHash().String(), nil
+}
+
+func (f *fakeTxSubmitter) GetRawTransaction(txID string) ([]byte, error) {
+	raw, ok := f.known[txID]
+	if !ok {
+		return nil, &btcjson.RPCError{Code: btcjson.ErrRPCNoTxInfo, Message: "No such mempool or blockchain transaction"}
+	}
+	return raw, nil
+}
+
+// testBTCTx builds a serialized transaction paying satoshis to each address
+func testBTCTx(t *testing.T, outputs map[string]int64) (string, []byte) {
+	t.Helper()
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	for address, value := range outputs {
+		addr, err := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+		if err != nil {
+			t.Fatalf("DecodeAddress(%s) error = %v", address, err)
+		}
+		script, _ := txscript.PayToAddrScript(addr)
+		tx.AddTxOut(wire.NewTxOut(value, script))
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	return tx.TxHash().String(), buf.Bytes()
+}
+
+func testnetAddress(t *testing.T, b byte) string {
+	t.Helper()
+	addr, err := btcutil.NewAddressPubKeyHash(bytes.Repeat([]byte{b}, 20), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash() error = %v", err)
+	}
+	return addr.EncodeAddress()
+}
+
+func newBTCTxTestPaywall(t *testing.T) (*Paywall, *Payment, *fakeTxSubmitter) {
+	t.Helper()
+	pw := newTestPaywall(t, Config{BTCTxSubmitPath: "/paywall/btc-tx"})
+
+	payment := &Payment{
+		ID:        "btc-tx-payment",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: testnetAddress(t, 1)},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	if err := pw.Store.CreatePayment(payment); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	submitter := &fakeTxSubmitter{known: make(map[string][]byte)}
+	pw.btcTxSubmitter = submitter
+	return pw, payment, submitter
+}
+
+func TestSubmitBitcoinTransaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		outputs       map[byte]int64
+		asTxID        bool
+		alreadyKnown  bool
+		broadcastErr  error
+		wantErr       error
+		wantBroadcast int
+	}{
+		{name: "raw tx with change is broadcast", outputs: map[byte]int64{1: 100000, 2: 5000}, wantBroadcast: 1},
+		{name: "exact amount", outputs: map[byte]int64{1: 100000}, wantBroadcast: 1},
+		{name: "known txid", outputs: map[byte]int64{1: 100000}, asTxID: true, alreadyKnown: true},
+		{name: "unknown txid", outputs: map[byte]int64{1: 100000}, asTxID: true, wantErr: ErrTxRejected},
+		{name: "underpayment is not broadcast", outputs: map[byte]int64{1: 99999, 2: 1000000}, wantErr: ErrProofInsufficient},
+		{name: "rejected broadcast", outputs: map[byte]int64{1: 100000}, broadcastErr: &btcjson.RPCError{Code: btcjson.ErrRPCVerify, Message: "bad-txns-inputs-missingorspent"}, wantErr: ErrTxRejected, wantBroadcast: 1},
+		{name: "already broadcast by payer wallet", outputs: map[byte]int64{1: 100000}, alreadyKnown: true, broadcastErr: &btcjson.RPCError{Code: btcjson.ErrRPCVerifyAlreadyInChain, Message: "txn-already-known"}, wantBroadcast: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, payment, submitter := newBTCTxTestPaywall(t)
+			submitter.broadcastErr = tt.broadcastErr
+			outputs := make(map[string]int64)
+			for b, v := range tt.outputs {
+				outputs[testnetAddress(t, b)] = v
+			}
+			txID, raw := testBTCTx(t, outputs)
+			if tt.alreadyKnown {
+				submitter.known[txID] = raw
+			}
+
+			submission := BitcoinTxSubmission{PaymentID: payment.ID, RawTx: hex.EncodeToString(raw)}
+			if tt.asTxID {
+				submission = BitcoinTxSubmission{PaymentID: payment.ID, TxID: txID}
+			}
+			result, err := pw.SubmitBitcoinTransaction(submission)
+			if submitter.broadcasts != tt.wantBroadcast {
+				t.Errorf("broadcasts = %d, want %d", submitter.broadcasts, tt.wantBroadcast)
+			}
+			stored, _ := pw.Store.GetPayment(payment.ID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SubmitBitcoinTransaction() error = %v, want %v", err, tt.wantErr)
+				}
+				if stored.Status != StatusPending {
+					t.Errorf("status = %s after failed submission, want pending", stored.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SubmitBitcoinTransaction() error = %v", err)
+			}
+			if result.Status != StatusDetected || result.TxID != txID {
+				t.Errorf("result = %+v, want detected %s", result, txID)
+			}
+			if stored.Status != StatusDetected || stored.DetectedTxID != txID {
+				t.Errorf("stored status = %s txid = %s, want detected %s", stored.Status, stored.DetectedTxID, txID)
+			}
+		})
+	}
+}
+
+func TestSubmitBitcoinTransaction_NoNode(t *testing.T) {
+	pw, payment, _ := newBTCTxTestPaywall(t)
+	pw.btcTxSubmitter = nil
+	_, raw := testBTCTx(t, map[string]int64{payment.Addresses[wallet.Bitcoin]: 100000})
+	_, err := pw.SubmitBitcoinTransaction(BitcoinTxSubmission{PaymentID: payment.ID, RawTx: hex.EncodeToString(raw)})
+	if !errors.Is(err, ErrProofNotSupported) {
+		t.Errorf("error = %v, want ErrProofNotSupported", err)
+	}
+}
+
+func TestHandleBitcoinTransaction(t *testing.T) {
+	t.Run("form post redirects back to the payment page", func(t *testing.T) {
+		pw, payment, _ := newBTCTxTestPaywall(t)
+		_, raw := testBTCTx(t, map[string]int64{payment.Addresses[wallet.Bitcoin]: 100000})
+		form := url.Values{"tx": {hex.EncodeToString(raw)}}
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/paywall/btc-tx", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Referer", "http://example.com/article?id=7")
+		req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+		rec := httptest.NewRecorder()
+		pw.HandleBitcoinTransaction(rec, req)
+
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/article?id=7" {
+			t.Fatalf("status = %d location = %q, want 303 to /article?id=7", rec.Code, rec.Header().Get("Location"))
+		}
+
+		// The detected payment keeps showing the payment page, now with a notice
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/article", nil)
+		req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+		pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("detected payment must not grant access")
+		})).ServeHTTP(rec, req)
+		if !strings.Contains(rec.Body.String(), "Transaction detected") {
+			t.Error("payment page does not show the detected notice")
+		}
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"txid via JSON", `{"payment_id":"btc-tx-payment","txid":"known"}`, http.StatusOK},
+		{"missing transaction", `{"payment_id":"btc-tx-payment"}`, http.StatusBadRequest},
+		{"malformed hex", `{"payment_id":"btc-tx-payment","raw_tx":"zz"}`, http.StatusBadRequest},
+		{"unknown payment", `{"payment_id":"missing","raw_tx":"00"}`, http.StatusNotFound},
+		{"unknown txid", `{"payment_id":"btc-tx-payment","txid":"` + strings.Repeat("ab", 32) + `"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, payment, submitter := newBTCTxTestPaywall(t)
+			txID, raw := testBTCTx(t, map[string]int64{payment.Addresses[wallet.Bitcoin]: 100000})
+			submitter.known[txID] = raw
+			body := strings.Replace(tt.body, `"known"`, `"`+txID+`"`, 1)
+
+			req := httptest.NewRequest(http.MethodPost, "/paywall/btc-tx", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			pw.HandleBitcoinTransaction(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(rec.Body.String(), `"status":"detected"`) {
+				t.Errorf("body = %q, want detected status", rec.Body.String())
+			}
+		})
+	}
+}
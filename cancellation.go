@@ -0,0 +1,84 @@
+package paywall
+
+import "context"
+
+// The helpers below make a store or chain call with ctx: through the
+// ...Context method when the store or client has one, otherwise through the
+// plain method unless ctx is already done.
+
+// createPaymentContext is store.CreatePayment cancelled by ctx
+func createPaymentContext(ctx context.Context, store PaymentStore, payment *Payment) error {
+	if cs, ok := store.(ContextPaymentStore); ok {
+		return cs.CreatePaymentContext(ctx, payment)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return store.CreatePayment(payment)
+}
+
+// getPaymentContext is store.GetPayment cancelled by ctx
+func getPaymentContext(ctx context.Context, store PaymentStore, id string) (*Payment, error) {
+	if cs, ok := store.(ContextPaymentStore); ok {
+		return cs.GetPaymentContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return store.GetPayment(id)
+}
+
+// getPaymentByAddressContext is store.GetPaymentByAddress cancelled by ctx
+func getPaymentByAddressContext(ctx context.Context, store PaymentStore, address string) (*Payment, error) {
+	if cs, ok := store.(ContextPaymentStore); ok {
+		return cs.GetPaymentByAddressContext(ctx, address)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return store.GetPaymentByAddress(address)
+}
+
+// updatePaymentContext is store.UpdatePayment cancelled by ctx
+func updatePaymentContext(ctx context.Context, store PaymentStore, payment *Payment) error {
+	if cs, ok := store.(ContextPaymentStore); ok {
+		return cs.UpdatePaymentContext(ctx, payment)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return store.UpdatePayment(payment)
+}
+
+// addressBalance is client.GetAddressBalance cancelled by ctx
+func addressBalance(ctx context.Context, client CryptoClient, address string) (float64, error) {
+	if cc, ok := client.(ContextCryptoClient); ok {
+		return cc.GetAddressBalanceContext(ctx, address)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return client.GetAddressBalance(address)
+}
+
+// addressBalanceMinConf is client.GetAddressBalanceMinConf cancelled by ctx
+func addressBalanceMinConf(ctx context.Context, client MinConfBalanceClient, address string, minConf int) (float64, error) {
+	if cc, ok := client.(ContextMinConfBalanceClient); ok {
+		return cc.GetAddressBalanceMinConfContext(ctx, address, minConf)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return client.GetAddressBalanceMinConf(address, minConf)
+}
+
+// addressConfirmations is client.GetAddressConfirmations cancelled by ctx
+func addressConfirmations(ctx context.Context, client ConfirmationCountClient, address string, amount float64, maxConf int) (int, error) {
+	if cc, ok := client.(ContextConfirmationCountClient); ok {
+		return cc.GetAddressConfirmationsContext(ctx, address, amount, maxConf)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return client.GetAddressConfirmations(address, amount, maxConf)
+}
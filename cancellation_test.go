@@ -0,0 +1,107 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	_ ContextPaymentStore            = (*SQLStore)(nil)
+	_ ContextPaymentStore            = (*ReplicatedStore)(nil)
+	_ ContextCryptoClient            = (*wallet.EsploraClient)(nil)
+	_ ContextCryptoClient            = (*wallet.MoneroLWSWallet)(nil)
+	_ ContextCryptoClient            = (*wallet.ETHHDWallet)(nil)
+	_ ContextCryptoClient            = (*wallet.LightningWallet)(nil)
+	_ ContextCryptoClient            = backendClient{}
+	_ ContextMinConfBalanceClient    = (*wallet.EsploraClient)(nil)
+	_ ContextMinConfBalanceClient    = backendClient{}
+	_ ContextConfirmationCountClient = (*wallet.EsploraClient)(nil)
+	_ ContextConfirmationCountClient = backendClient{}
+)
+
+// contextCryptoClient records the context of its balance queries
+type contextCryptoClient struct {
+	mockCryptoClient
+	ctx context.Context
+}
+
+func (c *contextCryptoClient) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	c.ctx = ctx
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.GetAddressBalance(address)
+}
+
+// countingCryptoClient counts its balance queries
+type countingCryptoClient struct {
+	mockCryptoClient
+	calls int
+}
+
+func (c *countingCryptoClient) GetAddressBalance(address string) (float64, error) {
+	c.calls++
+	return c.mockCryptoClient.GetAddressBalance(address)
+}
+
+func TestBalanceConfirmation_Context(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "monitor")
+	client := &contextCryptoClient{mockCryptoClient: mockCryptoClient{balance: 0.001}}
+	result, err := BalanceConfirmation{}.Confirm(ConfirmationCheck{Currency: wallet.Bitcoin, Address: "a", Required: 0.001, Client: client, Context: ctx})
+	if err != nil || !result.Confirmed {
+		t.Fatalf("Confirm() = %+v, %v, want confirmed", result, err)
+	}
+	if client.ctx == nil || client.ctx.Value(ctxKey{}) != "monitor" {
+		t.Error("ContextCryptoClient did not get the check's context")
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	plain := &countingCryptoClient{mockCryptoClient: mockCryptoClient{balance: 0.001}}
+	if _, err := (BalanceConfirmation{}).Confirm(ConfirmationCheck{Currency: wallet.Bitcoin, Address: "a", Required: 0.001, Client: plain, Context: cancelled}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Confirm() with a done context error = %v, want context.Canceled", err)
+	}
+	if plain.calls != 0 {
+		t.Errorf("client queried %d times after the context was done", plain.calls)
+	}
+	// Without a context the check runs as before
+	if result, err := (BalanceConfirmation{}).Confirm(ConfirmationCheck{Currency: wallet.Bitcoin, Address: "a", Required: 0.001, Client: plain}); err != nil || !result.Confirmed {
+		t.Errorf("Confirm() without a context = %+v, %v", result, err)
+	}
+}
+
+func TestMonitor_StopsWhenContextDone(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{Store: store, minConfirmations: 1, logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	client := &countingCryptoClient{}
+	ctx, cancel := context.WithCancel(context.Background())
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{wallet.Bitcoin: client}, ctx: ctx}
+	for _, id := range []string{"a", "b"} {
+		store.CreatePayment(&Payment{
+			ID:        id,
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-" + id},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+			Status:    StatusPending,
+		})
+	}
+
+	if err := monitor.checkPendingPayments(); err != nil || client.calls != 2 {
+		t.Fatalf("checkPendingPayments() = %v with %d queries, want both payments checked", err, client.calls)
+	}
+	cancel()
+	client.calls = 0
+	if err := monitor.checkPendingPayments(); !errors.Is(err, context.Canceled) {
+		t.Errorf("checkPendingPayments() after cancel error = %v, want context.Canceled", err)
+	}
+	if client.calls != 0 {
+		t.Errorf("chain queried %d times after the monitor's context was done", client.calls)
+	}
+}
@@ -0,0 +1,240 @@
+package paywall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// challengeCookieName is the cookie carrying a solved challenge
+const challengeCookieName = "pw_challenge"
+
+// challengePurpose binds challenge cookie signatures to this token type
+const challengePurpose = "challenge/v1"
+
+// challengeCookieTTL is how long a solved challenge exempts a client from new challenges
+const challengeCookieTTL = 10 * time.Minute
+
+// challengeMarkerField identifies challenge form submissions
+const challengeMarkerField = "pw_challenge_submit"
+
+// ChallengeVerifier is a pluggable CAPTCHA provider (hCaptcha, Cloudflare Turnstile, ...)
+// used to gate payment creation for requests that look automated.
+//
+// Related: WithChallenge, NewHCaptchaVerifier, NewTurnstileVerifier
+type ChallengeVerifier interface {
+	// WidgetHTML returns the script and widget markup embedded in the challenge form
+	WidgetHTML() template.HTML
+	// ResponseField is the form field the widget fills with its response token
+	ResponseField() string
+	// Verify checks a response token with the provider.
+	// Returns error if the challenge was not solved.
+	Verify(ctx context.Context, response, remoteIP string) error
+}
+
+// ChallengePolicy decides whether a request must solve a challenge before a
+// payment is created for it
+type ChallengePolicy func(r *http.Request) bool
+
+// AlwaysChallenge is a ChallengePolicy requiring every new visitor to solve a challenge
+func AlwaysChallenge(r *http.Request) bool { return true }
+
+// automatedUserAgents are User-Agent fragments of common scripting clients
+var automatedUserAgents = []string{
+	"bot", "crawl", "spider", "curl", "wget", "python", "go-http-client",
+	"java/", "okhttp", "libwww", "httpclient", "headless", "scrapy",
+}
+
+// LooksAutomated is the default ChallengePolicy. It flags requests without a
+// browser-like User-Agent or Accept-Language header and known scripting clients.
+func LooksAutomated(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" || r.Header.Get("Accept-Language") == "" {
+		return true
+	}
+	for _, fragment := range automatedUserAgents {
+		if strings.Contains(ua, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// siteVerifyChallenge implements ChallengeVerifier for providers using the
+// common "siteverify" API shared by hCaptcha and Turnstile
+type siteVerifyChallenge struct {
+	siteKey     string
+	secret      string
+	verifyURL   string
+	scriptURL   string
+	widgetClass string
+	field       string
+	client      *http.Client
+}
+
+// NewHCaptchaVerifier creates a ChallengeVerifier backed by hCaptcha
+//
+// Parameters:
+//   - siteKey: Public site key rendered in the widget
+//   - secret: Secret key used for server-side verification
+func NewHCaptchaVerifier(siteKey, secret string) ChallengeVerifier {
+	return &siteVerifyChallenge{
+		siteKey:     siteKey,
+		secret:      secret,
+		verifyURL:   "https://api.hcaptcha.com/siteverify",
+		scriptURL:   "https://js.hcaptcha.com/1/api.js",
+		widgetClass: "h-captcha",
+		field:       "h-captcha-response",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewTurnstileVerifier creates a ChallengeVerifier backed by Cloudflare Turnstile
+//
+// Parameters:
+//   - siteKey: Public site key rendered in the widget
+//   - secret: Secret key used for server-side verification
+func NewTurnstileVerifier(siteKey, secret string) ChallengeVerifier {
+	return &siteVerifyChallenge{
+		siteKey:     siteKey,
+		secret:      secret,
+		verifyURL:   "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		scriptURL:   "https://challenges.cloudflare.com/turnstile/v0/api.js",
+		widgetClass: "cf-turnstile",
+		field:       "cf-turnstile-response",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *siteVerifyChallenge) WidgetHTML() template.HTML {
+	return template.HTML(fmt.Sprintf(`<script src="%s" async defer></script><div class="%s" data-sitekey="%s"></div>`,
+		template.HTMLEscapeString(c.scriptURL), c.widgetClass, template.HTMLEscapeString(c.siteKey)))
+}
+
+func (c *siteVerifyChallenge) ResponseField() string {
+	return c.field
+}
+
+func (c *siteVerifyChallenge) Verify(ctx context.Context, response, remoteIP string) error {
+	if response == "" {
+		return fmt.Errorf("empty challenge response")
+	}
+	form := url.Values{"secret": {c.secret}, "response": {response}, "sitekey": {c.siteKey}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("create verify request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("verify challenge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode verify response: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("challenge failed: %s", strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// challengeTemplate renders the challenge form shown before payment creation
+var challengeTemplate = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Verification required</title>
+</head>
+<body>
+<main style="max-width:28rem;margin:4rem auto;font-family:sans-serif;text-align:center">
+<h1>Quick check</h1>
+<p>Please confirm you are human to continue to the payment page.</p>
+{{if .Failed}}<p role="alert">Verification failed, please try again.</p>{{end}}
+<form method="POST" action="{{.Action}}">
+<input type="hidden" name="` + challengeMarkerField + `" value="1">
+{{.Widget}}
+<button type="submit">Continue</button>
+</form>
+</main>
+</body>
+</html>`))
+
+// challengeSatisfied reports whether the request may proceed to payment creation,
+// handling challenge form submissions. When it returns false a response has been written.
+func (p *Paywall) challengeSatisfied(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig) bool {
+	if cfg.challenge == nil || !cfg.challengePolicy(r) {
+		return true
+	}
+	if cookie, err := r.Cookie(challengeCookieName); err == nil && p.verifyChallengeCookie(cookie.Value, r) {
+		return true
+	}
+
+	failed := false
+	if r.Method == http.MethodPost && r.PostFormValue(challengeMarkerField) != "" {
+		response := r.PostFormValue(cfg.challenge.ResponseField())
+		err := cfg.challenge.Verify(r.Context(), response, p.clientIP(r))
+		if err == nil {
+			http.SetCookie(w, &http.Cookie{
+				Name:     challengeCookieName,
+				Value:    p.signChallengeCookie(r, time.Now().Add(challengeCookieTTL)),
+				Path:     "/",
+				Secure:   p.isSecureRequest(r),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+				MaxAge:   int(challengeCookieTTL.Seconds()),
+			})
+			// Post/Redirect/Get so the payment page is served for a plain GET
+			http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+			return false
+		}
+		failed = true
+		p.logger.log(LogEntry{
+			Level:   LogLevelInfo,
+			Event:   "challenge_failed",
+			Message: fmt.Sprintf("Challenge verification failed for %s: %v", r.URL.Path, err),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	challengeTemplate.Execute(w, struct {
+		Action string
+		Widget template.HTML
+		Failed bool
+	}{r.URL.RequestURI(), cfg.challenge.WidgetHTML(), failed})
+	return false
+}
+
+// signChallengeCookie creates a cookie value bound to the client IP and expiry
+func (p *Paywall) signChallengeCookie(r *http.Request, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + p.signer.sign(challengePurpose, exp, p.clientIP(r))
+}
+
+// verifyChallengeCookie checks the signature, expiry and client binding of a challenge cookie
+func (p *Paywall) verifyChallengeCookie(value string, r *http.Request) bool {
+	exp, sig, ok := strings.Cut(value, ".")
+	if !ok || !p.signer.verify(sig, challengePurpose, exp, p.clientIP(r)) {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	return err == nil && time.Now().Before(time.Unix(expUnix, 0))
+}
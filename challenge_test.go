@@ -0,0 +1,165 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeChallenge accepts the response "solved"
+type fakeChallenge struct {
+	calls int
+}
+
+func (f *fakeChallenge) WidgetHTML() template.HTML { return `<div id="fake-widget"></div>` }
+func (f *fakeChallenge) ResponseField() string     { return "fake-response" }
+func (f *fakeChallenge) Verify(ctx context.Context, response, remoteIP string) error {
+	f.calls++
+	if response != "solved" {
+		return errors.New("wrong answer")
+	}
+	return nil
+}
+
+func TestLooksAutomated(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		language  string
+		want      bool
+	}{
+		{"browser", "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0", "en-US", false},
+		{"no user agent", "", "en-US", true},
+		{"no accept-language", "Mozilla/5.0 Firefox/120.0", "", true},
+		{"curl", "curl/8.4.0", "en", true},
+		{"python", "python-requests/2.31", "en", true},
+		{"crawler", "Mozilla/5.0 (compatible; Googlebot/2.1)", "en", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			if tt.language != "" {
+				r.Header.Set("Accept-Language", tt.language)
+			}
+			if got := LooksAutomated(r); got != tt.want {
+				t.Errorf("LooksAutomated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareWithOptions_Challenge(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	verifier := &fakeChallenge{}
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("protected"))
+	}), WithChallenge(verifier, nil))
+
+	// Scripted client gets the challenge instead of a payment
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/article", nil))
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "fake-widget") {
+		t.Fatalf("expected challenge page, got %d", rec.Code)
+	}
+	if payments, _ := pw.Store.(*MemoryStore).ListPayments(); len(payments) != 0 {
+		t.Fatalf("payment created before challenge was solved")
+	}
+
+	// Wrong answer re-renders the challenge
+	post := func(answer string) *httptest.ResponseRecorder {
+		form := url.Values{challengeMarkerField: {"1"}, "fake-response": {answer}}
+		r := httptest.NewRequest(http.MethodPost, "/article", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+	if rec := post("guess"); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "Verification failed") {
+		t.Fatalf("wrong answer: status %d", rec.Code)
+	}
+
+	// Correct answer sets the signed cookie and redirects back
+	rec = post("solved")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("correct answer: status %d, want 303", rec.Code)
+	}
+	var challengeCookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == challengeCookieName {
+			challengeCookie = c
+		}
+	}
+	if challengeCookie == nil {
+		t.Fatal("challenge cookie not set")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/article", nil)
+	r.AddCookie(challengeCookie)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after challenge: status %d, want payment page", rec.Code)
+	}
+	if payments, _ := pw.Store.(*MemoryStore).ListPayments(); len(payments) != 1 {
+		t.Errorf("payments after challenge = %d, want 1", len(payments))
+	}
+
+	// Tampered cookies are rejected
+	r = httptest.NewRequest(http.MethodGet, "/article", nil)
+	r.AddCookie(&http.Cookie{Name: challengeCookieName, Value: "9999999999.forged"})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("forged cookie: status %d, want 403", rec.Code)
+	}
+}
+
+func TestMiddlewareWithOptions_ChallengeSkippedForBrowsers(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	handler := pw.MiddlewareWithOptions(http.NotFoundHandler(), WithChallenge(&fakeChallenge{}, nil))
+
+	r := httptest.NewRequest(http.MethodGet, "/article", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 Firefox/120.0")
+	r.Header.Set("Accept-Language", "en-US")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "fake-widget") {
+		t.Errorf("browser request should go straight to the payment page, got %d", rec.Code)
+	}
+}
+
+func TestSiteVerifyChallenge_Verify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("secret") != "secret" {
+			t.Errorf("secret not sent")
+		}
+		if r.PostForm.Get("response") == "good" {
+			w.Write([]byte(`{"success":true}`))
+			return
+		}
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	verifier := NewTurnstileVerifier("site", "secret").(*siteVerifyChallenge)
+	verifier.verifyURL = server.URL
+	if err := verifier.Verify(context.Background(), "good", "203.0.113.1"); err != nil {
+		t.Errorf("Verify(good) error = %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "bad", ""); err == nil || !strings.Contains(err.Error(), "invalid-input-response") {
+		t.Errorf("Verify(bad) error = %v", err)
+	}
+	if err := verifier.Verify(context.Background(), "", ""); err == nil {
+		t.Error("Verify(empty) should fail")
+	}
+	if !strings.Contains(string(verifier.WidgetHTML()), `data-sitekey="site"`) {
+		t.Errorf("WidgetHTML() = %s", verifier.WidgetHTML())
+	}
+}
@@ -292,7 +292,7 @@ func TestChaosStoreOperations(t *testing.T) {
 	numPayments := 20
 	paymentIDs := make([]string, numPayments)
 	for i := 0; i < numPayments; i++ {
-		paymentID, _ := generatePaymentID()
+		paymentID, _ := generatePaymentID(nil)
 		payment := &Payment{
 			ID: paymentID,
 			Addresses: map[wallet.WalletType]string{
@@ -454,7 +454,7 @@ func TestChaosPaymentLifecycle(t *testing.T) {
 				defer wg.Done()
 
 				// Create payment
-				paymentID, _ := generatePaymentID()
+				paymentID, _ := generatePaymentID(nil)
 				payment := &Payment{
 					ID: paymentID,
 					Addresses: map[wallet.WalletType]string{
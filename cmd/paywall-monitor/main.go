@@ -0,0 +1,117 @@
+// Command paywall-monitor confirms payments for paywall instances running with
+// Config.ExternalMonitor, such as a FaaS web tier whose instances are frozen
+// between requests. It shares nothing with the web tier but the payment store:
+// it polls the store for pending payments, checks their addresses on the
+// blockchain and marks them confirmed or expired, which the web tier picks up on
+// the visitor's next request.
+//
+// Usage:
+//
+//	paywall-monitor -store ./payments -testnet
+//	paywall-monitor -s3-endpoint https://s3.eu-west-1.amazonaws.com -s3-bucket my-paywall -s3-region eu-west-1
+//	paywall-monitor -store ./payments -shadow -log-level DEBUG
+//	paywall-monitor -s3-bucket my-paywall -s3-region eu-west-1 -wal /var/lib/paywall-monitor/wal.jsonl
+//
+// With -shadow the monitor writes nothing: it logs the confirmations and
+// expiries it would make, so a new backend or configuration can be validated
+// next to the production monitor. With -wal it logs each confirmation to a
+// local file before storing it and replays those the store did not take, after
+// a crash or while the store is unavailable.
+//
+// S3 credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, a FileStore encryption key (hex) from PAYWALL_STORE_KEY
+// and the monero-wallet-rpc password from XMR_WALLET_PASS. Run exactly one
+// monitor per store unless the store supports locking (Config.PaymentLocker).
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opd-ai/paywall"
+)
+
+var (
+	storeDir         = flag.String("store", "./payments", "payment store directory shared with the web tier (ignored with -s3-bucket)")
+	s3Endpoint       = flag.String("s3-endpoint", "", "S3-compatible endpoint URL of the payment store")
+	s3Bucket         = flag.String("s3-bucket", "", "S3 bucket of the payment store")
+	s3Region         = flag.String("s3-region", "", "S3 signing region (defaults to AWS_REGION, then us-east-1)")
+	s3Prefix         = flag.String("s3-prefix", "", "S3 key prefix of the payment store")
+	testnet          = flag.Bool("testnet", false, "use Bitcoin testnet")
+	minConfirmations = flag.Int("min-confirmations", 1, "minimum blockchain confirmations required")
+	paymentTimeout   = flag.Duration("payment-timeout", 24*time.Hour, "payment timeout duration, as configured on the web tier")
+	xmrRPC           = flag.String("xmr-rpc", "", "monero-wallet-rpc URL, to confirm Monero payments")
+	xmrUser          = flag.String("xmr-user", "", "monero-wallet-rpc username")
+	logLevel         = flag.String("log-level", "INFO", "minimum log level: DEBUG, INFO, WARN or ERROR")
+	shadow           = flag.Bool("shadow", false, "dry run: log what would be confirmed or expired without writing to the store")
+	walPath          = flag.String("wal", "", "local file logging confirmations until they are stored, replayed after a crash or store outage")
+)
+
+func main() {
+	flag.Parse()
+
+	store, err := openStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config := paywall.Config{
+		// Prices only apply to new payments, which the monitor never creates;
+		// payments are checked against the amounts stored with them
+		PriceInBTC:       0.0001,
+		PaymentTimeout:   *paymentTimeout,
+		MinConfirmations: *minConfirmations,
+		TestNet:          *testnet,
+		Store:            store,
+		Logger:           paywall.NewStructuredLogger(os.Stdout, paywall.LogLevel(*logLevel), true),
+		MonitorShadow:    *shadow,
+		MonitorWALPath:   *walPath,
+	}
+	if *xmrRPC != "" {
+		config.PriceInXMR = 0.01
+		config.XMRRPC = *xmrRPC
+		config.XMRUser = *xmrUser
+		config.XMRPassword = os.Getenv("XMR_WALLET_PASS")
+	}
+
+	// A paywall without ExternalMonitor runs the monitor in the background
+	pw, err := paywall.NewPaywall(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *shadow {
+		log.Printf("paywall-monitor running in shadow mode, the store is not modified")
+	}
+	log.Printf("paywall-monitor running, stop with Ctrl-C or SIGTERM")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	pw.Close()
+}
+
+// openStore opens the payment store selected by the flags
+func openStore() (paywall.PaymentStore, error) {
+	if *s3Bucket != "" {
+		return paywall.NewS3Store(paywall.S3StoreConfig{
+			Endpoint: *s3Endpoint,
+			Bucket:   *s3Bucket,
+			Region:   *s3Region,
+			Prefix:   *s3Prefix,
+		})
+	}
+	var key []byte
+	if encoded := os.Getenv("PAYWALL_STORE_KEY"); encoded != "" {
+		var err error
+		if key, err = hex.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("PAYWALL_STORE_KEY must be hex encoded: %w", err)
+		}
+	}
+	return paywall.NewFileStoreWithConfig(paywall.FileStoreConfig{DataDir: *storeDir, EncryptionKey: key})
+}
@@ -0,0 +1,302 @@
+// Command paywall-preview serves the payment page template against fake payments
+// so designers can iterate on the checkout experience without running wallets,
+// blockchain nodes or a payment store.
+//
+// Usage:
+//
+//	paywall-preview -template ./mytheme/payment.html -addr localhost:8089
+//
+// Every scenario listed on the index page renders the template with a synthetic
+// payment (single and dual currency, multisig, near expiry, expired, detected, no QR script). When a
+// custom template file is given it is re-parsed whenever it changes on disk and
+// open preview tabs reload automatically.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	addr         = flag.String("addr", "localhost:8089", "address to serve the preview on")
+	templatePath = flag.String("template", "", "path to a custom payment template (defaults to the embedded templates/payment.html)")
+)
+
+// reloadScript is injected before </body> of every preview page. It polls the
+// version endpoint and reloads the page when the template fingerprint changes.
+const reloadScript = `<script id="paywall-preview-reload">
+(function () {
+    var current = %q;
+    setInterval(function () {
+        fetch('/__preview/version', {cache: 'no-store'})
+            .then(function (r) { return r.text(); })
+            .then(function (v) { if (v !== current) { location.reload(); } })
+            .catch(function () {});
+    }, 1000);
+})();
+</script>`
+
+// scenario describes a fake payment rendered by the preview server
+type scenario struct {
+	Name        string
+	Description string
+	build       func(now time.Time) *paywall.Payment
+	// noQRScript renders the page as if the QR script were blocked or unavailable
+	noQRScript bool
+}
+
+// scenarios lists all fake payments available in the preview
+var scenarios = map[string]scenario{
+	"btc": {
+		Name:        "btc",
+		Description: "Bitcoin only, pending, two hours left",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001})
+		},
+	},
+	"xmr": {
+		Name:        "xmr",
+		Description: "Monero only, pending, two hours left",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Monero: 0.01})
+		},
+	},
+	"btc-xmr": {
+		Name:        "btc-xmr",
+		Description: "Bitcoin and Monero, pending, two hours left",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001, wallet.Monero: 0.01})
+		},
+	},
+	"near-expiry": {
+		Name:        "near-expiry",
+		Description: "Bitcoin and Monero, 45 seconds until expiry",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 45*time.Second, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001, wallet.Monero: 0.01})
+		},
+	},
+	"expired": {
+		Name:        "expired",
+		Description: "Bitcoin and Monero, expired one minute ago",
+		build: func(now time.Time) *paywall.Payment {
+			p := fakePayment(now, -time.Minute, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001, wallet.Monero: 0.01})
+			p.Status = paywall.StatusExpired
+			return p
+		},
+	},
+	"detected": {
+		Name:        "detected",
+		Description: "Bitcoin transaction submitted by the payer, awaiting confirmations",
+		build: func(now time.Time) *paywall.Payment {
+			p := fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001})
+			p.Status = paywall.StatusDetected
+			p.DetectedTxID = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+			return p
+		},
+	},
+	"large-amounts": {
+		Name:        "large-amounts",
+		Description: "Long amounts to check wrapping and layout",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 12.34567891, wallet.Monero: 1234.567891234})
+		},
+	},
+	"no-qr-script": {
+		Name:        "no-qr-script",
+		Description: "Bitcoin and Monero with the QR script unavailable (server-rendered fallback)",
+		build: func(now time.Time) *paywall.Payment {
+			return fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0001, wallet.Monero: 0.01})
+		},
+		noQRScript: true,
+	},
+	"multisig": {
+		Name:        "multisig",
+		Description: "2-of-3 multisig Bitcoin escrow",
+		build: func(now time.Time) *paywall.Payment {
+			p := fakePayment(now, 2*time.Hour, map[wallet.WalletType]float64{wallet.Bitcoin: 0.0005})
+			p.MultisigEnabled = true
+			p.RequiredSignatures = map[wallet.WalletType]int{wallet.Bitcoin: 2}
+			p.MultisigMetadata = map[wallet.WalletType]*wallet.MultisigMetadata{
+				wallet.Bitcoin: {
+					Address:      p.Addresses[wallet.Bitcoin],
+					PublicKeys:   [][]byte{{0x02}, {0x03}, {0x02}},
+					RequiredSigs: 2,
+				},
+			}
+			return p
+		},
+	},
+}
+
+// fakeAddresses are well-formed but unfunded addresses used for previews
+var fakeAddresses = map[wallet.WalletType]string{
+	wallet.Bitcoin: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq",
+	wallet.Monero:  "888tNkZrPN6JsEgekjMnABU4TBzc2Dt29EPAvkRxbANsAnjyPbb3iQ1YBRk1UXcdRsiKc9dhwMVgN5S9cQUiyoogDavup3H",
+}
+
+func fakePayment(now time.Time, remaining time.Duration, amounts map[wallet.WalletType]float64) *paywall.Payment {
+	p := &paywall.Payment{
+		ID:        "preview-" + strings.Repeat("0", 24),
+		Addresses: make(map[wallet.WalletType]string),
+		Amounts:   make(map[wallet.WalletType]float64),
+		CreatedAt: now.Add(-time.Minute),
+		ExpiresAt: now.Add(remaining),
+		Status:    paywall.StatusPending,
+	}
+	for walletType, amount := range amounts {
+		p.Addresses[walletType] = fakeAddresses[walletType]
+		p.Amounts[walletType] = amount
+	}
+	return p
+}
+
+// previewServer renders the configured template and tracks its version for live reload
+type previewServer struct {
+	path string
+
+	mu       sync.Mutex
+	tmpl     *template.Template
+	version  string
+	modTime  time.Time
+	parseErr error
+}
+
+// load (re)parses the template if it changed on disk since the last call
+func (s *previewServer) load() (*template.Template, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		if s.tmpl == nil {
+			s.tmpl, s.parseErr = template.ParseFS(paywall.TemplateFS, "templates/payment.html")
+			s.version = "embedded"
+		}
+		return s.tmpl, s.version, s.parseErr
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("stat template: %w", err)
+	}
+	if s.tmpl != nil && info.ModTime().Equal(s.modTime) {
+		return s.tmpl, s.version, s.parseErr
+	}
+
+	src, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("read template: %w", err)
+	}
+	sum := sha256.Sum256(src)
+	s.version = hex.EncodeToString(sum[:8])
+	s.modTime = info.ModTime()
+	s.tmpl, s.parseErr = template.New("payment").Parse(string(src))
+	if s.parseErr != nil {
+		log.Printf("template parse error: %v", s.parseErr)
+	} else {
+		log.Printf("loaded template %s (version %s)", s.path, s.version)
+	}
+	return s.tmpl, s.version, s.parseErr
+}
+
+func (s *previewServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html><html><head><title>Paywall template preview</title></head><body>")
+	b.WriteString("<h1>Paywall template preview</h1><ul>")
+	for _, name := range names {
+		sc := scenarios[name]
+		fmt.Fprintf(&b, `<li><a href="/preview/%s">%s</a> &mdash; %s</li>`,
+			template.HTMLEscapeString(sc.Name), template.HTMLEscapeString(sc.Name), template.HTMLEscapeString(sc.Description))
+	}
+	b.WriteString("</ul></body></html>")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func (s *previewServer) handlePreview(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/preview/")
+	sc, ok := scenarios[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown scenario: %s", name), http.StatusNotFound)
+		return
+	}
+
+	tmpl, version, err := s.load()
+	var buf bytes.Buffer
+	if err == nil {
+		data, dataErr := paywall.NewPaymentPageData(sc.build(time.Now()))
+		if dataErr != nil {
+			log.Printf("page data: %v", dataErr)
+		}
+		if sc.noQRScript {
+			data.QrcodeJs = ""
+			data.QRScriptUnavailable = true
+		}
+		err = tmpl.Execute(&buf, data)
+	}
+	if err != nil {
+		// Render the error with the reload script so fixing the template recovers the tab
+		buf.Reset()
+		fmt.Fprintf(&buf, "<!DOCTYPE html><html><body><h1>Template error</h1><pre>%s</pre></body></html>",
+			template.HTMLEscapeString(err.Error()))
+	}
+
+	page := buf.String()
+	script := fmt.Sprintf(reloadScript, version)
+	if i := strings.LastIndex(page, "</body>"); i >= 0 {
+		page = page[:i] + script + page[i:]
+	} else {
+		page += script
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(page))
+}
+
+func (s *previewServer) handleVersion(w http.ResponseWriter, r *http.Request) {
+	_, version, _ := s.load()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(version))
+}
+
+func main() {
+	flag.Parse()
+
+	s := &previewServer{path: *templatePath}
+	if _, _, err := s.load(); err != nil {
+		log.Printf("warning: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/preview/", s.handlePreview)
+	mux.HandleFunc("/__preview/version", s.handleVersion)
+
+	log.Printf("Template preview available at http://%s/", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
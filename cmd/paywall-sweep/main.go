@@ -0,0 +1,141 @@
+// Command paywall-sweep moves the funds received by a paywall's Bitcoin wallet
+// to another address, e.g. of a hardware wallet, in one transaction. It derives
+// the wallet's receiving addresses up to the saved index plus a gap, looks up
+// their unspent outputs with an Esplora block explorer, and spends them all.
+//
+// Usage:
+//
+//	paywall-sweep -wallet-dir /var/lib/paywall -testnet -to tb1q...
+//	paywall-sweep -wallet-dir /var/lib/paywall -address-type p2wpkh -to bc1q... -fee-rate 4 -broadcast
+//
+// Without -broadcast it only prints the signed transaction, so it can be
+// checked or broadcast elsewhere. The wallet is read from -wallet-dir with the
+// hex encryption key in PAYWALL_WALLET_KEY or the raw key in -key-file, such as
+// the wallet.key ConstructPaywall writes (Config.BTCWalletStorage), or
+// restored from PAYWALL_MNEMONIC and PAYWALL_PASSPHRASE (Config.BTCMnemonic,
+// with -legacy-seed for Config.BTCMnemonicLegacySeed).
+// -address-type must match Config.AddressType, which is not stored with the
+// wallet.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	walletDir   = flag.String("wallet-dir", "", "BTCWalletStorage directory holding wallet.dat (key in PAYWALL_WALLET_KEY)")
+	keyFile     = flag.String("key-file", "", "file holding the raw 32-byte wallet key, instead of PAYWALL_WALLET_KEY")
+	testnet     = flag.Bool("testnet", false, "use Bitcoin testnet")
+	legacySeed  = flag.Bool("legacy-seed", false, "derive PAYWALL_MNEMONIC from the first 32 bytes of its seed, as wallets created by earlier versions")
+	addressType = flag.String("address-type", string(wallet.AddressP2PKH), "address type of the paywall: p2pkh, p2sh-p2wpkh, p2wpkh or p2tr")
+	destination = flag.String("to", "", "address receiving the funds (required)")
+	feeRate     = flag.Int64("fee-rate", 2, "fee rate in sat/vB")
+	gap         = flag.Int("gap", wallet.DefaultGapLimit, "addresses checked past the wallet's saved index")
+	explorer    = flag.String("esplora", "", "Esplora API URL (defaults to mempool.space and blockstream.info)")
+	broadcast   = flag.Bool("broadcast", false, "broadcast the transaction instead of printing it only")
+)
+
+func main() {
+	flag.Parse()
+	if *destination == "" {
+		log.Fatal("-to is required")
+	}
+
+	btcWallet, err := openWallet()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := btcWallet.SetAddressType(wallet.BTCAddressType(*addressType)); err != nil {
+		log.Fatal(err)
+	}
+	var config wallet.EsploraConfig
+	if *explorer != "" {
+		config.URLs = []string{*explorer}
+	}
+	client, err := wallet.NewEsploraClient(config, *testnet, 1)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	inputs, err := findInputs(btcWallet, client, btcWallet.GetNextIndex()+uint32(*gap))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		log.Printf("Nothing to sweep in the first %d addresses", btcWallet.GetNextIndex()+uint32(*gap))
+		return
+	}
+	tx, fee, err := btcWallet.SweepTransaction(inputs, *destination, *feeRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if err := tx.Serialize(&raw); err != nil {
+		log.Fatal(err)
+	}
+	txHex := hex.EncodeToString(raw.Bytes())
+	log.Printf("Sweeping %d outputs to %s: %d sat after a fee of %d sat", len(inputs), *destination, tx.TxOut[0].Value, fee)
+	if !*broadcast {
+		fmt.Println(txHex)
+		return
+	}
+	txID, err := client.BroadcastTransaction(txHex)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Broadcast %s", txID)
+}
+
+// openWallet loads the wallet from -wallet-dir, or restores it from the
+// mnemonic in the environment
+func openWallet() (*wallet.BTCHDWallet, error) {
+	if mnemonic := os.Getenv("PAYWALL_MNEMONIC"); mnemonic != "" {
+		if *legacySeed {
+			return wallet.NewLegacyBTCHDWalletFromMnemonic(mnemonic, os.Getenv("PAYWALL_PASSPHRASE"), *testnet, 1)
+		}
+		return wallet.NewBTCHDWalletFromMnemonic(mnemonic, os.Getenv("PAYWALL_PASSPHRASE"), *testnet, 1)
+	}
+	if *walletDir == "" {
+		return nil, fmt.Errorf("set -wallet-dir or PAYWALL_MNEMONIC")
+	}
+	var key []byte
+	var err error
+	if *keyFile != "" {
+		if key, err = os.ReadFile(*keyFile); err != nil {
+			return nil, fmt.Errorf("read wallet key: %w", err)
+		}
+	} else if key, err = hex.DecodeString(os.Getenv("PAYWALL_WALLET_KEY")); err != nil {
+		return nil, fmt.Errorf("PAYWALL_WALLET_KEY must be hex encoded: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("wallet key must be the 32-byte BTCWalletStorage key, got %d bytes", len(key))
+	}
+	return wallet.LoadBTCHDWallet(wallet.StorageConfig{DataDir: *walletDir, EncryptionKey: key}, *testnet, 1)
+}
+
+// findInputs returns the unspent outputs paid to the wallet's first count
+// receiving addresses
+func findInputs(btcWallet *wallet.BTCHDWallet, client *wallet.EsploraClient, count uint32) ([]wallet.SweepInput, error) {
+	var inputs []wallet.SweepInput
+	for index := uint32(0); index < count; index++ {
+		address, err := btcWallet.AddressAt(index)
+		if err != nil {
+			return nil, err
+		}
+		utxos, err := client.ListAddressUTXOs(address)
+		if err != nil {
+			return nil, fmt.Errorf("list outputs of %s: %w", address, err)
+		}
+		for _, utxo := range utxos {
+			inputs = append(inputs, wallet.SweepInput{TxID: utxo.TxID, Vout: utxo.Vout, Value: int64(utxo.Value), Index: index})
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,249 @@
+package paywall
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// ConfirmationStrategy decides whether a payment has been paid in one
+// currency. The monitor asks it on every check of an unconfirmed payment and
+// confirms the payment once it reports Confirmed; payment hooks, events and
+// shadow mode work the same for every strategy.
+//
+// Select one per currency with Config.ConfirmationStrategies. Currencies
+// without one use BalanceConfirmation, the default behavior.
+//
+// Related: BalanceConfirmation, BitcoinTxConfirmation, AttestationConfirmation, ConfirmationFunc
+type ConfirmationStrategy interface {
+	// Confirm checks a payment address. Errors are logged and the payment is
+	// checked again on the next cycle.
+	Confirm(check ConfirmationCheck) (ConfirmationResult, error)
+}
+
+// ConfirmationCheck is what a ConfirmationStrategy decides on
+type ConfirmationCheck struct {
+	// Payment is the payment being checked; strategies must not modify it
+	Payment *Payment
+	// Currency and Address select the payment address being checked
+	Currency wallet.WalletType
+	Address  string
+	// Required is the amount the payment asks for in Currency
+	Required float64
+	// MinConfirmations is the payment's RouteConfig.MinConfirmations when
+	// set, Config.MinConfirmations otherwise
+	MinConfirmations int
+	// Client is the monitor's chain client for Currency, nil if there is none
+	Client CryptoClient
+	// Context cancels the check's chain queries; the monitor's context ends
+	// with Paywall.Close. Nil means context.Background().
+	Context context.Context
+}
+
+// ConfirmationResult is a ConfirmationStrategy's decision
+type ConfirmationResult struct {
+	// Confirmed reports that the payment is paid
+	Confirmed bool
+	// Received is the amount seen for the address; a change marks the payment
+	// active for Config.WatchDecayAfter
+	Received float64
+	// Confirmations of the paying funds. While the payment is unconfirmed a
+	// count above 0 is stored on it as progress (Payment.Confirmations); once
+	// confirmed it is stored as is.
+	// Optional: 0 stores Config.MinConfirmations on confirmation.
+	Confirmations int
+	// TxID is the paying transaction, if the strategy knows it
+	TxID string
+}
+
+// ConfirmationFunc adapts a function to a ConfirmationStrategy, for example
+// to accept unconfirmed payments of trusted amounts
+type ConfirmationFunc func(check ConfirmationCheck) (ConfirmationResult, error)
+
+// Confirm calls f(check)
+func (f ConfirmationFunc) Confirm(check ConfirmationCheck) (ConfirmationResult, error) {
+	return f(check)
+}
+
+// MinConfBalanceClient is a CryptoClient that can count funds with a given
+// number of confirmations, as *wallet.BTCHDWallet, *wallet.EsploraClient,
+// *wallet.UTXOHDWallet, *wallet.MoneroHDWallet and *wallet.MoneroLWSWallet do
+type MinConfBalanceClient interface {
+	CryptoClient
+	// GetAddressBalanceMinConf returns the balance of address counting only
+	// funds with at least minConf confirmations
+	GetAddressBalanceMinConf(address string, minConf int) (float64, error)
+}
+
+// ConfirmationCountClient is a CryptoClient that can count the confirmations
+// of the funds paid to an address, as *wallet.BTCHDWallet,
+// *wallet.EsploraClient and *wallet.MoneroHDWallet do
+type ConfirmationCountClient interface {
+	CryptoClient
+	// GetAddressConfirmations returns how many confirmations the funds paying
+	// amount to address have, counting up to maxConf
+	GetAddressConfirmations(address string, amount float64, maxConf int) (int, error)
+}
+
+// ContextMinConfBalanceClient is a MinConfBalanceClient whose queries a
+// context cancels, as *wallet.EsploraClient, *wallet.MoneroLWSWallet and
+// *wallet.ETHHDWallet are
+type ContextMinConfBalanceClient interface {
+	MinConfBalanceClient
+	GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error)
+}
+
+// ContextConfirmationCountClient is a ConfirmationCountClient whose queries a
+// context cancels, as *wallet.EsploraClient is
+type ContextConfirmationCountClient interface {
+	ConfirmationCountClient
+	GetAddressConfirmationsContext(ctx context.Context, address string, amount float64, maxConf int) (int, error)
+}
+
+// BalanceConfirmation confirms a payment once the balance of its address,
+// as reported by the chain client, covers the required amount. The clients
+// only count funds with Config.MinConfirmations confirmations; for payments of
+// a route with its own RouteConfig.MinConfirmations, clients implementing
+// MinConfBalanceClient count those instead. Until then, clients implementing
+// ConfirmationCountClient report the confirmations the payment has so far.
+// The queries are cancelled with check.Context for clients with ...Context
+// methods (ContextCryptoClient and its siblings). This is the default strategy.
+type BalanceConfirmation struct{}
+
+// Confirm implements ConfirmationStrategy
+func (BalanceConfirmation) Confirm(check ConfirmationCheck) (ConfirmationResult, error) {
+	if check.Client == nil {
+		return ConfirmationResult{}, fmt.Errorf("%s client not found", check.Currency)
+	}
+	ctx := check.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var balance float64
+	var err error
+	if client, ok := check.Client.(MinConfBalanceClient); ok && check.Payment != nil && check.Payment.MinConfirmations > 0 {
+		balance, err = addressBalanceMinConf(ctx, client, check.Address, check.MinConfirmations)
+	} else {
+		balance, err = addressBalance(ctx, check.Client, check.Address)
+	}
+	if err != nil {
+		return ConfirmationResult{}, err
+	}
+	result := ConfirmationResult{Confirmed: balance >= check.Required, Received: balance}
+	if counter, ok := check.Client.(ConfirmationCountClient); ok && !result.Confirmed {
+		// The funds may be on their way: count the confirmations they have
+		result.Confirmations, err = addressConfirmations(ctx, counter, check.Address, check.Required, check.MinConfirmations)
+		if err != nil {
+			return ConfirmationResult{}, fmt.Errorf("count confirmations: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// BitcoinTransactionLookup looks up Bitcoin transactions.
+// *BTCBroadcaster implements it when Config.BTCRPCHost is set.
+type BitcoinTransactionLookup interface {
+	// GetRawTransaction returns the serialized transaction
+	GetRawTransaction(txID string) ([]byte, error)
+	// GetTransactionConfirmations returns the transaction's confirmations,
+	// 0 while it is unconfirmed
+	GetTransactionConfirmations(txID string) (int, error)
+}
+
+// BitcoinTxConfirmation confirms Bitcoin payments only through the transaction
+// the payer submitted (Payment.DetectedTxID, see SubmitBitcoinTransaction):
+// the transaction must pay the required amount to the payment address and
+// have Config.MinConfirmations confirmations. Funds sent to the address by
+// other transactions are ignored, so payments without a submitted
+// transaction are never confirmed.
+type BitcoinTxConfirmation struct {
+	// Lookup queries the transactions, usually a *BTCBroadcaster
+	Lookup BitcoinTransactionLookup
+}
+
+// Confirm implements ConfirmationStrategy
+func (s BitcoinTxConfirmation) Confirm(check ConfirmationCheck) (ConfirmationResult, error) {
+	if check.Currency != wallet.Bitcoin {
+		return ConfirmationResult{}, fmt.Errorf("BitcoinTxConfirmation cannot confirm %s payments", check.Currency)
+	}
+	txID := check.Payment.DetectedTxID
+	if txID == "" {
+		return ConfirmationResult{}, nil
+	}
+
+	raw, err := s.Lookup.GetRawTransaction(txID)
+	if err != nil {
+		return ConfirmationResult{}, fmt.Errorf("look up transaction %s: %w", txID, err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return ConfirmationResult{}, fmt.Errorf("decode transaction %s: %w", txID, err)
+	}
+	received, err := btcAmountPaidTo(&tx, check.Address)
+	if err != nil {
+		return ConfirmationResult{}, err
+	}
+	confirmations, err := s.Lookup.GetTransactionConfirmations(txID)
+	if err != nil {
+		return ConfirmationResult{}, fmt.Errorf("get confirmations of %s: %w", txID, err)
+	}
+
+	return ConfirmationResult{
+		Confirmed:     received >= check.Required && confirmations >= check.MinConfirmations,
+		Received:      received,
+		Confirmations: confirmations,
+		TxID:          txID,
+	}, nil
+}
+
+// Attestation is an external service's statement about a payment address
+type Attestation struct {
+	// Received is the amount the service saw arrive at the address
+	Received float64
+	// Confirmations of the paying transaction
+	Confirmations int
+	// TxID is the paying transaction, if known
+	TxID string
+}
+
+// PaymentAttestor asks an external service, such as a payment processor or
+// a company's own node, what a payment address received
+type PaymentAttestor interface {
+	// Attest reports what address received for the payment. Return a zero
+	// Attestation while nothing has arrived.
+	Attest(payment *Payment, currency wallet.WalletType, address string) (Attestation, error)
+}
+
+// AttestationConfirmation confirms payments on an external service's word:
+// once the attested amount covers the payment with Config.MinConfirmations
+// confirmations
+type AttestationConfirmation struct {
+	// Attestor is the external service
+	Attestor PaymentAttestor
+}
+
+// Confirm implements ConfirmationStrategy
+func (s AttestationConfirmation) Confirm(check ConfirmationCheck) (ConfirmationResult, error) {
+	attestation, err := s.Attestor.Attest(check.Payment, check.Currency, check.Address)
+	if err != nil {
+		return ConfirmationResult{}, fmt.Errorf("attest %s payment: %w", check.Currency, err)
+	}
+	return ConfirmationResult{
+		Confirmed:     attestation.Received >= check.Required && attestation.Confirmations >= check.MinConfirmations,
+		Received:      attestation.Received,
+		Confirmations: attestation.Confirmations,
+		TxID:          attestation.TxID,
+	}, nil
+}
+
+// confirmationStrategy returns the strategy configured for walletType,
+// BalanceConfirmation if there is none
+func (p *Paywall) confirmationStrategy(walletType wallet.WalletType) ConfirmationStrategy {
+	if strategy, ok := p.confirmationStrategies[walletType]; ok {
+		return strategy
+	}
+	return BalanceConfirmation{}
+}
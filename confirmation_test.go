@@ -0,0 +1,279 @@
+package paywall
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeTxLookup serves one transaction with a fixed number of confirmations
+type fakeTxLookup struct {
+	raw           []byte
+	confirmations int
+}
+
+func (f *fakeTxLookup) GetRawTransaction(txID string) ([]byte, error) {
+	if f.raw == nil {
+		return nil, errors.New("unknown transaction")
+	}
+	return f.raw, nil
+}
+
+func (f *fakeTxLookup) GetTransactionConfirmations(txID string) (int, error) {
+	return f.confirmations, nil
+}
+
+// testTxPaying serializes a transaction paying sats to address
+func testTxPaying(t *testing.T, address string, sats int64) []byte {
+	t.Helper()
+	addr, err := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("DecodeAddress() error = %v", err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript() error = %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(sats, script))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBitcoinTxConfirmation(t *testing.T) {
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash() error = %v", err)
+	}
+	address := addr.EncodeAddress()
+
+	tests := []struct {
+		name          string
+		txID          string
+		sats          int64
+		confirmations int
+		wantConfirmed bool
+		wantReceived  float64
+	}{
+		{name: "no submitted transaction", sats: 100000, confirmations: 6},
+		{name: "paid and confirmed", txID: "tx1", sats: 100000, confirmations: 1, wantConfirmed: true, wantReceived: 0.001},
+		{name: "unconfirmed", txID: "tx1", sats: 100000, confirmations: 0, wantReceived: 0.001},
+		{name: "underpaid", txID: "tx1", sats: 50000, confirmations: 6, wantReceived: 0.0005},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := BitcoinTxConfirmation{Lookup: &fakeTxLookup{raw: testTxPaying(t, address, tt.sats), confirmations: tt.confirmations}}
+			result, err := strategy.Confirm(ConfirmationCheck{
+				Payment:          &Payment{ID: "p", DetectedTxID: tt.txID},
+				Currency:         wallet.Bitcoin,
+				Address:          address,
+				Required:         0.001,
+				MinConfirmations: 1,
+			})
+			if err != nil {
+				t.Fatalf("Confirm() error = %v", err)
+			}
+			if result.Confirmed != tt.wantConfirmed || result.Received != tt.wantReceived {
+				t.Errorf("Confirm() = %+v, want confirmed %v, received %v", result, tt.wantConfirmed, tt.wantReceived)
+			}
+		})
+	}
+
+	if _, err := (BitcoinTxConfirmation{Lookup: &fakeTxLookup{}}).Confirm(ConfirmationCheck{Payment: &Payment{}, Currency: wallet.Monero}); err == nil {
+		t.Error("Confirm() of a Monero payment succeeded")
+	}
+}
+
+func TestConfirmationStrategies_Monitor(t *testing.T) {
+	store := NewMemoryStore()
+	// Accept unconfirmed payments (0-conf) and report the attested transaction
+	var checked []ConfirmationCheck
+	zeroConf := ConfirmationFunc(func(check ConfirmationCheck) (ConfirmationResult, error) {
+		checked = append(checked, check)
+		return ConfirmationResult{Confirmed: true, Received: check.Required, Confirmations: 0, TxID: "mempool-tx"}, nil
+	})
+	pw := &Paywall{
+		Store:                  store,
+		minConfirmations:       2,
+		logger:                 NewStructuredLogger(io.Discard, LogLevelError, true),
+		confirmationStrategies: map[wallet.WalletType]ConfirmationStrategy{wallet.Bitcoin: zeroConf},
+	}
+	var transitions []*PaymentTransition
+	pw.Use(func(next TransitionFunc) TransitionFunc {
+		return func(t *PaymentTransition) error {
+			transitions = append(transitions, t)
+			return next(t)
+		}
+	})
+	// No Bitcoin client: the strategy does not need one
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{}}
+
+	store.CreatePayment(&Payment{
+		ID:        "p",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	})
+	payment, _ := store.GetPayment("p")
+	if err := monitor.CheckBTCPayments(payment); err != nil {
+		t.Fatalf("CheckBTCPayments() error = %v", err)
+	}
+
+	if len(checked) != 1 || checked[0].Address != "btc-address" || checked[0].Required != 0.001 || checked[0].MinConfirmations != 2 {
+		t.Fatalf("strategy checks = %+v", checked)
+	}
+	stored, _ := store.GetPayment("p")
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.Bitcoin {
+		t.Errorf("payment = %s in %q, want confirmed in BTC", stored.Status, stored.PaidCurrency)
+	}
+	if stored.Confirmations != 2 {
+		t.Errorf("confirmations = %d, want MinConfirmations for a result without any", stored.Confirmations)
+	}
+	if len(transitions) != 1 || transitions[0].TxID != "mempool-tx" {
+		t.Errorf("transitions = %+v, want one confirm with the strategy's txid", transitions)
+	}
+
+	// Monero has no strategy and no client: BalanceConfirmation reports it
+	store.CreatePayment(&Payment{
+		ID:        "x",
+		Addresses: map[wallet.WalletType]string{wallet.Monero: "xmr-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Monero: 0.01},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	})
+	xmrPayment, _ := store.GetPayment("x")
+	if err := monitor.CheckXMRPayments(xmrPayment); err == nil {
+		t.Error("CheckXMRPayments() without a client succeeded")
+	}
+}
+
+func TestAttestationConfirmation(t *testing.T) {
+	attestor := attestorFunc(func(payment *Payment, currency wallet.WalletType, address string) (Attestation, error) {
+		return Attestation{Received: 0.01, Confirmations: 3, TxID: "abc"}, nil
+	})
+	strategy := AttestationConfirmation{Attestor: attestor}
+	for _, minConfirmations := range []int{3, 4} {
+		result, err := strategy.Confirm(ConfirmationCheck{Payment: &Payment{}, Currency: wallet.Monero, Required: 0.01, MinConfirmations: minConfirmations})
+		if err != nil {
+			t.Fatalf("Confirm() error = %v", err)
+		}
+		if want := minConfirmations <= 3; result.Confirmed != want || result.TxID != "abc" {
+			t.Errorf("MinConfirmations %d: Confirm() = %+v, want confirmed %v", minConfirmations, result, want)
+		}
+	}
+}
+
+type attestorFunc func(payment *Payment, currency wallet.WalletType, address string) (Attestation, error)
+
+func (f attestorFunc) Attest(payment *Payment, currency wallet.WalletType, address string) (Attestation, error) {
+	return f(payment, currency, address)
+}
+
+// confirmingClient is a Bitcoin node whose payment has confirmations
+// confirmations: it counts as balance once that reaches the requested minimum
+type confirmingClient struct {
+	amount        float64
+	confirmations int
+}
+
+func (c *confirmingClient) GetAddressBalance(address string) (float64, error) {
+	return c.GetAddressBalanceMinConf(address, 3)
+}
+
+func (c *confirmingClient) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	if c.confirmations < minConf {
+		return 0, nil
+	}
+	return c.amount, nil
+}
+
+func (c *confirmingClient) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	if c.amount < amount {
+		return 0, nil
+	}
+	return min(c.confirmations, maxConf), nil
+}
+
+func TestBalanceConfirmation_CountsConfirmations(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:            store,
+		minConfirmations: 3,
+		logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	client := &confirmingClient{amount: 0.001}
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{wallet.Bitcoin: client}}
+	store.CreatePayment(&Payment{
+		ID:        "p",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	})
+
+	for _, step := range []struct {
+		confirmations int
+		wantStored    int
+		wantStatus    PaymentStatus
+	}{
+		{0, 0, StatusPending},
+		{1, 1, StatusPending},
+		{2, 2, StatusPending},
+		{4, 3, StatusConfirmed},
+	} {
+		client.confirmations = step.confirmations
+		payment, _ := store.GetPayment("p")
+		monitor.checkPayment(payment)
+		stored, _ := store.GetPayment("p")
+		if stored.Confirmations != step.wantStored || stored.Status != step.wantStatus {
+			t.Errorf("with %d confirmations payment = %s with %d, want %s with %d", step.confirmations, stored.Status, stored.Confirmations, step.wantStatus, step.wantStored)
+		}
+	}
+}
+
+func TestValidateConfig_ConfirmationStrategies(t *testing.T) {
+	strategy := BalanceConfirmation{}
+	tests := []struct {
+		name     string
+		config   Config
+		currency wallet.WalletType
+		wantErr  bool
+	}{
+		{"bitcoin", Config{}, wallet.Bitcoin, false},
+		{"litecoin", utxoChainsTestConfig(NewMemoryStore()), wallet.Litecoin, false},
+		{"ether", ethereumTestConfig("http://127.0.0.1:8545", NewMemoryStore()), wallet.Ethereum, false},
+		{"erc20 token", ethereumTestConfig("http://127.0.0.1:8545", NewMemoryStore()), wallet.USDCMainnet.Symbol, false},
+		{"litecoin not configured", Config{}, wallet.Litecoin, true},
+		{"ether not configured", Config{}, wallet.Ethereum, true},
+		{"monero not configured", Config{}, wallet.Monero, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.PriceInBTC, config.PaymentTimeout, config.TestNet = 0.001, time.Hour, true
+			if config.Store == nil {
+				config.Store = NewMemoryStore()
+			}
+			config.ConfirmationStrategies = map[wallet.WalletType]ConfirmationStrategy{tt.currency: strategy}
+			if err := validateConfig(&config); (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package paywall
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// ConfirmationLatency summarizes how long payments in one currency took from
+// creation to confirmation. Durations encode as nanoseconds in JSON.
+type ConfirmationLatency struct {
+	// Payments is the number of timed confirmations
+	Payments int `json:"payments"`
+	// Median is the median time to confirm
+	Median time.Duration `json:"median"`
+	// P90 is the time within which 90% of the payments confirmed
+	P90 time.Duration `json:"p90"`
+	// Mean is the average time to confirm
+	Mean time.Duration `json:"mean"`
+	// Min and Max are the fastest and slowest confirmations
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+}
+
+// ConfirmationReport is the result of Paywall.ConfirmationLatency
+// Related: Paywall.ConfirmationLatency, ConfirmationLatency
+type ConfirmationReport struct {
+	// From and To are the requested report range
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Currencies holds the latency per settling currency
+	Currencies map[wallet.WalletType]ConfirmationLatency `json:"currencies"`
+	// Untimed counts confirmed payments without ConfirmedAt (confirmed before it
+	// was recorded); they are not part of any latency
+	Untimed int `json:"untimed,omitempty"`
+	// Unattributed counts timed payments whose settling currency is unknown
+	Unattributed int `json:"unattributed,omitempty"`
+}
+
+// ConfirmationLatency reports how long payments took to confirm, per currency,
+// for tuning MinConfirmations and telling visitors how long to wait.
+//
+// Parameters:
+//   - from: Inclusive start of the range
+//   - to: Exclusive end of the range
+//
+// Returns:
+//   - *ConfirmationReport: Latency statistics per settling currency
+//   - error: If the range is invalid or the store cannot list payments
+//
+// Payments are included when their ConfirmedAt falls within the range; latency
+// is ConfirmedAt - CreatedAt. The store must implement PaymentStreamer or
+// PaymentLister.
+//
+// Related: Payment.ConfirmedAt, Paywall.Revenue
+func (p *Paywall) ConfirmationLatency(from, to time.Time) (*ConfirmationReport, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid report range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	report := &ConfirmationReport{
+		From:       from,
+		To:         to,
+		Currencies: make(map[wallet.WalletType]ConfirmationLatency),
+	}
+	latencies := make(map[wallet.WalletType][]time.Duration)
+	err := StreamPayments(p.Store, PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}}, func(payment *Payment) error {
+		if payment.ConfirmedAt.IsZero() {
+			if !payment.CreatedAt.Before(from) && payment.CreatedAt.Before(to) {
+				report.Untimed++
+			}
+			return nil
+		}
+		if payment.ConfirmedAt.Before(from) || !payment.ConfirmedAt.Before(to) {
+			return nil
+		}
+		currency, ok := settledCurrency(payment)
+		if !ok {
+			report.Unattributed++
+			return nil
+		}
+		latencies[currency] = append(latencies[currency], payment.ConfirmedAt.Sub(payment.CreatedAt))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream payments: %w", err)
+	}
+
+	for currency, durations := range latencies {
+		report.Currencies[currency] = summarizeLatency(durations)
+	}
+	return report, nil
+}
+
+// summarizeLatency computes the statistics for a non-empty set of durations
+func summarizeLatency(durations []time.Duration) ConfirmationLatency {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	n := len(durations)
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	median := durations[n/2]
+	if n%2 == 0 {
+		median = (durations[n/2-1] + durations[n/2]) / 2
+	}
+	// Nearest-rank percentile
+	p90 := durations[(n*9+9)/10-1]
+
+	return ConfirmationLatency{
+		Payments: n,
+		Median:   median,
+		P90:      p90,
+		Mean:     total / time.Duration(n),
+		Min:      durations[0],
+		Max:      durations[n-1],
+	}
+}
@@ -0,0 +1,63 @@
+package paywall
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestConfirmationLatency(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	btc := map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}
+	both := map[wallet.WalletType]float64{wallet.Bitcoin: 0.001, wallet.Monero: 0.1}
+	confirmed := func(id string, created time.Time, latency time.Duration, paid wallet.WalletType, amounts map[wallet.WalletType]float64) *Payment {
+		payment := revenuePayment(id, created, StatusConfirmed, paid, amounts)
+		payment.ConfirmedAt = created.Add(latency)
+		return payment
+	}
+
+	store := revenueStore(
+		confirmed("a", day, 10*time.Minute, wallet.Bitcoin, both),
+		confirmed("b", day, 20*time.Minute, "", btc),
+		confirmed("c", day, 30*time.Minute, wallet.Bitcoin, both),
+		confirmed("d", day, 60*time.Minute, wallet.Bitcoin, both),
+		confirmed("e", day, 2*time.Minute, wallet.Monero, both),
+		confirmed("f", day, 5*time.Minute, "", both),                             // unattributed
+		confirmed("g", day.Add(-48*time.Hour), time.Minute, wallet.Bitcoin, btc), // out of range
+		revenuePayment("h", day, StatusConfirmed, wallet.Bitcoin, btc),           // untimed
+		revenuePayment("i", day, StatusPending, "", btc),
+	)
+	pw := &Paywall{Store: store, logger: NewDefaultLogger(), fiatCurrency: "USD"}
+
+	report, err := pw.ConfirmationLatency(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ConfirmationLatency() error = %v", err)
+	}
+
+	got := report.Currencies[wallet.Bitcoin]
+	want := ConfirmationLatency{
+		Payments: 4,
+		Median:   25 * time.Minute,
+		P90:      60 * time.Minute,
+		Mean:     30 * time.Minute,
+		Min:      10 * time.Minute,
+		Max:      60 * time.Minute,
+	}
+	if got != want {
+		t.Errorf("bitcoin latency = %+v, want %+v", got, want)
+	}
+	if xmr := report.Currencies[wallet.Monero]; xmr.Payments != 1 || xmr.Median != 2*time.Minute {
+		t.Errorf("monero latency = %+v", xmr)
+	}
+	if report.Unattributed != 1 || report.Untimed != 1 {
+		t.Errorf("Unattributed = %d, Untimed = %d, want 1 and 1", report.Unattributed, report.Untimed)
+	}
+
+	if _, err := pw.ConfirmationLatency(day, day); err == nil {
+		t.Error("expected error for empty range")
+	}
+	if _, err := (&Paywall{Store: &mockStore{}}).ConfirmationLatency(day, day.AddDate(0, 0, 1)); err == nil {
+		t.Error("expected error for store without PaymentLister")
+	}
+}
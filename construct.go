@@ -1,7 +1,11 @@
 package paywall
 
 import (
-	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/opd-ai/paywall/wallet"
@@ -9,7 +13,8 @@ import (
 
 // ConstructPaywall creates and initializes a new Paywall instance.
 // Unlike "NewPaywall" ConstructPaywall automatically configures a
-// persistent wallet with a file backed store.
+// persistent wallet with a file backed store. The wallet is encrypted with a
+// key kept in base/wallet.key, so back up base as a whole.
 // Parameters:
 //   - config: Configuration options for the paywall
 //
@@ -18,19 +23,19 @@ import (
 //   - error: If initialization fails
 //
 // Errors:
-//   - If random seed generation fails
-//   - If HD wallet creation fails
+//   - If the encryption key in base cannot be read or created
+//   - If the wallet in base cannot be loaded, or a new one not created
 //   - If template parsing fails
 //
 // Related types: Config, Paywall
 func ConstructPaywall(base string) (*Paywall, error) {
-	key, err := wallet.GenerateEncryptionKey()
-	if err != nil {
-		return nil, err
-	}
 	if base == "" {
 		base = "./paywallet"
 	}
+	key, err := loadOrCreateKey(filepath.Join(base, walletKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("wallet encryption key: %w", err)
+	}
 
 	storageConfig := wallet.StorageConfig{
 		DataDir:       base,
@@ -39,53 +44,44 @@ func ConstructPaywall(base string) (*Paywall, error) {
 
 	fileStore := NewFileStore(storageConfig.DataDir)
 
-	// Initialize paywall with minimal config
-	pw, err := NewPaywall(Config{
+	// Initialize paywall with minimal config; the wallet saved in base is
+	// loaded, or a new one generated and saved there
+	return NewPaywall(Config{
 		PriceInBTC:       0.0001,    // 0.0001 BTC
 		TestNet:          false,     // don't use testnet
 		Store:            fileStore, // Required for payment tracking
 		PaymentTimeout:   time.Hour * 2,
 		MinConfirmations: 1,
+		BTCWalletStorage: &storageConfig,
 	})
-	if err != nil {
-		return nil, err
-	}
+}
 
-	// Initialize wallet map
-	pw.HDWallets = make(map[wallet.WalletType]wallet.HDWallet)
+// walletKeyFile is the file in ConstructPaywall's directory holding the key
+// the wallet is encrypted with
+const walletKeyFile = "wallet.key"
 
-	// Try to load existing wallet or create new one
-	var btcWallet wallet.HDWallet
-	if loadedWallet, err := wallet.LoadFromFile(storageConfig); err != nil {
-		// Create new wallet if loading fails
-		// securely generate a random 64-byte seed using crypto/rand
-		seed, err := secureRandomSeed()
-		if err != nil {
-			return nil, err
-		}
-		btcWallet, err = wallet.NewBTCHDWallet(seed, false, pw.minConfirmations)
-		if err != nil {
-			return nil, err
-		}
-		// Save the newly generated wallet
-		if err := btcWallet.(*wallet.BTCHDWallet).SaveToFile(storageConfig); err != nil {
-			return nil, err
+// loadOrCreateKey reads the 32-byte encryption key at path, generating and
+// saving one when there is none yet
+func loadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%s holds %d bytes, want 32", path, len(key))
 		}
-	} else {
-		btcWallet = loadedWallet
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
 	}
 
-	// Assign wallet to paywall
-	pw.HDWallets[wallet.Bitcoin] = btcWallet
-
-	return pw, nil
-}
-
-func secureRandomSeed() ([]byte, error) {
-	seed := make([]byte, 64) // 64 bytes for a secure seed
-	_, err := rand.Read(seed)
-	if err != nil {
+	if key, err = wallet.GenerateEncryptionKey(); err != nil {
+		return nil, err
+	}
+	if err := wallet.MkdirMode(filepath.Dir(path), wallet.DefaultDirMode); err != nil {
+		return nil, err
+	}
+	if err := wallet.WriteFileMode(path, key, wallet.DefaultFileMode); err != nil {
 		return nil, err
 	}
-	return seed, nil
+	return key, nil
 }
@@ -2,6 +2,9 @@ package paywall
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -455,3 +458,153 @@ func TestNewPaywall_BitcoinOnlyConfiguration(t *testing.T) {
 		t.Error("Payment should not have Monero address for Bitcoin-only config")
 	}
 }
+
+func TestNewPaywall_MoneroLWSBackend(t *testing.T) {
+	var upserts int
+	lws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upsert_subaddrs":
+			upserts++
+		case "/get_address_info":
+			json.NewEncoder(w).Encode(map[string]interface{}{"blockchain_height": 100})
+			return
+		case "/get_unspent_outs":
+			json.NewEncoder(w).Encode(map[string]interface{}{"outputs": []interface{}{}})
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer lws.Close()
+
+	config := Config{
+		PriceInBTC:       0.001,
+		PriceInXMR:       0.01,
+		TestNet:          true,
+		Store:            NewMemoryStore(),
+		PaymentTimeout:   time.Hour,
+		MinConfirmations: 1,
+		XMRLWS: &wallet.MoneroLWSConfig{
+			URL:     lws.URL,
+			Address: "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A",
+			ViewKey: "f359631075708155cc3d92a32b75a7d02a5dcf27756707b47a2b31b21c389501",
+		},
+	}
+
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() with XMRLWS failed: %v", err)
+	}
+	defer pw.Close()
+
+	if _, ok := pw.HDWallets[wallet.Monero].(*wallet.MoneroLWSWallet); !ok {
+		t.Fatalf("Monero wallet = %T, want *wallet.MoneroLWSWallet", pw.HDWallets[wallet.Monero])
+	}
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() failed: %v", err)
+	}
+	if addr := payment.Addresses[wallet.Monero]; !strings.HasPrefix(addr, "8") {
+		t.Errorf("Monero payment address = %q, want a mainnet subaddress", addr)
+	}
+	if upserts != 1 {
+		t.Errorf("upsert_subaddrs called %d times, want 1", upserts)
+	}
+
+	t.Run("MissingViewKey", func(t *testing.T) {
+		bad := config
+		bad.Store = NewMemoryStore()
+		bad.XMRLWS = &wallet.MoneroLWSConfig{URL: lws.URL, Address: config.XMRLWS.Address}
+		if _, err := NewPaywall(bad); err == nil || !strings.Contains(err.Error(), "XMRLWS requires") {
+			t.Errorf("expected XMRLWS validation error, got %v", err)
+		}
+	})
+}
+
+func TestNewPaywall_ExternalMonitor(t *testing.T) {
+	key := make([]byte, minSigningKeyLength)
+	rand.Read(key)
+	store := NewFileStore(t.TempDir())
+	newWebTier := func(config Config) (*Paywall, error) {
+		config.PriceInBTC = 0.001
+		config.PaymentTimeout = time.Hour
+		config.TestNet = true
+		config.ExternalMonitor = true
+		config.QueryTokenEnabled = true
+		return NewPaywall(config)
+	}
+	watchKey := testWatchKey(t)
+
+	if _, err := newWebTier(Config{Store: store, BTCWatchKey: watchKey}); err == nil {
+		t.Error("NewPaywall() accepted ExternalMonitor without SigningKey")
+	}
+	if _, err := newWebTier(Config{Store: NewMemoryStore(), SigningKey: key, BTCWatchKey: watchKey}); err == nil {
+		t.Error("NewPaywall() accepted ExternalMonitor with a MemoryStore")
+	}
+	if _, err := newWebTier(Config{Store: store, SigningKey: key}); err == nil {
+		t.Error("NewPaywall() accepted ExternalMonitor with a Bitcoin wallet generated per instance")
+	}
+
+	first, err := newWebTier(Config{Store: store, SigningKey: key, BTCWatchKey: watchKey})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer first.Close()
+	if first.monitor != nil || first.timeoutMonitor != nil {
+		t.Error("ExternalMonitor instance started a monitor")
+	}
+	second, err := newWebTier(Config{Store: store, SigningKey: key, BTCWatchKey: watchKey})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer second.Close()
+
+	// The monitor process confirms a payment one instance created ...
+	payment, err := first.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	payment.Status = StatusConfirmed
+	payment.Confirmations = 1
+	if err := store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+
+	// ... and tokens issued by one instance grant access on the others
+	token, err := first.IssueQueryToken(payment.ID, "/feed.xml")
+	if err != nil {
+		t.Fatalf("IssueQueryToken() error = %v", err)
+	}
+	handler := second.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/feed.xml?pw_token="+token, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("second instance answered %d for a token from the first, want 200", rec.Code)
+	}
+}
+
+func TestConstructPaywall_ReloadsWallet(t *testing.T) {
+	base := t.TempDir()
+	pw, err := ConstructPaywall(base)
+	if err != nil {
+		t.Fatalf("ConstructPaywall() error = %v", err)
+	}
+	btcWallet := pw.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet)
+	for range 2 {
+		if _, err := btcWallet.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+	}
+	next, _ := btcWallet.DeriveNextAddress()
+	btcWallet.RollbackLastAddress()
+	pw.Close()
+
+	reopened, err := ConstructPaywall(base)
+	if err != nil {
+		t.Fatalf("ConstructPaywall() again error = %v", err)
+	}
+	defer reopened.Close()
+	if got, err := reopened.HDWallets[wallet.Bitcoin].DeriveNextAddress(); err != nil || got != next {
+		t.Errorf("DeriveNextAddress() after reopening = %s, %v, want %s", got, err, next)
+	}
+}
@@ -239,6 +239,14 @@ func (fs *FailingStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (fs *FailingStore) DeletePayment(id string) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (fs *FailingStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return 0, fmt.Errorf("not implemented")
+}
+
 func (fs *FailingStore) Close() error {
 	return nil
 }
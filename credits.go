@@ -0,0 +1,226 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// CreditsHeader reports the remaining balance of a metered API key, or of
+	// a payment with Config.PaymentCredits, on responses
+	CreditsHeader = "X-Paywall-Credits"
+	// creditTimeout bounds a CreditStore call on the request path
+	creditTimeout = 2 * time.Second
+	// maxCreditAttempts bounds the retries of the default CreditStore when
+	// concurrent requests update the same payment
+	maxCreditAttempts = 5
+)
+
+// CreditStore keeps the credit balances of payments with
+// Config.PaymentCredits. The default counts used credits on the payments
+// themselves; implement it on a shared cache (e.g. Redis DECRBY) so requests
+// with access grants or bearer tokens do not read the store.
+type CreditStore interface {
+	// ConsumeCredits atomically takes cost credits from the balance of a
+	// payment. Concurrent calls must never spend the same credits twice.
+	//
+	// Parameters:
+	//   - paymentID: The confirmed payment paying for the request
+	//   - initial: The balance of a payment without recorded use
+	//   - cost: Credits to take, 0 or more
+	//
+	// Returns:
+	//   - int64: The balance left after this request
+	//   - error: ErrInsufficientCredits, with the balance unchanged, when it
+	//     cannot cover cost; other errors if the balance cannot be updated
+	ConsumeCredits(ctx context.Context, paymentID string, initial, cost int64) (int64, error)
+}
+
+// paymentCreditStore is the default CreditStore: it records used credits in
+// Payment.CreditsUsed and relies on the store's version check for atomicity
+type paymentCreditStore struct {
+	store PaymentStore
+}
+
+// ConsumeCredits implements CreditStore
+func (s *paymentCreditStore) ConsumeCredits(ctx context.Context, paymentID string, initial, cost int64) (int64, error) {
+	for attempt := 0; attempt < maxCreditAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		payment, err := s.store.GetPayment(paymentID)
+		if err != nil {
+			return 0, fmt.Errorf("get payment: %w", err)
+		}
+		if payment == nil {
+			return 0, fmt.Errorf("payment %s not found", paymentID)
+		}
+		remaining := initial - payment.CreditsUsed
+		if remaining < cost {
+			return max(remaining, 0), ErrInsufficientCredits
+		}
+		payment.CreditsUsed += cost
+		err = s.store.UpdatePayment(payment)
+		if errors.Is(err, ErrVersionConflict) {
+			// A concurrent request spent credits first: read the new balance
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("update payment credits: %w", err)
+		}
+		return remaining - cost, nil
+	}
+	return 0, fmt.Errorf("consume credits of payment %s: %w", paymentID, ErrVersionConflict)
+}
+
+// resolveCreditStore picks Config.CreditStore, or else the payments in
+// Config.Store, when Config.PaymentCredits is set
+func resolveCreditStore(config Config) CreditStore {
+	if config.PaymentCredits <= 0 {
+		return nil
+	}
+	if config.CreditStore != nil {
+		return config.CreditStore
+	}
+	return &paymentCreditStore{store: config.Store}
+}
+
+// takeCredits charges r's cost to the balance of paymentID with
+// Config.PaymentCredits, setting CreditsHeader. It always succeeds for
+// unmetered payments.
+//
+// Returns:
+//   - error: ErrInsufficientCredits when the balance cannot cover r, so the
+//     visitor is asked for a new payment; other errors if the CreditStore failed
+func (p *Paywall) takeCredits(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, paymentID string) error {
+	if p.credits == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), creditTimeout)
+	defer cancel()
+	remaining, err := p.credits.ConsumeCredits(ctx, paymentID, p.paymentCredits, cfg.cost(r))
+	if err != nil && !errors.Is(err, ErrInsufficientCredits) {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "credit_store_failed",
+			Message:   fmt.Sprintf("Failed to charge request credits: %v", err),
+			PaymentID: paymentID,
+		})
+		return err
+	}
+	w.Header().Set(CreditsHeader, strconv.FormatInt(remaining, 10))
+	return err
+}
+
+// forwardPaid charges r to the credits of the payment in info and forwards it
+// to next. It returns false without answering r when the payment's credits are
+// used up, so the middleware asks for a new payment; CreditStore failures are
+// answered like store timeouts (Config.StoreTimeoutPolicy).
+func (p *Paywall) forwardPaid(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, info *PaymentInfo, check grantCheck, next http.Handler) bool {
+	switch err := p.takeCredits(w, r, cfg, info.PaymentID); {
+	case errors.Is(err, ErrInsufficientCredits):
+		return false
+	case err != nil:
+		p.respondStoreTimeout(w, r, cfg, next)
+		return true
+	}
+	p.forward(w, r, cfg, info, check, next)
+	return true
+}
+
+// WithRequestCost prices requests on a route for metered API keys and for
+// payments with Config.PaymentCredits, e.g. by endpoint, upload size or
+// requested model. Requests cost 1 credit by default. Keys whose balance
+// cannot cover the cost get 402 Payment Required; payments are replaced by a
+// new one.
+//
+// Parameters:
+//   - cost: Credits to charge for a request; negative values are treated as 0
+func WithRequestCost(cost func(*http.Request) int64) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.requestCost = cost
+	}
+}
+
+// cost returns the credits charged to metered API keys for r
+func (cfg *middlewareConfig) cost(r *http.Request) int64 {
+	if cfg.requestCost == nil {
+		return 1
+	}
+	if c := cfg.requestCost(r); c > 0 {
+		return c
+	}
+	return 0
+}
+
+// TopUpAPIKey converts a confirmed payment into Config.CreditsPerPayment credits
+// on a metered API key. Each payment can top up one key once, so clients buy more
+// calls by paying again and presenting the new payment.
+//
+// Parameters:
+//   - keyID: ID of a metered API key (APIKey.ID)
+//   - paymentID: ID of a confirmed payment that has not been credited yet
+//
+// Returns:
+//   - *APIKey: The key with its new balance
+//   - error: If the key is not metered, the payment is unconfirmed or already
+//     credited, top-ups are disabled, or storage fails
+func (p *Paywall) TopUpAPIKey(keyID, paymentID string) (*APIKey, error) {
+	store, err := p.apiKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	if p.creditsPerPayment <= 0 {
+		return nil, errors.New("top-ups are disabled (Config.CreditsPerPayment is 0)")
+	}
+
+	p.apiKeys.mu.Lock()
+	defer p.apiKeys.mu.Unlock()
+
+	key, err := store.GetAPIKey(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("get API key: %w", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("API key %s not found", keyID)
+	}
+	if !key.Metered {
+		return nil, fmt.Errorf("API key %s is not metered", keyID)
+	}
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, fmt.Errorf("payment %s not found", paymentID)
+	}
+	if payment.Status != StatusConfirmed {
+		return nil, fmt.Errorf("payment %s is not confirmed (status %s)", paymentID, payment.Status)
+	}
+	if payment.CreditedAPIKey != "" {
+		return nil, fmt.Errorf("payment %s was already credited to API key %s", paymentID, payment.CreditedAPIKey)
+	}
+
+	// Claim the payment first: the version check stops concurrent double top-ups
+	payment.CreditedAPIKey = key.ID
+	if err := p.Store.UpdatePayment(payment); err != nil {
+		return nil, fmt.Errorf("mark payment credited: %w", err)
+	}
+	key.Credits += p.creditsPerPayment
+	if err := store.SaveAPIKey(key); err != nil {
+		return nil, fmt.Errorf("save API key credits: %w", err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "api_key_topped_up",
+		Message:   fmt.Sprintf("Added %d credits to API key %s (balance %d)", p.creditsPerPayment, key.ID, key.Credits),
+		PaymentID: paymentID,
+	})
+	return key, nil
+}
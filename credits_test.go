@@ -0,0 +1,200 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMeteredAPIKeys(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:        0.001,
+		PaymentTimeout:    time.Hour,
+		TestNet:           true,
+		Store:             NewMemoryStore(),
+		APIKeysEnabled:    true,
+		CreditsPerPayment: 5,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), WithRequestCost(func(r *http.Request) int64 {
+		if r.URL.Path == "/api/expensive" {
+			return 3
+		}
+		return 1
+	}))
+	call := func(key, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(DefaultAPIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	payment := confirmedTestPayment(t, pw)
+	key, record, err := pw.IssueAPIKey(payment.ID, APIKeyOptions{Metered: true})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+	if record.Credits != 5 {
+		t.Fatalf("initial credits = %d, want 5 from the issuing payment", record.Credits)
+	}
+	if _, err := pw.TopUpAPIKey(record.ID, payment.ID); err == nil {
+		t.Error("TopUpAPIKey() should refuse a payment that was already credited")
+	}
+
+	steps := []struct {
+		path        string
+		wantStatus  int
+		wantCredits string
+	}{
+		{"/api/expensive", http.StatusOK, "2"},
+		{"/api/cheap", http.StatusOK, "1"},
+		{"/api/expensive", http.StatusPaymentRequired, "1"},
+		{"/api/cheap", http.StatusOK, "0"},
+		{"/api/cheap", http.StatusPaymentRequired, "0"},
+	}
+	for i, step := range steps {
+		rec := call(key, step.path)
+		if rec.Code != step.wantStatus || rec.Header().Get(CreditsHeader) != step.wantCredits {
+			t.Fatalf("step %d %s: status = %d credits = %q, want %d %q",
+				i, step.path, rec.Code, rec.Header().Get(CreditsHeader), step.wantStatus, step.wantCredits)
+		}
+	}
+
+	topUp := confirmedTestPayment(t, pw)
+	if _, err := pw.TopUpAPIKey(record.ID, topUp.ID); err != nil {
+		t.Fatalf("TopUpAPIKey() error = %v", err)
+	}
+	if rec := call(key, "/api/expensive"); rec.Code != http.StatusOK || rec.Header().Get(CreditsHeader) != "2" {
+		t.Errorf("after top-up: status = %d credits = %q, want 200 \"2\"", rec.Code, rec.Header().Get(CreditsHeader))
+	}
+
+	pending, _ := pw.CreatePayment()
+	if _, err := pw.TopUpAPIKey(record.ID, pending.ID); err == nil {
+		t.Error("TopUpAPIKey() should refuse unconfirmed payments")
+	}
+	_, unmetered, _ := pw.CreateAPIKey(APIKeyOptions{})
+	if _, err := pw.TopUpAPIKey(unmetered.ID, confirmedTestPayment(t, pw).ID); err == nil {
+		t.Error("TopUpAPIKey() should refuse unmetered keys")
+	}
+}
+
+func TestMiddleware_PaymentCredits(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		PaymentCredits: 4,
+		AccessGrantTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	payment, _ := pw.CreatePayment()
+	pw.markConfirmed(payment, time.Now())
+	pw.Store.UpdatePayment(payment)
+
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), WithRequestCost(func(r *http.Request) int64 {
+		if r.URL.Path == "/api/expensive" {
+			return 3
+		}
+		return 1
+	}))
+	cookies := []*http.Cookie{{Name: "payment_id", Value: payment.ID}}
+	call := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path        string
+		wantBody    string
+		wantCredits string
+	}{
+		{"/api/cheap", "ok", "3"},
+		{"/api/expensive", "ok", "0"},
+		{"/api/cheap", "", "0"},
+	}
+	for i, tt := range tests {
+		rec := call(tt.path)
+		if got := rec.Body.String(); (got == "ok") != (tt.wantBody == "ok") {
+			t.Errorf("request %d to %s: body = %.40q, want paid %v", i, tt.path, got, tt.wantBody == "ok")
+		}
+		if got := rec.Header().Get(CreditsHeader); got != tt.wantCredits {
+			t.Errorf("request %d to %s: %s = %q, want %q", i, tt.path, CreditsHeader, got, tt.wantCredits)
+		}
+		// Later requests carry the access grant as well, which is charged too
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == accessGrantCookie {
+				cookies = append(cookies, c)
+			}
+		}
+	}
+
+	// With its credits used up the visitor gets a new payment
+	var newPayment string
+	for _, c := range call("/api/cheap").Result().Cookies() {
+		if c.Name == "payment_id" {
+			newPayment = c.Value
+		}
+	}
+	if newPayment == "" || newPayment == payment.ID {
+		t.Errorf("payment cookie = %q, want a new payment", newPayment)
+	}
+	if stored, _ := pw.Store.GetPayment(payment.ID); stored.CreditsUsed != 4 {
+		t.Errorf("CreditsUsed = %d, want 4", stored.CreditsUsed)
+	}
+}
+
+func TestPaymentCreditStore_Concurrent(t *testing.T) {
+	store := NewMemoryStore()
+	store.CreatePayment(&Payment{ID: "p1", Status: StatusConfirmed})
+	credits := &paymentCreditStore{store: store}
+
+	const requests = 20
+	results := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			_, err := credits.ConsumeCredits(context.Background(), "p1", 10, 1)
+			results <- err
+		}()
+	}
+	// Requests beyond the balance are refused; some may also give up after
+	// losing the version check maxCreditAttempts times
+	spent := 0
+	for i := 0; i < requests; i++ {
+		if err := <-results; err == nil {
+			spent++
+		} else if !errors.Is(err, ErrInsufficientCredits) && !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("ConsumeCredits() error = %v", err)
+		}
+	}
+	stored, _ := store.GetPayment("p1")
+	if spent > 10 || int64(spent) != stored.CreditsUsed {
+		t.Errorf("spent %d credits, recorded %d, want at most 10 and equal", spent, stored.CreditsUsed)
+	}
+	if remaining, err := credits.ConsumeCredits(context.Background(), "p1", 10, 0); err != nil || remaining != 10-stored.CreditsUsed {
+		t.Errorf("ConsumeCredits(0) = %d, %v, want the balance", remaining, err)
+	}
+	if _, err := credits.ConsumeCredits(context.Background(), "missing", 10, 1); err == nil {
+		t.Error("ConsumeCredits() of an unknown payment succeeded")
+	}
+}
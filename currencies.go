@@ -0,0 +1,163 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// ErrCurrencyNotConfigured is returned when toggling a currency the paywall has no wallet for
+var ErrCurrencyNotConfigured = errors.New("currency has no wallet configured")
+
+// ErrLastCurrency is returned by DisableCurrency for the only enabled currency
+var ErrLastCurrency = errors.New("cannot disable the last enabled currency")
+
+// configuredCurrencies returns the currencies config creates a wallet for,
+// the ones EnableCurrency accepts once the paywall runs. Monero and
+// Lightning count when configured, even if their wallet turns out to be
+// unreachable at start.
+func configuredCurrencies(config Config) map[wallet.WalletType]bool {
+	currencies := map[wallet.WalletType]bool{wallet.Bitcoin: true}
+	if config.XMRLWS != nil || config.XMRUser != "" || config.XMRPassword != "" || config.XMRRPC != "" || config.PriceInXMR > 0 {
+		currencies[wallet.Monero] = true
+	}
+	if config.Lightning != nil {
+		currencies[wallet.Lightning] = true
+	}
+	if config.Ethereum != nil {
+		if config.PriceInETH > 0 {
+			currencies[wallet.Ethereum] = true
+		}
+		for _, token := range config.ERC20 {
+			currencies[token.Token.Symbol] = true
+		}
+	}
+	for _, chain := range config.UTXOChains {
+		currencies[chain.Type] = true
+	}
+	return currencies
+}
+
+// EnableCurrency accepts walletType for new payments again after DisableCurrency.
+// The change applies to the next payment; it is not persisted across restarts.
+//
+// Parameters:
+//   - walletType: Currency to enable
+//
+// Returns:
+//   - error: ErrCurrencyNotConfigured if the paywall has no wallet for walletType
+//
+// Related: DisableCurrency, CurrencyEnabled
+func (p *Paywall) EnableCurrency(walletType wallet.WalletType) error {
+	return p.setCurrencyEnabled(walletType, true)
+}
+
+// DisableCurrency stops offering walletType, e.g. while its wallet RPC is under
+// maintenance. New payments get no address in that currency and payment pages
+// hide it as long as the payment has another currency to pay with. Pending
+// payments keep their addresses and the monitor keeps checking them, so
+// visitors who already paid are still confirmed.
+//
+// Parameters:
+//   - walletType: Currency to disable
+//
+// Returns:
+//   - error: ErrCurrencyNotConfigured if the paywall has no wallet for
+//     walletType, ErrLastCurrency if no other currency would remain (use
+//     SetMode(ModeReadOnly) to stop taking payments altogether)
+//
+// Related: EnableCurrency, CurrencyEnabled
+func (p *Paywall) DisableCurrency(walletType wallet.WalletType) error {
+	return p.setCurrencyEnabled(walletType, false)
+}
+
+// CurrencyEnabled reports whether new payments are offered in walletType
+func (p *Paywall) CurrencyEnabled(walletType wallet.WalletType) bool {
+	_, ok := p.HDWallets[walletType]
+	return ok && !p.currencyDisabled(walletType)
+}
+
+// currencyDisabled reports whether DisableCurrency took walletType out of new payments
+func (p *Paywall) currencyDisabled(walletType wallet.WalletType) bool {
+	p.currencyMu.RLock()
+	defer p.currencyMu.RUnlock()
+	return p.disabledCurrencies[walletType]
+}
+
+// setCurrencyEnabled records the toggle and logs changes
+func (p *Paywall) setCurrencyEnabled(walletType wallet.WalletType, enabled bool) error {
+	if _, ok := p.HDWallets[walletType]; !ok {
+		return fmt.Errorf("%w: %s", ErrCurrencyNotConfigured, walletType)
+	}
+
+	p.currencyMu.Lock()
+	previous := !p.disabledCurrencies[walletType]
+	if !enabled {
+		remaining := 0
+		for other := range p.HDWallets {
+			if other != walletType && !p.disabledCurrencies[other] {
+				remaining++
+			}
+		}
+		if remaining == 0 {
+			p.currencyMu.Unlock()
+			return fmt.Errorf("%w: %s", ErrLastCurrency, walletType)
+		}
+	}
+	if p.disabledCurrencies == nil {
+		p.disabledCurrencies = make(map[wallet.WalletType]bool)
+	}
+	if enabled {
+		delete(p.disabledCurrencies, walletType)
+	} else {
+		p.disabledCurrencies[walletType] = true
+	}
+	p.currencyMu.Unlock()
+
+	if previous != enabled {
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		p.logger.log(LogEntry{
+			Level:    LogLevelWarn,
+			Event:    "currency_" + state,
+			Message:  fmt.Sprintf("%s %s for new payments", walletType, state),
+			Currency: walletType,
+		})
+	}
+	return nil
+}
+
+// hideDisabledCurrencies removes disabled currencies from a payment page,
+// unless the payment could not be paid otherwise
+func (p *Paywall) hideDisabledCurrencies(data *PaymentPageData) {
+	btc := data.BTCAddress != "" && !p.currencyDisabled(wallet.Bitcoin)
+	xmr := data.XMRAddress != "" && !p.currencyDisabled(wallet.Monero)
+	ln := data.LNInvoice != "" && !p.currencyDisabled(wallet.Lightning)
+	eth := data.ETHAddress != "" && !p.currencyDisabled(wallet.Ethereum)
+	enabled := func(options []PaymentOption) []PaymentOption {
+		return slices.DeleteFunc(slices.Clone(options), func(option PaymentOption) bool {
+			return p.currencyDisabled(option.Currency)
+		})
+	}
+	tokens, coins := enabled(data.Tokens), enabled(data.Coins)
+	if !btc && !xmr && !ln && !eth && len(tokens) == 0 && len(coins) == 0 {
+		return
+	}
+	if !btc {
+		data.BTCAddress, data.AmountBTC, data.BTCPaymentURI, data.BTCQRCode = "", 0, "", ""
+	}
+	if !xmr {
+		data.XMRAddress, data.AmountXMR, data.XMRPaymentURI, data.XMRQRCode = "", 0, "", ""
+	}
+	if !ln {
+		data.LNInvoice, data.AmountLN, data.AmountLNSats, data.LNPaymentURI, data.LNQRCode = "", 0, 0, "", ""
+	}
+	if !eth {
+		data.ETHAddress, data.AmountETH, data.ETHPaymentURI, data.ETHQRCode = "", 0, "", ""
+	}
+	data.Tokens, data.Coins = tokens, coins
+}
@@ -0,0 +1,106 @@
+package paywall
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestDisableCurrency(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	pw.HDWallets[wallet.Monero] = &handlerTestHDWallet{}
+
+	if err := pw.DisableCurrency("DOGE"); !errors.Is(err, ErrCurrencyNotConfigured) {
+		t.Errorf("DisableCurrency(DOGE) error = %v, want ErrCurrencyNotConfigured", err)
+	}
+
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if err := pw.DisableCurrency(wallet.Monero); err != nil {
+		t.Fatalf("DisableCurrency(XMR) error = %v", err)
+	}
+	if pw.CurrencyEnabled(wallet.Monero) {
+		t.Error("CurrencyEnabled(XMR) = true after DisableCurrency")
+	}
+	if err := pw.DisableCurrency(wallet.Bitcoin); !errors.Is(err, ErrLastCurrency) {
+		t.Errorf("DisableCurrency(BTC) error = %v, want ErrLastCurrency", err)
+	}
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, ok := payment.Addresses[wallet.Monero]; ok {
+		t.Error("new payment has an XMR address while XMR is disabled")
+	}
+	if _, ok := payment.Addresses[wallet.Bitcoin]; !ok {
+		t.Error("new payment has no BTC address")
+	}
+
+	// Existing payments keep the disabled currency
+	stored, err := pw.Store.GetPayment(pending.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetPayment() = %v, %v", stored, err)
+	}
+	if _, ok := stored.Addresses[wallet.Monero]; !ok {
+		t.Error("pending payment lost its XMR address")
+	}
+
+	if err := pw.EnableCurrency(wallet.Monero); err != nil {
+		t.Fatalf("EnableCurrency(XMR) error = %v", err)
+	}
+	payment, err = pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, ok := payment.Addresses[wallet.Monero]; !ok {
+		t.Error("new payment has no XMR address after EnableCurrency")
+	}
+}
+
+func TestHideDisabledCurrencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled []wallet.WalletType
+		payment  func() *Payment
+		wantBTC  bool
+		wantXMR  bool
+	}{
+		{name: "all enabled", payment: createHandlerTestPayment, wantBTC: true, wantXMR: true},
+		{name: "XMR disabled", disabled: []wallet.WalletType{wallet.Monero}, payment: createHandlerTestPayment, wantBTC: true},
+		{name: "BTC disabled", disabled: []wallet.WalletType{wallet.Bitcoin}, payment: createHandlerTestPayment, wantXMR: true},
+		{
+			name:     "only payable in disabled currency",
+			disabled: []wallet.WalletType{wallet.Monero},
+			payment: func() *Payment {
+				payment := createHandlerTestPayment()
+				delete(payment.Addresses, wallet.Bitcoin)
+				return payment
+			},
+			wantXMR: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw := createTestPaywall()
+			pw.disabledCurrencies = make(map[wallet.WalletType]bool)
+			for _, walletType := range tt.disabled {
+				pw.disabledCurrencies[walletType] = true
+			}
+			data, _ := NewPaymentPageData(tt.payment())
+			pw.hideDisabledCurrencies(&data)
+			if got := data.BTCAddress != ""; got != tt.wantBTC {
+				t.Errorf("BTC shown = %v, want %v", got, tt.wantBTC)
+			}
+			if got := data.XMRAddress != ""; got != tt.wantXMR {
+				t.Errorf("XMR shown = %v, want %v", got, tt.wantXMR)
+			}
+			if !tt.wantXMR && (data.XMRQRCode != "" || data.XMRPaymentURI != "") {
+				t.Error("hidden XMR option still has a QR code or payment link")
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package paywall
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// adminAssetsFS embeds the admin dashboard's template and stylesheet
+//
+//go:embed templates/admin.html static/admin.css
+var adminAssetsFS embed.FS
+
+const (
+	// dashboardRevenueDays is the revenue period shown on the dashboard
+	dashboardRevenueDays = 30
+	// dashboardRecentPayments is the number of payments listed on the dashboard
+	dashboardRecentPayments = 25
+)
+
+// dashboardTemplate renders the admin dashboard
+var dashboardTemplate = template.Must(template.New("admin.html").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"formatAmount": func(amount float64) string {
+		return strconv.FormatFloat(amount, 'f', -1, 64)
+	},
+}).ParseFS(adminAssetsFS, "templates/admin.html"))
+
+// AdminDashboard is the data shown by the admin dashboard
+// Related: Paywall.Dashboard, AdminHandler
+type AdminDashboard struct {
+	// Time is when the data was collected
+	Time time.Time `json:"time"`
+	// Mode is the current operating mode
+	Mode Mode `json:"mode"`
+	// Stats are the current pending counts and today's revenue
+	Stats *StatsSnapshot `json:"stats"`
+	// StatusCounts counts all stored payments by status
+	StatusCounts map[PaymentStatus]int `json:"status_counts"`
+	// RevenueDays is the period of Revenue, in days
+	RevenueDays int `json:"revenue_days"`
+	// Revenue is the daily revenue of the last RevenueDays days
+	Revenue *RevenueReport `json:"revenue"`
+	// Recent are the newest payments
+	Recent []*Payment `json:"recent"`
+	// Wallets are the derivation states of the configured wallets, by currency
+	Wallets []WalletIndex `json:"wallets"`
+}
+
+// WalletIndex is the derivation state of an HD wallet
+type WalletIndex struct {
+	// Currency of the wallet
+	Currency wallet.WalletType `json:"currency"`
+	// NextIndex is the index of the next address the wallet derives
+	NextIndex uint32 `json:"next_index"`
+	// Tracked is false for wallets that do not report their index, such as
+	// Lightning nodes and rotated Bitcoin wallets
+	Tracked bool `json:"tracked"`
+}
+
+// Dashboard collects the data shown by the admin dashboard: pending and
+// confirmed counts, revenue per currency, recent payments and the wallets'
+// derivation indexes. The store is read several times; do not call it on
+// every request of a busy site.
+//
+// Returns:
+//   - *AdminDashboard: The dashboard data
+//   - error: If the store cannot be streamed or listed
+//
+// Related: AdminHandler, CurrentStats, Revenue
+func (p *Paywall) Dashboard() (*AdminDashboard, error) {
+	now := time.Now().UTC()
+	stats, err := p.CurrentStats()
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := p.Revenue(now.Add(-dashboardRevenueDays*24*time.Hour), now, BucketDay)
+	if err != nil {
+		return nil, err
+	}
+	recent, _, err := p.ListAdminPayments(AdminPaymentFilter{Limit: dashboardRecentPayments})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[PaymentStatus]int)
+	err = StreamPayments(p.Store, PaymentFilter{}, func(payment *Payment) error {
+		counts[payment.Status]++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("count payments: %w", err)
+	}
+
+	return &AdminDashboard{
+		Time:         now,
+		Mode:         p.Mode(),
+		Stats:        stats,
+		StatusCounts: counts,
+		RevenueDays:  dashboardRevenueDays,
+		Revenue:      revenue,
+		Recent:       recent,
+		Wallets:      p.walletIndexes(),
+	}, nil
+}
+
+// walletIndexes returns the derivation state of every configured wallet
+func (p *Paywall) walletIndexes() []WalletIndex {
+	indexes := make([]WalletIndex, 0, len(p.HDWallets))
+	for currency, hdWallet := range p.HDWallets {
+		index := WalletIndex{Currency: currency}
+		switch w := hdWallet.(type) {
+		case interface{ GetNextIndex() uint32 }:
+			index.NextIndex, index.Tracked = w.GetNextIndex(), true
+		case interface{ NextIndex() uint32 }:
+			index.NextIndex, index.Tracked = w.NextIndex(), true
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Currency < indexes[j].Currency })
+	return indexes
+}
+
+// handleDashboard serves the admin dashboard page at the root of AdminHandler
+func (p *Paywall) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	dashboard, err := p.Dashboard()
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "admin_dashboard_failed",
+			Message: fmt.Sprintf("Failed to load dashboard: %v", err),
+		})
+		http.Error(w, "Failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, dashboard); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "admin_dashboard_failed",
+			Message: fmt.Sprintf("Failed to render dashboard: %v", err),
+		})
+		http.Error(w, "Failed to render dashboard", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'self'; form-action 'self'; frame-ancestors 'none'")
+	w.Write(page.Bytes())
+}
+
+// handleDashboardStyle serves the dashboard's stylesheet
+func (p *Paywall) handleDashboardStyle(w http.ResponseWriter, r *http.Request) {
+	css, err := adminAssetsFS.ReadFile("static/admin.css")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write(css)
+}
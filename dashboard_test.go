@@ -0,0 +1,134 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestAdminHandler_Dashboard(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	pw.adminUsers = map[string]string{"alice": "correct horse battery"}
+	now := time.Now()
+	pw.Store.CreatePayment(&Payment{
+		ID:           "paid-btc",
+		Status:       StatusConfirmed,
+		PaidCurrency: wallet.Bitcoin,
+		Addresses:    map[wallet.WalletType]string{wallet.Bitcoin: "addr-paid"},
+		Amounts:      map[wallet.WalletType]float64{wallet.Bitcoin: 0.0025},
+		CreatedAt:    now.Add(-time.Hour),
+		ExpiresAt:    now.Add(time.Hour),
+		ConfirmedAt:  now.Add(-30 * time.Minute),
+	})
+	pending, _ := pw.CreatePayment()
+
+	get := func(path, user, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		rec := httptest.NewRecorder()
+		pw.AdminHandler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/", "alice", "wrong password!"); rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Errorf("wrong password: status = %d, challenge = %q, want 401 with a Basic challenge", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if rec := get("/", "bob", "correct horse battery"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user: status = %d, want 401", rec.Code)
+	}
+
+	rec := get("/", "alice", "correct horse battery")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dashboard status = %d: %s", rec.Code, rec.Body.String())
+	}
+	page := rec.Body.String()
+	for _, want := range []string{
+		`<td class="mono"><a href="payments/paid-btc">paid-btc</a></td>`,
+		`<td class="mono"><a href="payments/` + pending.ID + `">`,
+		`<td>BTC</td><td class="amount">0.0025</td><td class="amount">0.0025</td>`,
+		`<td>BTC</td><td class="amount">1</td>`,
+		`confirmed in total`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("dashboard does not contain %q", want)
+		}
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Errorf("Content-Security-Policy = %q", csp)
+	}
+
+	if rec := get("/assets/admin.css", "alice", "correct horse battery"); rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("stylesheet status = %d, Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	// The bearer token keeps working next to the users
+	if rec := adminRequest(pw, http.MethodGet, "/", ""); rec.Code != http.StatusOK {
+		t.Errorf("dashboard with the token status = %d, want 200", rec.Code)
+	}
+}
+
+func TestAdminHandler_DashboardForms(t *testing.T) {
+	pw := newTestPaywall(t, adminTestConfig(t))
+	pw.adminUsers = map[string]string{"alice": "correct horse battery"}
+	payment, _ := pw.CreatePayment()
+
+	post := func(referer string) *httptest.ResponseRecorder {
+		body := url.Values{"note": {"no-show"}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/payments/"+payment.ID+"/expire", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("alice", "correct horse battery")
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+		rec := httptest.NewRecorder()
+		pw.AdminHandler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, referer := range []string{"", "https://evil.example/paywall/admin/"} {
+		if rec := post(referer); rec.Code != http.StatusForbidden {
+			t.Errorf("form post with Referer %q status = %d, want 403", referer, rec.Code)
+		}
+	}
+	if stored, _ := pw.Store.GetPayment(payment.ID); stored.Status != StatusPending {
+		t.Fatalf("payment = %s after rejected posts, want pending", stored.Status)
+	}
+
+	rec := post("http://example.com/paywall/admin/")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/paywall/admin/" {
+		t.Errorf("form post status = %d, Location = %q, want 303 to the dashboard", rec.Code, rec.Header().Get("Location"))
+	}
+	if stored, _ := pw.Store.GetPayment(payment.ID); stored.Status != StatusExpired {
+		t.Errorf("payment = %s, want expired", stored.Status)
+	}
+}
+
+func TestValidateAdminAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"none", Config{}, ""},
+		{"users only", Config{AdminUsers: map[string]string{"alice": "correct horse battery"}}, ""},
+		{"short token", Config{AdminToken: "short"}, "AdminToken"},
+		{"short password", Config{AdminUsers: map[string]string{"alice": "secret"}}, "at least 12"},
+		{"empty user", Config{AdminUsers: map[string]string{"": "correct horse battery"}}, "user name"},
+		{"colon in user", Config{AdminUsers: map[string]string{"a:b": "correct horse battery"}}, "user name"},
+	}
+	for _, tt := range tests {
+		err := validateAdminAuth(tt.config)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: error = %v, want nil", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultReusePendingWindow is how long a pending payment can be reclaimed by fingerprint
+const defaultReusePendingWindow = 30 * time.Minute
+
+// pendingIndex remembers which pending payment was created for a request
+// fingerprint, so a visitor who loses their cookie mid-checkout is shown the
+// same payment (and address) again. It is in-memory only by design.
+//
+// Related: Config.ReusePendingPayments, claimPendingPayment
+type pendingIndex struct {
+	mu        sync.Mutex
+	window    time.Duration
+	entries   map[string]pendingEntry
+	lastPrune time.Time
+}
+
+// pendingEntry is a fingerprint's most recent pending payment
+type pendingEntry struct {
+	paymentID string
+	createdAt time.Time
+}
+
+func newPendingIndex(window time.Duration) *pendingIndex {
+	return &pendingIndex{
+		window:  window,
+		entries: make(map[string]pendingEntry),
+	}
+}
+
+// lookup returns the payment ID remembered for fingerprint if still within the window
+func (ix *pendingIndex) lookup(fingerprint string, now time.Time) (string, bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	entry, ok := ix.entries[fingerprint]
+	if !ok {
+		return "", false
+	}
+	if now.Sub(entry.createdAt) > ix.window {
+		delete(ix.entries, fingerprint)
+		return "", false
+	}
+	return entry.paymentID, true
+}
+
+// remember associates fingerprint with a newly created payment, pruning stale
+// entries at most once per window to keep memory bounded
+func (ix *pendingIndex) remember(fingerprint, paymentID string, now time.Time) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if now.Sub(ix.lastPrune) > ix.window {
+		for fp, entry := range ix.entries {
+			if now.Sub(entry.createdAt) > ix.window {
+				delete(ix.entries, fp)
+			}
+		}
+		ix.lastPrune = now
+	}
+	ix.entries[fingerprint] = pendingEntry{paymentID: paymentID, createdAt: now}
+}
+
+// forget removes fingerprint from the index
+func (ix *pendingIndex) forget(fingerprint string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.entries, fingerprint)
+}
+
+// requestFingerprint derives the keyed fingerprint of a request.
+// The raw inputs (client IP, User-Agent, Accept-Language or the output of
+// Config.PaymentFingerprint) are HMACed with the signing key so the index never
+// holds identifying data in the clear.
+//
+// Returns:
+//   - string: Fingerprint, or "" when reuse should not apply to this request
+func (p *Paywall) requestFingerprint(r *http.Request) string {
+	if p.paymentFingerprint != nil {
+		raw := p.paymentFingerprint(r)
+		if raw == "" {
+			return ""
+		}
+		return p.signer.sign("fingerprint/v1", raw)
+	}
+	return p.signer.sign("fingerprint/v1",
+		p.clientIP(r),
+		r.UserAgent(),
+		r.Header.Get("Accept-Language"),
+	)
+}
+
+// claimPendingPayment returns the recent pending payment created for a request
+// with the same fingerprint, if any. Confirmed, expired or otherwise settled
+// payments are never returned, so a fingerprint match cannot grant access.
+//
+// Parameters:
+//   - r: The request without a payment
+//   - route: Name of the request's route (see WithRoute), "" for the default
+//     route; each route remembers its own payments
+//
+// Returns:
+//   - *Payment: The reusable pending payment, or nil
+//   - string: The request fingerprint ("" when reuse is disabled for the request),
+//     to be passed to rememberPendingPayment for new payments
+func (p *Paywall) claimPendingPayment(r *http.Request, route string) (*Payment, string) {
+	if p.pendingIndex == nil {
+		return nil, ""
+	}
+	fingerprint := p.requestFingerprint(r)
+	if fingerprint == "" {
+		return nil, ""
+	}
+	if route != "" {
+		fingerprint += "/" + route
+	}
+
+	now := time.Now()
+	paymentID, ok := p.pendingIndex.lookup(fingerprint, now)
+	if !ok {
+		return nil, fingerprint
+	}
+	payment, err := p.getPaymentInBudget(r.Context(), paymentID)
+	if errors.Is(err, ErrStoreTimeout) {
+		// The store may just be slow: keep the entry for the next visit
+		return nil, fingerprint
+	}
+	if err != nil || payment == nil || payment.Status != StatusPending || !now.Before(payment.ExpiresAt) || payment.Route != route {
+		p.pendingIndex.forget(fingerprint)
+		return nil, fingerprint
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "pending_payment_reused",
+		Message:   "Re-attached visitor without payment cookie to recent pending payment",
+		PaymentID: payment.ID,
+	})
+	return payment, fingerprint
+}
+
+// rememberPendingPayment records a newly created payment for fingerprint
+func (p *Paywall) rememberPendingPayment(fingerprint, paymentID string) {
+	if p.pendingIndex == nil || fingerprint == "" {
+		return
+	}
+	p.pendingIndex.remember(fingerprint, paymentID, time.Now())
+}
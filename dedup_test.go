@@ -0,0 +1,118 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// visit requests a protected page without cookies and returns the payment ID cookie set
+func visit(t *testing.T, handler http.Handler, remoteAddr, userAgent string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/article", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "payment_id" {
+			return c.Value
+		}
+	}
+	t.Fatalf("no payment_id cookie set (status %d)", rec.Code)
+	return ""
+}
+
+func TestMiddleware_ReusePendingPayment(t *testing.T) {
+	pw := newTestPaywall(t, Config{ReusePendingPayments: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	first := visit(t, handler, "203.0.113.5:1234", "Mozilla/5.0")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userAgent  string
+		wantSame   bool
+	}{
+		{"SameVisitorNewPort", "203.0.113.5:5678", "Mozilla/5.0", true},
+		{"DifferentIP", "203.0.113.6:1234", "Mozilla/5.0", false},
+		{"DifferentUserAgent", "203.0.113.5:1234", "curl/8.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := visit(t, handler, tt.remoteAddr, tt.userAgent)
+			if (got == first) != tt.wantSame {
+				t.Errorf("payment reused = %v, want %v", got == first, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ReusePendingPayment_NeverReusesConfirmed(t *testing.T) {
+	pw := newTestPaywall(t, Config{ReusePendingPayments: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("fingerprint match must not grant access")
+	}))
+
+	first := visit(t, handler, "203.0.113.5:1234", "Mozilla/5.0")
+	payment, err := pw.Store.GetPayment(first)
+	if err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+	payment.Status = StatusConfirmed
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+
+	if second := visit(t, handler, "203.0.113.5:1234", "Mozilla/5.0"); second == first {
+		t.Error("confirmed payment was re-attached by fingerprint")
+	}
+}
+
+func TestMiddleware_ReusePendingPayment_CustomFingerprint(t *testing.T) {
+	pw := newTestPaywall(t, Config{
+		ReusePendingPayments: true,
+		PaymentFingerprint: func(r *http.Request) string {
+			return r.Header.Get("User-Agent")
+		},
+	})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	first := visit(t, handler, "203.0.113.5:1234", "app/1.0")
+	if got := visit(t, handler, "198.51.100.1:1234", "app/1.0"); got != first {
+		t.Error("custom fingerprint ignoring IP should reuse the payment")
+	}
+	// An empty fingerprint opts the request out of reuse
+	if a, b := visit(t, handler, "203.0.113.5:1", ""), visit(t, handler, "203.0.113.5:1", ""); a == b {
+		t.Error("empty fingerprint should not reuse payments")
+	}
+}
+
+func TestPendingIndex_Window(t *testing.T) {
+	ix := newPendingIndex(time.Minute)
+	now := time.Now()
+	ix.remember("fp", "payment-1", now)
+
+	if id, ok := ix.lookup("fp", now.Add(30*time.Second)); !ok || id != "payment-1" {
+		t.Errorf("lookup() within window = %q, %v", id, ok)
+	}
+	if _, ok := ix.lookup("fp", now.Add(2*time.Minute)); ok {
+		t.Error("lookup() after window should miss")
+	}
+
+	ix.remember("old", "payment-2", now)
+	ix.remember("new", "payment-3", now.Add(3*time.Minute))
+	if _, ok := ix.entries["old"]; ok {
+		t.Error("stale entries should be pruned on insert")
+	}
+}
+
+func TestReusePendingPayments_Disabled(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if visit(t, handler, "203.0.113.5:1", "Mozilla/5.0") == visit(t, handler, "203.0.113.5:1", "Mozilla/5.0") {
+		t.Error("payments should not be reused unless ReusePendingPayments is set")
+	}
+}
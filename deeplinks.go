@@ -0,0 +1,91 @@
+package paywall
+
+import (
+	"html/template"
+	"slices"
+	"strings"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// Mobile platforms recognized from the User-Agent for wallet suggestions
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// WalletApp is a mobile wallet the payment page can suggest to visitors on a
+// phone, where scanning a QR code on one's own screen is impossible
+type WalletApp struct {
+	// Name is shown on the link, e.g. "Cake Wallet"
+	Name string
+	// Currency is the currency the app pays
+	Currency wallet.WalletType
+	// Platforms lists the platforms the app is available on (PlatformIOS, PlatformAndroid)
+	Platforms []string
+	// SchemePrefix is prepended to the standard payment URI to open this app
+	// specifically, e.g. "bluewallet:" for "bluewallet:bitcoin:<address>?amount=...".
+	// Empty for apps that register the standard bitcoin: or monero: scheme.
+	SchemePrefix string
+}
+
+// DefaultWalletApps is used when Config.WalletApps is nil
+var DefaultWalletApps = []WalletApp{
+	{Name: "Cake Wallet", Currency: wallet.Monero, Platforms: []string{PlatformIOS, PlatformAndroid}},
+	{Name: "Monerujo", Currency: wallet.Monero, Platforms: []string{PlatformAndroid}},
+	{Name: "BlueWallet", Currency: wallet.Bitcoin, Platforms: []string{PlatformIOS, PlatformAndroid}, SchemePrefix: "bluewallet:"},
+	{Name: "Cake Wallet", Currency: wallet.Bitcoin, Platforms: []string{PlatformIOS, PlatformAndroid}},
+}
+
+// WalletLink is a deep link shown on the payment page
+type WalletLink struct {
+	// Name is the wallet app's name
+	Name string
+	// URL opens the app with the address and amount filled in
+	URL template.URL
+}
+
+// mobilePlatform guesses the visitor's mobile platform from the User-Agent.
+// It only drives suggestions; the standard payment link is always shown.
+//
+// Returns:
+//   - string: PlatformIOS, PlatformAndroid, or "" for desktops and unknown clients
+func mobilePlatform(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		return PlatformAndroid
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return PlatformIOS
+	}
+	return ""
+}
+
+// paymentLink returns the standard payment URI as a link target, "" when the
+// currency is not offered
+func paymentLink(currency wallet.WalletType, address string, amount float64, label string) template.URL {
+	if address == "" {
+		return ""
+	}
+	uri, err := paymentURI(currency, address, amount, label)
+	if err != nil {
+		return ""
+	}
+	return template.URL(uri)
+}
+
+// walletLinks returns deep links into the wallet apps available on platform for
+// currency, in configuration order
+func (p *Paywall) walletLinks(platform string, currency wallet.WalletType, address string, amount float64) []WalletLink {
+	uri := paymentLink(currency, address, amount, p.paymentLabel)
+	if platform == "" || uri == "" {
+		return nil
+	}
+	var links []WalletLink
+	for _, app := range p.walletApps {
+		if app.Currency != currency || !slices.Contains(app.Platforms, platform) {
+			continue
+		}
+		links = append(links, WalletLink{Name: app.Name, URL: template.URL(app.SchemePrefix) + uri})
+	}
+	return links
+}
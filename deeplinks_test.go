@@ -0,0 +1,85 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMobilePlatform(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", PlatformIOS},
+		{"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15", PlatformIOS},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/124.0 Mobile Safari/537.36", PlatformAndroid},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := mobilePlatform(tt.userAgent); got != tt.want {
+			t.Errorf("mobilePlatform(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestPaymentPage_WalletDeepLinks(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	uri := "bitcoin:" + payment.Addresses["BTC"] + "?amount=0.001"
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      []string
+		wantNot   []string
+	}{
+		{
+			name:      "desktop",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+			want:      []string{`href="` + uri + `"`},
+			wantNot:   []string{"bluewallet:", "Cake Wallet"},
+		},
+		{
+			name:      "iphone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)",
+			want:      []string{`href="` + uri + `"`, `href="bluewallet:` + uri + `">BlueWallet</a>`, ">Cake Wallet</a>"},
+			wantNot:   []string{"Monerujo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			pw.renderPaymentPageFor(rec, req, payment)
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("payment page does not contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("payment page contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Command full protects a static file server with most of the paywall's
+// features enabled at once: a SQL payment store, a persistent Bitcoin wallet
+// swept with cmd/paywall-sweep, fiat pricing, signed webhooks, a payment page
+// updated over Server-Sent Events, the built-in admin dashboard, and graceful
+// shutdown. It runs against a Bitcoin signet/testnet node and a Monero
+// stagenet wallet, so every payment can be made with test coins.
+//
+// See README.md in this directory for how to start the nodes and the database.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opd-ai/paywall"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// The SQL driver is not imported here, so the paywall module keeps no
+// database dependency. Add the one of your database before running, e.g.
+//
+//	import _ "github.com/jackc/pgx/v5/stdlib"
+
+// options are the command line settings of the example
+type options struct {
+	Addr      string
+	AdminAddr string
+	FilesDir  string
+
+	// Payment store: a database/sql driver name and data source, and the
+	// SQL dialect of the database
+	DBDriver  string
+	DBSource  string
+	DBDialect string
+
+	// Bitcoin wallet storage: the directory and the 32-byte key encrypting
+	// wallet.dat, the same two cmd/paywall-sweep reads
+	WalletDir string
+	WalletKey []byte
+
+	// Bitcoin Core RPC of a signet or testnet node (empty disables
+	// transaction submission from the payment page)
+	BTCRPCHost string
+	BTCRPCUser string
+	BTCRPCPass string
+
+	// monero-wallet-rpc on stagenet (empty disables Monero)
+	XMRRPC      string
+	XMRUser     string
+	XMRPassword string
+
+	// Prices: the content costs PriceUSD, converted at the fixed rates below
+	PriceUSD float64
+	BTCUSD   float64
+	XMRUSD   float64
+
+	AdminUser     string
+	AdminPassword string
+	WebhookSecret string
+}
+
+func main() {
+	var opts options
+	var walletKey string
+	flag.StringVar(&opts.Addr, "addr", ":8080", "public listen address")
+	flag.StringVar(&opts.AdminAddr, "admin-addr", "127.0.0.1:8081", "admin listen address (keep it private)")
+	flag.StringVar(&opts.FilesDir, "files", "./public", "directory of files to sell")
+	flag.StringVar(&opts.DBDriver, "db-driver", "pgx", "database/sql driver name")
+	flag.StringVar(&opts.DBSource, "db", os.Getenv("PAYWALL_DB"), "database connection string (required)")
+	flag.StringVar(&opts.DBDialect, "db-dialect", string(paywall.DialectPostgres), "SQL dialect: postgres, mysql or sqlite")
+	flag.StringVar(&opts.WalletDir, "wallet-dir", "./wallet", "Bitcoin wallet directory")
+	flag.StringVar(&walletKey, "wallet-key", os.Getenv("PAYWALL_WALLET_KEY"), "hex key encrypting the Bitcoin wallet (required)")
+	flag.StringVar(&opts.BTCRPCHost, "btc-rpc", "", "Bitcoin Core RPC host, e.g. localhost:38332 (signet)")
+	flag.StringVar(&opts.BTCRPCUser, "btc-user", os.Getenv("BTC_RPC_USER"), "Bitcoin Core RPC user")
+	flag.StringVar(&opts.BTCRPCPass, "btc-pass", os.Getenv("BTC_RPC_PASS"), "Bitcoin Core RPC password")
+	flag.StringVar(&opts.XMRRPC, "xmr-rpc", "", "monero-wallet-rpc URL, e.g. http://localhost:38083/json_rpc (stagenet)")
+	flag.StringVar(&opts.XMRUser, "xmr-user", os.Getenv("XMR_WALLET_USER"), "monero-wallet-rpc user")
+	flag.StringVar(&opts.XMRPassword, "xmr-pass", os.Getenv("XMR_WALLET_PASS"), "monero-wallet-rpc password")
+	flag.Float64Var(&opts.PriceUSD, "price-usd", 1, "price of the files in USD")
+	flag.Float64Var(&opts.BTCUSD, "btc-usd", 60000, "BTC/USD rate")
+	flag.Float64Var(&opts.XMRUSD, "xmr-usd", 150, "XMR/USD rate")
+	flag.StringVar(&opts.AdminUser, "admin-user", "admin", "admin user")
+	flag.StringVar(&opts.AdminPassword, "admin-pass", os.Getenv("PAYWALL_ADMIN_PASS"), "admin password, at least 12 characters (required)")
+	flag.StringVar(&opts.WebhookSecret, "webhook-secret", os.Getenv("PAYWALL_WEBHOOK_SECRET"), "webhook signing secret")
+	flag.Parse()
+
+	if opts.AdminPassword == "" {
+		log.Fatalf("Set -admin-pass or PAYWALL_ADMIN_PASS")
+	}
+	if opts.DBSource == "" {
+		log.Fatalf("Set -db or PAYWALL_DB")
+	}
+	var err error
+	if opts.WalletKey, err = hex.DecodeString(walletKey); err != nil || len(opts.WalletKey) != 32 {
+		log.Fatalf("Set -wallet-key or PAYWALL_WALLET_KEY to 32 hex-encoded bytes, e.g. openssl rand -hex 32")
+	}
+	if err := os.MkdirAll(opts.FilesDir, 0o755); err != nil {
+		log.Fatalf("Failed to create files directory: %v", err)
+	}
+
+	db, err := sql.Open(opts.DBDriver, opts.DBSource)
+	if err != nil {
+		log.Fatalf("Failed to open database (import the %s driver, see main.go): %v", opts.DBDriver, err)
+	}
+	defer db.Close()
+	// Creates or migrates the tables on start
+	store, err := paywall.NewSQLStore(db, paywall.SQLStoreConfig{
+		Dialect:           paywall.SQLDialect(opts.DBDialect),
+		ValidateAddresses: true,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create payment store: %v", err)
+	}
+
+	pw, public, admin, err := newServer(opts, store)
+	if err != nil {
+		log.Fatalf("Failed to create paywall: %v", err)
+	}
+
+	servers := []*http.Server{
+		// No WriteTimeout: status event streams stay open until the payment
+		// settles
+		{Addr: opts.Addr, Handler: public, ReadHeaderTimeout: 10 * time.Second},
+		{Addr: opts.AdminAddr, Handler: admin, ReadHeaderTimeout: 10 * time.Second},
+	}
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Server on %s failed: %v", srv.Addr, err)
+			}
+		}(srv)
+	}
+	log.Printf("Selling %s on %s, admin dashboard on http://%s/", opts.FilesDir, opts.Addr, opts.AdminAddr)
+	log.Printf("Move received bitcoin with: go run ./cmd/paywall-sweep -wallet-dir %s -testnet -address-type %s -to <address>", opts.WalletDir, wallet.AddressP2WPKH)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	// Close the paywall first: it stops the monitor, saves the wallet and ends
+	// the open status streams, which Shutdown would otherwise wait for until
+	// its deadline. Then stop taking requests.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	pw.Close()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Shutdown of %s: %v", srv.Addr, err)
+		}
+	}
+	log.Println("Stopped")
+}
+
+// Paths of the public listener
+const (
+	statusPath = "/paywall/status"
+	qrCodePath = "/paywall/qr"
+	assetPath  = "/paywall/assets/"
+	btcTxPath  = "/paywall/btc-tx"
+)
+
+// newServer creates the paywall and the handlers of both listeners. It is
+// separate from main so the wiring can be tested without nodes.
+//
+// Parameters:
+//   - opts: Command line settings
+//   - store: Payment store
+//
+// Returns:
+//   - *paywall.Paywall: The paywall, to be closed by the caller
+//   - http.Handler: The public handler serving the files behind the paywall
+//   - http.Handler: The admin handler (dashboard, admin API, webhook receiver)
+//   - error: If the paywall configuration is invalid
+func newServer(opts options, store paywall.PaymentStore) (*paywall.Paywall, http.Handler, http.Handler, error) {
+	oracle := paywall.StaticPriceOracle{wallet.Bitcoin: opts.BTCUSD}
+	config := paywall.Config{
+		// PriceInBTC and PriceInXMR select the currencies; PriceInFiat
+		// replaces them with the converted price
+		PriceInBTC:       opts.PriceUSD / opts.BTCUSD,
+		PriceOracle:      oracle,
+		FiatCurrency:     "USD",
+		PriceInFiat:      opts.PriceUSD,
+		FiatEstimates:    true,
+		TestNet:          true,
+		Store:            store,
+		PaymentTimeout:   2 * time.Hour,
+		MinConfirmations: 1,
+		// Native SegWit addresses; cmd/paywall-sweep needs the same
+		// -address-type p2wpkh
+		AddressType: wallet.AddressP2WPKH,
+		BTCWalletStorage: &wallet.StorageConfig{
+			DataDir:       opts.WalletDir,
+			EncryptionKey: opts.WalletKey,
+		},
+		// The payment page listens to StatusPath with Server-Sent Events and
+		// falls back to polling it
+		StatusPath:    statusPath,
+		QRCodePath:    qrCodePath,
+		AssetPath:     assetPath,
+		StatsInterval: time.Minute,
+		AdminUsers:    map[string]string{opts.AdminUser: opts.AdminPassword},
+	}
+	if opts.XMRRPC != "" {
+		oracle[wallet.Monero] = opts.XMRUSD
+		config.PriceInXMR = opts.PriceUSD / opts.XMRUSD
+		config.XMRRPC = opts.XMRRPC
+		config.XMRUser = opts.XMRUser
+		config.XMRPassword = opts.XMRPassword
+	}
+	if opts.BTCRPCHost != "" {
+		config.BTCRPCHost = opts.BTCRPCHost
+		config.BTCRPCUser = opts.BTCRPCUser
+		config.BTCRPCPass = opts.BTCRPCPass
+		config.BTCDisableTLS = true // local test node
+		config.BTCTxSubmitPath = btcTxPath
+	}
+	if opts.WebhookSecret != "" {
+		config.WebhookConfig = &paywall.WebhookConfig{
+			URL:    "http://" + opts.AdminAddr + "/webhook",
+			Secret: opts.WebhookSecret,
+		}
+	}
+
+	pw, err := paywall.NewPaywall(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	public := http.NewServeMux()
+	public.HandleFunc(statusPath, pw.HandlePaymentStatus)
+	public.HandleFunc(qrCodePath, pw.HandleQRCode)
+	public.HandleFunc(assetPath, pw.HandleAsset)
+	if config.BTCTxSubmitPath != "" {
+		public.HandleFunc(btcTxPath, pw.HandleBitcoinTransaction)
+	}
+	public.Handle("/", pw.Middleware(http.FileServer(http.Dir(opts.FilesDir))))
+
+	admin := http.NewServeMux()
+	// The webhook receiver authenticates by signature, not by password
+	admin.HandleFunc("/webhook", webhookHandler(opts.WebhookSecret))
+	// Dashboard and admin API behind Config.AdminUsers
+	admin.Handle("/", pw.AdminHandler())
+
+	return pw, public, admin, nil
+}
+
+// webhookHandler logs the paywall's webhooks after checking their signature.
+// A real shop would unlock orders or send receipts here.
+func webhookHandler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if secret == "" || !paywall.VerifyWebhookSignature(body, r.Header.Get("X-Webhook-Signature"), secret) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Webhook: %s", body)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const testAdminPassword = "correct horse battery"
+
+func newTestServer(t *testing.T) (*paywall.Paywall, http.Handler, http.Handler) {
+	t.Helper()
+	files := t.TempDir()
+	if err := os.WriteFile(filepath.Join(files, "report.txt"), []byte("the paid content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pw, public, admin, err := newServer(options{
+		AdminAddr:     "127.0.0.1:8081",
+		FilesDir:      files,
+		WalletDir:     t.TempDir(),
+		WalletKey:     make([]byte, 32),
+		PriceUSD:      1,
+		BTCUSD:        60000,
+		XMRUSD:        150,
+		AdminUser:     "admin",
+		AdminPassword: testAdminPassword,
+		WebhookSecret: "whsec",
+	}, paywall.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+	return pw, public, admin
+}
+
+func TestNewServer_Public(t *testing.T) {
+	_, public, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	public.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report.txt", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "the paid content") {
+		t.Fatalf("file served without payment")
+	}
+	if !strings.Contains(body, "USD") {
+		t.Errorf("payment page does not show the fiat estimate")
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("no payment cookie set")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, statusPath, nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	public.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status endpoint = %d, want 200", rec.Code)
+	}
+
+	// The payment page's EventSource gets a stream of status events
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req = httptest.NewRequest(http.MethodGet, statusPath, nil).WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	public.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" || !strings.Contains(rec.Body.String(), "event: status") {
+		t.Errorf("status stream = %q: %q, want a status event", ct, rec.Body.String())
+	}
+}
+
+func TestNewServer_Admin(t *testing.T) {
+	pw, _, admin := newTestServer(t)
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("dashboard without credentials = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("admin", testAdminPassword)
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), payment.ID) {
+		t.Errorf("dashboard = %d, want 200 listing payment %s", rec.Code, payment.ID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/payments/"+payment.ID, nil)
+	req.SetBasicAuth("admin", testAdminPassword)
+	rec = httptest.NewRecorder()
+	admin.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin API = %d, want 200", rec.Code)
+	}
+}
+
+func TestNewServer_AddressType(t *testing.T) {
+	pw, _, _ := newTestServer(t)
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	// cmd/paywall-sweep derives the same native SegWit addresses
+	if address := payment.Addresses[wallet.Bitcoin]; !strings.HasPrefix(address, "tb1q") {
+		t.Errorf("payment address = %s, want a testnet P2WPKH address", address)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	_, _, admin := newTestServer(t)
+	body := `{"event":"payment.confirmed","payment_id":"abc"}`
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write([]byte(body))
+
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"valid signature", hex.EncodeToString(mac.Sum(nil)), http.StatusNoContent},
+		{"invalid signature", "00", http.StatusUnauthorized},
+		{"missing signature", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+			req.Header.Set("X-Webhook-Signature", tt.signature)
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("webhook = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
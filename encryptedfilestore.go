@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -22,17 +23,40 @@ type EncryptedFileStore struct {
 	gcm        cipher.AEAD
 }
 
-// NewEncryptedFileStore creates a new encrypted filesystem-based payment store
+// NewEncryptedFileStore creates a new encrypted filesystem-based payment store.
+// The key directory is created 0700 and the key file 0600; existing ones that
+// are accessible by group or others are logged as warnings.
 func NewEncryptedFileStore(keyPath, base string) (*EncryptedFileStore, error) {
+	return newEncryptedFileStore(keyPath, FileStoreConfig{DataDir: base})
+}
+
+// newEncryptedFileStore creates an EncryptedFileStore whose payment files and
+// directories use the modes in config
+func newEncryptedFileStore(keyPath string, config FileStoreConfig) (*EncryptedFileStore, error) {
 	if keyPath == "" {
 		keyPath = "./keys/store.key"
 	}
 
-	// Ensure key directory exists
+	// Ensure key directory exists; it is private unless it is the payments
+	// directory itself
 	keyDir := filepath.Dir(keyPath)
-	if err := os.MkdirAll(keyDir, 0o700); err != nil {
+	keyDirMode := wallet.DefaultDirMode
+	if filepath.Clean(keyDir) == filepath.Clean(config.DataDir) && config.DirMode != 0 {
+		keyDirMode = config.DirMode
+	}
+	if err := wallet.MkdirMode(keyDir, keyDirMode); err != nil {
 		return nil, fmt.Errorf("create key directory: %w", err)
 	}
+	// The key itself stays private even when the store is shared with a group
+	for path, mode := range map[string]os.FileMode{keyDir: keyDirMode, keyPath: wallet.DefaultFileMode} {
+		problem, err := wallet.CheckPermissions(path, mode, config.RepairPermissions)
+		if problem != nil {
+			log.Printf("Warning: %s", problem)
+		}
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 
 	// Load or generate key
 	key, err := loadOrGenerateKey(keyPath)
@@ -53,7 +77,7 @@ func NewEncryptedFileStore(keyPath, base string) (*EncryptedFileStore, error) {
 	}
 
 	return &EncryptedFileStore{
-		FileStore: NewFileStore(base), // use existing FileStore implementation
+		FileStore: newFileStore(config), // use existing FileStore implementation
 		keyPath:   keyPath,
 		key:       key,
 		gcm:       gcm,
@@ -74,7 +98,7 @@ func loadOrGenerateKey(keyPath string) ([]byte, error) {
 	}
 
 	// Save key
-	if err := os.WriteFile(keyPath, key, 0o600); err != nil {
+	if err := wallet.WriteFileMode(keyPath, key, wallet.DefaultFileMode); err != nil {
 		return nil, fmt.Errorf("save key: %w", err)
 	}
 
@@ -101,6 +125,9 @@ func (m *EncryptedFileStore) decrypt(data []byte) ([]byte, error) {
 // writeEncryptedPayment is a helper that marshals, encrypts, and writes a payment to disk.
 // Must be called with the mutex held.
 func (m *EncryptedFileStore) writeEncryptedPayment(p *Payment) error {
+	if err := validateObjectID(p.ID); err != nil {
+		return err
+	}
 	data, err := json.Marshal(p)
 	if err != nil {
 		return fmt.Errorf("marshal payment: %w", err)
@@ -112,21 +139,27 @@ func (m *EncryptedFileStore) writeEncryptedPayment(p *Payment) error {
 	}
 
 	filename := filepath.Join(m.baseDir, p.ID+".enc")
-	return os.WriteFile(filename, encrypted, 0o600)
+	return writeFileAtomic(filename, encrypted, m.fileMode)
 }
 
 // CreatePayment stores an encrypted payment record
 func (m *EncryptedFileStore) CreatePayment(p *Payment) error {
+	if err := m.checkAddresses(p); err != nil {
+		return err
+	}
 	// Use the embedded FileStore's mutex
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.lock()
+	defer m.unlock()
 	return m.writeEncryptedPayment(p)
 }
 
 // GetPayment retrieves and decrypts a payment record
 func (m *EncryptedFileStore) GetPayment(id string) (*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	if validateObjectID(id) != nil {
+		return nil, nil
+	}
+	m.rlock()
+	defer m.runlock()
 
 	filename := filepath.Join(m.baseDir, id+".enc")
 	encrypted, err := os.ReadFile(filename)
@@ -157,8 +190,11 @@ func (m *EncryptedFileStore) GetPayment(id string) (*Payment, error) {
 
 // UpdatePayment updates an encrypted payment record with optimistic locking
 func (m *EncryptedFileStore) UpdatePayment(p *Payment) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if err := m.checkAddresses(p); err != nil {
+		return err
+	}
+	m.lock()
+	defer m.unlock()
 
 	// Read existing payment within the write lock to prevent race conditions
 	filename := filepath.Join(m.baseDir, p.ID+".enc")
@@ -214,8 +250,8 @@ func (m *EncryptedFileStore) readAndDecryptPayment(filename string) (*Payment, e
 
 // ListPendingPayments returns all encrypted payment records with less than 1 confirmation
 func (m *EncryptedFileStore) ListPendingPayments() ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -237,10 +273,61 @@ func (m *EncryptedFileStore) ListPendingPayments() ([]*Payment, error) {
 	return payments, nil
 }
 
+// ListPayments returns all encrypted payment records, skipping unreadable files
+func (m *EncryptedFileStore) ListPayments() ([]*Payment, error) {
+	m.rlock()
+	defer m.runlock()
+
+	files, err := os.ReadDir(m.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []*Payment
+	for _, file := range files {
+		payment, err := m.readAndDecryptPayment(file.Name())
+		if err != nil || payment == nil {
+			continue
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
+
+// StreamPayments calls fn for every encrypted payment matching filter, reading
+// the directory in batches. Implements PaymentStreamer; see FileStore.StreamPayments.
+func (m *EncryptedFileStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	return m.streamPaymentFiles(m.readAndDecryptPayment, filter, fn)
+}
+
+// DeletePayment removes an encrypted payment file; see FileStore.DeletePayment
+func (m *EncryptedFileStore) DeletePayment(id string) error {
+	return m.deletePaymentFile(id, ".enc")
+}
+
+// ArchivePayments moves the encrypted files of the payments ArchivablePayment
+// selects for olderThan to the "archive" subdirectory; see
+// FileStore.ArchivePayments.
+func (m *EncryptedFileStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return m.archiveFiles(m.readAndDecryptPayment, ".enc", func(p *Payment) bool {
+		return ArchivablePayment(p, olderThan)
+	})
+}
+
+// PruneExpiredPayments moves the encrypted files of payments in StatusExpired
+// whose ExpiresAt is before t to the "archive" subdirectory. Implements
+// ExpiredPaymentPruner; see FileStore.PruneExpiredPayments.
+func (m *EncryptedFileStore) PruneExpiredPayments(t time.Time) (int, error) {
+	return m.archiveFiles(m.readAndDecryptPayment, ".enc", func(p *Payment) bool {
+		return p.Status == StatusExpired && p.ExpiresAt.Before(t)
+	})
+}
+
 // GetPaymentByAddress retrieves an encrypted payment record by Bitcoin address
 func (m *EncryptedFileStore) GetPaymentByAddress(addr string) (*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -272,8 +359,8 @@ func (m *EncryptedFileStore) GetPaymentByAddress(addr string) (*Payment, error)
 //   - []*Payment: Slice of pending multisig payments
 //   - error: Directory read or decryption errors
 func (m *EncryptedFileStore) GetPendingMultisigPayments() ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -310,8 +397,8 @@ func (m *EncryptedFileStore) GetPendingMultisigPayments() ([]*Payment, error) {
 //   - This matches existing read-path behavior to avoid blocking timeout checks on a single corrupt file
 //   - Thread-safety: Protected by read lock
 func (m *EncryptedFileStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -339,3 +426,79 @@ func (m *EncryptedFileStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Pa
 
 	return expiring, nil
 }
+
+// SaveAPIKey creates or replaces an encrypted API key record
+func (m *EncryptedFileStore) SaveAPIKey(key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal API key: %w", err)
+	}
+	encrypted, err := m.encrypt(data)
+	if err != nil {
+		return fmt.Errorf("encrypt API key: %w", err)
+	}
+
+	m.lock()
+	defer m.unlock()
+	dir := filepath.Join(m.baseDir, apiKeyDir)
+	if err := wallet.MkdirMode(dir, m.dirMode); err != nil {
+		return fmt.Errorf("create API key directory: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, key.ID+".enc"), encrypted, m.fileMode)
+}
+
+// GetAPIKey retrieves and decrypts an API key record, nil if not found
+func (m *EncryptedFileStore) GetAPIKey(id string) (*APIKey, error) {
+	m.rlock()
+	defer m.runlock()
+	return m.readAndDecryptAPIKey(filepath.Join(m.baseDir, apiKeyDir, filepath.Base(id)+".enc"))
+}
+
+// ListAPIKeys returns all encrypted API key records, skipping unreadable files
+func (m *EncryptedFileStore) ListAPIKeys() ([]*APIKey, error) {
+	m.rlock()
+	defer m.runlock()
+
+	dir := filepath.Join(m.baseDir, apiKeyDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []*APIKey
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".enc" {
+			continue
+		}
+		key, err := m.readAndDecryptAPIKey(filepath.Join(dir, file.Name()))
+		if err != nil || key == nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readAndDecryptAPIKey reads and decrypts an API key file, returning nil, nil if it does not exist.
+// Must be called with the mutex held.
+func (m *EncryptedFileStore) readAndDecryptAPIKey(path string) (*APIKey, error) {
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := m.decrypt(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt API key: %w", err)
+	}
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
@@ -56,7 +56,7 @@ func NewEscrowManager(pw *Paywall) (*EscrowManager, error) {
 	}
 	return &EscrowManager{
 		paywall:        pw,
-		auditLogger:    NewMemoryAuditLogger(),
+		auditLogger:    pw.auditLogger(NewMemoryAuditLogger()),
 		stateValidator: NewEscrowStateValidator(),
 	}, nil
 }
@@ -76,7 +76,7 @@ func NewEscrowManagerWithAudit(pw *Paywall, logger AuditLogger) (*EscrowManager,
 	}
 	return &EscrowManager{
 		paywall:        pw,
-		auditLogger:    logger,
+		auditLogger:    pw.auditLogger(logger),
 		stateValidator: NewEscrowStateValidator(),
 		arbiter:        nil, // No arbiter by default
 	}, nil
@@ -96,7 +96,7 @@ func NewEscrowManagerWithArbiter(pw *Paywall, logger AuditLogger, arbiter Arbite
 	}
 	return &EscrowManager{
 		paywall:        pw,
-		auditLogger:    logger,
+		auditLogger:    pw.auditLogger(logger),
 		stateValidator: NewEscrowStateValidator(),
 		arbiter:        arbiter,
 	}, nil
@@ -329,6 +329,12 @@ func (em *EscrowManager) CreateEscrow(priceMultiplier float64, escrowTimeout tim
 // FundEscrow marks an escrow as funded after the buyer sends funds
 // This should be called after payment verification confirms the multisig address has received funds
 func (em *EscrowManager) FundEscrow(paymentID string) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -397,19 +403,17 @@ func (em *EscrowManager) FundEscrow(paymentID string) error {
 		em.paywall.logger.LogEscrowFunded(paymentID, payment.TransactionID, amount, currency)
 	}
 
-	// Dispatch webhook for escrow funded event
-	if em.paywall.webhookDispatcher != nil {
-		em.paywall.webhookDispatcher.Dispatch(WebhookPayload{
-			Event:     EventEscrowFunded,
-			PaymentID: paymentID,
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"transaction_id": payment.TransactionID,
-				"amounts":        payment.Amounts,
-				"escrow_timeout": payment.EscrowTimeout,
-			},
-		})
-	}
+	// Publish escrow funded event
+	em.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventEscrowFunded,
+		PaymentID: paymentID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"transaction_id": payment.TransactionID,
+			"amounts":        payment.Amounts,
+			"escrow_timeout": payment.EscrowTimeout,
+		},
+	})
 
 	return nil
 }
@@ -418,6 +422,12 @@ func (em *EscrowManager) FundEscrow(paymentID string) error {
 // Requires signatures from buyer and seller (2-of-3)
 // This is the normal completion path when both parties agree
 func (em *EscrowManager) ReleaseToSeller(paymentID string, buyerSig, sellerSig *SignatureData) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -501,18 +511,16 @@ func (em *EscrowManager) ReleaseToSeller(paymentID string, buyerSig, sellerSig *
 		em.paywall.logger.LogEscrowCompleted(paymentID, RoleSeller)
 	}
 
-	// Dispatch webhook for escrow completed event
-	if em.paywall.webhookDispatcher != nil {
-		em.paywall.webhookDispatcher.Dispatch(WebhookPayload{
-			Event:     EventEscrowCompleted,
-			PaymentID: paymentID,
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"released_to": "seller",
-				"amounts":     payment.Amounts,
-			},
-		})
-	}
+	// Publish escrow completed event
+	em.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventEscrowCompleted,
+		PaymentID: paymentID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"released_to": "seller",
+			"amounts":     payment.Amounts,
+		},
+	})
 
 	return nil
 }
@@ -521,6 +529,12 @@ func (em *EscrowManager) ReleaseToSeller(paymentID string, buyerSig, sellerSig *
 // Either buyer or seller can request a dispute
 // Once disputed, resolution requires arbiter involvement
 func (em *EscrowManager) RequestDispute(paymentID string, requesterRole MultisigRole, reason string) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -681,6 +695,12 @@ func (em *EscrowManager) RequestDispute(paymentID string, requesterRole Multisig
 // Requires signatures from the arbiter and the winning party
 // The arbiterSig must be from an arbiter, winnerSig from buyer or seller
 func (em *EscrowManager) ResolveDispute(paymentID string, arbiterSig, winnerSig *SignatureData) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -795,24 +815,22 @@ func (em *EscrowManager) ResolveDispute(paymentID string, arbiterSig, winnerSig
 		em.paywall.logger.LogDisputeResolved(paymentID, winnerSig.Role, em.paywall.consensusManager != nil, resolutionTimeMs)
 	}
 
-	// Dispatch webhook for dispute resolved event
-	if em.paywall.webhookDispatcher != nil {
-		winner := "buyer"
-		if winnerSig.Role == RoleSeller {
-			winner = "seller"
-		}
-		em.paywall.webhookDispatcher.Dispatch(WebhookPayload{
-			Event:     EventDisputeResolved,
-			PaymentID: paymentID,
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"winner":        winner,
-				"arbiter_id":    string(arbiterSig.PublicKey),
-				"resolution_ms": time.Since(payment.DisputeFiledAt).Milliseconds(),
-				"final_state":   newState.String(),
-			},
-		})
-	}
+	// Publish dispute resolved event
+	winner := "buyer"
+	if winnerSig.Role == RoleSeller {
+		winner = "seller"
+	}
+	em.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventDisputeResolved,
+		PaymentID: paymentID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"winner":        winner,
+			"arbiter_id":    string(arbiterSig.PublicKey),
+			"resolution_ms": time.Since(payment.DisputeFiledAt).Milliseconds(),
+			"final_state":   newState.String(),
+		},
+	})
 
 	return nil
 }
@@ -821,6 +839,12 @@ func (em *EscrowManager) ResolveDispute(paymentID string, arbiterSig, winnerSig
 // Used for timeout scenarios or mutual agreement to cancel
 // Requires signatures from buyer and seller OR buyer and arbiter
 func (em *EscrowManager) RefundBuyer(paymentID string, sig1, sig2 *SignatureData) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -935,18 +959,16 @@ func (em *EscrowManager) RefundBuyer(paymentID string, sig1, sig2 *SignatureData
 		em.paywall.logger.LogEscrowRefunded(paymentID, RoleBuyer)
 	}
 
-	// Dispatch webhook for escrow refunded event
-	if em.paywall.webhookDispatcher != nil {
-		em.paywall.webhookDispatcher.Dispatch(WebhookPayload{
-			Event:     EventEscrowRefunded,
-			PaymentID: paymentID,
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"refunded_to": "buyer",
-				"amounts":     payment.Amounts,
-			},
-		})
-	}
+	// Publish escrow refunded event
+	em.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventEscrowRefunded,
+		PaymentID: paymentID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"refunded_to": "buyer",
+			"amounts":     payment.Amounts,
+		},
+	})
 
 	return nil
 }
@@ -1003,6 +1025,12 @@ func (em *EscrowManager) CheckEscrowTimeouts() ([]string, error) {
 // Requires signatures from 2 of the 3 participants (buyer, seller, arbiter)
 // The extension must not exceed the 7-day roadmap cap enforced by maxExtension.
 func (em *EscrowManager) ExtendTimeout(paymentID string, extension time.Duration, sig1, sig2 *SignatureData) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.validateExtensionPayment(paymentID)
 	if err != nil {
 		return err
@@ -1378,6 +1406,12 @@ func (em *EscrowManager) CastArbiterVote(paymentID string, vote *ArbiterVote) er
 
 // resolveDisputeByConsensus resolves a dispute based on multi-arbiter consensus
 func (em *EscrowManager) resolveDisputeByConsensus(paymentID string, consensus *ArbiterConsensus) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -1618,6 +1652,12 @@ func (em *EscrowManager) validateDisputeFeePayment(paymentID string, requesterRo
 // Security note: This method trusts the caller to have verified the payment.
 // In production, implement automated blockchain verification before calling this.
 func (em *EscrowManager) RecordDisputeFeePayment(paymentID string, requesterRole MultisigRole) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
@@ -1659,6 +1699,12 @@ func (em *EscrowManager) RecordDisputeFeePayment(paymentID string, requesterRole
 
 // SubmitDisputeEvidence submits evidence for a dispute with size validation
 func (em *EscrowManager) SubmitDisputeEvidence(paymentID string, evidence *Evidence) error {
+	unlock, err := em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	payment, err := em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
 		return fmt.Errorf("failed to get payment: %w", err)
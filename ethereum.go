@@ -0,0 +1,128 @@
+package paywall
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+
+	"github.com/opd-ai/paywall/wallet"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ERC20Price offers an ERC-20 token for payment, see Config.ERC20
+type ERC20Price struct {
+	// Token is the token contract, e.g. wallet.USDCMainnet
+	Token wallet.ERC20Token
+	// Price is the amount in whole tokens required for access, e.g. 5 for 5 USDC
+	Price float64
+}
+
+// validateEthereumConfig checks Config.Ethereum, EthereumSeed, PriceInETH and ERC20
+func validateEthereumConfig(config Config) error {
+	if config.PriceInETH < 0 {
+		return fmt.Errorf("PriceInETH must be positive, got: %.18g ETH (hint: set PriceInETH: 0.001 or leave at 0 to accept tokens only)", config.PriceInETH)
+	}
+	if config.Ethereum == nil {
+		if config.PriceInETH > 0 || len(config.ERC20) > 0 {
+			return fmt.Errorf("PriceInETH or ERC20 set but Ethereum is nil (hint: set Ethereum to your JSON-RPC endpoint, e.g. &wallet.ETHRPCConfig{URL: \"http://127.0.0.1:8545\"})")
+		}
+		return nil
+	}
+
+	if u, err := url.Parse(config.Ethereum.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Ethereum.URL %q must be an absolute http or https URL (hint: http://127.0.0.1:8545)", config.Ethereum.URL)
+	}
+	if len(config.EthereumSeed) < 16 || len(config.EthereumSeed) > 64 {
+		return fmt.Errorf("EthereumSeed must be between 16 and 64 bytes, got %d (hint: generate 32 random bytes once and keep them secret; they control the received funds)", len(config.EthereumSeed))
+	}
+	if config.PriceInETH == 0 && len(config.ERC20) == 0 {
+		return fmt.Errorf("Ethereum set but PriceInETH is zero and ERC20 is empty (hint: set PriceInETH: 0.001 or ERC20: []paywall.ERC20Price{{Token: wallet.USDCMainnet, Price: 5}})")
+	}
+
+	seen := map[wallet.WalletType]bool{wallet.Bitcoin: true, wallet.Monero: true, wallet.Lightning: true, wallet.Ethereum: true}
+	for i, token := range config.ERC20 {
+		if err := token.Token.Validate(); err != nil {
+			return fmt.Errorf("ERC20[%d]: %w", i, err)
+		}
+		if seen[token.Token.Symbol] {
+			return fmt.Errorf("ERC20[%d]: symbol %s is already in use (hint: list each token once)", i, token.Token.Symbol)
+		}
+		seen[token.Token.Symbol] = true
+		if token.Price <= 0 {
+			return fmt.Errorf("ERC20[%d]: %s price must be positive, got: %g", i, token.Token.Symbol, token.Price)
+		}
+	}
+	return nil
+}
+
+// initializeEthereumWallets creates the Ether wallet (BIP44 account 0) and
+// one wallet per ERC-20 token (accounts 1, 2, ... in Config.ERC20 order), all
+// derived from Config.EthereumSeed. Derivation continues after the addresses
+// of stored payments, so a restart does not hand out an address again.
+//
+// Returns:
+//   - map[wallet.WalletType]wallet.HDWallet: The wallets, empty without Config.Ethereum
+//   - map[wallet.WalletType]float64: Their prices
+//   - error: If a wallet cannot be created
+func initializeEthereumWallets(config Config) (map[wallet.WalletType]wallet.HDWallet, map[wallet.WalletType]float64, error) {
+	hdWallets := make(map[wallet.WalletType]wallet.HDWallet)
+	prices := make(map[wallet.WalletType]float64)
+	if config.Ethereum == nil {
+		return hdWallets, prices, nil
+	}
+
+	if config.PriceInETH > 0 {
+		ethWallet, err := wallet.NewETHHDWallet(config.EthereumSeed, 0, nil, config.Ethereum, config.MinConfirmations)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create Ethereum wallet: %w", err)
+		}
+		hdWallets[wallet.Ethereum] = ethWallet
+		prices[wallet.Ethereum] = config.PriceInETH
+	}
+	for i, token := range config.ERC20 {
+		tokenWallet, err := wallet.NewETHHDWallet(config.EthereumSeed, uint32(i+1), &token.Token, config.Ethereum, config.MinConfirmations)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create %s wallet: %w", token.Token.Symbol, err)
+		}
+		hdWallets[token.Token.Symbol] = tokenWallet
+		prices[token.Token.Symbol] = token.Price
+	}
+
+	restoreAddressIndexes(config, "Ethereum", hdWallets)
+	return hdWallets, prices, nil
+}
+
+// tokenPaymentOptions lists the ERC-20 tokens a payment accepts, by symbol.
+// Token transfers have no widely supported payment URI, so the QR code holds
+// the bare address and the payer picks the token and amount in their wallet.
+func tokenPaymentOptions(payment *Payment) []PaymentOption {
+	var tokens []PaymentOption
+	for walletType, address := range payment.Addresses {
+		switch walletType {
+		case wallet.Bitcoin, wallet.Monero, wallet.Lightning, wallet.Ethereum:
+			continue
+		}
+		if !wallet.IsEthereumAddress(address) {
+			continue
+		}
+		tokens = append(tokens, PaymentOption{
+			Currency: walletType,
+			Address:  address,
+			Amount:   payment.Amounts[walletType],
+			QRCode:   addressQRCodeDataURI(address),
+		})
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Currency < tokens[j].Currency })
+	return tokens
+}
+
+// addressQRCodeDataURI renders a bare address as an inline QR code image
+func addressQRCodeDataURI(address string) template.URL {
+	png, err := qrcode.Encode(address, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+}
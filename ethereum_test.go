@@ -0,0 +1,163 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeEthereumNode serves the JSON-RPC calls of wallet.ETHHDWallet
+type fakeEthereumNode struct {
+	mu       sync.Mutex
+	balances map[string]string // lower-case address -> wei or token units
+}
+
+func (f *fakeEthereumNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	result := "0x0"
+	switch req.Method {
+	case "eth_blockNumber":
+		result = "0x100"
+	case "eth_getBalance":
+		var address string
+		json.Unmarshal(req.Params[0], &address)
+		if balance, ok := f.balances[strings.ToLower(address)]; ok {
+			result = balance
+		}
+	case "eth_call":
+		var call struct{ Data string }
+		json.Unmarshal(req.Params[0], &call)
+		if balance, ok := f.balances["0x"+call.Data[len(call.Data)-40:]]; ok {
+			result = balance
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func (f *fakeEthereumNode) setBalance(address, balance string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[strings.ToLower(address)] = balance
+}
+
+// ethereumTestConfig enables ETH and USDC payments checked at the node url
+func ethereumTestConfig(url string, store PaymentStore) Config {
+	return Config{
+		Store:        store,
+		Logger:       NewStructuredLogger(io.Discard, LogLevelError, true),
+		Ethereum:     &wallet.ETHRPCConfig{URL: url},
+		EthereumSeed: bytes.Repeat([]byte{7}, 32),
+		PriceInETH:   0.002,
+		ERC20:        []ERC20Price{{Token: wallet.USDCMainnet, Price: 5}},
+	}
+}
+
+func TestEthereum_TokenPaymentConfirms(t *testing.T) {
+	node := &fakeEthereumNode{balances: map[string]string{}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	pw := newTestPaywall(t, ethereumTestConfig(server.URL, NewMemoryStore()))
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	ethAddress, usdcAddress := payment.Addresses[wallet.Ethereum], payment.Addresses[wallet.USDC]
+	if !wallet.IsEthereumAddress(ethAddress) || !wallet.IsEthereumAddress(usdcAddress) || ethAddress == usdcAddress {
+		t.Fatalf("addresses = %v, want distinct ETH and USDC addresses", payment.Addresses)
+	}
+	if payment.Amounts[wallet.Ethereum] != 0.002 || payment.Amounts[wallet.USDC] != 5 {
+		t.Errorf("amounts = %v, want 0.002 ETH and 5 USDC", payment.Amounts)
+	}
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/", nil), payment)
+	for _, want := range []string{ethAddress, "ethereum:" + ethAddress + "?value=0.002e18", usdcAddress, "USDC (ERC-20)"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("payment page missing %q", want)
+		}
+	}
+
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin:  &mockCryptoClient{},
+		wallet.Ethereum: pw.HDWallets[wallet.Ethereum],
+		wallet.USDC:     pw.HDWallets[wallet.USDC],
+	}}
+	node.setBalance(usdcAddress, "0x4c4b3f") // 4.999999 USDC
+	monitor.checkPayment(payment)
+	if payment.Status != StatusPending {
+		t.Fatalf("status after underpayment = %s, want pending", payment.Status)
+	}
+	node.setBalance(usdcAddress, "0x4c4b40") // 5 USDC
+	monitor.checkPayment(payment)
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.USDC {
+		t.Errorf("after payment status = %s paid in %q, want confirmed in USDC", stored.Status, stored.PaidCurrency)
+	}
+}
+
+func TestEthereum_RestartSkipsUsedAddresses(t *testing.T) {
+	server := httptest.NewServer(&fakeEthereumNode{balances: map[string]string{}})
+	defer server.Close()
+	store := NewMemoryStore()
+
+	first, err := newTestPaywall(t, ethereumTestConfig(server.URL, store)).CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	second, err := newTestPaywall(t, ethereumTestConfig(server.URL, store)).CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() after restart error = %v", err)
+	}
+	for _, currency := range []wallet.WalletType{wallet.Ethereum, wallet.USDC} {
+		if first.Addresses[currency] == second.Addresses[currency] {
+			t.Errorf("%s address %s handed out again after a restart", currency, first.Addresses[currency])
+		}
+	}
+}
+
+func TestEthereum_Config(t *testing.T) {
+	rpc := &wallet.ETHRPCConfig{URL: "http://127.0.0.1:8545"}
+	seed := bytes.Repeat([]byte{7}, 32)
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"price without endpoint", Config{PriceInBTC: 0.001, PriceInETH: 0.01}, "Ethereum is nil"},
+		{"negative price", Config{PriceInBTC: 0.001, PriceInETH: -1, Ethereum: rpc, EthereumSeed: seed}, "must be positive"},
+		{"no seed", Config{PriceInETH: 0.01, Ethereum: rpc}, "EthereumSeed"},
+		{"relative url", Config{PriceInETH: 0.01, Ethereum: &wallet.ETHRPCConfig{URL: "/rpc"}, EthereumSeed: seed}, "absolute"},
+		{"no price", Config{PriceInBTC: 0.001, Ethereum: rpc, EthereumSeed: seed}, "ERC20 is empty"},
+		{"duplicate token", Config{Ethereum: rpc, EthereumSeed: seed, ERC20: []ERC20Price{{wallet.USDCMainnet, 5}, {wallet.USDCMainnet, 6}}}, "already in use"},
+		{"token named like a coin", Config{Ethereum: rpc, EthereumSeed: seed, ERC20: []ERC20Price{{wallet.ERC20Token{Symbol: wallet.Bitcoin, Contract: wallet.USDCMainnet.Contract, Decimals: 8}, 1}}}, "already in use"},
+		{"bad contract", Config{Ethereum: rpc, EthereumSeed: seed, ERC20: []ERC20Price{{wallet.ERC20Token{Symbol: "EURC", Contract: "0x1234"}, 5}}}, "not an Ethereum address"},
+		{"zero token price", Config{Ethereum: rpc, EthereumSeed: seed, ERC20: []ERC20Price{{wallet.DAIMainnet, 0}}}, "price must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.PaymentTimeout = time.Hour
+			tt.config.TestNet = true
+			tt.config.Store = NewMemoryStore()
+			_, err := NewPaywall(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPaywall() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
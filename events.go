@@ -0,0 +1,275 @@
+package paywall
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// EventSchemaVersion is the PaymentEvent schema version. It is only raised
+	// for incompatible changes; new fields and Data keys are added without a bump.
+	EventSchemaVersion = 1
+	// DefaultEventSubjectPrefix is prepended to the event type to form the subject
+	DefaultEventSubjectPrefix = "paywall."
+	// defaultEventQueueSize bounds events waiting to be published
+	defaultEventQueueSize = 1000
+)
+
+// PaymentEvent is the JSON payload published through Config.EventSink for
+// every payment and escrow event webhooks receive
+type PaymentEvent struct {
+	// SchemaVersion is EventSchemaVersion at the time the event was published
+	SchemaVersion int `json:"schema_version"`
+	// ID uniquely identifies the event; redeliveries carry the same ID
+	ID string `json:"id"`
+	// Event identifies the type of event
+	Event WebhookEventType `json:"event"`
+	// PaymentID is the ID of the affected payment
+	PaymentID string `json:"payment_id"`
+	// Timestamp is when the event occurred
+	Timestamp time.Time `json:"timestamp"`
+	// Data contains event-specific data, the same as the webhook payload's
+	Data map[string]interface{} `json:"data"`
+}
+
+// EventMessage is a PaymentEvent ready for a message broker
+type EventMessage struct {
+	// Subject is the NATS subject or Kafka topic, e.g. "paywall.payment_confirmed"
+	Subject string
+	// ID is the event ID, for broker-side deduplication (NATS Nats-Msg-Id)
+	ID string
+	// Key is the payment ID, e.g. the Kafka message key keeping a payment's
+	// events in one partition and in order
+	Key string
+	// Data is the JSON-encoded PaymentEvent
+	Data []byte
+}
+
+// EventPublisher delivers event messages to a message broker. NATSPublisher
+// implements it for NATS; implement it on your Kafka (or other) client to
+// publish there:
+//
+//	type kafkaPublisher struct{ w *kafka.Writer }
+//
+//	func (k kafkaPublisher) Publish(ctx context.Context, msg paywall.EventMessage) error {
+//	    return k.w.WriteMessages(ctx, kafka.Message{Topic: msg.Subject, Key: []byte(msg.Key), Value: msg.Data})
+//	}
+type EventPublisher interface {
+	// Publish sends msg and returns once the broker accepted it.
+	// Returning an error makes the paywall retry the message.
+	Publish(ctx context.Context, msg EventMessage) error
+}
+
+// EventSinkConfig configures publishing of payment events to a message broker
+type EventSinkConfig struct {
+	// Publisher sends the events, e.g. a NATSPublisher
+	Publisher EventPublisher
+	// SubjectPrefix is prepended to the event type to form the subject.
+	// Optional: defaults to DefaultEventSubjectPrefix ("paywall.").
+	SubjectPrefix string
+	// EnabledEvents selects the published events.
+	// Optional: if empty, all events are published.
+	EnabledEvents []WebhookEventType
+	// MaxRetries is how often a failed publish is retried. Optional: defaults to 3.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries, doubling on each retry.
+	// Optional: defaults to 1 second.
+	RetryBackoff time.Duration
+	// Timeout bounds a single Publish call. Optional: defaults to 10 seconds.
+	Timeout time.Duration
+	// QueueSize bounds events waiting to be published; events are dropped and
+	// logged when the queue is full. Optional: defaults to 1000.
+	QueueSize int
+}
+
+// eventSink publishes events in the background, in the order they occurred
+type eventSink struct {
+	config EventSinkConfig
+	// send delivers one event: to config.Publisher, or to a Notifier
+	send    func(ctx context.Context, event PaymentEvent) error
+	logger  *StructuredLogger
+	enabled map[WebhookEventType]bool
+	// random is the source of event IDs, nil for crypto/rand
+	random io.Reader
+	queue  chan PaymentEvent
+	// stop interrupts retry backoffs when the sink is closed
+	stop   chan struct{}
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newEventSink applies defaults and starts the publishing goroutine
+func newEventSink(config EventSinkConfig, logger *StructuredLogger, random io.Reader) *eventSink {
+	s := &eventSink{}
+	return s.start(config, s.publishMessage, logger, random)
+}
+
+// start applies defaults and starts the goroutine delivering events with send
+func (s *eventSink) start(config EventSinkConfig, send func(context.Context, PaymentEvent) error, logger *StructuredLogger, random io.Reader) *eventSink {
+	if config.SubjectPrefix == "" {
+		config.SubjectPrefix = DefaultEventSubjectPrefix
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultEventQueueSize
+	}
+	s.config = config
+	s.send = send
+	s.logger = logger
+	s.random = random
+	s.queue = make(chan PaymentEvent, config.QueueSize)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	if len(config.EnabledEvents) > 0 {
+		s.enabled = make(map[WebhookEventType]bool)
+		for _, event := range config.EnabledEvents {
+			s.enabled[event] = true
+		}
+	}
+	go s.run()
+	return s
+}
+
+// dispatch queues payload for publishing unless its event is disabled
+func (s *eventSink) dispatch(payload WebhookPayload) {
+	if s.enabled != nil && !s.enabled[payload.Event] {
+		return
+	}
+	id, err := generateEventID(s.random)
+	if err != nil {
+		s.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "event_dropped",
+			Message:   fmt.Sprintf("Dropped %s event: %v", payload.Event, err),
+			PaymentID: payload.PaymentID,
+		})
+		return
+	}
+	event := PaymentEvent{
+		SchemaVersion: EventSchemaVersion,
+		ID:            id,
+		Event:         payload.Event,
+		PaymentID:     payload.PaymentID,
+		Timestamp:     payload.Timestamp,
+		Data:          payload.Data,
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "event_dropped",
+			Message:   fmt.Sprintf("Event queue full, dropped %s event", payload.Event),
+			PaymentID: payload.PaymentID,
+		})
+	}
+}
+
+// run publishes queued events until the queue is closed and drained
+func (s *eventSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.publish(event); err != nil {
+			s.logger.log(LogEntry{
+				Level:     LogLevelError,
+				Event:     "event_publish_failed",
+				Message:   fmt.Sprintf("Failed to publish %s event %s: %v", event.Event, event.ID, err),
+				PaymentID: event.PaymentID,
+			})
+		}
+	}
+}
+
+// publish sends one event, retrying with exponential backoff. Once the sink is
+// closing, remaining events get a single attempt each.
+func (s *eventSink) publish(event PaymentEvent) error {
+	backoff := s.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		err := s.send(ctx, event)
+		cancel()
+		if err == nil || attempt >= s.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-s.stop:
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// publishMessage sends event to the broker of config.Publisher
+func (s *eventSink) publishMessage(ctx context.Context, event PaymentEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	return s.config.Publisher.Publish(ctx, EventMessage{
+		Subject: s.config.SubjectPrefix + string(event.Event),
+		ID:      event.ID,
+		Key:     event.PaymentID,
+		Data:    data,
+	})
+}
+
+// close stops accepting events and waits until the queued ones were published
+func (s *eventSink) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	close(s.queue)
+	s.mu.Unlock()
+	<-s.done
+}
+
+// dispatchEvent notifies the webhook endpoint, the event sink and the
+// notifiers, whichever are configured, of a payment or escrow event, wakes
+// the status streams of the payment and counts the event in the metrics
+func (p *Paywall) dispatchEvent(payload WebhookPayload) {
+	p.metrics.observeEvent(payload)
+	p.notifyStatusWatchers(payload)
+	if p.webhookDispatcher != nil {
+		p.webhookDispatcher.Dispatch(payload)
+	}
+	if p.eventSink != nil {
+		p.eventSink.dispatch(payload)
+	}
+	for _, notifier := range p.notifiers {
+		notifier.dispatch(payload)
+	}
+}
+
+// generateEventID creates a unique identifier for a published event
+func generateEventID(random io.Reader) (string, error) {
+	b := make([]byte, 16)
+	if err := readRandom(random, b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
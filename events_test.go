@@ -0,0 +1,136 @@
+package paywall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher is an EventPublisher that records messages and fails the
+// first failures calls
+type recordingPublisher struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	messages []EventMessage
+}
+
+func (r *recordingPublisher) Publish(_ context.Context, msg EventMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		return errors.New("broker unavailable")
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *recordingPublisher) published() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages)
+}
+
+func TestEventSink_PublishesPaymentEvents(t *testing.T) {
+	publisher := &recordingPublisher{failures: 1}
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		EventSink: &EventSinkConfig{
+			Publisher:     publisher,
+			SubjectPrefix: "shop.",
+			RetryBackoff:  time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for publisher.published() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	pw.Close()
+
+	if len(publisher.messages) != 1 {
+		t.Fatalf("published %d messages, want 1", len(publisher.messages))
+	}
+	msg := publisher.messages[0]
+	if msg.Subject != "shop.payment_created" || msg.Key != payment.ID {
+		t.Errorf("message subject %q key %q, want shop.payment_created and %s", msg.Subject, msg.Key, payment.ID)
+	}
+	var event PaymentEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		t.Fatalf("message is not a PaymentEvent: %v", err)
+	}
+	if event.SchemaVersion != EventSchemaVersion || event.ID != msg.ID || event.ID == "" ||
+		event.Event != EventPaymentCreated || event.PaymentID != payment.ID {
+		t.Errorf("event = %+v", event)
+	}
+	if publisher.attempts != 2 {
+		t.Errorf("publish attempts = %d, want a retry after the failure", publisher.attempts)
+	}
+}
+
+func TestEventSink_Dispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     EventSinkConfig
+		events     []WebhookEventType
+		wantEvents []WebhookEventType
+	}{
+		{
+			name:       "all events",
+			events:     []WebhookEventType{EventPaymentCreated, EventPaymentConfirmed},
+			wantEvents: []WebhookEventType{EventPaymentCreated, EventPaymentConfirmed},
+		},
+		{
+			name:       "enabled events only",
+			config:     EventSinkConfig{EnabledEvents: []WebhookEventType{EventPaymentConfirmed}},
+			events:     []WebhookEventType{EventPaymentCreated, EventPaymentConfirmed},
+			wantEvents: []WebhookEventType{EventPaymentConfirmed},
+		},
+		{
+			name:   "retries exhausted",
+			config: EventSinkConfig{MaxRetries: 1},
+			events: []WebhookEventType{EventPaymentConfirmed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &recordingPublisher{}
+			if tt.wantEvents == nil {
+				publisher.failures = 100
+			}
+			config := tt.config
+			config.Publisher = publisher
+			config.RetryBackoff = time.Millisecond
+			sink := newEventSink(config, NewStructuredLogger(io.Discard, LogLevelError, true), nil)
+			for _, event := range tt.events {
+				sink.dispatch(WebhookPayload{Event: event, PaymentID: "pay", Timestamp: time.Now()})
+			}
+			sink.close()
+			// Events after close are ignored
+			sink.dispatch(WebhookPayload{Event: EventPaymentCreated, PaymentID: "late"})
+
+			if len(publisher.messages) != len(tt.wantEvents) {
+				t.Fatalf("published %d messages, want %d", len(publisher.messages), len(tt.wantEvents))
+			}
+			for i, want := range tt.wantEvents {
+				if got := publisher.messages[i].Subject; got != DefaultEventSubjectPrefix+string(want) {
+					t.Errorf("message %d subject = %q, want %s", i, got, DefaultEventSubjectPrefix+string(want))
+				}
+			}
+		})
+	}
+}
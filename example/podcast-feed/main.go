@@ -0,0 +1,156 @@
+// Example podcast-feed serves a paid podcast RSS feed.
+//
+// Listeners pay once in the browser at /subscribe, then receive a personal feed
+// URL carrying a signed feed token they can paste into any podcast app. The feed
+// and every episode enclosure are protected by FeedMiddleware, and subscribers
+// can rotate a leaked URL at /subscribe/regenerate.
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/opd-ai/paywall"
+)
+
+const baseURL = "http://localhost:8080"
+
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel channel  `xml:"channel"`
+}
+
+type channel struct {
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+	Items []item `xml:"item"`
+}
+
+type item struct {
+	Title     string    `xml:"title"`
+	Enclosure enclosure `xml:"enclosure"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+var episodes = []string{"episode-1.mp3", "episode-2.mp3"}
+
+var subscribePage = template.Must(template.New("subscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Premium Podcast</title></head>
+<body>
+	<h1>Thanks for subscribing!</h1>
+	<p>Add this private feed URL to your podcast app:</p>
+	<pre>{{.}}</pre>
+	<p>Do not share it. If it leaks, <a href="/subscribe/regenerate">regenerate your feed URL</a>.</p>
+</body>
+</html>`))
+
+func main() {
+	// A stable signing key keeps feed URLs valid across restarts
+	signingKey := []byte(os.Getenv("PAYWALL_SIGNING_KEY"))
+
+	pw, err := paywall.NewPaywall(paywall.Config{
+		PriceInBTC:       0.0001,
+		TestNet:          true,
+		Store:            paywall.NewFileStore("./payments"),
+		PaymentTimeout:   time.Hour * 24 * 30, // 30-day subscription
+		MinConfirmations: 1,
+		SigningKey:       signingKey,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create paywall: %v", err)
+	}
+	defer pw.Close()
+
+	http.Handle("/subscribe", pw.Middleware(subscribeHandler(pw, false)))
+	http.Handle("/subscribe/regenerate", pw.Middleware(subscribeHandler(pw, true)))
+	http.Handle("/feed.xml", pw.FeedMiddleware(http.HandlerFunc(feedHandler)))
+	http.Handle("/media/", pw.FeedMiddleware(http.HandlerFunc(mediaHandler)))
+
+	log.Printf("Podcast server running on %s - visit %s/subscribe", baseURL, baseURL)
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
+
+// subscribeHandler shows the subscriber's personal feed URL once they have paid
+func subscribeHandler(pw *paywall.Paywall, regenerate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paymentID, err := paymentIDFromCookie(r)
+		if err != nil {
+			http.Error(w, "Payment cookie missing", http.StatusBadRequest)
+			return
+		}
+
+		var token string
+		if regenerate {
+			token, err = pw.RegenerateFeedToken(paymentID)
+		} else {
+			token, err = pw.FeedToken(paymentID)
+		}
+		if err != nil {
+			http.Error(w, "Failed to issue feed URL", http.StatusInternalServerError)
+			return
+		}
+
+		feedURL, err := paywall.AppendFeedToken(baseURL+"/feed.xml", token)
+		if err != nil {
+			http.Error(w, "Failed to build feed URL", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		subscribePage.Execute(w, feedURL)
+	}
+}
+
+// feedHandler renders the RSS feed, propagating the subscriber's token to enclosures
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get(paywall.FeedTokenParam)
+
+	feed := rss{Version: "2.0", Channel: channel{Title: "Premium Podcast", Link: baseURL}}
+	for i, name := range episodes {
+		mediaURL, err := paywall.AppendFeedToken(baseURL+"/media/"+name, token)
+		if err != nil {
+			http.Error(w, "Failed to build feed", http.StatusInternalServerError)
+			return
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item{
+			Title:     fmt.Sprintf("Episode %d", i+1),
+			Enclosure: enclosure{URL: mediaURL, Type: "audio/mpeg"},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("Failed to encode feed: %v", err)
+	}
+}
+
+// mediaHandler serves episode audio (placeholder bytes in this example)
+func mediaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Write([]byte("ID3 placeholder audio for " + r.URL.Path))
+}
+
+func paymentIDFromCookie(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("__Host-payment_id")
+	if err != nil {
+		cookie, err = r.Cookie("payment_id")
+	}
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
@@ -4,15 +4,12 @@ import (
 	"flag"
 	"log"
 	"net"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/opd-ai/paywall"
 	reverseproxy "github.com/opd-ai/paywall/example/reverseproxy/proxy"
-	wileedot "github.com/opd-ai/wileedot"
-	"github.com/sethvargo/go-limiter/httplimit"
-	"github.com/sethvargo/go-limiter/memorystore"
+	"github.com/opd-ai/paywall/serve"
 )
 
 // flags: -target http://locaPlhost:3000
@@ -27,6 +24,10 @@ import (
 // flags: -letsencrypt false
 // flags: -email ""
 // flags: -cert-dir ./
+// flags: -teaser-paragraphs 0
+// flags: -teaser-percent 0
+// flags: -teaser-selector article
+// flags: -handoff false
 var (
 	target           = flag.String("target", "http://localhost:3000", "target server URL")
 	protectedPath    = flag.String("protected-path", "/protected", "protected path requiring payment")
@@ -40,8 +41,12 @@ var (
 	letsencrypt      = flag.Bool("letsencrypt", false, "use Let's Encrypt for HTTPS")
 	email            = flag.String("email", "", "email for Let's Encrypt certificate")
 	certDir          = flag.String("cert-dir", wd(), "directory for Let's Encrypt certificates")
-	tokens           = flag.Uint64("tokens", 15, "number of tokens allowed per interval")
-	interval         = flag.Duration("interval", 1*time.Minute, "interval until tokens reset")
+	tokens           = flag.Int("tokens", 15, "number of payments a client IP may create per interval")
+	interval         = flag.Duration("interval", 1*time.Minute, "interval over which the tokens refill")
+	teaserParagraphs = flag.Int("teaser-paragraphs", 0, "show unpaid visitors this many paragraphs of HTML pages (0 for a full-page paywall)")
+	teaserPercent    = flag.Int("teaser-percent", 0, "show unpaid visitors this percentage of the paragraphs of HTML pages")
+	teaserSelector   = flag.String("teaser-selector", "article", "element holding the article text for teasers (tag, #id or .class)")
+	handoff          = flag.Bool("handoff", false, "forward unpaid requests to the target with X-Paywall-* payment headers instead of showing the payment page")
 )
 
 func wd() string {
@@ -62,50 +67,45 @@ func main() {
 		PaymentTimeout:   *paymentTimeout,
 		MinConfirmations: *minConfirmations,
 		TestNet:          *testnet,
+		// The paywall limits its own endpoints; tighten payment creation
+		RateLimits: map[paywall.RateLimitClass]paywall.RateLimit{
+			paywall.RateLimitCreate: {Requests: *tokens, Interval: *interval},
+		},
 	}
 	// create a new paywall instance
-	paywall, err := paywall.NewPaywall(config)
+	pw, err := paywall.NewPaywall(config)
 	if err != nil {
 		log.Fatal(err)
 	}
-	proxy, err := reverseproxy.NewProxy(*target, paywall)
+	proxy, err := reverseproxy.NewProxy(*target, pw)
 	if err != nil {
 		log.Fatal(err)
 	}
 	if *protectedPath != "" {
 		proxy.ProtectedPath = *protectedPath
 	}
-	var listener net.Listener
-	store, err := memorystore.New(&memorystore.Config{
-		Tokens:   *tokens,
-		Interval: *interval,
-	})
-	if err != nil {
-		log.Fatal(err)
+	if *teaserParagraphs > 0 || *teaserPercent > 0 {
+		proxy.Teaser = &paywall.TeaserConfig{
+			Selector:   *teaserSelector,
+			Paragraphs: *teaserParagraphs,
+			Percent:    *teaserPercent,
+		}
 	}
-	limiter, err := httplimit.NewMiddleware(store, httplimit.IPKeyFunc())
-	if err != nil {
-		log.Fatal(err)
+	proxy.Handoff = *handoff
+	serveConfig := serve.Config{
+		HTTPAddr: net.JoinHostPort(*hostname, *port),
+		Paywall:  pw,
 	}
 	if *letsencrypt {
-		cfg := wileedot.Config{
-			Domain:         *hostname,
-			AllowedDomains: []string{*hostname},
-			CertDir:        *certDir,
-			Email:          *email,
-		}
-		listener, err = wileedot.New(cfg)
-		if err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		listenaAddr := net.JoinHostPort(*hostname, *port)
-		listener, err = net.Listen("tcp", listenaAddr)
-		if err != nil {
-			log.Fatal(err)
+		// HTTPS on :443 with a redirect from :80
+		serveConfig = serve.Config{
+			Domain:  *hostname,
+			Email:   *email,
+			CertDir: *certDir,
+			Paywall: pw,
 		}
 	}
-	if err := http.Serve(listener, limiter.Handle(proxy)); err != nil {
+	if err := serve.ListenAndServe(serveConfig, proxy); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -21,6 +21,13 @@ type Proxy struct {
 	*paywall.Paywall
 	*ReverseProxy
 	ProtectedPath string
+	// Teaser, when set, shows unpaid visitors the first paragraphs of upstream
+	// HTML pages with the payment widget below instead of a full-page block
+	Teaser *paywall.TeaserConfig
+	// Handoff forwards unpaid requests to the target with X-Paywall-Status:
+	// pending and the payment details in X-Paywall-* headers, so the target
+	// renders its own paywall (see paywall.WithUpstreamHandoff)
+	Handoff bool
 }
 
 // NewProxy creates a new Proxy instance
@@ -52,16 +59,28 @@ func NewProxy(target string, p *paywall.Paywall) (*Proxy, error) {
 //  3. Forwards the request to the target server if payment is confirmed
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if p.ProtectedPath != "" && checkPath(r.URL.Path, p.ProtectedPath) {
-		p.Middleware(p.ReverseProxy).ServeHTTP(w, r)
+		p.protected().ServeHTTP(w, r)
 		return
 	}
 	if p.ProtectedPath == "" {
-		p.Middleware(p.ReverseProxy).ServeHTTP(w, r)
+		p.protected().ServeHTTP(w, r)
 		return
 	}
 	p.ReverseProxy.ServeHTTP(w, r)
 }
 
+// protected returns the reverse proxy behind the paywall middleware
+func (p *Proxy) protected() http.Handler {
+	var opts []paywall.MiddlewareOption
+	if p.Teaser != nil {
+		opts = append(opts, paywall.WithTeaser(*p.Teaser))
+	}
+	if p.Handoff {
+		opts = append(opts, paywall.WithUpstreamHandoff(""))
+	}
+	return p.MiddlewareWithOptions(p.ReverseProxy, opts...)
+}
+
 func checkPath(path, protected string) bool {
 	return strings.HasPrefix(strings.TrimLeft(path, string(filepath.Separator)), strings.TrimLeft(protected, string(filepath.Separator)))
 }
@@ -0,0 +1,158 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultExpirySweepInterval is the default Config.ExpirySweepInterval
+	defaultExpirySweepInterval = time.Minute
+	// expirySweepGrace is how long past ExpiresAt the sweeper leaves a payment
+	// to the monitor, whose check before expiring it also sees a last-second
+	// payment
+	expirySweepGrace = 2 * monitorInterval
+)
+
+// ExpiredPaymentFinder is an optional PaymentStore extension for the expiry
+// sweeper: stores that index payments by expiry return the overdue ones
+// directly instead of the sweeper reading every pending payment.
+// MemoryStore implements it.
+type ExpiredPaymentFinder interface {
+	// GetPaymentsExpiredBefore returns the pending and detected payments whose
+	// ExpiresAt is before deadline
+	GetPaymentsExpiredBefore(deadline time.Time) ([]*Payment, error)
+}
+
+// ExpiredPaymentPruner is an optional PaymentStore extension that removes
+// payments that expired unpaid. Required when Config.ExpiredPaymentRetention
+// is set. MemoryStore deletes them; FileStore and EncryptedFileStore move
+// their files to the "archive" subdirectory of the payments directory.
+// RedisStore needs no pruning: its keys expire on their own.
+type ExpiredPaymentPruner interface {
+	// PruneExpiredPayments removes the payments in StatusExpired whose
+	// ExpiresAt is before t
+	// Returns how many payments were removed
+	PruneExpiredPayments(t time.Time) (int, error)
+}
+
+// overduePayments returns the pending and detected payments whose ExpiresAt
+// is before deadline, through ExpiredPaymentFinder when the store implements it
+func (p *Paywall) overduePayments(ctx context.Context, deadline time.Time) ([]*Payment, error) {
+	if finder, ok := p.Store.(ExpiredPaymentFinder); ok {
+		return finder.GetPaymentsExpiredBefore(deadline)
+	}
+	return selectPayments(ctx, p.Store, PaymentFilter{Statuses: []PaymentStatus{StatusPending, StatusDetected}}, func(payment *Payment) bool {
+		return payment.ExpiresAt.Before(deadline)
+	})
+}
+
+// startExpirySweeper sweeps expired payments every interval until ctx is done
+// or the paywall shuts down
+func (m *CryptoChainMonitor) startExpirySweeper(ctx context.Context, interval time.Duration) {
+	stop := m.paywall.workers.stopping()
+	if !m.paywall.workers.start() {
+		return
+	}
+	go func() {
+		defer m.paywall.workers.done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := m.sweepExpired(time.Now()); err != nil {
+					m.paywall.logger.log(LogEntry{
+						Level:   LogLevelWarn,
+						Event:   "expiry_sweep_failed",
+						Message: fmt.Sprintf("Expiry sweep failed: %v", err),
+					})
+				}
+			}
+		}
+	}()
+}
+
+// sweepExpired marks unpaid payments more than expirySweepGrace past their
+// ExpiresAt as StatusExpired without querying the chain, so payments expire on
+// time while the monitor backs off from a failing chain backend or works
+// through a long queue. Expired payments are still checked by the monitor, so
+// a late payment confirms them. With Config.ExpiredPaymentRetention set, it
+// then prunes the payments that expired longer ago than that.
+//
+// Returns:
+//   - int: How many payments were expired
+//   - error: If the store could not be read or pruned
+func (m *CryptoChainMonitor) sweepExpired(now time.Time) (int, error) {
+	ctx := m.context()
+	overdue, err := m.paywall.overduePayments(ctx, now.Add(-expirySweepGrace))
+	if err != nil {
+		return 0, fmt.Errorf("list expired payments: %w", err)
+	}
+
+	expired := 0
+	for _, payment := range overdue {
+		if ctx.Err() != nil || m.paywall.workers.stopped() {
+			break
+		}
+		if m.expireOverdue(payment.ID, now) {
+			expired++
+		}
+	}
+	if expired > 0 {
+		m.paywall.logger.log(LogEntry{
+			Level:   LogLevelInfo,
+			Event:   "expiry_sweep",
+			Message: fmt.Sprintf("Expired %d unpaid payments", expired),
+		})
+	}
+
+	if m.paywall.expiredPaymentRetention <= 0 {
+		return expired, nil
+	}
+	pruner, ok := m.paywall.Store.(ExpiredPaymentPruner)
+	if !ok {
+		return expired, nil
+	}
+	pruned, err := pruner.PruneExpiredPayments(now.Add(-m.paywall.expiredPaymentRetention))
+	if err != nil {
+		return expired, fmt.Errorf("prune expired payments: %w", err)
+	}
+	if pruned > 0 {
+		m.paywall.logger.log(LogEntry{
+			Level:   LogLevelInfo,
+			Event:   "expired_payments_pruned",
+			Message: fmt.Sprintf("Pruned %d payments that expired before %s", pruned, now.Add(-m.paywall.expiredPaymentRetention).Format(time.RFC3339)),
+		})
+	}
+	return expired, nil
+}
+
+// expireOverdue expires the payment with paymentID if it is still unpaid and
+// past its ExpiresAt once locked and read again
+//
+// Returns:
+//   - bool: true if the payment was expired
+func (m *CryptoChainMonitor) expireOverdue(paymentID string, now time.Time) bool {
+	unlock, err := m.paywall.lockPayment(paymentID, monitorLockWait)
+	if err != nil {
+		// The monitor or a request holds it; the next sweep tries again
+		return false
+	}
+	defer unlock()
+
+	payment, err := getPaymentContext(m.context(), m.paywall.Store, paymentID)
+	if err != nil || payment == nil {
+		return false
+	}
+	if (payment.Status != StatusPending && payment.Status != StatusDetected) || now.Before(payment.ExpiresAt) {
+		return false
+	}
+	m.expirePayment(payment)
+	return payment.Status == StatusExpired
+}
@@ -0,0 +1,191 @@
+package paywall
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestSweepExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		status     PaymentStatus
+		expiresAt  time.Time
+		wantStatus PaymentStatus
+	}{
+		{"overdue pending", StatusPending, now.Add(-time.Minute), StatusExpired},
+		{"overdue detected", StatusDetected, now.Add(-time.Minute), StatusExpired},
+		{"within grace", StatusPending, now.Add(-5 * time.Second), StatusPending},
+		{"not yet expired", StatusPending, now.Add(time.Hour), StatusPending},
+		{"confirmed", StatusConfirmed, now.Add(-time.Minute), StatusConfirmed},
+	}
+	for _, lister := range []bool{false, true} {
+		pw := newTestPaywall(t, Config{})
+		if lister {
+			// A store without ExpiredPaymentFinder is scanned
+			memory := pw.Store.(*MemoryStore)
+			pw.Store = struct {
+				PaymentStore
+				PaymentLister
+			}{memory, memory}
+		}
+		for _, tt := range tests {
+			payment := &Payment{
+				ID:        tt.name,
+				Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "addr-" + tt.name},
+				Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+				Status:    tt.status,
+				CreatedAt: now.Add(-time.Hour),
+				ExpiresAt: tt.expiresAt,
+			}
+			if err := pw.Store.CreatePayment(payment); err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+		}
+
+		monitor := &CryptoChainMonitor{paywall: pw}
+		expired, err := monitor.sweepExpired(now)
+		if err != nil || expired != 2 {
+			t.Fatalf("lister %v: sweepExpired() = %d, %v, want 2 expired", lister, expired, err)
+		}
+		for _, tt := range tests {
+			if stored, _ := pw.Store.GetPayment(tt.name); stored.Status != tt.wantStatus {
+				t.Errorf("lister %v: %s: status = %s, want %s", lister, tt.name, stored.Status, tt.wantStatus)
+			}
+		}
+		if expired, _ := monitor.sweepExpired(now); expired != 0 {
+			t.Errorf("lister %v: second sweepExpired() expired %d, want 0", lister, expired)
+		}
+	}
+}
+
+func TestSweepExpired_Retention(t *testing.T) {
+	store := NewMemoryStore()
+	pw, err := NewPaywall(Config{
+		PriceInBTC:              0.001,
+		PaymentTimeout:          time.Hour,
+		TestNet:                 true,
+		Store:                   store,
+		Logger:                  NewStructuredLogger(io.Discard, LogLevelError, true),
+		ExpiredPaymentRetention: 48 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+
+	now := time.Now()
+	for id, expiresAt := range map[string]time.Time{
+		"old":    now.Add(-72 * time.Hour),
+		"recent": now.Add(-30 * time.Hour),
+	} {
+		store.CreatePayment(&Payment{ID: id, Status: StatusExpired, ExpiresAt: expiresAt})
+	}
+	monitor := &CryptoChainMonitor{paywall: pw}
+	if _, err := monitor.sweepExpired(now); err != nil {
+		t.Fatalf("sweepExpired() error = %v", err)
+	}
+	if old, _ := store.GetPayment("old"); old != nil {
+		t.Error("payment expired 72 hours ago was kept")
+	}
+	if recent, _ := store.GetPayment("recent"); recent == nil {
+		t.Error("payment expired 30 hours ago was pruned")
+	}
+}
+
+func TestFileStore_PruneExpiredPayments(t *testing.T) {
+	encryptedDir := t.TempDir()
+	encrypted, err := NewEncryptedFileStore(t.TempDir()+"/store.key", encryptedDir)
+	if err != nil {
+		t.Fatalf("NewEncryptedFileStore() error = %v", err)
+	}
+	fileDir := t.TempDir()
+	stores := []struct {
+		name  string
+		store interface {
+			PaymentStore
+			ExpiredPaymentPruner
+		}
+		dir string
+		ext string
+	}{
+		{"file", NewFileStore(fileDir), fileDir, ".json"},
+		{"encrypted", encrypted, encryptedDir, ".enc"},
+	}
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			for _, p := range []*Payment{
+				{ID: "old", Status: StatusExpired, ExpiresAt: cutoff.Add(-time.Hour)},
+				{ID: "recent", Status: StatusExpired, ExpiresAt: cutoff.Add(time.Hour)},
+				{ID: "paid", Status: StatusConfirmed, ExpiresAt: cutoff.Add(-time.Hour)},
+			} {
+				p.Addresses = map[wallet.WalletType]string{wallet.Bitcoin: "addr-" + p.ID}
+				p.Amounts = map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}
+				if err := s.store.CreatePayment(p); err != nil {
+					t.Fatalf("CreatePayment() error = %v", err)
+				}
+			}
+
+			pruned, err := s.store.PruneExpiredPayments(cutoff)
+			if err != nil || pruned != 1 {
+				t.Fatalf("PruneExpiredPayments() = %d, %v, want 1", pruned, err)
+			}
+			if old, _ := s.store.GetPayment("old"); old != nil {
+				t.Error("pruned payment is still readable")
+			}
+			if _, err := os.Stat(filepath.Join(s.dir, archiveDir, "old"+s.ext)); err != nil {
+				t.Errorf("pruned payment was not moved to %s: %v", archiveDir, err)
+			}
+			for _, id := range []string{"recent", "paid"} {
+				if p, err := s.store.GetPayment(id); p == nil {
+					t.Errorf("payment %s was pruned: %v", id, err)
+				}
+			}
+			payments, _ := s.store.(PaymentLister).ListPayments()
+			if len(payments) != 2 {
+				t.Errorf("ListPayments() after pruning = %d payments, want 2", len(payments))
+			}
+			if pruned, _ := s.store.PruneExpiredPayments(cutoff); pruned != 0 {
+				t.Errorf("second PruneExpiredPayments() = %d, want 0", pruned)
+			}
+		})
+	}
+}
+
+func TestExpiryConfig(t *testing.T) {
+	memory := NewMemoryStore()
+	tests := []struct {
+		name      string
+		retention time.Duration
+		store     PaymentStore
+		wantErr   string
+	}{
+		{"negative", -time.Hour, memory, "must not be negative"},
+		{"shorter than late payment window", time.Hour, memory, "at least"},
+		{"store cannot prune", 48 * time.Hour, struct{ PaymentStore }{memory}, "ExpiredPaymentPruner"},
+		{"valid", 48 * time.Hour, memory, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: tt.store, ExpiredPaymentRetention: tt.retention})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewPaywall() error = %v", err)
+				}
+				pw.Close()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPaywall() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
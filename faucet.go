@@ -0,0 +1,261 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	// ErrFaucetDisabled is returned when no testnet faucet is configured
+	ErrFaucetDisabled = errors.New("testnet faucet is not configured")
+	// ErrFaucetAlreadyRequested is returned when test coins were already requested
+	// for the payment address
+	ErrFaucetAlreadyRequested = errors.New("test coins were already requested for this payment")
+	// ErrFaucetFailed is returned when the faucet API rejects the request or is unreachable
+	ErrFaucetFailed = errors.New("testnet faucet request failed")
+)
+
+// faucetRequestTimeout bounds a single call to the faucet API
+const faucetRequestTimeout = 15 * time.Second
+
+// FaucetRequest is the JSON body posted to Config.TestnetFaucetURL, and the
+// response of HandleTestnetFaucet
+type FaucetRequest struct {
+	PaymentID string            `json:"payment_id,omitempty"`
+	Currency  wallet.WalletType `json:"currency"`
+	Address   string            `json:"address"`
+	Amount    float64           `json:"amount"`
+}
+
+// faucetLimiter remembers which payment addresses were already funded by the
+// faucet, so a demo page cannot be used to drain it
+type faucetLimiter struct {
+	mu        sync.Mutex
+	requested map[string]time.Time
+}
+
+// claim records a faucet request for key, pruning entries older than ttl
+//
+// Returns:
+//   - bool: false if key was already claimed within ttl
+func (l *faucetLimiter) claim(key string, now time.Time, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, at := range l.requested {
+		if now.Sub(at) > ttl {
+			delete(l.requested, k)
+		}
+	}
+	if _, ok := l.requested[key]; ok {
+		return false
+	}
+	if l.requested == nil {
+		l.requested = make(map[string]time.Time)
+	}
+	l.requested[key] = now
+	return true
+}
+
+// release forgets a claim whose faucet request failed, so the payer can retry
+func (l *faucetLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.requested, key)
+}
+
+// RequestTestCoins asks the configured testnet faucet to send the payment amount
+// to the payment's address, so evaluators can try the paywall end to end without
+// hunting for a faucet. Each payment address is funded at most once.
+//
+// Parameters:
+//   - paymentID: The pending payment to fund
+//   - currency: wallet.Bitcoin or wallet.Monero
+//
+// Returns:
+//   - *FaucetRequest: The address and amount requested from the faucet
+//   - error: ErrFaucetDisabled, ErrProofPaymentNotFound, ErrProofPaymentNotPending,
+//     ErrFaucetAlreadyRequested, ErrFaucetFailed, or storage errors
+//
+// Related: HandleTestnetFaucet, Config.TestnetFaucetURL
+func (p *Paywall) RequestTestCoins(paymentID string, currency wallet.WalletType) (*FaucetRequest, error) {
+	if p.faucetURL == "" {
+		return nil, ErrFaucetDisabled
+	}
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	now := time.Now()
+	if payment.Status != StatusPending || !now.Before(payment.ExpiresAt) {
+		return nil, ErrProofPaymentNotPending
+	}
+	address := payment.Addresses[currency]
+	if address == "" {
+		return nil, fmt.Errorf("payment %s has no %s address: %w", payment.ID, currency, ErrProofPaymentNotPending)
+	}
+
+	req := &FaucetRequest{
+		PaymentID: payment.ID,
+		Currency:  currency,
+		Address:   address,
+		Amount:    payment.Amounts[currency],
+	}
+	key := string(currency) + ":" + address
+	if !p.faucetRequests.claim(key, now, p.paymentTimeout) {
+		return nil, ErrFaucetAlreadyRequested
+	}
+	if err := p.callFaucet(req); err != nil {
+		p.faucetRequests.release(key)
+		p.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "faucet_request_failed",
+			Message:   fmt.Sprintf("Testnet faucet request failed: %v", err),
+			PaymentID: payment.ID,
+			Currency:  currency,
+		})
+		return nil, fmt.Errorf("%w: %v", ErrFaucetFailed, err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "faucet_requested",
+		Message:   fmt.Sprintf("Requested test coins for %s", address),
+		PaymentID: payment.ID,
+		Currency:  currency,
+		Amount:    req.Amount,
+	})
+	return req, nil
+}
+
+// callFaucet posts req to the faucet API. The placeholders {address}, {amount}
+// and {currency} in the faucet URL are replaced first, for faucets that take
+// their parameters in the URL.
+func (p *Paywall) callFaucet(req *FaucetRequest) error {
+	target := strings.NewReplacer(
+		"{address}", url.QueryEscape(req.Address),
+		"{amount}", strconv.FormatFloat(req.Amount, 'f', -1, 64),
+		"{currency}", string(req.Currency),
+	).Replace(p.faucetURL)
+
+	body, err := json.Marshal(FaucetRequest{Currency: req.Currency, Address: req.Address, Amount: req.Amount})
+	if err != nil {
+		return fmt.Errorf("encode faucet request: %w", err)
+	}
+	resp, err := p.faucetClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("faucet answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// HandleTestnetFaucet requests test coins for the visitor's payment. Mount it at
+// Config.TestnetFaucetPath to show the "Request test coins" buttons on the
+// payment page.
+//
+// Requests are POSTs with the form fields (or JSON fields) "currency" ("BTC" by
+// default) and optionally "payment_id"; the payment cookie is used otherwise.
+// Form posts redirect back to the referring page; JSON requests get a FaucetRequest.
+//
+// Responses:
+//   - 200 with a FaucetRequest, or 303 back to the payment page for form posts
+//   - 400 Bad Request for malformed requests
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 404 Not Found if the payment does not exist
+//   - 409 Conflict if the payment is not pending or has no address for the currency
+//   - 429 Too Many Requests if test coins were already requested for the payment,
+//     or (with Retry-After) beyond Config.RateLimits
+//   - 501 Not Implemented if no faucet is configured
+//   - 502 Bad Gateway if the faucet rejects the request
+func (p *Paywall) HandleTestnetFaucet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitSubmit) {
+		return
+	}
+
+	var req FaucetRequest
+	form, ok := decodeRequestBody(w, r, p.requestLimits(), &req, true)
+	if !ok {
+		return
+	}
+	isForm := form != nil
+	if isForm {
+		req.PaymentID = form.Get("payment_id")
+		req.Currency = wallet.WalletType(form.Get("currency"))
+	}
+	if req.Currency == "" {
+		req.Currency = wallet.Bitcoin
+	}
+	if req.Currency != wallet.Bitcoin && req.Currency != wallet.Monero {
+		http.Error(w, fmt.Sprintf("Unsupported currency %q", req.Currency), http.StatusBadRequest)
+		return
+	}
+	if req.PaymentID == "" {
+		id, err := paymentIDFromCookie(r)
+		if err != nil {
+			http.Error(w, "payment_id is required", http.StatusBadRequest)
+			return
+		}
+		req.PaymentID = id
+	}
+
+	result, err := p.RequestTestCoins(req.PaymentID, req.Currency)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrFaucetDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, ErrProofPaymentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrProofPaymentNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrFaucetAlreadyRequested):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, ErrFaucetFailed):
+		http.Error(w, "The testnet faucet could not send coins, please try again later", http.StatusBadGateway)
+		return
+	default:
+		http.Error(w, "Failed to request test coins", http.StatusInternalServerError)
+		return
+	}
+
+	if isForm {
+		if back := sameOriginReferer(r); back != "" {
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode faucet response: %v", err),
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestRequestTestCoins(t *testing.T) {
+	var mu sync.Mutex
+	var received []FaucetRequest
+	var queries []string
+	failing := false
+	faucet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "faucet is dry", http.StatusServiceUnavailable)
+			return
+		}
+		var req FaucetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received = append(received, req)
+		queries = append(queries, r.URL.RawQuery)
+	}))
+	defer faucet.Close()
+
+	pw, err := NewPaywall(Config{
+		PriceInBTC:        0.001,
+		PaymentTimeout:    time.Hour,
+		TestNet:           true,
+		Store:             NewMemoryStore(),
+		TestnetFaucetURL:  faucet.URL + "/send?to={address}&amount={amount}",
+		TestnetFaucetPath: "/paywall/faucet",
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	address := payment.Addresses[wallet.Bitcoin]
+
+	page := httptest.NewRecorder()
+	pw.renderPaymentPage(page, payment)
+	if !strings.Contains(page.Body.String(), `action="/paywall/faucet"`) {
+		t.Error("payment page does not offer test coins")
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/paywall/faucet", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		pw.HandleTestnetFaucet(rec, req)
+		return rec
+	}
+
+	rec := post(`{"payment_id":"` + payment.ID + `","currency":"BTC"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200 (body %q)", rec.Code, rec.Body.String())
+	}
+	mu.Lock()
+	if len(received) != 1 || received[0].Address != address || received[0].Amount != 0.001 || received[0].Currency != wallet.Bitcoin {
+		t.Errorf("faucet received %+v, want %s for 0.001 BTC", received, address)
+	}
+	if want := "to=" + address + "&amount=0.001"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("faucet query = %v, want %q", queries, want)
+	}
+	mu.Unlock()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"already requested", `{"payment_id":"` + payment.ID + `"}`, http.StatusTooManyRequests},
+		{"no monero address", `{"payment_id":"` + payment.ID + `","currency":"XMR"}`, http.StatusConflict},
+		{"unknown currency", `{"payment_id":"` + payment.ID + `","currency":"DOGE"}`, http.StatusBadRequest},
+		{"unknown payment", `{"payment_id":"missing"}`, http.StatusNotFound},
+		{"no payment", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := post(tt.body); rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// A failed faucet call does not use up the payment's request
+	other, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	if _, err := pw.RequestTestCoins(other.ID, wallet.Bitcoin); !errors.Is(err, ErrFaucetFailed) {
+		t.Fatalf("RequestTestCoins() with failing faucet error = %v, want ErrFaucetFailed", err)
+	}
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if _, err := pw.RequestTestCoins(other.ID, wallet.Bitcoin); err != nil {
+		t.Errorf("RequestTestCoins() retry error = %v", err)
+	}
+}
+
+func TestTestnetFaucetConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		testNet bool
+		url     string
+		wantErr bool
+	}{
+		{"testnet faucet", true, "https://faucet.example/api/send", false},
+		{"mainnet faucet", false, "https://faucet.example/api/send", true},
+		{"not a url", true, "faucet.example", true},
+		{"disabled", true, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(&Config{
+				PriceInBTC:       0.001,
+				PaymentTimeout:   time.Hour,
+				TestNet:          tt.testNet,
+				Store:            NewMemoryStore(),
+				TestnetFaucetURL: tt.url,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	pw := createTestPaywall()
+	if _, err := pw.RequestTestCoins("any", wallet.Bitcoin); !errors.Is(err, ErrFaucetDisabled) {
+		t.Errorf("RequestTestCoins() without faucet error = %v, want ErrFaucetDisabled", err)
+	}
+}
@@ -0,0 +1,217 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedTokenParam is the query parameter carrying a per-subscriber feed token
+const FeedTokenParam = "feed_token"
+
+// feedTokenPurpose binds feed token signatures to this token type
+const feedTokenPurpose = "feed_token/v1"
+
+// ErrInvalidFeedToken is returned for malformed, tampered, regenerated or revoked feed tokens
+var ErrInvalidFeedToken = errors.New("invalid feed token")
+
+// FeedToken returns the current feed token for a confirmed payment.
+// Unlike pw_token query tokens, feed tokens are long-lived: they stay valid
+// until the payment expires or the token is regenerated or revoked, which
+// suits RSS/Atom readers and podcast apps that poll a fixed subscription URL.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed, unexpired payment
+//
+// Returns:
+//   - string: Token to pass as ?feed_token=<token>
+//   - error: If the payment does not exist or does not grant access
+//
+// Related: FeedURL, RegenerateFeedToken, RevokeFeedToken, FeedMiddleware
+func (p *Paywall) FeedToken(paymentID string) (string, error) {
+	payment, err := p.feedPayment(paymentID)
+	if err != nil {
+		return "", err
+	}
+	return p.signFeedToken(payment), nil
+}
+
+// FeedURL returns rawURL with the subscriber's feed token appended as a query parameter.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed, unexpired payment
+//   - rawURL: Feed or media URL (e.g. "https://example.com/podcast.xml")
+//
+// Returns:
+//   - string: URL including ?feed_token=<token>
+//   - error: If the URL cannot be parsed or FeedToken fails
+func (p *Paywall) FeedURL(paymentID, rawURL string) (string, error) {
+	token, err := p.FeedToken(paymentID)
+	if err != nil {
+		return "", err
+	}
+	return AppendFeedToken(rawURL, token)
+}
+
+// AppendFeedToken adds a feed token to rawURL. Feed handlers use it to carry the
+// subscriber's token into enclosure and item links so media downloads stay authorized.
+//
+// Parameters:
+//   - rawURL: URL to extend
+//   - token: Feed token, usually taken from the incoming request's feed_token parameter
+//
+// Returns:
+//   - string: URL including ?feed_token=<token>
+//   - error: If rawURL cannot be parsed
+func AppendFeedToken(rawURL, token string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse feed URL: %w", err)
+	}
+	query := u.Query()
+	query.Set(FeedTokenParam, token)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// RegenerateFeedToken invalidates every feed URL issued for the payment and
+// returns a fresh token. Use it when a subscriber reports a leaked feed URL.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed, unexpired payment
+//
+// Returns:
+//   - string: New feed token
+//   - error: If the payment does not grant access or cannot be updated
+func (p *Paywall) RegenerateFeedToken(paymentID string) (string, error) {
+	payment, err := p.bumpFeedTokenGeneration(paymentID)
+	if err != nil {
+		return "", err
+	}
+	return p.signFeedToken(payment), nil
+}
+
+// RevokeFeedToken invalidates every feed URL issued for the payment.
+// A new token can still be obtained later with FeedToken or RegenerateFeedToken
+// while the payment remains confirmed.
+//
+// Parameters:
+//   - paymentID: ID of the payment whose feed URLs should stop working
+//
+// Returns:
+//   - error: If the payment cannot be loaded or updated
+func (p *Paywall) RevokeFeedToken(paymentID string) error {
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return fmt.Errorf("payment %s not found", paymentID)
+	}
+	payment.FeedTokenGeneration++
+	if err := p.Store.UpdatePayment(payment); err != nil {
+		return fmt.Errorf("update payment: %w", err)
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "feed_token_revoked",
+		Message:   "Feed token revoked",
+		PaymentID: paymentID,
+	})
+	return nil
+}
+
+// FeedMiddleware protects feed and feed media endpoints with per-subscriber feed tokens.
+//
+// Parameters:
+//   - next: Handler serving the feed or its enclosures
+//
+// Returns:
+//   - http.Handler: Handler that requires a valid ?feed_token= parameter
+//
+// Feed readers cannot complete a checkout, so instead of rendering the payment
+// page requests without a valid token receive 403 Forbidden. The token is left
+// in the request so next can propagate it with AppendFeedToken.
+func (p *Paywall) FeedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get(FeedTokenParam)
+		if token == "" {
+			http.Error(w, "Feed subscription token required", http.StatusForbidden)
+			return
+		}
+		if _, err := p.verifyFeedToken(token); err != nil {
+			p.logger.log(LogEntry{
+				Level:   LogLevelDebug,
+				Event:   "feed_token_rejected",
+				Message: fmt.Sprintf("Rejected feed token for %s: %v", r.URL.Path, err),
+			})
+			http.Error(w, "Invalid or expired feed subscription", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// feedPayment loads a payment and checks that it currently grants access
+func (p *Paywall) feedPayment(paymentID string) (*Payment, error) {
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, fmt.Errorf("payment %s not found", paymentID)
+	}
+	if !payment.GrantsAccess(time.Now()) {
+		return nil, fmt.Errorf("payment %s does not grant access (status: %s)", paymentID, payment.Status)
+	}
+	return payment, nil
+}
+
+// bumpFeedTokenGeneration increments the payment's feed token generation
+func (p *Paywall) bumpFeedTokenGeneration(paymentID string) (*Payment, error) {
+	payment, err := p.feedPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	payment.FeedTokenGeneration++
+	if err := p.Store.UpdatePayment(payment); err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "feed_token_regenerated",
+		Message:   fmt.Sprintf("Feed token regenerated (generation %d)", payment.FeedTokenGeneration),
+		PaymentID: paymentID,
+	})
+	return payment, nil
+}
+
+func (p *Paywall) signFeedToken(payment *Payment) string {
+	gen := strconv.Itoa(payment.FeedTokenGeneration)
+	return payment.ID + "." + gen + "." + p.signer.sign(feedTokenPurpose, payment.ID, gen)
+}
+
+// verifyFeedToken checks the signature and generation of a feed token and
+// returns the payment it grants access for
+func (p *Paywall) verifyFeedToken(token string) (*Payment, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidFeedToken
+	}
+	paymentID, gen, sig := parts[0], parts[1], parts[2]
+	if !p.signer.verify(sig, feedTokenPurpose, paymentID, gen) {
+		return nil, ErrInvalidFeedToken
+	}
+	payment, err := p.feedPayment(paymentID)
+	if err != nil {
+		return nil, ErrInvalidFeedToken
+	}
+	if strconv.Itoa(payment.FeedTokenGeneration) != gen {
+		return nil, ErrInvalidFeedToken
+	}
+	return payment, nil
+}
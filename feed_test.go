@@ -0,0 +1,106 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFeedToken_Lifecycle(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	payment := confirmedTestPayment(t, pw)
+
+	token, err := pw.FeedToken(payment.ID)
+	if err != nil {
+		t.Fatalf("FeedToken() error = %v", err)
+	}
+	if _, err := pw.verifyFeedToken(token); err != nil {
+		t.Fatalf("verifyFeedToken() error = %v", err)
+	}
+	again, _ := pw.FeedToken(payment.ID)
+	if again != token {
+		t.Error("FeedToken() should be stable until regenerated")
+	}
+
+	regenerated, err := pw.RegenerateFeedToken(payment.ID)
+	if err != nil {
+		t.Fatalf("RegenerateFeedToken() error = %v", err)
+	}
+	if _, err := pw.verifyFeedToken(token); err != ErrInvalidFeedToken {
+		t.Errorf("old token after regenerate: error = %v, want ErrInvalidFeedToken", err)
+	}
+	if _, err := pw.verifyFeedToken(regenerated); err != nil {
+		t.Errorf("regenerated token rejected: %v", err)
+	}
+
+	if err := pw.RevokeFeedToken(payment.ID); err != nil {
+		t.Fatalf("RevokeFeedToken() error = %v", err)
+	}
+	if _, err := pw.verifyFeedToken(regenerated); err != ErrInvalidFeedToken {
+		t.Errorf("token after revoke: error = %v, want ErrInvalidFeedToken", err)
+	}
+}
+
+func TestFeedToken_RequiresConfirmedPayment(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, err := pw.FeedToken(pending.ID); err == nil {
+		t.Error("FeedToken() should refuse pending payments")
+	}
+	if _, err := pw.RegenerateFeedToken(pending.ID); err == nil {
+		t.Error("RegenerateFeedToken() should refuse pending payments")
+	}
+}
+
+func TestFeedMiddleware(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	payment := confirmedTestPayment(t, pw)
+
+	feedURL, err := pw.FeedURL(payment.ID, "https://example.com/podcast.xml?format=rss")
+	if err != nil {
+		t.Fatalf("FeedURL() error = %v", err)
+	}
+	if !strings.Contains(feedURL, "format=rss") || !strings.Contains(feedURL, FeedTokenParam+"=") {
+		t.Fatalf("FeedURL() = %q", feedURL)
+	}
+
+	handler := pw.FeedMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss/>"))
+	}))
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"valid token", feedURL, http.StatusOK},
+		{"missing token", "/podcast.xml", http.StatusForbidden},
+		{"forged token", "/podcast.xml?feed_token=" + payment.ID + ".0.AAAA", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAppendFeedToken(t *testing.T) {
+	got, err := AppendFeedToken("/media/ep1.mp3?dl=1", "abc.0.sig")
+	if err != nil {
+		t.Fatalf("AppendFeedToken() error = %v", err)
+	}
+	if got != "/media/ep1.mp3?dl=1&feed_token=abc.0.sig" {
+		t.Errorf("AppendFeedToken() = %q", got)
+	}
+	if _, err := AppendFeedToken("://bad", "x"); err == nil {
+		t.Error("AppendFeedToken() should reject invalid URLs")
+	}
+}
@@ -0,0 +1,67 @@
+package paywall
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// FiatEstimate is the approximate value of a payment's crypto amounts in
+// Config.FiatCurrency at the current oracle rate. It is for display only:
+// payments are always for the crypto amount.
+//
+// Related: Config.FiatEstimates, PaymentStatusResponse
+type FiatEstimate struct {
+	// Currency is the ISO 4217 code of Amounts
+	Currency string `json:"currency"`
+	// Amounts is the estimated fiat value per payment currency, rounded to
+	// cents; currencies the oracle has no rate for are missing
+	Amounts map[wallet.WalletType]float64 `json:"amounts"`
+	// At is when the rates were taken
+	At time.Time `json:"at"`
+}
+
+// fiatEstimate converts the amounts of payment to fiat at the current rate
+//
+// Parameters:
+//   - payment: Payment whose amounts are converted
+//
+// Returns:
+//   - *FiatEstimate: The estimate, nil when Config.FiatEstimates is off or
+//     the oracle has no rate for any of the payment's currencies
+func (p *Paywall) fiatEstimate(payment *Payment) *FiatEstimate {
+	if !p.fiatEstimates || p.priceOracle == nil {
+		return nil
+	}
+	now := time.Now()
+	estimate := &FiatEstimate{
+		Currency: p.fiatCurrency,
+		Amounts:  make(map[wallet.WalletType]float64, len(payment.Amounts)),
+		At:       now,
+	}
+	for currency, amount := range payment.Amounts {
+		if amount <= 0 || payment.Addresses[currency] == "" {
+			continue
+		}
+		rate, err := p.priceOracle.FiatPrice(rateCurrency(currency), p.fiatCurrency, now)
+		if err == nil && (rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0)) {
+			err = fmt.Errorf("invalid rate %v", rate)
+		}
+		if err != nil {
+			p.logger.log(LogEntry{
+				Level:     LogLevelDebug,
+				Event:     "fiat_estimate_failed",
+				Message:   fmt.Sprintf("No %s %s rate for the fiat estimate: %v", currency, p.fiatCurrency, err),
+				PaymentID: payment.ID,
+			})
+			continue
+		}
+		estimate.Amounts[currency] = math.Round(amount*rate*100) / 100
+	}
+	if len(estimate.Amounts) == 0 {
+		return nil
+	}
+	return estimate
+}
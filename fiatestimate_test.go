@@ -0,0 +1,87 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestFiatEstimate_PaymentPage(t *testing.T) {
+	oracle := &switchingOracle{rates: map[wallet.WalletType]float64{wallet.Bitcoin: 60000}}
+	pw := newTestPaywall(t, Config{PriceInBTC: 0.00042, PriceOracle: oracle, FiatCurrency: "EUR", FiatEstimates: true, StatusPath: "/paywall/status"})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/article", nil), payment)
+	body := rec.Body.String()
+	if !strings.Contains(body, `<span id="fiat-btc">25.20</span> EUR`) {
+		t.Errorf("payment page does not show the 25.20 EUR estimate")
+	}
+	if !strings.Contains(body, "estimate") {
+		t.Errorf("fiat amount is not labeled as an estimate")
+	}
+
+	// Without a rate the page shows crypto amounts only
+	oracle.set(wallet.Bitcoin, 0, errors.New("rate limited"))
+	rec = httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/article", nil), payment)
+	if strings.Contains(rec.Body.String(), `id="fiat-btc"`) {
+		t.Errorf("payment page shows an estimate without a rate")
+	}
+}
+
+func TestFiatEstimate_StatusEndpoint(t *testing.T) {
+	oracle := &switchingOracle{rates: map[wallet.WalletType]float64{wallet.Bitcoin: 60000}}
+	pw := newTestPaywall(t, Config{PriceInBTC: 0.00042, PriceOracle: oracle, FiatCurrency: "EUR", FiatEstimates: true, StatusPath: "/paywall/status"})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	status := func() PaymentStatusResponse {
+		req := httptest.NewRequest(http.MethodGet, "/paywall/status", nil)
+		req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+		rec := httptest.NewRecorder()
+		pw.HandlePaymentStatus(rec, req)
+		var resp PaymentStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		return resp
+	}
+
+	resp := status()
+	if resp.FiatEstimate == nil || resp.FiatEstimate.Currency != "EUR" || resp.FiatEstimate.Amounts[wallet.Bitcoin] != 25.2 {
+		t.Fatalf("FiatEstimate = %+v, want 25.2 EUR for BTC", resp.FiatEstimate)
+	}
+
+	// The estimate follows the rate
+	oracle.set(wallet.Bitcoin, 50000, nil)
+	if resp := status(); resp.FiatEstimate == nil || resp.FiatEstimate.Amounts[wallet.Bitcoin] != 21 {
+		t.Errorf("FiatEstimate after rate change = %+v, want 21 EUR", resp.FiatEstimate)
+	}
+
+	// Paid payments need no estimate
+	payment.Status = StatusConfirmed
+	payment.Confirmations = 1
+	pw.Store.UpdatePayment(payment)
+	if resp := status(); resp.FiatEstimate != nil {
+		t.Errorf("FiatEstimate for a confirmed payment = %+v, want nil", resp.FiatEstimate)
+	}
+}
+
+func TestFiatEstimate_Config(t *testing.T) {
+	config := Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, Store: NewMemoryStore(), FiatEstimates: true}
+	if err := validateConfig(&config); err == nil || !strings.Contains(err.Error(), "PriceOracle") {
+		t.Errorf("validateConfig() error = %v, want PriceOracle hint", err)
+	}
+}
@@ -0,0 +1,114 @@
+package paywall
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// storeLockFile is the file in a FileStore directory that processes sharing
+// the directory lock to coordinate their reads and writes
+const storeLockFile = ".filestore.lock"
+
+// processLock is an advisory lock on a file, held shared for reads and
+// exclusively for writes, that serializes FileStore access across processes.
+// It uses flock on Unix and LockFileEx on Windows. Both lock the open file
+// rather than the calling goroutine, so shared holds within the process are
+// counted and the file is unlocked when the last one is released.
+//
+// When the lock file cannot be created or locked (a read-only directory or a
+// file system without lock support) the store logs a warning once and falls
+// back to locking within the process only.
+type processLock struct {
+	path    string
+	mode    os.FileMode
+	mu      sync.Mutex
+	file    *os.File
+	readers int
+	warn    sync.Once
+}
+
+// newProcessLock returns the lock for the store directory dir
+func newProcessLock(dir string, mode os.FileMode) *processLock {
+	return &processLock{path: filepath.Join(dir, storeLockFile), mode: mode}
+}
+
+// acquire locks the file, opening it on first use. Must be called with l.mu held.
+func (l *processLock) acquire(exclusive bool) {
+	if l.file == nil {
+		f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, l.mode)
+		if err != nil {
+			l.warn.Do(func() {
+				log.Printf("Warning: cannot open store lock %s, other processes may corrupt payment files: %v", l.path, err)
+			})
+			return
+		}
+		l.file = f
+	}
+	if err := lockFile(l.file, exclusive); err != nil {
+		l.warn.Do(func() {
+			log.Printf("Warning: cannot lock %s, other processes may corrupt payment files: %v", l.path, err)
+		})
+	}
+}
+
+// release unlocks the file. Must be called with l.mu held.
+func (l *processLock) release() {
+	if l.file != nil {
+		unlockFile(l.file)
+	}
+}
+
+// lock takes the exclusive lock. The caller must hold the FileStore's write
+// lock, so no shared hold of this process is active.
+func (l *processLock) lock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.acquire(true)
+}
+
+// unlock releases the exclusive lock
+func (l *processLock) unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release()
+}
+
+// rlock takes a shared hold, locking the file for the first reader
+func (l *processLock) rlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.readers == 0 {
+		l.acquire(false)
+	}
+	l.readers++
+}
+
+// runlock releases a shared hold, unlocking the file after the last reader
+func (l *processLock) runlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.readers--
+	if l.readers == 0 {
+		l.release()
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so readers in other processes never see a partially written file.
+// The caller must hold the store's exclusive lock, which also protects the
+// temporary file name.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := wallet.WriteFileMode(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := replaceFile(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
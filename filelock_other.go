@@ -0,0 +1,21 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package paywall
+
+import "os"
+
+// lockFile is a no-op on platforms without flock or LockFileEx; FileStore
+// access is then only serialized within the process
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op, see lockFile
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+// replaceFile renames src over dst
+func replaceFile(src, dst string) error {
+	return os.Rename(src, dst)
+}
@@ -0,0 +1,118 @@
+package paywall
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func fileLockTestPayment(id string) *Payment {
+	return &Payment{
+		ID:        id,
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "addr-" + id},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		Status:    StatusPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestFileStore_ProcessLock(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("no inter-process file locking on " + runtime.GOOS)
+	}
+	dir := t.TempDir()
+	// Two stores on one directory stand in for two processes: each has its
+	// own lock file handle, which the platform locks treat independently
+	a, b := NewFileStore(dir), NewFileStore(dir)
+	if err := a.CreatePayment(fileLockTestPayment("pay")); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	a.lock()
+	read := make(chan struct{})
+	go func() {
+		b.GetPayment("pay")
+		close(read)
+	}()
+	select {
+	case <-read:
+		t.Fatal("GetPayment() on another store did not wait for the exclusive lock")
+	case <-time.After(100 * time.Millisecond):
+	}
+	a.unlock()
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetPayment() still blocked after the lock was released")
+	}
+}
+
+func TestFileStore_ConcurrentStoresNoLostUpdates(t *testing.T) {
+	dir := t.TempDir()
+	stores := []*FileStore{NewFileStore(dir), NewFileStore(dir)}
+	if err := stores[0].CreatePayment(fileLockTestPayment("pay")); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	// Every update either applies on top of the latest version or is
+	// rejected; none may overwrite another store's update
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(store *FileStore) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				payment, err := store.GetPayment("pay")
+				if err != nil || payment == nil {
+					t.Errorf("GetPayment() = %v, %v", payment, err)
+					return
+				}
+				payment.Confirmations++
+				err = store.UpdatePayment(payment)
+				if errors.Is(err, ErrVersionConflict) {
+					continue
+				}
+				if err != nil {
+					t.Errorf("UpdatePayment() error = %v", err)
+					return
+				}
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			}
+		}(stores[i%2])
+	}
+	wg.Wait()
+
+	payment, _ := stores[1].GetPayment("pay")
+	if payment.Confirmations != applied || payment.Version != applied {
+		t.Errorf("Confirmations = %d, Version = %d, want %d applied updates", payment.Confirmations, payment.Version, applied)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestFileStore_InvalidIDs(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(filepath.Join(dir, "payments"))
+	for _, id := range []string{"", "..", "../escape", `..\escape`, "C:escape", "pay:stream", "nul\x00"} {
+		if err := store.CreatePayment(fileLockTestPayment(id)); err == nil {
+			t.Errorf("CreatePayment(%q) succeeded", id)
+		}
+		if payment, err := store.GetPayment(id); payment != nil || err != nil {
+			t.Errorf("GetPayment(%q) = %v, %v, want nil, nil", id, payment, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("files written outside the store directory: %v", entries)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package paywall
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile blocks until it holds a shared or exclusive LockFileEx lock on the
+// first byte of f
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, new(windows.Overlapped))
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
+
+// replaceFile renames src over dst. Windows refuses to replace a file another
+// program (a backup tool or virus scanner) has open without delete sharing, so
+// the rename is retried for about a second.
+func replaceFile(src, dst string) error {
+	delay := 10 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := os.Rename(src, dst)
+		if err == nil || attempt == 6 ||
+			!(errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_SHARING_VIOLATION)) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
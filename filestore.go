@@ -1,11 +1,18 @@
 package paywall
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,12 +25,48 @@ import (
 //
 // Fields:
 //   - baseDir: Directory path where payment files are stored
+//   - dirMode: Mode of the payment and API key directories
+//   - fileMode: Mode of payment and API key files
 //   - mu: Mutex for thread-safe file operations
+//   - plock: Lock file serializing access with other processes using baseDir
+//
+// Files are replaced atomically (written to a temporary file, then renamed),
+// and every operation holds the directory's lock file, so several processes,
+// e.g. a web server and paywall-monitor, can share a store on Unix and Windows.
 //
 // Related: Store interface
 type FileStore struct {
-	baseDir string
-	mu      sync.RWMutex
+	baseDir  string
+	dirMode  os.FileMode
+	fileMode os.FileMode
+	mu       sync.RWMutex
+	plock    *processLock
+	// validateAddresses is FileStoreConfig.ValidateAddresses
+	validateAddresses bool
+}
+
+// lock acquires exclusive access to the store within and across processes
+func (m *FileStore) lock() {
+	m.mu.Lock()
+	m.plock.lock()
+}
+
+// unlock releases the lock taken by lock
+func (m *FileStore) unlock() {
+	m.plock.unlock()
+	m.mu.Unlock()
+}
+
+// rlock acquires shared access to the store within and across processes
+func (m *FileStore) rlock() {
+	m.mu.RLock()
+	m.plock.rlock()
+}
+
+// runlock releases the lock taken by rlock
+func (m *FileStore) runlock() {
+	m.plock.runlock()
+	m.mu.RUnlock()
 }
 
 // NewFileStore creates a new filesystem-based payment store instance.
@@ -33,28 +76,94 @@ type FileStore struct {
 //   - *FileStore: New payment store configured to use "./payments" directory
 //
 // Error handling:
-//   - Creates payments directory with 0755 permissions
+//   - Creates payments directory with 0700 permissions
 //   - Silently continues if directory already exists
+//   - Logs a warning for every existing directory or file accessible by
+//     group or others (use NewFileStoreWithConfig to repair them)
 func NewFileStore(base string) *FileStore {
+	return newFileStore(FileStoreConfig{DataDir: base})
+}
+
+// newFileStore creates a FileStore with the configured modes, creating the
+// payments directory and checking the permissions of existing files
+func newFileStore(config FileStoreConfig) *FileStore {
 	// Create payments directory if it doesn't exist
-	baseDir := base
+	baseDir := config.DataDir
 	if baseDir == "" {
 		baseDir = "./payments"
 	}
-	os.MkdirAll(baseDir, 0o755)
-	return &FileStore{baseDir: baseDir}
+	m := &FileStore{baseDir: baseDir, dirMode: config.DirMode, fileMode: config.FileMode, validateAddresses: config.ValidateAddresses}
+	if m.dirMode == 0 {
+		m.dirMode = wallet.DefaultDirMode
+	}
+	if m.fileMode == 0 {
+		m.fileMode = wallet.DefaultFileMode
+	}
+	m.plock = newProcessLock(m.baseDir, m.fileMode)
+	wallet.MkdirMode(m.baseDir, m.dirMode)
+	m.checkPermissions(config.RepairPermissions)
+	return m
+}
+
+// checkPermissions logs a warning for the payments directory, the API key
+// directory and every file in them that is more permissive than configured,
+// and tightens them when repair is set
+func (m *FileStore) checkPermissions(repair bool) {
+	check := func(path string, mode os.FileMode) {
+		problem, err := wallet.CheckPermissions(path, mode, repair)
+		if problem != nil {
+			log.Printf("Warning: %s", problem)
+		}
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	for _, dir := range []string{m.baseDir, filepath.Join(m.baseDir, apiKeyDir)} {
+		check(dir, m.dirMode)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				check(filepath.Join(dir, entry.Name()), m.fileMode)
+			}
+		}
+	}
 }
 
 // writePayment is a helper that marshals and writes a payment to disk.
-// Must be called with the mutex held.
+// Must be called with the store locked by lock.
 func (m *FileStore) writePayment(p *Payment) error {
+	if err := validateObjectID(p.ID); err != nil {
+		return err
+	}
 	data, err := json.Marshal(p)
 	if err != nil {
 		return fmt.Errorf("marshal payment: %w", err)
 	}
 
 	filename := filepath.Join(m.baseDir, p.ID+".json")
-	return os.WriteFile(filename, data, 0o600)
+	return writeFileAtomic(filename, data, m.fileMode)
+}
+
+// checkAddresses validates the payment's addresses when the store was
+// configured with ValidateAddresses
+func (m *FileStore) checkAddresses(p *Payment) error {
+	if !m.validateAddresses {
+		return nil
+	}
+	return ValidatePaymentAddresses(p)
+}
+
+// validateObjectID rejects IDs that are not a plain file or object name: empty,
+// "." and "..", or containing path separators of any platform, a drive or
+// stream separator (":") or NUL
+func validateObjectID(id string) error {
+	if id == "" || strings.ContainsAny(id, "/\\:\x00") || id == "." || id == ".." {
+		return fmt.Errorf("invalid object ID %q", id)
+	}
+	return nil
 }
 
 // CreatePayment stores a new payment record as a JSON file.
@@ -68,8 +177,11 @@ func (m *FileStore) writePayment(p *Payment) error {
 //
 // Thread-safety: Protected by write lock
 func (m *FileStore) CreatePayment(p *Payment) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if err := m.checkAddresses(p); err != nil {
+		return err
+	}
+	m.lock()
+	defer m.unlock()
 	return m.writePayment(p)
 }
 
@@ -84,8 +196,11 @@ func (m *FileStore) CreatePayment(p *Payment) error {
 //
 // Thread-safety: Protected by read lock
 func (m *FileStore) GetPayment(id string) (*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	if validateObjectID(id) != nil {
+		return nil, nil
+	}
+	m.rlock()
+	defer m.runlock()
 
 	filename := filepath.Join(m.baseDir, id+".json")
 	data, err := os.ReadFile(filename)
@@ -120,8 +235,11 @@ func (m *FileStore) GetPayment(id string) (*Payment, error) {
 //
 // Thread-safety: Protected by write lock
 func (m *FileStore) UpdatePayment(p *Payment) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	if err := m.checkAddresses(p); err != nil {
+		return err
+	}
+	m.lock()
+	defer m.unlock()
 
 	// Read existing payment within the write lock to prevent race conditions
 	filename := filepath.Join(m.baseDir, p.ID+".json")
@@ -157,8 +275,8 @@ func (m *FileStore) UpdatePayment(p *Payment) error {
 //   - Silently skips files with read or parse errors
 //   - Thread-safety: Protected by read lock
 func (m *FileStore) ListPendingPayments() ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -191,6 +309,131 @@ func (m *FileStore) ListPendingPayments() ([]*Payment, error) {
 	return payments, nil
 }
 
+// ListPayments returns all payment records in the storage directory.
+//
+// Returns:
+//   - []*Payment: Every readable payment, in directory order
+//   - error: Directory read errors
+//
+// Notes:
+//   - Silently skips non-JSON files
+//   - Silently skips files with read or parse errors
+//   - Thread-safety: Protected by read lock
+func (m *FileStore) ListPayments() ([]*Payment, error) {
+	m.rlock()
+	defer m.runlock()
+
+	files, err := os.ReadDir(m.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []*Payment
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.baseDir, file.Name()))
+		if err != nil {
+			log.Printf("Error reading file %s: %v", file.Name(), err)
+			continue
+		}
+
+		var payment Payment
+		if err := json.Unmarshal(data, &payment); err != nil {
+			log.Printf("Error parsing file %s: %v", file.Name(), err)
+			continue
+		}
+		payments = append(payments, &payment)
+	}
+
+	return payments, nil
+}
+
+// StreamPayments calls fn for every payment file matching filter, reading the
+// directory in batches so memory use does not grow with the store.
+// Implements PaymentStreamer.
+//
+// Parameters:
+//   - filter: Selects the payments passed to fn
+//   - fn: Called once per payment; return ErrStopStream to stop early
+//
+// Returns:
+//   - error: Directory read errors, or fn's error other than ErrStopStream
+//
+// Notes:
+//   - Silently skips non-JSON files
+//   - Logs and skips files with read or parse errors
+//   - Thread-safety: Each file is read under the read lock, which is released
+//     while fn runs
+func (m *FileStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	return m.streamPaymentFiles(m.readPaymentFile, filter, fn)
+}
+
+// readPaymentFile reads the payment file name in the store directory,
+// returning nil, nil for files that are not payments.
+// Must be called with the store locked.
+func (m *FileStore) readPaymentFile(name string) (*Payment, error) {
+	if filepath.Ext(name) != ".json" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.baseDir, name))
+	if err != nil {
+		return nil, err
+	}
+	var payment Payment
+	if err := json.Unmarshal(data, &payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// streamPaymentsBatch is how many directory entries StreamPayments reads at once
+const streamPaymentsBatch = 256
+
+// streamPaymentFiles reads the store directory in batches and calls fn for
+// every payment decoded by read that matches filter. read returns nil, nil for
+// files that are not payments and is called with the read lock held.
+func (m *FileStore) streamPaymentFiles(read func(name string) (*Payment, error), filter PaymentFilter, fn func(*Payment) error) error {
+	dir, err := os.Open(m.baseDir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	for {
+		entries, dirErr := dir.ReadDir(streamPaymentsBatch)
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), partitionIDPrefix(filter.Partition)) {
+				continue
+			}
+			m.rlock()
+			payment, err := read(entry.Name())
+			m.runlock()
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // removed since the directory was read
+			}
+			if err != nil {
+				log.Printf("Error reading file %s: %v", entry.Name(), err)
+				continue
+			}
+			if payment == nil || !filter.Matches(payment) {
+				continue
+			}
+			if err := fn(payment); err != nil {
+				return streamEnded(err)
+			}
+		}
+		if errors.Is(dirErr, io.EOF) {
+			return nil
+		}
+		if dirErr != nil {
+			return dirErr
+		}
+	}
+}
+
 // GetPaymentByAddress retrieves a payment record by Bitcoin address.
 // Scans all payment files sequentially until a match is found.
 //
@@ -206,8 +449,8 @@ func (m *FileStore) ListPendingPayments() ([]*Payment, error) {
 //   - Silently skips files with read or parse errors
 //   - Thread-safety: Protected by read lock
 func (m *FileStore) GetPaymentByAddress(addr string) (*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -253,8 +496,8 @@ func (m *FileStore) GetPaymentByAddress(addr string) (*Payment, error) {
 //   - Silently skips non-JSON files and parse errors
 //   - Thread-safety: Protected by read lock
 func (m *FileStore) GetPendingMultisigPayments() ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -300,8 +543,8 @@ func (m *FileStore) GetPendingMultisigPayments() ([]*Payment, error) {
 //   - Thread-safety: Protected by read lock
 //   - For better performance at scale, consider indexing by timeout
 func (m *FileStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	files, err := os.ReadDir(m.baseDir)
 	if err != nil {
@@ -339,19 +582,321 @@ func (m *FileStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, er
 	return expiring, nil
 }
 
+// apiKeyDir is the subdirectory of the store's base directory holding API keys
+const apiKeyDir = "apikeys"
+
+// SaveAPIKey creates or replaces an API key record in the apikeys subdirectory.
+//
+// Parameters:
+//   - key: API key record to store
+//
+// Returns:
+//   - error: Directory creation, JSON marshaling or file write errors
+//
+// Thread-safety: Protected by write lock
+func (m *FileStore) SaveAPIKey(key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal API key: %w", err)
+	}
+
+	if err := validateObjectID(key.ID); err != nil {
+		return err
+	}
+	m.lock()
+	defer m.unlock()
+	dir := filepath.Join(m.baseDir, apiKeyDir)
+	if err := wallet.MkdirMode(dir, m.dirMode); err != nil {
+		return fmt.Errorf("create API key directory: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, key.ID+".json"), data, m.fileMode)
+}
+
+// GetAPIKey retrieves an API key record by ID.
+//
+// Returns:
+//   - *APIKey: API key record if found, nil if not found
+//   - error: File read errors or JSON unmarshaling errors
+//
+// Thread-safety: Protected by read lock
+func (m *FileStore) GetAPIKey(id string) (*APIKey, error) {
+	m.rlock()
+	defer m.runlock()
+
+	data, err := os.ReadFile(filepath.Join(m.baseDir, apiKeyDir, filepath.Base(id)+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns all API key records.
+//
+// Returns:
+//   - []*APIKey: Every stored key; empty when none have been created
+//   - error: Directory read errors
+//
+// Notes:
+//   - Silently skips files with read or parse errors
+//   - Thread-safety: Protected by read lock
+func (m *FileStore) ListAPIKeys() ([]*APIKey, error) {
+	m.rlock()
+	defer m.runlock()
+
+	dir := filepath.Join(m.baseDir, apiKeyDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []*APIKey
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Printf("Error reading file %s: %v", file.Name(), err)
+			continue
+		}
+		var key APIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			log.Printf("Error parsing file %s: %v", file.Name(), err)
+			continue
+		}
+		keys = append(keys, &key)
+	}
+	return keys, nil
+}
+
+// statsDir is the subdirectory of the store's base directory holding stats snapshots
+const statsDir = "stats"
+
+// SaveStatsSnapshot stores a stats snapshot in the stats subdirectory, named
+// after its time.
+//
+// Returns:
+//   - error: Directory creation, JSON marshaling or file write errors
+//
+// Thread-safety: Protected by write lock
+func (m *FileStore) SaveStatsSnapshot(snapshot *StatsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal stats snapshot: %w", err)
+	}
+
+	m.lock()
+	defer m.unlock()
+	dir := filepath.Join(m.baseDir, statsDir)
+	if err := wallet.MkdirMode(dir, m.dirMode); err != nil {
+		return fmt.Errorf("create stats directory: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, statsSnapshotName(snapshot.Time)), data, m.fileMode)
+}
+
+// ListStatsSnapshots returns the stats snapshots taken in [from, to). Only
+// the files in the range are read.
+//
+// Returns:
+//   - []*StatsSnapshot: Snapshots, oldest first
+//   - error: Directory read errors
+//
+// Notes:
+//   - Silently skips files with read or parse errors
+//   - Thread-safety: Protected by read lock
+func (m *FileStore) ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error) {
+	m.rlock()
+	defer m.runlock()
+
+	dir := filepath.Join(m.baseDir, statsDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// os.ReadDir sorts by name, which sorts snapshots by time
+	var snapshots []*StatsSnapshot
+	for _, file := range files {
+		taken, ok := statsSnapshotTime(file.Name())
+		if !ok || taken.Before(from) || !taken.Before(to) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Printf("Error reading file %s: %v", file.Name(), err)
+			continue
+		}
+		var snapshot StatsSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			log.Printf("Error parsing file %s: %v", file.Name(), err)
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// DeleteStatsSnapshotsBefore removes the stats snapshot files taken before t.
+//
+// Returns:
+//   - error: Directory read or file removal errors
+//
+// Thread-safety: Protected by write lock
+func (m *FileStore) DeleteStatsSnapshotsBefore(t time.Time) error {
+	m.lock()
+	defer m.unlock()
+
+	dir := filepath.Join(m.baseDir, statsDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, file := range files {
+		taken, ok := statsSnapshotTime(file.Name())
+		if !ok || !taken.Before(t) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveDir is the subdirectory archived payment files are moved to
+const archiveDir = "archive"
+
+// DeletePayment removes a payment file.
+//
+// Returns:
+//   - error: File removal errors; removing a missing payment succeeds
+//
+// Thread-safety: Protected by write lock
+func (m *FileStore) DeletePayment(id string) error {
+	return m.deletePaymentFile(id, ".json")
+}
+
+// deletePaymentFile removes the payment file named after id and ext
+func (m *FileStore) deletePaymentFile(id, ext string) error {
+	if err := validateObjectID(id); err != nil {
+		return err
+	}
+	m.lock()
+	defer m.unlock()
+	if err := os.Remove(filepath.Join(m.baseDir, id+ext)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete payment: %w", err)
+	}
+	return nil
+}
+
+// ArchivePayments moves the files of the payments ArchivablePayment selects
+// for olderThan to the "archive" subdirectory, where the store no longer
+// reads them; delete or back up that directory as you see fit.
+//
+// Returns:
+//   - int: How many payments were moved
+//   - error: Directory read, creation or rename errors
+//
+// Thread-safety: Payments are selected under the read lock and moved under
+// the write lock, after reading them again
+func (m *FileStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return m.archiveFiles(m.readPaymentFile, ".json", func(p *Payment) bool {
+		return ArchivablePayment(p, olderThan)
+	})
+}
+
+// PruneExpiredPayments moves the files of payments in StatusExpired whose
+// ExpiresAt is before t to the "archive" subdirectory, like ArchivePayments.
+// Implements ExpiredPaymentPruner.
+//
+// Returns:
+//   - int: How many payments were moved
+//   - error: Directory read, creation or rename errors
+func (m *FileStore) PruneExpiredPayments(t time.Time) (int, error) {
+	return m.archiveFiles(m.readPaymentFile, ".json", func(p *Payment) bool {
+		return p.Status == StatusExpired && p.ExpiresAt.Before(t)
+	})
+}
+
+// archiveFiles moves the payment files named after the payment ID and ext
+// whose payment, as decoded by read, is selected by keep into archiveDir
+func (m *FileStore) archiveFiles(read func(name string) (*Payment, error), ext string, keep func(*Payment) bool) (int, error) {
+	var ids []string
+	err := m.streamPaymentFiles(read, PaymentFilter{}, func(p *Payment) error {
+		if keep(p) {
+			ids = append(ids, p.ID)
+		}
+		return nil
+	})
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	m.lock()
+	defer m.unlock()
+	dir := filepath.Join(m.baseDir, archiveDir)
+	if err := wallet.MkdirMode(dir, m.dirMode); err != nil {
+		return 0, fmt.Errorf("create archive directory: %w", err)
+	}
+	archived := 0
+	for _, id := range ids {
+		name := id + ext
+		// A late payment may have confirmed it since it was listed
+		payment, err := read(name)
+		if err != nil || payment == nil || !keep(payment) {
+			continue
+		}
+		if err := os.Rename(filepath.Join(m.baseDir, name), filepath.Join(dir, name)); err != nil {
+			return archived, fmt.Errorf("archive payment %s: %w", id, err)
+		}
+		archived++
+	}
+	return archived, nil
+}
+
 // FileStoreConfig defines configuration parameters for file-based payment storage
 //
 // Fields:
 //   - DataDir: Directory path where payment files will be stored
 //   - EncryptionKey: Optional 32-byte key for AES-256 encryption (if nil, no encryption)
+//   - DirMode: Mode of the payment and API key directories (optional, default 0700)
+//   - FileMode: Mode of payment and API key files (optional, default 0600)
+//   - RepairPermissions: Tighten existing directories and files found more
+//     permissive than DirMode and FileMode at startup (optional, default is to
+//     log a warning only)
 //
 // Security:
-//   - DataDir should have appropriate filesystem permissions (0755)
+//   - Modes are applied exactly, regardless of the process umask; widen them
+//     (e.g. DirMode 0750, FileMode 0640) only to share the store with a group
+//   - The encryption key file is always created 0600 and checked against it
 //   - EncryptionKey must be securely generated and stored if provided
 //   - When EncryptionKey is provided, files are stored with AES-256-GCM encryption
 type FileStoreConfig struct {
-	DataDir       string
-	EncryptionKey []byte // Optional: 32-byte key for AES-256 encryption
+	DataDir           string
+	EncryptionKey     []byte // Optional: 32-byte key for AES-256 encryption
+	DirMode           os.FileMode
+	FileMode          os.FileMode
+	RepairPermissions bool
+	// ValidateAddresses rejects payments whose addresses fail
+	// ValidatePaymentAddresses on CreatePayment and UpdatePayment, so corrupt
+	// records are never written. Optional: defaults to false.
+	ValidateAddresses bool
 }
 
 // NewFileStoreWithConfig creates a new filesystem-based payment store with configuration.
@@ -365,7 +910,8 @@ type FileStoreConfig struct {
 //   - error: If directory creation fails or encryption setup fails
 //
 // Security:
-//   - Creates directory with 0755 permissions
+//   - Creates directory with DirMode permissions (0700 by default)
+//   - Warns about, or with RepairPermissions tightens, over-permissive files
 //   - Validates encryption key length (must be 32 bytes if provided)
 //   - Uses AES-256-GCM encryption when key is provided
 //
@@ -376,7 +922,11 @@ func NewFileStoreWithConfig(config FileStoreConfig) (PaymentStore, error) {
 		config.DataDir = "./payments"
 	}
 
-	if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+	dirMode := config.DirMode
+	if dirMode == 0 {
+		dirMode = wallet.DefaultDirMode
+	}
+	if err := wallet.MkdirMode(config.DataDir, dirMode); err != nil {
 		return nil, fmt.Errorf("create storage directory: %w", err)
 	}
 
@@ -388,9 +938,117 @@ func NewFileStoreWithConfig(config FileStoreConfig) (PaymentStore, error) {
 
 		// For encrypted store, we need to save the key to a file
 		keyPath := filepath.Join(config.DataDir, "store.key")
-		return NewEncryptedFileStore(keyPath, config.DataDir)
+		return newEncryptedFileStore(keyPath, config)
 	}
 
 	// Use standard file store without encryption
-	return NewFileStore(config.DataDir), nil
+	return newFileStore(config), nil
+}
+
+const (
+	// fileLockStaleAfter is the age after which a lock file is considered left
+	// over from a crashed process and is broken. Lock holders finish well within it.
+	fileLockStaleAfter = 2 * time.Minute
+	// fileLockPoll is how often a held lock file is retried
+	fileLockPoll = 50 * time.Millisecond
+)
+
+// LockPayment implements PaymentLocker with a lock file next to the payment
+// file, so processes sharing the directory serialize their updates to a payment.
+//
+// Parameters:
+//   - ctx: Bounds the wait for a held lock
+//   - paymentID: Payment to lock
+//
+// Returns:
+//   - func(): Releases the lock
+//   - error: ErrPaymentLocked when ctx ends first, or file errors
+//
+// Lock files older than two minutes are treated as left over from a crashed
+// process and broken.
+func (m *FileStore) LockPayment(ctx context.Context, paymentID string) (func(), error) {
+	if err := validateObjectID(paymentID); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(m.baseDir, paymentID+".lock")
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			_, writeErr := f.WriteString(token)
+			closeErr := f.Close()
+			if writeErr != nil || closeErr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("write lock file: %w", errors.Join(writeErr, closeErr))
+			}
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					// Only remove our own lock, not one that replaced it after ours went stale
+					if data, err := os.ReadFile(path); err == nil && string(data) == token {
+						os.Remove(path)
+					}
+				})
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("create lock file: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > fileLockStaleAfter {
+			log.Printf("Breaking stale lock for payment %s", paymentID)
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrPaymentLocked, ctx.Err())
+		case <-time.After(fileLockPoll):
+		}
+	}
+}
+
+// addressIndexFile is the file in the store's base directory holding the next
+// address index of every wallet, as a JSON object. It has no .json extension,
+// so it is not read as a payment.
+const addressIndexFile = "address_indexes"
+
+// ReserveAddressIndex implements AddressIndexReserver. The counters are kept
+// in one file, updated under the lock shared with other processes using the
+// directory.
+//
+// Returns:
+//   - uint32: The reserved index, at least floor
+//   - error: File read, parse or write errors
+func (m *FileStore) ReserveAddressIndex(ctx context.Context, key string, floor uint32) (uint32, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.lock()
+	defer m.unlock()
+
+	path := filepath.Join(m.baseDir, addressIndexFile)
+	next := make(map[string]uint32)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("read address indexes: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &next); err != nil {
+			return 0, fmt.Errorf("parse address indexes: %w", err)
+		}
+	}
+	index := max(next[key], floor)
+	next[key] = index + 1
+	if data, err = json.Marshal(next); err != nil {
+		return 0, fmt.Errorf("marshal address indexes: %w", err)
+	}
+	if err := writeFileAtomic(path, data, m.fileMode); err != nil {
+		return 0, fmt.Errorf("write address indexes: %w", err)
+	}
+	return index, nil
 }
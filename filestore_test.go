@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
@@ -463,6 +464,34 @@ func TestFileStore_ListPendingPayments_NonJSONFiles(t *testing.T) {
 	}
 }
 
+func TestFileStore_ListPayments(t *testing.T) {
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	store := NewFileStore(tempDir)
+
+	pending := createTestPayment("pending-payment")
+	confirmed := createTestPayment("confirmed-payment")
+	confirmed.Status = StatusConfirmed
+	confirmed.Confirmations = 3
+	for _, payment := range []*Payment{pending, confirmed} {
+		if err := store.CreatePayment(payment); err != nil {
+			t.Fatalf("Failed to create test payment: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "notes.txt"), []byte("ignore me"), 0o644); err != nil {
+		t.Fatalf("Failed to create non-JSON file: %v", err)
+	}
+
+	payments, err := store.ListPayments()
+	if err != nil {
+		t.Fatalf("FileStore.ListPayments() error = %v", err)
+	}
+	if len(payments) != 2 {
+		t.Errorf("FileStore.ListPayments() count = %v, want 2", len(payments))
+	}
+}
+
 func TestFileStore_GetPaymentByAddress(t *testing.T) {
 	tempDir := createTempDir(t)
 	defer os.RemoveAll(tempDir)
@@ -870,3 +899,60 @@ func TestStoreImplementations_PendingPaymentConsistency(t *testing.T) {
 		}
 	})
 }
+
+func TestFileStore_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	newPayment := func() *Payment {
+		return &Payment{
+			ID:        "pay",
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "addr"},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+			Status:    StatusPending,
+			CreatedAt: time.Now(),
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "payments")
+	store := NewFileStore(dir)
+	if err := store.CreatePayment(newPayment()); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0o700 {
+		t.Errorf("payments directory mode = %04o, want 0700", info.Mode().Perm())
+	}
+
+	// Files left world readable, e.g. by an older version, are repaired on request
+	paymentFile := filepath.Join(dir, "pay.json")
+	os.Chmod(dir, 0o755)
+	os.Chmod(paymentFile, 0o644)
+	if _, err := NewFileStoreWithConfig(FileStoreConfig{DataDir: dir, RepairPermissions: true}); err != nil {
+		t.Fatalf("NewFileStoreWithConfig() error = %v", err)
+	}
+	for path, want := range map[string]os.FileMode{dir: 0o700, paymentFile: 0o600} {
+		if info, _ := os.Stat(path); info.Mode().Perm() != want {
+			t.Errorf("%s mode = %04o after repair, want %04o", path, info.Mode().Perm(), want)
+		}
+	}
+
+	// A store shared with a group writes group-readable files
+	shared, err := NewFileStoreWithConfig(FileStoreConfig{DataDir: filepath.Join(t.TempDir(), "shared"), DirMode: 0o750, FileMode: 0o640})
+	if err != nil {
+		t.Fatalf("NewFileStoreWithConfig() error = %v", err)
+	}
+	if err := shared.CreatePayment(newPayment()); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(shared.(*FileStore).baseDir, "pay.json")); info.Mode().Perm() != 0o640 {
+		t.Errorf("shared payment file mode = %04o, want 0640", info.Mode().Perm())
+	}
+
+	// The encryption key stays private regardless of FileMode
+	encDir := filepath.Join(t.TempDir(), "encrypted")
+	if _, err := NewFileStoreWithConfig(FileStoreConfig{DataDir: encDir, EncryptionKey: make([]byte, 32), FileMode: 0o640}); err != nil {
+		t.Fatalf("NewFileStoreWithConfig(encrypted) error = %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(encDir, "store.key")); info.Mode().Perm() != 0o600 {
+		t.Errorf("store.key mode = %04o, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,210 @@
+package paywall
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// grantExportVersion is the GrantExport format version written by ExportGrants
+const grantExportVersion = 1
+
+// GrantExport is the document ExportGrants writes and ImportGrants reads
+type GrantExport struct {
+	// Version is the export format version
+	Version int `json:"version"`
+	// ExportedAt is when the export was taken
+	ExportedAt time.Time `json:"exported_at"`
+	// Payments are the confirmed payments granting access, plus confirmed
+	// payments that active API keys are bound to
+	Payments []*Payment `json:"payments"`
+	// APIKeys are the active API keys; secrets are only stored as hashes
+	APIKeys []*APIKey `json:"api_keys,omitempty"`
+}
+
+// GrantImportSummary reports what ImportGrants restored
+type GrantImportSummary struct {
+	// Payments and APIKeys count the restored records
+	Payments int
+	APIKeys  int
+	// Skipped counts records that already exist in the store or no longer
+	// grant access
+	Skipped int
+}
+
+// ExportGrants writes every active access grant as JSON, so a rebuilt instance
+// with a new store can keep honoring existing customers (see ImportGrants).
+// Grants are confirmed payments whose access window is open, which visitors
+// reach through their payment cookie, and active API keys together with the
+// payments they are bound to. Query tokens (pw_token) stay valid as long as
+// the new instance uses the same Config.SigningKey.
+//
+// The export contains payment addresses and API key hashes: store it like the
+// payment store itself.
+//
+// Parameters:
+//   - w: Destination of the JSON document
+//
+// Returns:
+//   - error: If the store implements neither PaymentStreamer nor PaymentLister,
+//     or listing or writing fails
+//
+// Related: ImportGrants, GrantExport
+func (p *Paywall) ExportGrants(w io.Writer) error {
+	now := time.Now()
+	var keys []*APIKey
+	bound := make(map[string]bool)
+	if keyStore, err := apiKeyStoreOf(p.Store); err == nil {
+		all, err := keyStore.ListAPIKeys()
+		if err != nil {
+			return fmt.Errorf("list API keys: %w", err)
+		}
+		for _, key := range all {
+			if key.Active(now) {
+				keys = append(keys, key)
+				bound[key.PaymentID] = true
+			}
+		}
+	}
+
+	// Payments are streamed into the document one at a time, so exporting a
+	// large store does not hold every payment in memory
+	exportedAt, err := json.Marshal(now)
+	if err != nil {
+		return fmt.Errorf("write grant export: %w", err)
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "{\n  \"version\": %d,\n  \"exported_at\": %s,\n  \"payments\": [", grantExportVersion, exportedAt)
+	exported := 0
+	err = StreamPayments(p.Store, PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}}, func(payment *Payment) error {
+		if !payment.GrantsAccess(now) && !bound[payment.ID] {
+			return nil
+		}
+		data, err := json.MarshalIndent(payment, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		if exported > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n    ")
+		out.Write(data)
+		exported++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("stream payments: %w", err)
+	}
+	if exported > 0 {
+		out.WriteString("\n  ")
+	}
+	out.WriteString("]")
+	if len(keys) > 0 {
+		data, err := json.MarshalIndent(keys, "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("write grant export: %w", err)
+		}
+		out.WriteString(",\n  \"api_keys\": ")
+		out.Write(data)
+	}
+	out.WriteString("\n}\n")
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("write grant export: %w", err)
+	}
+
+	p.logger.log(LogEntry{
+		Level:   LogLevelInfo,
+		Event:   "grants_exported",
+		Message: fmt.Sprintf("Exported %d payments and %d API keys", exported, len(keys)),
+	})
+	return nil
+}
+
+// ImportGrants restores access grants written by ExportGrants into the store.
+// Records that already exist are left untouched, so an import can be repeated
+// safely; payments and keys that stopped granting access since the export are
+// skipped.
+//
+// Parameters:
+//   - r: JSON document from ExportGrants
+//
+// Returns:
+//   - GrantImportSummary: Restored and skipped records
+//   - error: If the document is invalid, contains API keys the store cannot
+//     hold, or the store fails; records restored before the failure remain
+//
+// Related: ExportGrants
+func (p *Paywall) ImportGrants(r io.Reader) (GrantImportSummary, error) {
+	var summary GrantImportSummary
+	var export GrantExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return summary, fmt.Errorf("parse grant export: %w", err)
+	}
+	if export.Version != grantExportVersion {
+		return summary, fmt.Errorf("unsupported grant export version %d (want %d)", export.Version, grantExportVersion)
+	}
+	var keys APIKeyStore
+	if len(export.APIKeys) > 0 {
+		var err error
+		if keys, err = apiKeyStoreOf(p.Store); err != nil {
+			return summary, err
+		}
+	}
+
+	now := time.Now()
+	bound := make(map[string]bool)
+	for _, key := range export.APIKeys {
+		if key.Active(now) {
+			bound[key.PaymentID] = true
+		}
+	}
+	for _, payment := range export.Payments {
+		if err := MigratePayment(payment); err != nil {
+			return summary, fmt.Errorf("invalid payment in export: %w", err)
+		}
+		if !payment.GrantsAccess(now) && !(payment.Status == StatusConfirmed && bound[payment.ID]) {
+			summary.Skipped++
+			continue
+		}
+		existing, err := p.Store.GetPayment(payment.ID)
+		if err != nil {
+			return summary, fmt.Errorf("get payment %s: %w", payment.ID, err)
+		}
+		if existing != nil {
+			summary.Skipped++
+			continue
+		}
+		if err := p.Store.CreatePayment(payment); err != nil {
+			return summary, fmt.Errorf("restore payment %s: %w", payment.ID, err)
+		}
+		summary.Payments++
+	}
+
+	for _, key := range export.APIKeys {
+		if !key.Active(now) {
+			summary.Skipped++
+			continue
+		}
+		existing, err := keys.GetAPIKey(key.ID)
+		if err != nil {
+			return summary, fmt.Errorf("get API key %s: %w", key.ID, err)
+		}
+		if existing != nil {
+			summary.Skipped++
+			continue
+		}
+		if err := keys.SaveAPIKey(key); err != nil {
+			return summary, fmt.Errorf("restore API key %s: %w", key.ID, err)
+		}
+		summary.APIKeys++
+	}
+
+	p.logger.log(LogEntry{
+		Level:   LogLevelInfo,
+		Event:   "grants_imported",
+		Message: fmt.Sprintf("Imported %d payments and %d API keys, skipped %d records", summary.Payments, summary.APIKeys, summary.Skipped),
+	})
+	return summary, nil
+}
@@ -0,0 +1,103 @@
+package paywall
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExportImportGrants(t *testing.T) {
+	newPaywall := func() *Paywall {
+		pw, err := NewPaywall(Config{
+			PriceInBTC:     0.001,
+			PaymentTimeout: time.Hour,
+			TestNet:        true,
+			Store:          NewMemoryStore(),
+			APIKeysEnabled: true,
+			Logger:         NewStructuredLogger(io.Discard, LogLevelError, true),
+		})
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		t.Cleanup(pw.Close)
+		return pw
+	}
+	old := newPaywall()
+	confirm := func(payment *Payment, accessEnds time.Time) {
+		payment.Status = StatusConfirmed
+		payment.Confirmations = 1
+		payment.AccessExpiresAt = accessEnds
+		if err := old.Store.UpdatePayment(payment); err != nil {
+			t.Fatalf("UpdatePayment() error = %v", err)
+		}
+	}
+
+	active, _ := old.CreatePayment()
+	confirm(active, time.Now().Add(time.Hour))
+	keyed, _ := old.CreatePayment()
+	confirm(keyed, time.Now().Add(time.Hour))
+	apiKey, _, err := old.IssueAPIKey(keyed.ID, APIKeyOptions{Label: "customer"})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+	// The key outlives its payment's access window
+	confirm(keyed, time.Now().Add(-time.Minute))
+	lapsed, _ := old.CreatePayment()
+	confirm(lapsed, time.Now().Add(-time.Minute))
+	old.CreatePayment() // pending
+
+	var export bytes.Buffer
+	if err := old.ExportGrants(&export); err != nil {
+		t.Fatalf("ExportGrants() error = %v", err)
+	}
+
+	rebuilt := newPaywall()
+	summary, err := rebuilt.ImportGrants(bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatalf("ImportGrants() error = %v", err)
+	}
+	if summary != (GrantImportSummary{Payments: 2, APIKeys: 1}) {
+		t.Errorf("ImportGrants() = %+v, want 2 payments and 1 API key", summary)
+	}
+	if again, _ := rebuilt.ImportGrants(bytes.NewReader(export.Bytes())); again != (GrantImportSummary{Skipped: 3}) {
+		t.Errorf("repeated ImportGrants() = %+v, want everything skipped", again)
+	}
+
+	handler := rebuilt.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served", "1")
+	}))
+	tests := []struct {
+		name       string
+		setup      func(r *http.Request)
+		wantServed bool
+	}{
+		{"payment cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "payment_id", Value: active.ID}) }, true},
+		{"API key", func(r *http.Request) { r.Header.Set(DefaultAPIKeyHeader, apiKey) }, true},
+		{"lapsed payment cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "payment_id", Value: lapsed.ID}) }, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/content", nil)
+		tt.setup(req)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if served := rec.Header().Get("X-Served") != ""; served != tt.wantServed {
+			t.Errorf("%s: served = %v, want %v", tt.name, served, tt.wantServed)
+		}
+	}
+}
+
+func TestImportGrants_Invalid(t *testing.T) {
+	pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore()})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	for _, doc := range []string{`not json`, `{"version":99,"payments":[]}`, `{"version":1,"payments":[{"id":""}]}`} {
+		if _, err := pw.ImportGrants(bytes.NewReader([]byte(doc))); err == nil {
+			t.Errorf("ImportGrants(%s) error = nil", doc)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// GraphQL error codes reported in the "code" extension of GraphQLPaymentError
+const (
+	// GraphQLCodePaymentRequired means the field needs a confirmed payment;
+	// the "payment" extension says how to pay
+	GraphQLCodePaymentRequired = "PAYMENT_REQUIRED"
+	// GraphQLCodeInsufficientCredits means a metered API key cannot cover the
+	// field's cost; the "credits" extension holds the balance
+	GraphQLCodeInsufficientCredits = "INSUFFICIENT_CREDITS"
+	// GraphQLCodeRateLimited means the client must wait "retry_after" seconds
+	GraphQLCodeRateLimited = "RATE_LIMITED"
+)
+
+// ErrNoGraphQLGate is returned by RequireGraphQLPayment for contexts that do
+// not come from a request served through GraphQLMiddleware
+var ErrNoGraphQLGate = errors.New("request not served through GraphQLMiddleware")
+
+// GraphQLPaymentDetails describes the payment a client must make, in the
+// "payment" extension of a PAYMENT_REQUIRED error
+type GraphQLPaymentDetails struct {
+	// ID is the payment ID, also set as the payment cookie
+	ID string `json:"id"`
+	// Status is StatusPending, or StatusDetected while the transaction confirms
+	Status PaymentStatus `json:"status"`
+	// ExpiresAt is when the payment expires
+	ExpiresAt time.Time `json:"expires_at"`
+	// Addresses are the addresses to pay to, one of them is enough
+	Addresses map[wallet.WalletType]string `json:"addresses"`
+	// Amounts are the amounts to pay per currency
+	Amounts map[wallet.WalletType]float64 `json:"amounts"`
+	// URIs are bitcoin: and monero: payment URIs with address and amount
+	URIs map[wallet.WalletType]string `json:"uris,omitempty"`
+	// StatusURL is Config.StatusPath, which reports when the payment confirms
+	StatusURL string `json:"status_url,omitempty"`
+}
+
+// GraphQLPaymentError is returned by RequireGraphQLPayment when a field may not
+// be resolved yet. Its Extensions method is picked up by gqlgen
+// (graphql.ExtendedError) and graph-gophers/graphql-go, so the error reaches
+// clients as
+//
+//	{"message": "payment required", "path": [...],
+//	 "extensions": {"code": "PAYMENT_REQUIRED", "payment": {...}}}
+type GraphQLPaymentError struct {
+	// Code is one of the GraphQLCode constants
+	Code string
+	// Payment is set for GraphQLCodePaymentRequired
+	Payment *GraphQLPaymentDetails
+	// Credits is the key's balance for GraphQLCodeInsufficientCredits
+	Credits int64
+	// RetryAfter is set for GraphQLCodeRateLimited
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *GraphQLPaymentError) Error() string {
+	switch e.Code {
+	case GraphQLCodeInsufficientCredits:
+		return "insufficient credits"
+	case GraphQLCodeRateLimited:
+		return "too many requests, please try again later"
+	}
+	return "payment required"
+}
+
+// Extensions returns the GraphQL error extensions
+func (e *GraphQLPaymentError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.Code}
+	switch e.Code {
+	case GraphQLCodePaymentRequired:
+		ext["payment"] = e.Payment
+	case GraphQLCodeInsufficientCredits:
+		ext["credits"] = e.Credits
+	case GraphQLCodeRateLimited:
+		ext["retry_after"] = int(math.Ceil(e.RetryAfter.Seconds()))
+	}
+	return ext
+}
+
+// graphQLGateKey is the context key of the request's graphQLGate
+type graphQLGateKey struct{}
+
+// graphQLGate decides once per GraphQL request whether its paid fields may be
+// resolved; all fields of a request share one payment
+type graphQLGate struct {
+	p *Paywall
+	w http.ResponseWriter
+	r *http.Request
+
+	mu sync.Mutex
+	// payment is the request's payment once looked up or created
+	payment *Payment
+	// key is the request's API key once authenticated
+	key *APIKey
+}
+
+// GraphQLMiddleware prepares a GraphQL endpoint for field-level pricing. It
+// never blocks a request itself: queries of free fields run as usual, and
+// resolvers of priced fields call RequireGraphQLPayment, which checks the
+// caller's payment cookie or API key. Path-based Middleware cannot tell a
+// free query from a paid one, as they share the same URL.
+//
+// With gqlgen, price fields with a directive:
+//
+//	directive @paid(cost: Int = 1) on FIELD_DEFINITION
+//
+//	cfg.Directives.Paid = func(ctx context.Context, obj interface{}, next graphql.Resolver, cost int) (interface{}, error) {
+//		if err := paywall.RequireGraphQLPayment(ctx, int64(cost)); err != nil {
+//			return nil, err
+//		}
+//		return next(ctx)
+//	}
+//	http.Handle("/graphql", pw.GraphQLMiddleware(srv))
+//
+// The handler must not write response headers before the fields are
+// resolved, as the payment cookie is set on the response by the first priced
+// field; both gqlgen and graphql-go write the response afterwards.
+//
+// Parameters:
+//   - next: The GraphQL handler
+//
+// Returns:
+//   - http.Handler: next with the request's credentials in its context
+//
+// Related: RequireGraphQLPayment, GraphQLPaymentError
+func (p *Paywall) GraphQLMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gate := &graphQLGate{p: p, w: w, r: r}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), graphQLGateKey{}, gate)))
+	})
+}
+
+// RequireGraphQLPayment checks that the GraphQL request of ctx may resolve a
+// priced field: its payment cookie holds a confirmed payment, or its API key
+// is valid. Metered API keys are charged cost credits per call, so a query
+// selecting two priced fields pays for both; payments unlock every priced field.
+//
+// Callers without access get a GraphQLPaymentError. Unless the cookie holds a
+// payment that is still payable, a payment is created for the request (once,
+// however many fields are priced) and its cookie set, so browser clients can
+// pay and repeat the query.
+//
+// Parameters:
+//   - ctx: The resolver context, derived from a request served by GraphQLMiddleware
+//   - cost: Credits charged to metered API keys; negative values are treated as 0
+//
+// Returns:
+//   - error: nil if the field may be resolved, a *GraphQLPaymentError, or
+//     ErrNoGraphQLGate, ErrInvalidAPIKey, ErrAPIKeyUsageExhausted,
+//     ErrReadOnlyMode, ErrStoreTimeout or a storage error
+func RequireGraphQLPayment(ctx context.Context, cost int64) error {
+	gate, ok := ctx.Value(graphQLGateKey{}).(*graphQLGate)
+	if !ok {
+		return ErrNoGraphQLGate
+	}
+	if cost < 0 {
+		cost = 0
+	}
+	return gate.require(cost)
+}
+
+// require implements RequireGraphQLPayment
+func (g *graphQLGate) require(cost int64) error {
+	p := g.p
+	if p.Mode() == ModeBypass {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if p.apiKeys != nil {
+		if rawKey := g.r.Header.Get(p.apiKeyHeader); rawKey != "" {
+			return g.requireAPIKey(rawKey, cost)
+		}
+	}
+
+	now := time.Now()
+	if g.payment == nil {
+		if paymentID, err := paymentIDFromCookie(g.r); err == nil {
+			payment, err := p.getPaymentInBudget(g.r.Context(), paymentID)
+			if err != nil {
+				return fmt.Errorf("get payment: %w", err)
+			}
+			// Payments of routes priced with WithRoute are not valid here
+			if payment != nil && payment.Route == "" {
+				g.payment = payment
+			}
+		}
+	}
+	if g.payment != nil {
+		if g.payment.GrantsAccess(now) {
+			return nil
+		}
+		if (g.payment.Status == StatusPending || g.payment.Status == StatusDetected) && now.Before(g.payment.ExpiresAt) {
+			return p.graphQLPaymentRequired(g.payment)
+		}
+	}
+
+	payment, fingerprint := p.claimPendingPayment(g.r, "")
+	if payment == nil {
+		if ok, retryAfter := p.takeRequestToken(g.r, RateLimitCreate); !ok {
+			return &GraphQLPaymentError{Code: GraphQLCodeRateLimited, RetryAfter: retryAfter}
+		}
+		var err error
+		payment, err = p.createPayment(g.r, "", nil)
+		if err != nil {
+			return err
+		}
+		p.rememberPendingPayment(fingerprint, payment.ID)
+	}
+	g.payment = payment
+	p.setPaymentCookie(g.w, g.r, payment.ID)
+	return p.graphQLPaymentRequired(payment)
+}
+
+// requireAPIKey authenticates the request's API key, charging metered keys
+// cost credits. Keys that are not metered are authenticated once per request.
+func (g *graphQLGate) requireAPIKey(rawKey string, cost int64) error {
+	if g.key != nil && !g.key.Metered {
+		return nil
+	}
+	key, retryAfter, err := g.p.authenticateAPIKey(rawKey, cost)
+	switch {
+	case err == nil:
+		g.key = key
+		return nil
+	case errors.Is(err, ErrInsufficientCredits):
+		return &GraphQLPaymentError{Code: GraphQLCodeInsufficientCredits, Credits: key.Credits}
+	case errors.Is(err, ErrAPIKeyRateLimited):
+		return &GraphQLPaymentError{Code: GraphQLCodeRateLimited, RetryAfter: retryAfter}
+	}
+	return err
+}
+
+// graphQLPaymentRequired returns the PAYMENT_REQUIRED error for payment
+func (p *Paywall) graphQLPaymentRequired(payment *Payment) *GraphQLPaymentError {
+	details := &GraphQLPaymentDetails{
+		ID:        payment.ID,
+		Status:    payment.Status,
+		ExpiresAt: payment.ExpiresAt,
+		Addresses: make(map[wallet.WalletType]string, len(payment.Addresses)),
+		Amounts:   make(map[wallet.WalletType]float64, len(payment.Amounts)),
+		URIs:      make(map[wallet.WalletType]string, len(payment.Addresses)),
+		StatusURL: p.statusPath,
+	}
+	for currency, address := range payment.Addresses {
+		if address == "" {
+			continue
+		}
+		details.Addresses[currency] = address
+		details.Amounts[currency] = payment.Amounts[currency]
+		if uri, err := paymentURI(currency, address, payment.Amounts[currency], p.paymentLabel); err == nil {
+			details.URIs[currency] = uri
+		}
+	}
+	return &GraphQLPaymentError{Code: GraphQLCodePaymentRequired, Payment: details}
+}
@@ -0,0 +1,131 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// graphQLFields serves a fake GraphQL request resolving two priced fields of
+// cost each and returns their errors
+func graphQLFields(pw *Paywall, req *http.Request, cost int64) (*httptest.ResponseRecorder, []error) {
+	var errs []error
+	handler := pw.GraphQLMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			errs = append(errs, RequireGraphQLPayment(r.Context(), cost))
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, errs
+}
+
+func TestRequireGraphQLPayment_NewVisitor(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		StatusPath:     "/paywall/status",
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+
+	rec, errs := graphQLFields(pw, httptest.NewRequest(http.MethodPost, "/graphql", nil), 1)
+	var first, second *GraphQLPaymentError
+	if !errors.As(errs[0], &first) || !errors.As(errs[1], &second) {
+		t.Fatalf("errors = %v, want GraphQLPaymentErrors", errs)
+	}
+	if first.Code != GraphQLCodePaymentRequired || first.Payment == nil {
+		t.Fatalf("error = %+v, want PAYMENT_REQUIRED with payment details", first)
+	}
+	if second.Payment.ID != first.Payment.ID {
+		t.Errorf("fields got payments %s and %s, want one per request", first.Payment.ID, second.Payment.ID)
+	}
+	details := first.Payment
+	if details.Addresses[wallet.Bitcoin] == "" || details.Amounts[wallet.Bitcoin] != 0.001 {
+		t.Errorf("payment details = %+v, want BTC address and amount", details)
+	}
+	if !strings.HasPrefix(details.URIs[wallet.Bitcoin], "bitcoin:") || details.StatusURL != "/paywall/status" {
+		t.Errorf("payment details = %+v, want bitcoin: URI and status URL", details)
+	}
+	ext := first.Extensions()
+	if ext["code"] != GraphQLCodePaymentRequired || ext["payment"] != details {
+		t.Errorf("Extensions() = %v", ext)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != details.ID {
+		t.Fatalf("cookies = %v, want payment cookie for %s", cookies, details.ID)
+	}
+
+	// The same payment is reported until it confirms, then fields resolve
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.AddCookie(cookies[0])
+	if _, errs := graphQLFields(pw, req, 1); !errors.As(errs[0], &first) || first.Payment.ID != details.ID {
+		t.Errorf("error for pending payment = %v, want PAYMENT_REQUIRED for %s", errs[0], details.ID)
+	}
+	payment, _ := pw.Store.GetPayment(details.ID)
+	payment.Status = StatusConfirmed
+	payment.Confirmations = 1
+	pw.Store.UpdatePayment(payment)
+	if _, errs := graphQLFields(pw, req, 1); errs[0] != nil || errs[1] != nil {
+		t.Errorf("errors for confirmed payment = %v, want none", errs)
+	}
+
+	// Bypass mode resolves everything
+	pw.SetMode(ModeBypass)
+	if _, errs := graphQLFields(pw, httptest.NewRequest(http.MethodPost, "/graphql", nil), 1); errs[0] != nil {
+		t.Errorf("error in bypass mode = %v, want none", errs[0])
+	}
+}
+
+func TestRequireGraphQLPayment_MeteredAPIKey(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:        0.001,
+		PaymentTimeout:    time.Hour,
+		TestNet:           true,
+		Store:             NewMemoryStore(),
+		APIKeysEnabled:    true,
+		CreditsPerPayment: 5,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+	key, _, err := pw.IssueAPIKey(confirmedTestPayment(t, pw).ID, APIKeyOptions{Metered: true})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set(DefaultAPIKeyHeader, key)
+	_, errs := graphQLFields(pw, req, 3)
+	if errs[0] != nil {
+		t.Fatalf("first field error = %v, want none", errs[0])
+	}
+	var gqlErr *GraphQLPaymentError
+	if !errors.As(errs[1], &gqlErr) || gqlErr.Code != GraphQLCodeInsufficientCredits || gqlErr.Credits != 2 {
+		t.Errorf("second field error = %v, want INSUFFICIENT_CREDITS with 2 credits", errs[1])
+	}
+
+	req.Header.Set(DefaultAPIKeyHeader, "pk_invalid")
+	if _, errs := graphQLFields(pw, req, 1); !errors.Is(errs[0], ErrInvalidAPIKey) {
+		t.Errorf("error for invalid key = %v, want ErrInvalidAPIKey", errs[0])
+	}
+}
+
+func TestRequireGraphQLPayment_NoGate(t *testing.T) {
+	if err := RequireGraphQLPayment(context.Background(), 1); !errors.Is(err, ErrNoGraphQLGate) {
+		t.Errorf("RequireGraphQLPayment() = %v, want ErrNoGraphQLGate", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -98,13 +99,24 @@ func parseWalletType(value string) (wallet.WalletType, error) {
 //   - Payment amount in BTC
 //   - Payment expiration time
 //   - QR code for the payment address
+//   - Copyable address blocks and server-rendered QR images as a no-JS/CSP fallback
 //
 // Error handling:
-//   - QR code library loading failures result in QR code feature being disabled
-//   - Template rendering failures return 500 Internal Server Error
+//   - QR code script loading failures are logged and the page falls back to
+//     server-rendered QR images (linked to HandleQRCode when Config.QRCodePath is set)
+//   - Template execution failures are logged and the built-in minimal page
+//     (addresses, amounts and expiry) is served instead; 500 Internal Server
+//     Error is only returned if that page fails as well
 //
 // Related types: Payment, PaymentPageData, template.Template
 func (p *Paywall) renderPaymentPage(w http.ResponseWriter, payment *Payment) {
+	p.renderPaymentPageFor(w, nil, payment)
+}
+
+// renderPaymentPageFor is renderPaymentPage for a protected request r, whose
+// method tells the page's status poller whether it can replay the request.
+// r may be nil.
+func (p *Paywall) renderPaymentPageFor(w http.ResponseWriter, r *http.Request, payment *Payment) {
 	// Ensure logger is initialized for safety in tests
 	if p.logger == nil {
 		p.logger = NewStructuredLogger(io.Discard, LogLevelError, true)
@@ -113,58 +125,187 @@ func (p *Paywall) renderPaymentPage(w http.ResponseWriter, payment *Payment) {
 	if invalidPayment := p.validatePaymentData(payment, w); invalidPayment {
 		return
 	}
-	qrCodeJsBytes, err := QrcodeJs.ReadFile("static/qrcode.min.js")
+	page, err := p.executePaymentPage(r, payment)
 	if err != nil {
 		p.logger.log(LogEntry{
-			Level:   LogLevelError,
-			Event:   "qrcode_load_failed",
-			Message: fmt.Sprintf("Failed to load QR code JavaScript: %v", err),
+			Level:     LogLevelError,
+			Event:     "template_render_failed",
+			Message:   fmt.Sprintf("Failed to render payment page: %v", err),
+			PaymentID: payment.ID,
 		})
-		http.Error(w, "QR Code Error", http.StatusInternalServerError)
-		qrCodeJsBytes = []byte("")
-		// don't return here, let people manually type in the address
-		// !return
+		http.Error(w, "Failed to render payment page", http.StatusInternalServerError)
+		return
 	}
-	// Properly format the Javascript bytes for inclusion in the HTML template as a <script>
-	qrCodeJsString := template.JS(qrCodeJsBytes)
-	// Prepare template data
+
+	// The page shows a specific payment at a specific time; caches must never
+	// hand it to the visitor again
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// paymentPageData builds the payment page's template data for payment, shown
+// for the protected request r (which may be nil)
+func (p *Paywall) paymentPageData(r *http.Request, payment *Payment) PaymentPageData {
+	data, err := newPaymentPageData(payment, p.paymentLabel)
+	if err != nil {
+		// Degrade deliberately: the page still renders with server-side QR images
+		// and copyable addresses, so the visitor can pay without the script
+		p.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "qrcode_script_unavailable",
+			Message:   fmt.Sprintf("QR code script unavailable, using server-rendered fallback: %v", err),
+			PaymentID: payment.ID,
+		})
+	}
+	p.hideDisabledCurrencies(&data)
+	if p.qrCodePath != "" {
+		data.BTCQRCode = p.qrCodeURL(wallet.Bitcoin, data.BTCAddress, data.AmountBTC)
+		data.XMRQRCode = p.qrCodeURL(wallet.Monero, data.XMRAddress, data.AmountXMR)
+		data.LNQRCode = p.qrCodeURL(wallet.Lightning, data.LNInvoice, data.AmountLN)
+		data.ETHQRCode = p.qrCodeURL(wallet.Ethereum, data.ETHAddress, data.AmountETH)
+		for i, coin := range data.Coins {
+			data.Coins[i].QRCode = p.qrCodeURL(coin.Currency, coin.Address, coin.Amount)
+		}
+	}
+	if p.assetPath != "" {
+		if scriptURL := p.assetURL(qrcodeScriptAsset); scriptURL != "" {
+			data.QrcodeScriptURL = scriptURL
+			data.QrcodeJs = ""
+			data.QRScriptUnavailable = false
+		}
+	}
+	if p.btcTxSubmitPath != "" && data.BTCAddress != "" {
+		data.BTCTxSubmitURL = p.btcTxSubmitPath
+	}
+	if p.faucetURL != "" && p.faucetPath != "" {
+		data.FaucetURL = p.faucetPath
+	}
+	if p.refunds != nil && p.refunds.addressPath != "" {
+		data.RefundURL = p.refunds.addressPath
+		data.RefundCurrencies = refundCurrencies(&data)
+		data.RefundAddresses = payment.RefundAddresses
+	}
+	if p.statusPath != "" {
+		data.StatusURL = p.statusPath
+		data.StatusPollMillis = p.statusPollInterval.Milliseconds()
+	}
+	if r != nil {
+		data.RequestMethod = r.Method
+		platform := mobilePlatform(r.UserAgent())
+		data.BTCWalletLinks = p.walletLinks(platform, wallet.Bitcoin, data.BTCAddress, data.AmountBTC)
+		data.XMRWalletLinks = p.walletLinks(platform, wallet.Monero, data.XMRAddress, data.AmountXMR)
+	}
+	data.Maintenance = p.Mode() == ModeReadOnly
+	if estimate := p.fiatEstimate(payment); estimate != nil {
+		data.FiatCurrency = estimate.Currency
+		if data.BTCAddress != "" {
+			data.FiatBTC = estimate.Amounts[wallet.Bitcoin]
+		}
+		if data.XMRAddress != "" {
+			data.FiatXMR = estimate.Amounts[wallet.Monero]
+		}
+		if data.LNInvoice != "" {
+			data.FiatLN = estimate.Amounts[wallet.Lightning]
+		}
+		if data.ETHAddress != "" {
+			data.FiatETH = estimate.Amounts[wallet.Ethereum]
+		}
+		for i := range data.Tokens {
+			data.Tokens[i].Fiat = estimate.Amounts[data.Tokens[i].Currency]
+		}
+		for i := range data.Coins {
+			data.Coins[i].Fiat = estimate.Amounts[data.Coins[i].Currency]
+		}
+	}
+	if payment.MultisigEnabled {
+		data.MultisigRole = p.multisigRole
+	}
+	if p.signer != nil {
+		now := time.Now()
+		data.PageNonce = p.pageNonce(payment, now)
+		data.PageIssuedMillis = now.UnixMilli()
+		data.PageMaxAgeMillis = pageNonceMaxAge.Milliseconds()
+	}
+	if p.pageDataHook != nil {
+		p.pageDataHook(r, payment, &data)
+	}
+	return data
+}
+
+// NewPaymentPageData builds the template data for a payment's checkout page
+// Parameters:
+//   - payment: Payment record containing address and amount information
+//
+// Returns:
+//   - PaymentPageData: Template data with addresses, amounts, expiry, QR code script
+//     and server-rendered QR images (inline data: URIs)
+//   - error: If the embedded QR code library cannot be loaded; the returned data is
+//     still usable, omits the QR code script and sets QRScriptUnavailable
+//
+// This is the same data renderPaymentPage passes to the payment template, exported
+// so tools such as cmd/paywall-preview can render templates against fake payments.
+// Instance-specific values (e.g. the configured multisig role and PaymentLabel)
+// are not included.
+//
+// Related types: Payment, PaymentPageData
+func NewPaymentPageData(payment *Payment) (PaymentPageData, error) {
+	return newPaymentPageData(payment, "")
+}
+
+// newPaymentPageData is NewPaymentPageData with payment URIs and QR codes
+// naming label as the recipient
+func newPaymentPageData(payment *Payment, label string) (PaymentPageData, error) {
 	data := PaymentPageData{
 		BTCAddress: payment.Addresses[wallet.Bitcoin],
 		AmountBTC:  payment.Amounts[wallet.Bitcoin],
 		XMRAddress: payment.Addresses[wallet.Monero],
 		AmountXMR:  payment.Amounts[wallet.Monero],
+		LNInvoice:  payment.Addresses[wallet.Lightning],
+		AmountLN:   payment.Amounts[wallet.Lightning],
+		ETHAddress: payment.Addresses[wallet.Ethereum],
+		AmountETH:  payment.Amounts[wallet.Ethereum],
+		Tokens:     tokenPaymentOptions(payment),
+		Coins:      coinPaymentOptions(payment, label),
 		ExpiresAt:  payment.ExpiresAt.Format(time.RFC3339),
 		PaymentID:  payment.ID,
-		QrcodeJs:   qrCodeJsString,
+	}
+	data.AmountLNSats = math.Round(data.AmountLN*1e11) / 1e3
+	if payment.Status == StatusDetected {
+		data.Detected = true
+		data.DetectedTxID = payment.DetectedTxID
 	}
 
 	// Add multisig information if enabled
 	if payment.MultisigEnabled {
 		data.IsMultisig = true
 		// Determine multisig type from payment metadata
-		if len(payment.RequiredSignatures) > 0 {
-			// Find any wallet type to get signature requirements
-			for walletType, required := range payment.RequiredSignatures {
-				if metadata, ok := payment.MultisigMetadata[walletType]; ok {
-					total := len(metadata.PublicKeys)
-					data.MultisigType = fmt.Sprintf("%d-of-%d", required, total)
-					break
-				}
+		for walletType, required := range payment.RequiredSignatures {
+			if metadata, ok := payment.MultisigMetadata[walletType]; ok && metadata != nil {
+				data.MultisigType = fmt.Sprintf("%d-of-%d", required, len(metadata.PublicKeys))
+				break
 			}
 		}
-		data.MultisigRole = p.multisigRole
 		data.MultisigInstructions = "This is a multisig payment address. Funds sent to this address require multiple signatures to spend, providing additional security for escrow transactions."
 	}
 
-	if err := p.template.Execute(w, data); err != nil {
-		p.logger.log(LogEntry{
-			Level:   LogLevelError,
-			Event:   "template_render_failed",
-			Message: fmt.Sprintf("Failed to render payment page: %v", err),
-		})
-		http.Error(w, "Failed to render payment page", http.StatusInternalServerError)
-		return
+	data.BTCPaymentURI = paymentLink(wallet.Bitcoin, data.BTCAddress, data.AmountBTC, label)
+	data.XMRPaymentURI = paymentLink(wallet.Monero, data.XMRAddress, data.AmountXMR, label)
+	data.BTCQRCode = qrCodeDataURI(wallet.Bitcoin, data.BTCAddress, data.AmountBTC, label)
+	data.XMRQRCode = qrCodeDataURI(wallet.Monero, data.XMRAddress, data.AmountXMR, label)
+	data.LNPaymentURI = paymentLink(wallet.Lightning, data.LNInvoice, data.AmountLN, label)
+	data.LNQRCode = qrCodeDataURI(wallet.Lightning, data.LNInvoice, data.AmountLN, label)
+	data.ETHPaymentURI = paymentLink(wallet.Ethereum, data.ETHAddress, data.AmountETH, label)
+	data.ETHQRCode = qrCodeDataURI(wallet.Ethereum, data.ETHAddress, data.AmountETH, label)
+
+	qrCodeJsBytes, err := QrcodeJs.ReadFile("static/" + qrcodeScriptAsset)
+	if err != nil {
+		data.QRScriptUnavailable = true
+		return data, err
 	}
+	// Properly format the Javascript bytes for inclusion in the HTML template as a <script>
+	data.QrcodeJs = template.JS(qrCodeJsBytes)
+	return data, nil
 }
 
 // validatePaymentData checks if the payment data is valid before rendering the payment page
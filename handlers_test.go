@@ -2,6 +2,7 @@
 package paywall
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"html/template"
@@ -217,9 +218,11 @@ func TestPaywall_renderPaymentPage_NilMaps(t *testing.T) {
 
 func TestPaywall_renderPaymentPage_TemplateError(t *testing.T) {
 	// Create paywall with invalid template that will fail during execution
-	invalidTemplate, _ := template.New("invalid").Parse("{{.NonExistentField}}")
+	invalidTemplate, _ := template.New("invalid").Parse("<h1>Custom</h1>{{.NonExistentField}}")
+	var logs bytes.Buffer
 	paywall := &Paywall{
 		template: invalidTemplate,
+		logger:   NewStructuredLogger(&logs, LogLevelError, true),
 		prices: map[wallet.WalletType]float64{
 			wallet.Bitcoin: 0.001,
 			wallet.Monero:  0.01,
@@ -231,12 +234,24 @@ func TestPaywall_renderPaymentPage_TemplateError(t *testing.T) {
 
 	paywall.renderPaymentPage(recorder, payment)
 
-	if recorder.Code != http.StatusInternalServerError {
-		t.Errorf("renderPaymentPage() with template error status = %v, want %v", recorder.Code, http.StatusInternalServerError)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("renderPaymentPage() with template error status = %v, want %v", recorder.Code, http.StatusOK)
 	}
-
-	if !strings.Contains(recorder.Body.String(), "Failed to render payment page") {
-		t.Error("renderPaymentPage() should return 'Failed to render payment page' error message")
+	body := recorder.Body.String()
+	if strings.Contains(body, "Custom") {
+		t.Error("partial output of the failed template reached the response")
+	}
+	for _, want := range []string{
+		payment.Addresses[wallet.Bitcoin], "0.001",
+		payment.Addresses[wallet.Monero], "0.01",
+		payment.ID,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fallback page does not contain %q", want)
+		}
+	}
+	if !strings.Contains(logs.String(), "template_render_failed") || !strings.Contains(logs.String(), payment.ID) {
+		t.Errorf("template error was not logged with the payment ID: %s", logs.String())
 	}
 }
 
@@ -642,3 +657,65 @@ func BenchmarkPaywall_renderPaymentPage(b *testing.B) {
 		paywall.renderPaymentPage(recorder, payment)
 	}
 }
+
+func TestNewPaymentPageData(t *testing.T) {
+	payment := createHandlerTestPayment()
+
+	data, err := NewPaymentPageData(payment)
+	if err != nil {
+		t.Fatalf("NewPaymentPageData() error = %v", err)
+	}
+	if data.BTCAddress != payment.Addresses[wallet.Bitcoin] || data.XMRAddress != payment.Addresses[wallet.Monero] {
+		t.Errorf("NewPaymentPageData() addresses = %q/%q, want payment addresses", data.BTCAddress, data.XMRAddress)
+	}
+	if data.AmountBTC != 0.001 || data.AmountXMR != 0.01 {
+		t.Errorf("NewPaymentPageData() amounts = %v/%v, want 0.001/0.01", data.AmountBTC, data.AmountXMR)
+	}
+	if data.ExpiresAt != payment.ExpiresAt.Format(time.RFC3339) {
+		t.Errorf("NewPaymentPageData() ExpiresAt = %q, want RFC3339 expiry", data.ExpiresAt)
+	}
+	if data.QrcodeJs == "" {
+		t.Error("NewPaymentPageData() should embed the QR code script")
+	}
+	if data.IsMultisig {
+		t.Error("NewPaymentPageData() IsMultisig = true for single-signature payment")
+	}
+
+	payment.MultisigEnabled = true
+	payment.RequiredSignatures = map[wallet.WalletType]int{wallet.Bitcoin: 2}
+	payment.MultisigMetadata = map[wallet.WalletType]*wallet.MultisigMetadata{
+		wallet.Bitcoin: {PublicKeys: [][]byte{{1}, {2}, {3}}},
+	}
+	data, _ = NewPaymentPageData(payment)
+	if !data.IsMultisig || data.MultisigType != "2-of-3" {
+		t.Errorf("NewPaymentPageData() multisig = %v %q, want true \"2-of-3\"", data.IsMultisig, data.MultisigType)
+	}
+}
+
+func TestPaywall_renderPaymentPage_PageDataHook(t *testing.T) {
+	pw := createTestPaywall()
+	pw.template = template.Must(template.New("payment").Parse(
+		`<h1>{{.Extra.Title}}</h1><a href="{{.Extra.ReturnURL}}">back</a><p>{{.PaymentID}}</p>`))
+	var gotPayment *Payment
+	pw.pageDataHook = func(r *http.Request, payment *Payment, data *PaymentPageData) {
+		gotPayment = payment
+		data.Extra = map[string]any{
+			"Title":     "Premium: " + r.URL.Query().Get("article"),
+			"ReturnURL": r.URL.Path,
+		}
+	}
+
+	payment := createHandlerTestPayment()
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/articles/42?article=Go", nil), payment)
+
+	if gotPayment != payment {
+		t.Error("hook did not receive the rendered payment")
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"<h1>Premium: Go</h1>", `href="/articles/42"`, payment.ID} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
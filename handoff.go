@@ -0,0 +1,109 @@
+package paywall
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// DefaultHandoffHeaderPrefix prefixes the request headers set by WithUpstreamHandoff
+const DefaultHandoffHeaderPrefix = "X-Paywall-"
+
+// Values of the <prefix>Status header set by WithUpstreamHandoff
+const (
+	// HandoffStatusPaid marks requests with a confirmed, unexpired payment
+	HandoffStatusPaid = "paid"
+	// HandoffStatusPending marks requests whose payment has not been received
+	HandoffStatusPending = "pending"
+	// HandoffStatusDetected marks requests whose payment awaits confirmations
+	HandoffStatusDetected = "detected"
+	// HandoffStatusFree marks requests served without payment: methods the
+	// route does not charge for, the metered free allowance, ModeBypass and
+	// store timeouts under StoreFailOpen
+	HandoffStatusFree = "free"
+)
+
+// WithUpstreamHandoff forwards unpaid requests to the protected handler instead
+// of answering them with the payment page, so an upstream application (for
+// example behind the reverse proxy) can render its own teaser or paywall.
+// The paywall still creates and tracks the visitor's payment and sets its
+// cookie; the request carries the payment details in headers:
+//
+//	X-Paywall-Status:      paid, pending, detected or free
+//	X-Paywall-Payment-Id:  the payment ID
+//	X-Paywall-Expires-At:  RFC 3339 time the unpaid payment expires
+//	X-Paywall-Btc-Address, X-Paywall-Btc-Amount: Bitcoin payment details
+//	X-Paywall-Xmr-Address, X-Paywall-Xmr-Amount: Monero payment details, if offered
+//
+// Paid requests are forwarded with X-Paywall-Status: paid and the payment ID,
+// requests served for free with X-Paywall-Status: free.
+// Headers under the prefix sent by the client are always removed, so the
+// upstream can trust them. The upstream's response, including its status code
+// (typically 402 for its own paywall page), is passed through unchanged.
+//
+// Responders configured with WithStatusResponse take precedence for their
+// status, and WithTeaser is not used for requests that are handed off.
+//
+// Parameters:
+//   - prefix: Header name prefix, "" for DefaultHandoffHeaderPrefix
+func WithUpstreamHandoff(prefix string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if prefix == "" {
+			prefix = DefaultHandoffHeaderPrefix
+		}
+		cfg.handoffPrefix = http.CanonicalHeaderKey(prefix)
+	}
+}
+
+// handoff forwards an unpaid request to next with the payment's details when
+// the route hands unpaid requests off
+//
+// Returns:
+//   - bool: true if the request was forwarded
+func (p *Paywall) handoff(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, payment *Payment, next http.Handler) bool {
+	if cfg.handoffPrefix == "" {
+		return false
+	}
+	if _, ok := cfg.responders[payment.Status]; ok {
+		return false
+	}
+	status := HandoffStatusPending
+	if payment.Status == StatusDetected {
+		status = HandoffStatusDetected
+	}
+	r = cfg.withHandoffHeaders(r, status, payment.ID)
+	prefix := cfg.handoffPrefix
+	r.Header.Set(prefix+"Expires-At", payment.ExpiresAt.UTC().Format(time.RFC3339))
+	for currency, name := range map[wallet.WalletType]string{wallet.Bitcoin: "Btc", wallet.Monero: "Xmr"} {
+		if address := payment.Addresses[currency]; address != "" {
+			r.Header.Set(prefix+name+"-Address", address)
+			r.Header.Set(prefix+name+"-Amount", strconv.FormatFloat(payment.Amounts[currency], 'f', -1, 64))
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	next.ServeHTTP(w, r)
+	return true
+}
+
+// withHandoffHeaders returns a copy of r without client-supplied headers under
+// the handoff prefix and with the status and payment ID set. r is returned
+// unchanged when the route does not hand off requests.
+func (cfg *middlewareConfig) withHandoffHeaders(r *http.Request, status, paymentID string) *http.Request {
+	if cfg.handoffPrefix == "" {
+		return r
+	}
+	r = r.Clone(r.Context())
+	for name := range r.Header {
+		if strings.HasPrefix(name, cfg.handoffPrefix) {
+			r.Header.Del(name)
+		}
+	}
+	r.Header.Set(cfg.handoffPrefix+"Status", status)
+	if paymentID != "" {
+		r.Header.Set(cfg.handoffPrefix+"Payment-Id", paymentID)
+	}
+	return r
+}
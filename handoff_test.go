@@ -0,0 +1,92 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestMiddleware_UpstreamHandoff(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	var upstream http.Header
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Clone()
+		if r.Header.Get("X-Paywall-Status") != HandoffStatusPaid {
+			w.WriteHeader(http.StatusPaymentRequired)
+		}
+		w.Write([]byte("upstream page"))
+	}), WithUpstreamHandoff(""))
+
+	confirmed := confirmedTestPayment(t, pw)
+	tests := []struct {
+		name        string
+		cookie      string
+		wantCode    int
+		wantStatus  string
+		wantAddress bool
+	}{
+		{name: "new visitor", wantCode: http.StatusPaymentRequired, wantStatus: HandoffStatusPending, wantAddress: true},
+		{name: "paid visitor", cookie: confirmed.ID, wantCode: http.StatusOK, wantStatus: HandoffStatusPaid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream = nil
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: tt.cookie})
+			}
+			// Clients cannot forge the handoff headers
+			req.Header.Set("X-Paywall-Status", HandoffStatusPaid)
+			req.Header.Set("X-Paywall-Btc-Address", "forged")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if upstream == nil {
+				t.Fatal("request was not forwarded upstream")
+			}
+			if rec.Code != tt.wantCode || rec.Body.String() != "upstream page" {
+				t.Errorf("response = %d %q, want %d from upstream", rec.Code, rec.Body.String(), tt.wantCode)
+			}
+			if got := upstream.Get("X-Paywall-Status"); got != tt.wantStatus {
+				t.Errorf("X-Paywall-Status = %q, want %q", got, tt.wantStatus)
+			}
+			paymentID := upstream.Get("X-Paywall-Payment-Id")
+			if paymentID == "" {
+				t.Error("X-Paywall-Payment-Id missing")
+			}
+			address := upstream.Get("X-Paywall-Btc-Address")
+			if address == "forged" || (address != "") != tt.wantAddress {
+				t.Errorf("X-Paywall-Btc-Address = %q, want address %v", address, tt.wantAddress)
+			}
+			if !tt.wantAddress {
+				return
+			}
+			payment, _ := pw.Store.GetPayment(paymentID)
+			if payment == nil || payment.Addresses[wallet.Bitcoin] != address {
+				t.Errorf("X-Paywall-Btc-Address = %q does not belong to payment %s", address, paymentID)
+			}
+			if upstream.Get("X-Paywall-Btc-Amount") == "" || upstream.Get("X-Paywall-Expires-At") == "" {
+				t.Error("amount or expiry header missing")
+			}
+			if len(rec.Result().Cookies()) == 0 {
+				t.Error("payment cookie not set for a handed-off request")
+			}
+		})
+	}
+}
+
+func TestMiddleware_UpstreamHandoffResponderWins(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	forwarded := false
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = true
+	}), WithUpstreamHandoff("X-Shop-"), WithStatusResponse(StatusPending, StatusCodeResponder(http.StatusPaymentRequired, 0)))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if forwarded || rec.Code != http.StatusPaymentRequired {
+		t.Errorf("forwarded = %v, status = %d, want the StatusPending responder", forwarded, rec.Code)
+	}
+}
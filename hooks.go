@@ -0,0 +1,133 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// TransitionEvent identifies a payment lifecycle transition passed to hooks
+type TransitionEvent string
+
+const (
+	// TransitionCreate is a new payment about to be stored
+	TransitionCreate TransitionEvent = "create"
+	// TransitionDetect is a paying transaction seen before its confirmations
+	TransitionDetect TransitionEvent = "detect"
+	// TransitionConfirm is a payment about to grant access
+	TransitionConfirm TransitionEvent = "confirm"
+	// TransitionExpire is an unpaid payment the monitor found past its ExpiresAt
+	TransitionExpire TransitionEvent = "expire"
+)
+
+// ErrTransitionVetoed wraps the error of a hook that refused a transition
+var ErrTransitionVetoed = errors.New("payment transition vetoed")
+
+// PaymentTransition describes a lifecycle transition on its way through the hook chain
+type PaymentTransition struct {
+	// Event is the kind of transition
+	Event TransitionEvent
+	// From is the payment's current status, "" for TransitionCreate
+	From PaymentStatus
+	// To is the status the payment moves to
+	To PaymentStatus
+	// Payment is the payment in its current state; the transition is applied to it
+	// when the innermost handler runs. Hooks may enrich it before calling next.
+	Payment *Payment
+	// Currency, Amount and TxID describe the payment that caused a detection or
+	// confirmation; Amount is the balance or proven amount received
+	Currency wallet.WalletType
+	Amount   float64
+	TxID     string
+	// Request is the visitor's request for TransitionCreate from Middleware, nil otherwise
+	Request *http.Request
+}
+
+// TransitionFunc applies a transition, or passes it on to the next hook
+type TransitionFunc func(t *PaymentTransition) error
+
+// PaymentHook wraps the handling of payment transitions, like HTTP middleware.
+// A hook observes a transition, vetoes it by returning an error without calling
+// next, or lets it happen by calling next; code after next runs once the
+// transition was stored. Errors returned after next succeeded are logged and
+// do not undo the transition.
+//
+// Example (refuse confirmations for a blocked payment):
+//
+//	pw.Use(func(next paywall.TransitionFunc) paywall.TransitionFunc {
+//	    return func(t *paywall.PaymentTransition) error {
+//	        if t.Event == paywall.TransitionConfirm && blocked(t.Payment.ID) {
+//	            return errors.New("payment is blocked")
+//	        }
+//	        return next(t)
+//	    }
+//	})
+type PaymentHook func(next TransitionFunc) TransitionFunc
+
+// Use appends hook to the payment hook chain. Hooks run in the order they were
+// added, the first one outermost. Use is safe to call while the paywall serves
+// requests; transitions already in flight keep the chain they started with.
+//
+// Vetoed transitions:
+//   - TransitionCreate: CreatePayment returns ErrTransitionVetoed and Middleware
+//     answers 403 Forbidden
+//   - TransitionDetect: the submitted transaction is refused and the payment stays pending
+//   - TransitionConfirm: the payment keeps its status and grants no access; the
+//     monitor asks the hooks again on its next check
+//   - TransitionExpire: the payment keeps its status; it no longer shows a payment
+//     page, but the monitor keeps checking it and a late payment still confirms it
+func (p *Paywall) Use(hook PaymentHook) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.hooks = append(p.hooks[:len(p.hooks):len(p.hooks)], hook)
+}
+
+// transition runs t through the hook chain, with apply as the innermost handler
+// that changes and stores the payment
+//
+// Returns:
+//   - error: ErrTransitionVetoed wrapping the hook's error, or apply's error
+func (p *Paywall) transition(t *PaymentTransition, apply TransitionFunc) error {
+	p.hooksMu.RLock()
+	hooks := p.hooks
+	p.hooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return apply(t)
+	}
+
+	applied := false
+	var applyErr error
+	handler := func(t *PaymentTransition) error {
+		applyErr = apply(t)
+		applied = applyErr == nil
+		return applyErr
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		handler = hooks[i](handler)
+	}
+
+	err := handler(t)
+	switch {
+	case err == nil:
+		return nil
+	case applied:
+		p.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "payment_hook_failed",
+			Message:   fmt.Sprintf("Payment hook failed after %s transition: %v", t.Event, err),
+			PaymentID: t.Payment.ID,
+		})
+		return nil
+	case applyErr != nil && errors.Is(err, applyErr):
+		return err
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "payment_transition_vetoed",
+		Message:   fmt.Sprintf("Payment hook vetoed %s transition: %v", t.Event, err),
+		PaymentID: t.Payment.ID,
+	})
+	return fmt.Errorf("%w: %s: %w", ErrTransitionVetoed, t.Event, err)
+}
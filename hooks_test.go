@@ -0,0 +1,225 @@
+package paywall
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestUse_HookChainOrder(t *testing.T) {
+	pw := &Paywall{Store: NewMemoryStore(), logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+
+	var calls []string
+	record := func(name string) PaymentHook {
+		return func(next TransitionFunc) TransitionFunc {
+			return func(t *PaymentTransition) error {
+				calls = append(calls, name+" before")
+				err := next(t)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	pw.Use(record("outer"))
+	pw.Use(record("inner"))
+
+	payment := &Payment{ID: "chain", Status: StatusPending}
+	err := pw.transition(&PaymentTransition{Event: TransitionCreate, To: StatusPending, Payment: payment}, func(t *PaymentTransition) error {
+		calls = append(calls, "apply")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transition() error = %v", err)
+	}
+	want := "outer before,inner before,apply,inner after,outer after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+
+	// Errors after next do not undo the transition
+	pw.Use(func(next TransitionFunc) TransitionFunc {
+		return func(t *PaymentTransition) error {
+			if err := next(t); err != nil {
+				return err
+			}
+			return errors.New("audit log unavailable")
+		}
+	})
+	if err := pw.transition(&PaymentTransition{Event: TransitionCreate, Payment: payment}, func(*PaymentTransition) error { return nil }); err != nil {
+		t.Errorf("transition() with failing observer error = %v, want nil", err)
+	}
+
+	// Errors of the transition itself are not reported as vetoes
+	storeErr := errors.New("disk full")
+	err = pw.transition(&PaymentTransition{Event: TransitionCreate, Payment: payment}, func(*PaymentTransition) error { return storeErr })
+	if !errors.Is(err, storeErr) || errors.Is(err, ErrTransitionVetoed) {
+		t.Errorf("transition() with failing apply error = %v, want the apply error", err)
+	}
+}
+
+func TestUse_VetoCreate(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	// Allow-list: only visitors from 192.0.2.0/24 may start a payment
+	var seenRequest bool
+	pw.Use(func(next TransitionFunc) TransitionFunc {
+		return func(t *PaymentTransition) error {
+			if t.Event != TransitionCreate {
+				return next(t)
+			}
+			if t.Request == nil {
+				return errors.New("no request")
+			}
+			seenRequest = true
+			if !strings.HasPrefix(t.Request.RemoteAddr, "192.0.2.") {
+				return errors.New("visitor not allow-listed")
+			}
+			return next(t)
+		}
+	})
+
+	if _, err := pw.CreatePayment(); !errors.Is(err, ErrTransitionVetoed) {
+		t.Errorf("CreatePayment() error = %v, want ErrTransitionVetoed", err)
+	}
+
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantCode   int
+	}{
+		{"allowed", "192.0.2.10:4000", http.StatusOK},
+		{"refused", "198.51.100.7:4000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+	if !seenRequest {
+		t.Error("create hook did not receive the visitor's request")
+	}
+}
+
+func TestUse_MonitorTransitions(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:            store,
+		minConfirmations: 1,
+		logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	client := &mockCryptoClient{}
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: client,
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+
+	veto := map[TransitionEvent]bool{TransitionConfirm: true, TransitionExpire: true}
+	var seen []PaymentTransition
+	pw.Use(func(next TransitionFunc) TransitionFunc {
+		return func(t *PaymentTransition) error {
+			seen = append(seen, *t)
+			if veto[t.Event] {
+				return errors.New("held for review")
+			}
+			return next(t)
+		}
+	})
+
+	newPayment := func(id string, expiresAt time.Time) *Payment {
+		payment := &Payment{
+			ID:        id,
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: id + "-address"},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+			CreatedAt: time.Now().Add(-time.Hour),
+			ExpiresAt: expiresAt,
+			Status:    StatusPending,
+		}
+		store.CreatePayment(payment)
+		stored, _ := store.GetPayment(id)
+		return stored
+	}
+	status := func(id string) PaymentStatus {
+		payment, _ := store.GetPayment(id)
+		return payment.Status
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		client.balance = 0.001
+		newPayment("paid", time.Now().Add(time.Hour))
+
+		payment, _ := store.GetPayment("paid")
+		monitor.checkPayment(payment)
+		if got := status("paid"); got != StatusPending {
+			t.Fatalf("status after vetoed confirmation = %s, want pending", got)
+		}
+		last := seen[len(seen)-1]
+		if last.Event != TransitionConfirm || last.From != StatusPending || last.To != StatusConfirmed || last.Currency != wallet.Bitcoin || last.Amount != 0.001 {
+			t.Errorf("confirm transition = %+v", last)
+		}
+
+		veto[TransitionConfirm] = false
+		payment, _ = store.GetPayment("paid")
+		monitor.checkPayment(payment)
+		if got := status("paid"); got != StatusConfirmed {
+			t.Fatalf("status after confirmation = %s, want confirmed", got)
+		}
+
+		// Confirmed payments are not confirmed again
+		count := len(seen)
+		payment, _ = store.GetPayment("paid")
+		monitor.checkPayment(payment)
+		if len(seen) != count {
+			t.Errorf("hooks saw %d transitions for an already confirmed payment", len(seen)-count)
+		}
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		client.balance = 0
+		payment := newPayment("unpaid", time.Now().Add(-time.Minute))
+
+		monitor.checkPayment(payment)
+		if got := status("unpaid"); got != StatusPending {
+			t.Fatalf("status after vetoed expiry = %s, want pending", got)
+		}
+
+		veto[TransitionExpire] = false
+		payment, _ = store.GetPayment("unpaid")
+		monitor.checkPayment(payment)
+		if got := status("unpaid"); got != StatusExpired {
+			t.Fatalf("status after expiry = %s, want expired", got)
+		}
+
+		// A late payment still confirms an expired payment
+		client.balance = 0.001
+		payment, _ = store.GetPayment("unpaid")
+		monitor.checkPayment(payment)
+		if got := status("unpaid"); got != StatusConfirmed {
+			t.Errorf("status after late payment = %s, want confirmed", got)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package paywall
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// Benchmarks of the paths every request or monitor cycle takes. The sizes
+// mirror deployments with a busy store; TestPerformanceBudget compares a
+// selection of them with testdata/perf_budget.json.
+// Run with: go test -run '^$' -bench 'Middleware|CreatePayment|StoreLookup|MonitorCycle' -benchmem
+
+// hotPathStoreSizes are the store sizes benchmarked, in payments
+var hotPathStoreSizes = []int{10000, 100000}
+
+// newHotPathPaywall returns a quiet paywall on store for benchmarks
+func newHotPathPaywall(b *testing.B, store PaymentStore) *Paywall {
+	b.Helper()
+	pw, err := NewPaywall(Config{
+		PriceInBTC:        0.001,
+		PaymentTimeout:    time.Hour,
+		TestNet:           true,
+		Store:             store,
+		Logger:            NewStructuredLogger(io.Discard, LogLevelError, true),
+		DisableRateLimits: true,
+	})
+	if err != nil {
+		b.Fatalf("NewPaywall() error = %v", err)
+	}
+	b.Cleanup(pw.Close)
+	return pw
+}
+
+// seedPayments adds n payments to store, one in ten confirmed and the rest
+// pending, and returns the ID and address of a pending one in the middle
+func seedPayments(b *testing.B, store PaymentStore, n int) (string, string) {
+	b.Helper()
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		payment := &Payment{
+			ID:        fmt.Sprintf("%032x", i),
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: fmt.Sprintf("tb1qbench%d", i)},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+			CreatedAt: now,
+			ExpiresAt: now.Add(time.Hour),
+			Status:    StatusPending,
+		}
+		if i%10 == 0 {
+			payment.Status = StatusConfirmed
+		}
+		if err := store.CreatePayment(payment); err != nil {
+			b.Fatalf("CreatePayment() error = %v", err)
+		}
+	}
+	middle := n/2 + 1
+	return fmt.Sprintf("%032x", middle), fmt.Sprintf("tb1qbench%d", middle)
+}
+
+// benchMiddlewareAllow serves a request carrying a confirmed payment cookie
+func benchMiddlewareAllow(b *testing.B) {
+	pw := newHotPathPaywall(b, NewMemoryStore())
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		b.Fatal(err)
+	}
+	pw.markConfirmed(payment, time.Now())
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		b.Fatal(err)
+	}
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/article", nil)
+	req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+// benchMiddlewareNewPayment serves first visits: a payment is created and the
+// payment page rendered
+func benchMiddlewareNewPayment(b *testing.B) {
+	pw := newHotPathPaywall(b, NewMemoryStore())
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/article", nil))
+		if rec.Code != http.StatusOK {
+			b.Fatalf("status = %d, want the payment page", rec.Code)
+		}
+	}
+}
+
+// benchCreatePayment creates payments with fresh addresses
+func benchCreatePayment(b *testing.B) {
+	pw := newHotPathPaywall(b, NewMemoryStore())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pw.CreatePayment(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchStoreLookup looks payments up by ID and address in a store of n payments
+func benchStoreLookup(b *testing.B, store PaymentStore, n int, byAddress bool) {
+	id, address := seedPayments(b, store, n)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var (
+			payment *Payment
+			err     error
+		)
+		if byAddress {
+			payment, err = store.GetPaymentByAddress(address)
+		} else {
+			payment, err = store.GetPayment(id)
+		}
+		if err != nil || payment == nil || payment.ID != id {
+			b.Fatalf("lookup = %v, %v", payment, err)
+		}
+	}
+}
+
+// benchMonitorCycle runs monitor cycles over n pending payments, none of them
+// paid
+func benchMonitorCycle(b *testing.B, n int) {
+	store := NewMemoryStore()
+	pw := newHotPathPaywall(b, store)
+	seedPayments(b, store, n)
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client:  map[wallet.WalletType]CryptoClient{wallet.Bitcoin: &mockCryptoClient{}},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := monitor.checkPendingPayments(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMiddleware_AllowPath(b *testing.B) { benchMiddlewareAllow(b) }
+
+func BenchmarkMiddleware_NewPayment(b *testing.B) { benchMiddlewareNewPayment(b) }
+
+func BenchmarkCreatePayment(b *testing.B) { benchCreatePayment(b) }
+
+func BenchmarkStoreLookup(b *testing.B) {
+	stores := []struct {
+		name  string
+		store func(b *testing.B) PaymentStore
+	}{
+		{name: "memory", store: func(*testing.B) PaymentStore { return NewMemoryStore() }},
+		{name: "file", store: func(b *testing.B) PaymentStore { return NewFileStore(b.TempDir()) }},
+	}
+	for _, s := range stores {
+		for _, n := range hotPathStoreSizes {
+			if s.name == "file" && n > 10000 && testing.Short() {
+				continue
+			}
+			b.Run(fmt.Sprintf("%s/%d/id", s.name, n), func(b *testing.B) { benchStoreLookup(b, s.store(b), n, false) })
+			b.Run(fmt.Sprintf("%s/%d/address", s.name, n), func(b *testing.B) { benchStoreLookup(b, s.store(b), n, true) })
+		}
+	}
+}
+
+func BenchmarkMonitorCycle(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("pending=%d", n), func(b *testing.B) { benchMonitorCycle(b, n) })
+	}
+}
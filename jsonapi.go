@@ -0,0 +1,125 @@
+package paywall
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// PaymentRequiredResponse is the JSON body of the 402 Payment Required
+// response the middleware sends to API clients (Config.JSONResponses, or an
+// Accept header asking for JSON) in place of the payment page. Apps show the
+// addresses and amounts in their own UI, poll StatusURL until the payment
+// confirms and then repeat the request with the payment_id cookie set by the
+// response.
+type PaymentRequiredResponse struct {
+	// PaymentID identifies the payment; it is also the payment_id cookie value
+	PaymentID string `json:"payment_id"`
+	// Status is StatusPending, or StatusDetected once a paying transaction
+	// awaits confirmations
+	Status PaymentStatus `json:"status"`
+	// Addresses to pay to, per currency; only set while the payment is pending
+	Addresses map[wallet.WalletType]string `json:"addresses,omitempty"`
+	// Amounts to pay, per currency; only set while the payment is pending
+	Amounts map[wallet.WalletType]float64 `json:"amounts,omitempty"`
+	// PaymentURIs are the bitcoin: and monero: URIs opening a wallet with the
+	// address and amount filled in; only set while the payment is pending
+	PaymentURIs map[wallet.WalletType]string `json:"payment_uris,omitempty"`
+	// FiatEstimate is the current fiat value of the amounts, when
+	// Config.FiatEstimates is set
+	FiatEstimate *FiatEstimate `json:"fiat_estimate,omitempty"`
+	// Confirmations is the number of blockchain confirmations recorded
+	Confirmations int `json:"confirmations"`
+	// MinConfirmations is the number of confirmations the payment needs
+	MinConfirmations int `json:"min_confirmations"`
+	// ExpiresAt is when the payment expires unpaid
+	ExpiresAt time.Time `json:"expires_at"`
+	// StatusURL is where to poll the payment status (HandlePaymentStatus),
+	// empty unless Config.StatusPath is set
+	StatusURL string `json:"status_url,omitempty"`
+	// PollInterval is the suggested polling interval in seconds
+	PollInterval int `json:"poll_interval,omitempty"`
+}
+
+// wantsJSON reports whether an unpaid request is answered with a
+// PaymentRequiredResponse instead of the payment page
+func (p *Paywall) wantsJSON(r *http.Request) bool {
+	return p.jsonResponses || acceptsJSON(r.Header.Get("Accept"))
+}
+
+// acceptsJSON reports whether an Accept header prefers JSON to HTML: it lists
+// application/json (or a +json type) with a higher quality than text/html.
+// Browsers navigating to a page ask for HTML and keep getting the payment page.
+func acceptsJSON(accept string) bool {
+	jsonQuality, htmlQuality := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQuality = max(jsonQuality, quality)
+		case mediaType == "text/html":
+			htmlQuality = max(htmlQuality, quality)
+		}
+	}
+	return jsonQuality > htmlQuality
+}
+
+// respondPaymentRequired answers an unpaid request with 402 Payment Required
+// and a PaymentRequiredResponse describing payment
+func (p *Paywall) respondPaymentRequired(w http.ResponseWriter, r *http.Request, payment *Payment) {
+	body := PaymentRequiredResponse{
+		PaymentID:        payment.ID,
+		Status:           payment.Status,
+		Confirmations:    payment.Confirmations,
+		MinConfirmations: p.requiredConfirmations(payment),
+		ExpiresAt:        payment.ExpiresAt,
+		FiatEstimate:     p.fiatEstimate(payment),
+	}
+	if payment.Status == StatusPending {
+		body.Addresses = payment.Addresses
+		body.Amounts = payment.Amounts
+		body.PaymentURIs = make(map[wallet.WalletType]string, len(payment.Addresses))
+		for currency, address := range payment.Addresses {
+			if uri, err := paymentURI(currency, address, payment.Amounts[currency], p.paymentLabel); err == nil {
+				body.PaymentURIs[currency] = uri
+			}
+		}
+	}
+	if p.statusPath != "" {
+		body.StatusURL = p.statusPath
+		interval := p.statusPollInterval
+		if interval <= 0 {
+			interval = defaultStatusPollInterval
+		}
+		body.PollInterval = int(interval.Seconds())
+	}
+
+	// The answer depends on the Accept header and shows one payment at one time
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPaymentRequired)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "response_encoding_failed",
+			Message:   fmt.Sprintf("Failed to encode payment required response: %v", err),
+			PaymentID: payment.ID,
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package paywall
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "application/json", want: true},
+		{accept: "application/problem+json", want: true},
+		{accept: "application/json, text/plain, */*", want: true},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: false},
+		{accept: "text/html;q=0.5, application/json", want: true},
+		{accept: "application/json;q=0.5, text/html", want: false},
+		{accept: "*/*", want: false},
+		{accept: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := acceptsJSON(tt.accept); got != tt.want {
+			t.Errorf("acceptsJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware_PaymentRequiredJSON(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		StatusPath:     "/paywall/status",
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unpaid request reached the protected handler")
+	}))
+
+	tests := []struct {
+		name     string
+		accept   string
+		forced   bool
+		wantJSON bool
+	}{
+		{name: "browser", accept: "text/html,*/*;q=0.8"},
+		{name: "api client", accept: "application/json", wantJSON: true},
+		{name: "forced", accept: "text/html", forced: true, wantJSON: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw.jsonResponses = tt.forced
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if !tt.wantJSON {
+				if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+					t.Errorf("got %d %s, want the payment page", rec.Code, rec.Header().Get("Content-Type"))
+				}
+				return
+			}
+			if rec.Code != http.StatusPaymentRequired || rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("got %d %s, want 402 JSON", rec.Code, rec.Header().Get("Content-Type"))
+			}
+			var body PaymentRequiredResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			var cookie string
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "payment_id" {
+					cookie = c.Value
+				}
+			}
+			if body.PaymentID == "" || body.PaymentID != cookie || body.Status != StatusPending {
+				t.Errorf("payment %q (%s), cookie %q", body.PaymentID, body.Status, cookie)
+			}
+			if body.Addresses[wallet.Bitcoin] == "" || body.Amounts[wallet.Bitcoin] != 0.001 || body.PaymentURIs[wallet.Bitcoin] == "" {
+				t.Errorf("payment details = %+v", body)
+			}
+			if body.StatusURL != "/paywall/status" || body.PollInterval != 10 || body.ExpiresAt.IsZero() {
+				t.Errorf("status URL %q every %ds, expires %v", body.StatusURL, body.PollInterval, body.ExpiresAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package paywall
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// minLightningAmount is the smallest Lightning price, one millisatoshi in BTC
+const minLightningAmount = 0.00000000001
+
+// initializeLightningWallet connects Config.Lightning. Connection failures are
+// logged and yield a nil wallet, like Monero's: the paywall keeps taking
+// on-chain payments. The configuration itself is checked by validateConfig.
+func initializeLightningWallet(config Config) wallet.HDWallet {
+	if config.Lightning == nil {
+		return nil
+	}
+	lnWallet, err := wallet.NewLightningWallet(*config.Lightning)
+	if err == nil {
+		return lnWallet
+	}
+	if config.Logger != nil {
+		config.Logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "ln_wallet_init_failed",
+			Message: fmt.Sprintf("Lightning node configured but unavailable: %v. Continuing without Lightning.", err),
+		})
+	} else {
+		log.Printf("WARNING: Lightning node configured but unavailable: %v. Continuing without Lightning.", err)
+	}
+	return nil
+}
+
+// rateCurrency is the currency whose exchange rate prices walletType:
+// Lightning amounts are bitcoin
+func rateCurrency(walletType wallet.WalletType) wallet.WalletType {
+	if walletType == wallet.Lightning {
+		return wallet.Bitcoin
+	}
+	return walletType
+}
@@ -0,0 +1,147 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeLND serves the LND REST calls of wallet.LightningWallet
+type fakeLND struct {
+	t       *testing.T
+	mu      sync.Mutex
+	amounts []string // value_msat of each invoice added
+	settled map[string]string
+}
+
+// invoice encodes an unsigned BOLT11 invoice whose payment hash is n repeated
+func (f *fakeLND) invoice(n int) (string, string) {
+	hash := bytes.Repeat([]byte{byte(n)}, 32)
+	words, _ := bech32.ConvertBits(hash, 8, 5, true)
+	data := append(make([]byte, 7), 1, byte(len(words)>>5), byte(len(words)&31))
+	data = append(append(data, words...), make([]byte, 104)...)
+	invoice, err := bech32.Encode("lntb", data)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return invoice, hex.EncodeToString(hash)
+}
+
+func (f *fakeLND) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/getinfo":
+		io.WriteString(w, `{}`)
+	case r.URL.Path == "/v1/invoices":
+		var req struct {
+			ValueMsat string `json:"value_msat"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		f.amounts = append(f.amounts, req.ValueMsat)
+		invoice, _ := f.invoice(len(f.amounts))
+		json.NewEncoder(w).Encode(map[string]string{"payment_request": invoice})
+	case strings.HasPrefix(r.URL.Path, "/v1/invoice/"):
+		paid, ok := f.settled[strings.TrimPrefix(r.URL.Path, "/v1/invoice/")]
+		if !ok {
+			io.WriteString(w, `{"state": "OPEN"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"state": "SETTLED", "amt_paid_msat": paid})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestLightning_PaymentSettles(t *testing.T) {
+	node := &fakeLND{t: t, settled: map[string]string{}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	pw, err := NewPaywall(Config{
+		PriceInBTC:       0.001,
+		PriceInLightning: 0.00000500,
+		PaymentTimeout:   time.Hour,
+		TestNet:          true,
+		Store:            NewMemoryStore(),
+		Logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+		Lightning:        &wallet.LightningConfig{Backend: wallet.LightningLND, URL: server.URL, Macaroon: "0201"},
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	invoice := payment.Addresses[wallet.Lightning]
+	if !strings.HasPrefix(invoice, "lntb") || payment.Amounts[wallet.Lightning] != 0.000005 || node.amounts[0] != "500000" {
+		t.Fatalf("payment = %v %v, node asked for %v msat; want a 500000 msat invoice", payment.Addresses, payment.Amounts, node.amounts)
+	}
+	if payment.Addresses[wallet.Bitcoin] == "" {
+		t.Error("on-chain Bitcoin is no longer offered next to Lightning")
+	}
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/", nil), payment)
+	for _, want := range []string{invoice, ">500</span> sats", "lightning:" + invoice} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("payment page missing %q", want)
+		}
+	}
+
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin:   &mockCryptoClient{},
+		wallet.Lightning: pw.HDWallets[wallet.Lightning],
+	}}
+	monitor.checkPayment(payment)
+	if payment.Status != StatusPending {
+		t.Fatalf("status before settlement = %s, want pending", payment.Status)
+	}
+	_, hash := node.invoice(1)
+	node.mu.Lock()
+	node.settled[hash] = "500000"
+	node.mu.Unlock()
+	monitor.checkPayment(payment)
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.Lightning {
+		t.Errorf("after settlement status = %s paid in %q, want confirmed in LN", stored.Status, stored.PaidCurrency)
+	}
+}
+
+func TestLightning_Config(t *testing.T) {
+	lnd := &wallet.LightningConfig{Backend: wallet.LightningLND, URL: "https://127.0.0.1:8080", Macaroon: "0201"}
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"price without node", Config{PriceInBTC: 0.001, PriceInLightning: 0.00001}, "Lightning is nil"},
+		{"negative price", Config{PriceInBTC: 0.001, PriceInLightning: -1, Lightning: lnd}, "must be positive"},
+		{"invalid node", Config{PriceInBTC: 0.001, Lightning: &wallet.LightningConfig{Backend: wallet.LightningLND, URL: "https://127.0.0.1:8080"}}, "Macaroon"},
+		{"no price", Config{Lightning: lnd}, "below one millisatoshi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.PaymentTimeout = time.Hour
+			tt.config.TestNet = true
+			tt.config.Store = NewMemoryStore()
+			_, err := NewPaywall(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPaywall() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -27,6 +27,8 @@ type StructuredLogger struct {
 	writer     io.Writer
 	minLevel   LogLevel
 	jsonOutput bool
+	// redactor hides identifiers (Config.AnonymousLogs), nil to log them
+	redactor *logRedactor
 }
 
 // LogEntry represents a single structured log entry
@@ -61,6 +63,14 @@ func NewDefaultLogger() *StructuredLogger {
 	return NewStructuredLogger(os.Stdout, LogLevelInfo, true)
 }
 
+// withRedactor returns a copy of the logger writing to the same output that
+// hides identifiers with redactor
+func (l *StructuredLogger) withRedactor(redactor *logRedactor) *StructuredLogger {
+	redacting := *l
+	redacting.redactor = redactor
+	return &redacting
+}
+
 func (l *StructuredLogger) shouldLog(level LogLevel) bool {
 	levels := map[LogLevel]int{
 		LogLevelDebug: 0,
@@ -77,6 +87,9 @@ func (l *StructuredLogger) log(entry LogEntry) {
 	}
 
 	entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	if l.redactor != nil {
+		l.redactor.redactEntry(&entry)
+	}
 
 	if l.jsonOutput {
 		data, err := json.Marshal(entry)
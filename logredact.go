@@ -0,0 +1,178 @@
+package paywall
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+)
+
+// logReferencePrefix starts the hashed references that replace identifiers
+// in anonymous logs
+const logReferencePrefix = "ref_"
+
+// logIdentifierPattern matches the identifiers anonymous logs hide, longest
+// alternatives first: Lightning invoices, Monero addresses of every network,
+// transaction IDs, payment IDs (with an
+// optional partition prefix), Ethereum addresses and transaction hashes, bech32
+// and base58 Bitcoin, Litecoin or Dogecoin addresses, and Bitcoin Cash
+// cashaddr addresses with or without their prefix
+var logIdentifierPattern = regexp.MustCompile(`\b(?:` +
+	`ln(?:bc|tbs|tb|bcrt)[0-9]*[munp]?1[02-9ac-hj-np-z]{50,}` +
+	`|[45789AB][1-9A-HJ-NP-Za-km-z]{94}(?:[1-9A-HJ-NP-Za-km-z]{11})?` +
+	`|0x[0-9a-fA-F]{64}` +
+	`|0x[0-9a-fA-F]{40}` +
+	`|[0-9a-f]{64}` +
+	`|(?:[a-z0-9-]{1,32}_)?[0-9a-f]{32}` +
+	`|(?:bc|tb|bcrt|ltc|tltc|rltc)1[02-9ac-hj-np-z]{6,87}` +
+	`|(?:(?:bitcoincash|bchtest|bchreg):)?[qp][02-9ac-hj-np-z]{41}` +
+	`|[13mn2LMD][1-9A-HJ-NP-Za-km-z]{25,34}` +
+	`)\b`)
+
+// logRedactor replaces payment IDs, addresses and transaction IDs with keyed
+// hashes for Config.AnonymousLogs. The same value always gets the same
+// reference under one SigningKey, so log lines about one payment can still be
+// correlated, while the value cannot be recovered without the key.
+type logRedactor struct {
+	signer *tokenSigner
+}
+
+// ref returns the reference replacing value
+func (r *logRedactor) ref(value string) string {
+	if value == "" {
+		return ""
+	}
+	return logReferencePrefix + r.signer.sign("log-reference/v1", value)[:16]
+}
+
+// scrub replaces the identifiers found in text
+func (r *logRedactor) scrub(text string) string {
+	return logIdentifierPattern.ReplaceAllStringFunc(text, r.ref)
+}
+
+// redactEntry replaces the identifiers of a log entry: the ID fields
+// entirely, and those found in the message and data
+func (r *logRedactor) redactEntry(entry *LogEntry) {
+	entry.PaymentID = r.ref(entry.PaymentID)
+	entry.EscrowID = r.ref(entry.EscrowID)
+	entry.Message = r.scrub(entry.Message)
+	if len(entry.Data) == 0 {
+		return
+	}
+	data := make(map[string]interface{}, len(entry.Data))
+	for key, value := range entry.Data {
+		data[key] = r.scrubValue(value)
+	}
+	entry.Data = data
+}
+
+// scrubValue scrubs a log data value; values other than strings are
+// scrubbed in their JSON encoding
+func (r *logRedactor) scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.scrub(v)
+	case nil, bool, int, int64, float64:
+		return v
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "[redacted]"
+	}
+	return json.RawMessage(r.scrub(string(encoded)))
+}
+
+// redactAudit returns a copy of an audit entry with its payment ID replaced
+// and identifiers scrubbed from its metadata
+func (r *logRedactor) redactAudit(entry *AuditLogEntry) *AuditLogEntry {
+	redacted := *entry
+	redacted.PaymentID = r.ref(entry.PaymentID)
+	if entry.Metadata != nil {
+		redacted.Metadata = make(map[string]string, len(entry.Metadata))
+		for key, value := range entry.Metadata {
+			redacted.Metadata[key] = r.scrub(value)
+		}
+	}
+	return &redacted
+}
+
+// redactingAuditLogger stores the entries of an AuditLogger with payment IDs
+// replaced by references; trails are looked up by the reference of the ID
+type redactingAuditLogger struct {
+	AuditLogger
+	redactor *logRedactor
+}
+
+// LogAction implements AuditLogger
+func (l *redactingAuditLogger) LogAction(entry *AuditLogEntry) (string, error) {
+	if entry == nil {
+		return l.AuditLogger.LogAction(nil)
+	}
+	redacted := l.redactor.redactAudit(entry)
+	id, err := l.AuditLogger.LogAction(redacted)
+	entry.ID, entry.Timestamp = redacted.ID, redacted.Timestamp
+	return id, err
+}
+
+// GetAuditTrail implements AuditLogger
+func (l *redactingAuditLogger) GetAuditTrail(paymentID string) ([]*AuditLogEntry, error) {
+	return l.AuditLogger.GetAuditTrail(l.redactor.ref(paymentID))
+}
+
+// auditLogger returns logger, redacting its entries with Config.AnonymousLogs
+func (p *Paywall) auditLogger(logger AuditLogger) AuditLogger {
+	if p.redactor == nil {
+		return logger
+	}
+	return &redactingAuditLogger{AuditLogger: logger, redactor: p.redactor}
+}
+
+// LogReference returns what logs and audit entries show in place of value, a
+// payment ID, address or transaction ID, when Config.AnonymousLogs is set; use
+// it to find the log lines of a payment. Without AnonymousLogs value is
+// returned unchanged.
+//
+// Parameters:
+//   - value: Payment ID, address or transaction ID
+//
+// Returns:
+//   - string: The reference ("ref_" and 16 characters), or value
+func (p *Paywall) LogReference(value string) string {
+	if p.redactor == nil {
+		return value
+	}
+	return p.redactor.ref(value)
+}
+
+// RedactWriter returns a writer that scrubs payment IDs, addresses and
+// transaction IDs from what is written to w, as AnonymousLogs does for the
+// paywall's own log lines. Stores and wallets report some errors through the
+// standard log package; route it through the writer to hide identifiers
+// there too:
+//
+//	log.SetOutput(pw.RedactWriter(os.Stderr))
+//
+// Without Config.AnonymousLogs w is returned unchanged.
+//
+// Parameters:
+//   - w: Destination of the scrubbed output
+func (p *Paywall) RedactWriter(w io.Writer) io.Writer {
+	if p.redactor == nil {
+		return w
+	}
+	return &redactingWriter{w: w, redactor: p.redactor}
+}
+
+// redactingWriter scrubs identifiers from each write. The log package writes
+// one line per call, so identifiers are never split across writes.
+type redactingWriter struct {
+	w        io.Writer
+	redactor *logRedactor
+}
+
+// Write implements io.Writer
+func (rw *redactingWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.redactor.scrub(string(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,120 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogRedactor_Scrub(t *testing.T) {
+	redactor := &logRedactor{signer: &tokenSigner{key: bytes.Repeat([]byte("k"), minSigningKeyLength)}}
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "payment id", value: "0123456789abcdef0123456789abcdef"},
+		{name: "partitioned payment id", value: "tenant-a_0123456789abcdef0123456789abcdef"},
+		{name: "transaction id", value: strings.Repeat("ab", 32)},
+		{name: "legacy address", value: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{name: "testnet address", value: "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"},
+		{name: "bech32 address", value: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{name: "litecoin address", value: "ltc1qg82tvf6qwt0jzyq4sxm0qhylhq2gc6pfy3hfqf"},
+		{name: "monero address", value: "4" + strings.Repeat("A", 94)},
+		{name: "monero stagenet subaddress", value: "7" + strings.Repeat("A", 94)},
+		{name: "lightning invoice", value: "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp"},
+		{name: "testnet lightning invoice without amount", value: "lntb1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq8rkx3yf5tcsyz3d73gafnh3cax9rn449d9p5uxz9ezhhypd0elx87sjle52x86fux2ypatgddc6k63n7erqz25le42c4u4ecky03ylcqca784w"},
+		{name: "regtest lightning invoice", value: "lnbcrt10n1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4js"},
+		{name: "ethereum address", value: "0x52908400098527886E0F7030069857D2E4169EE7"},
+		{name: "lowercase ethereum address", value: "0xde709f2102306220921060314715629080e2fb77"},
+		{name: "dogecoin address", value: "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L"},
+		{name: "bitcoin cash address", value: "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{name: "bitcoin cash address without prefix", value: "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{name: "bitcoin cash testnet address", value: "bchtest:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{name: "ethereum transaction hash", value: "0x" + strings.Repeat("ab", 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactor.scrub("paid to " + tt.value + ".")
+			if want := "paid to " + redactor.ref(tt.value) + "."; got != want {
+				t.Errorf("scrub() = %q, want %q", got, want)
+			}
+			if ref := redactor.ref(tt.value); !strings.HasPrefix(ref, logReferencePrefix) || len(ref) != len(logReferencePrefix)+16 {
+				t.Errorf("ref() = %q, want %s and 16 characters", ref, logReferencePrefix)
+			}
+		})
+	}
+
+	if got := redactor.scrub("status confirmed after 3 confirmations"); got != "status confirmed after 3 confirmations" {
+		t.Errorf("scrub() changed text without identifiers: %q", got)
+	}
+}
+
+func TestAnonymousLogs(t *testing.T) {
+	var logs bytes.Buffer
+	pw := newTestPaywall(t, Config{Logger: NewStructuredLogger(&logs, LogLevelDebug, true), AnonymousLogs: true, SigningKey: testSigningKey})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "test",
+		Message:   "Payment " + payment.ID + " received",
+		PaymentID: payment.ID,
+		Data:      map[string]interface{}{"addresses": payment.Addresses, "confirmations": 1},
+	})
+
+	output := logs.String()
+	if strings.Contains(output, payment.ID) {
+		t.Errorf("logs contain the payment ID:\n%s", output)
+	}
+	for _, address := range payment.Addresses {
+		if strings.Contains(output, address) {
+			t.Errorf("logs contain the address %s:\n%s", address, output)
+		}
+	}
+	ref := pw.LogReference(payment.ID)
+	var entry LogEntry
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.PaymentID != ref || entry.Message != "Payment "+ref+" received" {
+		t.Errorf("log entry = %q / %q, want reference %s", entry.PaymentID, entry.Message, ref)
+	}
+
+	var stdlib bytes.Buffer
+	logger := log.New(pw.RedactWriter(&stdlib), "", 0)
+	logger.Printf("Breaking stale lock for payment %s", payment.ID)
+	if got := stdlib.String(); got != "Breaking stale lock for payment "+ref+"\n" {
+		t.Errorf("RedactWriter output = %q", got)
+	}
+}
+
+func TestAnonymousLogs_AuditTrail(t *testing.T) {
+	var logs bytes.Buffer
+	pw := newTestPaywall(t, Config{Logger: NewStructuredLogger(&logs, LogLevelDebug, true), AnonymousLogs: true, SigningKey: testSigningKey})
+	memory := NewMemoryAuditLogger()
+	audit := pw.auditLogger(memory)
+	paymentID := "0123456789abcdef0123456789abcdef"
+	address := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+
+	entry := &AuditLogEntry{PaymentID: paymentID, Action: "release", Metadata: map[string]string{"address": address}}
+	if _, err := audit.LogAction(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID == "" || entry.PaymentID != paymentID {
+		t.Errorf("LogAction() left entry ID %q, payment ID %q", entry.ID, entry.PaymentID)
+	}
+
+	stored, _ := memory.GetAllEntries()
+	if len(stored) != 1 || stored[0].PaymentID != pw.LogReference(paymentID) || stored[0].Metadata["address"] != pw.LogReference(address) {
+		t.Fatalf("stored entries = %+v, want references only", stored)
+	}
+	trail, err := audit.GetAuditTrail(paymentID)
+	if err != nil || len(trail) != 1 {
+		t.Errorf("GetAuditTrail() = %d entries, %v, want 1", len(trail), err)
+	}
+}
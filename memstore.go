@@ -1,6 +1,10 @@
 package paywall
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,7 +17,14 @@ import (
 // Warning: Data is not persisted and will be lost on server restart
 type MemoryStore struct {
 	payments map[string]*Payment
-	mu       sync.RWMutex
+	apiKeys  map[string]*APIKey
+	// stats holds the stats snapshots, oldest first
+	stats []*StatsSnapshot
+	mu    sync.RWMutex
+
+	// locks holds a channel per locked payment, closed on unlock
+	locks   map[string]chan struct{}
+	locksMu sync.Mutex
 }
 
 // NewMemoryStore creates a new in-memory payment store instance.
@@ -25,6 +36,7 @@ type MemoryStore struct {
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		payments: make(map[string]*Payment),
+		apiKeys:  make(map[string]*APIKey),
 	}
 }
 
@@ -77,6 +89,14 @@ func deepCopyPayment(p *Payment) *Payment {
 	paymentCopy.RequiredSignatures = copyRequiredSignatures(p.RequiredSignatures)
 	paymentCopy.Signatures = copySignatures(p.Signatures)
 	paymentCopy.StateTransitionHistory = copyStateHistory(p.StateTransitionHistory)
+	paymentCopy.LastBalanceSeen = copyAmounts(p.LastBalanceSeen)
+	paymentCopy.Notes = slices.Clone(p.Notes)
+	paymentCopy.Tags = slices.Clone(p.Tags)
+	paymentCopy.RefundAddresses = copyAddresses(p.RefundAddresses)
+	if p.Overpayment != nil {
+		overpayment := *p.Overpayment
+		paymentCopy.Overpayment = &overpayment
+	}
 
 	return &paymentCopy
 }
@@ -226,6 +246,56 @@ func (m *MemoryStore) ListPendingPayments() ([]*Payment, error) {
 	return payments, nil
 }
 
+// ListPayments returns deep copies of all payment records.
+//
+// Returns:
+//   - []*Payment: Every stored payment, in no particular order
+//   - error: Always nil in this implementation
+func (m *MemoryStore) ListPayments() ([]*Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	payments := make([]*Payment, 0, len(m.payments))
+	for _, p := range m.payments {
+		payments = append(payments, deepCopyPayment(p))
+	}
+	return payments, nil
+}
+
+// StreamPayments calls fn with a deep copy of every payment matching filter.
+// Implements PaymentStreamer. The store is not locked while fn runs.
+//
+// Returns:
+//   - error: fn's error other than ErrStopStream
+func (m *MemoryStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.payments))
+	for id := range m.payments {
+		if strings.HasPrefix(id, partitionIDPrefix(filter.Partition)) {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range ids {
+		m.mu.RLock()
+		payment, ok := m.payments[id]
+		if ok && filter.Matches(payment) {
+			payment = deepCopyPayment(payment)
+		} else {
+			payment = nil
+		}
+		m.mu.RUnlock()
+		if payment == nil {
+			continue
+		}
+		if err := fn(payment); err != nil {
+			return streamEnded(err)
+		}
+	}
+	return nil
+}
+
 // GetPaymentByAddress retrieves a payment record by Bitcoin address.
 // Returns a deep copy to prevent concurrent modification.
 //
@@ -295,3 +365,222 @@ func (m *MemoryStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment,
 	}
 	return expiring, nil
 }
+
+// DeletePayment removes a payment record.
+//
+// Parameters:
+//   - id: Payment identifier
+//
+// Returns:
+//   - error: Always nil in this implementation, also for unknown payments
+func (m *MemoryStore) DeletePayment(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.payments, id)
+	return nil
+}
+
+// ArchivePayments deletes the payments ArchivablePayment selects for
+// olderThan; a MemoryStore has no archive.
+//
+// Returns:
+//   - int: How many payments were deleted
+//   - error: Always nil in this implementation
+func (m *MemoryStore) ArchivePayments(olderThan time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	archived := 0
+	for id, p := range m.payments {
+		if ArchivablePayment(p, olderThan) {
+			delete(m.payments, id)
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// GetPaymentsExpiredBefore returns copies of the pending and detected payments
+// whose ExpiresAt is before deadline. Implements ExpiredPaymentFinder.
+//
+// Returns:
+//   - []*Payment: Overdue payments, in no particular order
+//   - error: Always nil in this implementation
+func (m *MemoryStore) GetPaymentsExpiredBefore(deadline time.Time) ([]*Payment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var overdue []*Payment
+	for _, p := range m.payments {
+		if (p.Status == StatusPending || p.Status == StatusDetected) && p.ExpiresAt.Before(deadline) {
+			overdue = append(overdue, deepCopyPayment(p))
+		}
+	}
+	return overdue, nil
+}
+
+// PruneExpiredPayments deletes the payments in StatusExpired whose ExpiresAt
+// is before t. Implements ExpiredPaymentPruner.
+//
+// Returns:
+//   - int: How many payments were deleted
+//   - error: Always nil in this implementation
+func (m *MemoryStore) PruneExpiredPayments(t time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pruned := 0
+	for id, p := range m.payments {
+		if p.Status == StatusExpired && p.ExpiresAt.Before(t) {
+			delete(m.payments, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// SaveAPIKey creates or replaces an API key record.
+//
+// Parameters:
+//   - key: API key record to store (a copy is stored)
+//
+// Returns:
+//   - error: Always nil in this implementation
+func (m *MemoryStore) SaveAPIKey(key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.apiKeys == nil {
+		m.apiKeys = make(map[string]*APIKey)
+	}
+	m.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+// GetAPIKey retrieves a copy of an API key record by ID.
+//
+// Returns:
+//   - *APIKey: API key copy if found, nil if not found
+//   - error: Always nil in this implementation
+func (m *MemoryStore) GetAPIKey(id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyAPIKey(key), nil
+}
+
+// ListAPIKeys returns copies of all API key records.
+//
+// Returns:
+//   - []*APIKey: Every stored key, in no particular order
+//   - error: Always nil in this implementation
+func (m *MemoryStore) ListAPIKeys() ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]*APIKey, 0, len(m.apiKeys))
+	for _, key := range m.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	return keys, nil
+}
+
+// SaveStatsSnapshot stores a copy of a stats snapshot.
+//
+// Returns:
+//   - error: Always nil in this implementation
+func (m *MemoryStore) SaveStatsSnapshot(snapshot *StatsSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, found := slices.BinarySearchFunc(m.stats, snapshot.Time, func(s *StatsSnapshot, t time.Time) int {
+		return s.Time.Compare(t)
+	})
+	if found {
+		m.stats[i] = copyStatsSnapshot(snapshot)
+		return nil
+	}
+	m.stats = slices.Insert(m.stats, i, copyStatsSnapshot(snapshot))
+	return nil
+}
+
+// ListStatsSnapshots returns copies of the stats snapshots taken in [from, to).
+//
+// Returns:
+//   - []*StatsSnapshot: Snapshots, oldest first
+//   - error: Always nil in this implementation
+func (m *MemoryStore) ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var snapshots []*StatsSnapshot
+	for _, snapshot := range m.stats {
+		if !snapshot.Time.Before(from) && snapshot.Time.Before(to) {
+			snapshots = append(snapshots, copyStatsSnapshot(snapshot))
+		}
+	}
+	return snapshots, nil
+}
+
+// DeleteStatsSnapshotsBefore deletes the stats snapshots taken before t.
+//
+// Returns:
+//   - error: Always nil in this implementation
+func (m *MemoryStore) DeleteStatsSnapshotsBefore(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = slices.DeleteFunc(m.stats, func(snapshot *StatsSnapshot) bool {
+		return snapshot.Time.Before(t)
+	})
+	return nil
+}
+
+// copyAPIKey creates a copy of an API key record, including the RevokedAt pointer
+func copyAPIKey(key *APIKey) *APIKey {
+	cp := *key
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		cp.RevokedAt = &revokedAt
+	}
+	return &cp
+}
+
+// LockPayment implements PaymentLocker for a single process: operations on the
+// same payment wait for each other.
+//
+// Parameters:
+//   - ctx: Bounds the wait for a held lock
+//   - paymentID: Payment to lock
+//
+// Returns:
+//   - func(): Releases the lock
+//   - error: ErrPaymentLocked when ctx ends first
+func (m *MemoryStore) LockPayment(ctx context.Context, paymentID string) (func(), error) {
+	for {
+		m.locksMu.Lock()
+		if m.locks == nil {
+			m.locks = make(map[string]chan struct{})
+		}
+		held, locked := m.locks[paymentID]
+		if !locked {
+			released := make(chan struct{})
+			m.locks[paymentID] = released
+			m.locksMu.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					m.locksMu.Lock()
+					delete(m.locks, paymentID)
+					m.locksMu.Unlock()
+					close(released)
+				})
+			}, nil
+		}
+		m.locksMu.Unlock()
+
+		select {
+		case <-held:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrPaymentLocked, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMeterWindow is how long a visitor's free allowance lasts before it resets
+	defaultMeterWindow = 30 * 24 * time.Hour
+	// meterTimeout bounds a MeterStore call on the request path
+	meterTimeout = 2 * time.Second
+	// MeterRemainingHeader reports the free requests a metered visitor has left
+	MeterRemainingHeader = "X-Paywall-Free-Remaining"
+)
+
+// MeterStore counts the requests of visitors without a payment for the metered
+// paywall (Config.FreeRequests, Config.FreeTime). The default keeps counters in
+// memory; implement it on a shared cache (e.g. Redis INCR with EXPIRE) when
+// several paywall instances serve the same visitors.
+type MeterStore interface {
+	// CountRequest records a request of the visitor identified by key.
+	//
+	// Parameters:
+	//   - key: Keyed visitor fingerprint, never identifying data in the clear
+	//   - now: Time of the request
+	//   - window: How long the visitor's meter lasts; a meter older than window
+	//     starts over at now
+	//
+	// Returns:
+	//   - count: Requests in the current window, including this one
+	//   - since: When the current window started
+	//   - err: If the counter cannot be updated
+	CountRequest(ctx context.Context, key string, now time.Time, window time.Duration) (count int, since time.Time, err error)
+}
+
+// meterEntry is a visitor's request count in the current window
+type meterEntry struct {
+	count int
+	since time.Time
+}
+
+// memoryMeter is the default in-memory MeterStore
+type memoryMeter struct {
+	mu        sync.Mutex
+	entries   map[string]meterEntry
+	lastPrune time.Time
+}
+
+// newMemoryMeter creates an empty in-memory meter
+func newMemoryMeter() *memoryMeter {
+	return &memoryMeter{entries: make(map[string]meterEntry)}
+}
+
+// CountRequest implements MeterStore, pruning expired meters at most once per
+// window to keep memory bounded
+func (m *memoryMeter) CountRequest(_ context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastPrune) > window {
+		for k, entry := range m.entries {
+			if now.Sub(entry.since) > window {
+				delete(m.entries, k)
+			}
+		}
+		m.lastPrune = now
+	}
+	entry, ok := m.entries[key]
+	if !ok || now.Sub(entry.since) > window {
+		entry = meterEntry{since: now}
+	}
+	entry.count++
+	m.entries[key] = entry
+	return entry.count, entry.since, nil
+}
+
+// meterAllows counts a request of a visitor without a payment and reports
+// whether it is still within the free allowance. Visitors without a fingerprint
+// and meter failures get no free requests. Allowed requests carry
+// MeterRemainingHeader when Config.FreeRequests is set.
+func (p *Paywall) meterAllows(w http.ResponseWriter, r *http.Request) bool {
+	if p.meter == nil {
+		return false
+	}
+	fingerprint := p.requestFingerprint(r)
+	if fingerprint == "" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), meterTimeout)
+	defer cancel()
+	now := time.Now()
+	count, since, err := p.meter.CountRequest(ctx, fingerprint, now, p.meterWindow)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "meter_failed",
+			Message: fmt.Sprintf("Metered paywall counter failed, requiring payment: %v", err),
+		})
+		return false
+	}
+	if p.freeRequests > 0 && count > p.freeRequests {
+		return false
+	}
+	if p.freeTime > 0 && now.Sub(since) >= p.freeTime {
+		return false
+	}
+	if p.freeRequests > 0 {
+		w.Header().Set(MeterRemainingHeader, strconv.Itoa(p.freeRequests-count))
+	}
+	return true
+}
+
+// resolveMeterStore picks Config.MeterStore, or else an in-memory meter when
+// the metered paywall is enabled
+func resolveMeterStore(config Config) MeterStore {
+	if config.FreeRequests <= 0 && config.FreeTime <= 0 {
+		return nil
+	}
+	if config.MeterStore != nil {
+		return config.MeterStore
+	}
+	return newMemoryMeter()
+}
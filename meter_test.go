@@ -0,0 +1,101 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// failingMeter is a MeterStore that is always unavailable
+type failingMeter struct{}
+
+func (failingMeter) CountRequest(context.Context, string, time.Time, time.Duration) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("cache unavailable")
+}
+
+func TestMemoryMeter_Window(t *testing.T) {
+	meter := newMemoryMeter()
+	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		count, since, _ := meter.CountRequest(context.Background(), "visitor", start.Add(time.Duration(i)*time.Minute), time.Hour)
+		if count != i || !since.Equal(start.Add(time.Minute)) {
+			t.Fatalf("request %d: count %d since %s", i, count, since)
+		}
+	}
+	count, since, _ := meter.CountRequest(context.Background(), "visitor", start.Add(2*time.Hour), time.Hour)
+	if count != 1 || !since.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("after the window: count %d since %s, want a fresh meter", count, since)
+	}
+	if count, _, _ := meter.CountRequest(context.Background(), "other", start.Add(2*time.Hour), time.Hour); count != 1 {
+		t.Errorf("other visitor count = %d, want 1", count)
+	}
+}
+
+func TestMiddleware_Metered(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		requests    int
+		wantFree    int
+		wantHeaders bool
+	}{
+		{"free requests", Config{FreeRequests: 3}, 5, 3, true},
+		{"free time", Config{FreeTime: time.Hour}, 3, 3, false},
+		{"free time used up", Config{FreeTime: time.Nanosecond}, 2, 1, false},
+		{"meter unavailable", Config{FreeRequests: 3, MeterStore: failingMeter{}}, 2, 0, false},
+		{"not metered", Config{}, 2, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.PriceInBTC = 0.001
+			config.PaymentTimeout = time.Hour
+			config.TestNet = true
+			config.Store = NewMemoryStore()
+			pw, err := NewPaywall(config)
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			defer pw.Close()
+
+			served := 0
+			handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served++
+			}))
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/article", nil)
+				req.RemoteAddr = "192.0.2.10:4000"
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if i < tt.wantFree && tt.wantHeaders {
+					if got, want := rec.Header().Get(MeterRemainingHeader), strconv.Itoa(tt.wantFree-i-1); got != want {
+						t.Errorf("request %d: %s = %q, want %q", i+1, MeterRemainingHeader, got, want)
+					}
+				}
+				if i >= tt.wantFree && rec.Header().Get("Set-Cookie") == "" {
+					t.Errorf("request %d: no payment started after the free allowance", i+1)
+				}
+				if tt.config.FreeTime == time.Nanosecond {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if served != tt.wantFree {
+				t.Errorf("served %d requests for free, want %d", served, tt.wantFree)
+			}
+
+			// Other visitors have their own allowance
+			if tt.wantFree > 0 {
+				req := httptest.NewRequest(http.MethodGet, "/article", nil)
+				req.RemoteAddr = "198.51.100.7:4000"
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+				if served != tt.wantFree+1 {
+					t.Error("a new visitor was not served for free")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+// Package metrics implements Prometheus counters, gauges and histograms and
+// serves them in the Prometheus text exposition format, without depending on
+// the Prometheus client library.
+//
+// Usage:
+//
+//	registry := metrics.NewRegistry()
+//	requests := registry.NewCounter("app_requests_total", "Requests served.", "method")
+//	requests.Inc("GET")
+//	http.Handle("/metrics", registry.Handler())
+//
+// Label values are passed in the order of the label names given at
+// registration; passing a different number of values panics, as it is a
+// programming error.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are histogram buckets, in seconds, suited to network and
+// storage latencies
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Registry holds metrics and writes them in the text exposition format.
+// The zero value is not usable; create registries with NewRegistry.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family is one metric with all its labelled series
+type family struct {
+	name       string
+	help       string
+	kind       string // "counter", "gauge" or "histogram"
+	labelNames []string
+	buckets    []float64 // histograms only, sorted, without +Inf
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a family for one set of label values
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // histograms: observations per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// register adds a family, panicking on an invalid or duplicate name
+func (r *Registry) register(f *family) {
+	if !metricNameRe.MatchString(f.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", f.name))
+	}
+	for _, label := range f.labelNames {
+		if !labelNameRe.MatchString(label) || strings.HasPrefix(label, "__") || (f.kind == "histogram" && label == "le") {
+			panic(fmt.Sprintf("metrics: invalid label name %q for %s", label, f.name))
+		}
+	}
+	f.series = make(map[string]*series)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[f.name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.families[f.name] = f
+}
+
+// with runs fn on the series for labelValues, creating it on first use
+func (f *family) with(labelValues []string, fn func(s *series)) {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// read runs fn on the series for labelValues if it exists, without creating it
+func (f *family) read(labelValues []string, fn func(s *series)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[strings.Join(labelValues, "\xff")]; ok {
+		fn(s)
+	}
+}
+
+// Counter is a value that only goes up, such as a number of requests
+type Counter struct{ f *family }
+
+// NewCounter registers a counter. By convention its name ends in "_total".
+//
+// Parameters:
+//   - name: Metric name
+//   - help: One-line description
+//   - labelNames: Names of the labels its series are told apart by
+//
+// Returns:
+//   - *Counter: The counter, starting at 0 for every set of label values
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	f := &family{name: name, help: help, kind: "counter", labelNames: labelNames}
+	r.register(f)
+	return &Counter{f: f}
+}
+
+// Inc adds 1 to the counter
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.f.name))
+	}
+	c.f.with(labelValues, func(s *series) { s.value += v })
+}
+
+// Value returns the counter's current value
+func (c *Counter) Value(labelValues ...string) float64 {
+	var v float64
+	c.f.read(labelValues, func(s *series) { v = s.value })
+	return v
+}
+
+// Gauge is a value that goes up and down, such as a queue length
+type Gauge struct{ f *family }
+
+// NewGauge registers a gauge
+//
+// Parameters:
+//   - name: Metric name
+//   - help: One-line description
+//   - labelNames: Names of the labels its series are told apart by
+//
+// Returns:
+//   - *Gauge: The gauge, starting at 0 for every set of label values
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	f := &family{name: name, help: help, kind: "gauge", labelNames: labelNames}
+	r.register(f)
+	return &Gauge{f: f}
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.with(labelValues, func(s *series) { s.value = v })
+}
+
+// Add adds v, which may be negative, to the gauge
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.with(labelValues, func(s *series) { s.value += v })
+}
+
+// Value returns the gauge's current value
+func (g *Gauge) Value(labelValues ...string) float64 {
+	var v float64
+	g.f.read(labelValues, func(s *series) { v = s.value })
+	return v
+}
+
+// Histogram counts observations, such as latencies, in buckets
+type Histogram struct{ f *family }
+
+// NewHistogram registers a histogram
+//
+// Parameters:
+//   - name: Metric name
+//   - help: One-line description
+//   - buckets: Upper bounds of the buckets, nil for DefBuckets. The +Inf
+//     bucket is added.
+//   - labelNames: Names of the labels its series are told apart by
+//
+// Returns:
+//   - *Histogram: The histogram
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	bounds := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) {
+			bounds = append(bounds, b)
+		}
+	}
+	sort.Float64s(bounds)
+	f := &family{name: name, help: help, kind: "histogram", labelNames: labelNames, buckets: bounds}
+	r.register(f)
+	return &Histogram{f: f}
+}
+
+// Observe records v
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.with(labelValues, func(s *series) {
+		if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.count++
+		s.sum += v
+	})
+}
+
+// Count returns how many values were observed
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	var n uint64
+	h.f.read(labelValues, func(s *series) { n = s.count })
+	return n
+}
+
+// Handler serves the registry's metrics, for mounting at /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		// A write error means the scraper went away; there is no one to tell
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// WriteTo writes all metrics in the text exposition format, families sorted
+// by name and series by label values
+//
+// Returns:
+//   - int64: Bytes written
+//   - error: The writer's error
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// write writes the family's HELP and TYPE lines and its series
+func (f *family) write(w *countingWriter) {
+	f.mu.Lock()
+	all := make([]series, 0, len(f.series))
+	for _, s := range f.series {
+		copied := *s
+		copied.counts = append([]uint64(nil), s.counts...)
+		all = append(all, copied)
+	}
+	f.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	for _, s := range all {
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labels(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labels(s.labelValues, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labels(s.labelValues, ""), s.count)
+	}
+}
+
+// labels formats the label set of a series, with an "le" label when le is set
+func (f *family) labels(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, f.labelNames[i]+`="`+escapeLabelValue(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats a sample value as Prometheus parses it
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp escapes backslashes and line feeds in HELP text
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+// escapeLabelValue escapes backslashes, line feeds and quotes in label values
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+// countingWriter counts bytes written and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("app_requests_total", "Requests served.", "method")
+	queue := registry.NewGauge("app_queue_length", "Jobs waiting.\nIncludes retries.")
+	latency := registry.NewHistogram("app_latency_seconds", "Request latency.", []float64{1, 0.1}, "route")
+
+	requests.Inc("POST")
+	requests.Add(2, "GET")
+	queue.Set(3)
+	queue.Add(-1)
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+	latency.Observe(0.1, `/b"\`)
+
+	var out strings.Builder
+	if _, err := registry.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := `# HELP app_latency_seconds Request latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{route="/a",le="0.1"} 1
+app_latency_seconds_bucket{route="/a",le="1"} 2
+app_latency_seconds_bucket{route="/a",le="+Inf"} 3
+app_latency_seconds_sum{route="/a"} 5.55
+app_latency_seconds_count{route="/a"} 3
+app_latency_seconds_bucket{route="/b\"\\",le="0.1"} 1
+app_latency_seconds_bucket{route="/b\"\\",le="1"} 1
+app_latency_seconds_bucket{route="/b\"\\",le="+Inf"} 1
+app_latency_seconds_sum{route="/b\"\\"} 0.1
+app_latency_seconds_count{route="/b\"\\"} 1
+# HELP app_queue_length Jobs waiting.\nIncludes retries.
+# TYPE app_queue_length gauge
+app_queue_length 2
+# HELP app_requests_total Requests served.
+# TYPE app_requests_total counter
+app_requests_total{method="GET"} 2
+app_requests_total{method="POST"} 1
+`
+	if out.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if got := requests.Value("GET"); got != 2 {
+		t.Errorf("Value(GET) = %v, want 2", got)
+	}
+	if got := latency.Count("/a"); got != 3 {
+		t.Errorf("Count(/a) = %d, want 3", got)
+	}
+	if got := requests.Value("PUT"); got != 0 {
+		t.Errorf("Value(PUT) = %v, want 0", got)
+	}
+	if strings.Contains(out.String(), "PUT") {
+		t.Error("reading a value created a series")
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("app_started_total", "Starts.").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	if !strings.Contains(rec.Body.String(), "app_started_total 1\n") {
+		t.Errorf("body = %q, want the counter", rec.Body.String())
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"invalid name", func(r *Registry) { r.NewCounter("app-requests", "") }},
+		{"invalid label", func(r *Registry) { r.NewGauge("app_gauge", "", "__reserved") }},
+		{"le label on histogram", func(r *Registry) { r.NewHistogram("app_seconds", "", nil, "le") }},
+		{"registered twice", func(r *Registry) { r.NewCounter("app_total", ""); r.NewGauge("app_total", "") }},
+		{"missing label value", func(r *Registry) { r.NewCounter("app_total", "", "method").Inc() }},
+		{"negative counter increment", func(r *Registry) { r.NewCounter("app_total", "").Add(-1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
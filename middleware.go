@@ -2,7 +2,10 @@
 package paywall
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -15,36 +18,210 @@ import (
 //   - http.Handler: A handler that checks payment status before allowing access
 //
 // Flow:
+//  0. Cookie-less credentials are checked first:
+//     - With Config.APIKeysEnabled, a request carrying the API key header is decided
+//     by the key alone: valid keys get access, others get 401/403/429
+//     - With Config.QueryTokenEnabled, a valid ?pw_token= for the request path grants
+//     access directly (the parameter is stripped before calling next)
+//     - With Config.BearerTokensEnabled, a request whose Authorization bearer token
+//     is an access grant is decided by the grant alone: valid grants for the route
+//     get access, invalid or expired ones get 401. Other bearer tokens are ignored
+//     and the request continues below as without them
+//     - With Config.AccessGrantTTL, a valid access grant cookie for the route grants
+//     access without reading the store
 //  1. Checks for existing payment_id cookie
 //  2. If cookie exists:
 //     - Verifies payment status and expiration
-//     - Allows access for confirmed, unexpired payments
-//     - Shows payment page for pending, unexpired payments
+//     - Allows access for confirmed, unexpired payments, minting a fresh access
+//     grant cookie with Config.AccessGrantTTL
+//     - With Config.PaymentCredits, paid requests (by cookie, pw_token, access
+//     grant or bearer token) are charged to the payment's credits; payments
+//     without enough credits left are treated as expired
+//     - Shows payment page for pending (or detected), unexpired payments
+//     - For expired payments, answers with the route's StatusExpired responder if any
 //  3. If no valid payment:
+//     - Serves the request for free while the visitor is within the metered
+//     allowance (Config.FreeRequests, Config.FreeTime)
+//     - Reuses the visitor's recent pending payment when Config.ReusePendingPayments
+//     matches their request fingerprint
+//     - Otherwise requires a solved CAPTCHA first when configured with WithChallenge
 //     - Creates new payment
 //     - Sets secure payment_id cookie
-//     - Shows payment page
+//     - Shows payment page, or answers 402 with a PaymentRequiredResponse for
+//     API clients (Config.JSONResponses or an Accept header preferring JSON)
+//
+// Routes built with MiddlewareWithOptions can charge only some methods
+// (WithPricedMethods), replace the payment page per status
+// (WithStatusResponse), show unpaid visitors the start of the page (WithTeaser),
+// let the protected handler render unpaid requests itself (WithUpstreamHandoff),
+// tag paid requests with the payment ID (WithPaymentIDHeader), end
+// long-lived responses when access lapses (WithRevalidation) and set their own
+// prices, payment timeout and confirmations (WithRoute).
+//
+// Paid requests reach next with the payment in their context: see FromContext.
+//
+// In ModeBypass every request is served without payment; in ModeReadOnly
+// visitors without a payment get 503 Service Unavailable (see SetMode).
 //
 // Error Handling:
 //   - Returns 500 Internal Server Error if payment creation fails
-//   - Invalid/expired payments result in new payment creation
+//   - Returns 403 Forbidden if a payment hook refuses to create the payment
+//   - Returns 429 Too Many Requests when a client creates payments faster than
+//     Config.RateLimits allows (RateLimitCreate)
+//   - Invalid/expired payments result in new payment creation unless a
+//     StatusExpired responder is configured
 //
 // Security:
 //   - Uses secure, HTTP-only cookies with SameSite=Strict
 //   - Payment IDs are cryptographically random
 //   - Validates payment status and expiration
+//   - Forwarding headers only count from Config.TrustedProxies when configured
 //
 // Related types: Payment, PaymentStore, PaymentStatus
 func (p *Paywall) Middleware(next http.Handler) http.Handler {
+	return p.MiddlewareWithOptions(next)
+}
+
+// MiddlewareOption customizes a single protected route
+// Related: MiddlewareWithOptions
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds per-route settings applied by MiddlewareOption values
+type middlewareConfig struct {
+	// challenge gates payment creation behind a CAPTCHA, nil when disabled
+	challenge ChallengeVerifier
+	// challengePolicy selects which requests must solve the challenge
+	challengePolicy ChallengePolicy
+	// responders replace the payment page per payment status, see WithStatusResponse
+	responders map[PaymentStatus]PaymentResponder
+	// paymentIDHeader is added to forwarded requests, see WithPaymentIDHeader
+	paymentIDHeader string
+	// pricedMethods lists the methods that require payment, nil to charge all methods
+	pricedMethods map[string]bool
+	// requestCost prices requests for metered API keys, nil for 1 credit each
+	requestCost func(*http.Request) int64
+	// revalidateInterval re-checks access during responses, 0 to check only at the start
+	revalidateInterval time.Duration
+	// teaser shows unpaid visitors the start of the page, nil for the full payment page
+	teaser *TeaserConfig
+	// handoffPrefix forwards unpaid requests with payment headers under this
+	// prefix, "" to answer them with the payment page; see WithUpstreamHandoff
+	handoffPrefix string
+	// partition prefixes the IDs of payments created on the route, see WithPartition
+	partition string
+	// routes price the route's requests, longest PathPrefix first; nil to use
+	// the paywall's prices, see WithRoute
+	routes []RouteConfig
+}
+
+// WithChallenge requires requests selected by policy to solve a CAPTCHA before
+// a payment (and HD address) is created for them. Solved challenges are
+// remembered for 10 minutes in a signed, HTTP-only cookie.
+//
+// Parameters:
+//   - verifier: CAPTCHA provider, e.g. NewHCaptchaVerifier or NewTurnstileVerifier
+//   - policy: Which requests to challenge; nil uses LooksAutomated
+func WithChallenge(verifier ChallengeVerifier, policy ChallengePolicy) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.challenge = verifier
+		cfg.challengePolicy = policy
+		if cfg.challengePolicy == nil {
+			cfg.challengePolicy = LooksAutomated
+		}
+	}
+}
+
+// WithPricedMethods charges only for requests using one of methods on a route;
+// requests with any other method pass through without payment. Use it for paid
+// writes next to free reads, e.g. WithPricedMethods(http.MethodPost, http.MethodPut)
+// on an upload or compute-job endpoint. Apply the middleware per route to price
+// paths differently.
+//
+// Parameters:
+//   - methods: HTTP methods that require payment (case-insensitive)
+func WithPricedMethods(methods ...string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.pricedMethods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			cfg.pricedMethods[strings.ToUpper(method)] = true
+		}
+	}
+}
+
+// MiddlewareWithOptions is Middleware with per-route options such as WithChallenge,
+// WithStatusResponse and WithPaymentIDHeader
+//
+// Parameters:
+//   - next: The HTTP handler to protect with payment verification
+//   - opts: Per-route options
+//
+// Returns:
+//   - http.Handler: A handler that checks payment status before allowing access
+func (p *Paywall) MiddlewareWithOptions(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	cfg := &middlewareConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Determine cookie name and security based on connection type
-		cookieName := "payment_id"
-		isSecure := false
+		// Methods the route does not charge for are served for free, as is
+		// everything while the paywall is bypassed
+		if (cfg.pricedMethods != nil && !cfg.pricedMethods[r.Method]) || p.Mode() == ModeBypass {
+			next.ServeHTTP(w, cfg.withHandoffHeaders(r, HandoffStatusFree, ""))
+			return
+		}
 
 		// Use __Host- prefix only for HTTPS connections
-		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		cookieName := "payment_id"
+		if p.isSecureRequest(r) {
 			cookieName = "__Host-payment_id"
-			isSecure = true
+		}
+
+		// Machine-to-machine clients authenticate with an API key instead of cookies
+		if p.apiKeys != nil {
+			if rawKey := r.Header.Get(p.apiKeyHeader); rawKey != "" {
+				p.serveAPIKey(w, r, cfg, rawKey, next)
+				return
+			}
+		}
+
+		// Payments only grant access on the route they were created for
+		route := cfg.routeFor(r)
+
+		// Cookie-less clients may present a signed, path-bound query token
+		if p.queryTokenEnabled {
+			if token := r.URL.Query().Get(QueryTokenParam); token != "" {
+				payment, err := p.verifyQueryToken(token, r.URL.Path)
+				if err == nil && payment.Route != routeName(route) {
+					err = ErrInvalidQueryToken
+				}
+				if err == nil {
+					if p.forwardPaid(w, withoutQueryToken(r), cfg, newPaymentInfo(payment, AccessQueryToken), p.paymentGrant(payment.ID), next) {
+						return
+					}
+					err = ErrInsufficientCredits
+				}
+				p.logger.log(LogEntry{
+					Level:   LogLevelDebug,
+					Event:   "query_token_rejected",
+					Message: fmt.Sprintf("Rejected %s for %s: %v", QueryTokenParam, r.URL.Path, err),
+				})
+			}
+		}
+
+		// Scripts and services send their access grant as a bearer token
+		if p.bearerTokens {
+			if token, ok := bearerToken(r); ok && p.serveBearerToken(w, r, cfg, routeName(route), token, next) {
+				return
+			}
+		}
+
+		// Paid visitors may present a signed access grant, checked without the store
+		if p.accessGrantTTL > 0 {
+			if grant, ok := p.accessGrantFromRequest(r); ok && grant.Route == routeName(route) &&
+				p.forwardPaid(w, r, cfg, grant.paymentInfo(AccessGrantCookie), grant.check(), next) {
+				return
+			}
 		}
 
 		// First check for existing cookie (try both names for compatibility)
@@ -56,46 +233,101 @@ func (p *Paywall) Middleware(next http.Handler) http.Handler {
 		}
 		if err == nil {
 			// Cookie exists, verify payment
-			// update expiration +15 minutes
+			payment, err := p.getPaymentInBudget(r.Context(), cookie.Value)
+			if errors.Is(err, ErrStoreTimeout) {
+				p.respondStoreTimeout(w, r, cfg, next)
+				return
+			}
+			// Keep the cookie for an hour after this visit, and for paid visitors
+			// until their access ends
 			cookie.Expires = time.Now().Add(1 * time.Hour)
+			if err == nil && payment != nil && payment.GrantsAccess(time.Now()) && payment.AccessEnds().After(cookie.Expires) {
+				cookie.Expires = payment.AccessEnds()
+			}
 			http.SetCookie(w, cookie)
-			payment, err := p.Store.GetPayment(cookie.Value)
-			if err == nil && payment != nil {
-				if payment.Status == StatusConfirmed && time.Now().Before(payment.ExpiresAt) {
+			if err == nil && payment != nil && payment.Route == routeName(route) {
+				if payment.GrantsAccess(time.Now()) {
 					// Payment confirmed and not expired, allow access
-					next.ServeHTTP(w, r)
+					if p.accessGrantTTL > 0 {
+						p.setAccessGrantCookie(w, r, payment)
+					}
+					if p.forwardPaid(w, r, cfg, newPaymentInfo(payment, AccessCookie), p.paymentGrant(payment.ID), next) {
+						return
+					}
+					// Its credits are used up (Config.PaymentCredits): start a new payment
+				} else if (payment.Status == StatusPending || payment.Status == StatusDetected) && time.Now().Before(payment.ExpiresAt) {
+					// Payment pending (or detected but unconfirmed) and not expired, show existing payment page
+					p.respondUnpaid(w, r, cfg, payment, next)
 					return
-				}
-				if payment.Status == StatusPending && time.Now().Before(payment.ExpiresAt) {
-					// Payment pending and not expired, show existing payment page
-					p.renderPaymentPage(w, payment)
+				} else if cfg.respondExpired(w, r, payment) {
+					// Payment expired: routes with a StatusExpired responder report it
+					// instead of silently starting a new payment
 					return
 				}
 			}
 		}
 
-		// No valid payment found, create new one
-		payment, err := p.CreatePayment()
-		if err != nil {
-			http.Error(w, "Failed to create payment", http.StatusInternalServerError)
+		// Metered paywall: visitors within their free allowance pass without paying
+		if p.meterAllows(w, r) {
+			next.ServeHTTP(w, cfg.withHandoffHeaders(r, HandoffStatusFree, ""))
 			return
 		}
-		cookieExpiration := time.Now().Add(1 * time.Hour)
-
-		// Set cookie for new payment with appropriate security settings
-		http.SetCookie(w, &http.Cookie{
-			Name:     cookieName,
-			Value:    payment.ID,
-			Path:     "/",
-			Secure:   isSecure,
-			HttpOnly: true,
-			SameSite: http.SameSiteStrictMode,
-			Domain:   "",
-			Expires:  cookieExpiration,
-		})
+
+		// No cookie: optionally re-attach the visitor to their recent pending payment
+		payment, fingerprint := p.claimPendingPayment(r, routeName(route))
+		if payment == nil {
+			// No valid payment found; optionally challenge automated clients first
+			if !p.challengeSatisfied(w, r, cfg) {
+				return
+			}
+
+			// Create new payment
+			if !p.allowRequest(w, r, RateLimitCreate) {
+				return
+			}
+			payment, err = p.createPayment(r, cfg.partition, route)
+			if errors.Is(err, ErrReadOnlyMode) {
+				respondMaintenance(w)
+				return
+			}
+			if errors.Is(err, ErrTransitionVetoed) {
+				http.Error(w, "Payment not available for this request", http.StatusForbidden)
+				return
+			}
+			if errors.Is(err, ErrStoreTimeout) {
+				p.respondStoreTimeout(w, r, cfg, next)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to create payment", http.StatusInternalServerError)
+				return
+			}
+			p.rememberPendingPayment(fingerprint, payment.ID)
+		}
+		p.setPaymentCookie(w, r, payment.ID)
 
 		// Show payment page
-		p.renderPaymentPage(w, payment)
+		p.respondUnpaid(w, r, cfg, payment, next)
+	})
+}
+
+// setPaymentCookie sets the payment cookie for paymentID for an hour, with
+// the __Host- prefix and Secure flag on HTTPS connections
+func (p *Paywall) setPaymentCookie(w http.ResponseWriter, r *http.Request, paymentID string) {
+	cookieName := "payment_id"
+	isSecure := false
+	if p.isSecureRequest(r) {
+		cookieName = "__Host-payment_id"
+		isSecure = true
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    paymentID,
+		Path:     "/",
+		Secure:   isSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(1 * time.Hour),
 	})
 }
 
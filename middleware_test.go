@@ -88,6 +88,15 @@ func (m *mockPaymentStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Paym
 	return expiring, nil
 }
 
+func (m *mockPaymentStore) DeletePayment(id string) error {
+	delete(m.payments, id)
+	return nil
+}
+
+func (m *mockPaymentStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return 0, nil
+}
+
 // mockPaywall provides a testable Paywall instance
 type mockPaywall struct {
 	store           PaymentStore
@@ -634,3 +643,98 @@ func TestMiddlewareFuncFunc_ReturnsCorrectHandlerFunc(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestWithPricedMethods(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served"))
+	}), WithPricedMethods("post", http.MethodPut))
+
+	tests := []struct {
+		method   string
+		wantPaid bool
+	}{
+		{http.MethodGet, false},
+		{http.MethodHead, false},
+		{http.MethodOptions, false},
+		{http.MethodPost, true},
+		{http.MethodPut, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, "/jobs", nil))
+			served := rec.Body.String() == "served"
+			if served == tt.wantPaid {
+				t.Errorf("%s served = %v, want payment required = %v", tt.method, served, tt.wantPaid)
+			}
+			if tt.wantPaid && len(rec.Result().Cookies()) == 0 {
+				t.Errorf("%s should start a payment", tt.method)
+			}
+		})
+	}
+}
+
+func TestMiddleware_AccessDuration(t *testing.T) {
+	tests := []struct {
+		name           string
+		accessDuration time.Duration
+	}{
+		{"access outlives payment window", 24 * time.Hour},
+		{"access ends with payment window", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, err := NewPaywall(Config{
+				PriceInBTC:     0.001,
+				PaymentTimeout: 10 * time.Minute,
+				AccessDuration: tt.accessDuration,
+				TestNet:        true,
+				Store:          NewMemoryStore(),
+			})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			defer pw.Close()
+
+			payment, err := pw.CreatePayment()
+			if err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			// Confirmed at the end of the payment window, visited 15 minutes after creation
+			pw.markConfirmed(payment, payment.ExpiresAt.Add(-time.Second))
+			payment.ExpiresAt = time.Now().Add(-5 * time.Minute)
+			if err := pw.Store.UpdatePayment(payment); err != nil {
+				t.Fatalf("UpdatePayment() error = %v", err)
+			}
+
+			handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("content"))
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			granted := rec.Body.String() == "content"
+			if want := tt.accessDuration > 0; granted != want {
+				t.Fatalf("access granted = %v, want %v", granted, want)
+			}
+			if !granted {
+				return
+			}
+			if payment.ConfirmedAt.IsZero() {
+				t.Error("ConfirmedAt not recorded")
+			}
+			var cookieExpires time.Time
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "payment_id" {
+					cookieExpires = c.Expires
+				}
+			}
+			if cookieExpires.Before(time.Now().Add(23 * time.Hour)) {
+				t.Errorf("cookie expires %v, want it kept until access ends", cookieExpires)
+			}
+		})
+	}
+}
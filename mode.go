@@ -0,0 +1,114 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Mode is the paywall's operating mode, switchable at runtime with SetMode
+type Mode string
+
+const (
+	// ModeNormal charges for protected content as configured
+	ModeNormal Mode = "normal"
+	// ModeReadOnly keeps granting access to confirmed payments and API keys but
+	// creates no new payments. Pending payments stay payable and their page shows
+	// a maintenance notice; new visitors get 503 Service Unavailable.
+	ModeReadOnly Mode = "read_only"
+	// ModeBypass serves protected content to everyone without payment
+	ModeBypass Mode = "bypass"
+)
+
+// maintenanceRetryAfter is the Retry-After (in seconds) sent to new visitors in read-only mode
+const maintenanceRetryAfter = 300
+
+// ErrReadOnlyMode is returned by CreatePayment while the paywall is in ModeReadOnly
+var ErrReadOnlyMode = errors.New("paywall is in read-only mode, no new payments are created")
+
+// SetMode switches the operating mode, e.g. during incidents or wallet maintenance.
+// The change applies to the next request; it is not persisted across restarts.
+//
+// Parameters:
+//   - mode: ModeNormal, ModeReadOnly or ModeBypass
+//
+// Returns:
+//   - error: If mode is unknown
+//
+// Related: HandleMode
+func (p *Paywall) SetMode(mode Mode) error {
+	switch mode {
+	case ModeNormal, ModeReadOnly, ModeBypass:
+	default:
+		return fmt.Errorf("unknown paywall mode %q (hint: use %q, %q or %q)", mode, ModeNormal, ModeReadOnly, ModeBypass)
+	}
+	previous := p.Mode()
+	p.mode.Store(mode)
+	if previous != mode {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "paywall_mode_changed",
+			Message: fmt.Sprintf("Paywall mode changed from %s to %s", previous, mode),
+		})
+	}
+	return nil
+}
+
+// Mode returns the current operating mode
+func (p *Paywall) Mode() Mode {
+	if mode, ok := p.mode.Load().(Mode); ok {
+		return mode
+	}
+	return ModeNormal
+}
+
+// modeRequest is the body of HandleMode responses and of PUT/POST requests
+type modeRequest struct {
+	Mode Mode `json:"mode"`
+}
+
+// HandleMode reads (GET) and switches (PUT or POST with {"mode": "read_only"})
+// the operating mode. It performs no authentication of its own: mount it on an
+// admin listener or behind your admin authentication.
+//
+// Responses:
+//   - 200 with {"mode": "..."}
+//   - 400 Bad Request for malformed bodies or unknown modes
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 405 Method Not Allowed for other methods
+func (p *Paywall) HandleMode(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		var req modeRequest
+		if _, ok := decodeRequestBody(w, r, p.requestLimits(), &req, false); !ok {
+			return
+		}
+		if err := p.SetMode(req.Mode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(modeRequest{Mode: p.Mode()}); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode mode response: %v", err),
+		})
+	}
+}
+
+// respondMaintenance tells a visitor without a payment that payments are paused
+func respondMaintenance(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+	http.Error(w, "Payments are temporarily unavailable for maintenance. Please try again later.", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,128 @@
+package paywall
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetMode_Middleware(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("protected"))
+	}))
+	paid := confirmedTestPayment(t, pw)
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	request := func(paymentID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/article", nil)
+		if paymentID != "" {
+			req.AddCookie(&http.Cookie{Name: "payment_id", Value: paymentID})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got := pw.Mode(); got != ModeNormal {
+		t.Fatalf("Mode() = %q, want %q", got, ModeNormal)
+	}
+
+	tests := []struct {
+		name      string
+		mode      Mode
+		paymentID string
+		wantCode  int
+		wantBody  string
+	}{
+		{"normal new visitor", ModeNormal, "", http.StatusOK, "Payment Required"},
+		{"read-only paid visitor", ModeReadOnly, paid.ID, http.StatusOK, "protected"},
+		{"read-only new visitor", ModeReadOnly, "", http.StatusServiceUnavailable, "maintenance"},
+		{"read-only pending visitor", ModeReadOnly, pending.ID, http.StatusOK, "Maintenance in progress"},
+		{"bypass new visitor", ModeBypass, "", http.StatusOK, "protected"},
+		{"bypass pending visitor", ModeBypass, pending.ID, http.StatusOK, "protected"},
+		{"normal pending visitor", ModeNormal, pending.ID, http.StatusOK, pending.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pw.SetMode(tt.mode); err != nil {
+				t.Fatalf("SetMode(%q) error = %v", tt.mode, err)
+			}
+			rec := request(tt.paymentID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body does not contain %q", tt.wantBody)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("503 response has no Retry-After header")
+			}
+		})
+	}
+
+	pw.SetMode(ModeReadOnly)
+	if _, err := pw.CreatePayment(); !errors.Is(err, ErrReadOnlyMode) {
+		t.Errorf("CreatePayment() in read-only mode error = %v, want ErrReadOnlyMode", err)
+	}
+	if err := pw.SetMode("maintenance"); err == nil {
+		t.Error("SetMode() with unknown mode should fail")
+	}
+	if got := pw.Mode(); got != ModeReadOnly {
+		t.Errorf("Mode() after rejected SetMode = %q, want %q", got, ModeReadOnly)
+	}
+}
+
+func TestHandleMode(t *testing.T) {
+	pw := createTestPaywall()
+	pw.logger = NewStructuredLogger(io.Discard, LogLevelError, true)
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		wantCode int
+		wantMode Mode
+	}{
+		{"get default", http.MethodGet, "", http.StatusOK, ModeNormal},
+		{"set read-only", http.MethodPut, `{"mode":"read_only"}`, http.StatusOK, ModeReadOnly},
+		{"set bypass", http.MethodPost, `{"mode":"bypass"}`, http.StatusOK, ModeBypass},
+		{"unknown mode", http.MethodPut, `{"mode":"off"}`, http.StatusBadRequest, ModeBypass},
+		{"malformed body", http.MethodPut, `mode=normal`, http.StatusBadRequest, ModeBypass},
+		{"wrong method", http.MethodDelete, "", http.StatusMethodNotAllowed, ModeBypass},
+		{"back to normal", http.MethodPut, `{"mode":"normal"}`, http.StatusOK, ModeNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/admin/mode", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			pw.HandleMode(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(rec.Body.String(), `"mode":"`+string(tt.wantMode)+`"`) {
+				t.Errorf("body = %q, want mode %q", rec.Body.String(), tt.wantMode)
+			}
+			if got := pw.Mode(); got != tt.wantMode {
+				t.Errorf("Mode() = %q, want %q", got, tt.wantMode)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+var (
+	// ErrProofNotSupported is returned when the wallet backend cannot verify payment
+	// proofs (Monero without wallet-rpc, Bitcoin without Config.BTCRPCHost)
+	ErrProofNotSupported = errors.New("wallet backend cannot verify payment proofs")
+	// ErrProofInsufficient is returned when a valid proof shows less than the required amount
+	ErrProofInsufficient = errors.New("proven amount is less than the payment amount")
+	// ErrProofPaymentNotFound is returned when the proof names an unknown payment
+	ErrProofPaymentNotFound = errors.New("payment not found")
+	// ErrProofPaymentNotPending is returned for payments that expired or cannot accept proofs
+	ErrProofPaymentNotPending = errors.New("payment is expired or cannot accept payment proofs")
+)
+
+// MoneroProofChecker is implemented by Monero wallets that can verify
+// payer-supplied payment proofs, such as wallet.MoneroHDWallet (wallet-rpc)
+type MoneroProofChecker interface {
+	CheckTxKey(txID, txKey, address string) (*wallet.MoneroTxProof, error)
+	CheckTxProof(txID, address, message, signature string) (*wallet.MoneroTxProof, error)
+}
+
+// MoneroPaymentProof is a payer's proof that a transaction paid the payment address.
+// Provide either TxKey (from the sender wallet's get_tx_key) or Signature
+// (from get_tx_proof, optionally signed over Message).
+type MoneroPaymentProof struct {
+	// PaymentID selects the payment; HandleMoneroProof falls back to the payment cookie
+	PaymentID string `json:"payment_id,omitempty"`
+	// TxID is the transaction hash
+	TxID string `json:"txid"`
+	// TxKey is the transaction secret key
+	TxKey string `json:"tx_key,omitempty"`
+	// Signature is a transaction proof ("OutProofV2...")
+	Signature string `json:"signature,omitempty"`
+	// Message is the optional message the Signature was created with
+	Message string `json:"message,omitempty"`
+}
+
+// MoneroProofResult reports the outcome of a verified proof
+type MoneroProofResult struct {
+	PaymentID             string        `json:"payment_id"`
+	Status                PaymentStatus `json:"status"`
+	Received              float64       `json:"received"`
+	Required              float64       `json:"required"`
+	Confirmations         int           `json:"confirmations"`
+	RequiredConfirmations int           `json:"required_confirmations"`
+}
+
+// VerifyMoneroProof checks a payer-submitted Monero payment proof through the
+// wallet (check_tx_key / check_tx_proof) and confirms the payment immediately
+// when the proven amount and confirmations suffice, without waiting for the
+// monitor to detect it.
+//
+// Parameters:
+//   - proof: Transaction ID plus a tx key or tx proof signature; PaymentID is required
+//
+// Returns:
+//   - *MoneroProofResult: Proven amount and resulting status (StatusPending while
+//     the transaction lacks confirmations; resubmit later)
+//   - error: ErrProofNotSupported, ErrProofPaymentNotFound, ErrProofPaymentNotPending, ErrProofInsufficient,
+//     wallet.ErrInvalidTxProof, ErrTransitionVetoed, ErrPaymentLocked, or
+//     lookup/verification/storage errors
+//
+// Related: HandleMoneroProof, MoneroProofChecker
+func (p *Paywall) VerifyMoneroProof(proof MoneroPaymentProof) (*MoneroProofResult, error) {
+	if proof.TxID == "" || (proof.TxKey == "" && proof.Signature == "") {
+		return nil, errors.New("txid and either tx_key or signature are required")
+	}
+
+	unlock, err := p.lockPayment(proof.PaymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(proof.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	address, ok := payment.Addresses[wallet.Monero]
+	if !ok || address == "" {
+		return nil, fmt.Errorf("payment %s has no Monero address: %w", payment.ID, ErrProofPaymentNotPending)
+	}
+
+	result := &MoneroProofResult{
+		PaymentID:             payment.ID,
+		Status:                payment.Status,
+		Required:              payment.Amounts[wallet.Monero],
+		RequiredConfirmations: p.requiredConfirmations(payment),
+	}
+	if payment.Status == StatusConfirmed {
+		return result, nil
+	}
+	if (payment.Status != StatusPending && payment.Status != StatusDetected) || !time.Now().Before(payment.ExpiresAt) {
+		return nil, ErrProofPaymentNotPending
+	}
+
+	checker, ok := p.HDWallets[wallet.Monero].(MoneroProofChecker)
+	if !ok {
+		return nil, ErrProofNotSupported
+	}
+
+	var verified *wallet.MoneroTxProof
+	if proof.TxKey != "" {
+		verified, err = checker.CheckTxKey(proof.TxID, proof.TxKey, address)
+	} else {
+		verified, err = checker.CheckTxProof(proof.TxID, address, proof.Message, proof.Signature)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Received = verified.Received
+	if !verified.InPool {
+		result.Confirmations = verified.Confirmations
+	}
+	if result.Received < result.Required {
+		return result, ErrProofInsufficient
+	}
+	if result.Confirmations < result.RequiredConfirmations {
+		p.logger.log(LogEntry{
+			Level:     LogLevelInfo,
+			Event:     "monero_proof_awaiting_confirmations",
+			Message:   fmt.Sprintf("Valid proof for tx %s, waiting for confirmations (%d/%d)", proof.TxID, result.Confirmations, result.RequiredConfirmations),
+			PaymentID: payment.ID,
+			Currency:  wallet.Monero,
+			Amount:    result.Received,
+		})
+		return result, nil
+	}
+
+	confirmed := &PaymentTransition{
+		Event:    TransitionConfirm,
+		From:     payment.Status,
+		To:       StatusConfirmed,
+		Payment:  payment,
+		Currency: wallet.Monero,
+		Amount:   result.Received,
+		TxID:     proof.TxID,
+	}
+	err = p.transition(confirmed, func(t *PaymentTransition) error {
+		p.markConfirmed(t.Payment, time.Now())
+		t.Payment.Confirmations = result.Confirmations
+		t.Payment.PaidCurrency = wallet.Monero
+		return p.Store.UpdatePayment(t.Payment)
+	})
+	if errors.Is(err, ErrTransitionVetoed) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	result.Status = StatusConfirmed
+
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "monero_proof_confirmed",
+		Message:   fmt.Sprintf("Payment confirmed by payer-submitted proof for tx %s", proof.TxID),
+		PaymentID: payment.ID,
+		Currency:  wallet.Monero,
+		Amount:    result.Received,
+	})
+	p.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentConfirmed,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"confirmations": payment.Confirmations,
+			"amount":        result.Received,
+			"currency":      wallet.Monero,
+			"txid":          proof.TxID,
+			"source":        "proof",
+		},
+	})
+	return result, nil
+}
+
+// HandleMoneroProof processes POST requests with a JSON MoneroPaymentProof, letting
+// payers who say "I paid but it's not unlocking" prove their Monero payment.
+// The payment is taken from the body's payment_id or else the payment cookie.
+//
+// Responses:
+//   - 200 with a MoneroProofResult (status "confirmed", or "pending" while the
+//     transaction still lacks confirmations)
+//   - 400 Bad Request for malformed submissions
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 403 Forbidden if a payment hook refused the confirmation
+//   - 404 Not Found if the payment does not exist
+//   - 409 Conflict if the payment expired or has no Monero option
+//   - 422 Unprocessable Entity if the proof is invalid or the amount is short
+//   - 429 Too Many Requests (with Retry-After) beyond Config.RateLimits
+//   - 501 Not Implemented if the Monero backend cannot verify proofs
+//   - 502 Bad Gateway if the wallet rejects the proof or is unreachable
+//   - 503 Service Unavailable if another operation holds the payment's lock
+func (p *Paywall) HandleMoneroProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitSubmit) {
+		return
+	}
+
+	var proof MoneroPaymentProof
+	if _, ok := decodeRequestBody(w, r, p.requestLimits(), &proof, false); !ok {
+		return
+	}
+	if proof.PaymentID == "" {
+		id, err := paymentIDFromCookie(r)
+		if err != nil {
+			http.Error(w, "payment_id is required", http.StatusBadRequest)
+			return
+		}
+		proof.PaymentID = id
+	}
+	if proof.TxID == "" || (proof.TxKey == "" && proof.Signature == "") {
+		http.Error(w, "txid and either tx_key or signature are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := p.VerifyMoneroProof(proof)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrProofNotSupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, ErrProofPaymentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrProofPaymentNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrTransitionVetoed):
+		http.Error(w, "Payment not accepted", http.StatusForbidden)
+		return
+	case errors.Is(err, ErrPaymentLocked):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Payment is being updated, please try again", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, ErrProofInsufficient), errors.Is(err, wallet.ErrInvalidTxProof):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	default:
+		p.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "monero_proof_failed",
+			Message:   fmt.Sprintf("Monero proof verification failed: %v", err),
+			PaymentID: proof.PaymentID,
+			Currency:  wallet.Monero,
+		})
+		http.Error(w, "Proof could not be verified by the wallet", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode Monero proof response: %v", err),
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// proofTestWallet is a Monero wallet that verifies proofs for a single known transaction
+type proofTestWallet struct {
+	handlerTestHDWallet
+	txID    string
+	address string
+	proof   wallet.MoneroTxProof
+}
+
+func (m *proofTestWallet) CheckTxKey(txID, txKey, address string) (*wallet.MoneroTxProof, error) {
+	if txKey != "good-key" {
+		return nil, errors.New("wallet RPC error: invalid tx key")
+	}
+	return m.check(txID, address)
+}
+
+func (m *proofTestWallet) CheckTxProof(txID, address, message, signature string) (*wallet.MoneroTxProof, error) {
+	if signature != "OutProofV2good" {
+		return nil, wallet.ErrInvalidTxProof
+	}
+	return m.check(txID, address)
+}
+
+func (m *proofTestWallet) check(txID, address string) (*wallet.MoneroTxProof, error) {
+	if txID != m.txID || address != m.address {
+		return &wallet.MoneroTxProof{}, nil
+	}
+	proof := m.proof
+	return &proof, nil
+}
+
+func newMoneroProofTestPaywall(t *testing.T, proof wallet.MoneroTxProof) (*Paywall, *Payment) {
+	t.Helper()
+	pw := newTestPaywall(t, Config{MinConfirmations: 2})
+
+	payment := &Payment{
+		ID:        "proof-payment",
+		Addresses: map[wallet.WalletType]string{wallet.Monero: "xmr-subaddress"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Monero: 0.5},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	if err := pw.Store.CreatePayment(payment); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	pw.HDWallets[wallet.Monero] = &proofTestWallet{txID: "tx1", address: "xmr-subaddress", proof: proof}
+	return pw, payment
+}
+
+func TestVerifyMoneroProof(t *testing.T) {
+	tests := []struct {
+		name       string
+		proof      wallet.MoneroTxProof
+		submit     MoneroPaymentProof
+		wantErr    error
+		wantStatus PaymentStatus
+	}{
+		{
+			name:       "tx key confirms payment",
+			proof:      wallet.MoneroTxProof{Received: 0.5, Confirmations: 3},
+			submit:     MoneroPaymentProof{TxID: "tx1", TxKey: "good-key"},
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "tx proof confirms payment",
+			proof:      wallet.MoneroTxProof{Received: 0.6, Confirmations: 2},
+			submit:     MoneroPaymentProof{TxID: "tx1", Signature: "OutProofV2good"},
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "in-pool transaction stays pending",
+			proof:      wallet.MoneroTxProof{Received: 0.5, Confirmations: 5, InPool: true},
+			submit:     MoneroPaymentProof{TxID: "tx1", TxKey: "good-key"},
+			wantStatus: StatusPending,
+		},
+		{
+			name:    "underpayment is rejected",
+			proof:   wallet.MoneroTxProof{Received: 0.4, Confirmations: 3},
+			submit:  MoneroPaymentProof{TxID: "tx1", TxKey: "good-key"},
+			wantErr: ErrProofInsufficient,
+		},
+		{
+			name:    "transaction to another address is rejected",
+			proof:   wallet.MoneroTxProof{Received: 0.5, Confirmations: 3},
+			submit:  MoneroPaymentProof{TxID: "other", TxKey: "good-key"},
+			wantErr: ErrProofInsufficient,
+		},
+		{
+			name:    "invalid signature",
+			submit:  MoneroPaymentProof{TxID: "tx1", Signature: "forged"},
+			wantErr: wallet.ErrInvalidTxProof,
+		},
+		{
+			name:    "unknown payment",
+			submit:  MoneroPaymentProof{PaymentID: "missing", TxID: "tx1", TxKey: "good-key"},
+			wantErr: ErrProofPaymentNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, payment := newMoneroProofTestPaywall(t, tt.proof)
+			if tt.submit.PaymentID == "" {
+				tt.submit.PaymentID = payment.ID
+			}
+
+			result, err := pw.VerifyMoneroProof(tt.submit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyMoneroProof() error = %v, want %v", err, tt.wantErr)
+				}
+				stored, _ := pw.Store.GetPayment(payment.ID)
+				if stored.Status != StatusPending {
+					t.Errorf("payment status = %s after failed proof, want pending", stored.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyMoneroProof() error = %v", err)
+			}
+			if result.Status != tt.wantStatus {
+				t.Errorf("result status = %s, want %s", result.Status, tt.wantStatus)
+			}
+			stored, _ := pw.Store.GetPayment(payment.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == StatusConfirmed && stored.PaidCurrency != wallet.Monero {
+				t.Errorf("PaidCurrency = %q, want %q", stored.PaidCurrency, wallet.Monero)
+			}
+		})
+	}
+}
+
+func TestVerifyMoneroProof_PaymentState(t *testing.T) {
+	pw, payment := newMoneroProofTestPaywall(t, wallet.MoneroTxProof{Received: 0.5, Confirmations: 3})
+	submit := MoneroPaymentProof{PaymentID: payment.ID, TxID: "tx1", TxKey: "good-key"}
+
+	payment.ExpiresAt = time.Now().Add(-time.Minute)
+	pw.Store.UpdatePayment(payment)
+	if _, err := pw.VerifyMoneroProof(submit); !errors.Is(err, ErrProofPaymentNotPending) {
+		t.Errorf("expired payment: error = %v, want ErrProofPaymentNotPending", err)
+	}
+
+	payment.Status = StatusConfirmed
+	pw.Store.UpdatePayment(payment)
+	result, err := pw.VerifyMoneroProof(submit)
+	if err != nil || result.Status != StatusConfirmed {
+		t.Errorf("confirmed payment: result = %+v, error = %v, want confirmed", result, err)
+	}
+
+	pw.HDWallets[wallet.Monero] = &handlerTestHDWallet{}
+	payment.Status = StatusPending
+	payment.ExpiresAt = time.Now().Add(time.Hour)
+	pw.Store.UpdatePayment(payment)
+	if _, err := pw.VerifyMoneroProof(submit); !errors.Is(err, ErrProofNotSupported) {
+		t.Errorf("unsupported backend: error = %v, want ErrProofNotSupported", err)
+	}
+}
+
+func TestHandleMoneroProof(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		cookie     bool
+		wantStatus int
+	}{
+		{"confirmed via cookie", http.MethodPost, `{"txid":"tx1","tx_key":"good-key"}`, true, http.StatusOK},
+		{"confirmed via body", http.MethodPost, `{"payment_id":"proof-payment","txid":"tx1","tx_key":"good-key"}`, false, http.StatusOK},
+		{"wrong method", http.MethodGet, ``, true, http.StatusMethodNotAllowed},
+		{"malformed JSON", http.MethodPost, `{`, true, http.StatusBadRequest},
+		{"missing payment", http.MethodPost, `{"txid":"tx1","tx_key":"good-key"}`, false, http.StatusBadRequest},
+		{"missing key and signature", http.MethodPost, `{"txid":"tx1"}`, true, http.StatusBadRequest},
+		{"unknown payment", http.MethodPost, `{"payment_id":"missing","txid":"tx1","tx_key":"good-key"}`, false, http.StatusNotFound},
+		{"invalid proof", http.MethodPost, `{"txid":"tx1","signature":"forged"}`, true, http.StatusUnprocessableEntity},
+		{"wallet error", http.MethodPost, `{"txid":"tx1","tx_key":"bad-key"}`, true, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, payment := newMoneroProofTestPaywall(t, wallet.MoneroTxProof{Received: 0.5, Confirmations: 3})
+			req := httptest.NewRequest(tt.method, "/paywall/xmr-proof", strings.NewReader(tt.body))
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+			}
+			rec := httptest.NewRecorder()
+			pw.HandleMoneroProof(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(rec.Body.String(), `"status":"confirmed"`) {
+				t.Errorf("body = %q, want confirmed status", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package paywall
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// monitorWALCompactRecords is how many records the monitor log may hold before
+// it is truncated, which happens once no change is left to apply
+const monitorWALCompactRecords = 1024
+
+// monitorWALEntry is a status change the monitor intends to make, or the mark
+// that the change with Seq was settled
+type monitorWALEntry struct {
+	Seq uint64 `json:"seq"`
+	// Done marks the change with Seq as applied or no longer applicable
+	Done bool `json:"done,omitempty"`
+
+	PaymentID     string            `json:"payment_id,omitempty"`
+	Event         TransitionEvent   `json:"event,omitempty"`
+	From          PaymentStatus     `json:"from,omitempty"`
+	To            PaymentStatus     `json:"to,omitempty"`
+	Currency      wallet.WalletType `json:"currency,omitempty"`
+	Amount        float64           `json:"amount,omitempty"`
+	TxID          string            `json:"txid,omitempty"`
+	Confirmations int               `json:"confirmations,omitempty"`
+	// At is when the monitor decided the change
+	At time.Time `json:"at,omitempty"`
+
+	// claimed is set while a monitor goroutine applies the change
+	claimed bool
+}
+
+// monitorWAL is the monitor's write-ahead log (Config.MonitorWALPath): an
+// append-only JSON Lines file where each status change is recorded, and
+// synced, before it is written to the store, then marked done. Changes not
+// marked done, because the process crashed or the store failed, are replayed
+// until they settle. A nil *monitorWAL records nothing.
+type monitorWAL struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	// seq is the last sequence number handed out
+	seq uint64
+	// pending holds the changes not yet marked done, by Seq
+	pending map[uint64]*monitorWALEntry
+	// records counts the lines in the file
+	records int
+	// skipped counts unreadable lines found on open, e.g. a line torn by a crash
+	skipped int
+}
+
+// openMonitorWAL opens the log at path, creating it if needed, and loads the
+// changes not yet marked done. The file is rewritten to hold only those.
+//
+// Parameters:
+//   - path: Log file path; its directory is created if needed
+//
+// Returns:
+//   - *monitorWAL: The open log
+//   - error: If the file cannot be read or rewritten
+func openMonitorWAL(path string) (*monitorWAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create monitor log directory: %w", err)
+	}
+	w := &monitorWAL{path: path, pending: make(map[uint64]*monitorWALEntry)}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	if err := w.rewrite(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// load reads the records of an existing log file
+func (w *monitorWAL) load() error {
+	file, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open monitor log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry monitorWALEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Seq == 0 {
+			w.skipped++
+			continue
+		}
+		if entry.Seq > w.seq {
+			w.seq = entry.Seq
+		}
+		if entry.Done {
+			delete(w.pending, entry.Seq)
+		} else {
+			w.pending[entry.Seq] = &entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read monitor log: %w", err)
+	}
+	return nil
+}
+
+// rewrite replaces the log file with one holding only the pending changes and
+// opens it for appending
+func (w *monitorWAL) rewrite() error {
+	tmp := w.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite monitor log: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range w.sortedPending() {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to encode monitor log entry: %w", err)
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rewrite monitor log: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("failed to replace monitor log: %w", err)
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open monitor log: %w", err)
+	}
+	w.records = len(w.pending)
+	return nil
+}
+
+// sortedPending returns the pending changes, oldest first. Callers hold w.mu
+// or own w exclusively.
+func (w *monitorWAL) sortedPending() []*monitorWALEntry {
+	entries := make([]*monitorWALEntry, 0, len(w.pending))
+	for _, entry := range w.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// append writes entry to the file and syncs it. Callers hold w.mu.
+func (w *monitorWAL) append(entry *monitorWALEntry) error {
+	if w.file == nil {
+		return errors.New("monitor log is closed")
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode monitor log entry: %w", err)
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write monitor log: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync monitor log: %w", err)
+	}
+	w.records++
+	return nil
+}
+
+// begin records a change before it is applied. The entry is claimed by the
+// caller, which must pass it to end once it tried to apply it.
+//
+// Returns:
+//   - error: If the change could not be written; it is then not replayed
+func (w *monitorWAL) begin(entry *monitorWALEntry) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	entry.Seq = w.seq
+	if err := w.append(entry); err != nil {
+		entry.Seq = 0
+		return err
+	}
+	entry.claimed = true
+	w.pending[entry.Seq] = entry
+	return nil
+}
+
+// end releases a change claimed by begin or claimReplayable. A settled change
+// is marked done; otherwise it is left for the next replay.
+//
+// Returns:
+//   - error: If the done mark could not be written; the change is then
+//     replayed again, which finds it settled
+func (w *monitorWAL) end(entry *monitorWALEntry, settled bool) error {
+	if w == nil || entry.Seq == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry.claimed = false
+	if !settled {
+		return nil
+	}
+	if err := w.append(&monitorWALEntry{Seq: entry.Seq, Done: true}); err != nil {
+		return err
+	}
+	delete(w.pending, entry.Seq)
+	if len(w.pending) == 0 && w.records >= monitorWALCompactRecords {
+		if err := w.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate monitor log: %w", err)
+		}
+		w.records = 0
+	}
+	return nil
+}
+
+// claimReplayable claims the pending changes no goroutine is applying,
+// oldest first
+func (w *monitorWAL) claimReplayable() []*monitorWALEntry {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var entries []*monitorWALEntry
+	for _, entry := range w.sortedPending() {
+		if !entry.claimed {
+			entry.claimed = true
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// pendingCount returns how many changes are waiting to be applied
+func (w *monitorWAL) pendingCount() int {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// close closes the log file; later changes are no longer recorded
+func (w *monitorWAL) close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// transition returns the change as a transition of payment
+func (e *monitorWALEntry) transition(payment *Payment) *PaymentTransition {
+	return &PaymentTransition{
+		Event:    e.Event,
+		From:     e.From,
+		To:       e.To,
+		Payment:  payment,
+		Currency: e.Currency,
+		Amount:   e.Amount,
+		TxID:     e.TxID,
+	}
+}
+
+// replayWAL applies the changes of the monitor log that did not reach the
+// store, e.g. because the process crashed or the store failed after the
+// monitor saw a payment confirm. A change is dropped once the stored payment
+// has left the status the change started from: it was applied, or another
+// process moved the payment on.
+func (m *CryptoChainMonitor) replayWAL() {
+	for _, entry := range m.paywall.monitorWAL.claimReplayable() {
+		settled := m.replayEntry(entry)
+		if err := m.paywall.monitorWAL.end(entry, settled); err != nil {
+			m.paywall.logger.log(LogEntry{
+				Level:     LogLevelWarn,
+				Event:     "monitor_wal_write_failed",
+				Message:   fmt.Sprintf("Failed to mark replayed change done: %v", err),
+				PaymentID: entry.PaymentID,
+			})
+		}
+	}
+}
+
+// replayEntry applies one logged change
+//
+// Returns:
+//   - bool: true if the change is settled and can be dropped from the log
+func (m *CryptoChainMonitor) replayEntry(entry *monitorWALEntry) bool {
+	unlock, err := m.paywall.lockPayment(entry.PaymentID, monitorLockWait)
+	if err != nil {
+		return false
+	}
+	defer unlock()
+
+	payment, err := m.paywall.Store.GetPayment(entry.PaymentID)
+	if err != nil {
+		return false
+	}
+	if payment == nil || payment.Status != entry.From {
+		return true
+	}
+
+	err = m.applyTransition(entry.transition(payment), entry)
+	if errors.Is(err, ErrTransitionVetoed) {
+		return true
+	}
+	if err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "monitor_wal_replay_failed",
+			Message:   fmt.Sprintf("Failed to replay %s of payment, retrying next cycle: %v", entry.Event, err),
+			PaymentID: entry.PaymentID,
+		})
+		return false
+	}
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "monitor_wal_replayed",
+		Message:   fmt.Sprintf("Replayed %s of payment decided at %s", entry.Event, entry.At.Format(time.RFC3339)),
+		PaymentID: entry.PaymentID,
+	})
+	switch entry.Event {
+	case TransitionConfirm:
+		m.announceConfirmed(payment, entry.Amount, entry.Currency)
+	case TransitionExpire:
+		m.announceExpired(payment)
+	}
+	return true
+}
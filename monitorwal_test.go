@@ -0,0 +1,169 @@
+package paywall
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// unavailableUpdateStore fails payment updates while down is set
+type unavailableUpdateStore struct {
+	*MemoryStore
+	down bool
+}
+
+func (s *unavailableUpdateStore) UpdatePayment(p *Payment) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return s.MemoryStore.UpdatePayment(p)
+}
+
+func newWALTestMonitor(t *testing.T, store PaymentStore, path string, balance float64) *CryptoChainMonitor {
+	t.Helper()
+	wal, err := openMonitorWAL(path)
+	if err != nil {
+		t.Fatalf("openMonitorWAL() error = %v", err)
+	}
+	t.Cleanup(func() { wal.close() })
+	return &CryptoChainMonitor{
+		paywall: &Paywall{
+			Store:            store,
+			minConfirmations: 1,
+			logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+			monitorWAL:       wal,
+		},
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: &mockCryptoClient{balance: balance},
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+}
+
+func TestMonitorWAL_ReplaysConfirmationAfterStoreFailure(t *testing.T) {
+	store := &unavailableUpdateStore{MemoryStore: NewMemoryStore(), down: true}
+	payment := &Payment{
+		ID:        "wal-confirm",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	store.CreatePayment(payment)
+	path := filepath.Join(t.TempDir(), "monitor-wal.jsonl")
+
+	monitor := newWALTestMonitor(t, store, path, 0.001)
+	if err := monitor.checkPendingPayments(); err == nil {
+		t.Fatal("checkPendingPayments() succeeded with the store down")
+	}
+	if stored, _ := store.GetPayment(payment.ID); stored.Status != StatusPending {
+		t.Fatalf("status with the store down = %s, want pending", stored.Status)
+	}
+	if pending := monitor.paywall.monitorWAL.pendingCount(); pending != 1 {
+		t.Fatalf("logged changes = %d, want 1", pending)
+	}
+	monitor.paywall.monitorWAL.close()
+
+	// After a restart the confirmation is stored even though the funds are
+	// no longer seen, e.g. because the backend is now out of sync
+	store.down = false
+	restartedAt := time.Now()
+	restarted := newWALTestMonitor(t, store, path, 0)
+	restarted.replayWAL()
+	stored, _ := store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.Bitcoin || stored.Confirmations != 1 {
+		t.Errorf("replayed payment = %s, paid in %q with %d confirmations", stored.Status, stored.PaidCurrency, stored.Confirmations)
+	}
+	if stored.ConfirmedAt.IsZero() || !stored.ConfirmedAt.Before(restartedAt) {
+		t.Errorf("ConfirmedAt = %v, want the time the confirmation was seen", stored.ConfirmedAt)
+	}
+	if pending := restarted.paywall.monitorWAL.pendingCount(); pending != 0 {
+		t.Errorf("logged changes after replay = %d, want 0", pending)
+	}
+	restarted.paywall.monitorWAL.close()
+
+	reopened, err := openMonitorWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.close()
+	if pending := reopened.pendingCount(); pending != 0 {
+		t.Errorf("logged changes after reopening = %d, want 0", pending)
+	}
+}
+
+func TestMonitorWAL_DropsSettledChanges(t *testing.T) {
+	store := NewMemoryStore()
+	store.CreatePayment(&Payment{ID: "already-confirmed", Status: StatusConfirmed, Confirmations: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	monitor := newWALTestMonitor(t, store, filepath.Join(t.TempDir(), "wal.jsonl"), 0)
+	wal := monitor.paywall.monitorWAL
+
+	for _, id := range []string{"already-confirmed", "deleted"} {
+		entry := &monitorWALEntry{PaymentID: id, Event: TransitionConfirm, From: StatusPending, To: StatusConfirmed, At: time.Now()}
+		if err := wal.begin(entry); err != nil {
+			t.Fatal(err)
+		}
+		wal.end(entry, false)
+	}
+	monitor.replayWAL()
+	if pending := wal.pendingCount(); pending != 0 {
+		t.Errorf("logged changes after replay = %d, want 0", pending)
+	}
+	if stored, _ := store.GetPayment("already-confirmed"); stored.Version != 0 {
+		t.Errorf("settled payment rewritten, version = %d", stored.Version)
+	}
+}
+
+func TestMonitorWAL_Open(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	lines := `{"seq":1,"payment_id":"a","event":"confirm","from":"pending","to":"confirmed"}
+{"seq":2,"payment_id":"b","event":"expire","from":"pending","to":"expired"}
+{"seq":1,"done":true}
+{"seq":3,"payment_id":"c","ev`
+	if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	wal, err := openMonitorWAL(path)
+	if err != nil {
+		t.Fatalf("openMonitorWAL() error = %v", err)
+	}
+	if wal.skipped != 1 || wal.pendingCount() != 1 {
+		t.Errorf("skipped = %d, pending = %d, want 1 and 1", wal.skipped, wal.pendingCount())
+	}
+	entry := &monitorWALEntry{PaymentID: "d", Event: TransitionConfirm}
+	if err := wal.begin(entry); err != nil || entry.Seq != 3 {
+		t.Errorf("begin() seq = %d, %v, want 3", entry.Seq, err)
+	}
+	wal.close()
+
+	// Only the changes still pending are kept on disk
+	data, _ := os.ReadFile(path)
+	if got := strings.Count(string(data), "\n"); got != 2 || !strings.Contains(string(data), `"payment_id":"b"`) {
+		t.Errorf("log after rewrite =\n%s", data)
+	}
+	if err := wal.begin(&monitorWALEntry{PaymentID: "e"}); err == nil {
+		t.Error("begin() on a closed log succeeded")
+	}
+}
+
+func TestMonitorWAL_Config(t *testing.T) {
+	_, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		MonitorShadow:  true,
+		MonitorWALPath: filepath.Join(t.TempDir(), "wal.jsonl"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "MonitorWALPath") {
+		t.Errorf("NewPaywall() error = %v, want MonitorWALPath rejected in shadow mode", err)
+	}
+}
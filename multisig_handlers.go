@@ -164,8 +164,7 @@ func (mc *MultisigCoordinator) HandleInitiate(w http.ResponseWriter, r *http.Req
 	}
 
 	var req MultisigInitiateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+	if _, ok := decodeRequestBody(w, r, mc.paywall.requestLimits(), &req, false); !ok {
 		return
 	}
 
@@ -226,8 +225,7 @@ func (mc *MultisigCoordinator) HandleSign(w http.ResponseWriter, r *http.Request
 	}
 
 	var req MultisigSignRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+	if _, ok := decodeRequestBody(w, r, mc.paywall.requestLimits(), &req, false); !ok {
 		return
 	}
 
@@ -366,8 +364,7 @@ func (mc *MultisigCoordinator) HandleBroadcast(w http.ResponseWriter, r *http.Re
 	}
 
 	var req MultisigBroadcastRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+	if _, ok := decodeRequestBody(w, r, mc.paywall.requestLimits(), &req, false); !ok {
 		return
 	}
 
@@ -574,7 +571,7 @@ func (mc *MultisigCoordinator) createMultisigPayment(req *MultisigInitiateReques
 	// requires full wallet implementation (Phases 2-3 of the multisig plan).
 	// For now, create a payment with multisig metadata structure.
 
-	paymentID, err := generatePaymentID()
+	paymentID, err := generatePaymentID(mc.paywall.rand)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate payment ID: %w", err)
 	}
@@ -597,8 +594,12 @@ func (mc *MultisigCoordinator) createMultisigPayment(req *MultisigInitiateReques
 	// Set the required signatures and amounts
 	payment.RequiredSignatures[req.WalletType] = req.RequiredSigs
 
+	if mc.paywall.currencyDisabled(req.WalletType) {
+		return nil, fmt.Errorf("%s is disabled for new payments", req.WalletType)
+	}
+
 	// Set the price based on wallet type
-	if price, ok := mc.paywall.prices[req.WalletType]; ok {
+	if price, ok := mc.paywall.price(req.WalletType); ok {
 		payment.Amounts[req.WalletType] = price * req.PriceMultiplier
 	} else {
 		return nil, fmt.Errorf("price not configured for wallet type: %s", req.WalletType)
@@ -0,0 +1,156 @@
+package paywall
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNATSServer accepts connections speaking enough of the NATS protocol for
+// NATSPublisher and records published messages
+type fakeNATSServer struct {
+	listener net.Listener
+	token    string
+	// messages receives "subject|msg-id|payload" for every published message
+	messages chan string
+	// reject answers publishes with -ERR instead of PONG
+	reject bool
+	// dropAfter closes each connection after this many messages, 0 for never
+	dropAfter int
+}
+
+// start listens on a free local port; configure s before calling it
+func (s *fakeNATSServer) start(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s.listener = listener
+	s.messages = make(chan string, 10)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+}
+
+func (s *fakeNATSServer) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *fakeNATSServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"headers\":true,\"max_payload\":1024}\r\n")
+	r := bufio.NewReader(conn)
+	published := 0
+	for {
+		line, err := readNATSLine(r)
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case line == "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "CONNECT "):
+			var options map[string]interface{}
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &options)
+			if options["auth_token"] != s.token && s.token != "" {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case fields[0] == "HPUB" || fields[0] == "PUB":
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			frame := make([]byte, total+2)
+			io.ReadFull(r, frame)
+			header, payload := "", string(frame[:total])
+			if fields[0] == "HPUB" {
+				headerLen, _ := strconv.Atoi(fields[2])
+				header, payload = payload[:headerLen], payload[headerLen:]
+			}
+			msgID := ""
+			for _, h := range strings.Split(header, "\r\n") {
+				if v, ok := strings.CutPrefix(h, "Nats-Msg-Id: "); ok {
+					msgID = v
+				}
+			}
+			if s.reject {
+				fmt.Fprint(conn, "-ERR 'Permissions Violation for Publish'\r\n")
+				continue
+			}
+			s.messages <- fields[1] + "|" + msgID + "|" + payload
+			published++
+			if s.dropAfter > 0 && published >= s.dropAfter {
+				// Swallow the PING of this message's confirmation, then drop
+				readNATSLine(r)
+				fmt.Fprint(conn, "PONG\r\n")
+				return
+			}
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	server := &fakeNATSServer{token: "s3cret", dropAfter: 1}
+	server.start(t)
+
+	if _, err := NewNATSPublisher(NATSConfig{URL: server.url()}); err == nil {
+		t.Error("NewNATSPublisher() without the token error = nil")
+	}
+	if _, err := NewNATSPublisher(NATSConfig{URL: "http://localhost:4222"}); err == nil {
+		t.Error("NewNATSPublisher() accepted an http:// URL")
+	}
+
+	publisher, err := NewNATSPublisher(NATSConfig{URL: "nats://s3cret@" + server.listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("NewNATSPublisher() error = %v", err)
+	}
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, id := range []string{"evt_1", "evt_2"} {
+		// The server drops the connection after every message, so the second
+		// publish has to reconnect
+		if err := publisher.Publish(ctx, EventMessage{Subject: "paywall.payment_confirmed", ID: id, Data: []byte(`{"n":1}`)}); err != nil {
+			t.Fatalf("Publish() #%d error = %v", i+1, err)
+		}
+		if got, want := <-server.messages, "paywall.payment_confirmed|"+id+`|{"n":1}`; got != want {
+			t.Errorf("server received %q, want %q", got, want)
+		}
+	}
+
+	if err := publisher.Publish(ctx, EventMessage{Subject: "bad subject", Data: []byte("{}")}); err == nil {
+		t.Error("Publish() accepted a subject with a space")
+	}
+	if err := publisher.Publish(ctx, EventMessage{Subject: "paywall.big", Data: make([]byte, 2048)}); err == nil {
+		t.Error("Publish() sent a message above max_payload")
+	}
+}
+
+func TestNATSPublisher_Rejected(t *testing.T) {
+	server := &fakeNATSServer{reject: true}
+	server.start(t)
+	publisher, err := NewNATSPublisher(NATSConfig{URL: server.url()})
+	if err != nil {
+		t.Fatalf("NewNATSPublisher() error = %v", err)
+	}
+	defer publisher.Close()
+	err = publisher.Publish(context.Background(), EventMessage{Subject: "paywall.x", Data: []byte("{}")})
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Publish() error = %v, want the server's -ERR", err)
+	}
+}
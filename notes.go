@@ -0,0 +1,345 @@
+package paywall
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const (
+	// maxNoteLength bounds a single note, in characters
+	maxNoteLength = 2000
+	// maxTagLength bounds a single tag, in characters
+	maxTagLength = 64
+	// maxNotesPerPayment bounds the notes kept on a payment
+	maxNotesPerPayment = 100
+)
+
+// ErrInvalidAnnotation is returned for empty, oversized or malformed notes and tags
+var ErrInvalidAnnotation = errors.New("invalid payment annotation")
+
+// PaymentNote is a free-form operator note on a payment
+type PaymentNote struct {
+	// Text is the note, e.g. "refunded manually 2024-05-01"
+	Text string `json:"text"`
+	// Author identifies the operator who wrote the note, optional
+	Author string `json:"author,omitempty"`
+	// CreatedAt is when the note was added
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PaymentAnnotation is a change to a payment's operator notes and tags
+type PaymentAnnotation struct {
+	// Note is appended to the payment's notes when not empty
+	Note string `json:"note,omitempty"`
+	// Author is recorded with Note
+	Author string `json:"author,omitempty"`
+	// AddTags are added to the payment's tags
+	AddTags []string `json:"add_tags,omitempty"`
+	// RemoveTags are removed from the payment's tags
+	RemoveTags []string `json:"remove_tags,omitempty"`
+}
+
+// HasTag reports whether the payment carries tag (case-insensitive)
+func (payment *Payment) HasTag(tag string) bool {
+	return slices.Contains(payment.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// AnnotatePayment adds an operator note and/or changes the tags of a payment,
+// e.g. to record a manual refund or mark a VIP customer. Annotations are stored
+// with the payment, so every store persists them, and they appear in
+// ListPaymentsTagged and WritePaymentsCSV. They never affect access.
+//
+// Parameters:
+//   - paymentID: ID of the payment to annotate
+//   - annotation: Note to append and tags to add or remove; tags are trimmed and
+//     lowercased, and must not contain commas
+//
+// Returns:
+//   - *Payment: The updated payment
+//   - error: ErrInvalidAnnotation, ErrProofPaymentNotFound, ErrPaymentLocked, or storage errors
+//
+// Related: HandlePaymentNotes
+func (p *Paywall) AnnotatePayment(paymentID string, annotation PaymentAnnotation) (*Payment, error) {
+	note := strings.TrimSpace(annotation.Note)
+	if note == "" && len(annotation.AddTags) == 0 && len(annotation.RemoveTags) == 0 {
+		return nil, fmt.Errorf("%w: nothing to change", ErrInvalidAnnotation)
+	}
+	if utf8.RuneCountInString(note) > maxNoteLength {
+		return nil, fmt.Errorf("%w: note longer than %d characters", ErrInvalidAnnotation, maxNoteLength)
+	}
+	addTags, err := normalizeTags(annotation.AddTags)
+	if err != nil {
+		return nil, err
+	}
+	removeTags, err := normalizeTags(annotation.RemoveTags)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := p.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if note != "" {
+		if len(payment.Notes) >= maxNotesPerPayment {
+			return nil, fmt.Errorf("%w: payment already has %d notes", ErrInvalidAnnotation, maxNotesPerPayment)
+		}
+		payment.Notes = append(payment.Notes, PaymentNote{
+			Text:      note,
+			Author:    strings.TrimSpace(annotation.Author),
+			CreatedAt: time.Now(),
+		})
+	}
+	for _, tag := range addTags {
+		if !slices.Contains(payment.Tags, tag) {
+			payment.Tags = append(payment.Tags, tag)
+		}
+	}
+	payment.Tags = slices.DeleteFunc(payment.Tags, func(tag string) bool {
+		return slices.Contains(removeTags, tag)
+	})
+	slices.Sort(payment.Tags)
+	if len(payment.Tags) == 0 {
+		payment.Tags = nil
+	}
+
+	if err := p.Store.UpdatePayment(payment); err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "payment_annotated",
+		Message:   fmt.Sprintf("Payment annotated (tags: %s)", strings.Join(payment.Tags, ",")),
+		PaymentID: paymentID,
+	})
+	return payment, nil
+}
+
+// normalizeTags trims and lowercases tags, rejecting empty, long or comma-separated ones
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		switch {
+		case tag == "":
+			return nil, fmt.Errorf("%w: empty tag", ErrInvalidAnnotation)
+		case utf8.RuneCountInString(tag) > maxTagLength:
+			return nil, fmt.Errorf("%w: tag %q longer than %d characters", ErrInvalidAnnotation, tag, maxTagLength)
+		case strings.ContainsAny(tag, ",\r\n"):
+			return nil, fmt.Errorf("%w: tag %q contains a comma or line break", ErrInvalidAnnotation, tag)
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// ListPaymentsTagged returns the stored payments carrying tag, oldest first.
+// An empty tag returns every payment that has notes or tags.
+//
+// Returns:
+//   - []*Payment: Matching payments
+//   - error: If the store implements neither PaymentStreamer nor PaymentLister, or listing fails
+func (p *Paywall) ListPaymentsTagged(tag string) ([]*Payment, error) {
+	var payments []*Payment
+	err := StreamPayments(p.Store, PaymentFilter{Tag: tag}, func(payment *Payment) error {
+		if tag != "" || len(payment.Notes) > 0 || len(payment.Tags) > 0 {
+			payments = append(payments, payment)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream payments: %w", err)
+	}
+	slices.SortFunc(payments, func(a, b *Payment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return payments, nil
+}
+
+// WritePaymentsCSV writes payments with their tags and notes as CSV, one row
+// per payment. Tags are comma-separated; notes are joined with " | " and
+// prefixed with their date and author.
+//
+// Parameters:
+//   - w: Destination writer (e.g. an http.ResponseWriter for downloads)
+//   - payments: Payments to export, e.g. from ListPaymentsTagged
+//
+// Returns:
+//   - error: If writing fails
+func WritePaymentsCSV(w io.Writer, payments []*Payment) error {
+	cw := csv.NewWriter(w)
+	header := []string{"id", "status", "created_at", "confirmed_at", "paid_currency", "amount_btc", "amount_xmr", "tags", "notes"}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("write CSV header: %w", err)
+	}
+	for _, payment := range payments {
+		confirmedAt := ""
+		if !payment.ConfirmedAt.IsZero() {
+			confirmedAt = payment.ConfirmedAt.UTC().Format(time.RFC3339)
+		}
+		notes := make([]string, 0, len(payment.Notes))
+		for _, note := range payment.Notes {
+			prefix := note.CreatedAt.UTC().Format("2006-01-02")
+			if note.Author != "" {
+				prefix += " " + note.Author
+			}
+			notes = append(notes, prefix+": "+note.Text)
+		}
+		row := []string{
+			payment.ID,
+			string(payment.Status),
+			payment.CreatedAt.UTC().Format(time.RFC3339),
+			confirmedAt,
+			string(payment.PaidCurrency),
+			strconv.FormatFloat(payment.Amounts[wallet.Bitcoin], 'f', -1, 64),
+			strconv.FormatFloat(payment.Amounts[wallet.Monero], 'f', -1, 64),
+			strings.Join(payment.Tags, ","),
+			strings.Join(notes, " | "),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write CSV row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("write CSV: %w", err)
+	}
+	return nil
+}
+
+// paymentNotesResponse is the JSON body returned by HandlePaymentNotes
+type paymentNotesResponse struct {
+	ID    string        `json:"id"`
+	Tags  []string      `json:"tags"`
+	Notes []PaymentNote `json:"notes"`
+}
+
+// paymentNotesRequest is the JSON body accepted by HandlePaymentNotes
+type paymentNotesRequest struct {
+	ID string `json:"id"`
+	PaymentAnnotation
+}
+
+// HandlePaymentNotes reads (GET ?id=<payment id>) and changes (POST with a
+// PaymentAnnotation plus "id") a payment's operator notes and tags. GET with
+// ?format=csv and an optional &tag= instead exports the annotated payments.
+// It performs no authentication of its own: mount it on an admin listener or
+// behind your admin authentication.
+//
+// Responses:
+//   - 200 with {"id", "tags", "notes"}, or text/csv for exports
+//   - 400 Bad Request for missing IDs or invalid notes and tags
+//   - 404 Not Found if the payment does not exist
+//   - 405 Method Not Allowed for other methods
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 503 Service Unavailable if another operation holds the payment's lock
+func (p *Paywall) HandlePaymentNotes(w http.ResponseWriter, r *http.Request) {
+	var payment *Payment
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if r.URL.Query().Get("format") == "csv" {
+			p.exportPaymentNotes(w, r)
+			return
+		}
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if payment, err = p.Store.GetPayment(id); err != nil {
+			http.Error(w, "Failed to load payment", http.StatusInternalServerError)
+			return
+		}
+		if payment == nil {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		var req paymentNotesRequest
+		if _, ok := decodeRequestBody(w, r, p.requestLimits(), &req, false); !ok {
+			return
+		}
+		if req.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		var err error
+		payment, err = p.AnnotatePayment(req.ID, req.PaymentAnnotation)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInvalidAnnotation):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, ErrProofPaymentNotFound):
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrPaymentLocked):
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Payment is busy, please retry", http.StatusServiceUnavailable)
+			return
+		default:
+			http.Error(w, "Failed to annotate payment", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := paymentNotesResponse{ID: payment.ID, Tags: payment.Tags, Notes: payment.Notes}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if resp.Notes == nil {
+		resp.Notes = []PaymentNote{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "response_encoding_failed",
+			Message:   fmt.Sprintf("Failed to encode payment notes response: %v", err),
+			PaymentID: payment.ID,
+		})
+	}
+}
+
+// exportPaymentNotes writes the annotated payments, optionally filtered by ?tag=, as CSV
+func (p *Paywall) exportPaymentNotes(w http.ResponseWriter, r *http.Request) {
+	payments, err := p.ListPaymentsTagged(r.URL.Query().Get("tag"))
+	if err != nil {
+		http.Error(w, "Failed to list payments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="payments.csv"`)
+	w.Header().Set("Cache-Control", "no-store")
+	if err := WritePaymentsCSV(w, payments); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to write payments CSV: %v", err),
+		})
+	}
+}
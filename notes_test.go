@@ -0,0 +1,176 @@
+package paywall
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestAnnotatePayment(t *testing.T) {
+	tests := []struct {
+		name  string
+		store PaymentStore
+	}{
+		{"memory store", NewMemoryStore()},
+		{"file store", NewFileStore(t.TempDir())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw := &Paywall{Store: tt.store, logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+			tt.store.CreatePayment(&Payment{
+				ID:        "pay-1",
+				Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+				Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+				Status:    StatusConfirmed,
+				CreatedAt: time.Now(),
+			})
+
+			if _, err := pw.AnnotatePayment("pay-1", PaymentAnnotation{
+				Note:    "  refunded manually 2024-05-01 ",
+				Author:  "alice",
+				AddTags: []string{"Refunded", " VIP "},
+			}); err != nil {
+				t.Fatalf("AnnotatePayment() error = %v", err)
+			}
+			if _, err := pw.AnnotatePayment("pay-1", PaymentAnnotation{AddTags: []string{"vip"}, RemoveTags: []string{"REFUNDED"}}); err != nil {
+				t.Fatalf("AnnotatePayment() error = %v", err)
+			}
+
+			stored, _ := tt.store.GetPayment("pay-1")
+			if got := strings.Join(stored.Tags, ","); got != "vip" {
+				t.Errorf("tags = %q, want vip", got)
+			}
+			if len(stored.Notes) != 1 || stored.Notes[0].Text != "refunded manually 2024-05-01" || stored.Notes[0].Author != "alice" || stored.Notes[0].CreatedAt.IsZero() {
+				t.Errorf("notes = %+v", stored.Notes)
+			}
+			if !stored.HasTag("VIP") {
+				t.Error("HasTag(VIP) = false")
+			}
+		})
+	}
+}
+
+func TestAnnotatePayment_Invalid(t *testing.T) {
+	pw := &Paywall{Store: NewMemoryStore(), logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	pw.Store.CreatePayment(&Payment{ID: "pay-1", Status: StatusPending})
+
+	tests := []struct {
+		name       string
+		paymentID  string
+		annotation PaymentAnnotation
+		want       error
+	}{
+		{"nothing to change", "pay-1", PaymentAnnotation{Note: "  "}, ErrInvalidAnnotation},
+		{"long note", "pay-1", PaymentAnnotation{Note: strings.Repeat("x", maxNoteLength+1)}, ErrInvalidAnnotation},
+		{"empty tag", "pay-1", PaymentAnnotation{AddTags: []string{" "}}, ErrInvalidAnnotation},
+		{"tag with comma", "pay-1", PaymentAnnotation{AddTags: []string{"a,b"}}, ErrInvalidAnnotation},
+		{"unknown payment", "missing", PaymentAnnotation{Note: "hello"}, ErrProofPaymentNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pw.AnnotatePayment(tt.paymentID, tt.annotation); !errors.Is(err, tt.want) {
+				t.Errorf("AnnotatePayment() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPaymentsTagged_WritePaymentsCSV(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{Store: store, logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	now := time.Now()
+	store.CreatePayment(&Payment{ID: "plain", CreatedAt: now.Add(-3 * time.Hour)})
+	store.CreatePayment(&Payment{ID: "noted", CreatedAt: now.Add(-2 * time.Hour)})
+	store.CreatePayment(&Payment{
+		ID:           "vip",
+		CreatedAt:    now.Add(-time.Hour),
+		Status:       StatusConfirmed,
+		PaidCurrency: wallet.Bitcoin,
+		Amounts:      map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+	})
+	pw.AnnotatePayment("noted", PaymentAnnotation{Note: "asked for an invoice"})
+	pw.AnnotatePayment("vip", PaymentAnnotation{Note: "long-time reader, say thanks", Author: "bob", AddTags: []string{"vip", "press"}})
+
+	tagged, err := pw.ListPaymentsTagged("VIP")
+	if err != nil || len(tagged) != 1 || tagged[0].ID != "vip" {
+		t.Fatalf("ListPaymentsTagged(VIP) = %v, %v", tagged, err)
+	}
+	annotated, _ := pw.ListPaymentsTagged("")
+	if len(annotated) != 2 || annotated[0].ID != "noted" || annotated[1].ID != "vip" {
+		t.Fatalf("ListPaymentsTagged(\"\") returned %d payments", len(annotated))
+	}
+
+	var buf bytes.Buffer
+	if err := WritePaymentsCSV(&buf, annotated); err != nil {
+		t.Fatalf("WritePaymentsCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("CSV has %d records, want header and 2 rows", len(records))
+	}
+	row := records[2]
+	if row[0] != "vip" || row[4] != "BTC" || row[5] != "0.001" || row[7] != "press,vip" || !strings.HasSuffix(row[8], " bob: long-time reader, say thanks") {
+		t.Errorf("CSV row = %q", row)
+	}
+}
+
+func TestHandlePaymentNotes(t *testing.T) {
+	pw := &Paywall{Store: NewMemoryStore(), logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	pw.Store.CreatePayment(&Payment{ID: "pay-1", Status: StatusConfirmed})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantTags string
+	}{
+		{"read without notes", http.MethodGet, "/admin/notes?id=pay-1", "", http.StatusOK, ""},
+		{"annotate", http.MethodPost, "/admin/notes", `{"id":"pay-1","note":"VIP customer","add_tags":["vip"]}`, http.StatusOK, "vip"},
+		{"read", http.MethodGet, "/admin/notes?id=pay-1", "", http.StatusOK, "vip"},
+		{"missing id", http.MethodGet, "/admin/notes", "", http.StatusBadRequest, ""},
+		{"unknown payment", http.MethodPost, "/admin/notes", `{"id":"nope","note":"x"}`, http.StatusNotFound, ""},
+		{"invalid tag", http.MethodPost, "/admin/notes", `{"id":"pay-1","add_tags":[""]}`, http.StatusBadRequest, ""},
+		{"wrong method", http.MethodDelete, "/admin/notes?id=pay-1", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			pw.HandlePaymentNotes(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var resp paymentNotesResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got := strings.Join(resp.Tags, ","); got != tt.wantTags {
+				t.Errorf("tags = %q, want %q", got, tt.wantTags)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/notes?format=csv&tag=vip", nil)
+	rec := httptest.NewRecorder()
+	pw.HandlePaymentNotes(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") || !strings.Contains(rec.Body.String(), "VIP customer") {
+		t.Errorf("CSV export = %d %q", rec.Code, rec.Body.String())
+	}
+}
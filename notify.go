@@ -0,0 +1,145 @@
+package paywall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Notifier is told about payment events, e.g. to credit an order in a billing
+// system once its payment confirmed. WebhookNotifier implements it for HTTP
+// endpoints; implement it to call your billing system directly:
+//
+//	type billing struct{ client *billingapi.Client }
+//
+//	func (b billing) Notify(ctx context.Context, event paywall.PaymentEvent) error {
+//	    if event.Event != paywall.EventPaymentConfirmed {
+//	        return nil
+//	    }
+//	    return b.client.MarkPaid(ctx, event.PaymentID)
+//	}
+//
+// Related: NotifierConfig, Config.Notifiers
+type Notifier interface {
+	// Notify delivers event and returns once it was accepted. Returning an
+	// error makes the paywall retry the event, with the same event ID.
+	Notify(ctx context.Context, event PaymentEvent) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, event PaymentEvent) error
+
+// Notify implements Notifier
+func (f NotifierFunc) Notify(ctx context.Context, event PaymentEvent) error {
+	return f(ctx, event)
+}
+
+// NotifierConfig configures one Notifier of Config.Notifiers. Each notifier
+// has a queue of its own, so a slow or failing one does not hold up the others.
+type NotifierConfig struct {
+	// Notifier delivers the events, e.g. a *WebhookNotifier
+	Notifier Notifier
+	// EnabledEvents selects the delivered events.
+	// Optional: defaults to EventPaymentConfirmed and EventPaymentExpired.
+	EnabledEvents []WebhookEventType
+	// MaxRetries is how often a failed delivery is retried. Optional: defaults to 3.
+	MaxRetries int
+	// RetryBackoff is the initial delay between retries, doubling on each retry.
+	// Optional: defaults to 1 second.
+	RetryBackoff time.Duration
+	// Timeout bounds a single Notify call. Optional: defaults to 10 seconds.
+	Timeout time.Duration
+	// QueueSize bounds events waiting to be delivered; events are dropped and
+	// logged when the queue is full. Optional: defaults to 1000.
+	QueueSize int
+}
+
+// defaultNotifierEvents are delivered to notifiers without EnabledEvents
+var defaultNotifierEvents = []WebhookEventType{EventPaymentConfirmed, EventPaymentExpired}
+
+// newNotifierSink starts delivering the events config enables to its Notifier
+func newNotifierSink(config NotifierConfig, logger *StructuredLogger, random io.Reader) *eventSink {
+	events := config.EnabledEvents
+	if len(events) == 0 {
+		events = defaultNotifierEvents
+	}
+	s := &eventSink{}
+	return s.start(EventSinkConfig{
+		EnabledEvents: events,
+		MaxRetries:    config.MaxRetries,
+		RetryBackoff:  config.RetryBackoff,
+		Timeout:       config.Timeout,
+		QueueSize:     config.QueueSize,
+	}, config.Notifier.Notify, logger, random)
+}
+
+// WebhookNotifier POSTs each event as a JSON PaymentEvent to an HTTP endpoint,
+// signed with HMAC-SHA256 of the body under Secret in the X-Webhook-Signature
+// header (hex encoded; check it with VerifyWebhookSignature). The event type and
+// ID are also sent as X-Webhook-Event and X-Webhook-ID headers.
+//
+// Retried deliveries carry the same event ID, so receivers should ignore IDs
+// they already processed; rejecting events with an old Timestamp guards against
+// replayed requests. Any response other than 2xx counts as a failed delivery.
+type WebhookNotifier struct {
+	// URL is the http or https endpoint receiving the events
+	URL string
+	// Secret signs the requests, e.g. from GenerateWebhookSecret
+	Secret string
+	// Client sends the requests. Optional: defaults to http.DefaultClient;
+	// NotifierConfig.Timeout bounds each request.
+	Client *http.Client
+}
+
+// Validate checks the notifier's URL and secret
+//
+// Returns:
+//   - error: If the URL is not an absolute http or https URL or Secret is empty
+func (n *WebhookNotifier) Validate() error {
+	u, err := url.Parse(n.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook notifier URL %q must be an absolute http or https URL", n.URL)
+	}
+	if n.Secret == "" {
+		return fmt.Errorf("webhook notifier for %s has no Secret (hint: use paywall.GenerateWebhookSecret())", u.Host)
+	}
+	return nil
+}
+
+// Notify implements Notifier
+func (n *WebhookNotifier) Notify(ctx context.Context, event PaymentEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "paywall-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", string(event.Event))
+	req.Header.Set("X-Webhook-ID", event.ID)
+	req.Header.Set("X-Webhook-Signature", signWebhookBody(body, n.Secret))
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post event to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post event to %s: unexpected status code %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
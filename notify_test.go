@@ -0,0 +1,135 @@
+package paywall
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestWebhookNotifier_PaymentExpired(t *testing.T) {
+	secret := "notifier-secret"
+	var (
+		mu       sync.Mutex
+		attempts int
+		bodies   [][]byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if !VerifyWebhookSignature(body, r.Header.Get("X-Webhook-Signature"), secret) || r.Header.Get("X-Webhook-Event") != string(EventPaymentExpired) {
+			t.Errorf("request headers %v do not sign the body", r.Header)
+		}
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	pw := newTestPaywall(t, Config{
+		Logger: NewStructuredLogger(io.Discard, LogLevelError, true),
+		Notifiers: []NotifierConfig{{
+			Notifier:     &WebhookNotifier{URL: server.URL, Secret: secret},
+			RetryBackoff: time.Millisecond,
+		}},
+	})
+	// payment_created is not delivered by default
+	if _, err := pw.CreatePayment(); err != nil {
+		t.Fatal(err)
+	}
+	pw.Store.CreatePayment(&Payment{
+		ID:        "expiring",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt: time.Now().Add(-time.Hour),
+		Status:    StatusPending,
+	})
+	payment, _ := pw.Store.GetPayment("expiring")
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{}}
+	monitor.expirePayment(payment)
+	deadline := time.Now().Add(5 * time.Second)
+	for delivered := 0; delivered == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		delivered = len(bodies)
+		mu.Unlock()
+	}
+	pw.Close()
+
+	if attempts != 2 || len(bodies) != 1 {
+		t.Fatalf("%d attempts delivered %d events, want 2 attempts delivering 1", attempts, len(bodies))
+	}
+	var event PaymentEvent
+	if err := json.Unmarshal(bodies[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Event != EventPaymentExpired || event.PaymentID != "expiring" || event.ID == "" || event.Data["amounts"] == nil {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestNotifierFunc_EnabledEvents(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []PaymentEvent
+	)
+	pw := newTestPaywall(t, Config{
+		Logger: NewStructuredLogger(io.Discard, LogLevelError, true),
+		Notifiers: []NotifierConfig{{
+			Notifier: NotifierFunc(func(_ context.Context, event PaymentEvent) error {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+				return nil
+			}),
+			EnabledEvents: []WebhookEventType{EventPaymentCreated},
+		}},
+	})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+
+	if len(events) != 1 || events[0].Event != EventPaymentCreated || events[0].PaymentID != payment.ID {
+		t.Errorf("events = %+v, want the payment_created event of %s", events, payment.ID)
+	}
+}
+
+func TestNotifiers_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		notifier NotifierConfig
+		wantErr  string
+	}{
+		{name: "no notifier", notifier: NotifierConfig{}, wantErr: "has no Notifier"},
+		{name: "relative url", notifier: NotifierConfig{Notifier: &WebhookNotifier{URL: "/hooks", Secret: "s"}}, wantErr: "absolute http or https URL"},
+		{name: "no secret", notifier: NotifierConfig{Notifier: &WebhookNotifier{URL: "https://billing.example/hooks"}}, wantErr: "no Secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPaywall(Config{
+				PriceInBTC:     0.001,
+				PaymentTimeout: time.Hour,
+				TestNet:        true,
+				Store:          NewMemoryStore(),
+				Notifiers:      []NotifierConfig{tt.notifier},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPaywall() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
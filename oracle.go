@@ -0,0 +1,37 @@
+package paywall
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// defaultFiatCurrency is used when Config.FiatCurrency is empty
+const defaultFiatCurrency = "USD"
+
+// PriceOracle converts cryptocurrency amounts to fiat values.
+// Implementations typically wrap an exchange-rate API and should cache results;
+// the paywall may call FiatPrice once per payment when building reports.
+//
+// Related: Config.PriceOracle, StaticPriceOracle
+type PriceOracle interface {
+	// FiatPrice returns the value of one unit of currency in the fiat currency
+	// (ISO 4217 code such as "USD") at the given time.
+	// Returns error if no rate is available.
+	FiatPrice(currency wallet.WalletType, fiat string, at time.Time) (float64, error)
+}
+
+// StaticPriceOracle is a PriceOracle with fixed rates per currency, ignoring the
+// requested fiat currency and time. Useful for tests, previews and deployments
+// that price content manually.
+type StaticPriceOracle map[wallet.WalletType]float64
+
+// FiatPrice returns the fixed rate for currency
+func (s StaticPriceOracle) FiatPrice(currency wallet.WalletType, fiat string, at time.Time) (float64, error) {
+	rate, ok := s[currency]
+	if !ok {
+		return 0, fmt.Errorf("no %s rate for %s", fiat, currency)
+	}
+	return rate, nil
+}
@@ -0,0 +1,78 @@
+package paywall
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// fallbackPageTemplate is the minimal payment page served when the payment
+// template fails to execute. It only uses fields every payment has, so a
+// broken custom template degrades the page instead of taking the paywall down.
+var fallbackPageTemplate = template.Must(template.New("fallback").Funcs(template.FuncMap{
+	"amount": func(amount float64) string { return strconv.FormatFloat(amount, 'f', -1, 64) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Payment Required</title>
+</head>
+<body>
+<main style="max-width:40rem;margin:2rem auto;font-family:sans-serif">
+<h1>Payment Required</h1>
+{{if .Detected}}<p><strong>Transaction detected.</strong> Waiting for confirmations; reload this page to check.</p>{{end}}
+<p>Send exactly one of the following payments:</p>
+{{if .BTCAddress}}
+<h2>Bitcoin</h2>
+<p>Amount: <code>{{amount .AmountBTC}}</code> BTC</p>
+<p>Address: <code style="word-break:break-all">{{.BTCAddress}}</code></p>
+{{if .BTCPaymentURI}}<p><a href="{{.BTCPaymentURI}}">Open in wallet</a></p>{{end}}
+{{end}}
+{{if .XMRAddress}}
+<h2>Monero</h2>
+<p>Amount: <code>{{amount .AmountXMR}}</code> XMR</p>
+<p>Address: <code style="word-break:break-all">{{.XMRAddress}}</code></p>
+{{if .XMRPaymentURI}}<p><a href="{{.XMRPaymentURI}}">Open in wallet</a></p>{{end}}
+{{end}}
+<p>Payment expires at {{.ExpiresAt}}. Reload this page after paying.</p>
+<p>Payment ID: <code>{{.PaymentID}}</code></p>
+</main>
+</body>
+</html>`))
+
+// executePaymentPage renders the payment page for payment, shown for the
+// protected request r (which may be nil). The page is rendered into a buffer
+// so a template that fails halfway never reaches the visitor: on a template
+// error the failure is logged and the built-in fallback page is returned.
+//
+// Parameters:
+//   - r: The protected request, or nil
+//   - payment: Payment to render
+//
+// Returns:
+//   - []byte: The rendered page
+//   - error: Only if the fallback page fails as well
+func (p *Paywall) executePaymentPage(r *http.Request, payment *Payment) ([]byte, error) {
+	data := p.paymentPageData(r, payment)
+
+	var page bytes.Buffer
+	err := p.template.Execute(&page, data)
+	if err == nil {
+		return page.Bytes(), nil
+	}
+	p.logger.log(LogEntry{
+		Level:     LogLevelError,
+		Event:     "template_render_failed",
+		Message:   fmt.Sprintf("Payment template %q failed, serving the fallback page: %v", p.template.Name(), err),
+		PaymentID: payment.ID,
+	})
+
+	page.Reset()
+	if err := fallbackPageTemplate.Execute(&page, data); err != nil {
+		return nil, fmt.Errorf("render fallback payment page: %w", err)
+	}
+	return page.Bytes(), nil
+}
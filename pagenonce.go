@@ -0,0 +1,45 @@
+package paywall
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pageNonceMaxAge is how old a payment page may be when it loads before the
+// page treats itself as a stale cached copy and reloads. It is generous so
+// visitors with skewed clocks are not sent into reloads.
+const pageNonceMaxAge = 5 * time.Minute
+
+// pageNonce signs the payment page rendered for payment at issued. The nonce
+// binds the page to the payment ID and expiry it shows, so a copy served from a
+// proxy or browser cache can be told apart from the visitor's current payment.
+//
+// Format: <issued unix seconds>.<signature>
+func (p *Paywall) pageNonce(payment *Payment, issued time.Time) string {
+	ts := strconv.FormatInt(issued.Unix(), 10)
+	return ts + "." + p.signer.sign("page/v1", payment.ID, strconv.FormatInt(payment.ExpiresAt.Unix(), 10), ts)
+}
+
+// pageIsCurrent reports whether nonce was issued for payment as it is now: the
+// same payment ID and expiry. A page whose payment was replaced, extended or
+// expired is stale.
+func (p *Paywall) pageIsCurrent(nonce string, payment *Payment, now time.Time) bool {
+	return p.pageNonceValid(nonce, payment) && now.Before(payment.ExpiresAt)
+}
+
+// pageNonceValid reports whether nonce was signed for payment's ID and expiry,
+// i.e. comes from a payment page rendered for the payment's owner
+func (p *Paywall) pageNonceValid(nonce string, payment *Payment) bool {
+	if p.signer == nil {
+		return false
+	}
+	ts, sig, ok := strings.Cut(nonce, ".")
+	if !ok {
+		return false
+	}
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		return false
+	}
+	return p.signer.verify(sig, "page/v1", payment.ID, strconv.FormatInt(payment.ExpiresAt.Unix(), 10), ts)
+}
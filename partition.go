@@ -0,0 +1,93 @@
+package paywall
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// partitionSeparator ends the partition prefix of a payment ID. Partition
+	// names and the random part of IDs never contain it.
+	partitionSeparator = "_"
+	// maxPartitionLength bounds partition names, which end up in cookies and file names
+	maxPartitionLength = 32
+)
+
+// ValidatePartition checks a partition name for WithPartition and
+// CreatePaymentInPartition: 1 to 32 lowercase letters, digits or hyphens.
+//
+// Returns:
+//   - error: If the name is empty, too long or contains other characters
+func ValidatePartition(name string) error {
+	if name == "" || len(name) > maxPartitionLength {
+		return fmt.Errorf("partition name must be 1 to %d characters, got %q", maxPartitionLength, name)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("partition name %q may only contain a-z, 0-9 and '-'", name)
+		}
+	}
+	return nil
+}
+
+// PaymentPartition returns the partition a payment ID was created in, "" for
+// payments created without one
+//
+// Parameters:
+//   - paymentID: ID of the payment
+//
+// Related: WithPartition, PaymentFilter.Partition
+func PaymentPartition(paymentID string) string {
+	partition, _, found := strings.Cut(paymentID, partitionSeparator)
+	if !found || ValidatePartition(partition) != nil {
+		return ""
+	}
+	return partition
+}
+
+// partitionIDPrefix is the ID prefix of every payment in partition, "" for
+// all payments
+func partitionIDPrefix(partition string) string {
+	if partition == "" {
+		return ""
+	}
+	return partition + partitionSeparator
+}
+
+// WithPartition creates the route's payments in a partition: their IDs start
+// with the partition name (e.g. "articles_3f2a..."), so housekeeping such as
+// per-route revenue, cleanup or quotas can select them with
+// PaymentFilter.Partition. FileStore, EncryptedFileStore and S3Store select
+// them by file or object name, and SQLStore by ID prefix, without reading
+// other payments.
+//
+// Partitions are labels, not access boundaries: a confirmed payment grants
+// access on every route of the paywall priced alike (see WithRoute).
+//
+// Parameters:
+//   - name: Partition name, see ValidatePartition; the function panics on
+//     invalid names, like http.ServeMux on invalid patterns
+func WithPartition(name string) MiddlewareOption {
+	if err := ValidatePartition(name); err != nil {
+		panic(fmt.Sprintf("paywall: WithPartition: %v", err))
+	}
+	return func(cfg *middlewareConfig) {
+		cfg.partition = name
+	}
+}
+
+// CreatePaymentInPartition is CreatePayment for payments in a partition, see
+// WithPartition
+//
+// Parameters:
+//   - partition: Partition name, see ValidatePartition
+//
+// Returns:
+//   - *Payment: The new payment; its ID starts with the partition name
+//   - error: If the name is invalid or payment creation fails
+func (p *Paywall) CreatePaymentInPartition(partition string) (*Payment, error) {
+	if err := ValidatePartition(partition); err != nil {
+		return nil, err
+	}
+	return p.createPayment(nil, partition, nil)
+}
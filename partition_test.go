@@ -0,0 +1,103 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestPaymentPartition(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "articles_3f2a9c1b7d4e5f60", want: "articles"},
+		{id: "api-v2_3f2a9c1b7d4e5f60", want: "api-v2"},
+		{id: "3f2a9c1b7d4e5f60", want: ""},
+		{id: "Articles_3f2a", want: ""},
+		{id: "_3f2a", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := PaymentPartition(tt.id); got != tt.want {
+				t.Errorf("PaymentPartition(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+
+	for _, name := range []string{"", "a_b", "a/b", "UPPER", strings.Repeat("a", maxPartitionLength+1)} {
+		if err := ValidatePartition(name); err == nil {
+			t.Errorf("ValidatePartition(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestWithPartition(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithPartition("articles"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/article", nil))
+	var paymentID string
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "payment_id" {
+			paymentID = cookie.Value
+		}
+	}
+	if PaymentPartition(paymentID) != "articles" {
+		t.Fatalf("payment ID %q is not in partition articles", paymentID)
+	}
+	payment, err := pw.Store.GetPayment(paymentID)
+	if err != nil || payment == nil {
+		t.Fatalf("GetPayment(%q) = %v, %v", paymentID, payment, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithPartition with an invalid name did not panic")
+		}
+	}()
+	WithPartition("no_underscores")
+}
+
+func TestStreamPayments_Partition(t *testing.T) {
+	s3Store, _ := newTestS3Store(t)
+	stores := map[string]PaymentStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+		"s3":     s3Store,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, id := range []string{"articles_aa01", "articles_aa02", "api_bb01", "cc01"} {
+				err := store.CreatePayment(&Payment{
+					ID:        id,
+					Addresses: map[wallet.WalletType]string{wallet.Bitcoin: id + "-address"},
+					Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+					CreatedAt: time.Now(),
+					ExpiresAt: time.Now().Add(time.Hour),
+					Status:    StatusPending,
+				})
+				if err != nil {
+					t.Fatalf("CreatePayment(%s) error = %v", id, err)
+				}
+			}
+
+			var got []string
+			err := StreamPayments(store, PaymentFilter{Partition: "articles"}, func(payment *Payment) error {
+				got = append(got, payment.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("StreamPayments() error = %v", err)
+			}
+			if len(got) != 2 || !strings.HasPrefix(got[0], "articles_") || !strings.HasPrefix(got[1], "articles_") {
+				t.Errorf("streamed %v, want the two articles payments", got)
+			}
+		})
+	}
+}
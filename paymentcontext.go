@@ -0,0 +1,97 @@
+package paywall
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// AccessMethod tells how a request proved that it was paid for
+type AccessMethod string
+
+const (
+	// AccessCookie is a confirmed payment in the payment_id cookie
+	AccessCookie AccessMethod = "cookie"
+	// AccessQueryToken is a signed pw_token query parameter (Config.QueryTokenEnabled)
+	AccessQueryToken AccessMethod = "query_token"
+	// AccessAPIKey is an API key (Config.APIKeysEnabled)
+	AccessAPIKey AccessMethod = "api_key"
+	// AccessGrantCookie is a signed access grant cookie (Config.AccessGrantTTL)
+	AccessGrantCookie AccessMethod = "access_grant"
+	// AccessBearerToken is an access grant sent as an Authorization bearer
+	// token (Config.BearerTokensEnabled)
+	AccessBearerToken AccessMethod = "bearer_token"
+)
+
+// PaymentInfo describes the payment a request was let through for. The
+// middleware attaches it to the request context of paid requests; protected
+// handlers read it with FromContext to personalize content, log the payment
+// or apply entitlements such as tags.
+type PaymentInfo struct {
+	// PaymentID identifies the payment; empty for API keys issued by the
+	// operator rather than bought with a payment
+	PaymentID string
+	// Method is how the request presented the payment
+	Method AccessMethod
+	// APIKeyID is the ID of the API key used, set for AccessAPIKey
+	APIKeyID string
+	// Route is the WithRoute name the payment was made on, "" for the
+	// paywall's default pricing
+	Route string
+	// Partition is the partition of the payment ID, see PaymentPartition
+	Partition string
+	// Currency is the currency that settled the payment, when recorded
+	Currency wallet.WalletType
+	// Amount is the amount asked in Currency
+	Amount float64
+	// ConfirmedAt is when the payment was confirmed, when recorded
+	ConfirmedAt time.Time
+	// AccessEndsAt is when the payment stops granting access
+	AccessEndsAt time.Time
+	// Tags are the operator tags of the payment, e.g. "vip"
+	Tags []string
+}
+
+// paymentInfoKey is the context key of the request's PaymentInfo
+type paymentInfoKey struct{}
+
+// FromContext returns the payment a protected request was let through for.
+//
+// For API keys only PaymentID, Method and APIKeyID are set, as the key's
+// payment is not loaded from the store. Access grants and bearer tokens
+// likewise only set PaymentID, Method, Route, Partition and AccessEndsAt (the
+// grant's end).
+//
+// Parameters:
+//   - ctx: The request context seen by the protected handler
+//
+// Returns:
+//   - *PaymentInfo: The payment; callers must not modify it
+//   - bool: false for requests served without a payment (free methods,
+//     metered allowance, ModeBypass) or outside the middleware
+func FromContext(ctx context.Context) (*PaymentInfo, bool) {
+	info, ok := ctx.Value(paymentInfoKey{}).(*PaymentInfo)
+	return info, ok
+}
+
+// newPaymentInfo describes payment presented with method
+func newPaymentInfo(payment *Payment, method AccessMethod) *PaymentInfo {
+	return &PaymentInfo{
+		PaymentID:    payment.ID,
+		Method:       method,
+		Route:        payment.Route,
+		Partition:    PaymentPartition(payment.ID),
+		Currency:     payment.PaidCurrency,
+		Amount:       payment.Amounts[payment.PaidCurrency],
+		ConfirmedAt:  payment.ConfirmedAt,
+		AccessEndsAt: payment.AccessEnds(),
+		Tags:         append([]string(nil), payment.Tags...),
+	}
+}
+
+// withPaymentInfo returns r with info attached to its context
+func withPaymentInfo(r *http.Request, info *PaymentInfo) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), paymentInfoKey{}, info))
+}
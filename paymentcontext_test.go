@@ -0,0 +1,80 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestFromContext(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	now := time.Now()
+	pw.Store.CreatePayment(&Payment{
+		ID:           "paid",
+		Addresses:    map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:      map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Hour),
+		Status:       StatusConfirmed,
+		PaidCurrency: wallet.Bitcoin,
+		ConfirmedAt:  now,
+		Tags:         []string{"vip"},
+	})
+	rawKey, _, err := pw.CreateAPIKey(APIKeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		info *PaymentInfo
+		ok   bool
+	)
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok = FromContext(r.Context())
+	}), WithPricedMethods(http.MethodGet))
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		apiKey     string
+		wantMethod AccessMethod
+	}{
+		{name: "cookie", method: http.MethodGet, cookie: "paid", wantMethod: AccessCookie},
+		{name: "api key", method: http.MethodGet, apiKey: rawKey, wantMethod: AccessAPIKey},
+		{name: "free method", method: http.MethodPost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, ok = nil, false
+			req := httptest.NewRequest(tt.method, "/article", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: tt.cookie})
+			}
+			if tt.apiKey != "" {
+				req.Header.Set(DefaultAPIKeyHeader, tt.apiKey)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.wantMethod == "" {
+				if ok {
+					t.Errorf("FromContext() = %+v for a free request", info)
+				}
+				return
+			}
+			if !ok || info.Method != tt.wantMethod {
+				t.Fatalf("FromContext() = %+v, %v, want method %s", info, ok, tt.wantMethod)
+			}
+			if tt.wantMethod == AccessCookie && (info.PaymentID != "paid" || info.Currency != wallet.Bitcoin ||
+				info.Amount != 0.001 || len(info.Tags) != 1 || !info.AccessEndsAt.Equal(now.Add(time.Hour))) {
+				t.Errorf("FromContext() = %+v", info)
+			}
+			if tt.wantMethod == AccessAPIKey && info.APIKeyID == "" {
+				t.Errorf("FromContext() = %+v, want the API key ID", info)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPaymentLocked is returned when a payment's lock is not acquired in time,
+// usually because another process sharing the store is mutating the payment
+var ErrPaymentLocked = errors.New("payment is locked by another operation")
+
+const (
+	// paymentLockTimeout is how long admin actions and payer submissions wait
+	// for a payment's lock
+	paymentLockTimeout = 10 * time.Second
+	// monitorLockWait is how long the monitor waits before skipping a payment
+	// another process is already working on
+	monitorLockWait = time.Second
+)
+
+// PaymentLocker serializes mutations of a payment across processes that share a
+// store, such as several paywall instances behind a load balancer. Stores may
+// implement it (FileStore and MemoryStore do), or an external lock service can
+// be configured with Config.PaymentLocker.
+//
+// The monitor, escrow operations and payer-submitted transactions and proofs
+// hold the lock while they read, change and write a payment.
+type PaymentLocker interface {
+	// LockPayment blocks until it holds the lock for paymentID or ctx is done.
+	//
+	// Returns:
+	//   - unlock: Releases the lock; must be called exactly once
+	//   - error: ErrPaymentLocked (possibly wrapped) when ctx ends first
+	LockPayment(ctx context.Context, paymentID string) (unlock func(), err error)
+}
+
+// lockPayment acquires the lock of paymentID, waiting at most wait. Without a
+// configured PaymentLocker it returns immediately and only the store's
+// optimistic version check protects the payment.
+func (p *Paywall) lockPayment(paymentID string, wait time.Duration) (func(), error) {
+	if p.locker == nil {
+		return func() {}, nil
+	}
+	parent := p.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, wait)
+	defer cancel()
+	unlock, err := p.locker.LockPayment(ctx, paymentID)
+	if err != nil {
+		if errors.Is(err, ErrPaymentLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("lock payment %s: %w", paymentID, err)
+	}
+	return unlock, nil
+}
+
+// resolvePaymentLocker picks Config.PaymentLocker, or else the store when it
+// implements PaymentLocker
+func resolvePaymentLocker(config Config) PaymentLocker {
+	if config.PaymentLocker != nil {
+		return config.PaymentLocker
+	}
+	if locker, ok := config.Store.(PaymentLocker); ok {
+		return locker
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestPaymentLocker(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		first PaymentLocker
+		other PaymentLocker
+	}{
+		{"memory store", NewMemoryStore(), nil},
+		// Two stores on one directory stand in for two processes
+		{"file store", NewFileStore(dir), NewFileStore(dir)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.other == nil {
+				tt.other = tt.first
+			}
+			unlock, err := tt.first.LockPayment(context.Background(), "pay-1")
+			if err != nil {
+				t.Fatalf("LockPayment() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := tt.other.LockPayment(ctx, "pay-1"); !errors.Is(err, ErrPaymentLocked) {
+				t.Fatalf("LockPayment() on held lock error = %v, want ErrPaymentLocked", err)
+			}
+			otherUnlock, err := tt.other.LockPayment(context.Background(), "pay-2")
+			if err != nil {
+				t.Fatalf("LockPayment() on another payment error = %v", err)
+			}
+			otherUnlock()
+
+			acquired := make(chan func())
+			go func() {
+				unlock, err := tt.other.LockPayment(context.Background(), "pay-1")
+				if err != nil {
+					t.Errorf("LockPayment() after unlock error = %v", err)
+				}
+				acquired <- unlock
+			}()
+			time.Sleep(20 * time.Millisecond)
+			unlock()
+			unlock() // releasing twice is harmless
+			select {
+			case next := <-acquired:
+				next()
+			case <-time.After(2 * time.Second):
+				t.Fatal("waiting LockPayment() did not acquire the released lock")
+			}
+		})
+	}
+}
+
+func TestFileStoreLockPayment_Stale(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	path := filepath.Join(dir, "crashed.lock")
+	if err := os.WriteFile(path, []byte("left over"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-fileLockStaleAfter - time.Minute)
+	os.Chtimes(path, old, old)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := store.LockPayment(ctx, "crashed")
+	if err != nil {
+		t.Fatalf("LockPayment() over stale lock error = %v", err)
+	}
+
+	// A lock that replaced ours is not removed by our unlock
+	os.WriteFile(path, []byte("someone else"), 0o600)
+	unlock()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("unlock removed a lock it did not own: %v", err)
+	}
+
+	if _, err := store.LockPayment(ctx, "../escape"); err == nil {
+		t.Error("LockPayment() accepted a payment ID with a path separator")
+	}
+}
+
+func TestResolvePaymentLocker(t *testing.T) {
+	external := NewMemoryStore()
+	store := NewMemoryStore()
+	if got := resolvePaymentLocker(Config{Store: store, PaymentLocker: external}); got != external {
+		t.Error("Config.PaymentLocker should take precedence over the store")
+	}
+	if got := resolvePaymentLocker(Config{Store: store}); got != store {
+		t.Error("a store implementing PaymentLocker should be used")
+	}
+	if got := resolvePaymentLocker(Config{Store: &mockStore{}}); got != nil {
+		t.Errorf("resolvePaymentLocker() = %v, want nil for a store without locking", got)
+	}
+}
+
+func TestCheckPaymentLocked_SkipsLockedPayment(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:            store,
+		locker:           store,
+		minConfirmations: 1,
+		logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: &mockCryptoClient{balance: 0.001},
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+	payment := &Payment{
+		ID:        "busy",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	store.CreatePayment(payment)
+
+	unlock, _ := store.LockPayment(context.Background(), payment.ID)
+	monitor.checkPaymentLocked(payment)
+	if got, _ := store.GetPayment(payment.ID); got.Status != StatusPending || got.CheckCount != 0 {
+		t.Errorf("locked payment was checked: status %s, check count %d", got.Status, got.CheckCount)
+	}
+	unlock()
+
+	monitor.checkPaymentLocked(payment)
+	if got, _ := store.GetPayment(payment.ID); got.Status != StatusConfirmed {
+		t.Errorf("status after unlock = %s, want confirmed", got.Status)
+	}
+}
@@ -6,11 +6,19 @@ import (
 	"crypto/rand"
 	"embed"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -36,21 +44,156 @@ type Config struct {
 	PriceInXMR float64
 	// PaymentTimeout is the duration after which pending payments expire
 	PaymentTimeout time.Duration
+	// AccessDuration is how long a confirmed payment grants access, counted from
+	// confirmation (Payment.ConfirmedAt). Optional: when 0, access ends when the
+	// payment window (PaymentTimeout from creation) ends.
+	AccessDuration time.Duration
 	// MinConfirmations is the required number of blockchain confirmations
 	MinConfirmations int
 	// TestNet determines whether to use Bitcoin testnet (true) or mainnet (false)
 	TestNet bool
 	// Store implements the payment persistence interface
 	Store PaymentStore
+	// PaymentLocker serializes payment updates across processes sharing Store,
+	// e.g. a lock service such as Redis or etcd.
+	// Optional: defaults to Store when it implements PaymentLocker (FileStore,
+	// MemoryStore and RedisStore do); otherwise concurrent updates are only
+	// caught by the store's version check.
+	PaymentLocker PaymentLocker
 	// Logger provides structured logging for paywall lifecycle events
 	// Optional: defaults to NewDefaultLogger() when nil
 	Logger *StructuredLogger
+	// AnonymousLogs replaces payment IDs, payment addresses and transaction
+	// IDs in log lines and escrow audit entries with keyed hashes ("ref_..."),
+	// for data-minimization requirements; LogReference finds the reference
+	// of a payment. References are stable across restarts and instances only
+	// with a shared SigningKey. Webhooks and events still carry the IDs.
+	// Optional: defaults to false.
+	AnonymousLogs bool
 	// XMRUser is the monero-rpc username
 	XMRUser string
 	// XMRPassword is the monero-rpc password
 	XMRPassword string
 	// XMRRPC is the monero-rpc URL
 	XMRRPC string
+	// XMRAccountIndex is the monero-wallet-rpc account payment subaddresses are
+	// created in. Optional: defaults to the primary account (0).
+	XMRAccountIndex uint32
+	// XMRLWS selects a Monero light-wallet server (monero-lws) backend instead of
+	// monero-wallet-rpc. Optional: when set, XMRUser/XMRPassword/XMRRPC are not needed.
+	// The server only receives the primary address and private view key and scales
+	// better than wallet-rpc when many payment subaddresses are being watched.
+	XMRLWS *wallet.MoneroLWSConfig
+
+	// Lightning (optional - invoices for small amounts)
+
+	// Lightning connects an LND or Core Lightning node over its REST API. Each
+	// payment then also gets a BOLT11 invoice, which confirms the payment the
+	// moment it is settled, without waiting for blocks.
+	// Optional: nil disables Lightning.
+	Lightning *wallet.LightningConfig
+	// PriceInLightning is the amount in BTC required for access when paying
+	// with Lightning. Lightning has no dust limit, so it can be far below
+	// PriceInBTC; leave PriceInBTC at 0 for a Lightning-only paywall.
+	// Optional: defaults to PriceInBTC.
+	PriceInLightning float64
+
+	// Ethereum (optional - Ether and ERC-20 stablecoins)
+
+	// Ethereum is the JSON-RPC endpoint (a node or provider) balances and
+	// confirmations of Ether and ERC-20 payments are read from. Addresses are
+	// derived from EthereumSeed; the node holds no keys.
+	// Optional: nil disables Ethereum.
+	Ethereum *wallet.ETHRPCConfig
+	// EthereumSeed (16-64 bytes) derives the Ethereum payment addresses
+	// (BIP44 m/44'/60'/account'/0/i). Required with Ethereum: unlike the
+	// Bitcoin wallet, whose seed is generated per process, it must be kept to
+	// spend the received funds.
+	EthereumSeed []byte
+	// PriceInETH is the amount in Ether required for access.
+	// Optional: 0 accepts only the ERC20 tokens.
+	PriceInETH float64
+	// ERC20 are the tokens accepted next to Ether, e.g.
+	// {Token: wallet.USDCMainnet, Price: 5}. Each token is paid to addresses
+	// of its own BIP44 account (1, 2, ... in list order), so keep the order
+	// when adding tokens.
+	ERC20 []ERC20Price
+
+	// Other Bitcoin-derived chains (optional - Litecoin, Dogecoin, Bitcoin Cash)
+
+	// UTXOChains are accepted next to Bitcoin, each with its own node and
+	// price, e.g. {Type: wallet.Litecoin, Price: 0.05, RPC: ...}. Config.TestNet
+	// selects their test networks too. Routes do not offer them.
+	UTXOChains []UTXOChain
+	// UTXOSeed (16-64 bytes) derives the addresses of UTXOChains (BIP44
+	// m/44'/coin'/0'/0/i). Required with UTXOChains; keep it to spend the
+	// received funds.
+	UTXOSeed []byte
+
+	// Bitcoin wallet rotation (optional - spreads receipts over several wallets)
+
+	// BTCWallets derive the Bitcoin addresses of payments instead of the
+	// wallet NewPaywall generates, each payment from one wallet selected by
+	// BTCWalletRotation, so received funds do not accumulate in a single
+	// on-chain cluster. Payment.WalletIDs records the wallet of each payment.
+	// Optional: not supported with MultisigEnabled.
+	BTCWallets []NamedWallet
+	// BTCWalletRotation is RotationRoundRobin (the default) or RotationFill.
+	// Wallet selection restarts with the first wallet when the process restarts.
+	BTCWalletRotation WalletRotation
+	// BTCWalletFillAddresses is how many consecutive payments RotationFill
+	// gives to a wallet. Defaults to 100.
+	BTCWalletFillAddresses int
+
+	// Bitcoin address type (optional - defaults to legacy addresses)
+
+	// AddressType selects the script type of the Bitcoin addresses payments
+	// get, and with it their derivation path: wallet.AddressP2PKH (1...,
+	// m/44'/0'/0', the default), wallet.AddressP2SHP2WPKH (3..., 49'),
+	// wallet.AddressP2WPKH (bc1q..., 84') or wallet.AddressP2TR (bc1p...,
+	// 86'). SegWit and Taproot addresses cost the buyer less to pay. The type
+	// is not stored with BTCWalletStorage: changing it continues the new path
+	// at the saved index, and a wallet restored from BTCMnemonic elsewhere
+	// needs the same type to find its funds. Optional: not supported with
+	// BTCWallets, BTCWatchKey or MultisigEnabled.
+	AddressType wallet.BTCAddressType
+
+	// Bitcoin wallet persistence (optional - keeps payment addresses across restarts)
+
+	// BTCWalletStorage keeps the Bitcoin wallet NewPaywall derives payment
+	// addresses from, so that payments made before a restart stay watched and
+	// their addresses are not handed out again. The wallet is loaded from
+	// DataDir, or generated and saved there on first start, and saved again
+	// with its next address index whenever a payment gets an address. Keep
+	// EncryptionKey with your secrets: the wallet cannot be loaded without it.
+	// Required for a mainnet Bitcoin wallet unless BTCMnemonic, BTCWatchKey or
+	// BTCWallets supply the keys; on TestNet nil generates a new wallet on
+	// every start. Not supported with BTCWallets.
+	BTCWalletStorage *wallet.StorageConfig
+	// BTCRecoveryGapLimit scans the addresses of the stored wallet on start
+	// until this many in a row have received nothing, and continues after the
+	// last used one, e.g. after restoring DataDir from an older backup. The
+	// addresses are checked with BTCBackends, BTCExplorer or the node.
+	// Optional: 0 trusts the saved index; wallet.DefaultGapLimit (20) is the
+	// BIP44 choice. Requires BTCWalletStorage.
+	BTCRecoveryGapLimit int
+	// BTCMnemonic restores the Bitcoin wallet from a BIP39 mnemonic instead
+	// of generating one, e.g. to move the paywall to another host without its
+	// DataDir. With BTCWalletStorage, a stored wallet must match it.
+	// Optional: back up the generated wallet with ExportMnemonic on
+	// pw.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet). Not supported with
+	// BTCWallets.
+	BTCMnemonic string
+	// BTCMnemonicPassphrase is the BIP39 passphrase ("25th word") of
+	// BTCMnemonic and of the generated mnemonic. Optional: changing it derives
+	// a different wallet, so back it up separately from the mnemonic.
+	BTCMnemonicPassphrase string
+	// BTCMnemonicLegacySeed derives BTCMnemonic from the first 32 bytes of
+	// its BIP39 seed, as wallet.NewBTCHDWalletFromMnemonic did before it used
+	// the full seed, to run the paywall on a wallet created that way; see
+	// wallet.NewLegacyBTCHDWalletFromMnemonic. Optional: defaults to false,
+	// the derivation BIP44 wallets use. Requires BTCMnemonic.
+	BTCMnemonicLegacySeed bool
 
 	// Bitcoin RPC configuration (optional - for transaction broadcasting)
 
@@ -64,6 +207,36 @@ type Config struct {
 	// BTCDisableTLS disables TLS verification for Bitcoin RPC (testnet only, insecure)
 	BTCDisableTLS bool
 
+	// Bitcoin block explorer (optional - verification without a wallet node)
+
+	// BTCExplorer verifies Bitcoin payments with the Esplora API of block
+	// explorers (mempool.space, blockstream.info or a self-hosted esplora)
+	// instead of getreceivedbyaddress on a wallet-enabled node, which pruned
+	// and public nodes do not answer. The URLs are tried in order.
+	// Optional: nil verifies with the node. &wallet.EsploraConfig{} uses
+	// mempool.space and blockstream.info.
+	BTCExplorer *wallet.EsploraConfig
+	// BTCBackends verifies Bitcoin payments with the nodes and explorers
+	// registered with it, asked in priority order and skipped while failing,
+	// e.g. your own node with a public explorer as fallback. Backends can be
+	// registered and removed while the paywall runs; their health is checked
+	// every minute. Optional: replaces BTCExplorer, which it cannot be
+	// combined with.
+	BTCBackends *wallet.BackendRegistry
+
+	// Watch-only Bitcoin wallet (optional - no private keys on the server)
+
+	// BTCWatchKey derives the Bitcoin addresses of payments from the extended
+	// public key of an account in your own wallet (xpub for legacy, ypub for
+	// nested SegWit, zpub for native SegWit addresses; tpub, upub, vpub on
+	// testnet) instead of a wallet generated on the server. Payments are
+	// verified with BTCBackends, BTCExplorer or, when neither is set,
+	// mempool.space and blockstream.info, and spent from your wallet. The
+	// address index continues after the addresses of stored payments on
+	// restart. Optional: not supported with BTCWallets, MultisigEnabled or the
+	// wallet persistence settings.
+	BTCWatchKey string
+
 	// Multisig configuration (optional - defaults to single-signature mode)
 
 	// MultisigEnabled enables multisig address generation for payments.
@@ -181,6 +354,405 @@ type Config struct {
 	// Optional: if nil, webhook notifications are disabled.
 	// When provided, enables external system integration (inventory management, notifications).
 	WebhookConfig *WebhookConfig
+
+	// EventSink publishes the same events as JSON PaymentEvent messages to a
+	// message broker such as NATS (see NewNATSPublisher) or Kafka, for
+	// event-driven fulfillment pipelines.
+	// Optional: if nil, events are not published.
+	EventSink *EventSinkConfig
+
+	// Notifiers are told about payment events, by default when a payment
+	// confirms or expires, e.g. a WebhookNotifier posting signed events to
+	// your billing system. Each is retried with exponential backoff.
+	// Optional: if empty, no notifiers are called.
+	Notifiers []NotifierConfig
+
+	// Reverse proxy configuration (optional - for deployments behind load balancers/CDNs)
+
+	// TrustedProxies lists IP addresses or CIDR ranges (e.g. "10.0.0.0/8") of reverse
+	// proxies whose Forwarded and X-Forwarded-* headers are honored when deciding
+	// whether the client connection used HTTPS and when determining the client IP.
+	// Optional: when empty, TLS on the direct connection or "X-Forwarded-Proto: https"
+	// from any peer enables Secure/__Host- cookies (legacy behavior).
+	TrustedProxies []string
+
+	// Request limits (optional - for the paywall's own POST endpoints)
+
+	// MaxRequestBodyBytes bounds request bodies of HandleBitcoinTransaction,
+	// HandleMoneroProof, HandleTestnetFaucet, HandleMode and the multisig handlers;
+	// larger bodies are answered with 413 Request Entity Too Large.
+	// Optional: defaults to DefaultMaxRequestBodyBytes (64 KiB).
+	MaxRequestBodyBytes int64
+	// RequestReadTimeout bounds how long a client may take to send a request body
+	// to those endpoints, so slow clients cannot hold connections open
+	// (slowloris); late bodies are answered with 408 Request Timeout.
+	// Optional: defaults to DefaultRequestReadTimeout (10 seconds). Configure
+	// http.Server.ReadHeaderTimeout for the headers.
+	RequestReadTimeout time.Duration
+
+	// Rate limiting (optional - on by default for the paywall's own endpoints)
+
+	// RateLimits overrides DefaultRateLimits per RateLimitClass. Each client IP
+	// (see TrustedProxies) has a token bucket per class; requests beyond it are
+	// answered with 429 Too Many Requests and a Retry-After header.
+	// Optional: missing classes use DefaultRateLimits; a zero RateLimit turns
+	// limiting off for its class.
+	RateLimits map[RateLimitClass]RateLimit
+	// RateLimitStore keeps the token buckets.
+	// Optional: defaults to a MemoryRateLimitStore per instance.
+	RateLimitStore RateLimitStore
+	// DisableRateLimits turns the built-in rate limiting off, e.g. when a
+	// reverse proxy already limits requests.
+	DisableRateLimits bool
+
+	// Store latency budget (optional - for slow or network-backed stores)
+
+	// StoreTimeout bounds the store calls Middleware makes on a visitor's request
+	// (looking up and creating payments), so a hung store such as a FileStore on
+	// a stalled NFS mount does not hang every protected request with it.
+	// Optional: 0 waits for the store indefinitely, as before.
+	StoreTimeout time.Duration
+	// StoreTimeoutPolicy is how Middleware answers when StoreTimeout is exceeded:
+	// StoreFailClosed (503 with Retry-After) or StoreFailOpen (serve the content
+	// without payment).
+	// Optional: defaults to StoreFailClosed.
+	StoreTimeoutPolicy StoreTimeoutPolicy
+
+	// Access token configuration (optional - for signed, cookie-less access)
+
+	// SigningKey is the HMAC-SHA256 key used to sign access tokens such as pw_token.
+	// Must be at least 32 bytes when set. Optional: when empty a random key is generated
+	// at startup, so issued tokens stop validating after a restart and are not shared
+	// between instances.
+	SigningKey []byte
+
+	// QueryTokenEnabled allows access via a signed ?pw_token= query parameter in addition
+	// to the payment cookie, for RSS readers, podcast clients and other cookie-less
+	// consumers. Tokens are bound to a single URL path. Defaults to false.
+	QueryTokenEnabled bool
+
+	// QueryTokenTTL is how long issued query tokens remain valid.
+	// Defaults to 1 hour. Tokens never outlive the payment they were issued for.
+	QueryTokenTTL time.Duration
+
+	// AccessGrantTTL enables access grants: visitors whose payment cookie
+	// shows a confirmed payment also get a signed access grant cookie, and
+	// requests carrying a valid grant are let through without reading the
+	// store. A grant lasts until the payment's access ends (see
+	// AccessDuration, e.g. 30 days for "pay once, 30 days of access"), but at
+	// most AccessGrantTTL; the next visit then re-reads the payment and mints
+	// a fresh grant. Refunds and manual changes take effect once the current
+	// grant ends, so keep the TTL short enough for your refund policy.
+	// Optional: 0 disables access grants.
+	AccessGrantTTL time.Duration
+
+	// BearerTokensEnabled lets scripts and services pay for REST APIs without
+	// cookies: once a payment confirms, HandlePaymentStatus returns an access
+	// grant as AccessToken, and the middleware accepts it in an
+	// "Authorization: Bearer <token>" header. Requests carrying an access
+	// grant are decided by it alone (401 when tampered with or expired);
+	// bearer tokens that are not access grants, e.g. of the application's own
+	// authentication, are ignored. Requires AccessGrantTTL. Defaults to false.
+	BearerTokensEnabled bool
+
+	// APIKeysEnabled lets machine-to-machine clients authenticate with long-lived API
+	// keys (see IssueAPIKey, CreateAPIKey) sent in APIKeyHeader instead of cookies.
+	// The Store must implement APIKeyStore (MemoryStore, FileStore,
+	// EncryptedFileStore and SQLStore do). Defaults to false.
+	APIKeysEnabled bool
+
+	// APIKeyHeader is the request header carrying API keys. Defaults to "X-API-Key".
+	APIKeyHeader string
+
+	// CreditsPerPayment is the number of credits a confirmed payment adds to a
+	// metered API key (see APIKeyOptions.Metered, TopUpAPIKey). Metered keys pay
+	// per request, 1 credit by default or as set by WithRequestCost.
+	// Optional: 0 disables top-ups from payments.
+	CreditsPerPayment int64
+
+	// PaymentCredits turns confirmed payments into prepaid bundles, e.g. 1000
+	// API calls: every request let through for a payment (payment cookie,
+	// pw_token, access grant or bearer token) costs 1 credit, or as set by
+	// WithRequestCost, and once the balance cannot cover a request the visitor
+	// is asked for a new payment. Responses carry the remaining balance in
+	// CreditsHeader. Optional: 0 lets payments make any number of requests
+	// while their access lasts.
+	PaymentCredits int64
+
+	// CreditStore keeps the balances of PaymentCredits. Implement it on a
+	// shared cache (e.g. Redis DECRBY) to keep access grants off the store.
+	// Optional: defaults to counting used credits on the payments in Store.
+	CreditStore CreditStore
+
+	// Fiat conversion (optional - for reporting and display)
+
+	// PriceOracle converts crypto amounts to fiat for revenue reports.
+	// Optional: if nil, reports contain crypto amounts only.
+	PriceOracle PriceOracle
+
+	// FiatCurrency is the ISO 4217 code used with PriceOracle. Defaults to "USD".
+	FiatCurrency string
+
+	// FiatEstimates shows the value of the amounts to pay in FiatCurrency next
+	// to the crypto amounts on the payment page and in HandlePaymentStatus
+	// responses, which keep them current while the page is open. They are
+	// labeled as estimates: visitors always pay the crypto amount.
+	// Requires PriceOracle.
+	FiatEstimates bool
+
+	// PriceInFiat is the content price in FiatCurrency. Requires PriceOracle.
+	// Optional: when set, the BTC and XMR prices are derived from it using the
+	// oracle rate, fetched at startup and every PriceRefreshInterval rather than
+	// when payments are created. PriceInBTC and PriceInXMR still select the
+	// enabled currencies and are used until the oracle answers.
+	PriceInFiat float64
+	// PriceRefreshInterval is how often PriceInFiat is converted again; with
+	// ExternalMonitor, which runs no background refresh, when the first
+	// payment after the interval is created. Defaults to 5 minutes.
+	PriceRefreshInterval time.Duration
+	// PriceChangeThreshold is how far, in percent, a converted price must move
+	// before it replaces the current one, so prices do not jitter with every
+	// rate update. Defaults to 1 (%). Each change fires EventPriceChanged.
+	PriceChangeThreshold float64
+
+	// Payment page configuration (optional)
+
+	// QRCodePath is where HandleQRCode is mounted (e.g. "/paywall/qr").
+	// Optional: when set, the payment page's no-JavaScript QR fallback links to this
+	// endpoint instead of inlining data: URIs, which suits a strict
+	// Content-Security-Policy (img-src 'self') and lets browsers cache the images.
+	QRCodePath string
+	// AssetPath is where HandleAsset is mounted, a prefix such as "/paywall/assets/".
+	// Optional: when set, the payment page loads the QR code library from a
+	// fingerprinted URL that browsers cache for a year, instead of inlining it
+	// in every page; a new version gets a new URL and applies immediately.
+	AssetPath string
+	// BTCTxSubmitPath is where HandleBitcoinTransaction is mounted (e.g. "/paywall/btc-tx").
+	// Optional: when set, the payment page offers a form to paste a signed raw
+	// transaction or txid. Requires BTCRPCHost for broadcasting and lookups.
+	BTCTxSubmitPath string
+	// WalletApps are the mobile wallets the payment page suggests, as deep links
+	// that open the app with the address and amount filled in, to visitors whose
+	// User-Agent shows an iPhone, iPad or Android device.
+	// Optional: defaults to DefaultWalletApps; set an empty slice to suggest none.
+	// A standard bitcoin:/monero: link is shown to every visitor regardless.
+	WalletApps []WalletApp
+	// PaymentLabel names the recipient in payment URIs and QR codes, e.g. your
+	// site's name. Wallets show it as the BIP21 label of Bitcoin, Litecoin,
+	// Dogecoin and Bitcoin Cash payments and the recipient_name of Monero
+	// payments, and many keep it with the transaction. Optional: keep it
+	// short, every character enlarges the QR codes.
+	PaymentLabel string
+	// TestnetFaucetURL is a faucet API that sends test coins, for demos (e.g.
+	// "https://faucet.example/api/send"). The paywall POSTs a JSON FaucetRequest
+	// ({"currency", "address", "amount"}); {address}, {amount} and {currency} in
+	// the URL are replaced for faucets that take parameters in the query string.
+	// Optional: requires TestNet.
+	TestnetFaucetURL string
+	// TestnetFaucetPath is where HandleTestnetFaucet is mounted (e.g. "/paywall/faucet").
+	// Optional: when set together with TestnetFaucetURL, the payment page offers a
+	// "Request test coins" button for each address.
+	TestnetFaucetPath string
+	// StatusPath is where HandlePaymentStatus is mounted (e.g. "/paywall/status").
+	// Optional: when set, the payment page listens to it and returns the visitor to
+	// the content they requested as soon as the payment confirms, without a manual reload.
+	StatusPath string
+	// StatusPollInterval is how often the payment page polls StatusPath when
+	// Server-Sent Events are unavailable, and how often event streams re-read
+	// payments changed by another instance.
+	// Optional: defaults to 10 seconds.
+	StatusPollInterval time.Duration
+	// JSONResponses answers every unpaid request with 402 Payment Required and
+	// a JSON PaymentRequiredResponse instead of the HTML payment page, for
+	// single-page and mobile apps rendering their own payment UI. Without it
+	// only requests whose Accept header prefers application/json get JSON.
+	// Optional: defaults to false.
+	JSONResponses bool
+	// PageDataHook is called with the template data just before the payment page
+	// renders, so integrators can add request-specific values such as a return URL,
+	// the article title or the visitor's locale (usually via data.Extra).
+	// r is nil when the page is not rendered for a request.
+	// Optional: if nil, the page data is used as built.
+	PageDataHook func(r *http.Request, payment *Payment, data *PaymentPageData)
+
+	// Repeat visitor configuration (optional - for cookie loss during checkout)
+
+	// ReusePendingPayments re-attaches a visitor without a payment cookie to their
+	// recent pending payment instead of creating a new one, matched by a request
+	// fingerprint. Prevents orphaned payments (and payments to stale addresses) when
+	// cookies are cleared mid-checkout. Defaults to false.
+	// Privacy: fingerprints are keyed HMACs kept in memory only; they are never
+	// persisted or logged, and only pending (never confirmed) payments are reused.
+	ReusePendingPayments bool
+
+	// ReusePendingWindow is how long after creation a pending payment can be claimed
+	// by a matching fingerprint. Defaults to 30 minutes.
+	ReusePendingWindow time.Duration
+
+	// PaymentFingerprint derives the stable request fingerprint used by
+	// ReusePendingPayments and the metered paywall. Optional: defaults to client
+	// IP, User-Agent and Accept-Language. Returning "" disables reuse and free
+	// requests for that request.
+	PaymentFingerprint func(r *http.Request) string
+
+	// Metered paywall configuration (optional - for soft paywalls)
+
+	// FreeRequests lets visitors without a payment make this many requests per
+	// MeterWindow before they are asked to pay, like a news site's article meter.
+	// Visitors are told apart by PaymentFingerprint. Optional: 0 disables the
+	// request allowance.
+	FreeRequests int
+	// FreeTime lets visitors without a payment browse for this long from their
+	// first request in a MeterWindow before they are asked to pay. With
+	// FreeRequests also set, payment is required once either allowance runs out.
+	// Optional: 0 disables the time allowance.
+	FreeTime time.Duration
+	// MeterWindow is how long a visitor's allowance lasts before it starts over.
+	// Optional: defaults to 30 days.
+	MeterWindow time.Duration
+	// MeterStore keeps the visitors' request counters.
+	// Optional: defaults to an in-memory meter, which resets on restart and is not
+	// shared between instances.
+	MeterStore MeterStore
+
+	// Monitor configuration (optional - for many abandoned payments)
+
+	// WatchDecayAfter is how long a pending payment may go without any funds
+	// arriving before the monitor checks it less often: the gap between checks
+	// doubles for every further WatchDecayAfter without funds, up to
+	// WatchDecayMaxInterval. Payments about to expire are always checked.
+	// Optional: 0 checks every pending payment on every cycle.
+	WatchDecayAfter time.Duration
+
+	// WatchDecayMaxInterval caps the gap between checks of an unfunded payment.
+	// Optional: defaults to 5 monitor cycles (50 seconds).
+	WatchDecayMaxInterval time.Duration
+
+	// MonitorShadow runs the payment monitor as a dry run: it checks pending
+	// payments against the chain as usual, but only logs and publishes
+	// (EventShadowDecision) the confirmations and expiries it would make,
+	// without calling payment hooks or writing to the store. Run it next to the
+	// production monitor to validate a new chain backend or configuration.
+	// Payments created by a shadow instance are never confirmed by it.
+	// Optional: defaults to false.
+	MonitorShadow bool
+
+	// ConfirmationStrategies replaces how the monitor decides that a payment is
+	// paid, per configured currency (Bitcoin, Monero, Lightning, Ether, ERC-20
+	// tokens and UTXOChains): BalanceConfirmation (the default),
+	// BitcoinTxConfirmation, AttestationConfirmation or your own, e.g. to
+	// accept unconfirmed payments of small amounts.
+	// Optional: currencies without a strategy use BalanceConfirmation.
+	ConfirmationStrategies map[wallet.WalletType]ConfirmationStrategy
+
+	// MonitorWALPath is a file where the monitor records each confirmation and
+	// expiry before writing it to the Store. Changes that did not reach the
+	// Store, because the process crashed or the Store failed, are replayed when
+	// the paywall starts and on every monitor cycle until they are stored, so a
+	// payment seen confirming is not lost. Use one file per monitor process, on
+	// local disk.
+	// Optional: empty keeps no log; a confirmation whose store update fails is
+	// then only found again by the next check.
+	MonitorWALPath string
+
+	// MonitorStoreRetries is how many times the monitor retries storing a
+	// confirmation or expiry that failed with a transient error (see
+	// ClassifyStoreError), waiting MonitorStoreRetryBackoff before the first
+	// retry and twice as long before each further one. Writes that still fail
+	// are published as EventStoreWriteFailed and counted in StatsSnapshot.
+	// Optional: defaults to 3; negative values disable retries.
+	MonitorStoreRetries int
+
+	// MonitorStoreRetryBackoff is the wait before the first retry of a failed
+	// store write. Optional: defaults to 250ms.
+	MonitorStoreRetryBackoff time.Duration
+
+	// ExpirySweepInterval is how often the monitor process sweeps for unpaid
+	// payments that the payment monitor has not expired 20 seconds after their
+	// ExpiresAt, e.g. while it backs off from a failing chain backend, and marks
+	// them StatusExpired without querying the chain. Stores implementing
+	// ExpiredPaymentFinder serve the sweep from their own index.
+	// Optional: defaults to 1 minute; negative values disable the sweep.
+	ExpirySweepInterval time.Duration
+
+	// ExpiredPaymentRetention is how long payments that expired unpaid are kept
+	// before each sweep prunes them from the Store, which must implement
+	// ExpiredPaymentPruner (MemoryStore, FileStore and EncryptedFileStore do;
+	// the file stores move them to an "archive" subdirectory). It must be at
+	// least 24 hours, the time the monitor watches expired payments for late
+	// payments. Optional: 0 keeps expired payments.
+	ExpiredPaymentRetention time.Duration
+
+	// Refunds (optional - refund addresses and overpayment handling)
+
+	// Refunds enables the RefundManager (see GetRefundManager): confirmed
+	// payments that received more than their price are recorded in
+	// Payment.Overpayment and refunded through a RefundSigner or left for
+	// review, and buyers can leave a refund address with HandleRefundAddress.
+	// Optional: nil ignores overpayments.
+	Refunds *RefundConfig
+
+	// Stateless deployment (optional - for FaaS web tiers)
+
+	// ExternalMonitor runs this instance without the payment monitor, escrow
+	// timeout monitor and arbiter consensus worker, so NewPaywall starts no
+	// background goroutines and instances can be created per invocation and
+	// frozen between requests (AWS Lambda, Cloud Run). Payments are confirmed by
+	// a separate long-running process sharing only the Store, such as
+	// cmd/paywall-monitor or any Paywall created without ExternalMonitor.
+	// Requires SigningKey, so tokens issued by one instance validate on all
+	// others, and a Store the monitor process can reach (not a MemoryStore).
+	// Unless MultisigEnabled, the Bitcoin wallet must also outlive instances,
+	// from BTCWatchKey, BTCMnemonic or an existing wallet in BTCWalletStorage,
+	// and the Store must implement AddressIndexReserver, which then hands out
+	// the address indexes instead of each instance's own counter.
+	// Defaults to false.
+	ExternalMonitor bool
+
+	// Stats history (optional - for dashboards without a metrics stack)
+
+	// StatsInterval records a StatsSnapshot (pending and confirmed payments,
+	// today's revenue, monitor error rate) into the Store at this interval,
+	// served by HandleStatsHistory. The Store must implement StatsStore
+	// (MemoryStore, FileStore, EncryptedFileStore, S3Store and SQLStore do). Instances
+	// with ExternalMonitor record nothing; the monitor process does.
+	// Optional: 0 records no snapshots.
+	StatsInterval time.Duration
+	// StatsRetention is how long snapshots are kept.
+	// Optional: defaults to 90 days.
+	StatsRetention time.Duration
+
+	// Retention (optional - for privacy and disk hygiene)
+
+	// RetentionPolicy purges confirmed and expired payments from the Store
+	// RetentionPolicy.MaxAge after they finished (see ArchivablePayment),
+	// moving them to the store's archive or deleting them. Instances with
+	// ExternalMonitor purge nothing; the monitor process does.
+	// Optional: nil keeps every payment.
+	RetentionPolicy *RetentionPolicy
+
+	// Admin API (optional - for customer support)
+
+	// AdminToken is the bearer token AdminHandler requires, at least 32
+	// characters. Requests send it as "Authorization: Bearer <token>".
+	// Optional: empty disables the admin API unless AdminUsers is set;
+	// AdminHandler then answers 404.
+	AdminToken string
+	// AdminUsers maps user names to passwords accepted by AdminHandler over
+	// HTTP basic auth, so the admin dashboard opens in a browser. Passwords
+	// need at least 12 characters. Serve the dashboard over HTTPS only.
+	// Optional: nil accepts only AdminToken.
+	AdminUsers map[string]string
+
+	// Randomness (optional - for reproducible tests and simulations)
+
+	// Rand is the randomness source for payment IDs, the generated wallet
+	// seed and SigningKey, API keys and event IDs. Keys encrypting data at
+	// rest always come from crypto/rand.
+	// Optional: defaults to crypto/rand. Use NewDeterministicRand for
+	// reproducible test runs; a predictable source on mainnet lets anyone
+	// derive the wallet and forge access tokens.
+	Rand io.Reader
 }
 
 // Paywall manages Bitcoin payment processing and verification
@@ -191,20 +763,44 @@ type Paywall struct {
 	HDWallets map[wallet.WalletType]wallet.HDWallet
 	// Store persists payment information
 	Store PaymentStore
+	// locker serializes payment updates across processes, nil when unavailable
+	locker PaymentLocker
 	// prices is the required payment amount in crypto per wallet
 	prices map[wallet.WalletType]float64
+	// pricesMu guards prices, which priceRefresher updates
+	pricesMu sync.RWMutex
+	// disabledCurrencies are the wallets DisableCurrency took out of new payments
+	disabledCurrencies map[wallet.WalletType]bool
+	currencyMu         sync.RWMutex
 	// paymentTimeout is how long payments can remain pending
 	paymentTimeout time.Duration
+	// accessDuration is how long confirmed payments grant access, 0 for until ExpiresAt
+	accessDuration time.Duration
 	// minConfirmations is required blockchain confirmations
 	minConfirmations int
 	// template is the parsed payment page HTML template
 	template *template.Template
-	// monitor is the blockchain monitoring service
+	// monitor is the blockchain monitoring service, nil with Config.ExternalMonitor
 	monitor *CryptoChainMonitor
+	// monitorWAL is the monitor's log of status changes, nil without
+	// Config.MonitorWALPath
+	monitorWAL *monitorWAL
 	// ctx is the context for monitoring goroutine
 	ctx context.Context
 	// cancel is the context cancellation function
 	cancel context.CancelFunc
+	// workers tracks the background work CloseContext waits for
+	workers workerGroup
+	// walletStorage is where the Bitcoin wallet is saved
+	// (Config.BTCWalletStorage), nil to not save it
+	walletStorage *wallet.StorageConfig
+	// walletSaveMu serializes the saves of the Bitcoin wallet
+	walletSaveMu sync.Mutex
+	// addressIndexes reserves the Bitcoin address indexes with
+	// Config.ExternalMonitor, nil to count them in the wallet
+	addressIndexes AddressIndexReserver
+	// addressIndexKey identifies the Bitcoin wallet in addressIndexes
+	addressIndexKey string
 	// logger emits structured events for payment and escrow operations
 	logger *StructuredLogger
 
@@ -255,6 +851,9 @@ type Paywall struct {
 	// btcBroadcaster handles Bitcoin transaction broadcasting to the network
 	// Initialized if BTCRPCHost is provided in config
 	btcBroadcaster *BTCBroadcaster
+	// btcTxSubmitter broadcasts and looks up payer-submitted transactions,
+	// btcBroadcaster when configured
+	btcTxSubmitter BitcoinTxSubmitter
 	// xmrBroadcaster handles Monero transaction broadcasting to the network
 	// Initialized if XMR RPC config is provided
 	xmrBroadcaster *XMRBroadcaster
@@ -281,6 +880,144 @@ type Paywall struct {
 	// webhookDispatcher handles webhook delivery for payment and escrow events
 	// Initialized when WebhookConfig is provided
 	webhookDispatcher *WebhookDispatcher
+	// eventSink publishes events to a message broker, nil unless Config.EventSink is set
+	eventSink *eventSink
+	// notifiers deliver events to Config.Notifiers
+	notifiers []*eventSink
+
+	// trustedProxies are networks whose forwarding headers are honored
+	trustedProxies []*net.IPNet
+	// limits guard request bodies of the POST endpoints
+	limits requestLimits
+	// rateLimiter limits requests per client IP, nil when disabled
+	rateLimiter *rateLimiter
+	// storeTimeout and storeTimeoutPolicy are Config.StoreTimeout and Config.StoreTimeoutPolicy
+	storeTimeout       time.Duration
+	storeTimeoutPolicy StoreTimeoutPolicy
+	// meter counts requests of visitors without a payment, nil when not metered
+	meter MeterStore
+	// freeRequests and freeTime are the metered allowance per meterWindow
+	freeRequests int
+	freeTime     time.Duration
+	meterWindow  time.Duration
+
+	// Access tokens (optional - for signed, cookie-less access)
+
+	// signer signs and verifies access tokens with Config.SigningKey
+	signer *tokenSigner
+	// redactor hides identifiers in logs and audit entries, nil unless
+	// Config.AnonymousLogs is set
+	redactor *logRedactor
+	// queryTokenEnabled allows access via the pw_token query parameter
+	queryTokenEnabled bool
+	// queryTokenTTL is the maximum lifetime of issued query tokens
+	queryTokenTTL time.Duration
+	// accessGrantTTL is the maximum lifetime of access grants, 0 when disabled
+	accessGrantTTL time.Duration
+	// bearerTokens accepts access grants in the Authorization header
+	bearerTokens bool
+	// apiKeys tracks API key rate limits and usage, nil unless Config.APIKeysEnabled
+	apiKeys *apiKeyState
+	// apiKeyHeader is the request header carrying API keys
+	apiKeyHeader string
+	// creditsPerPayment is the balance a confirmed payment adds to a metered API key
+	creditsPerPayment int64
+	// paymentCredits is the balance of each confirmed payment, 0 when unmetered
+	paymentCredits int64
+	// credits keeps the payments' balances, nil unless Config.PaymentCredits is set
+	credits CreditStore
+
+	// Fiat conversion (optional - for reporting and display)
+
+	// priceOracle converts crypto amounts to fiat, nil when not configured
+	priceOracle PriceOracle
+	// fiatCurrency is the ISO 4217 code passed to priceOracle
+	fiatCurrency string
+	// fiatEstimates shows fiat estimates next to crypto amounts
+	fiatEstimates bool
+	// priceRefresher converts Config.PriceInFiat into prices, nil when not configured
+	priceRefresher *priceRefresher
+
+	// stats counts monitor checks for stats snapshots
+	stats statsCounters
+	// statsRetention is how long stats snapshots are kept
+	statsRetention time.Duration
+	// statsRecorder records snapshots every Config.StatsInterval, nil when not configured
+	statsRecorder *statsRecorder
+	// metrics are served by MetricsHandler
+	metrics *paywallMetrics
+
+	// qrCodePath is the mount point of HandleQRCode, empty to inline QR images
+	qrCodePath string
+	// assetPath is the mount point of HandleAsset ending in "/", empty to inline scripts
+	assetPath string
+	// btcTxSubmitPath is the mount point of HandleBitcoinTransaction, empty to hide the form
+	btcTxSubmitPath string
+	// walletApps are the wallet apps suggested to mobile visitors
+	walletApps []WalletApp
+	// paymentLabel is the recipient named in payment URIs (Config.PaymentLabel)
+	paymentLabel string
+	// faucetURL is the testnet faucet API, empty when disabled
+	faucetURL string
+	// faucetPath is the mount point of HandleTestnetFaucet, empty to hide the buttons
+	faucetPath string
+	// faucetClient calls faucetURL
+	faucetClient *http.Client
+	// faucetRequests limits faucet requests to one per payment address
+	faucetRequests faucetLimiter
+	// statusPath is the mount point of HandlePaymentStatus, empty to disable polling
+	statusPath string
+	// statusPollInterval is the payment page's polling interval
+	statusPollInterval time.Duration
+	// statusWatchers wakes status streams when a payment changes
+	statusWatchers statusWatchers
+	// jsonResponses answers all unpaid requests with JSON, see Config.JSONResponses
+	jsonResponses bool
+	// pageDataHook customizes payment page data per request, nil when not configured
+	pageDataHook func(r *http.Request, payment *Payment, data *PaymentPageData)
+
+	// pendingIndex maps request fingerprints to recent pending payments,
+	// nil unless Config.ReusePendingPayments is set
+	pendingIndex *pendingIndex
+	// paymentFingerprint overrides the default request fingerprint
+	paymentFingerprint func(r *http.Request) string
+
+	// watchDecayAfter is the unfunded age after which checks are spaced out, 0 to disable
+	watchDecayAfter time.Duration
+	// watchDecayMaxInterval caps the gap between checks of unfunded payments
+	watchDecayMaxInterval time.Duration
+	// monitorShadow makes the monitor report its decisions instead of storing them
+	monitorShadow bool
+	// monitorStoreRetries is how often failed monitor writes are retried, 0 for never
+	monitorStoreRetries int
+	// monitorStoreRetryBackoff is the wait before the first retry
+	monitorStoreRetryBackoff time.Duration
+	// expirySweepInterval is Config.ExpirySweepInterval, negative when disabled
+	expirySweepInterval time.Duration
+	// expiredPaymentRetention is Config.ExpiredPaymentRetention, 0 to keep expired payments
+	expiredPaymentRetention time.Duration
+	// confirmationStrategies are Config.ConfirmationStrategies
+	confirmationStrategies map[wallet.WalletType]ConfirmationStrategy
+	// refunds handles overpayments, nil unless Config.Refunds is set
+	refunds *RefundManager
+	// retention is Config.RetentionPolicy with its defaults, nil when not configured
+	retention *RetentionPolicy
+	// retentionPurger applies retention, nil when not configured
+	retentionPurger *retentionPurger
+	// adminToken protects AdminHandler, empty when not configured
+	adminToken string
+	// adminUsers are the basic auth credentials accepted by AdminHandler
+	adminUsers map[string]string
+
+	// mode holds the operating Mode set by SetMode, empty for ModeNormal
+	mode atomic.Value
+
+	// hooks is the payment hook chain added with Use
+	hooks   []PaymentHook
+	hooksMu sync.RWMutex
+
+	// rand is the randomness source for identifiers and keys, nil for crypto/rand
+	rand io.Reader
 }
 
 func validateConfig(config *Config) error {
@@ -296,7 +1033,66 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("PriceInXMR must be positive, got: %.8f XMR (hint: set PriceInXMR: 0.01 or leave at 0 to disable Monero payments)", config.PriceInXMR)
 	}
 
-	if config.PriceInBTC <= 0 && config.PriceInXMR <= 0 {
+	if config.PriceInFiat < 0 {
+		return fmt.Errorf("PriceInFiat must be positive, got: %.2f", config.PriceInFiat)
+	}
+
+	if config.PriceInFiat > 0 && config.PriceOracle == nil {
+		return fmt.Errorf("PriceInFiat set (%.2f) but PriceOracle is nil (hint: set PriceOracle to convert the fiat price into BTC and XMR)", config.PriceInFiat)
+	}
+
+	if config.FiatEstimates && config.PriceOracle == nil {
+		return fmt.Errorf("FiatEstimates set but PriceOracle is nil (hint: set PriceOracle to convert the amounts into FiatCurrency)")
+	}
+
+	if config.PriceChangeThreshold < 0 {
+		return fmt.Errorf("PriceChangeThreshold must not be negative, got: %.2f%%", config.PriceChangeThreshold)
+	}
+
+	if config.PriceInLightning < 0 {
+		return fmt.Errorf("PriceInLightning must be positive, got: %.11f BTC (hint: set PriceInLightning: 0.00001 or leave at 0 to use PriceInBTC)", config.PriceInLightning)
+	}
+
+	if config.PriceInLightning > 0 && config.Lightning == nil {
+		return fmt.Errorf("PriceInLightning set (%.11f BTC) but Lightning is nil (hint: set Lightning to your LND or Core Lightning node)", config.PriceInLightning)
+	}
+
+	if config.Lightning != nil {
+		if err := config.Lightning.Validate(); err != nil {
+			return fmt.Errorf("Lightning: %w", err)
+		}
+		if max(config.PriceInLightning, config.PriceInBTC) < minLightningAmount {
+			return fmt.Errorf("Lightning configured but PriceInLightning and PriceInBTC are below one millisatoshi (hint: PriceInLightning: 0.00001)")
+		}
+	}
+
+	if err := validateRefundConfig(config.Refunds); err != nil {
+		return err
+	}
+	if err := validateRetentionPolicy(config.RetentionPolicy); err != nil {
+		return err
+	}
+	if err := validateAdminAuth(*config); err != nil {
+		return err
+	}
+	if err := validateEthereumConfig(*config); err != nil {
+		return err
+	}
+
+	if err := validateUTXOChains(*config); err != nil {
+		return err
+	}
+
+	if config.BTCExplorer != nil {
+		if config.BTCBackends != nil {
+			return fmt.Errorf("BTCExplorer and BTCBackends are both set (hint: register the explorer with BTCBackends.Register(wallet.NewEsploraClient(...), priority))")
+		}
+		if err := config.BTCExplorer.Validate(); err != nil {
+			return fmt.Errorf("BTCExplorer: %w", err)
+		}
+	}
+
+	if config.PriceInBTC <= 0 && config.PriceInXMR <= 0 && config.Lightning == nil && config.Ethereum == nil && len(config.UTXOChains) == 0 {
 		return fmt.Errorf("configuration error: PriceInBTC and PriceInXMR are both zero - at least one cryptocurrency price must be set (hint: set PriceInBTC: 0.0001 or PriceInXMR: 0.01)")
 	}
 
@@ -310,8 +1106,17 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("PriceInXMR %.8f is below dust limit (minimum: %.4f XMR). Dust payments are rejected by the Monero network. Please increase the price", config.PriceInXMR, minXMRDustLimit)
 	}
 
-	if config.PriceInXMR > 0 && (config.XMRUser == "" || config.XMRPassword == "" || config.XMRRPC == "") {
-		return fmt.Errorf("Monero price set (%.8f XMR) but credentials missing. Required: XMRUser, XMRPassword, and XMRRPC (hint: set XMRUser from XMR_WALLET_USER env, XMRPassword from XMR_WALLET_PASS env, XMRRPC: 'http://localhost:18081')", config.PriceInXMR)
+	if config.PriceInXMR > 0 && config.XMRLWS == nil && (config.XMRUser == "" || config.XMRPassword == "" || config.XMRRPC == "") {
+		return fmt.Errorf("Monero price set (%.8f XMR) but credentials missing. Required: XMRUser, XMRPassword, and XMRRPC (hint: set XMRUser from XMR_WALLET_USER env, XMRPassword from XMR_WALLET_PASS env, XMRRPC: 'http://localhost:18081', or configure XMRLWS)", config.PriceInXMR)
+	}
+
+	if config.XMRLWS != nil {
+		if config.PriceInXMR <= 0 {
+			return fmt.Errorf("Monero light-wallet server configured but PriceInXMR is zero. Set PriceInXMR to enable Monero payments (hint: PriceInXMR: 0.01)")
+		}
+		if config.XMRLWS.URL == "" || config.XMRLWS.Address == "" || config.XMRLWS.ViewKey == "" {
+			return fmt.Errorf("XMRLWS requires URL, Address and ViewKey (hint: URL: 'http://127.0.0.1:8443', Address: your primary address, ViewKey: your private view key in hex)")
+		}
 	}
 
 	if (config.XMRUser != "" || config.XMRPassword != "" || config.XMRRPC != "") && config.PriceInXMR <= 0 {
@@ -344,6 +1149,171 @@ func validateConfig(config *Config) error {
 		config.MinConfirmations = 1
 	}
 
+	if _, err := parseTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("TrustedProxies: %w", err)
+	}
+
+	if len(config.SigningKey) > 0 && len(config.SigningKey) < minSigningKeyLength {
+		return fmt.Errorf("SigningKey must be at least %d bytes, got %d (hint: use wallet.GenerateEncryptionKey())", minSigningKeyLength, len(config.SigningKey))
+	}
+
+	if config.QueryTokenTTL < 0 {
+		return fmt.Errorf("QueryTokenTTL must not be negative, got: %s (hint: leave at 0 for the 1 hour default)", config.QueryTokenTTL)
+	}
+
+	if config.APIKeysEnabled && config.Store != nil {
+		if _, ok := config.Store.(APIKeyStore); !ok {
+			return fmt.Errorf("APIKeysEnabled requires a Store implementing APIKeyStore, got %T (hint: use NewMemoryStore, NewFileStore, NewEncryptedFileStore or NewSQLStore)", config.Store)
+		}
+	}
+
+	if config.AccessDuration < 0 {
+		return fmt.Errorf("AccessDuration must not be negative, got: %v", config.AccessDuration)
+	}
+	if config.AccessGrantTTL < 0 {
+		return fmt.Errorf("AccessGrantTTL must not be negative, got: %v", config.AccessGrantTTL)
+	}
+	if config.BearerTokensEnabled {
+		if config.AccessGrantTTL == 0 {
+			return fmt.Errorf("BearerTokensEnabled requires AccessGrantTTL (hint: bearer tokens are access grants, e.g. set AccessGrantTTL to 24 * time.Hour)")
+		}
+		if strings.EqualFold(config.APIKeyHeader, "Authorization") {
+			return fmt.Errorf("BearerTokensEnabled cannot be combined with APIKeyHeader %q (hint: send API keys in X-API-Key)", config.APIKeyHeader)
+		}
+	}
+	if config.CreditsPerPayment < 0 {
+		return fmt.Errorf("CreditsPerPayment must not be negative, got: %d", config.CreditsPerPayment)
+	}
+	if config.PaymentCredits < 0 {
+		return fmt.Errorf("PaymentCredits must not be negative, got: %d", config.PaymentCredits)
+	}
+
+	if config.TestnetFaucetURL != "" {
+		if !config.TestNet {
+			return fmt.Errorf("TestnetFaucetURL requires TestNet (hint: the faucet only helps demos on test networks)")
+		}
+		if u, err := url.Parse(config.TestnetFaucetURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("TestnetFaucetURL must be an http(s) URL, got: %q", config.TestnetFaucetURL)
+		}
+	}
+
+	if config.FreeRequests < 0 || config.FreeTime < 0 || config.MeterWindow < 0 {
+		return fmt.Errorf("FreeRequests, FreeTime and MeterWindow must not be negative, got: %d, %s and %s", config.FreeRequests, config.FreeTime, config.MeterWindow)
+	}
+
+	for class, limit := range config.RateLimits {
+		if _, ok := DefaultRateLimits[class]; !ok {
+			return fmt.Errorf("RateLimits: unknown class %q (hint: use RateLimitCreate, RateLimitStatus or RateLimitSubmit)", class)
+		}
+		if limit.Requests < 0 || limit.Interval < 0 || (limit.Requests > 0 && limit.Interval == 0) {
+			return fmt.Errorf("RateLimits[%s] must have positive Requests and Interval, got: %d per %s (hint: use RateLimit{} to disable the class)", class, limit.Requests, limit.Interval)
+		}
+	}
+
+	if config.MaxRequestBodyBytes < 0 || config.RequestReadTimeout < 0 {
+		return fmt.Errorf("MaxRequestBodyBytes and RequestReadTimeout must not be negative, got: %d and %s (hint: leave at 0 for the defaults)", config.MaxRequestBodyBytes, config.RequestReadTimeout)
+	}
+
+	if config.StatsInterval < 0 || config.StatsRetention < 0 {
+		return fmt.Errorf("StatsInterval and StatsRetention must not be negative, got: %s and %s", config.StatsInterval, config.StatsRetention)
+	}
+	if config.StatsInterval > 0 {
+		if _, ok := config.Store.(StatsStore); !ok {
+			return fmt.Errorf("StatsInterval requires a Store implementing StatsStore, got %T (hint: use NewMemoryStore, NewFileStore, NewEncryptedFileStore, NewS3Store or NewSQLStore)", config.Store)
+		}
+	}
+
+	if config.StoreTimeout < 0 {
+		return fmt.Errorf("StoreTimeout must not be negative, got: %s (hint: leave at 0 to wait for the store indefinitely)", config.StoreTimeout)
+	}
+	switch config.StoreTimeoutPolicy {
+	case "", StoreFailClosed, StoreFailOpen:
+	default:
+		return fmt.Errorf("invalid StoreTimeoutPolicy: %q (must be %q or %q)", config.StoreTimeoutPolicy, StoreFailClosed, StoreFailOpen)
+	}
+
+	if config.WatchDecayAfter < 0 || config.WatchDecayMaxInterval < 0 {
+		return fmt.Errorf("WatchDecayAfter and WatchDecayMaxInterval must not be negative, got: %s and %s", config.WatchDecayAfter, config.WatchDecayMaxInterval)
+	}
+
+	if config.MonitorStoreRetryBackoff < 0 {
+		return fmt.Errorf("MonitorStoreRetryBackoff must not be negative, got: %s (hint: leave at 0 for the 250ms default)", config.MonitorStoreRetryBackoff)
+	}
+
+	if config.ExpiredPaymentRetention < 0 {
+		return fmt.Errorf("ExpiredPaymentRetention must not be negative, got: %s (hint: leave at 0 to keep expired payments)", config.ExpiredPaymentRetention)
+	}
+	if config.ExpiredPaymentRetention > 0 {
+		if config.ExpiredPaymentRetention < latePaymentWindow {
+			return fmt.Errorf("ExpiredPaymentRetention must be at least %s, got: %s (hint: expired payments are watched that long for late payments)", latePaymentWindow, config.ExpiredPaymentRetention)
+		}
+		if _, ok := config.Store.(ExpiredPaymentPruner); !ok {
+			return fmt.Errorf("ExpiredPaymentRetention requires a Store implementing ExpiredPaymentPruner, got %T (hint: use NewMemoryStore, NewFileStore or NewEncryptedFileStore; RedisStore expires payments itself)", config.Store)
+		}
+	}
+
+	if config.ReusePendingWindow < 0 {
+		return fmt.Errorf("ReusePendingWindow must not be negative, got: %s (hint: leave at 0 for the 30 minute default)", config.ReusePendingWindow)
+	}
+
+	if config.ExternalMonitor {
+		if len(config.SigningKey) == 0 {
+			return fmt.Errorf("ExternalMonitor requires SigningKey so all instances accept the same tokens (hint: share a key from wallet.GenerateEncryptionKey() between instances)")
+		}
+		if _, ok := config.Store.(*MemoryStore); ok {
+			return fmt.Errorf("ExternalMonitor requires a Store shared with the monitor process, got %T (hint: use NewSQLStore, NewRedisStore, NewS3Store or a FileStore on shared storage)", config.Store)
+		}
+		if config.MonitorShadow {
+			return fmt.Errorf("MonitorShadow has no effect with ExternalMonitor, which runs no monitor (hint: run cmd/paywall-monitor -shadow instead)")
+		}
+		if config.MonitorWALPath != "" {
+			return fmt.Errorf("MonitorWALPath has no effect with ExternalMonitor, which runs no monitor (hint: run cmd/paywall-monitor -wal instead)")
+		}
+	}
+	if config.MonitorShadow && config.MonitorWALPath != "" {
+		return fmt.Errorf("MonitorWALPath has no effect with MonitorShadow, which writes no changes")
+	}
+
+	currencies := configuredCurrencies(*config)
+	for walletType, strategy := range config.ConfirmationStrategies {
+		if !currencies[walletType] {
+			return fmt.Errorf("ConfirmationStrategies: currency %q is not configured (hint: strategies apply to Bitcoin and the currencies enabled by the XMR, Lightning, Ethereum, ERC20 and UTXOChains settings)", walletType)
+		}
+		if strategy == nil {
+			return fmt.Errorf("ConfirmationStrategies: nil strategy for %s (hint: omit the currency to use BalanceConfirmation)", walletType)
+		}
+	}
+
+	if err := validateBTCWallets(config); err != nil {
+		return err
+	}
+	if err := validateBTCWalletStorage(*config); err != nil {
+		return err
+	}
+	if err := validateBTCWatchKey(*config); err != nil {
+		return err
+	}
+	if err := validateAddressType(*config); err != nil {
+		return err
+	}
+	if err := validateExternalMonitorWallet(*config); err != nil {
+		return err
+	}
+
+	if config.EventSink != nil && config.EventSink.Publisher == nil {
+		return fmt.Errorf("EventSink requires a Publisher (hint: use paywall.NewNATSPublisher)")
+	}
+	for i, notifier := range config.Notifiers {
+		if notifier.Notifier == nil {
+			return fmt.Errorf("Notifiers[%d] has no Notifier (hint: use &paywall.WebhookNotifier{URL: ..., Secret: ...})", i)
+		}
+		if webhook, ok := notifier.Notifier.(*WebhookNotifier); ok {
+			if err := webhook.Validate(); err != nil {
+				return fmt.Errorf("Notifiers[%d]: %w", i, err)
+			}
+		}
+	}
+
 	if config.Store == nil {
 		return fmt.Errorf("Store is required (hint: use paywall.NewMemoryStore() for testing or paywall.NewFileStore() for production)")
 	}
@@ -352,22 +1322,87 @@ func validateConfig(config *Config) error {
 }
 
 func initializeWallets(config Config) (map[wallet.WalletType]wallet.HDWallet, map[wallet.WalletType]float64, error) {
-	seed := make([]byte, 32)
-	if _, err := rand.Read(seed); err != nil {
-		return nil, nil, fmt.Errorf("generate seed: %w", err)
+	var hdWallet wallet.HDWallet
+	if len(config.BTCWallets) > 0 {
+		hdWallet = newWalletRotation(config)
+	} else if config.BTCWatchKey != "" {
+		watchWallet, err := initializeBTCWatchWallet(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		hdWallet = watchWallet
+	} else {
+		btcWallet, err := initializeBTCWallet(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		if config.AddressType != "" {
+			if err := btcWallet.SetAddressType(config.AddressType); err != nil {
+				return nil, nil, fmt.Errorf("set Bitcoin address type: %w", err)
+			}
+		}
+
+		if config.MultisigEnabled {
+			if pubKeys, ok := config.ParticipantPubKeys[wallet.Bitcoin]; ok {
+				if err := btcWallet.EnableMultisig(pubKeys, config.MultisigRequired); err != nil {
+					return nil, nil, fmt.Errorf("enable multisig on Bitcoin wallet: %w", err)
+				}
+			}
+		}
+		hdWallet = btcWallet
 	}
 
-	hdWallet, err := wallet.NewBTCHDWallet(seed, config.TestNet, config.MinConfirmations)
+	xmrHdWallet, err := initializeMoneroWallet(config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create wallet: %w", err)
+		return nil, nil, err
 	}
 
-	if config.MultisigEnabled {
-		if pubKeys, ok := config.ParticipantPubKeys[wallet.Bitcoin]; ok {
-			if err := hdWallet.EnableMultisig(pubKeys, config.MultisigRequired); err != nil {
-				return nil, nil, fmt.Errorf("enable multisig on Bitcoin wallet: %w", err)
-			}
+	hdWallets := make(map[wallet.WalletType]wallet.HDWallet)
+	hdWallets[wallet.WalletType(hdWallet.Currency())] = hdWallet
+	if xmrHdWallet != nil {
+		hdWallets[wallet.WalletType(xmrHdWallet.Currency())] = xmrHdWallet
+	}
+
+	prices := make(map[wallet.WalletType]float64)
+	prices[wallet.WalletType(hdWallet.Currency())] = config.PriceInBTC
+	if xmrHdWallet != nil {
+		prices[wallet.WalletType(xmrHdWallet.Currency())] = config.PriceInXMR
+	}
+
+	if lnWallet := initializeLightningWallet(config); lnWallet != nil {
+		hdWallets[wallet.Lightning] = lnWallet
+		prices[wallet.Lightning] = config.PriceInLightning
+	}
+
+	for _, initialize := range []func(Config) (map[wallet.WalletType]wallet.HDWallet, map[wallet.WalletType]float64, error){
+		initializeUTXOChainWallets,
+		initializeEthereumWallets,
+	} {
+		extraWallets, extraPrices, err := initialize(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		for walletType, extraWallet := range extraWallets {
+			hdWallets[walletType] = extraWallet
+			prices[walletType] = extraPrices[walletType]
+		}
+	}
+
+	return hdWallets, prices, nil
+}
+
+// initializeMoneroWallet creates the Monero wallet backend: a light-wallet server
+// client when Config.XMRLWS is set, otherwise monero-wallet-rpc.
+// Connection failures are logged and yield a nil wallet (Bitcoin-only operation);
+// only invalid credentials are returned as errors.
+func initializeMoneroWallet(config Config) (wallet.HDWallet, error) {
+	if config.XMRLWS != nil {
+		lwsWallet, err := wallet.NewMoneroLWSWallet(*config.XMRLWS, config.MinConfirmations)
+		if err != nil {
+			logMoneroInitFailure(config, err)
+			return nil, nil
 		}
+		return lwsWallet, nil
 	}
 
 	if config.XMRUser != "" || config.XMRPassword != "" || config.XMRRPC != "" || config.PriceInXMR > 0 {
@@ -377,7 +1412,7 @@ func initializeWallets(config Config) (map[wallet.WalletType]wallet.HDWallet, ma
 		if config.XMRPassword == "" {
 			pass, exists := os.LookupEnv("XMR_WALLET_PASS")
 			if !exists {
-				return nil, nil, fmt.Errorf("XMR wallet password not provided")
+				return nil, fmt.Errorf("XMR wallet password not provided")
 			}
 			config.XMRPassword = pass
 		}
@@ -385,44 +1420,38 @@ func initializeWallets(config Config) (map[wallet.WalletType]wallet.HDWallet, ma
 			config.XMRRPC = "http://127.0.0.1:18081"
 		}
 		if config.XMRUser != "" && len(config.XMRUser) < 3 {
-			return nil, nil, fmt.Errorf("XMR RPC username must be at least 3 characters")
+			return nil, fmt.Errorf("XMR RPC username must be at least 3 characters")
 		}
 		if config.XMRPassword != "" && len(config.XMRPassword) < 8 {
-			return nil, nil, fmt.Errorf("XMR RPC password must be at least 8 characters")
+			return nil, fmt.Errorf("XMR RPC password must be at least 8 characters")
 		}
 	}
 
 	xmrHdWallet, err := wallet.NewMoneroWallet(wallet.MoneroConfig{
-		RPCUser:     config.XMRUser,
-		RPCURL:      config.XMRRPC,
-		RPCPassword: config.XMRPassword,
+		RPCUser:      config.XMRUser,
+		RPCURL:       config.XMRRPC,
+		RPCPassword:  config.XMRPassword,
+		AccountIndex: config.XMRAccountIndex,
 	}, config.MinConfirmations)
 	if err != nil {
-		if config.Logger != nil {
-			config.Logger.log(LogEntry{
-				Level:   LogLevelWarn,
-				Event:   "xmr_wallet_init_failed",
-				Message: fmt.Sprintf("XMR wallet configuration provided but creation failed: %v. Continuing with Bitcoin-only support.", err),
-			})
-		} else {
-			log.Printf("WARNING: XMR wallet configuration was provided but wallet creation failed: %v", err)
-			log.Printf("Continuing with Bitcoin-only support. Please check your Monero RPC configuration.")
-		}
-	}
-
-	hdWallets := make(map[wallet.WalletType]wallet.HDWallet)
-	hdWallets[wallet.WalletType(hdWallet.Currency())] = hdWallet
-	if xmrHdWallet != nil {
-		hdWallets[wallet.WalletType(xmrHdWallet.Currency())] = xmrHdWallet
+		logMoneroInitFailure(config, err)
+		return nil, nil
 	}
+	return xmrHdWallet, nil
+}
 
-	prices := make(map[wallet.WalletType]float64)
-	prices[wallet.WalletType(hdWallet.Currency())] = config.PriceInBTC
-	if xmrHdWallet != nil {
-		prices[wallet.WalletType(xmrHdWallet.Currency())] = config.PriceInXMR
+// logMoneroInitFailure warns that Monero support is disabled because the wallet backend could not be created
+func logMoneroInitFailure(config Config, err error) {
+	if config.Logger != nil {
+		config.Logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "xmr_wallet_init_failed",
+			Message: fmt.Sprintf("XMR wallet configuration provided but creation failed: %v. Continuing with Bitcoin-only support.", err),
+		})
+	} else {
+		log.Printf("WARNING: XMR wallet configuration was provided but wallet creation failed: %v", err)
+		log.Printf("Continuing with Bitcoin-only support. Please check your Monero RPC configuration.")
 	}
-
-	return hdWallets, prices, nil
 }
 
 func setupMultisig(config Config, reputationTracker *ArbiterReputationTracker) (*ArbiterConsensusManager, error) {
@@ -450,11 +1479,30 @@ func startBackgroundWorkers(p *Paywall, hdWallets map[wallet.WalletType]wallet.H
 		client:  make(map[wallet.WalletType]CryptoClient),
 	}
 	monitor.client[wallet.Bitcoin] = hdWallets[wallet.Bitcoin]
+	if client := initializeBitcoinClient(config); client != nil {
+		monitor.client[wallet.Bitcoin] = client
+	}
+	if config.BTCBackends != nil {
+		go config.BTCBackends.RunHealthChecks(p.ctx, backendHealthInterval)
+	}
 	if xmrWallet, ok := hdWallets[wallet.Monero]; ok {
 		monitor.client[wallet.Monero] = xmrWallet
 	}
+	if lnWallet, ok := hdWallets[wallet.Lightning]; ok {
+		monitor.client[wallet.Lightning] = lnWallet
+	}
+	for walletType, hdWallet := range hdWallets {
+		switch hdWallet.(type) {
+		case *wallet.ETHHDWallet, *wallet.UTXOHDWallet:
+			monitor.client[walletType] = hdWallet
+		}
+	}
 	p.monitor = monitor
 	p.monitor.Start(p.ctx)
+	// A shadow monitor only reports; the production monitor sweeps
+	if p.expirySweepInterval > 0 && !p.monitorShadow {
+		p.monitor.startExpirySweeper(p.ctx, p.expirySweepInterval)
+	}
 
 	// Start timeout monitor if escrow is enabled and auto-timeout is configured
 	if p.escrowManager != nil && config.AutoTimeoutRefunds {
@@ -494,6 +1542,74 @@ func applyDefaultConfig(config *Config) {
 	if config.MaxEscrowTimeout <= 0 {
 		config.MaxEscrowTimeout = 90 * 24 * time.Hour
 	}
+	if config.QueryTokenTTL <= 0 {
+		config.QueryTokenTTL = defaultQueryTokenTTL
+	}
+	if config.FiatCurrency == "" {
+		config.FiatCurrency = defaultFiatCurrency
+	}
+	if config.AssetPath != "" && !strings.HasSuffix(config.AssetPath, "/") {
+		config.AssetPath += "/"
+	}
+	if config.Rand == nil {
+		config.Rand = rand.Reader
+	}
+	if config.Lightning != nil && config.PriceInLightning == 0 {
+		config.PriceInLightning = config.PriceInBTC
+	}
+	if config.PriceRefreshInterval <= 0 {
+		config.PriceRefreshInterval = defaultPriceRefreshInterval
+	}
+	if config.PriceChangeThreshold == 0 {
+		config.PriceChangeThreshold = defaultPriceChangeThreshold
+	}
+	if len(config.BTCWallets) > 0 {
+		if config.BTCWalletRotation == "" {
+			config.BTCWalletRotation = RotationRoundRobin
+		}
+		if config.BTCWalletFillAddresses <= 0 {
+			config.BTCWalletFillAddresses = defaultWalletFillAddresses
+		}
+	}
+	if config.ReusePendingWindow <= 0 {
+		config.ReusePendingWindow = defaultReusePendingWindow
+	}
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = DefaultAPIKeyHeader
+	}
+	if config.StatusPollInterval <= 0 {
+		config.StatusPollInterval = defaultStatusPollInterval
+	}
+	if config.WatchDecayMaxInterval <= 0 {
+		config.WatchDecayMaxInterval = defaultWatchDecayMaxInterval
+	}
+	if config.MonitorStoreRetries == 0 {
+		config.MonitorStoreRetries = defaultMonitorStoreRetries
+	}
+	if config.MonitorStoreRetryBackoff == 0 {
+		config.MonitorStoreRetryBackoff = defaultMonitorStoreRetryBackoff
+	}
+	if config.ExpirySweepInterval == 0 {
+		config.ExpirySweepInterval = defaultExpirySweepInterval
+	}
+	if config.WalletApps == nil {
+		config.WalletApps = DefaultWalletApps
+	}
+	if config.MeterWindow <= 0 {
+		config.MeterWindow = defaultMeterWindow
+	}
+	if config.MaxRequestBodyBytes == 0 {
+		config.MaxRequestBodyBytes = DefaultMaxRequestBodyBytes
+	}
+	if config.RequestReadTimeout == 0 {
+		config.RequestReadTimeout = DefaultRequestReadTimeout
+	}
+	if config.StoreTimeoutPolicy == "" {
+		config.StoreTimeoutPolicy = StoreFailClosed
+	}
+	if config.StatsRetention == 0 {
+		config.StatsRetention = defaultStatsRetention
+	}
 }
 
 func initializeBroadcasters(p *Paywall, config Config) {
@@ -522,6 +1638,7 @@ func initializeBroadcasters(p *Paywall, config Config) {
 			})
 		} else {
 			p.btcBroadcaster = btcBroadcaster
+			p.btcTxSubmitter = btcBroadcaster
 			p.logger.log(LogEntry{
 				Level:   LogLevelInfo,
 				Event:   "btc_broadcaster_initialized",
@@ -587,35 +1704,121 @@ func NewPaywall(config Config) (*Paywall, error) {
 	pctx, pcancel := context.WithCancel(context.Background())
 
 	p := &Paywall{
-		HDWallets:             hdWallets,
-		Store:                 config.Store,
-		logger:                config.Logger,
-		prices:                prices,
-		paymentTimeout:        config.PaymentTimeout,
-		minConfirmations:      config.MinConfirmations,
-		template:              tmpl,
-		ctx:                   pctx,
-		cancel:                pcancel,
-		multisigEnabled:       config.MultisigEnabled,
-		multisigRequired:      config.MultisigRequired,
-		multisigTotal:         config.MultisigTotal,
-		participantPubKeys:    config.ParticipantPubKeys,
-		multisigRole:          config.MultisigRole,
-		authorizedArbiters:    config.AuthorizedArbiters,
-		minEscrowTimeout:      config.MinEscrowTimeout,
-		maxEscrowTimeout:      config.MaxEscrowTimeout,
-		disputeFeePercent:     config.DisputeFeePercent,
-		maxDisputesPerPeriod:  config.MaxDisputesPerPeriod,
-		disputePeriod:         config.DisputePeriod,
-		maxEvidenceSizeBytes:  config.MaxEvidenceSizeBytes,
-		extendEscrowOnDispute: config.ExtendEscrowOnDispute,
-		disputeHistory:        make(map[string][]time.Time),
+		HDWallets:                hdWallets,
+		Store:                    config.Store,
+		locker:                   resolvePaymentLocker(config),
+		logger:                   config.Logger,
+		metrics:                  newPaywallMetrics(),
+		prices:                   prices,
+		paymentTimeout:           config.PaymentTimeout,
+		accessDuration:           config.AccessDuration,
+		minConfirmations:         config.MinConfirmations,
+		template:                 tmpl,
+		ctx:                      pctx,
+		cancel:                   pcancel,
+		multisigEnabled:          config.MultisigEnabled,
+		multisigRequired:         config.MultisigRequired,
+		multisigTotal:            config.MultisigTotal,
+		participantPubKeys:       config.ParticipantPubKeys,
+		multisigRole:             config.MultisigRole,
+		authorizedArbiters:       config.AuthorizedArbiters,
+		minEscrowTimeout:         config.MinEscrowTimeout,
+		maxEscrowTimeout:         config.MaxEscrowTimeout,
+		disputeFeePercent:        config.DisputeFeePercent,
+		maxDisputesPerPeriod:     config.MaxDisputesPerPeriod,
+		disputePeriod:            config.DisputePeriod,
+		maxEvidenceSizeBytes:     config.MaxEvidenceSizeBytes,
+		extendEscrowOnDispute:    config.ExtendEscrowOnDispute,
+		disputeHistory:           make(map[string][]time.Time),
+		queryTokenEnabled:        config.QueryTokenEnabled,
+		queryTokenTTL:            config.QueryTokenTTL,
+		accessGrantTTL:           config.AccessGrantTTL,
+		bearerTokens:             config.BearerTokensEnabled,
+		apiKeyHeader:             config.APIKeyHeader,
+		creditsPerPayment:        config.CreditsPerPayment,
+		paymentCredits:           config.PaymentCredits,
+		credits:                  resolveCreditStore(config),
+		rand:                     config.Rand,
+		priceOracle:              config.PriceOracle,
+		fiatCurrency:             config.FiatCurrency,
+		fiatEstimates:            config.FiatEstimates,
+		paymentFingerprint:       config.PaymentFingerprint,
+		qrCodePath:               config.QRCodePath,
+		assetPath:                config.AssetPath,
+		btcTxSubmitPath:          config.BTCTxSubmitPath,
+		walletApps:               config.WalletApps,
+		paymentLabel:             config.PaymentLabel,
+		faucetURL:                config.TestnetFaucetURL,
+		faucetPath:               config.TestnetFaucetPath,
+		faucetClient:             &http.Client{Timeout: faucetRequestTimeout},
+		statusPath:               config.StatusPath,
+		statusPollInterval:       config.StatusPollInterval,
+		jsonResponses:            config.JSONResponses,
+		pageDataHook:             config.PageDataHook,
+		watchDecayAfter:          config.WatchDecayAfter,
+		watchDecayMaxInterval:    config.WatchDecayMaxInterval,
+		monitorStoreRetries:      max(config.MonitorStoreRetries, 0),
+		monitorStoreRetryBackoff: config.MonitorStoreRetryBackoff,
+		expirySweepInterval:      config.ExpirySweepInterval,
+		expiredPaymentRetention:  config.ExpiredPaymentRetention,
+		monitorShadow:            config.MonitorShadow,
+		confirmationStrategies:   config.ConfirmationStrategies,
+		meter:                    resolveMeterStore(config),
+		freeRequests:             config.FreeRequests,
+		freeTime:                 config.FreeTime,
+		meterWindow:              config.MeterWindow,
+		limits: requestLimits{
+			maxBytes:    config.MaxRequestBodyBytes,
+			readTimeout: config.RequestReadTimeout,
+		},
+		rateLimiter:        newRateLimiter(config),
+		storeTimeout:       config.StoreTimeout,
+		storeTimeoutPolicy: config.StoreTimeoutPolicy,
+		statsRetention:     config.StatsRetention,
+		adminToken:         config.AdminToken,
+		adminUsers:         maps.Clone(config.AdminUsers),
+		walletStorage:      config.BTCWalletStorage,
+	}
+
+	if config.APIKeysEnabled {
+		p.apiKeys = newAPIKeyState()
+	}
+
+	if config.ReusePendingPayments {
+		p.pendingIndex = newPendingIndex(config.ReusePendingWindow)
 	}
 
 	if p.logger == nil {
 		p.logger = NewStructuredLogger(io.Discard, LogLevelError, true)
 	}
 
+	if config.Rand != rand.Reader && !config.TestNet {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "custom_rand_on_mainnet",
+			Message: "Config.Rand replaces crypto/rand on mainnet; wallet seeds and tokens are only as unpredictable as this source",
+		})
+	}
+
+	p.recoverBTCWallet(config)
+	if err := p.initializeAddressIndexes(config); err != nil {
+		pcancel()
+		return nil, err
+	}
+
+	// Already validated in validateConfig
+	p.trustedProxies, _ = parseTrustedProxies(config.TrustedProxies)
+
+	p.signer, err = newTokenSigner(config.SigningKey, config.Rand)
+	if err != nil {
+		pcancel()
+		return nil, fmt.Errorf("initialize token signer: %w", err)
+	}
+	if config.AnonymousLogs {
+		p.redactor = &logRedactor{signer: p.signer}
+		p.logger = p.logger.withRedactor(p.redactor)
+	}
+
 	if p.disputePeriod <= 0 {
 		p.disputePeriod = 30 * 24 * time.Hour
 	}
@@ -654,7 +1857,41 @@ func NewPaywall(config Config) (*Paywall, error) {
 		})
 	}
 
-	startBackgroundWorkers(p, hdWallets, config)
+	if config.EventSink != nil {
+		p.eventSink = newEventSink(*config.EventSink, p.logger, config.Rand)
+	}
+	if config.Refunds != nil {
+		p.refunds = newRefundManager(p, *config.Refunds, config.TestNet)
+	}
+	for _, notifier := range config.Notifiers {
+		p.notifiers = append(p.notifiers, newNotifierSink(notifier, p.logger, config.Rand))
+	}
+
+	if config.MonitorWALPath != "" {
+		p.monitorWAL, err = openMonitorWAL(config.MonitorWALPath)
+		if err != nil {
+			pcancel()
+			return nil, fmt.Errorf("open monitor log: %w", err)
+		}
+		if p.monitorWAL.skipped > 0 {
+			p.logger.log(LogEntry{
+				Level:   LogLevelWarn,
+				Event:   "monitor_wal_lines_skipped",
+				Message: fmt.Sprintf("Skipped %d unreadable lines of the monitor log, e.g. one torn by a crash", p.monitorWAL.skipped),
+			})
+		}
+		if pending := p.monitorWAL.pendingCount(); pending > 0 {
+			p.logger.log(LogEntry{
+				Level:   LogLevelInfo,
+				Event:   "monitor_wal_pending",
+				Message: fmt.Sprintf("Replaying %d payment status changes that did not reach the store", pending),
+			})
+		}
+	}
+
+	if !config.ExternalMonitor {
+		startBackgroundWorkers(p, hdWallets, config)
+	}
 
 	// Initialize webhook dispatcher if configured
 	if config.WebhookConfig != nil {
@@ -666,25 +1903,31 @@ func NewPaywall(config Config) (*Paywall, error) {
 		})
 	}
 
-	return p, nil
-}
-
-func (p *Paywall) Close() {
-	// Stop timeout monitor if running
-	if p.timeoutMonitor != nil {
-		p.timeoutMonitor.Stop()
+	// Price new payments in fiat; started last so price events reach the
+	// webhook dispatcher and event sink
+	if config.PriceInFiat > 0 {
+		p.priceRefresher = newPriceRefresher(p, config)
+		p.priceRefresher.Start()
 	}
-	// Stop consensus manager if running
-	if p.consensusManager != nil {
-		p.consensusManager.Stop()
+
+	if config.StatsInterval > 0 && !config.ExternalMonitor {
+		p.statsRecorder = newStatsRecorder(p, config.StatsInterval)
+		p.statsRecorder.Start()
 	}
-	// Stop webhook dispatcher if running
-	if p.webhookDispatcher != nil {
-		p.webhookDispatcher.Close()
+
+	if config.RetentionPolicy != nil {
+		retention := *config.RetentionPolicy
+		if retention.Interval == 0 {
+			retention.Interval = defaultRetentionInterval
+		}
+		p.retention = &retention
+		if !config.ExternalMonitor {
+			p.retentionPurger = newRetentionPurger(p)
+			p.retentionPurger.Start()
+		}
 	}
-	// Cancel context and close monitor
-	p.cancel()
-	p.monitor.Close()
+
+	return p, nil
 }
 
 func (p *Paywall) btcWalletAddress() (string, error) {
@@ -761,28 +2004,56 @@ func (p *Paywall) addressMap() (map[wallet.WalletType]string, error) {
 //   - Initial status of StatusPending
 //
 // Error handling:
+//   - Returns ErrReadOnlyMode while the paywall is in ModeReadOnly
+//   - Returns ErrTransitionVetoed if a payment hook refuses the payment (see Use)
 //   - Returns error if random ID generation fails
 //   - Returns error if any wallet address generation fails
 //   - Validates payment amounts against dust limits
 //
 // Related types: Payment, wallet.HDWallet, PaymentStatus
 func (p *Paywall) CreatePayment() (*Payment, error) {
+	return p.createPayment(nil, "", nil)
+}
+
+// createPayment creates a payment for r, the visitor's request passed to
+// payment hooks (nil outside of Middleware), in partition ("" for none),
+// priced by route (nil for the paywall's prices)
+func (p *Paywall) createPayment(r *http.Request, partition string, route *RouteConfig) (*Payment, error) {
+	if p.Mode() == ModeReadOnly {
+		return nil, ErrReadOnlyMode
+	}
+	// A visitor who goes away cancels the wallet and store calls
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+
 	// Generate cryptographically secure payment ID
-	idBytes := make([]byte, 16)
-	if _, err := rand.Read(idBytes); err != nil {
+	paymentID, err := generatePaymentID(p.rand)
+	if err != nil {
 		return nil, fmt.Errorf("generate payment ID: %w", err)
 	}
-	paymentID := hex.EncodeToString(idBytes)
+	paymentID = partitionIDPrefix(partition) + paymentID
+
+	timeout := p.paymentTimeout
+	if route != nil && route.PaymentTimeout > 0 {
+		timeout = route.PaymentTimeout
+	}
 
 	// Create payment record
+	now := time.Now()
 	payment := &Payment{
 		ID:            paymentID,
 		Addresses:     make(map[wallet.WalletType]string),
 		Amounts:       make(map[wallet.WalletType]float64),
-		CreatedAt:     time.Now(),
-		ExpiresAt:     time.Now().Add(p.paymentTimeout),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(timeout),
 		Status:        StatusPending,
 		Confirmations: 0,
+		Route:         routeName(route),
+	}
+	if route != nil {
+		payment.MinConfirmations = route.MinConfirmations
 	}
 
 	// Initialize multisig fields if multisig is enabled
@@ -793,11 +2064,34 @@ func (p *Paywall) CreatePayment() (*Payment, error) {
 		payment.Signatures = make(map[wallet.WalletType][]SignatureData)
 	}
 
+	if p.priceRefresher != nil {
+		p.priceRefresher.refreshIfStale()
+	}
+
 	// Generate addresses for all enabled wallets
 	// Track which wallets had addresses generated for rollback on failure
 	var generatedWallets []wallet.WalletType
 	for walletType, hdWallet := range p.HDWallets {
+		if p.currencyDisabled(walletType) {
+			continue
+		}
+		amount, priced := p.price(walletType)
+		if route != nil {
+			var offered bool
+			if amount, offered = route.price(walletType); !offered {
+				continue
+			}
+		} else if priced && amount <= 0 {
+			// A zero price disables the currency, e.g. on-chain Bitcoin on a
+			// Lightning-only paywall
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			p.rollbackAddressGeneration(generatedWallets)
+			return nil, fmt.Errorf("generate %s address: %w", walletType, err)
+		}
 		var address string
+		var reserved bool
 		var err error
 
 		// Use multisig address if enabled, otherwise use standard HD derivation
@@ -822,8 +2116,23 @@ func (p *Paywall) CreatePayment() (*Payment, error) {
 			payment.MultisigMetadata[walletType] = metadata
 			payment.RequiredSignatures[walletType] = p.multisigRequired
 		} else {
-			// Standard single-signature address derivation
-			address, err = hdWallet.DeriveNextAddress()
+			// Standard single-signature address derivation, from the wallet
+			// selected by Config.BTCWalletRotation when there are several
+			if rotation, ok := hdWallet.(*walletRotation); ok {
+				var walletID string
+				walletID, address, err = rotation.deriveNext()
+				if err == nil {
+					if payment.WalletIDs == nil {
+						payment.WalletIDs = make(map[wallet.WalletType]string)
+					}
+					payment.WalletIDs[walletType] = walletID
+				}
+			} else if invoicer, ok := hdWallet.(wallet.InvoiceWallet); ok {
+				// Lightning invoices carry the amount and expire with the payment
+				address, err = invoicer.CreateInvoice(amount, timeout)
+			} else if address, reserved, err = p.reservedAddress(ctx, walletType, hdWallet); !reserved {
+				address, err = hdWallet.DeriveNextAddress()
+			}
 			if err != nil {
 				// Rollback any previously generated addresses
 				p.rollbackAddressGeneration(generatedWallets)
@@ -832,8 +2141,11 @@ func (p *Paywall) CreatePayment() (*Payment, error) {
 		}
 
 		payment.Addresses[walletType] = address
-		payment.Amounts[walletType] = p.prices[walletType]
-		generatedWallets = append(generatedWallets, walletType)
+		payment.Amounts[walletType] = amount
+		if !reserved {
+			// Reserved indexes stay with the Store, which never returns them
+			generatedWallets = append(generatedWallets, walletType)
+		}
 	}
 
 	// Validate payment has at least one enabled currency
@@ -841,10 +2153,33 @@ func (p *Paywall) CreatePayment() (*Payment, error) {
 		return nil, fmt.Errorf("no wallets enabled for payment")
 	}
 
-	// Store the payment
-	if err := p.Store.CreatePayment(payment); err != nil {
-		// Rollback address generation on storage failure
-		p.rollbackAddressGeneration(generatedWallets)
+	// Save the address index before the address is handed out, so that a
+	// restart does not give it to another payment. An address whose index
+	// could not be saved is not handed out at all.
+	if _, derived := payment.Addresses[wallet.Bitcoin]; derived && !p.multisigEnabled && p.addressIndexes == nil {
+		if err := p.persistWallets(); err != nil {
+			p.rollbackAddressGeneration(generatedWallets)
+			return nil, fmt.Errorf("save bitcoin address index: %w", err)
+		}
+	}
+
+	// Store the payment, unless a payment hook vetoes it
+	created := &PaymentTransition{Event: TransitionCreate, To: StatusPending, Payment: payment, Request: r}
+	err = p.transition(created, func(t *PaymentTransition) error {
+		_, err := withStoreBudget(ctx, p, "create payment", func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, createPaymentContext(ctx, p.Store, t.Payment)
+		})
+		return err
+	})
+	if err != nil {
+		// Rollback address generation on storage failure or veto. A timed-out
+		// write may still land, so its addresses stay reserved.
+		if !errors.Is(err, ErrStoreTimeout) {
+			p.rollbackAddressGeneration(generatedWallets)
+		}
+		if errors.Is(err, ErrTransitionVetoed) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("store payment: %w", err)
 	}
 
@@ -854,20 +2189,18 @@ func (p *Paywall) CreatePayment() (*Payment, error) {
 		}
 	}
 
-	// Dispatch webhook for payment creation
-	if p.webhookDispatcher != nil {
-		p.webhookDispatcher.Dispatch(WebhookPayload{
-			Event:     EventPaymentCreated,
-			PaymentID: payment.ID,
-			Timestamp: time.Now(),
-			Data: map[string]interface{}{
-				"addresses":        payment.Addresses,
-				"amounts":          payment.Amounts,
-				"expires_at":       payment.ExpiresAt,
-				"multisig_enabled": payment.MultisigEnabled,
-			},
-		})
-	}
+	// Publish payment creation
+	p.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentCreated,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"addresses":        payment.Addresses,
+			"amounts":          payment.Amounts,
+			"expires_at":       payment.ExpiresAt,
+			"multisig_enabled": payment.MultisigEnabled,
+		},
+	})
 
 	return payment, nil
 }
@@ -883,20 +2216,31 @@ func (p *Paywall) rollbackAddressGeneration(walletTypes []wallet.WalletType) {
 				w.RollbackLastAddress()
 			case *wallet.MoneroHDWallet:
 				w.RollbackLastAddress()
+			case *wallet.MoneroLWSWallet:
+				w.RollbackLastAddress()
+			case *wallet.ETHHDWallet:
+				w.RollbackLastAddress()
+			case *wallet.UTXOHDWallet:
+				w.RollbackLastAddress()
+			case *wallet.BTCWatchWallet:
+				w.RollbackLastAddress()
+			case *walletRotation:
+				w.RollbackLastAddress()
 			}
 		}
 	}
 }
 
 // generatePaymentID creates a random 16-byte hex-encoded payment identifier
+// Parameters:
+//   - r: Randomness source (Config.Rand), nil for crypto/rand
+//
 // Returns:
 //   - string: A 32-character hexadecimal string
 //   - error: If random generation fails
-//
-// This is an internal helper function that uses crypto/rand for secure randomness
-func generatePaymentID() (string, error) {
+func generatePaymentID(r io.Reader) (string, error) {
 	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
+	if err := readRandom(r, b); err != nil {
 		return "", fmt.Errorf("failed to generate secure random payment ID: %w", err)
 	}
 	return hex.EncodeToString(b), nil
@@ -0,0 +1,130 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// latePaymentWindow is how long after expiry the monitor keeps checking an
+// expired payment, so funds sent at the last minute still confirm it
+const latePaymentWindow = 24 * time.Hour
+
+// monitoredStatuses are the statuses of payments the monitor checks
+var monitoredStatuses = []PaymentStatus{StatusPending, StatusDetected, StatusExpired}
+
+// needsMonitoring reports whether the monitor checks payment at now: unpaid
+// payments (the check expires them once past ExpiresAt) and payments that
+// expired less than latePaymentWindow ago
+func needsMonitoring(payment *Payment, now time.Time) bool {
+	switch payment.Status {
+	case StatusPending, StatusDetected:
+		return true
+	case StatusExpired:
+		return now.Sub(payment.ExpiresAt) < latePaymentWindow
+	}
+	return false
+}
+
+// ListForMonitoring returns the payments the monitor checks in a cycle
+// starting now: unpaid payments due for a check (see Config.WatchDecayAfter)
+// and payments that expired within the last 24 hours, which a late payment
+// still confirms. They are ordered like the monitor checks them, payments
+// about to expire first.
+//
+// Unlike PaymentStore.ListPendingPayments, whose confirmation-count filter
+// differs between stores, the selection only depends on payment status and
+// expiry.
+//
+// Returns:
+//   - []*Payment: The monitor's queue
+//   - error: If the store cannot be read
+//
+// Related: ListPendingForDisplay
+func (p *Paywall) ListForMonitoring() ([]*Payment, error) {
+	now := time.Now()
+	payments, err := p.monitoredPayments(context.Background(), now)
+	if err != nil {
+		return nil, err
+	}
+	due := payments[:0]
+	for _, payment := range payments {
+		if p.dueForCheck(payment, now) {
+			due = append(due, payment)
+		}
+	}
+	return due, nil
+}
+
+// monitoredPayments returns every payment needsMonitoring selects at now,
+// whether or not it is due for a check, in monitor priority order. Listing
+// stops with ctx's error once ctx is done.
+func (p *Paywall) monitoredPayments(ctx context.Context, now time.Time) ([]*Payment, error) {
+	start := time.Now()
+	payments, err := selectPayments(ctx, p.Store, PaymentFilter{Statuses: monitoredStatuses}, func(payment *Payment) bool {
+		return needsMonitoring(payment, now)
+	})
+	p.metrics.observeStore("list payments", time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("list payments for monitoring: %w", err)
+	}
+	sortByMonitorPriority(payments, now)
+	return payments, nil
+}
+
+// ListPendingForDisplay returns the payments a person would call pending:
+// status StatusPending, expired or not yet. Payments with a detected
+// transaction (StatusDetected) are not included.
+//
+// Returns:
+//   - []*Payment: Pending payments, in no particular order
+//   - error: If the store cannot be read
+//
+// Related: ListForMonitoring
+func (p *Paywall) ListPendingForDisplay() ([]*Payment, error) {
+	payments, err := selectPayments(context.Background(), p.Store, PaymentFilter{Statuses: []PaymentStatus{StatusPending}}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list pending payments: %w", err)
+	}
+	return payments, nil
+}
+
+// selectPayments collects the payments in store matching filter and keep
+// (which may be nil). Stores that can neither stream nor list payments are
+// read through ListPendingPayments. A streamed selection stops with ctx's
+// error once ctx is done.
+func selectPayments(ctx context.Context, store PaymentStore, filter PaymentFilter, keep func(*Payment) bool) ([]*Payment, error) {
+	var payments []*Payment
+	collect := func(payment *Payment) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if keep == nil || keep(payment) {
+			payments = append(payments, payment)
+		}
+		return nil
+	}
+
+	_, streams := store.(PaymentStreamer)
+	_, lists := store.(PaymentLister)
+	if streams || lists {
+		if err := StreamPayments(store, filter, collect); err != nil {
+			return nil, err
+		}
+		return payments, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pending, err := store.ListPendingPayments()
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range pending {
+		if filter.Matches(payment) {
+			collect(payment)
+		}
+	}
+	return payments, nil
+}
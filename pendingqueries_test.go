@@ -0,0 +1,85 @@
+package paywall
+
+import (
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestPendingQueries(t *testing.T) {
+	now := time.Now()
+	payment := func(id string, status PaymentStatus, expiresAt time.Time) *Payment {
+		return &Payment{
+			ID:        id,
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: id + "-address"},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+			CreatedAt: now.Add(-time.Hour),
+			ExpiresAt: expiresAt,
+			Status:    status,
+		}
+	}
+	idle := payment("idle", StatusPending, now.Add(time.Hour))
+	idle.CreatedAt = now.Add(-10 * time.Hour)
+	idle.LastCheckedAt = now
+	payments := []*Payment{
+		payment("pending", StatusPending, now.Add(time.Hour)),
+		payment("pending-past-expiry", StatusPending, now.Add(-time.Minute)),
+		payment("detected", StatusDetected, now.Add(time.Hour)),
+		payment("expired-recently", StatusExpired, now.Add(-time.Hour)),
+		payment("expired-long-ago", StatusExpired, now.Add(-48*time.Hour)),
+		payment("confirmed", StatusConfirmed, now.Add(time.Hour)),
+		idle,
+	}
+
+	stores := map[string]PaymentStore{
+		"streaming": NewMemoryStore(),
+		// Embedding the interface hides StreamPayments and ListPayments, leaving
+		// ListPendingPayments
+		"pending list only": struct{ PaymentStore }{NewMemoryStore()},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, p := range payments {
+				if err := store.CreatePayment(p); err != nil {
+					t.Fatalf("CreatePayment(%s) error = %v", p.ID, err)
+				}
+			}
+			pw := &Paywall{
+				Store:                 store,
+				logger:                NewStructuredLogger(io.Discard, LogLevelError, true),
+				watchDecayAfter:       time.Hour,
+				watchDecayMaxInterval: defaultWatchDecayMaxInterval,
+			}
+
+			ids := func(payments []*Payment) []string {
+				var ids []string
+				for _, payment := range payments {
+					ids = append(ids, payment.ID)
+				}
+				slices.Sort(ids)
+				return ids
+			}
+
+			monitoring, err := pw.ListForMonitoring()
+			if err != nil {
+				t.Fatalf("ListForMonitoring() error = %v", err)
+			}
+			want := []string{"detected", "expired-recently", "pending", "pending-past-expiry"}
+			if got := ids(monitoring); !slices.Equal(got, want) {
+				t.Errorf("ListForMonitoring() = %v, want %v", got, want)
+			}
+
+			display, err := pw.ListPendingForDisplay()
+			if err != nil {
+				t.Fatalf("ListPendingForDisplay() error = %v", err)
+			}
+			want = []string{"idle", "pending", "pending-past-expiry"}
+			if got := ids(display); !slices.Equal(got, want) {
+				t.Errorf("ListPendingForDisplay() = %v, want %v", got, want)
+			}
+		})
+	}
+}
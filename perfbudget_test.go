@@ -0,0 +1,119 @@
+package paywall
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// Performance budget: TestPerformanceBudget runs the hot path benchmarks below
+// and fails when one got slower, or allocates more, than recorded in
+// perfBudgetFile. Timings depend on the machine, so the check only runs when
+// asked for, on the machine that recorded the budget:
+//
+//	go test -run TestPerformanceBudget -perf.budget .                  # compare
+//	go test -run TestPerformanceBudget -perf.budget -perf.update .     # record
+//
+// Record the budget again when a change makes a path deliberately slower, and
+// commit the file with the change.
+var (
+	perfBudget    = flag.Bool("perf.budget", false, "compare the hot path benchmarks with "+perfBudgetFile)
+	perfUpdate    = flag.Bool("perf.update", false, "record the hot path benchmarks in "+perfBudgetFile)
+	perfTolerance = flag.Float64("perf.tolerance", 0.5, "allowed ns/op increase over the budget, as a fraction")
+)
+
+// perfBudgetFile holds the recorded budget
+const perfBudgetFile = "testdata/perf_budget.json"
+
+// perfAllocSlack is how many allocations per operation a benchmark may add
+// before failing the budget; allocation counts barely depend on the machine
+const perfAllocSlack = 2
+
+// perfBudgetBenchmarks are the benchmarks held to the budget
+var perfBudgetBenchmarks = []struct {
+	name string
+	fn   func(b *testing.B)
+}{
+	{name: "MiddlewareAllowPath", fn: benchMiddlewareAllow},
+	{name: "MiddlewareNewPayment", fn: benchMiddlewareNewPayment},
+	{name: "CreatePayment", fn: benchCreatePayment},
+	{name: "StoreLookupMemory10k", fn: func(b *testing.B) { benchStoreLookup(b, NewMemoryStore(), 10000, false) }},
+	{name: "StoreLookupMemory10kByAddress", fn: func(b *testing.B) { benchStoreLookup(b, NewMemoryStore(), 10000, true) }},
+	{name: "MonitorCycle1000", fn: func(b *testing.B) { benchMonitorCycle(b, 1000) }},
+}
+
+// perfResult is the recorded cost of one benchmark operation
+type perfResult struct {
+	NsPerOp     int64 `json:"ns_per_op"`
+	AllocsPerOp int64 `json:"allocs_per_op"`
+	BytesPerOp  int64 `json:"bytes_per_op"`
+}
+
+func TestPerformanceBudget(t *testing.T) {
+	if !*perfBudget {
+		t.Skip("run with -perf.budget to check the performance budget")
+	}
+	if raceDetectorEnabled {
+		t.Skip("the race detector distorts timings")
+	}
+
+	budget := map[string]perfResult{}
+	if !*perfUpdate {
+		data, err := os.ReadFile(perfBudgetFile)
+		if err != nil {
+			t.Fatalf("read budget (hint: record one with -perf.update): %v", err)
+		}
+		if err := json.Unmarshal(data, &budget); err != nil {
+			t.Fatalf("parse %s: %v", perfBudgetFile, err)
+		}
+	}
+
+	current := make(map[string]perfResult, len(perfBudgetBenchmarks))
+	var report strings.Builder
+	tw := tabwriter.NewWriter(&report, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\told ns/op\tnew ns/op\tdelta\told allocs\tnew allocs\t")
+	for _, bench := range perfBudgetBenchmarks {
+		result := testing.Benchmark(bench.fn)
+		if result.N == 0 {
+			t.Fatalf("%s: benchmark failed", bench.name)
+		}
+		got := perfResult{NsPerOp: result.NsPerOp(), AllocsPerOp: result.AllocsPerOp(), BytesPerOp: result.AllocedBytesPerOp()}
+		current[bench.name] = got
+
+		want, ok := budget[bench.name]
+		if *perfUpdate || !ok {
+			fmt.Fprintf(tw, "%s\t-\t%d\t\t-\t%d\t\n", bench.name, got.NsPerOp, got.AllocsPerOp)
+			if !*perfUpdate {
+				t.Errorf("%s has no budget (hint: record it with -perf.update)", bench.name)
+			}
+			continue
+		}
+		delta := float64(got.NsPerOp-want.NsPerOp) / float64(max(want.NsPerOp, 1))
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+.2f%%\t%d\t%d\t\n", bench.name, want.NsPerOp, got.NsPerOp, delta*100, want.AllocsPerOp, got.AllocsPerOp)
+		if delta > *perfTolerance {
+			t.Errorf("%s: %d ns/op exceeds the budget of %d ns/op by more than %.0f%%", bench.name, got.NsPerOp, want.NsPerOp, *perfTolerance*100)
+		}
+		if got.AllocsPerOp > want.AllocsPerOp+perfAllocSlack {
+			t.Errorf("%s: %d allocs/op exceeds the budget of %d allocs/op", bench.name, got.AllocsPerOp, want.AllocsPerOp)
+		}
+	}
+	tw.Flush()
+	t.Log("\n" + report.String())
+
+	if *perfUpdate {
+		data, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(perfBudgetFile, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
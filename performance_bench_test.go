@@ -304,7 +304,7 @@ func BenchmarkStoreCreatePayment(b *testing.B) {
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
-		paymentID, _ := generatePaymentID()
+		paymentID, _ := generatePaymentID(nil)
 		payment := &Payment{
 			ID: paymentID,
 			Addresses: map[wallet.WalletType]string{
@@ -327,7 +327,7 @@ func BenchmarkStoreGetPayment(b *testing.B) {
 	store := NewMemoryStore()
 
 	// Create test payment
-	paymentID, _ := generatePaymentID()
+	paymentID, _ := generatePaymentID(nil)
 	payment := &Payment{
 		ID: paymentID,
 		Addresses: map[wallet.WalletType]string{
@@ -356,7 +356,7 @@ func BenchmarkStoreUpdatePayment(b *testing.B) {
 	store := NewMemoryStore()
 
 	// Create test payment
-	paymentID, _ := generatePaymentID()
+	paymentID, _ := generatePaymentID(nil)
 	payment := &Payment{
 		ID: paymentID,
 		Addresses: map[wallet.WalletType]string{
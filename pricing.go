@@ -0,0 +1,234 @@
+package paywall
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const (
+	// defaultPriceRefreshInterval is used when Config.PriceRefreshInterval is zero
+	defaultPriceRefreshInterval = 5 * time.Minute
+	// defaultPriceChangeThreshold is used when Config.PriceChangeThreshold is zero
+	defaultPriceChangeThreshold = 1.0
+)
+
+// priceDustLimits are the smallest prices derived from PriceInFiat that the
+// paywall accepts, matching the checks in validateConfig
+var priceDustLimits = map[wallet.WalletType]float64{
+	wallet.Bitcoin:     0.00001,
+	wallet.Monero:      0.0001,
+	wallet.Lightning:   minLightningAmount,
+	wallet.Litecoin:    0.0001,
+	wallet.Dogecoin:    0.01,
+	wallet.BitcoinCash: 0.00001,
+}
+
+// priceDecimals are the decimal places a derived price is rounded to, the
+// smallest unit of each currency
+var priceDecimals = map[wallet.WalletType]float64{
+	wallet.Bitcoin:     8,
+	wallet.Monero:      12,
+	wallet.Lightning:   11,
+	wallet.Ethereum:    18,
+	wallet.Litecoin:    8,
+	wallet.Dogecoin:    8,
+	wallet.BitcoinCash: 8,
+}
+
+// price returns the current amount a new payment in walletType costs
+//
+// Parameters:
+//   - walletType: Currency of the payment
+//
+// Returns:
+//   - float64: Price in crypto
+//   - bool: False if the currency is not enabled
+func (p *Paywall) price(walletType wallet.WalletType) (float64, bool) {
+	p.pricesMu.RLock()
+	defer p.pricesMu.RUnlock()
+	price, ok := p.prices[walletType]
+	return price, ok
+}
+
+// Prices returns the current crypto price of new payments per enabled currency.
+// With Config.PriceInFiat the prices follow the oracle rate, see
+// Config.PriceRefreshInterval.
+//
+// Returns:
+//   - map[wallet.WalletType]float64: Copy of the current prices
+//
+// Related: Config.PriceInFiat, EventPriceChanged
+func (p *Paywall) Prices() map[wallet.WalletType]float64 {
+	p.pricesMu.RLock()
+	defer p.pricesMu.RUnlock()
+	prices := make(map[wallet.WalletType]float64, len(p.prices))
+	for walletType, price := range p.prices {
+		prices[walletType] = price
+	}
+	return prices
+}
+
+// priceRefresher periodically converts Config.PriceInFiat into crypto prices,
+// so creating a payment never waits for the oracle
+type priceRefresher struct {
+	paywall   *Paywall
+	fiatPrice float64
+	interval  time.Duration
+	// lazy skips the refresh loop, for Config.ExternalMonitor instances that
+	// must not run background goroutines; they refresh when a payment is
+	// created after the interval has passed, see refreshIfStale
+	lazy bool
+	// refreshMu serializes lazy refreshes, and refreshed is when the last one
+	// started
+	refreshMu sync.Mutex
+	refreshed time.Time
+	// threshold is the relative change, in percent, a rate must move before
+	// the price is updated
+	threshold float64
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newPriceRefresher creates a refresher for the paywall's fiat price
+func newPriceRefresher(p *Paywall, config Config) *priceRefresher {
+	return &priceRefresher{
+		paywall:   p,
+		fiatPrice: config.PriceInFiat,
+		interval:  config.PriceRefreshInterval,
+		lazy:      config.ExternalMonitor,
+		threshold: config.PriceChangeThreshold,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start fetches the rates once, then refreshes them every interval until Stop
+func (r *priceRefresher) Start() {
+	r.refreshed = time.Now()
+	r.refresh(true)
+	if r.lazy {
+		close(r.done)
+		return
+	}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.refresh(false)
+			}
+		}
+	}()
+}
+
+// refreshIfStale refreshes the rates of a lazy refresher when the last
+// refresh is older than the interval. Payments created while another one
+// refreshes keep the current price instead of waiting for the oracle.
+func (r *priceRefresher) refreshIfStale() {
+	if !r.lazy || !r.refreshMu.TryLock() {
+		return
+	}
+	defer r.refreshMu.Unlock()
+	if time.Since(r.refreshed) < r.interval {
+		return
+	}
+	r.refreshed = time.Now()
+	r.refresh(false)
+}
+
+// Stop ends the refresh loop and waits for a running refresh to finish
+func (r *priceRefresher) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+// refresh queries the oracle for every currency with a price. A price is
+// replaced when force is set or it moved by more than the threshold; failed
+// lookups and prices below the dust limit keep the current price.
+func (r *priceRefresher) refresh(force bool) {
+	p := r.paywall
+	now := time.Now()
+	for walletType, current := range p.Prices() {
+		if current <= 0 {
+			// Currency disabled by a zero PriceInBTC/PriceInXMR
+			continue
+		}
+		rate, err := p.priceOracle.FiatPrice(rateCurrency(walletType), p.fiatCurrency, now)
+		if err == nil && (rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0)) {
+			err = fmt.Errorf("invalid rate %v", rate)
+		}
+		if err != nil {
+			p.logger.log(LogEntry{
+				Level:   LogLevelWarn,
+				Event:   "price_refresh_failed",
+				Message: fmt.Sprintf("Failed to get %s %s rate, keeping price %.12g: %v", walletType, p.fiatCurrency, current, err),
+			})
+			continue
+		}
+
+		price := roundPrice(walletType, r.fiatPrice/rate)
+		if price <= priceDustLimits[walletType] {
+			p.logger.log(LogEntry{
+				Level:   LogLevelWarn,
+				Event:   "price_below_dust",
+				Message: fmt.Sprintf("%.2f %s is %.12g %s, below the dust limit; keeping price %.12g", r.fiatPrice, p.fiatCurrency, price, walletType, current),
+			})
+			continue
+		}
+		if price == current || (!force && !priceMoved(current, price, r.threshold)) {
+			continue
+		}
+
+		p.pricesMu.Lock()
+		p.prices[walletType] = price
+		p.pricesMu.Unlock()
+
+		p.logger.log(LogEntry{
+			Level:   LogLevelInfo,
+			Event:   "price_changed",
+			Message: fmt.Sprintf("%s price changed from %.12g to %.12g (%.2f %s at %.2f)", walletType, current, price, r.fiatPrice, p.fiatCurrency, rate),
+		})
+		p.dispatchEvent(WebhookPayload{
+			Event:     EventPriceChanged,
+			Timestamp: now,
+			Data: map[string]interface{}{
+				"currency":      walletType,
+				"old_price":     current,
+				"new_price":     price,
+				"fiat_price":    r.fiatPrice,
+				"fiat_currency": p.fiatCurrency,
+				"rate":          rate,
+			},
+		})
+	}
+}
+
+// priceMoved reports whether price differs from current by more than
+// threshold percent
+func priceMoved(current, price, threshold float64) bool {
+	if current <= 0 {
+		return true
+	}
+	return math.Abs(price-current)/current*100 > threshold
+}
+
+// roundPrice rounds price to the smallest unit of walletType
+func roundPrice(walletType wallet.WalletType, price float64) float64 {
+	decimals, ok := priceDecimals[walletType]
+	if !ok {
+		return price
+	}
+	scale := math.Pow(10, decimals)
+	return math.Round(price*scale) / scale
+}
@@ -0,0 +1,198 @@
+package paywall
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// switchingOracle is a PriceOracle whose rates can change during a test
+type switchingOracle struct {
+	mu    sync.Mutex
+	rates map[wallet.WalletType]float64
+	err   error
+}
+
+func (o *switchingOracle) FiatPrice(currency wallet.WalletType, fiat string, at time.Time) (float64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err != nil {
+		return 0, o.err
+	}
+	return o.rates[currency], nil
+}
+
+func (o *switchingOracle) set(currency wallet.WalletType, rate float64, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rates[currency] = rate
+	o.err = err
+}
+
+func TestPriceRefresher_Refresh(t *testing.T) {
+	oracle := &switchingOracle{rates: map[wallet.WalletType]float64{wallet.Bitcoin: 50000}}
+	publisher := &recordingPublisher{}
+	pw, err := NewPaywall(Config{
+		PriceInBTC:           0.001,
+		PriceInFiat:          10,
+		PriceOracle:          oracle,
+		PriceRefreshInterval: time.Hour,
+		PaymentTimeout:       time.Hour,
+		TestNet:              true,
+		Store:                NewMemoryStore(),
+		EventSink:            &EventSinkConfig{Publisher: publisher},
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	// The startup refresh replaces the configured fallback price
+	if got := pw.Prices()[wallet.Bitcoin]; got != 0.0002 {
+		t.Fatalf("price after startup = %v, want 0.0002", got)
+	}
+
+	tests := []struct {
+		name string
+		rate float64
+		err  error
+		want float64
+	}{
+		{name: "within threshold", rate: 50400, want: 0.0002},
+		{name: "beyond threshold", rate: 40000, want: 0.00025},
+		{name: "oracle failure keeps price", err: errors.New("rate limited"), want: 0.00025},
+		{name: "dust keeps price", rate: 10000000, want: 0.00025},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oracle.set(wallet.Bitcoin, tt.rate, tt.err)
+			pw.priceRefresher.refresh(false)
+			if got := pw.Prices()[wallet.Bitcoin]; got != tt.want {
+				t.Errorf("price = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if payment.Amounts[wallet.Bitcoin] != 0.00025 {
+		t.Errorf("payment amount = %v, want 0.00025", payment.Amounts[wallet.Bitcoin])
+	}
+
+	pw.Close()
+	var changes int
+	for _, msg := range publisher.messages {
+		if strings.HasSuffix(msg.Subject, string(EventPriceChanged)) {
+			changes++
+		}
+	}
+	if changes != 2 {
+		t.Errorf("%s events = %d, want 2", EventPriceChanged, changes)
+	}
+}
+
+func TestPriceRefresher_Schedule(t *testing.T) {
+	oracle := &switchingOracle{rates: map[wallet.WalletType]float64{wallet.Bitcoin: 50000}}
+	pw, err := NewPaywall(Config{
+		PriceInBTC:           0.001,
+		PriceInFiat:          10,
+		PriceOracle:          oracle,
+		PriceRefreshInterval: 10 * time.Millisecond,
+		PaymentTimeout:       time.Hour,
+		TestNet:              true,
+		Store:                NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	oracle.set(wallet.Bitcoin, 25000, nil)
+	deadline := time.Now().Add(2 * time.Second)
+	for pw.Prices()[wallet.Bitcoin] != 0.0004 {
+		if time.Now().After(deadline) {
+			t.Fatalf("price = %v, want 0.0004 after scheduled refresh", pw.Prices()[wallet.Bitcoin])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPriceRefresher_ExternalMonitor(t *testing.T) {
+	oracle := &switchingOracle{rates: map[wallet.WalletType]float64{wallet.Bitcoin: 50000}}
+	pw, err := NewPaywall(Config{
+		PriceInBTC:           0.001,
+		PriceInFiat:          10,
+		PriceOracle:          oracle,
+		PriceRefreshInterval: time.Hour,
+		PaymentTimeout:       time.Hour,
+		TestNet:              true,
+		Store:                NewFileStore(t.TempDir()),
+		ExternalMonitor:      true,
+		SigningKey:           make([]byte, minSigningKeyLength),
+		BTCWatchKey:          testWatchKey(t),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	amount := func() float64 {
+		t.Helper()
+		payment, err := pw.CreatePayment()
+		if err != nil {
+			t.Fatalf("CreatePayment() error = %v", err)
+		}
+		return payment.Amounts[wallet.Bitcoin]
+	}
+
+	// Within the interval the price fetched in NewPaywall is kept
+	oracle.set(wallet.Bitcoin, 25000, nil)
+	if got := amount(); got != 0.0002 {
+		t.Errorf("amount within interval = %v, want 0.0002", got)
+	}
+
+	// Once the interval has passed, the next payment refreshes the price
+	pw.priceRefresher.refreshed = time.Now().Add(-2 * time.Hour)
+	if got := amount(); got != 0.0004 {
+		t.Errorf("amount after interval = %v, want 0.0004", got)
+	}
+	oracle.set(wallet.Bitcoin, 20000, nil)
+	if got := amount(); got != 0.0004 {
+		t.Errorf("amount right after refresh = %v, want 0.0004", got)
+	}
+}
+
+func TestPriceMoved(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   float64
+		price     float64
+		threshold float64
+		want      bool
+	}{
+		{name: "below threshold", current: 1, price: 1.005, threshold: 1, want: false},
+		{name: "at threshold", current: 2, price: 2.5, threshold: 25, want: false},
+		{name: "above threshold", current: 1, price: 0.98, threshold: 1, want: true},
+		{name: "no current price", current: 0, price: 1, threshold: 1, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := priceMoved(tt.current, tt.price, tt.threshold); got != tt.want {
+				t.Errorf("priceMoved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_PriceInFiat(t *testing.T) {
+	config := Config{PriceInBTC: 0.001, PriceInFiat: 10, PaymentTimeout: time.Hour}
+	if err := validateConfig(&config); err == nil || !strings.Contains(err.Error(), "PriceOracle") {
+		t.Errorf("validateConfig() error = %v, want PriceOracle hint", err)
+	}
+}
@@ -0,0 +1,127 @@
+package paywall
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opd-ai/paywall/metrics"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// paywallMetrics are the Prometheus metrics served by Paywall.MetricsHandler.
+// Labels only name currencies and store operations, never payments or
+// addresses, so the metrics are as anonymous as Config.AnonymousLogs logs.
+// A nil *paywallMetrics records nothing.
+type paywallMetrics struct {
+	registry *metrics.Registry
+
+	paymentsCreated   *metrics.Counter
+	paymentsConfirmed *metrics.Counter
+	paymentsExpired   *metrics.Counter
+	revenue           *metrics.Counter
+
+	monitorCheckDuration *metrics.Histogram
+	monitorPayments      *metrics.Gauge
+	chainRequests        *metrics.Counter
+	chainErrors          *metrics.Counter
+
+	storeDuration *metrics.Histogram
+	storeErrors   *metrics.Counter
+}
+
+// newPaywallMetrics registers the paywall's metrics in a new registry
+func newPaywallMetrics() *paywallMetrics {
+	registry := metrics.NewRegistry()
+	return &paywallMetrics{
+		registry: registry,
+
+		paymentsCreated:   registry.NewCounter("paywall_payments_created_total", "Payments created."),
+		paymentsConfirmed: registry.NewCounter("paywall_payments_confirmed_total", "Payments confirmed, by the currency they were paid in.", "currency"),
+		paymentsExpired:   registry.NewCounter("paywall_payments_expired_total", "Payments that expired unpaid."),
+		revenue:           registry.NewCounter("paywall_revenue_total", "Amount received by confirmed payments, in whole coins.", "currency"),
+
+		monitorCheckDuration: registry.NewHistogram("paywall_monitor_check_duration_seconds", "Time the monitor took to check one payment on every chain.", metrics.DefBuckets),
+		monitorPayments:      registry.NewGauge("paywall_monitor_payments", "Payments watched in the last monitor cycle."),
+		chainRequests:        registry.NewCounter("paywall_chain_requests_total", "Balance and confirmation checks sent to chain backends.", "currency"),
+		chainErrors:          registry.NewCounter("paywall_chain_errors_total", "Balance and confirmation checks that failed.", "currency"),
+
+		storeDuration: registry.NewHistogram("paywall_store_operation_duration_seconds", "Payment store call durations.", metrics.DefBuckets, "operation"),
+		storeErrors:   registry.NewCounter("paywall_store_errors_total", "Payment store calls that failed.", "operation"),
+	}
+}
+
+// observeEvent counts payment events and revenue
+func (m *paywallMetrics) observeEvent(payload WebhookPayload) {
+	if m == nil {
+		return
+	}
+	switch payload.Event {
+	case EventPaymentCreated:
+		m.paymentsCreated.Inc()
+	case EventPaymentExpired:
+		m.paymentsExpired.Inc()
+	case EventPaymentConfirmed:
+		currency, _ := payload.Data["currency"].(wallet.WalletType)
+		m.paymentsConfirmed.Inc(string(currency))
+		if amount, ok := payload.Data["amount"].(float64); ok && amount > 0 {
+			m.revenue.Add(amount, string(currency))
+		}
+	}
+}
+
+// observeChainCheck records a chain backend check of one currency
+func (m *paywallMetrics) observeChainCheck(currency wallet.WalletType, err error) {
+	if m == nil {
+		return
+	}
+	m.chainRequests.Inc(string(currency))
+	if err != nil {
+		m.chainErrors.Inc(string(currency))
+	}
+}
+
+// observeMonitorCheck records how long a payment check took
+func (m *paywallMetrics) observeMonitorCheck(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.monitorCheckDuration.Observe(d.Seconds())
+}
+
+// setMonitorPayments records the size of a monitor cycle
+func (m *paywallMetrics) setMonitorPayments(n int) {
+	if m == nil {
+		return
+	}
+	m.monitorPayments.Set(float64(n))
+}
+
+// observeStore records a store call. op is a fixed operation name such as
+// "get payment"; spaces become underscores in the label.
+func (m *paywallMetrics) observeStore(op string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	label := strings.ReplaceAll(op, " ", "_")
+	m.storeDuration.Observe(d.Seconds(), label)
+	if err != nil {
+		m.storeErrors.Inc(label)
+	}
+}
+
+// MetricsHandler serves the paywall's metrics in the Prometheus text format:
+// payments created, confirmed and expired, revenue per currency, monitor
+// check latency, chain backend error rates and store call durations. Mount
+// it at /metrics, behind authentication or on an internal listener if the
+// payment volume is not public.
+//
+// Returns:
+//   - http.Handler: The metrics endpoint
+func (p *Paywall) MetricsHandler() http.Handler {
+	if p.metrics == nil {
+		// Paywalls not built by NewPaywall have nothing to report
+		return newPaywallMetrics().registry.Handler()
+	}
+	return p.metrics.registry.Handler()
+}
@@ -0,0 +1,66 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	pw := newTestPaywall(t, Config{})
+	paid, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	payAndConfirm(t, pw, paid, 0.001)
+
+	unpaid, _ := pw.CreatePayment()
+	stored, _ := pw.Store.GetPayment(unpaid.ID)
+	stored.ExpiresAt = time.Now().Add(-time.Hour)
+	pw.Store.UpdatePayment(stored)
+	if expired, err := (&CryptoChainMonitor{paywall: pw}).sweepExpired(time.Now()); err != nil || expired != 1 {
+		t.Fatalf("sweepExpired() = %d, %v, want 1", expired, err)
+	}
+
+	failing := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin: &mockCryptoClient{err: errors.New("node unreachable")},
+	}}
+	third, _ := pw.CreatePayment()
+	failing.checkPayment(third)
+
+	rec := httptest.NewRecorder()
+	pw.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"paywall_payments_created_total 3\n",
+		`paywall_payments_confirmed_total{currency="BTC"} 1` + "\n",
+		"paywall_payments_expired_total 1\n",
+		`paywall_revenue_total{currency="BTC"} 0.001` + "\n",
+		"paywall_monitor_check_duration_seconds_count 2\n",
+		`paywall_chain_requests_total{currency="BTC"} 2` + "\n",
+		`paywall_chain_errors_total{currency="BTC"} 1` + "\n",
+		`paywall_store_operation_duration_seconds_count{operation="create_payment"} 3` + "\n",
+		`paywall_store_operation_duration_seconds_count{operation="update_payment"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	for _, id := range []string{paid.ID, unpaid.ID, third.ID} {
+		if strings.Contains(body, id) {
+			t.Errorf("metrics expose payment ID %s", id)
+		}
+	}
+
+	// Paywalls not built by NewPaywall serve empty metrics
+	rec = httptest.NewRecorder()
+	(&Paywall{}).MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "# TYPE paywall_payments_created_total counter") {
+		t.Errorf("MetricsHandler() of a bare Paywall = %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,224 @@
+package paywall
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedElement is a single hop from an RFC 7239 Forwarded header
+type forwardedElement struct {
+	For   string
+	Proto string
+	Host  string
+}
+
+// parseTrustedProxies converts Config.TrustedProxies entries into networks.
+// Entries may be single IP addresses ("10.0.0.1", "::1") or CIDR ranges ("10.0.0.0/8").
+//
+// Returns:
+//   - []*net.IPNet: Parsed networks (single IPs become /32 or /128 networks)
+//   - error: If any entry is neither an IP address nor a CIDR range
+func parseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", entry, err)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy address %q (hint: use an IP such as 10.0.0.1 or a CIDR such as 10.0.0.0/8)", entry)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// isTrustedProxy reports whether the given address (with or without port) belongs
+// to one of the configured trusted proxy networks
+func (p *Paywall) isTrustedProxy(addr string) bool {
+	ip := parseHostIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range p.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostIP extracts the IP from "ip", "ip:port", "[ipv6]:port" or quoted
+// Forwarded node values. Returns nil for obfuscated or unknown identifiers.
+func parseHostIP(addr string) net.IP {
+	addr = strings.Trim(strings.TrimSpace(addr), `"`)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	return net.ParseIP(addr)
+}
+
+// parseForwarded parses an RFC 7239 Forwarded header into its hop elements,
+// ordered from the original client (first) to the nearest proxy (last).
+// Multiple Forwarded header lines are treated as one comma-separated list.
+func parseForwarded(values []string) []forwardedElement {
+	var elements []forwardedElement
+	for _, value := range values {
+		for _, rawElement := range splitQuoted(value, ',') {
+			var element forwardedElement
+			for _, pair := range splitQuoted(rawElement, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(strings.TrimSpace(val), `"`)
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					element.For = val
+				case "proto":
+					element.Proto = strings.ToLower(val)
+				case "host":
+					element.Host = val
+				}
+			}
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// splitQuoted splits s on sep, ignoring separators inside double quotes
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case r == sep && !inQuotes:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// splitHeaderList splits a comma-separated header (possibly repeated) into trimmed values
+func splitHeaderList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// isSecureRequest reports whether the client connection to the site used HTTPS.
+//
+// Without Config.TrustedProxies the legacy behavior applies: TLS on the direct
+// connection or an "X-Forwarded-Proto: https" header marks the request secure.
+// With TrustedProxies configured, forwarding headers are only honored when the
+// direct peer is a trusted proxy, which prevents clients from spoofing them:
+//  1. The RFC 7239 Forwarded header is walked from the nearest hop towards the
+//     client, skipping hops added by trusted proxies; the proto of the first
+//     untrusted hop wins.
+//  2. Otherwise the last X-Forwarded-Proto value (set by the nearest proxy) is used.
+//
+// Related: Config.TrustedProxies, clientIP
+func (p *Paywall) isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	if len(p.trustedProxies) == 0 {
+		return r.Header.Get("X-Forwarded-Proto") == "https"
+	}
+
+	if !p.isTrustedProxy(r.RemoteAddr) {
+		return false
+	}
+
+	if elements := parseForwarded(r.Header.Values("Forwarded")); len(elements) > 0 {
+		proto := ""
+		for i := len(elements) - 1; i >= 0; i-- {
+			if elements[i].Proto != "" {
+				proto = elements[i].Proto
+			}
+			if !p.isTrustedProxy(elements[i].For) {
+				break
+			}
+		}
+		if proto != "" {
+			return proto == "https"
+		}
+	}
+
+	if protos := splitHeaderList(r.Header.Values("X-Forwarded-Proto")); len(protos) > 0 {
+		return strings.EqualFold(protos[len(protos)-1], "https")
+	}
+	return false
+}
+
+// clientIP returns the best-effort IP address of the end client.
+//
+// The direct peer address is used unless it is a trusted proxy, in which case the
+// Forwarded (preferred) or X-Forwarded-For chain is walked from the nearest hop
+// and the first address not belonging to a trusted proxy is returned.
+// Forwarding headers are never consulted when TrustedProxies is empty.
+func (p *Paywall) clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if len(p.trustedProxies) == 0 || !p.isTrustedProxy(remote) {
+		return remote
+	}
+
+	var chain []string
+	if elements := parseForwarded(r.Header.Values("Forwarded")); len(elements) > 0 {
+		for _, element := range elements {
+			chain = append(chain, element.For)
+		}
+	} else {
+		chain = splitHeaderList(r.Header.Values("X-Forwarded-For"))
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseHostIP(chain[i])
+		if ip == nil {
+			// Obfuscated or unknown identifier: stop at the last known hop
+			break
+		}
+		if !p.isTrustedProxy(ip.String()) {
+			return ip.String()
+		}
+		remote = ip.String()
+	}
+	return remote
+}
@@ -0,0 +1,150 @@
+package paywall
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantLen int
+		wantErr bool
+	}{
+		{"empty", nil, 0, false},
+		{"single IPv4", []string{"10.0.0.1"}, 1, false},
+		{"single IPv6", []string{"::1"}, 1, false},
+		{"CIDR ranges", []string{"10.0.0.0/8", "2001:db8::/32"}, 2, false},
+		{"blank entries skipped", []string{" ", "192.168.1.1"}, 1, false},
+		{"invalid IP", []string{"proxy.example.com"}, 0, true},
+		{"invalid CIDR", []string{"10.0.0.0/99"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := parseTrustedProxies(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrustedProxies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(nets) != tt.wantLen {
+				t.Errorf("parseTrustedProxies() len = %d, want %d", len(nets), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	elements := parseForwarded([]string{
+		`for=192.0.2.60;proto=http;by=203.0.113.43`,
+		`For="[2001:db8:cafe::17]:4711";Proto=HTTPS, for=10.0.0.2;host="example.com"`,
+	})
+	if len(elements) != 3 {
+		t.Fatalf("parseForwarded() returned %d elements, want 3", len(elements))
+	}
+	if elements[0].For != "192.0.2.60" || elements[0].Proto != "http" {
+		t.Errorf("element 0 = %+v", elements[0])
+	}
+	if elements[1].For != "[2001:db8:cafe::17]:4711" || elements[1].Proto != "https" {
+		t.Errorf("element 1 = %+v", elements[1])
+	}
+	if elements[2].Host != "example.com" {
+		t.Errorf("element 2 = %+v", elements[2])
+	}
+	if ip := parseHostIP(elements[1].For); ip == nil || ip.String() != "2001:db8:cafe::17" {
+		t.Errorf("parseHostIP(%q) = %v", elements[1].For, ip)
+	}
+}
+
+func TestPaywall_isSecureRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		tls        bool
+		headers    map[string][]string
+		want       bool
+	}{
+		{"direct TLS", []string{"10.0.0.0/8"}, "198.51.100.1:1234", true, nil, true},
+		{"legacy XFP honored without trusted proxies", nil, "198.51.100.1:1234", false, map[string][]string{"X-Forwarded-Proto": {"https"}}, true},
+		{"legacy plain HTTP", nil, "198.51.100.1:1234", false, nil, false},
+		{"spoofed XFP from untrusted peer", []string{"10.0.0.0/8"}, "198.51.100.1:1234", false, map[string][]string{"X-Forwarded-Proto": {"https"}}, false},
+		{"XFP from trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, map[string][]string{"X-Forwarded-Proto": {"https"}}, true},
+		{"last XFP value wins", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, map[string][]string{"X-Forwarded-Proto": {"https, http"}}, false},
+		{"Forwarded from trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, map[string][]string{"Forwarded": {"for=203.0.113.9;proto=https"}}, true},
+		{"Forwarded preferred over XFP", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, map[string][]string{"Forwarded": {"for=203.0.113.9;proto=http"}, "X-Forwarded-Proto": {"https"}}, false},
+		{"Forwarded chain skips trusted hops", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, map[string][]string{"Forwarded": {"for=203.0.113.9;proto=https, for=10.9.9.9;proto=http"}}, true},
+		{"trusted proxy without headers", []string{"10.0.0.0/8"}, "10.1.2.3:443", false, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := parseTrustedProxies(tt.trusted)
+			if err != nil {
+				t.Fatalf("parseTrustedProxies() error = %v", err)
+			}
+			p := &Paywall{trustedProxies: nets}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.headers {
+				r.Header[k] = v
+			}
+			if got := p.isSecureRequest(r); got != tt.want {
+				t.Errorf("isSecureRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaywall_clientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{"no trusted proxies ignores XFF", nil, "198.51.100.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4"}}, "198.51.100.1"},
+		{"untrusted peer ignores XFF", []string{"10.0.0.0/8"}, "198.51.100.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4"}}, "198.51.100.1"},
+		{"XFF through trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.1:80", map[string][]string{"X-Forwarded-For": {"1.2.3.4, 5.6.7.8, 10.0.0.7"}}, "5.6.7.8"},
+		{"Forwarded through trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.1:80", map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"obfuscated hop stops walk", []string{"10.0.0.0/8"}, "10.0.0.1:80", map[string][]string{"Forwarded": {"for=_hidden"}}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := parseTrustedProxies(tt.trusted)
+			if err != nil {
+				t.Fatalf("parseTrustedProxies() error = %v", err)
+			}
+			p := &Paywall{trustedProxies: nets}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header[k] = v
+			}
+			if got := p.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_TrustedProxies(t *testing.T) {
+	config := &Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: 1,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		TrustedProxies: []string{"not-an-ip"},
+	}
+	if err := validateConfig(config); err == nil {
+		t.Error("validateConfig() should reject invalid TrustedProxies")
+	}
+	config.TrustedProxies = []string{"173.245.48.0/20"}
+	if err := validateConfig(config); err != nil {
+		t.Errorf("validateConfig() error = %v", err)
+	}
+}
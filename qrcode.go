@@ -0,0 +1,173 @@
+package paywall
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opd-ai/paywall/wallet"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrCodeSize is the edge length in pixels of server-rendered QR code images
+const qrCodeSize = 256
+
+// paymentURI builds the wallet URI encoded into QR codes and payment links,
+// so that wallets fill in the amount: BIP21 for Bitcoin and its forks, the
+// Monero URI scheme, BOLT11 and EIP-681
+//
+// Parameters:
+//   - currency: Currency selecting the URI scheme
+//   - address: Payment address, or the invoice for Lightning
+//   - amount: Amount in the currency's main unit
+//   - label: Recipient shown by the wallet (Config.PaymentLabel); "" omits it.
+//     Lightning invoices and EIP-681 have no such field.
+//
+// Returns:
+//   - string: e.g. "bitcoin:<address>?amount=0.001&label=Example%20News" or
+//     "monero:<address>?tx_amount=0.05"
+//   - error: If the currency has no URI scheme
+func paymentURI(currency wallet.WalletType, address string, amount float64, label string) (string, error) {
+	amountStr := strconv.FormatFloat(amount, 'f', -1, 64)
+	switch currency {
+	case wallet.Bitcoin:
+		return bip21URI("bitcoin:"+address, amountStr, label), nil
+	case wallet.Litecoin:
+		return bip21URI("litecoin:"+address, amountStr, label), nil
+	case wallet.Dogecoin:
+		return bip21URI("dogecoin:"+address, amountStr, label), nil
+	case wallet.BitcoinCash:
+		// Cash addresses carry their scheme, bitcoincash: or bchtest:
+		if !strings.Contains(address, ":") {
+			address = "bitcoincash:" + address
+		}
+		return bip21URI(address, amountStr, label), nil
+	case wallet.Monero:
+		uri := "monero:" + address + "?tx_amount=" + amountStr
+		if label != "" {
+			uri += "&recipient_name=" + uriEscape(label)
+		}
+		return uri, nil
+	case wallet.Lightning:
+		// The invoice carries the amount
+		return "lightning:" + address, nil
+	case wallet.Ethereum:
+		// EIP-681 takes the amount in wei; the exponent avoids float rounding
+		return "ethereum:" + address + "?value=" + amountStr + "e18", nil
+	default:
+		return "", fmt.Errorf("no payment URI scheme for %s", currency)
+	}
+}
+
+// bip21URI appends the BIP21 amount and label to target, "<scheme>:<address>"
+func bip21URI(target, amount, label string) string {
+	uri := target + "?amount=" + amount
+	if label != "" {
+		uri += "&label=" + uriEscape(label)
+	}
+	return uri
+}
+
+// uriEscape percent-encodes a payment URI parameter. Spaces become %20
+// rather than "+", which BIP21 and several wallets read literally.
+func uriEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// qrCodePNG renders a payment URI as a PNG QR code
+func qrCodePNG(currency wallet.WalletType, address string, amount float64, label string) ([]byte, error) {
+	uri, err := paymentURI(currency, address, amount, label)
+	if err != nil {
+		return nil, err
+	}
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("encode QR code: %w", err)
+	}
+	return png, nil
+}
+
+// qrCodeDataURI renders a payment QR code as an inline data: URI for <img src>.
+// Returns "" when the address is empty or the code cannot be rendered.
+func qrCodeDataURI(currency wallet.WalletType, address string, amount float64, label string) template.URL {
+	if address == "" {
+		return ""
+	}
+	png, err := qrCodePNG(currency, address, amount, label)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+}
+
+// qrCodeURL returns a signed link to HandleQRCode for the given payment address.
+// The payment ID is deliberately not part of the URL since it is the access credential,
+// and the label is added by HandleQRCode from the configuration.
+func (p *Paywall) qrCodeURL(currency wallet.WalletType, address string, amount float64) template.URL {
+	if address == "" {
+		return ""
+	}
+	amountStr := strconv.FormatFloat(amount, 'f', -1, 64)
+	query := url.Values{
+		"c": {string(currency)},
+		"a": {address},
+		"v": {amountStr},
+		"s": {p.signer.sign("qr/v1", string(currency), address, amountStr)},
+	}
+	return template.URL(p.qrCodePath + "?" + query.Encode())
+}
+
+// HandleQRCode serves server-rendered payment QR codes as PNG images.
+// The payment page falls back to these images when the QR script cannot run
+// (JavaScript disabled or blocked by a Content-Security-Policy).
+//
+// Mount it at Config.QRCodePath, e.g. http.HandleFunc("/paywall/qr", pw.HandleQRCode).
+// Only links generated by the payment page are accepted: the currency, address
+// and amount are signed, so the endpoint cannot be used as an open QR generator.
+//
+// Responses:
+//   - 200 with image/png on success
+//   - 403 Forbidden if the signature is missing or invalid
+//   - 405 Method Not Allowed for non-GET requests
+//   - 429 Too Many Requests (with Retry-After) beyond Config.RateLimits
+func (p *Paywall) HandleQRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitStatus) {
+		return
+	}
+
+	q := r.URL.Query()
+	currency, address, amountStr := q.Get("c"), q.Get("a"), q.Get("v")
+	if !p.signer.verify(q.Get("s"), "qr/v1", currency, address, amountStr) {
+		http.Error(w, "Invalid QR code link", http.StatusForbidden)
+		return
+	}
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+
+	png, err := qrCodePNG(wallet.WalletType(currency), address, amount, p.paymentLabel)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:    LogLevelError,
+			Event:    "qrcode_render_failed",
+			Message:  fmt.Sprintf("Failed to render QR code image: %v", err),
+			Currency: wallet.WalletType(currency),
+		})
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(png)
+}
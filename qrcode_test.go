@@ -0,0 +1,160 @@
+package paywall
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestPaymentURI(t *testing.T) {
+	tests := []struct {
+		currency wallet.WalletType
+		label    string
+		want     string
+		wantErr  bool
+	}{
+		{wallet.Bitcoin, "", "bitcoin:addr?amount=0.0001", false},
+		{wallet.Bitcoin, "Example News & Co", "bitcoin:addr?amount=0.0001&label=Example%20News%20%26%20Co", false},
+		{wallet.Monero, "", "monero:addr?tx_amount=0.0001", false},
+		{wallet.Monero, "Example News", "monero:addr?tx_amount=0.0001&recipient_name=Example%20News", false},
+		{wallet.Litecoin, "Café", "litecoin:addr?amount=0.0001&label=Caf%C3%A9", false},
+		{wallet.Dogecoin, "", "dogecoin:addr?amount=0.0001", false},
+		{wallet.BitcoinCash, "", "bitcoincash:addr?amount=0.0001", false},
+		{wallet.BitcoinCash, "a+b", "bitcoincash:addr?amount=0.0001&label=a%2Bb", false},
+		{wallet.Lightning, "Example News", "lightning:addr", false},
+		{wallet.Ethereum, "Example News", "ethereum:addr?value=0.0001e18", false},
+		{wallet.USDC, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.currency)+"/"+tt.label, func(t *testing.T) {
+			got, err := paymentURI(tt.currency, "addr", 0.0001, tt.label)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("paymentURI() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentURI_SmallAmounts(t *testing.T) {
+	// Wallets reject exponents, which %v produces for amounts below 1e-4
+	got, err := paymentURI(wallet.Bitcoin, "addr", 0.00001, "")
+	if err != nil || got != "bitcoin:addr?amount=0.00001" {
+		t.Errorf("paymentURI() = %q, %v; want bitcoin:addr?amount=0.00001", got, err)
+	}
+}
+
+func TestNewPaymentPageData_ServerQRCodes(t *testing.T) {
+	data, err := NewPaymentPageData(createHandlerTestPayment())
+	if err != nil {
+		t.Fatalf("NewPaymentPageData() error = %v", err)
+	}
+	for name, uri := range map[string]template.URL{"BTC": data.BTCQRCode, "XMR": data.XMRQRCode} {
+		if !strings.HasPrefix(string(uri), "data:image/png;base64,") {
+			t.Errorf("%s QR code = %.40q, want PNG data URI", name, uri)
+		}
+	}
+	if data.QRScriptUnavailable {
+		t.Error("QRScriptUnavailable = true with embedded script present")
+	}
+}
+
+func TestRenderPaymentPage_NoScriptFallback(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	payment := createHandlerTestPayment()
+
+	t.Run("InlineImages", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		pw.renderPaymentPage(rec, payment)
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		for _, want := range []string{`<img src="data:image/png;base64,`, `class="address copy"`, "<noscript>", "typeof qrcode === 'function'"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("payment page missing %q", want)
+			}
+		}
+	})
+
+	t.Run("EndpointImages", func(t *testing.T) {
+		pw.qrCodePath = "/paywall/qr"
+		defer func() { pw.qrCodePath = "" }()
+
+		rec := httptest.NewRecorder()
+		pw.renderPaymentPage(rec, payment)
+		body := rec.Body.String()
+		if !strings.Contains(body, `<img src="/paywall/qr?`) {
+			t.Error("payment page should link QR images to the QR endpoint")
+		}
+		if strings.Contains(body, "data:image/png") {
+			t.Error("payment page should not inline QR images when QRCodePath is set")
+		}
+		start := strings.Index(body, "/paywall/qr?")
+		link := body[start : start+strings.Index(body[start:], `"`)]
+		if strings.Contains(link, payment.ID) {
+			t.Error("QR image link must not contain the payment ID")
+		}
+	})
+}
+
+func TestRenderPaymentPage_PaymentLabel(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	pw.paymentLabel = "Example News"
+	payment := createHandlerTestPayment()
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPage(rec, payment)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`href="bitcoin:1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa?amount=0.001&amp;label=Example%20News"`,
+		`href="monero:49gCuLWHMxCSDSDKKKSDK5QGefi2DMPTfTL5SLmv7DivfNa?tx_amount=0.01&amp;recipient_name=Example%20News"`,
+		// The QR script encodes the same URIs, escaped for a JavaScript string
+		`var bqrData = 'bitcoin:1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa?amount=0.001\u0026label=Example%20News'`,
+		`var xqrData = 'monero:49gCuLWHMxCSDSDKKKSDK5QGefi2DMPTfTL5SLmv7DivfNa?tx_amount=0.01\u0026recipient_name=Example%20News'`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("payment page missing %s", want)
+		}
+	}
+}
+
+func TestHandleQRCode(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	pw.qrCodePath = "/paywall/qr"
+	link := string(pw.qrCodeURL(wallet.Bitcoin, "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", 0.001))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"Valid", http.MethodGet, link, http.StatusOK},
+		{"TamperedAddress", http.MethodGet, strings.Replace(link, "1A1zP1", "1B1zP1", 1), http.StatusForbidden},
+		{"TamperedAmount", http.MethodGet, strings.Replace(link, "v=0.001", "v=0.0001", 1), http.StatusForbidden},
+		{"Unsigned", http.MethodGet, "/paywall/qr?c=BTC&a=x&v=1", http.StatusForbidden},
+		{"WrongMethod", http.MethodPost, link, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			pw.HandleQRCode(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+					t.Errorf("Content-Type = %q, want image/png", ct)
+				}
+				if !bytes.HasPrefix(rec.Body.Bytes(), []byte("\x89PNG")) {
+					t.Error("response is not a PNG image")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryTokenParam is the query parameter carrying a signed access token for
+// cookie-less clients (RSS readers, podcast apps, download managers)
+const QueryTokenParam = "pw_token"
+
+// queryTokenPurpose binds query token signatures to this token type
+const queryTokenPurpose = "pw_token/v1"
+
+// defaultQueryTokenTTL is used when Config.QueryTokenTTL is zero
+const defaultQueryTokenTTL = time.Hour
+
+var (
+	// ErrQueryTokensDisabled is returned when issuing a token while Config.QueryTokenEnabled is false
+	ErrQueryTokensDisabled = errors.New("query tokens are disabled (hint: set Config.QueryTokenEnabled)")
+	// ErrInvalidQueryToken is returned for malformed, tampered, expired or wrong-path tokens
+	ErrInvalidQueryToken = errors.New("invalid query token")
+)
+
+// IssueQueryToken creates a signed pw_token granting access to a single path
+// on behalf of a confirmed payment.
+//
+// Parameters:
+//   - paymentID: ID of a confirmed, unexpired payment
+//   - path: URL path the token is valid for (e.g. "/feeds/premium.xml")
+//
+// Returns:
+//   - string: Token to append as ?pw_token=<token>
+//   - error: If query tokens are disabled or the payment does not grant access
+//
+// The token expires after Config.QueryTokenTTL or when the payment expires,
+// whichever comes first.
+//
+// Related: QueryTokenForRequest, Config.QueryTokenEnabled
+func (p *Paywall) IssueQueryToken(paymentID, path string) (string, error) {
+	if !p.queryTokenEnabled {
+		return "", ErrQueryTokensDisabled
+	}
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return "", fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return "", fmt.Errorf("payment %s not found", paymentID)
+	}
+	now := time.Now()
+	if !payment.GrantsAccess(now) {
+		return "", fmt.Errorf("payment %s does not grant access (status: %s)", paymentID, payment.Status)
+	}
+
+	expires := now.Add(p.queryTokenTTL)
+	if payment.AccessEnds().Before(expires) {
+		expires = payment.AccessEnds()
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	sig := p.signer.sign(queryTokenPurpose, payment.ID, path, exp)
+	return payment.ID + "." + exp + "." + sig, nil
+}
+
+// QueryTokenForRequest issues a query token for the payment attached to the
+// request's payment cookie. Use it from a handler protected by Middleware to
+// render personal feed URLs for cookie-less clients.
+//
+// Parameters:
+//   - r: Request carrying a payment_id or __Host-payment_id cookie
+//   - path: URL path the token is valid for
+//
+// Returns:
+//   - string: Token to append as ?pw_token=<token>
+//   - error: If there is no payment cookie or IssueQueryToken fails
+func (p *Paywall) QueryTokenForRequest(r *http.Request, path string) (string, error) {
+	paymentID, err := paymentIDFromCookie(r)
+	if err != nil {
+		return "", err
+	}
+	return p.IssueQueryToken(paymentID, path)
+}
+
+// paymentIDFromCookie returns the payment ID from the __Host-payment_id or payment_id cookie
+func paymentIDFromCookie(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("__Host-payment_id")
+	if err != nil {
+		cookie, err = r.Cookie("payment_id")
+	}
+	if err != nil {
+		return "", fmt.Errorf("no payment cookie: %w", err)
+	}
+	return cookie.Value, nil
+}
+
+// verifyQueryToken checks the token signature, expiry and path binding and
+// returns the payment it grants access for
+func (p *Paywall) verifyQueryToken(token, path string) (*Payment, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidQueryToken
+	}
+	paymentID, exp, sig := parts[0], parts[1], parts[2]
+	if !p.signer.verify(sig, queryTokenPurpose, paymentID, path, exp) {
+		return nil, ErrInvalidQueryToken
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !time.Now().Before(time.Unix(expUnix, 0)) {
+		return nil, ErrInvalidQueryToken
+	}
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil || payment == nil {
+		return nil, ErrInvalidQueryToken
+	}
+	// Re-check the payment so revoked or expired payments stop granting access
+	if !payment.GrantsAccess(time.Now()) {
+		return nil, ErrInvalidQueryToken
+	}
+	return payment, nil
+}
+
+// withoutQueryToken returns a shallow copy of r with the pw_token parameter
+// removed so protected handlers and their access logs never see it
+func withoutQueryToken(r *http.Request) *http.Request {
+	r2 := r.Clone(r.Context())
+	query := r2.URL.Query()
+	query.Del(QueryTokenParam)
+	r2.URL.RawQuery = query.Encode()
+	r2.RequestURI = r2.URL.RequestURI()
+	return r2
+}
@@ -0,0 +1,140 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func confirmedTestPayment(t *testing.T, pw *Paywall) *Payment {
+	t.Helper()
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	payment.Status = StatusConfirmed
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+	return payment
+}
+
+func TestTokenSigner(t *testing.T) {
+	if _, err := newTokenSigner([]byte("short"), nil); err == nil {
+		t.Error("newTokenSigner() should reject short keys")
+	}
+	s, err := newTokenSigner(nil, nil)
+	if err != nil {
+		t.Fatalf("newTokenSigner(nil, nil) error = %v", err)
+	}
+	sig := s.sign("purpose", "ab", "c")
+	if !s.verify(sig, "purpose", "ab", "c") {
+		t.Error("verify() rejected a valid signature")
+	}
+	if s.verify(sig, "purpose", "a", "bc") {
+		t.Error("verify() accepted a signature for shifted fields")
+	}
+	if s.verify(sig, "other", "ab", "c") {
+		t.Error("verify() accepted a signature for another purpose")
+	}
+}
+
+func TestIssueQueryToken(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, err := pw.IssueQueryToken(pending.ID, "/feed.xml"); err == nil {
+		t.Error("IssueQueryToken() should refuse pending payments")
+	}
+	if _, err := pw.IssueQueryToken("missing", "/feed.xml"); err == nil {
+		t.Error("IssueQueryToken() should refuse unknown payments")
+	}
+
+	confirmed := confirmedTestPayment(t, pw)
+	token, err := pw.IssueQueryToken(confirmed.ID, "/feed.xml")
+	if err != nil {
+		t.Fatalf("IssueQueryToken() error = %v", err)
+	}
+	if _, err := pw.verifyQueryToken(token, "/feed.xml"); err != nil {
+		t.Errorf("verifyQueryToken() error = %v", err)
+	}
+
+	pw.queryTokenEnabled = false
+	if _, err := pw.IssueQueryToken(confirmed.ID, "/feed.xml"); err != ErrQueryTokensDisabled {
+		t.Errorf("IssueQueryToken() error = %v, want ErrQueryTokensDisabled", err)
+	}
+}
+
+func TestMiddleware_QueryToken(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	payment := confirmedTestPayment(t, pw)
+	token, err := pw.IssueQueryToken(payment.ID, "/feed.xml")
+	if err != nil {
+		t.Fatalf("IssueQueryToken() error = %v", err)
+	}
+
+	var seenQuery string
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenQuery = r.URL.RawQuery
+		w.Write([]byte("protected"))
+	}))
+
+	tests := []struct {
+		name        string
+		target      string
+		wantContent bool
+	}{
+		{"valid token", "/feed.xml?page=2&pw_token=" + token, true},
+		{"wrong path", "/other.xml?pw_token=" + token, false},
+		{"tampered token", "/feed.xml?pw_token=" + strings.Replace(token, ".", ".9", 1), false},
+		{"garbage token", "/feed.xml?pw_token=abc", false},
+		{"no token", "/feed.xml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seenQuery = ""
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			got := rec.Body.String() == "protected"
+			if got != tt.wantContent {
+				t.Fatalf("access = %v, want %v (status %d)", got, tt.wantContent, rec.Code)
+			}
+			if got && seenQuery != "page=2" {
+				t.Errorf("next handler saw query %q, want pw_token stripped", seenQuery)
+			}
+		})
+	}
+
+	// Tokens stop working once the payment no longer grants access
+	payment.Status = StatusExpired
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/feed.xml?pw_token="+token, nil))
+	if rec.Body.String() == "protected" {
+		t.Error("token still granted access after payment expired")
+	}
+}
+
+func TestQueryTokenForRequest(t *testing.T) {
+	pw := newTestPaywall(t, Config{SigningKey: testSigningKey, QueryTokenEnabled: true})
+	payment := confirmedTestPayment(t, pw)
+
+	r := httptest.NewRequest(http.MethodGet, "/account", nil)
+	if _, err := pw.QueryTokenForRequest(r, "/feed.xml"); err == nil {
+		t.Error("QueryTokenForRequest() should fail without a payment cookie")
+	}
+	r.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+	token, err := pw.QueryTokenForRequest(r, "/feed.xml")
+	if err != nil {
+		t.Fatalf("QueryTokenForRequest() error = %v", err)
+	}
+	if !strings.HasPrefix(token, payment.ID+".") {
+		t.Errorf("token %q not issued for payment %s", token, payment.ID)
+	}
+}
@@ -0,0 +1,64 @@
+package paywall
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestNewDeterministicRand(t *testing.T) {
+	read := func(r io.Reader, sizes ...int) []byte {
+		var out []byte
+		for _, n := range sizes {
+			b := make([]byte, n)
+			if _, err := io.ReadFull(r, b); err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			out = append(out, b...)
+		}
+		return out
+	}
+
+	a := read(NewDeterministicRand([]byte("scenario")), 100)
+	// Read sizes must not change the stream
+	b := read(NewDeterministicRand([]byte("scenario")), 7, 32, 61)
+	if !bytes.Equal(a, b) {
+		t.Error("same seed produced different streams")
+	}
+	c := read(NewDeterministicRand([]byte("other")), 100)
+	if bytes.Equal(a, c) {
+		t.Error("different seeds produced the same stream")
+	}
+}
+
+func TestConfigRand_ReproduciblePayments(t *testing.T) {
+	newPayment := func() *Payment {
+		pw, err := NewPaywall(Config{
+			PriceInBTC:     0.001,
+			PaymentTimeout: time.Hour,
+			TestNet:        true,
+			Store:          NewMemoryStore(),
+			Rand:           NewDeterministicRand([]byte(t.Name())),
+		})
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		defer pw.Close()
+		payment, err := pw.CreatePayment()
+		if err != nil {
+			t.Fatalf("CreatePayment() error = %v", err)
+		}
+		return payment
+	}
+
+	first, second := newPayment(), newPayment()
+	if first.ID != second.ID {
+		t.Errorf("payment IDs differ: %s and %s", first.ID, second.ID)
+	}
+	if first.Addresses[wallet.Bitcoin] != second.Addresses[wallet.Bitcoin] {
+		t.Errorf("addresses differ: %s and %s", first.Addresses[wallet.Bitcoin], second.Addresses[wallet.Bitcoin])
+	}
+}
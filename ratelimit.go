@@ -0,0 +1,196 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitClass groups the paywall endpoints that share a rate limit
+type RateLimitClass string
+
+const (
+	// RateLimitCreate limits payment creation by Middleware, the most
+	// expensive request a visitor can trigger (address derivation and a
+	// store write)
+	RateLimitCreate RateLimitClass = "create"
+	// RateLimitStatus limits HandlePaymentStatus and HandleQRCode
+	RateLimitStatus RateLimitClass = "status"
+	// RateLimitSubmit limits the inbound submission endpoints:
+	// HandleBitcoinTransaction, HandleMoneroProof, HandleTestnetFaucet and
+	// HandleRefundAddress
+	RateLimitSubmit RateLimitClass = "submit"
+)
+
+// RateLimit is a token bucket per client IP: a client may send Requests
+// requests at once, and the bucket refills at Requests per Interval.
+// The zero RateLimit disables limiting.
+type RateLimit struct {
+	Requests int
+	Interval time.Duration
+}
+
+// DefaultRateLimits are used for classes missing from Config.RateLimits.
+// They are generous for visitors (the payment page polls the status every
+// 10 seconds) and visitors sharing an address behind NAT.
+var DefaultRateLimits = map[RateLimitClass]RateLimit{
+	RateLimitCreate: {Requests: 30, Interval: time.Minute},
+	RateLimitStatus: {Requests: 120, Interval: time.Minute},
+	RateLimitSubmit: {Requests: 10, Interval: time.Minute},
+}
+
+// RateLimitStore keeps the token buckets of Config.RateLimits. Implement it
+// to share buckets between instances, e.g. in Redis.
+//
+// Related: NewMemoryRateLimitStore
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key, which holds limit.Requests
+	// tokens and refills at limit.Requests per limit.Interval.
+	//
+	// Returns:
+	//   - bool: false if the bucket is empty
+	//   - time.Duration: When the next token is available, if the bucket is empty
+	//   - error: If the store failed; the request is then allowed
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// rateLimitSweepInterval is how often MemoryRateLimitStore drops full buckets
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is the in-memory RateLimitStore used by default.
+// Buckets are per process, so each instance of a scaled-out deployment
+// limits on its own.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket is a client's bucket in MemoryRateLimitStore
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again and can be dropped
+	full time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	if limit.Requests <= 0 || limit.Interval <= 0 {
+		return true, 0, nil
+	}
+	now := time.Now()
+	rate := float64(limit.Requests) / limit.Interval.Seconds() // tokens per second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for k, bucket := range s.buckets {
+			if !now.Before(bucket.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Requests), last: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(limit.Requests), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+		return false, wait, nil
+	}
+	bucket.tokens--
+	bucket.full = now.Add(time.Duration((float64(limit.Requests) - bucket.tokens) / rate * float64(time.Second)))
+	return true, 0, nil
+}
+
+// rateLimiter applies Config.RateLimits to the paywall's endpoints
+type rateLimiter struct {
+	store  RateLimitStore
+	limits map[RateLimitClass]RateLimit
+}
+
+// newRateLimiter returns the limiter for config, nil when disabled
+func newRateLimiter(config Config) *rateLimiter {
+	if config.DisableRateLimits {
+		return nil
+	}
+	limits := make(map[RateLimitClass]RateLimit, len(DefaultRateLimits))
+	for class, limit := range DefaultRateLimits {
+		limits[class] = limit
+	}
+	for class, limit := range config.RateLimits {
+		limits[class] = limit
+	}
+	store := config.RateLimitStore
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	return &rateLimiter{store: store, limits: limits}
+}
+
+// allowRequest takes a token for r's client from the bucket of class. When
+// the bucket is empty it answers 429 Too Many Requests with a Retry-After
+// header and returns false; the handler must then return.
+//
+// Store failures are logged and the request is allowed, so a limiter outage
+// never takes payments down with it.
+func (p *Paywall) allowRequest(w http.ResponseWriter, r *http.Request, class RateLimitClass) bool {
+	ok, retryAfter := p.takeRequestToken(r, class)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+	return false
+}
+
+// takeRequestToken takes a token for r's client from the bucket of class,
+// for callers that report rate limiting themselves (see allowRequest)
+//
+// Returns:
+//   - bool: false if the client is rate limited
+//   - time.Duration: When the client may retry, if rate limited
+func (p *Paywall) takeRequestToken(r *http.Request, class RateLimitClass) (bool, time.Duration) {
+	if p.rateLimiter == nil {
+		return true, 0
+	}
+	limit := p.rateLimiter.limits[class]
+	if limit.Requests <= 0 {
+		return true, 0
+	}
+	ip := p.clientIP(r)
+	ok, retryAfter, err := p.rateLimiter.store.Take(r.Context(), string(class)+":"+ip, limit)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "rate_limit_store_failed",
+			Message: fmt.Sprintf("Rate limit store failed, allowing %s request: %v", class, err),
+		})
+		return true, 0
+	}
+	if ok {
+		return true, 0
+	}
+
+	p.logger.log(LogEntry{
+		Level:   LogLevelDebug,
+		Event:   "rate_limited",
+		Message: fmt.Sprintf("Rate limited %s request from %s to %s", class, ip, r.URL.Path),
+	})
+	return false, retryAfter
+}
@@ -0,0 +1,146 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore_Take(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := RateLimit{Requests: 3, Interval: 300 * time.Millisecond}
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := store.Take(context.Background(), "a", limit); !ok {
+			t.Fatalf("request %d was limited within the burst", i+1)
+		}
+	}
+	ok, retryAfter, err := store.Take(context.Background(), "a", limit)
+	if ok || err != nil || retryAfter <= 0 || retryAfter > 100*time.Millisecond {
+		t.Fatalf("Take() on an empty bucket = %v, %v, %v; want false with a retry within 100ms", ok, retryAfter, err)
+	}
+	if ok, _, _ := store.Take(context.Background(), "b", limit); !ok {
+		t.Error("another key shares the empty bucket")
+	}
+
+	// One token refills every 100ms
+	time.Sleep(110 * time.Millisecond)
+	if ok, _, _ := store.Take(context.Background(), "a", limit); !ok {
+		t.Error("bucket did not refill")
+	}
+
+	if ok, _, _ := store.Take(context.Background(), "a", RateLimit{}); !ok {
+		t.Error("zero RateLimit limited a request")
+	}
+}
+
+// failingRateLimitStore is a RateLimitStore that is always down
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestRateLimits_Endpoints(t *testing.T) {
+	newPaywall := func(t *testing.T, config Config) *Paywall {
+		t.Helper()
+		config.PriceInBTC = 0.001
+		config.PaymentTimeout = time.Hour
+		config.TestNet = true
+		config.Store = NewMemoryStore()
+		pw, err := NewPaywall(config)
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		t.Cleanup(pw.Close)
+		return pw
+	}
+	paywalled := func(pw *Paywall) http.Handler {
+		return pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	}
+	request := func(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/article", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("payment creation", func(t *testing.T) {
+		pw := newPaywall(t, Config{RateLimits: map[RateLimitClass]RateLimit{
+			RateLimitCreate: {Requests: 2, Interval: time.Hour},
+		}})
+		handler := paywalled(pw)
+		for i := 0; i < 2; i++ {
+			if rec := request(handler, "198.51.100.1:1234"); rec.Code != http.StatusOK {
+				t.Fatalf("request %d status = %d, want 200", i+1, rec.Code)
+			}
+		}
+		rec := request(handler, "198.51.100.1:1234")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+			t.Errorf("third request = %d (Retry-After %q), want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+		}
+		if rec := request(handler, "198.51.100.2:1234"); rec.Code != http.StatusOK {
+			t.Errorf("other client status = %d, want 200", rec.Code)
+		}
+	})
+
+	t.Run("status endpoint", func(t *testing.T) {
+		pw := newPaywall(t, Config{RateLimits: map[RateLimitClass]RateLimit{
+			RateLimitStatus: {Requests: 1, Interval: time.Hour},
+		}})
+		codes := make([]int, 2)
+		for i := range codes {
+			rec := httptest.NewRecorder()
+			pw.HandlePaymentStatus(rec, httptest.NewRequest(http.MethodGet, "/paywall/status", nil))
+			codes[i] = rec.Code
+		}
+		if codes[0] != http.StatusNotFound || codes[1] != http.StatusTooManyRequests {
+			t.Errorf("status codes = %v, want [404 429]", codes)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		pw := newPaywall(t, Config{
+			DisableRateLimits: true,
+			RateLimits:        map[RateLimitClass]RateLimit{RateLimitCreate: {Requests: 1, Interval: time.Hour}},
+		})
+		handler := paywalled(pw)
+		for i := 0; i < 3; i++ {
+			if rec := request(handler, "198.51.100.1:1234"); rec.Code != http.StatusOK {
+				t.Fatalf("request %d status = %d, want 200", i+1, rec.Code)
+			}
+		}
+	})
+
+	t.Run("store failure allows requests", func(t *testing.T) {
+		pw := newPaywall(t, Config{RateLimitStore: failingRateLimitStore{}})
+		if rec := request(paywalled(pw), "198.51.100.1:1234"); rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200", rec.Code)
+		}
+	})
+}
+
+func TestRateLimits_Config(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits map[RateLimitClass]RateLimit
+		want   string
+	}{
+		{"unknown class", map[RateLimitClass]RateLimit{"sse": {Requests: 1, Interval: time.Second}}, "unknown class"},
+		{"missing interval", map[RateLimitClass]RateLimit{RateLimitCreate: {Requests: 1}}, "positive Requests and Interval"},
+		{"negative", map[RateLimitClass]RateLimit{RateLimitStatus: {Requests: -1, Interval: time.Second}}, "positive Requests and Interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, Store: NewMemoryStore(), RateLimits: tt.limits}
+			if err := validateConfig(&config); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
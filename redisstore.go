@@ -0,0 +1,845 @@
+package paywall
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redisRequestTimeout bounds dialing and a single command round trip
+	redisRequestTimeout = 10 * time.Second
+	// redisScanCount is how many payment IDs a listing reads per round trip
+	redisScanCount = 500
+	// redisLockTTL is how long a payment lock lives if its holder dies
+	redisLockTTL = 30 * time.Second
+	// redisLockPoll is how often a waiting LockPayment retries
+	redisLockPoll = 50 * time.Millisecond
+	// redisMinTTL is the shortest lifetime given to a stored payment, so a
+	// payment updated after its retention ended can still be read back
+	redisMinTTL = time.Minute
+	// defaultRedisRetention is the default RedisStoreConfig.Retention
+	defaultRedisRetention = 7 * 24 * time.Hour
+	// defaultRedisPoolSize is the default RedisStoreConfig.PoolSize
+	defaultRedisPoolSize = 8
+	// redisDeleteRetries is how often DeletePayment retries when the payment
+	// changes while it is being deleted
+	redisDeleteRetries = 5
+)
+
+// RedisStoreConfig configures a RedisStore
+type RedisStoreConfig struct {
+	// Addr is the server's host:port, e.g. "localhost:6379"
+	Addr string
+	// Username and Password authenticate the connections (AUTH).
+	// Optional: Username is only needed for ACL users other than "default".
+	Username string
+	Password string
+	// DB selects the logical database. Optional: defaults to 0.
+	DB int
+	// TLS encrypts the connections, as managed Redis services require.
+	// Optional: defaults to plain TCP.
+	TLS *tls.Config
+	// KeyPrefix is prepended to every key, to share a server.
+	// Optional: defaults to "paywall:".
+	KeyPrefix string
+	// Retention is how long a payment is kept after it stops granting access
+	// (its ExpiresAt, AccessExpiresAt or escrow timeout, whichever is last), so
+	// late payments are still matched and reports see recent payments.
+	// Optional: defaults to 7 days.
+	Retention time.Duration
+	// PoolSize is how many idle connections are kept open.
+	// Optional: defaults to 8.
+	PoolSize int
+	// ValidateAddresses rejects payments whose addresses fail
+	// ValidatePaymentAddresses on CreatePayment and UpdatePayment.
+	// Optional: defaults to false.
+	ValidateAddresses bool
+}
+
+// RedisStore is a PaymentStore backed by Redis, so instances behind a load
+// balancer share payment state. Each payment is a JSON string whose TTL follows
+// the payment: it lives until the payment stops granting access plus
+// RedisStoreConfig.Retention, so payments created with Config.PaymentTimeout
+// disappear on their own. Keys mapping addresses to payment IDs make
+// GetPaymentByAddress a single lookup, and sorted sets of payment IDs serve the
+// listings.
+//
+// Creates and updates run in MULTI/EXEC transactions guarded by WATCH, so a
+// payment and its indexes change together, and UpdatePayment only replaces the
+// version it was read at, returning ErrVersionConflict otherwise.
+//
+// RedisStore implements PaymentLocker with expiring lock keys, so it also
+// serializes the monitor and escrow updates of instances sharing the server.
+//
+// Related: PaymentStore, PaymentLister, PaymentStreamer, PaymentLocker
+type RedisStore struct {
+	addr      string
+	username  string
+	password  string
+	db        int
+	tls       *tls.Config
+	prefix    string
+	retention time.Duration
+	// idle holds open connections for reuse
+	idle chan *redisConn
+	// validateAddresses is RedisStoreConfig.ValidateAddresses
+	validateAddresses bool
+}
+
+// NewRedisStore creates a payment store on a Redis server.
+//
+// Parameters:
+//   - config: Server address, credentials and key layout
+//
+// Returns:
+//   - *RedisStore: The store, connected
+//   - error: If Addr is missing or the server cannot be reached
+func NewRedisStore(config RedisStoreConfig) (*RedisStore, error) {
+	if config.Addr == "" {
+		return nil, fmt.Errorf("Redis address is required (hint: e.g. \"localhost:6379\")")
+	}
+	if config.Retention < 0 || config.PoolSize < 0 || config.DB < 0 {
+		return nil, fmt.Errorf("Redis Retention, PoolSize and DB must not be negative")
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "paywall:"
+	}
+	if config.Retention == 0 {
+		config.Retention = defaultRedisRetention
+	}
+	if config.PoolSize == 0 {
+		config.PoolSize = defaultRedisPoolSize
+	}
+	s := &RedisStore{
+		addr:              config.Addr,
+		username:          config.Username,
+		password:          config.Password,
+		db:                config.DB,
+		tls:               config.TLS,
+		prefix:            config.KeyPrefix,
+		retention:         config.Retention,
+		idle:              make(chan *redisConn, config.PoolSize),
+		validateAddresses: config.ValidateAddresses,
+	}
+	if _, err := s.do("PING"); err != nil {
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+	return s, nil
+}
+
+// key returns the prefixed key of parts joined by ":"
+func (s *RedisStore) key(parts ...string) string {
+	return s.prefix + strings.Join(parts, ":")
+}
+
+// checkPayment validates a payment's ID and, with ValidateAddresses, its addresses
+func (s *RedisStore) checkPayment(p *Payment) error {
+	if p.ID == "" {
+		return fmt.Errorf("payment ID is required")
+	}
+	if s.validateAddresses {
+		return ValidatePaymentAddresses(p)
+	}
+	return nil
+}
+
+// expiry returns when p's keys expire: Retention after the last of its access
+// end and escrow timeout, but never within redisMinTTL of now
+func (s *RedisStore) expiry(p *Payment, now time.Time) time.Time {
+	end := p.AccessEnds()
+	if p.EscrowTimeout.After(end) {
+		end = p.EscrowTimeout
+	}
+	expiry := end.Add(s.retention)
+	if min := now.Add(redisMinTTL); expiry.Before(min) {
+		expiry = min
+	}
+	return expiry
+}
+
+// writeCommands returns the commands storing p as data together with its
+// indexes, replacing the address keys of previous
+func (s *RedisStore) writeCommands(p *Payment, data []byte, previous *Payment) [][]string {
+	now := time.Now()
+	expiry := s.expiry(p, now)
+	ttl := strconv.FormatInt(expiry.Sub(now).Milliseconds(), 10)
+	score := strconv.FormatInt(expiry.UnixMilli(), 10)
+
+	commands := [][]string{{"SET", s.key("payment", p.ID), string(data), "PX", ttl}}
+	if previous != nil {
+		for _, address := range previous.Addresses {
+			if address != "" && !paymentHasAddress(p, address) {
+				commands = append(commands, []string{"DEL", s.key("address", address)})
+			}
+		}
+	}
+	for _, address := range p.Addresses {
+		if address != "" {
+			commands = append(commands, []string{"SET", s.key("address", address), p.ID, "PX", ttl})
+		}
+	}
+	commands = append(commands, []string{"ZADD", s.key("payments"), score, p.ID})
+	if p.Confirmations < 1 {
+		commands = append(commands, []string{"ZADD", s.key("pending"), score, p.ID})
+	} else {
+		commands = append(commands, []string{"ZREM", s.key("pending"), p.ID})
+	}
+	return commands
+}
+
+// paymentHasAddress reports whether address is one of p's addresses
+func paymentHasAddress(p *Payment, address string) bool {
+	for _, a := range p.Addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePayment stores a new payment and indexes its addresses in one transaction.
+//
+// Returns:
+//   - error: If the payment already exists or a command fails
+func (s *RedisStore) CreatePayment(p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+	key := s.key("payment", p.ID)
+	return s.withConn(func(c *redisConn) error {
+		if err := c.watch(key); err != nil {
+			return err
+		}
+		if existing, err := c.do("GET", key); err != nil {
+			return fmt.Errorf("read payment: %w", err)
+		} else if existing != nil {
+			return fmt.Errorf("payment %s already exists", p.ID)
+		}
+		committed, err := c.transaction(s.writeCommands(p, data, nil))
+		if err != nil {
+			return fmt.Errorf("store payment: %w", err)
+		}
+		if !committed {
+			return fmt.Errorf("payment %s already exists", p.ID)
+		}
+		return nil
+	})
+}
+
+// GetPayment retrieves a payment by ID.
+//
+// Returns:
+//   - *Payment: The payment, nil if not found
+//   - error: Command, unmarshaling or migration errors
+func (s *RedisStore) GetPayment(id string) (*Payment, error) {
+	reply, err := s.do("GET", s.key("payment", id))
+	if err != nil {
+		return nil, fmt.Errorf("read payment: %w", err)
+	}
+	return decodeRedisPayment(reply)
+}
+
+// decodeRedisPayment decodes a payment read with GET or MGET, nil if the
+// reply is nil
+func decodeRedisPayment(reply interface{}) (*Payment, error) {
+	data, ok := reply.(string)
+	if !ok {
+		return nil, nil
+	}
+	var payment Payment
+	if err := json.Unmarshal([]byte(data), &payment); err != nil {
+		return nil, fmt.Errorf("unmarshal payment: %w", err)
+	}
+	if err := MigratePayment(&payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// UpdatePayment replaces a payment if it still has the version p was read at,
+// moving its address keys and listing entries in the same transaction.
+//
+// Returns:
+//   - error: ErrVersionConflict if the payment changed since it was read, or
+//     an error if it does not exist or a command fails
+func (s *RedisStore) UpdatePayment(p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	p.Version++
+	data, err := json.Marshal(p)
+	p.Version--
+	if err != nil {
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+	key := s.key("payment", p.ID)
+	err = s.withConn(func(c *redisConn) error {
+		if err := c.watch(key); err != nil {
+			return err
+		}
+		reply, err := c.do("GET", key)
+		if err != nil {
+			return fmt.Errorf("read payment: %w", err)
+		}
+		existing, err := decodeRedisPayment(reply)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("payment %s not found", p.ID)
+		}
+		if existing.Version != p.Version {
+			return ErrVersionConflict
+		}
+		committed, err := c.transaction(s.writeCommands(p, data, existing))
+		if err != nil {
+			return fmt.Errorf("store payment: %w", err)
+		}
+		if !committed {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+	if err == nil {
+		p.Version++
+	}
+	return err
+}
+
+// GetPaymentByAddress retrieves a payment by one of its addresses using the
+// address keys.
+//
+// Returns:
+//   - *Payment: The payment, nil if no payment uses the address
+//   - error: Command or unmarshaling errors
+func (s *RedisStore) GetPaymentByAddress(addr string) (*Payment, error) {
+	id, err := s.do("GET", s.key("address", addr))
+	if err != nil {
+		return nil, fmt.Errorf("read address index: %w", err)
+	}
+	paymentID, ok := id.(string)
+	if !ok {
+		return nil, nil
+	}
+	return s.GetPayment(paymentID)
+}
+
+// ListPendingPayments returns all payments with less than 1 confirmation.
+func (s *RedisStore) ListPendingPayments() ([]*Payment, error) {
+	var payments []*Payment
+	err := s.scanPayments(s.key("pending"), "", func(p *Payment) error {
+		if p.Confirmations < 1 {
+			payments = append(payments, p)
+		}
+		return nil
+	})
+	return payments, err
+}
+
+// ListPayments returns every stored payment.
+func (s *RedisStore) ListPayments() ([]*Payment, error) {
+	return s.listPayments(func(*Payment) bool { return true })
+}
+
+// GetPendingMultisigPayments returns all pending payments that have multisig enabled.
+func (s *RedisStore) GetPendingMultisigPayments() ([]*Payment, error) {
+	return s.listPayments(func(p *Payment) bool {
+		return p.MultisigEnabled && p.Status == StatusPending
+	})
+}
+
+// GetEscrowsExpiringBefore returns funded or disputed escrows expiring before deadline.
+func (s *RedisStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
+	return s.listPayments(func(p *Payment) bool {
+		return p.MultisigEnabled &&
+			(p.EscrowState == EscrowFunded || p.EscrowState == EscrowDisputed) &&
+			!p.EscrowTimeout.IsZero() && p.EscrowTimeout.Before(deadline)
+	})
+}
+
+// DeletePayment removes a payment, its address keys and listing entries in
+// one transaction.
+//
+// Returns:
+//   - error: Command errors; deleting a missing payment succeeds
+func (s *RedisStore) DeletePayment(id string) error {
+	key := s.key("payment", id)
+	return s.withConn(func(c *redisConn) error {
+		for attempt := 0; attempt < redisDeleteRetries; attempt++ {
+			if err := c.watch(key); err != nil {
+				return err
+			}
+			reply, err := c.do("GET", key)
+			if err != nil {
+				return fmt.Errorf("read payment: %w", err)
+			}
+			existing, err := decodeRedisPayment(reply)
+			if err != nil {
+				return err
+			}
+			commands := [][]string{{"DEL", key}, {"ZREM", s.key("payments"), id}, {"ZREM", s.key("pending"), id}}
+			if existing != nil {
+				for _, address := range existing.Addresses {
+					if address != "" {
+						commands = append(commands, []string{"DEL", s.key("address", address)})
+					}
+				}
+			}
+			committed, err := c.transaction(commands)
+			if err != nil {
+				return fmt.Errorf("delete payment: %w", err)
+			}
+			if committed {
+				return nil
+			}
+		}
+		return fmt.Errorf("payment %s kept changing while being deleted", id)
+	})
+}
+
+// ArchivePayments deletes the payments ArchivablePayment selects for
+// olderThan; RedisStore has no archive. Their keys would expire after
+// RedisStoreConfig.Retention anyway.
+//
+// Returns:
+//   - int: How many payments were deleted
+//   - error: Command errors
+func (s *RedisStore) ArchivePayments(olderThan time.Time) (int, error) {
+	old, err := s.listPayments(func(p *Payment) bool { return ArchivablePayment(p, olderThan) })
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range old {
+		if err := s.DeletePayment(p.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(old), nil
+}
+
+// listPayments returns the stored payments matching keep
+func (s *RedisStore) listPayments(keep func(*Payment) bool) ([]*Payment, error) {
+	var payments []*Payment
+	err := s.scanPayments(s.key("payments"), "", func(p *Payment) error {
+		if keep(p) {
+			payments = append(payments, p)
+		}
+		return nil
+	})
+	return payments, err
+}
+
+// StreamPayments calls fn for every payment matching filter, reading a page of
+// payments at a time, so memory use does not grow with the store. Implements
+// PaymentStreamer.
+//
+// Returns:
+//   - error: Command errors, or fn's error other than ErrStopStream
+func (s *RedisStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	return streamEnded(s.scanPayments(s.key("payments"), partitionIDPrefix(filter.Partition), func(p *Payment) error {
+		if !filter.Matches(p) {
+			return nil
+		}
+		return fn(p)
+	}))
+}
+
+// scanPayments calls fn for each payment listed in the sorted set index whose
+// ID starts with idPrefix. Entries of expired payments are removed from the
+// index first; payments that cannot be parsed are logged and skipped.
+func (s *RedisStore) scanPayments(index, idPrefix string, fn func(*Payment) error) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if _, err := s.do("ZREMRANGEBYSCORE", index, "-inf", "("+now); err != nil {
+		return fmt.Errorf("prune payment index: %w", err)
+	}
+
+	cursor := "0"
+	for {
+		reply, err := s.do("ZSCAN", index, cursor, "COUNT", strconv.Itoa(redisScanCount))
+		if err != nil {
+			return fmt.Errorf("scan payment index: %w", err)
+		}
+		page, ok := reply.([]interface{})
+		if !ok || len(page) != 2 {
+			return fmt.Errorf("scan payment index: unexpected reply %v", reply)
+		}
+		cursor, _ = page[0].(string)
+		members, _ := page[1].([]interface{})
+
+		// Members alternate with their scores
+		keys := []string{"MGET"}
+		for i := 0; i < len(members); i += 2 {
+			if id, _ := members[i].(string); strings.HasPrefix(id, idPrefix) {
+				keys = append(keys, s.key("payment", id))
+			}
+		}
+		if len(keys) > 1 {
+			reply, err := s.do(keys...)
+			if err != nil {
+				return fmt.Errorf("read payments: %w", err)
+			}
+			values, _ := reply.([]interface{})
+			for i, value := range values {
+				payment, err := decodeRedisPayment(value)
+				if err != nil {
+					log.Printf("Error reading %s: %v", keys[i+1], err)
+					continue
+				}
+				if payment == nil {
+					continue
+				}
+				if err := fn(payment); err != nil {
+					return err
+				}
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// LockPayment takes the payment's lock key, waiting until it is free or ctx
+// is done. A lock whose holder died expires after 30 seconds. Implements
+// PaymentLocker.
+//
+// Returns:
+//   - unlock: Releases the lock if it is still held by this call
+//   - error: ErrPaymentLocked (wrapped) when ctx ends first, or command errors
+func (s *RedisStore) LockPayment(ctx context.Context, paymentID string) (func(), error) {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, fmt.Errorf("generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+	key := s.key("lock", paymentID)
+	ttl := strconv.FormatInt(redisLockTTL.Milliseconds(), 10)
+
+	for {
+		reply, err := s.do("SET", key, token, "NX", "PX", ttl)
+		if err != nil {
+			return nil, fmt.Errorf("take payment lock: %w", err)
+		}
+		if reply != nil {
+			var once sync.Once
+			return func() {
+				once.Do(func() { s.unlock(key, token) })
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrPaymentLocked, ctx.Err())
+		case <-time.After(redisLockPoll):
+		}
+	}
+}
+
+// unlock deletes a lock key if it still holds token, not a lock that replaced
+// it after it expired
+func (s *RedisStore) unlock(key, token string) {
+	err := s.withConn(func(c *redisConn) error {
+		if err := c.watch(key); err != nil {
+			return err
+		}
+		if held, err := c.do("GET", key); err != nil || held != token {
+			return errors.Join(err, c.unwatch())
+		}
+		_, err := c.transaction([][]string{{"DEL", key}})
+		return err
+	})
+	if err != nil {
+		log.Printf("Error releasing %s: %v", key, err)
+	}
+}
+
+// ReserveAddressIndex implements AddressIndexReserver with a counter key per
+// wallet, advanced with INCRBY so concurrent callers always get distinct
+// indexes. A counter behind floor jumps to it; the indexes it skips are
+// never handed out.
+//
+// Returns:
+//   - uint32: The reserved index, at least floor
+//   - error: Command errors
+func (s *RedisStore) ReserveAddressIndex(ctx context.Context, key string, floor uint32) (uint32, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	counter := s.key("addressindex", key)
+	reply, err := s.do("INCRBY", counter, "1")
+	if err != nil {
+		return 0, fmt.Errorf("reserve address index: %w", err)
+	}
+	next, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("reserve address index: unexpected reply %T", reply)
+	}
+	if next-1 < int64(floor) {
+		reply, err = s.do("INCRBY", counter, strconv.FormatInt(int64(floor)-(next-1), 10))
+		if err != nil {
+			return 0, fmt.Errorf("reserve address index: %w", err)
+		}
+		if next, ok = reply.(int64); !ok {
+			return 0, fmt.Errorf("reserve address index: unexpected reply %T", reply)
+		}
+	}
+	return uint32(next - 1), nil
+}
+
+// Close closes the idle connections. Commands issued afterwards open new ones.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs one command on a pooled connection
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	var reply interface{}
+	err := s.withConn(func(c *redisConn) error {
+		var err error
+		reply, err = c.do(args...)
+		return err
+	})
+	return reply, err
+}
+
+// withConn runs fn on a pooled connection. A connection left watching keys or
+// inside a transaction, e.g. because fn returned early, is closed instead of
+// reused, as is one that failed for other reasons than a rejected command.
+func (s *RedisStore) withConn(fn func(c *redisConn) error) error {
+	var c *redisConn
+	select {
+	case c = <-s.idle:
+	default:
+		var err error
+		if c, err = s.dial(); err != nil {
+			return err
+		}
+	}
+	err := fn(c)
+	var replyErr redisError
+	if !c.clean() || (err != nil && !errors.As(err, &replyErr)) {
+		c.conn.Close()
+		return err
+	}
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+	return err
+}
+
+// dial opens and authenticates a connection
+func (s *RedisStore) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: redisRequestTimeout}
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial Redis: %w", err)
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("authenticate to Redis: %w", err)
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("select Redis database %d: %w", s.db, err)
+		}
+	}
+	return c, nil
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// Temporary reports whether the server may accept the command later, e.g.
+// while it loads its data set or a replica is promoted (see ClassifyStoreError)
+func (e redisError) Temporary() bool {
+	for _, prefix := range []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY"} {
+		if strings.HasPrefix(string(e), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redisConn is one connection speaking RESP, the Redis protocol
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+	// watching is set between WATCH and EXEC, DISCARD or UNWATCH
+	watching bool
+	// inTx is set between MULTI and EXEC or DISCARD
+	inTx bool
+}
+
+// clean reports whether the connection watches no keys and is outside a
+// transaction, so it can be reused
+func (c *redisConn) clean() bool {
+	return !c.watching && !c.inTx
+}
+
+// watch makes the next transaction abort if key changes first
+func (c *redisConn) watch(key string) error {
+	c.watching = true
+	_, err := c.do("WATCH", key)
+	return err
+}
+
+// unwatch forgets the watched keys
+func (c *redisConn) unwatch() error {
+	_, err := c.do("UNWATCH")
+	if err == nil {
+		c.watching = false
+	}
+	return err
+}
+
+// do sends a command and reads its reply: a string, int64, nil, []interface{}
+// or, for the failed commands of a transaction, redisError elements
+//
+// Returns:
+//   - interface{}: The reply
+//   - error: redisError if the server rejected the command, or I/O errors
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(redisRequestTimeout))
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	reply, err := readRedisReply(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := reply.(redisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// transaction runs commands in MULTI/EXEC. Keys WATCHed before are released.
+//
+// Returns:
+//   - bool: false if a watched key changed and nothing was run
+//   - error: If a command was rejected or failed
+func (c *redisConn) transaction(commands [][]string) (bool, error) {
+	if _, err := c.do("MULTI"); err != nil {
+		return false, err
+	}
+	c.inTx = true
+	for _, command := range commands {
+		if _, err := c.do(command...); err != nil {
+			if _, discardErr := c.do("DISCARD"); discardErr == nil {
+				c.inTx, c.watching = false, false
+			}
+			return false, err
+		}
+	}
+	reply, err := c.do("EXEC")
+	if err != nil {
+		return false, err
+	}
+	c.inTx, c.watching = false, false
+	if reply == nil {
+		return false, nil
+	}
+	results, _ := reply.([]interface{})
+	for _, result := range results {
+		if replyErr, ok := result.(redisError); ok {
+			return true, replyErr
+		}
+	}
+	return true, nil
+}
+
+// readRedisReply reads one RESP2 reply. Error replies are returned as a
+// redisError value so that errors nested in arrays keep the stream in sync.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+}
@@ -0,0 +1,596 @@
+package paywall
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeRedis is a minimal in-memory Redis server speaking RESP, implementing
+// the commands RedisStore uses, including WATCH/MULTI/EXEC and key expiry
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	strings  map[string]string
+	zsets    map[string]map[string]float64
+	expireAt map[string]time.Time
+	// versions counts writes per key, for WATCH
+	versions map[string]int
+	// beforeExec runs before a transaction executes, to simulate another client
+	beforeExec func()
+}
+
+// fakeRedisNilArray is the reply of an aborted EXEC
+type fakeRedisNilArray struct{}
+
+// fakeRedisStatus is a simple string reply
+type fakeRedisStatus string
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{
+		listener: listener,
+		password: password,
+		strings:  make(map[string]string),
+		zsets:    make(map[string]map[string]float64),
+		expireAt: make(map[string]time.Time),
+		versions: make(map[string]int),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.listener.Addr().String() }
+
+// serve handles one client connection
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authenticated := f.password == ""
+	watched := make(map[string]int)
+	var queue [][]string
+	inMulti := false
+
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		command := strings.ToUpper(args[0])
+
+		var out interface{}
+		switch {
+		case command == "AUTH":
+			if args[len(args)-1] == f.password {
+				authenticated = true
+				out = fakeRedisStatus("OK")
+			} else {
+				out = redisError("WRONGPASS invalid username-password pair")
+			}
+		case !authenticated:
+			out = redisError("NOAUTH Authentication required.")
+		case command == "MULTI":
+			inMulti = true
+			queue = nil
+			out = fakeRedisStatus("OK")
+		case command == "DISCARD":
+			inMulti = false
+			watched = make(map[string]int)
+			out = fakeRedisStatus("OK")
+		case command == "EXEC":
+			inMulti = false
+			f.mu.Lock()
+			if f.beforeExec != nil {
+				hook := f.beforeExec
+				f.beforeExec = nil
+				f.mu.Unlock()
+				hook()
+				f.mu.Lock()
+			}
+			aborted := false
+			for key, version := range watched {
+				f.expire(key)
+				if f.versions[key] != version {
+					aborted = true
+				}
+			}
+			if aborted {
+				out = fakeRedisNilArray{}
+			} else {
+				results := make([]interface{}, len(queue))
+				for i, queued := range queue {
+					results[i] = f.run(queued)
+				}
+				out = results
+			}
+			f.mu.Unlock()
+			watched = make(map[string]int)
+		case inMulti:
+			queue = append(queue, args)
+			out = fakeRedisStatus("QUEUED")
+		case command == "WATCH":
+			f.mu.Lock()
+			for _, key := range args[1:] {
+				f.expire(key)
+				watched[key] = f.versions[key]
+			}
+			f.mu.Unlock()
+			out = fakeRedisStatus("OK")
+		case command == "UNWATCH":
+			watched = make(map[string]int)
+			out = fakeRedisStatus("OK")
+		default:
+			f.mu.Lock()
+			out = f.run(args)
+			f.mu.Unlock()
+		}
+		writeFakeRedisReply(w, out)
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// expire deletes key if its TTL passed. Callers hold f.mu.
+func (f *fakeRedis) expire(key string) {
+	if at, ok := f.expireAt[key]; ok && !time.Now().Before(at) {
+		f.delete(key)
+	}
+}
+
+// delete removes key. Callers hold f.mu.
+func (f *fakeRedis) delete(key string) bool {
+	_, isString := f.strings[key]
+	_, isZset := f.zsets[key]
+	delete(f.strings, key)
+	delete(f.zsets, key)
+	delete(f.expireAt, key)
+	f.versions[key]++
+	return isString || isZset
+}
+
+// run executes a data command. Callers hold f.mu.
+func (f *fakeRedis) run(args []string) interface{} {
+	if len(args) > 1 {
+		f.expire(args[1])
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return fakeRedisStatus("PONG")
+	case "SELECT":
+		return fakeRedisStatus("OK")
+	case "GET":
+		if _, ok := f.zsets[args[1]]; ok {
+			return redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+		}
+		if value, ok := f.strings[args[1]]; ok {
+			return value
+		}
+		return nil
+	case "MGET":
+		values := make([]interface{}, len(args)-1)
+		for i, key := range args[1:] {
+			f.expire(key)
+			if value, ok := f.strings[key]; ok {
+				values[i] = value
+			}
+		}
+		return values
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if _, exists := f.strings[key]; exists {
+					return nil
+				}
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			}
+		}
+		f.strings[key] = value
+		delete(f.expireAt, key)
+		if ttl > 0 {
+			f.expireAt[key] = time.Now().Add(ttl)
+		}
+		f.versions[key]++
+		return fakeRedisStatus("OK")
+	case "INCRBY":
+		n, _ := strconv.ParseInt(f.strings[args[1]], 10, 64)
+		by, _ := strconv.ParseInt(args[2], 10, 64)
+		n += by
+		f.strings[args[1]] = strconv.FormatInt(n, 10)
+		f.versions[args[1]]++
+		return n
+	case "DEL":
+		n := int64(0)
+		for _, key := range args[1:] {
+			if f.delete(key) {
+				n++
+			}
+		}
+		return n
+	case "ZADD":
+		zset := f.zsets[args[1]]
+		if zset == nil {
+			zset = make(map[string]float64)
+			f.zsets[args[1]] = zset
+		}
+		score, _ := strconv.ParseFloat(args[2], 64)
+		_, existed := zset[args[3]]
+		zset[args[3]] = score
+		f.versions[args[1]]++
+		if existed {
+			return int64(0)
+		}
+		return int64(1)
+	case "ZREM":
+		zset := f.zsets[args[1]]
+		if _, ok := zset[args[2]]; !ok {
+			return int64(0)
+		}
+		delete(zset, args[2])
+		f.versions[args[1]]++
+		return int64(1)
+	case "ZREMRANGEBYSCORE":
+		max, _ := strconv.ParseFloat(strings.TrimPrefix(args[3], "("), 64)
+		n := int64(0)
+		for member, score := range f.zsets[args[1]] {
+			if score < max {
+				delete(f.zsets[args[1]], member)
+				n++
+			}
+		}
+		return n
+	case "ZSCAN":
+		var members []string
+		for member := range f.zsets[args[1]] {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		offset, _ := strconv.Atoi(args[2])
+		count, _ := strconv.Atoi(args[4])
+		end := offset + count
+		next := strconv.Itoa(end)
+		if end >= len(members) {
+			end = len(members)
+			next = "0"
+		}
+		var page []interface{}
+		for _, member := range members[min(offset, len(members)):end] {
+			page = append(page, member, strconv.FormatFloat(f.zsets[args[1]][member], 'f', -1, 64))
+		}
+		return []interface{}{next, page}
+	}
+	return redisError("ERR unknown command '" + args[0] + "'")
+}
+
+// writeFakeRedisReply encodes a reply in RESP
+func writeFakeRedisReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case fakeRedisNilArray:
+		w.WriteString("*-1\r\n")
+	case fakeRedisStatus:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case redisError:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeFakeRedisReply(w, item)
+		}
+	}
+}
+
+// ttl returns the remaining lifetime of key, 0 without one
+func (f *fakeRedis) ttl(key string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if at, ok := f.expireAt[key]; ok {
+		return time.Until(at)
+	}
+	return 0
+}
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *fakeRedis) {
+	t.Helper()
+	fake := newFakeRedis(t, "secret")
+	store, err := NewRedisStore(RedisStoreConfig{Addr: fake.addr(), Password: "secret", Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRedisStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, fake
+}
+
+func TestRedisStore_PaymentLifecycle(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	payment := newSQLTestPayment("pay1", "tb1qold")
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if err := store.CreatePayment(payment); err == nil {
+		t.Error("CreatePayment() of an existing payment succeeded")
+	}
+
+	got, err := store.GetPayment("pay1")
+	if err != nil || got == nil || got.Addresses[wallet.Bitcoin] != "tb1qold" {
+		t.Fatalf("GetPayment() = %+v, %v", got, err)
+	}
+	if missing, err := store.GetPayment("nope"); missing != nil || err != nil {
+		t.Errorf("GetPayment(missing) = %v, %v, want nil, nil", missing, err)
+	}
+	if byAddress, err := store.GetPaymentByAddress("tb1qold"); err != nil || byAddress == nil || byAddress.ID != "pay1" {
+		t.Errorf("GetPaymentByAddress() = %+v, %v", byAddress, err)
+	}
+
+	// Updates bump the version, move the address index; stale copies conflict
+	stale, _ := store.GetPayment("pay1")
+	got.Status = StatusConfirmed
+	got.Confirmations = 1
+	got.Addresses[wallet.Bitcoin] = "tb1qnew"
+	if err := store.UpdatePayment(got); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+	if got.Version != 1 {
+		t.Errorf("version after update = %d, want 1", got.Version)
+	}
+	if err := store.UpdatePayment(stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UpdatePayment(stale) error = %v, want ErrVersionConflict", err)
+	}
+	if old, _ := store.GetPaymentByAddress("tb1qold"); old != nil {
+		t.Error("old address still indexed after update")
+	}
+	if byAddress, _ := store.GetPaymentByAddress("tb1qnew"); byAddress == nil || byAddress.Status != StatusConfirmed {
+		t.Errorf("GetPaymentByAddress(new) = %+v", byAddress)
+	}
+	if err := store.UpdatePayment(newSQLTestPayment("nope", "x")); err == nil {
+		t.Error("UpdatePayment() of a missing payment succeeded")
+	}
+}
+
+func TestRedisStore_ConcurrentUpdate(t *testing.T) {
+	store, fake := newTestRedisStore(t)
+	payment := newSQLTestPayment("pay1", "tb1qaddress")
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+	mine, _ := store.GetPayment("pay1")
+
+	// Another instance writes between the version check and the transaction
+	other, _ := store.GetPayment("pay1")
+	fake.beforeExec = func() {
+		other.Status = StatusExpired
+		if err := store.UpdatePayment(other); err != nil {
+			t.Errorf("concurrent UpdatePayment() error = %v", err)
+		}
+	}
+	mine.Status = StatusConfirmed
+	if err := store.UpdatePayment(mine); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("UpdatePayment() error = %v, want ErrVersionConflict", err)
+	}
+	if mine.Version != 0 {
+		t.Errorf("version after conflict = %d, want 0", mine.Version)
+	}
+	stored, _ := store.GetPayment("pay1")
+	if stored.Status != StatusExpired {
+		t.Errorf("stored status = %s, want the concurrent writer's expired", stored.Status)
+	}
+
+	// The connection is reusable after the aborted transaction
+	if err := store.UpdatePayment(stored); err != nil {
+		t.Errorf("UpdatePayment() after conflict error = %v", err)
+	}
+}
+
+func TestRedisStore_TTL(t *testing.T) {
+	store, fake := newTestRedisStore(t)
+	payment := newSQLTestPayment("pay1", "tb1qaddress")
+	payment.ExpiresAt = time.Now().Add(30 * time.Minute)
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+	// Payment window plus one hour of retention
+	for _, key := range []string{"paywall:payment:pay1", "paywall:address:tb1qaddress"} {
+		if ttl := fake.ttl(key); ttl < 89*time.Minute || ttl > 90*time.Minute {
+			t.Errorf("TTL of %s = %v, want 90 minutes", key, ttl)
+		}
+	}
+
+	// A longer access window extends the payment's life
+	payment.Status = StatusConfirmed
+	payment.Confirmations = 1
+	payment.AccessExpiresAt = time.Now().Add(24 * time.Hour)
+	if err := store.UpdatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := fake.ttl("paywall:payment:pay1"); ttl < 24*time.Hour {
+		t.Errorf("TTL after confirmation = %v, want 25 hours", ttl)
+	}
+
+	// Expired payments drop out of the listings
+	old := newSQLTestPayment("old", "tb1qold")
+	old.ExpiresAt = time.Now().Add(-2 * time.Hour)
+	store.CreatePayment(old)
+	fake.mu.Lock()
+	fake.expireAt["paywall:payment:old"] = time.Now()
+	fake.zsets["paywall:pending"]["old"] = float64(time.Now().Add(-time.Second).UnixMilli())
+	fake.mu.Unlock()
+	if pending, err := store.ListPendingPayments(); err != nil || len(pending) != 0 {
+		t.Errorf("ListPendingPayments() = %d payments, %v, want none", len(pending), err)
+	}
+	fake.mu.Lock()
+	_, indexed := fake.zsets["paywall:pending"]["old"]
+	fake.mu.Unlock()
+	if indexed {
+		t.Error("expired payment still in the pending index")
+	}
+}
+
+func TestRedisStore_StreamPayments(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	total := redisScanCount + 20
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("p%04d", i)
+		if i%2 == 1 {
+			id = "shop_" + id
+		}
+		payment := newSQLTestPayment(id, "addr"+id)
+		if i%3 == 0 {
+			payment.Status = StatusConfirmed
+			payment.Confirmations = 1
+		}
+		if err := store.CreatePayment(payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(filter PaymentFilter) int {
+		n := 0
+		if err := store.StreamPayments(filter, func(*Payment) error { n++; return nil }); err != nil {
+			t.Fatalf("StreamPayments(%+v) error = %v", filter, err)
+		}
+		return n
+	}
+	if got := count(PaymentFilter{}); got != total {
+		t.Errorf("all payments = %d, want %d", got, total)
+	}
+	if got := count(PaymentFilter{Partition: "shop"}); got != total/2 {
+		t.Errorf("partition payments = %d, want %d", got, total/2)
+	}
+	if got := count(PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}}); got != (total+2)/3 {
+		t.Errorf("confirmed payments = %d, want %d", got, (total+2)/3)
+	}
+
+	n := 0
+	store.StreamPayments(PaymentFilter{}, func(*Payment) error {
+		n++
+		if n == 3 {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if n != 3 {
+		t.Errorf("stream visited %d payments after ErrStopStream, want 3", n)
+	}
+
+	pending, err := store.ListPendingPayments()
+	if err != nil || len(pending) != total-(total+2)/3 {
+		t.Errorf("ListPendingPayments() = %d payments, %v", len(pending), err)
+	}
+	all, err := store.ListPayments()
+	if err != nil || len(all) != total {
+		t.Errorf("ListPayments() = %d payments, %v", len(all), err)
+	}
+}
+
+func TestRedisStore_LockPayment(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	unlock, err := store.LockPayment(context.Background(), "pay1")
+	if err != nil {
+		t.Fatalf("LockPayment() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	if _, err := store.LockPayment(ctx, "pay1"); !errors.Is(err, ErrPaymentLocked) {
+		t.Errorf("second LockPayment() error = %v, want ErrPaymentLocked", err)
+	}
+	if other, err := store.LockPayment(context.Background(), "pay2"); err != nil {
+		t.Errorf("LockPayment(other payment) error = %v", err)
+	} else {
+		other()
+	}
+
+	unlock()
+	unlock()
+	again, err := store.LockPayment(context.Background(), "pay1")
+	if err != nil {
+		t.Fatalf("LockPayment() after unlock error = %v", err)
+	}
+	again()
+}
+
+func TestNewRedisStore_Config(t *testing.T) {
+	fake := newFakeRedis(t, "secret")
+	tests := []struct {
+		name   string
+		config RedisStoreConfig
+	}{
+		{"missing address", RedisStoreConfig{}},
+		{"wrong password", RedisStoreConfig{Addr: fake.addr(), Password: "wrong"}},
+		{"no password", RedisStoreConfig{Addr: fake.addr()}},
+		{"negative retention", RedisStoreConfig{Addr: fake.addr(), Password: "secret", Retention: -time.Hour}},
+	}
+	for _, tt := range tests {
+		if _, err := NewRedisStore(tt.config); err == nil {
+			t.Errorf("%s: NewRedisStore() succeeded", tt.name)
+		}
+	}
+}
+
+func TestRedisStore_Paywall(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          store,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+	if pw.locker != store {
+		t.Errorf("payment locker = %T, want the RedisStore", pw.locker)
+	}
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	byAddress, err := store.GetPaymentByAddress(payment.Addresses[wallet.Bitcoin])
+	if err != nil || byAddress == nil || byAddress.ID != payment.ID {
+		t.Errorf("GetPaymentByAddress() = %+v, %v", byAddress, err)
+	}
+}
@@ -0,0 +1,616 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// defaultOverpaymentTolerance is the default RefundConfig.Tolerance
+const defaultOverpaymentTolerance = 0.01
+
+var (
+	// ErrInvalidRefundAddress is returned for refund addresses that do not
+	// belong to one of the payment's currencies
+	ErrInvalidRefundAddress = errors.New("invalid refund address")
+	// ErrNoOverpayment is returned for payments without an open overpayment
+	ErrNoOverpayment = errors.New("payment has no open overpayment")
+	// ErrRefundUnavailable is returned when an overpayment cannot be refunded
+	// automatically: no RefundSigner for its currency or no refund address
+	ErrRefundUnavailable = errors.New("refund not available")
+	// ErrRefundAddressForbidden is returned by HandleRefundAddress for requests
+	// that carry neither the payment cookie nor the payment page's nonce
+	ErrRefundAddressForbidden = errors.New("not allowed to set the refund address")
+)
+
+// RefundStatus is the state of an Overpayment
+type RefundStatus string
+
+const (
+	// RefundReview marks overpayments waiting for the operator
+	RefundReview RefundStatus = "review"
+	// RefundSent marks overpayments whose excess was sent back
+	RefundSent RefundStatus = "refunded"
+	// RefundFailed marks overpayments whose automatic refund failed; they
+	// wait for the operator like RefundReview
+	RefundFailed RefundStatus = "failed"
+	// RefundResolved marks overpayments the operator closed without a refund
+	// through the paywall, e.g. after refunding by hand
+	RefundResolved RefundStatus = "resolved"
+)
+
+// Overpayment records funds a payment received beyond its price
+type Overpayment struct {
+	// Currency is the currency the payment was paid in
+	Currency wallet.WalletType `json:"currency"`
+	// Required is the price asked in Currency
+	Required float64 `json:"required"`
+	// Received is the amount seen when the payment was confirmed
+	Received float64 `json:"received"`
+	// Excess is Received minus Required, the amount to refund
+	Excess float64 `json:"excess"`
+	// DetectedAt is when the overpayment was recorded
+	DetectedAt time.Time `json:"detected_at"`
+	// Status is the state of the refund
+	Status RefundStatus `json:"status"`
+	// RefundTxID is the refund transaction, set with RefundSent
+	RefundTxID string `json:"refund_txid,omitempty"`
+	// RefundedAt is when the refund was sent
+	RefundedAt time.Time `json:"refunded_at,omitempty"`
+	// Error is why the last automatic refund failed
+	Error string `json:"error,omitempty"`
+}
+
+// RefundSigner constructs, signs and broadcasts refund transactions.
+// *BTCBroadcaster implements it for Bitcoin with the node's wallet.
+type RefundSigner interface {
+	// Refund sends amount of currency to address
+	//
+	// Returns:
+	//   - string: The refund's transaction ID
+	//   - error: If the refund was not sent
+	Refund(currency wallet.WalletType, address string, amount float64) (string, error)
+}
+
+// RefundConfig configures the RefundManager, see Config.Refunds
+type RefundConfig struct {
+	// AddressPath is where HandleRefundAddress is mounted (e.g. "/paywall/refund-address").
+	// Optional: when set, the payment page offers a form for the buyer's refund address.
+	AddressPath string
+	// Tolerance is the excess, as a fraction of the price, that does not count
+	// as an overpayment, e.g. 0.01 ignores up to 1% extra.
+	// Optional: defaults to 0.01; negative values count any excess.
+	Tolerance float64
+	// Signers refund overpayments automatically, per currency, when the buyer
+	// left a refund address. Overpayments in other currencies, or without a
+	// refund address, wait for review.
+	// Optional: signers can also be added later with RefundManager.SetSigner,
+	// e.g. the GetBTCBroadcaster of Config.BTCRPCHost.
+	Signers map[wallet.WalletType]RefundSigner
+}
+
+// validateRefundConfig checks Config.Refunds
+func validateRefundConfig(config *RefundConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Tolerance >= 1 {
+		return fmt.Errorf("Refunds.Tolerance must be below 1, got: %g (hint: 0.01 ignores up to 1%% extra)", config.Tolerance)
+	}
+	if config.AddressPath != "" && !strings.HasPrefix(config.AddressPath, "/") {
+		return fmt.Errorf("Refunds.AddressPath %q must start with / (hint: \"/paywall/refund-address\")", config.AddressPath)
+	}
+	for currency, signer := range config.Signers {
+		if signer == nil {
+			return fmt.Errorf("Refunds.Signers[%s] is nil (hint: remove the entry to review %s overpayments by hand)", currency, currency)
+		}
+	}
+	return nil
+}
+
+// RefundManager detects overpayments and refunds them. The monitor hands it
+// every confirmed payment; payments that received more than their price plus
+// RefundConfig.Tolerance get a Payment.Overpayment. When a RefundSigner is
+// configured for the currency and the buyer left a refund address, the excess
+// is refunded right away; otherwise the overpayment waits for review
+// (ListOverpayments, RefundOverpayment, ResolveOverpayment).
+//
+// Overpayments are published as EventPaymentOverpaid and refunds as
+// EventPaymentRefunded.
+type RefundManager struct {
+	paywall     *Paywall
+	addressPath string
+	tolerance   float64
+	testNet     bool
+
+	mu      sync.RWMutex
+	signers map[wallet.WalletType]RefundSigner
+}
+
+// newRefundManager creates the RefundManager of Config.Refunds
+func newRefundManager(p *Paywall, config RefundConfig, testNet bool) *RefundManager {
+	tolerance := config.Tolerance
+	if tolerance == 0 {
+		tolerance = defaultOverpaymentTolerance
+	}
+	signers := make(map[wallet.WalletType]RefundSigner, len(config.Signers))
+	for currency, signer := range config.Signers {
+		signers[currency] = signer
+	}
+	return &RefundManager{
+		paywall:     p,
+		addressPath: config.AddressPath,
+		tolerance:   max(tolerance, 0),
+		testNet:     testNet,
+		signers:     signers,
+	}
+}
+
+// GetRefundManager returns the RefundManager, nil unless Config.Refunds is set
+func (p *Paywall) GetRefundManager() *RefundManager {
+	return p.refunds
+}
+
+// SetSigner refunds overpayments in currency with signer from now on; a nil
+// signer leaves them for review
+func (m *RefundManager) SetSigner(currency wallet.WalletType, signer RefundSigner) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if signer == nil {
+		delete(m.signers, currency)
+		return
+	}
+	m.signers[currency] = signer
+}
+
+// signer returns the RefundSigner of currency, nil if there is none
+func (m *RefundManager) signer(currency wallet.WalletType) RefundSigner {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.signers[currency]
+}
+
+// checkOverpayment records an overpayment of a payment the monitor just
+// confirmed, and refunds it when possible. The caller holds the payment's
+// lock.
+func (m *RefundManager) checkOverpayment(payment *Payment, currency wallet.WalletType, received float64) {
+	required := payment.Amounts[currency]
+	if payment.Overpayment != nil || required <= 0 || received <= required*(1+m.tolerance) {
+		return
+	}
+	payment.Overpayment = &Overpayment{
+		Currency:   currency,
+		Required:   required,
+		Received:   received,
+		Excess:     roundPrice(currency, received-required),
+		DetectedAt: time.Now(),
+		Status:     RefundReview,
+	}
+	refunded := m.refund(payment) == nil
+	if err := m.paywall.Store.UpdatePayment(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "overpayment_store_failed",
+			Message:   fmt.Sprintf("Failed to store overpayment of %.8f %s: %v", payment.Overpayment.Excess, currency, err),
+			PaymentID: payment.ID,
+			Currency:  currency,
+		})
+	}
+
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelWarn,
+		Event:     "payment_overpaid",
+		Message:   fmt.Sprintf("Payment overpaid by %.8f %s (refund %s)", payment.Overpayment.Excess, currency, payment.Overpayment.Status),
+		PaymentID: payment.ID,
+		Amount:    received,
+		Currency:  currency,
+	})
+	m.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentOverpaid,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data:      overpaymentEventData(payment.Overpayment),
+	})
+	if refunded {
+		m.announceRefund(payment)
+	}
+}
+
+// refund sends the excess of payment's overpayment back through the
+// currency's RefundSigner and records the outcome on the payment
+//
+// Returns:
+//   - error: ErrRefundUnavailable without a signer or refund address, or the
+//     signer's error (also recorded as RefundFailed)
+func (m *RefundManager) refund(payment *Payment) error {
+	overpayment := payment.Overpayment
+	signer := m.signer(overpayment.Currency)
+	address := payment.RefundAddresses[overpayment.Currency]
+	if signer == nil || address == "" {
+		return fmt.Errorf("%w: %s needs a refund signer and a refund address", ErrRefundUnavailable, overpayment.Currency)
+	}
+	txID, err := signer.Refund(overpayment.Currency, address, overpayment.Excess)
+	if err != nil {
+		overpayment.Status = RefundFailed
+		overpayment.Error = err.Error()
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "refund_failed",
+			Message:   fmt.Sprintf("Failed to refund %.8f %s: %v", overpayment.Excess, overpayment.Currency, err),
+			PaymentID: payment.ID,
+			Currency:  overpayment.Currency,
+		})
+		return fmt.Errorf("refund %s: %w", overpayment.Currency, err)
+	}
+	overpayment.Status = RefundSent
+	overpayment.RefundTxID = txID
+	overpayment.RefundedAt = time.Now()
+	overpayment.Error = ""
+	return nil
+}
+
+// announceRefund logs and publishes a sent refund
+func (m *RefundManager) announceRefund(payment *Payment) {
+	overpayment := payment.Overpayment
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "payment_refunded",
+		Message:   fmt.Sprintf("Refunded %.8f %s in transaction %s", overpayment.Excess, overpayment.Currency, overpayment.RefundTxID),
+		PaymentID: payment.ID,
+		Amount:    overpayment.Excess,
+		Currency:  overpayment.Currency,
+	})
+	m.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentRefunded,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data:      overpaymentEventData(overpayment),
+	})
+}
+
+// overpaymentEventData is the Data of EventPaymentOverpaid and EventPaymentRefunded
+func overpaymentEventData(overpayment *Overpayment) map[string]interface{} {
+	return map[string]interface{}{
+		"currency":    overpayment.Currency,
+		"required":    overpayment.Required,
+		"received":    overpayment.Received,
+		"excess":      overpayment.Excess,
+		"status":      overpayment.Status,
+		"refund_txid": overpayment.RefundTxID,
+	}
+}
+
+// ListOverpayments returns the overpaid payments in status, oldest first. An
+// empty status returns every overpaid payment; RefundReview also returns
+// RefundFailed ones, as both wait for the operator.
+//
+// Returns:
+//   - []*Payment: Matching payments
+//   - error: If the store implements neither PaymentStreamer nor PaymentLister, or listing fails
+func (m *RefundManager) ListOverpayments(status RefundStatus) ([]*Payment, error) {
+	var payments []*Payment
+	err := StreamPayments(m.paywall.Store, PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}}, func(payment *Payment) error {
+		if payment.Overpayment == nil {
+			return nil
+		}
+		switch {
+		case status == "", payment.Overpayment.Status == status:
+		case status == RefundReview && payment.Overpayment.Status == RefundFailed:
+		default:
+			return nil
+		}
+		payments = append(payments, payment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream payments: %w", err)
+	}
+	slices.SortFunc(payments, func(a, b *Payment) int {
+		return a.Overpayment.DetectedAt.Compare(b.Overpayment.DetectedAt)
+	})
+	return payments, nil
+}
+
+// RefundOverpayment refunds an overpayment waiting for review, e.g. after the
+// buyer left a refund address or a signer was added with SetSigner.
+//
+// Parameters:
+//   - paymentID: ID of the overpaid payment
+//
+// Returns:
+//   - *Payment: The updated payment
+//   - error: ErrProofPaymentNotFound, ErrNoOverpayment, ErrRefundUnavailable,
+//     ErrPaymentLocked, the signer's error or storage errors
+func (m *RefundManager) RefundOverpayment(paymentID string) (*Payment, error) {
+	return m.updateOverpayment(paymentID, func(payment *Payment) error {
+		if err := m.refund(payment); errors.Is(err, ErrRefundUnavailable) {
+			return err
+		} else if err != nil {
+			// Store the failure, then report it
+			if storeErr := m.paywall.Store.UpdatePayment(payment); storeErr != nil {
+				return fmt.Errorf("update payment: %w", storeErr)
+			}
+			return err
+		}
+		m.announceRefund(payment)
+		return nil
+	})
+}
+
+// ResolveOverpayment closes an overpayment waiting for review without a
+// refund through the paywall, e.g. after refunding by hand. Record the
+// details with AnnotatePayment.
+//
+// Returns:
+//   - *Payment: The updated payment
+//   - error: ErrProofPaymentNotFound, ErrNoOverpayment, ErrPaymentLocked or storage errors
+func (m *RefundManager) ResolveOverpayment(paymentID string) (*Payment, error) {
+	return m.updateOverpayment(paymentID, func(payment *Payment) error {
+		payment.Overpayment.Status = RefundResolved
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelInfo,
+			Event:     "overpayment_resolved",
+			Message:   fmt.Sprintf("Overpayment of %.8f %s resolved", payment.Overpayment.Excess, payment.Overpayment.Currency),
+			PaymentID: payment.ID,
+			Currency:  payment.Overpayment.Currency,
+		})
+		return nil
+	})
+}
+
+// updateOverpayment applies change to the open overpayment of a payment
+// under the payment's lock and stores the result
+func (m *RefundManager) updateOverpayment(paymentID string, change func(payment *Payment) error) (*Payment, error) {
+	unlock, err := m.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := m.paywall.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if payment.Overpayment == nil || (payment.Overpayment.Status != RefundReview && payment.Overpayment.Status != RefundFailed) {
+		return nil, ErrNoOverpayment
+	}
+	if err := change(payment); err != nil {
+		return nil, err
+	}
+	if err := m.paywall.Store.UpdatePayment(payment); err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	return payment, nil
+}
+
+// SetRefundAddress stores the buyer's refund address for one of a payment's
+// currencies. Overpayments in that currency are refunded to it. Addresses can
+// be changed until a refund was sent; Lightning payments have no refund
+// address.
+//
+// SetRefundAddress trusts its caller: use it for buyers the application has
+// authenticated itself. HandleRefundAddress checks that requests come from the
+// buyer.
+//
+// Parameters:
+//   - paymentID: ID of the payment
+//   - currency: One of the payment's currencies
+//   - address: The buyer's address in currency, checked with wallet.ValidateAddress
+//
+// Returns:
+//   - *Payment: The updated payment
+//   - error: ErrInvalidRefundAddress, ErrProofPaymentNotFound, ErrPaymentLocked,
+//     or storage errors
+//
+// Related: HandleRefundAddress
+func (m *RefundManager) SetRefundAddress(paymentID string, currency wallet.WalletType, address string) (*Payment, error) {
+	return m.setRefundAddress(paymentID, currency, address, nil)
+}
+
+// setRefundAddress implements SetRefundAddress. authorize, if not nil, is
+// called with the locked payment before it is changed and aborts with its error.
+func (m *RefundManager) setRefundAddress(paymentID string, currency wallet.WalletType, address string, authorize func(*Payment) error) (*Payment, error) {
+	address = strings.TrimSpace(address)
+	if currency == wallet.Lightning {
+		return nil, fmt.Errorf("%w: Lightning payments are refunded with a new invoice, not to an address", ErrInvalidRefundAddress)
+	}
+	if err := wallet.ValidateAddress(currency, address); err != nil || address == "" {
+		return nil, fmt.Errorf("%w: not a %s address", ErrInvalidRefundAddress, currency)
+	}
+	if currency == wallet.Bitcoin {
+		if _, network := wallet.IsBitcoinAddress(address); (network == "mainnet") == m.testNet {
+			return nil, fmt.Errorf("%w: %s address for the wrong network", ErrInvalidRefundAddress, network)
+		}
+	}
+
+	unlock, err := m.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment, err := m.paywall.Store.GetPayment(paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrProofPaymentNotFound
+	}
+	if authorize != nil {
+		if err := authorize(payment); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := payment.Addresses[currency]; !ok {
+		return nil, fmt.Errorf("%w: the payment does not accept %s", ErrInvalidRefundAddress, currency)
+	}
+	if payment.Overpayment != nil && payment.Overpayment.Status == RefundSent {
+		return nil, fmt.Errorf("%w: the refund was already sent", ErrInvalidRefundAddress)
+	}
+	if payment.RefundAddresses == nil {
+		payment.RefundAddresses = make(map[wallet.WalletType]string)
+	}
+	payment.RefundAddresses[currency] = address
+	if err := m.paywall.Store.UpdatePayment(payment); err != nil {
+		return nil, fmt.Errorf("update payment: %w", err)
+	}
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "refund_address_set",
+		Message:   fmt.Sprintf("Refund address set for %s", currency),
+		PaymentID: paymentID,
+		Currency:  currency,
+	})
+	return payment, nil
+}
+
+// RefundAddressRequest is the JSON body accepted by HandleRefundAddress
+type RefundAddressRequest struct {
+	// PaymentID selects the payment; HandleRefundAddress falls back to the payment cookie
+	PaymentID string `json:"payment_id,omitempty"`
+	// Currency is the currency the address is for, e.g. "BTC"
+	Currency wallet.WalletType `json:"currency"`
+	// Address is the buyer's refund address
+	Address string `json:"address"`
+	// Page is the payment page's signed nonce (PaymentPageData.PageNonce); it
+	// authorizes requests that do not carry the payment cookie
+	Page string `json:"page,omitempty"`
+}
+
+// refundAddressResponse is the JSON body returned by HandleRefundAddress
+type refundAddressResponse struct {
+	PaymentID       string                       `json:"payment_id"`
+	RefundAddresses map[wallet.WalletType]string `json:"refund_addresses"`
+}
+
+// HandleRefundAddress lets buyers leave a refund address for their payment.
+// Mount it at RefundConfig.AddressPath to enable the payment page form.
+//
+// Requests are POSTs with a JSON RefundAddressRequest or the form fields
+// "currency", "address" and optionally "payment_id" and "page". The payment is
+// taken from payment_id or else the payment cookie. Successful form posts
+// redirect back to the referring page; JSON requests get {"payment_id",
+// "refund_addresses"}.
+//
+// Refunds are paid to this address, so knowing the payment ID is not enough to
+// set it: the request must carry the payment's cookie or the signed nonce of a
+// payment page rendered for it ("page"), which only the buyer has. Clients
+// without either should let the application authenticate the buyer and call
+// RefundManager.SetRefundAddress.
+//
+// Responses:
+//   - 200 with the payment's refund addresses, or 303 back to the payment page for form posts
+//   - 400 Bad Request for missing payment IDs or invalid addresses
+//   - 403 Forbidden without the payment's cookie or page nonce
+//   - 404 Not Found if the payment does not exist or Config.Refunds is not set
+//   - 405 Method Not Allowed for non-POST requests
+//   - 408, 413 or 415 for bodies that arrive too slowly, exceed
+//     Config.MaxRequestBodyBytes or have an unsupported Content-Type
+//   - 429 Too Many Requests (with Retry-After) beyond Config.RateLimits
+//   - 503 Service Unavailable if another operation holds the payment's lock
+func (p *Paywall) HandleRefundAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if p.refunds == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitSubmit) {
+		return
+	}
+
+	var req RefundAddressRequest
+	form, ok := decodeRequestBody(w, r, p.requestLimits(), &req, true)
+	if !ok {
+		return
+	}
+	isForm := form != nil
+	if isForm {
+		req.PaymentID = form.Get("payment_id")
+		req.Currency = wallet.WalletType(form.Get("currency"))
+		req.Address = form.Get("address")
+		req.Page = form.Get("page")
+	}
+	cookieID, _ := paymentIDFromCookie(r)
+	if req.PaymentID == "" {
+		if cookieID == "" {
+			http.Error(w, "payment_id is required", http.StatusBadRequest)
+			return
+		}
+		req.PaymentID = cookieID
+	}
+
+	payment, err := p.refunds.setRefundAddress(req.PaymentID, req.Currency, req.Address, func(payment *Payment) error {
+		if payment.ID == cookieID || p.pageNonceValid(req.Page, payment) {
+			return nil
+		}
+		return ErrRefundAddressForbidden
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrInvalidRefundAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrRefundAddressForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case errors.Is(err, ErrProofPaymentNotFound):
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrPaymentLocked):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Payment is being updated, please try again", http.StatusServiceUnavailable)
+		return
+	default:
+		http.Error(w, "Failed to store refund address", http.StatusInternalServerError)
+		return
+	}
+
+	if isForm {
+		if back := sameOriginReferer(r); back != "" {
+			http.Redirect(w, r, back, http.StatusSeeOther)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(refundAddressResponse{PaymentID: payment.ID, RefundAddresses: payment.RefundAddresses}); err != nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "response_encoding_failed",
+			Message:   fmt.Sprintf("Failed to encode refund address response: %v", err),
+			PaymentID: payment.ID,
+		})
+	}
+}
+
+// refundCurrencies lists the currencies shown on a payment page that accept a
+// refund address
+func refundCurrencies(data *PaymentPageData) []wallet.WalletType {
+	var currencies []wallet.WalletType
+	if data.BTCAddress != "" {
+		currencies = append(currencies, wallet.Bitcoin)
+	}
+	if data.XMRAddress != "" {
+		currencies = append(currencies, wallet.Monero)
+	}
+	if data.ETHAddress != "" {
+		currencies = append(currencies, wallet.Ethereum)
+	}
+	for _, option := range append(slices.Clone(data.Tokens), data.Coins...) {
+		currencies = append(currencies, option.Currency)
+	}
+	return currencies
+}
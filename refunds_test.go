@@ -0,0 +1,219 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// testRefundAddress is a testnet P2PKH address
+const testRefundAddress = "mkpZhYtJu2r87Js3pDiWJDmPte2NRZ8bJV"
+
+// fakeRefundSigner records refunds and answers with txID or err
+type fakeRefundSigner struct {
+	refunds []float64
+	err     error
+}
+
+func (f *fakeRefundSigner) Refund(currency wallet.WalletType, address string, amount float64) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.refunds = append(f.refunds, amount)
+	return "refund-tx", nil
+}
+
+// payAndConfirm has the monitor see received BTC at payment's address
+func payAndConfirm(t *testing.T, pw *Paywall, payment *Payment, received float64) *Payment {
+	t.Helper()
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin: &mockCryptoClient{balance: received},
+	}}
+	monitor.checkPayment(payment)
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed {
+		t.Fatalf("status = %s, want confirmed", stored.Status)
+	}
+	return stored
+}
+
+func TestRefunds_Overpayment(t *testing.T) {
+	tests := []struct {
+		name          string
+		received      float64
+		refundAddress bool
+		signerErr     error
+		wantStatus    RefundStatus // empty for no overpayment
+		wantRefunds   int
+	}{
+		{name: "exact", received: 0.001},
+		{name: "within tolerance", received: 0.00101, refundAddress: true},
+		{name: "no refund address", received: 0.0015, wantStatus: RefundReview},
+		{name: "refunded", received: 0.0015, refundAddress: true, wantStatus: RefundSent, wantRefunds: 1},
+		{name: "signer fails", received: 0.0015, refundAddress: true, signerErr: errors.New("wallet locked"), wantStatus: RefundFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &fakeRefundSigner{err: tt.signerErr}
+			pw := newTestPaywall(t, Config{Refunds: &RefundConfig{Signers: map[wallet.WalletType]RefundSigner{wallet.Bitcoin: signer}}})
+			payment, err := pw.CreatePayment()
+			if err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			if tt.refundAddress {
+				if payment, err = pw.GetRefundManager().SetRefundAddress(payment.ID, wallet.Bitcoin, testRefundAddress); err != nil {
+					t.Fatalf("SetRefundAddress() error = %v", err)
+				}
+			}
+
+			stored := payAndConfirm(t, pw, payment, tt.received)
+			if tt.wantStatus == "" {
+				if stored.Overpayment != nil {
+					t.Errorf("overpayment = %+v, want none", stored.Overpayment)
+				}
+				return
+			}
+			if stored.Overpayment == nil || stored.Overpayment.Status != tt.wantStatus || stored.Overpayment.Excess != 0.0005 {
+				t.Fatalf("overpayment = %+v, want %s of 0.0005", stored.Overpayment, tt.wantStatus)
+			}
+			if len(signer.refunds) != tt.wantRefunds {
+				t.Errorf("refunds sent = %v, want %d", signer.refunds, tt.wantRefunds)
+			}
+			if tt.wantStatus == RefundSent && stored.Overpayment.RefundTxID != "refund-tx" {
+				t.Errorf("refund txid = %q, want refund-tx", stored.Overpayment.RefundTxID)
+			}
+		})
+	}
+}
+
+func TestRefunds_Review(t *testing.T) {
+	pw := newTestPaywall(t, Config{Refunds: &RefundConfig{}})
+	refunds := pw.GetRefundManager()
+	payment, _ := pw.CreatePayment()
+	payAndConfirm(t, pw, payment, 0.002)
+
+	review, err := refunds.ListOverpayments(RefundReview)
+	if err != nil || len(review) != 1 || review[0].ID != payment.ID {
+		t.Fatalf("ListOverpayments(review) = %v, %v, want the payment", review, err)
+	}
+	if _, err := refunds.RefundOverpayment(payment.ID); !errors.Is(err, ErrRefundUnavailable) {
+		t.Errorf("RefundOverpayment() without signer error = %v, want ErrRefundUnavailable", err)
+	}
+
+	// The buyer leaves an address after paying, the operator adds a signer
+	if _, err := refunds.SetRefundAddress(payment.ID, wallet.Bitcoin, testRefundAddress); err != nil {
+		t.Fatalf("SetRefundAddress() error = %v", err)
+	}
+	signer := &fakeRefundSigner{}
+	refunds.SetSigner(wallet.Bitcoin, signer)
+	refunded, err := refunds.RefundOverpayment(payment.ID)
+	if err != nil || refunded.Overpayment.Status != RefundSent || len(signer.refunds) != 1 || signer.refunds[0] != 0.001 {
+		t.Fatalf("RefundOverpayment() = %+v, %v, refunds %v, want 0.001 refunded", refunded, err, signer.refunds)
+	}
+	if _, err := refunds.RefundOverpayment(payment.ID); !errors.Is(err, ErrNoOverpayment) {
+		t.Errorf("second RefundOverpayment() error = %v, want ErrNoOverpayment", err)
+	}
+	if _, err := refunds.ResolveOverpayment(payment.ID); !errors.Is(err, ErrNoOverpayment) {
+		t.Errorf("ResolveOverpayment() after refund error = %v, want ErrNoOverpayment", err)
+	}
+	if _, err := refunds.SetRefundAddress(payment.ID, wallet.Bitcoin, testRefundAddress); !errors.Is(err, ErrInvalidRefundAddress) {
+		t.Errorf("SetRefundAddress() after refund error = %v, want ErrInvalidRefundAddress", err)
+	}
+	if all, _ := refunds.ListOverpayments(""); len(all) != 1 {
+		t.Errorf("ListOverpayments(\"\") = %d payments, want 1", len(all))
+	}
+	if open, _ := refunds.ListOverpayments(RefundReview); len(open) != 0 {
+		t.Errorf("ListOverpayments(review) after refund = %d payments, want 0", len(open))
+	}
+}
+
+func TestHandleRefundAddress(t *testing.T) {
+	pw := newTestPaywall(t, Config{Refunds: &RefundConfig{AddressPath: "/paywall/refund-address"}, DisableRateLimits: true})
+	payment, _ := pw.CreatePayment()
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/", nil), payment)
+	if !strings.Contains(rec.Body.String(), `action="/paywall/refund-address"`) {
+		t.Error("payment page has no refund address form")
+	}
+	nonce := pw.pageNonce(payment, time.Now())
+	other, _ := pw.CreatePayment()
+
+	tests := []struct {
+		name       string
+		body       string
+		form       bool
+		cookie     string
+		wantStatus int
+	}{
+		{"form with page nonce", url.Values{"payment_id": {payment.ID}, "page": {nonce}, "currency": {"BTC"}, "address": {testRefundAddress}}.Encode(), true, "", http.StatusSeeOther},
+		{"json with cookie", `{"currency": "BTC", "address": "` + testRefundAddress + `"}`, false, payment.ID, http.StatusOK},
+		{"json with page nonce", `{"payment_id": "` + payment.ID + `", "page": "` + nonce + `", "currency": "BTC", "address": "` + testRefundAddress + `"}`, false, "", http.StatusOK},
+		{"payment ID only", `{"payment_id": "` + payment.ID + `", "currency": "BTC", "address": "` + testRefundAddress + `"}`, false, "", http.StatusForbidden},
+		{"cookie of another payment", `{"payment_id": "` + payment.ID + `", "currency": "BTC", "address": "` + testRefundAddress + `"}`, false, other.ID, http.StatusForbidden},
+		{"page nonce of another payment", `{"payment_id": "` + other.ID + `", "page": "` + nonce + `", "currency": "BTC", "address": "` + testRefundAddress + `"}`, false, "", http.StatusForbidden},
+		{"no payment", `{"currency": "BTC", "address": "` + testRefundAddress + `"}`, false, "", http.StatusBadRequest},
+		{"mainnet address", `{"currency": "BTC", "address": "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"}`, false, payment.ID, http.StatusBadRequest},
+		{"malformed address", `{"currency": "BTC", "address": "not-an-address"}`, false, payment.ID, http.StatusBadRequest},
+		{"currency not offered", `{"currency": "XMR", "address": "` + testRefundAddress + `"}`, false, payment.ID, http.StatusBadRequest},
+		{"lightning", `{"currency": "LN", "address": "lnbc1"}`, false, payment.ID, http.StatusBadRequest},
+		{"unknown payment", `{"payment_id": "missing", "currency": "BTC", "address": "` + testRefundAddress + `"}`, false, "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/paywall/refund-address", strings.NewReader(tt.body))
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: tt.cookie})
+			}
+			if tt.form {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.Header.Set("Referer", "http://example.com/article")
+				req.Host = "example.com"
+			}
+			rec := httptest.NewRecorder()
+			pw.HandleRefundAddress(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.RefundAddresses[wallet.Bitcoin] != testRefundAddress {
+		t.Errorf("refund addresses = %v, want the BTC address", stored.RefundAddresses)
+	}
+	if stored, _ := pw.Store.GetPayment(other.ID); len(stored.RefundAddresses) != 0 {
+		t.Errorf("refund addresses of the other payment = %v, want none", stored.RefundAddresses)
+	}
+	rec = httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/", nil), stored)
+	if !strings.Contains(rec.Body.String(), testRefundAddress) {
+		t.Error("payment page does not show the saved refund address")
+	}
+
+	disabled := newTestPaywall(t, Config{})
+	rec = httptest.NewRecorder()
+	disabled.HandleRefundAddress(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status without Config.Refunds = %d, want 404", rec.Code)
+	}
+}
+
+func TestRefunds_Config(t *testing.T) {
+	for _, refunds := range []*RefundConfig{
+		{Tolerance: 1},
+		{AddressPath: "paywall/refund-address"},
+		{Signers: map[wallet.WalletType]RefundSigner{wallet.Bitcoin: nil}},
+	} {
+		_, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), Refunds: refunds})
+		if err == nil || !strings.Contains(err.Error(), "Refunds.") {
+			t.Errorf("NewPaywall(Refunds: %+v) error = %v, want a Refunds error", refunds, err)
+		}
+	}
+}
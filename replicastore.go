@@ -0,0 +1,254 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// replicaRetryAfter is how long a failing replica is skipped before it is tried again
+const replicaRetryAfter = 30 * time.Second
+
+// ReplicatedStore splits reads and writes between two stores, typically the
+// primary and a read replica of the same database. Listing queries (the
+// monitor's queue, reports and exports via ListPayments, escrow
+// timeout scans, API key and stats listings) go to the replica, so heavy reads do not load
+// the primary. Everything that precedes or performs a mutation - CreatePayment,
+// GetPayment, GetPaymentByAddress, UpdatePayment, API key reads and writes and
+// payment locks - goes to the primary.
+//
+// When the replica fails, the read is retried on the primary and the replica is
+// skipped for 30 seconds. Payments listed from a lagging replica may be stale;
+// updating one fails with ErrVersionConflict rather than overwriting newer data,
+// and the monitor re-reads each payment from the primary before checking it.
+//
+// Usage:
+//
+//	store := paywall.NewReplicatedStore(primaryStore, replicaStore)
+//	pw, err := paywall.NewPaywall(paywall.Config{Store: store, ...})
+type ReplicatedStore struct {
+	primary PaymentStore
+	replica PaymentStore
+
+	// replicaDownUntil is the UnixNano time until which reads skip the replica
+	replicaDownUntil atomic.Int64
+	// fallbacks counts reads served by the primary because the replica failed
+	fallbacks atomic.Uint64
+}
+
+// NewReplicatedStore creates a store that writes to primary and lists from replica.
+//
+// Parameters:
+//   - primary: Store receiving all writes and point reads
+//   - replica: Store serving listings; nil serves everything from primary
+//
+// Returns:
+//   - *ReplicatedStore: The combined store
+func NewReplicatedStore(primary, replica PaymentStore) *ReplicatedStore {
+	if replica == nil {
+		replica = primary
+	}
+	return &ReplicatedStore{primary: primary, replica: replica}
+}
+
+// ReplicaFallbacks returns how many reads fell back to the primary because the replica failed
+func (s *ReplicatedStore) ReplicaFallbacks() uint64 {
+	return s.fallbacks.Load()
+}
+
+// readFromReplica runs read against the replica, falling back to the primary
+// when the replica fails or recently failed
+func readFromReplica[T any](s *ReplicatedStore, read func(PaymentStore) (T, error)) (T, error) {
+	if s.replica != s.primary && time.Now().UnixNano() >= s.replicaDownUntil.Load() {
+		result, err := read(s.replica)
+		if err == nil {
+			return result, nil
+		}
+		s.replicaDownUntil.Store(time.Now().Add(replicaRetryAfter).UnixNano())
+		s.fallbacks.Add(1)
+	}
+	return read(s.primary)
+}
+
+// CreatePayment stores a new payment on the primary
+func (s *ReplicatedStore) CreatePayment(payment *Payment) error {
+	return s.primary.CreatePayment(payment)
+}
+
+// GetPayment reads a payment from the primary, so callers about to update it see its latest version
+func (s *ReplicatedStore) GetPayment(id string) (*Payment, error) {
+	return s.primary.GetPayment(id)
+}
+
+// GetPaymentByAddress reads a payment by address from the primary
+func (s *ReplicatedStore) GetPaymentByAddress(address string) (*Payment, error) {
+	return s.primary.GetPaymentByAddress(address)
+}
+
+// UpdatePayment updates a payment on the primary
+func (s *ReplicatedStore) UpdatePayment(payment *Payment) error {
+	return s.primary.UpdatePayment(payment)
+}
+
+// CreatePaymentContext is CreatePayment with a context. Implements ContextPaymentStore.
+func (s *ReplicatedStore) CreatePaymentContext(ctx context.Context, payment *Payment) error {
+	return createPaymentContext(ctx, s.primary, payment)
+}
+
+// GetPaymentContext is GetPayment with a context. Implements ContextPaymentStore.
+func (s *ReplicatedStore) GetPaymentContext(ctx context.Context, id string) (*Payment, error) {
+	return getPaymentContext(ctx, s.primary, id)
+}
+
+// GetPaymentByAddressContext is GetPaymentByAddress with a context. Implements ContextPaymentStore.
+func (s *ReplicatedStore) GetPaymentByAddressContext(ctx context.Context, address string) (*Payment, error) {
+	return getPaymentByAddressContext(ctx, s.primary, address)
+}
+
+// UpdatePaymentContext is UpdatePayment with a context. Implements ContextPaymentStore.
+func (s *ReplicatedStore) UpdatePaymentContext(ctx context.Context, payment *Payment) error {
+	return updatePaymentContext(ctx, s.primary, payment)
+}
+
+// ListPendingPayments lists pending payments from the replica
+func (s *ReplicatedStore) ListPendingPayments() ([]*Payment, error) {
+	return readFromReplica(s, PaymentStore.ListPendingPayments)
+}
+
+// GetPendingMultisigPayments lists pending multisig payments from the replica
+func (s *ReplicatedStore) GetPendingMultisigPayments() ([]*Payment, error) {
+	return readFromReplica(s, PaymentStore.GetPendingMultisigPayments)
+}
+
+// GetEscrowsExpiringBefore lists expiring escrows from the replica
+func (s *ReplicatedStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
+	return readFromReplica(s, func(store PaymentStore) ([]*Payment, error) {
+		return store.GetEscrowsExpiringBefore(deadline)
+	})
+}
+
+// DeletePayment deletes a payment on the primary
+func (s *ReplicatedStore) DeletePayment(id string) error {
+	return s.primary.DeletePayment(id)
+}
+
+// ArchivePayments archives old payments on the primary
+func (s *ReplicatedStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return s.primary.ArchivePayments(olderThan)
+}
+
+// ListPayments lists every payment from the replica. Both stores must implement PaymentLister.
+func (s *ReplicatedStore) ListPayments() ([]*Payment, error) {
+	return readFromReplica(s, func(store PaymentStore) ([]*Payment, error) {
+		lister, ok := store.(PaymentLister)
+		if !ok {
+			return nil, fmt.Errorf("payment store %T does not support listing payments", store)
+		}
+		return lister.ListPayments()
+	})
+}
+
+// StreamPayments streams payments from the replica, falling back to the
+// primary when the replica fails before fn was called. Implements PaymentStreamer.
+func (s *ReplicatedStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	if s.replica != s.primary && time.Now().UnixNano() >= s.replicaDownUntil.Load() {
+		called := false
+		err := StreamPayments(s.replica, filter, func(payment *Payment) error {
+			called = true
+			return fn(payment)
+		})
+		if err == nil || called {
+			return err
+		}
+		s.replicaDownUntil.Store(time.Now().Add(replicaRetryAfter).UnixNano())
+		s.fallbacks.Add(1)
+	}
+	return StreamPayments(s.primary, filter, fn)
+}
+
+// SaveAPIKey stores an API key on the primary, which must implement APIKeyStore
+func (s *ReplicatedStore) SaveAPIKey(key *APIKey) error {
+	keys, err := apiKeyStoreOf(s.primary)
+	if err != nil {
+		return err
+	}
+	return keys.SaveAPIKey(key)
+}
+
+// GetAPIKey reads an API key from the primary, which must implement APIKeyStore
+func (s *ReplicatedStore) GetAPIKey(id string) (*APIKey, error) {
+	keys, err := apiKeyStoreOf(s.primary)
+	if err != nil {
+		return nil, err
+	}
+	return keys.GetAPIKey(id)
+}
+
+// ListAPIKeys lists API keys from the replica
+func (s *ReplicatedStore) ListAPIKeys() ([]*APIKey, error) {
+	return readFromReplica(s, func(store PaymentStore) ([]*APIKey, error) {
+		keys, err := apiKeyStoreOf(store)
+		if err != nil {
+			return nil, err
+		}
+		return keys.ListAPIKeys()
+	})
+}
+
+// SaveStatsSnapshot stores a stats snapshot on the primary, which must implement StatsStore
+func (s *ReplicatedStore) SaveStatsSnapshot(snapshot *StatsSnapshot) error {
+	stats, err := statsStoreOf(s.primary)
+	if err != nil {
+		return err
+	}
+	return stats.SaveStatsSnapshot(snapshot)
+}
+
+// ListStatsSnapshots lists stats snapshots from the replica
+func (s *ReplicatedStore) ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error) {
+	return readFromReplica(s, func(store PaymentStore) ([]*StatsSnapshot, error) {
+		stats, err := statsStoreOf(store)
+		if err != nil {
+			return nil, err
+		}
+		return stats.ListStatsSnapshots(from, to)
+	})
+}
+
+// DeleteStatsSnapshotsBefore deletes old stats snapshots on the primary
+func (s *ReplicatedStore) DeleteStatsSnapshotsBefore(t time.Time) error {
+	stats, err := statsStoreOf(s.primary)
+	if err != nil {
+		return err
+	}
+	return stats.DeleteStatsSnapshotsBefore(t)
+}
+
+// LockPayment locks the payment on the primary. When the primary does not
+// implement PaymentLocker, it returns immediately, as if no locker was configured.
+func (s *ReplicatedStore) LockPayment(ctx context.Context, paymentID string) (func(), error) {
+	locker, ok := s.primary.(PaymentLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	return locker.LockPayment(ctx, paymentID)
+}
+
+// apiKeyStoreOf returns store as an APIKeyStore
+func apiKeyStoreOf(store PaymentStore) (APIKeyStore, error) {
+	keys, ok := store.(APIKeyStore)
+	if !ok {
+		return nil, fmt.Errorf("payment store %T does not support API keys", store)
+	}
+	return keys, nil
+}
+
+// statsStoreOf returns store as a StatsStore
+func statsStoreOf(store PaymentStore) (StatsStore, error) {
+	stats, ok := store.(StatsStore)
+	if !ok {
+		return nil, fmt.Errorf("payment store %T does not support stats snapshots", store)
+	}
+	return stats, nil
+}
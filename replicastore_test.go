@@ -0,0 +1,109 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// failingReplica is a MemoryStore whose listings fail while down is set
+type failingReplica struct {
+	*MemoryStore
+	down  bool
+	calls int
+}
+
+func (r *failingReplica) ListPendingPayments() ([]*Payment, error) {
+	r.calls++
+	if r.down {
+		return nil, errors.New("replica unreachable")
+	}
+	return r.MemoryStore.ListPendingPayments()
+}
+
+func TestReplicatedStore_Routing(t *testing.T) {
+	primary := NewMemoryStore()
+	replica := NewMemoryStore()
+	store := NewReplicatedStore(primary, replica)
+
+	payment := &Payment{
+		ID:        "pay-1",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		CreatedAt: time.Now(),
+		Status:    StatusPending,
+	}
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if got, _ := primary.GetPayment("pay-1"); got == nil {
+		t.Fatal("CreatePayment() did not write to the primary")
+	}
+	if got, _ := replica.GetPayment("pay-1"); got != nil {
+		t.Fatal("CreatePayment() wrote to the replica")
+	}
+
+	// Point reads see the primary, listings see the replica
+	if got, _ := store.GetPayment("pay-1"); got == nil {
+		t.Error("GetPayment() did not read from the primary")
+	}
+	if got, _ := store.GetPaymentByAddress("btc-address"); got == nil {
+		t.Error("GetPaymentByAddress() did not read from the primary")
+	}
+	if got, _ := store.ListPendingPayments(); len(got) != 0 {
+		t.Errorf("ListPendingPayments() = %d payments, want the replica's 0", len(got))
+	}
+	replica.CreatePayment(&Payment{ID: "pay-1", CreatedAt: time.Now()})
+	if got, _ := store.ListPayments(); len(got) != 1 {
+		t.Errorf("ListPayments() = %d payments, want the replica's 1", len(got))
+	}
+
+	// Locks and API keys use the primary
+	if resolvePaymentLocker(Config{Store: store}) != store {
+		t.Error("ReplicatedStore should act as the payment locker")
+	}
+	unlock, err := store.LockPayment(context.Background(), "pay-1")
+	if err != nil {
+		t.Fatalf("LockPayment() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := primary.LockPayment(ctx, "pay-1"); !errors.Is(err, ErrPaymentLocked) {
+		t.Errorf("primary lock error = %v, want ErrPaymentLocked", err)
+	}
+	unlock()
+	if err := store.SaveAPIKey(&APIKey{ID: "key-1"}); err != nil {
+		t.Fatalf("SaveAPIKey() error = %v", err)
+	}
+	if key, _ := primary.GetAPIKey("key-1"); key == nil {
+		t.Error("SaveAPIKey() did not write to the primary")
+	}
+}
+
+func TestReplicatedStore_Fallback(t *testing.T) {
+	primary := NewMemoryStore()
+	replica := &failingReplica{MemoryStore: NewMemoryStore(), down: true}
+	store := NewReplicatedStore(primary, replica)
+	primary.CreatePayment(&Payment{ID: "pay-1", CreatedAt: time.Now()})
+
+	got, err := store.ListPendingPayments()
+	if err != nil || len(got) != 1 {
+		t.Fatalf("ListPendingPayments() = %d payments, %v; want the primary's 1", len(got), err)
+	}
+	if store.ReplicaFallbacks() != 1 {
+		t.Errorf("ReplicaFallbacks() = %d, want 1", store.ReplicaFallbacks())
+	}
+
+	// A failed replica is skipped until replicaRetryAfter has passed
+	replica.down = false
+	store.ListPendingPayments()
+	if replica.calls != 1 {
+		t.Errorf("replica called %d times, want 1 while it is skipped", replica.calls)
+	}
+	store.replicaDownUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	if got, _ := store.ListPendingPayments(); len(got) != 0 || replica.calls != 2 {
+		t.Errorf("recovered replica not used: %d payments, %d calls", len(got), replica.calls)
+	}
+}
@@ -0,0 +1,119 @@
+package paywall
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeRequestBody(t *testing.T) {
+	limits := requestLimits{maxBytes: 64, readTimeout: time.Second}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		allowForm   bool
+		wantOK      bool
+		wantForm    bool
+		wantCode    int
+	}{
+		{"json", "application/json; charset=utf-8", `{"mode":"bypass"}`, false, true, false, http.StatusOK},
+		{"json without content type", "", `{"mode":"bypass"}`, false, true, false, http.StatusOK},
+		{"form", "application/x-www-form-urlencoded", "mode=bypass", true, true, true, http.StatusOK},
+		{"json without content type on form endpoint", "", `{"mode":"bypass"}`, true, true, false, http.StatusOK},
+		{"form on json endpoint", "application/x-www-form-urlencoded", "mode=bypass", false, false, false, http.StatusUnsupportedMediaType},
+		{"multipart", "multipart/form-data; boundary=x", "--x--", true, false, false, http.StatusUnsupportedMediaType},
+		{"bad content type", "application/", `{}`, false, false, false, http.StatusUnsupportedMediaType},
+		{"malformed json", "application/json", `{"mode":`, false, false, false, http.StatusBadRequest},
+		{"json too large", "application/json", `{"mode":"` + strings.Repeat("a", 100) + `"}`, false, false, false, http.StatusRequestEntityTooLarge},
+		{"form too large", "application/x-www-form-urlencoded", "mode=" + strings.Repeat("a", 100), true, false, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/paywall/mode", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			var dst modeRequest
+			form, ok := decodeRequestBody(rec, req, limits, &dst, tt.allowForm)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (status %d)", ok, tt.wantOK, rec.Code)
+			}
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !ok {
+				return
+			}
+			if (form != nil) != tt.wantForm {
+				t.Errorf("form = %v, want form body %v", form, tt.wantForm)
+			}
+			if tt.wantForm && form.Get("mode") != "bypass" || !tt.wantForm && dst.Mode != ModeBypass {
+				t.Errorf("decoded form %v / JSON %+v, want mode bypass", form, dst)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestBody_SlowClient(t *testing.T) {
+	limits := requestLimits{maxBytes: DefaultMaxRequestBodyBytes, readTimeout: 100 * time.Millisecond}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var dst modeRequest
+		if _, ok := decodeRequestBody(w, r, limits, &dst, false); ok {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Announce a body but send only part of it
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"mode\":")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("no response to a stalled body: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestTimeout)
+	}
+}
+
+func TestNewPaywall_RequestLimits(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:          0.001,
+		PaymentTimeout:      time.Hour,
+		TestNet:             true,
+		Store:               NewMemoryStore(),
+		MaxRequestBodyBytes: 16,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	if got := pw.requestLimits(); got.maxBytes != 16 || got.readTimeout != DefaultRequestReadTimeout {
+		t.Errorf("requestLimits() = %+v, want 16 bytes and the default timeout", got)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/paywall/mode", strings.NewReader(`{"mode":"read_only"}`))
+	rec := httptest.NewRecorder()
+	pw.HandleMode(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("HandleMode() status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	if _, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, Store: NewMemoryStore(), RequestReadTimeout: -time.Second}); err == nil {
+		t.Error("NewPaywall() accepted a negative RequestReadTimeout")
+	}
+}
@@ -0,0 +1,185 @@
+package paywall
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultRetentionInterval is the default RetentionPolicy.Interval
+const defaultRetentionInterval = time.Hour
+
+// RetentionPolicy purges payments that no longer matter from the Store, for
+// privacy and disk hygiene (see Config.RetentionPolicy).
+type RetentionPolicy struct {
+	// MaxAge is how long payments are kept once ArchivablePayment selects
+	// them: confirmed payments after their access ends, expired payments after
+	// they expired, escrows after their timeout. It must be at least 24 hours,
+	// the time the monitor watches expired payments for late payments.
+	MaxAge time.Duration
+	// Delete removes old payments with PaymentStore.DeletePayment instead of
+	// moving them to the store's archive with PaymentStore.ArchivePayments.
+	// Optional: defaults to false, archiving them.
+	Delete bool
+	// Interval is how often old payments are purged.
+	// Optional: defaults to 1 hour.
+	Interval time.Duration
+}
+
+// validateRetentionPolicy checks Config.RetentionPolicy, if set
+func validateRetentionPolicy(policy *RetentionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxAge < latePaymentWindow {
+		return fmt.Errorf("RetentionPolicy.MaxAge must be at least %s, got: %s (hint: expired payments are watched that long for late payments)", latePaymentWindow, policy.MaxAge)
+	}
+	if policy.Interval < 0 {
+		return fmt.Errorf("RetentionPolicy.Interval must not be negative, got: %s (hint: leave at 0 for the 1 hour default)", policy.Interval)
+	}
+	return nil
+}
+
+// ArchivablePayment reports whether payment was finished before olderThan:
+// confirmed or expired, with no funded or disputed escrow, and its access
+// window, expiry and escrow timeout all before olderThan. Stores implementing
+// PaymentStore.ArchivePayments select payments with it.
+func ArchivablePayment(payment *Payment, olderThan time.Time) bool {
+	if payment.Status != StatusConfirmed && payment.Status != StatusExpired {
+		return false
+	}
+	if payment.EscrowState == EscrowFunded || payment.EscrowState == EscrowDisputed {
+		return false
+	}
+	end := payment.AccessEnds()
+	if payment.EscrowTimeout.After(end) {
+		end = payment.EscrowTimeout
+	}
+	return end.Before(olderThan)
+}
+
+// purgeOldPayments archives or, with RetentionPolicy.Delete, deletes the
+// payments finished more than RetentionPolicy.MaxAge before now
+//
+// Returns:
+//   - int: How many payments were purged
+//   - error: If the store could not be read, archived or deleted from
+func (p *Paywall) purgeOldPayments(now time.Time) (int, error) {
+	olderThan := now.Add(-p.retention.MaxAge)
+	if !p.retention.Delete {
+		archived, err := p.Store.ArchivePayments(olderThan)
+		if err != nil {
+			return archived, fmt.Errorf("archive payments: %w", err)
+		}
+		return archived, nil
+	}
+
+	old, err := selectPayments(context.Background(), p.Store, PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed, StatusExpired}}, func(payment *Payment) bool {
+		return ArchivablePayment(payment, olderThan)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("list old payments: %w", err)
+	}
+	deleted := 0
+	for _, payment := range old {
+		ok, err := p.deleteIfArchivable(payment.ID, olderThan)
+		if err != nil {
+			return deleted, err
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteIfArchivable deletes the payment with paymentID if ArchivablePayment
+// still selects it once locked and read again
+//
+// Returns:
+//   - bool: true if the payment was deleted
+//   - error: If the payment could not be read or deleted
+func (p *Paywall) deleteIfArchivable(paymentID string, olderThan time.Time) (bool, error) {
+	unlock, err := p.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		// In use; the next purge tries again
+		return false, nil
+	}
+	defer unlock()
+
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		return false, fmt.Errorf("read payment %s: %w", paymentID, err)
+	}
+	if payment == nil || !ArchivablePayment(payment, olderThan) {
+		return false, nil
+	}
+	if err := p.Store.DeletePayment(paymentID); err != nil {
+		return false, fmt.Errorf("delete payment %s: %w", paymentID, err)
+	}
+	return true, nil
+}
+
+// retentionPurger purges old payments every RetentionPolicy.Interval
+type retentionPurger struct {
+	paywall  *Paywall
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newRetentionPurger creates a purger for the paywall's RetentionPolicy
+func newRetentionPurger(p *Paywall) *retentionPurger {
+	return &retentionPurger{
+		paywall:  p,
+		interval: p.retention.Interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start purges old payments every interval until Stop
+func (r *retentionPurger) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.purge()
+			}
+		}
+	}()
+}
+
+// purge runs one purge and logs its outcome
+func (r *retentionPurger) purge() {
+	purged, err := r.paywall.purgeOldPayments(time.Now())
+	if err != nil {
+		r.paywall.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "retention_purge_failed",
+			Message: fmt.Sprintf("Failed to purge old payments: %v", err),
+		})
+	}
+	if purged > 0 {
+		r.paywall.logger.log(LogEntry{
+			Level:   LogLevelInfo,
+			Event:   "retention_purge",
+			Message: fmt.Sprintf("Purged %d payments finished more than %s ago", purged, r.paywall.retention.MaxAge),
+		})
+	}
+}
+
+// Stop ends the purge loop and waits for a running purge to finish
+func (r *retentionPurger) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
@@ -0,0 +1,151 @@
+package paywall
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestArchivablePayment(t *testing.T) {
+	olderThan := time.Now()
+	before, after := olderThan.Add(-time.Hour), olderThan.Add(time.Hour)
+	tests := []struct {
+		name    string
+		payment Payment
+		want    bool
+	}{
+		{"expired", Payment{Status: StatusExpired, ExpiresAt: before}, true},
+		{"expired recently", Payment{Status: StatusExpired, ExpiresAt: after}, false},
+		{"confirmed", Payment{Status: StatusConfirmed, ExpiresAt: before}, true},
+		{"access still open", Payment{Status: StatusConfirmed, ExpiresAt: before, AccessExpiresAt: after}, false},
+		{"pending", Payment{Status: StatusPending, ExpiresAt: before}, false},
+		{"escrow funded", Payment{Status: StatusConfirmed, ExpiresAt: before, EscrowState: EscrowFunded}, false},
+		{"escrow timeout ahead", Payment{Status: StatusConfirmed, ExpiresAt: before, EscrowState: EscrowRefunded, EscrowTimeout: after}, false},
+	}
+	for _, tt := range tests {
+		if got := ArchivablePayment(&tt.payment, olderThan); got != tt.want {
+			t.Errorf("%s: ArchivablePayment() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPaymentStore_DeleteAndArchive(t *testing.T) {
+	encrypted, err := NewEncryptedFileStore(t.TempDir()+"/store.key", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEncryptedFileStore() error = %v", err)
+	}
+	sqlStore, _ := newTestSQLStore(t, DialectPostgres)
+	redisStore, _ := newTestRedisStore(t)
+	s3, _ := newTestS3Store(t)
+	stores := map[string]PaymentStore{
+		"memory":     NewMemoryStore(),
+		"file":       NewFileStore(t.TempDir()),
+		"encrypted":  encrypted,
+		"sql":        sqlStore,
+		"redis":      redisStore,
+		"s3":         s3,
+		"replicated": NewReplicatedStore(NewMemoryStore(), nil),
+	}
+
+	olderThan := time.Now().Add(-48 * time.Hour)
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, p := range []*Payment{
+				{ID: "old", Status: StatusConfirmed, ExpiresAt: olderThan.Add(-time.Hour)},
+				{ID: "recent", Status: StatusExpired, ExpiresAt: olderThan.Add(time.Hour)},
+				{ID: "pending", Status: StatusPending, ExpiresAt: olderThan.Add(-time.Hour)},
+				{ID: "unwanted", Status: StatusPending, ExpiresAt: time.Now().Add(time.Hour)},
+			} {
+				p.Addresses = map[wallet.WalletType]string{wallet.Bitcoin: "addr-" + p.ID}
+				p.Amounts = map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}
+				if err := store.CreatePayment(p); err != nil {
+					t.Fatalf("CreatePayment() error = %v", err)
+				}
+			}
+
+			if err := store.DeletePayment("unwanted"); err != nil {
+				t.Fatalf("DeletePayment() error = %v", err)
+			}
+			if p, _ := store.GetPayment("unwanted"); p != nil {
+				t.Error("deleted payment is still readable")
+			}
+			if p, _ := store.GetPaymentByAddress("addr-unwanted"); p != nil {
+				t.Error("deleted payment is still found by address")
+			}
+			if err := store.DeletePayment("unwanted"); err != nil {
+				t.Errorf("DeletePayment() of a missing payment error = %v", err)
+			}
+
+			archived, err := store.ArchivePayments(olderThan)
+			if err != nil || archived != 1 {
+				t.Fatalf("ArchivePayments() = %d, %v, want 1", archived, err)
+			}
+			if p, _ := store.GetPayment("old"); p != nil {
+				t.Error("archived payment is still readable")
+			}
+			if p, _ := store.GetPaymentByAddress("addr-old"); p != nil {
+				t.Error("archived payment is still found by address")
+			}
+			var left []string
+			StreamPayments(store, PaymentFilter{}, func(p *Payment) error {
+				left = append(left, p.ID)
+				return nil
+			})
+			if len(left) != 2 {
+				t.Errorf("payments left = %v, want recent and pending", left)
+			}
+			if archived, _ := store.ArchivePayments(olderThan); archived != 0 {
+				t.Errorf("second ArchivePayments() = %d, want 0", archived)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	for _, remove := range []bool{false, true} {
+		store := NewMemoryStore()
+		pw, err := NewPaywall(Config{
+			PriceInBTC:      0.001,
+			PaymentTimeout:  time.Hour,
+			TestNet:         true,
+			Store:           store,
+			Logger:          NewStructuredLogger(io.Discard, LogLevelError, true),
+			RetentionPolicy: &RetentionPolicy{MaxAge: 30 * 24 * time.Hour, Delete: remove},
+		})
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		if pw.retention.Interval != defaultRetentionInterval {
+			t.Errorf("Interval = %s, want the default", pw.retention.Interval)
+		}
+
+		now := time.Now()
+		store.CreatePayment(&Payment{ID: "old", Status: StatusConfirmed, ExpiresAt: now.Add(-31 * 24 * time.Hour)})
+		store.CreatePayment(&Payment{ID: "kept", Status: StatusConfirmed, ExpiresAt: now.Add(-29 * 24 * time.Hour)})
+		purged, err := pw.purgeOldPayments(now)
+		if err != nil || purged != 1 {
+			t.Errorf("delete %v: purgeOldPayments() = %d, %v, want 1", remove, purged, err)
+		}
+		if old, _ := store.GetPayment("old"); old != nil {
+			t.Errorf("delete %v: payment past MaxAge was kept", remove)
+		}
+		if kept, _ := store.GetPayment("kept"); kept == nil {
+			t.Errorf("delete %v: payment within MaxAge was purged", remove)
+		}
+		pw.Close()
+	}
+
+	for _, policy := range []*RetentionPolicy{
+		{},
+		{MaxAge: time.Hour},
+		{MaxAge: 48 * time.Hour, Interval: -time.Minute},
+	} {
+		_, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), RetentionPolicy: policy})
+		if err == nil || !strings.Contains(err.Error(), "RetentionPolicy.") {
+			t.Errorf("NewPaywall(RetentionPolicy: %+v) error = %v, want a RetentionPolicy error", policy, err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package paywall
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// RevenueBucket is the width of the time buckets in a revenue report
+type RevenueBucket string
+
+const (
+	// BucketDay groups revenue per UTC calendar day
+	BucketDay RevenueBucket = "day"
+	// BucketWeek groups revenue per ISO week (Monday 00:00 UTC)
+	BucketWeek RevenueBucket = "week"
+	// BucketMonth groups revenue per UTC calendar month
+	BucketMonth RevenueBucket = "month"
+)
+
+// RevenuePoint is the revenue collected in a single time bucket
+type RevenuePoint struct {
+	// Start is the inclusive start of the bucket (UTC)
+	Start time.Time `json:"start"`
+	// End is the exclusive end of the bucket (UTC)
+	End time.Time `json:"end"`
+	// Payments is the number of confirmed payments in the bucket
+	Payments int `json:"payments"`
+	// Amounts is the revenue per currency
+	Amounts map[wallet.WalletType]float64 `json:"amounts"`
+	// Fiat is the revenue in RevenueReport.FiatCurrency (zero without a PriceOracle)
+	Fiat float64 `json:"fiat,omitempty"`
+}
+
+// RevenueReport is the result of Paywall.Revenue
+// Related: Paywall.Revenue, RevenuePoint
+type RevenueReport struct {
+	// From and To are the requested report range
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Bucket is the bucket width used for Points
+	Bucket RevenueBucket `json:"bucket"`
+	// FiatCurrency is the currency of fiat values, empty without a PriceOracle
+	FiatCurrency string `json:"fiat_currency,omitempty"`
+	// Points holds one entry per bucket, in chronological order, including empty buckets
+	Points []RevenuePoint `json:"points"`
+	// Totals is the revenue per currency across the whole range
+	Totals map[wallet.WalletType]float64 `json:"totals"`
+	// FiatTotal is the fiat revenue across the whole range
+	FiatTotal float64 `json:"fiat_total,omitempty"`
+	// FiatIncomplete is true when the oracle failed for at least one payment
+	FiatIncomplete bool `json:"fiat_incomplete,omitempty"`
+	// Unattributed counts confirmed payments whose settling currency is unknown
+	// (confirmed before PaidCurrency was recorded and offering several currencies)
+	Unattributed int `json:"unattributed,omitempty"`
+}
+
+// Revenue reports confirmed revenue per time bucket and currency.
+//
+// Parameters:
+//   - from: Inclusive start of the range
+//   - to: Exclusive end of the range
+//   - bucket: BucketDay, BucketWeek or BucketMonth
+//
+// Returns:
+//   - *RevenueReport: Revenue per bucket, with fiat values when Config.PriceOracle is set
+//   - error: If the range or bucket is invalid, or the store cannot list payments
+//
+// Payments are attributed to the bucket containing their creation time.
+// Payments are streamed from the store, which must implement PaymentStreamer
+// or PaymentLister.
+//
+// Related: RevenueReport.WriteCSV, PriceOracle
+func (p *Paywall) Revenue(from, to time.Time, bucket RevenueBucket) (*RevenueReport, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid revenue range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if bucket != BucketDay && bucket != BucketWeek && bucket != BucketMonth {
+		return nil, fmt.Errorf("unsupported revenue bucket %q (hint: use BucketDay, BucketWeek or BucketMonth)", bucket)
+	}
+	report := &RevenueReport{
+		From:   from,
+		To:     to,
+		Bucket: bucket,
+		Totals: make(map[wallet.WalletType]float64),
+	}
+	if p.priceOracle != nil {
+		report.FiatCurrency = p.fiatCurrency
+	}
+	for start := bucketStart(from, bucket); start.Before(to); start = nextBucket(start, bucket) {
+		report.Points = append(report.Points, RevenuePoint{
+			Start:   start,
+			End:     nextBucket(start, bucket),
+			Amounts: make(map[wallet.WalletType]float64),
+		})
+	}
+
+	filter := PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}, CreatedFrom: from, CreatedTo: to}
+	err := StreamPayments(p.Store, filter, func(payment *Payment) error {
+		currency, ok := settledCurrency(payment)
+		if !ok {
+			report.Unattributed++
+			return nil
+		}
+		amount := payment.Amounts[currency]
+
+		i := sort.Search(len(report.Points), func(i int) bool {
+			return report.Points[i].End.After(payment.CreatedAt)
+		})
+		point := &report.Points[i]
+		point.Payments++
+		point.Amounts[currency] += amount
+		report.Totals[currency] += amount
+
+		if p.priceOracle != nil {
+			rate, err := p.priceOracle.FiatPrice(rateCurrency(currency), p.fiatCurrency, payment.CreatedAt)
+			if err != nil {
+				report.FiatIncomplete = true
+				p.logger.log(LogEntry{
+					Level:     LogLevelWarn,
+					Event:     "price_oracle_failed",
+					Message:   fmt.Sprintf("Price oracle failed for %s/%s: %v", currency, p.fiatCurrency, err),
+					PaymentID: payment.ID,
+				})
+				return nil
+			}
+			point.Fiat += amount * rate
+			report.FiatTotal += amount * rate
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream payments: %w", err)
+	}
+	return report, nil
+}
+
+// settledCurrency returns the currency that settled a confirmed payment.
+// Older payments without PaidCurrency are attributed only when they offered a single currency.
+func settledCurrency(payment *Payment) (wallet.WalletType, bool) {
+	if payment.PaidCurrency != "" {
+		return payment.PaidCurrency, true
+	}
+	var found wallet.WalletType
+	for currency, amount := range payment.Amounts {
+		if amount <= 0 {
+			continue
+		}
+		if found != "" {
+			return "", false
+		}
+		found = currency
+	}
+	return found, found != ""
+}
+
+// bucketStart truncates t to the start of its bucket in UTC
+func bucketStart(t time.Time, bucket RevenueBucket) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch bucket {
+	case BucketWeek:
+		// time.Weekday starts on Sunday; ISO weeks start on Monday
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextBucket returns the start of the bucket following start
+func nextBucket(start time.Time, bucket RevenueBucket) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// WriteCSV writes the report as CSV with one row per bucket and one amount
+// column per currency, followed by a fiat column when fiat values are available.
+//
+// Parameters:
+//   - w: Destination writer (e.g. an http.ResponseWriter for downloads)
+//
+// Returns:
+//   - error: If writing fails
+func (r *RevenueReport) WriteCSV(w io.Writer) error {
+	currencies := make([]wallet.WalletType, 0, len(r.Totals))
+	seen := make(map[wallet.WalletType]bool)
+	for _, point := range r.Points {
+		for currency := range point.Amounts {
+			if !seen[currency] {
+				seen[currency] = true
+				currencies = append(currencies, currency)
+			}
+		}
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+
+	header := []string{"bucket_start", "bucket_end", "payments"}
+	for _, currency := range currencies {
+		header = append(header, string(currency))
+	}
+	if r.FiatCurrency != "" {
+		header = append(header, r.FiatCurrency)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("write CSV header: %w", err)
+	}
+	for _, point := range r.Points {
+		row := []string{
+			point.Start.Format("2006-01-02"),
+			point.End.Format("2006-01-02"),
+			strconv.Itoa(point.Payments),
+		}
+		for _, currency := range currencies {
+			row = append(row, strconv.FormatFloat(point.Amounts[currency], 'f', -1, 64))
+		}
+		if r.FiatCurrency != "" {
+			row = append(row, strconv.FormatFloat(point.Fiat, 'f', 2, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write CSV row: %w", err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
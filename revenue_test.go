@@ -0,0 +1,126 @@
+package paywall
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// revenueStore returns a MemoryStore holding payments
+func revenueStore(payments ...*Payment) *MemoryStore {
+	store := NewMemoryStore()
+	for _, payment := range payments {
+		store.CreatePayment(payment)
+	}
+	return store
+}
+
+func revenuePayment(id string, createdAt time.Time, status PaymentStatus, paid wallet.WalletType, amounts map[wallet.WalletType]float64) *Payment {
+	return &Payment{
+		ID:           id,
+		Addresses:    map[wallet.WalletType]string{},
+		Amounts:      amounts,
+		CreatedAt:    createdAt,
+		ExpiresAt:    createdAt.Add(24 * time.Hour),
+		Status:       status,
+		PaidCurrency: paid,
+	}
+}
+
+func TestRevenue_DailyBuckets(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC) // Monday
+	both := map[wallet.WalletType]float64{wallet.Bitcoin: 0.001, wallet.Monero: 0.1}
+	store := revenueStore(
+		revenuePayment("a", day.Add(time.Hour), StatusConfirmed, wallet.Bitcoin, both),
+		revenuePayment("b", day.Add(2*time.Hour), StatusConfirmed, wallet.Monero, both),
+		revenuePayment("c", day.Add(26*time.Hour), StatusConfirmed, wallet.Bitcoin, both),
+		revenuePayment("d", day.Add(3*time.Hour), StatusPending, "", both),
+		revenuePayment("e", day.Add(4*time.Hour), StatusConfirmed, "", both),
+		revenuePayment("f", day.Add(5*time.Hour), StatusConfirmed, "", map[wallet.WalletType]float64{wallet.Bitcoin: 0.002}),
+		revenuePayment("g", day.Add(-time.Hour), StatusConfirmed, wallet.Bitcoin, both),
+	)
+	pw := &Paywall{Store: store, logger: NewDefaultLogger(), priceOracle: StaticPriceOracle{wallet.Bitcoin: 50000, wallet.Monero: 150}, fiatCurrency: "USD"}
+
+	report, err := pw.Revenue(day, day.AddDate(0, 0, 3), BucketDay)
+	if err != nil {
+		t.Fatalf("Revenue() error = %v", err)
+	}
+	if len(report.Points) != 3 {
+		t.Fatalf("len(Points) = %d, want 3", len(report.Points))
+	}
+	first := report.Points[0]
+	if first.Payments != 3 || first.Amounts[wallet.Bitcoin] != 0.003 || first.Amounts[wallet.Monero] != 0.1 {
+		t.Errorf("day 1 = %+v", first)
+	}
+	if report.Points[1].Payments != 1 || report.Points[2].Payments != 0 {
+		t.Errorf("day 2/3 payments = %d/%d, want 1/0", report.Points[1].Payments, report.Points[2].Payments)
+	}
+	if report.Unattributed != 1 {
+		t.Errorf("Unattributed = %d, want 1", report.Unattributed)
+	}
+	if report.FiatCurrency != "USD" || report.FiatTotal != 0.004*50000+0.1*150 {
+		t.Errorf("fiat = %s %.2f", report.FiatCurrency, report.FiatTotal)
+	}
+}
+
+func TestRevenue_WeekAndMonthBuckets(t *testing.T) {
+	wednesday := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	if got := bucketStart(wednesday, BucketWeek); !got.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("week start = %v", got)
+	}
+	sunday := time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC)
+	if got := bucketStart(sunday, BucketWeek); !got.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("sunday week start = %v", got)
+	}
+	if got := bucketStart(wednesday, BucketMonth); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month start = %v", got)
+	}
+
+	pw := &Paywall{Store: NewMemoryStore(), logger: NewDefaultLogger(), fiatCurrency: "USD"}
+	report, err := pw.Revenue(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), BucketMonth)
+	if err != nil {
+		t.Fatalf("Revenue() error = %v", err)
+	}
+	if len(report.Points) != 3 || report.FiatCurrency != "" {
+		t.Errorf("months = %d, fiat = %q", len(report.Points), report.FiatCurrency)
+	}
+}
+
+func TestRevenue_InvalidArguments(t *testing.T) {
+	pw := &Paywall{Store: NewMemoryStore(), logger: NewDefaultLogger(), fiatCurrency: "USD"}
+	now := time.Now()
+	if _, err := pw.Revenue(now, now.Add(-time.Hour), BucketDay); err == nil {
+		t.Error("Revenue() should reject inverted ranges")
+	}
+	if _, err := pw.Revenue(now.Add(-time.Hour), now, "hour"); err == nil {
+		t.Error("Revenue() should reject unknown buckets")
+	}
+	pw.Store = &mockPaymentStore{}
+	if _, err := pw.Revenue(now.Add(-time.Hour), now, BucketDay); err == nil {
+		t.Error("Revenue() should fail for stores without ListPayments")
+	}
+}
+
+func TestRevenueReport_WriteCSV(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	store := revenueStore(
+		revenuePayment("a", day.Add(time.Hour), StatusConfirmed, wallet.Bitcoin, map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}),
+	)
+	pw := &Paywall{Store: store, logger: NewDefaultLogger(), priceOracle: StaticPriceOracle{wallet.Bitcoin: 50000}, fiatCurrency: "USD"}
+	report, err := pw.Revenue(day, day.AddDate(0, 0, 2), BucketDay)
+	if err != nil {
+		t.Fatalf("Revenue() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "bucket_start,bucket_end,payments,BTC,USD\n" +
+		"2024-03-04,2024-03-05,1,0.001,50.00\n" +
+		"2024-03-05,2024-03-06,0,0,0.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
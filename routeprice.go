@@ -0,0 +1,173 @@
+package paywall
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// RouteConfig prices a protected route independently of Config.PriceInBTC and
+// Config.PriceInXMR, e.g. premium articles next to cheaper basic ones on the
+// same Paywall. Apply it with WithRoute.
+//
+// Payments created on a route record its Name and only grant access on routes
+// with the same Name, so a payment for a cheap route never unlocks an
+// expensive one. Routes without a RouteConfig form one route of their own.
+type RouteConfig struct {
+	// Name identifies the route on its payments (Payment.Route): 1 to 32
+	// lowercase letters, digits or hyphens. Routes sharing a Name share
+	// their payments and must be priced the same.
+	Name string
+	// PathPrefix selects the requests the route prices when several routes
+	// are passed to WithRoute, e.g. "/premium/"; the longest matching prefix
+	// wins. Optional: "" matches every request of the handler.
+	PathPrefix string
+	// PriceInBTC is the amount in Bitcoin required for access on the route.
+	// Optional: 0 does not offer Bitcoin on the route.
+	PriceInBTC float64
+	// PriceInXMR is the amount in Monero required for access on the route.
+	// Optional: 0 does not offer Monero on the route.
+	PriceInXMR float64
+	// PriceInLightning is the amount in BTC required for access on the route
+	// when paying with Lightning (Config.Lightning).
+	// Optional: 0 uses PriceInBTC.
+	PriceInLightning float64
+	// PaymentTimeout is how long the route's payments may stay unpaid.
+	// Optional: 0 uses Config.PaymentTimeout.
+	PaymentTimeout time.Duration
+	// MinConfirmations is the number of blockchain confirmations the route's
+	// payments need. Optional: 0 uses Config.MinConfirmations.
+	MinConfirmations int
+}
+
+// Validate checks the route configuration for WithRoute
+//
+// Returns:
+//   - error: If the name is invalid, no price is set, a price is negative or
+//     below the dust limit, or the timeout or confirmations are negative
+func (rc RouteConfig) Validate() error {
+	if err := ValidatePartition(rc.Name); err != nil {
+		return fmt.Errorf("route name: %w", err)
+	}
+	if rc.PathPrefix != "" && !strings.HasPrefix(rc.PathPrefix, "/") {
+		return fmt.Errorf("route %q: PathPrefix must start with '/', got %q", rc.Name, rc.PathPrefix)
+	}
+	if rc.PriceInBTC <= 0 && rc.PriceInXMR <= 0 && rc.PriceInLightning <= 0 {
+		return fmt.Errorf("route %q: PriceInBTC, PriceInXMR and PriceInLightning are all zero (hint: set PriceInBTC: 0.0005 for a premium route)", rc.Name)
+	}
+	for walletType, price := range rc.prices() {
+		if price < 0 {
+			return fmt.Errorf("route %q: %s price must be positive, got %.8f", rc.Name, walletType, price)
+		}
+		if price > 0 && price <= priceDustLimits[walletType] {
+			return fmt.Errorf("route %q: %s price %.8f is below the dust limit (minimum: %g)", rc.Name, walletType, price, priceDustLimits[walletType])
+		}
+	}
+	if rc.PaymentTimeout < 0 {
+		return fmt.Errorf("route %q: PaymentTimeout must not be negative, got %v", rc.Name, rc.PaymentTimeout)
+	}
+	if rc.MinConfirmations < 0 {
+		return fmt.Errorf("route %q: MinConfirmations must not be negative, got %d", rc.Name, rc.MinConfirmations)
+	}
+	return nil
+}
+
+// prices returns the route's price per currency, including unset ones
+func (rc RouteConfig) prices() map[wallet.WalletType]float64 {
+	lightning := rc.PriceInLightning
+	if lightning == 0 {
+		lightning = rc.PriceInBTC
+	}
+	return map[wallet.WalletType]float64{
+		wallet.Bitcoin:   rc.PriceInBTC,
+		wallet.Monero:    rc.PriceInXMR,
+		wallet.Lightning: lightning,
+	}
+}
+
+// price returns the amount a payment in walletType costs on the route
+//
+// Returns:
+//   - float64: Price in crypto
+//   - bool: False if the route does not offer the currency
+func (rc *RouteConfig) price(walletType wallet.WalletType) (float64, bool) {
+	price := rc.prices()[walletType]
+	return price, price > 0
+}
+
+// WithRoute prices a route with its own amounts, payment timeout and required
+// confirmations instead of the paywall's, e.g.
+//
+//	mux.Handle("/premium/", pw.MiddlewareWithOptions(premium, paywall.WithRoute(paywall.RouteConfig{
+//		Name: "premium", PriceInBTC: 0.0005, MinConfirmations: 3,
+//	})))
+//
+// To price paths below one handler, pass several routes with a PathPrefix
+// each; requests matching none of them are priced by the paywall's Config.
+//
+// Route prices are fixed crypto amounts: they do not follow Config.PriceInFiat.
+// Visitors hold one payment cookie, so paying on one route replaces the
+// payment of another.
+//
+// Parameters:
+//   - routes: Route configurations, see RouteConfig.Validate; the function
+//     panics on invalid or duplicate routes, like http.ServeMux on invalid
+//     patterns
+//
+// Related: RouteConfig, Payment.Route
+func WithRoute(routes ...RouteConfig) MiddlewareOption {
+	if len(routes) == 0 {
+		panic("paywall: WithRoute: no routes")
+	}
+	names := make(map[string]bool, len(routes))
+	prefixes := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if err := route.Validate(); err != nil {
+			panic(fmt.Sprintf("paywall: WithRoute: %v", err))
+		}
+		if names[route.Name] || prefixes[route.PathPrefix] {
+			panic(fmt.Sprintf("paywall: WithRoute: duplicate route %q (path prefix %q)", route.Name, route.PathPrefix))
+		}
+		names[route.Name] = true
+		prefixes[route.PathPrefix] = true
+	}
+
+	// Longest prefixes first, so the first match is the most specific one
+	sorted := append([]RouteConfig(nil), routes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].PathPrefix) > len(sorted[j].PathPrefix) })
+	return func(cfg *middlewareConfig) {
+		cfg.routes = sorted
+	}
+}
+
+// routeFor returns the route pricing r, nil for the paywall's defaults
+func (cfg *middlewareConfig) routeFor(r *http.Request) *RouteConfig {
+	for i := range cfg.routes {
+		if strings.HasPrefix(r.URL.Path, cfg.routes[i].PathPrefix) {
+			return &cfg.routes[i]
+		}
+	}
+	return nil
+}
+
+// routeName returns the name route records on its payments, "" for the
+// paywall's defaults
+func routeName(route *RouteConfig) string {
+	if route == nil {
+		return ""
+	}
+	return route.Name
+}
+
+// requiredConfirmations returns the confirmations payment needs: those of its
+// route when set, Config.MinConfirmations otherwise
+func (p *Paywall) requiredConfirmations(payment *Payment) int {
+	if payment.MinConfirmations > 0 {
+		return payment.MinConfirmations
+	}
+	return p.minConfirmations
+}
@@ -0,0 +1,187 @@
+package paywall
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestRouteConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   RouteConfig
+		wantErr bool
+	}{
+		{name: "valid", route: RouteConfig{Name: "premium", PathPrefix: "/premium/", PriceInBTC: 0.0005, PaymentTimeout: time.Hour, MinConfirmations: 3}},
+		{name: "monero only", route: RouteConfig{Name: "basic", PriceInXMR: 0.01}},
+		{name: "invalid name", route: RouteConfig{Name: "Premium", PriceInBTC: 0.0005}, wantErr: true},
+		{name: "relative prefix", route: RouteConfig{Name: "premium", PathPrefix: "premium/", PriceInBTC: 0.0005}, wantErr: true},
+		{name: "no price", route: RouteConfig{Name: "premium"}, wantErr: true},
+		{name: "negative price", route: RouteConfig{Name: "premium", PriceInBTC: 0.0005, PriceInXMR: -1}, wantErr: true},
+		{name: "dust", route: RouteConfig{Name: "premium", PriceInBTC: 0.000001}, wantErr: true},
+		{name: "negative timeout", route: RouteConfig{Name: "premium", PriceInBTC: 0.0005, PaymentTimeout: -time.Minute}, wantErr: true},
+		{name: "negative confirmations", route: RouteConfig{Name: "premium", PriceInBTC: 0.0005, MinConfirmations: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.route.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// routeTestRequest serves a GET of path on a route protected with opts, with
+// the payment cookie paymentID ("" for none)
+//
+// Returns:
+//   - bool: Whether the protected handler ran
+//   - string: The new payment cookie set by the response, "" for none
+func routeTestRequest(t *testing.T, pw *Paywall, opts []MiddlewareOption, path, paymentID string) (bool, string) {
+	t.Helper()
+	served := false
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}), opts...)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if paymentID != "" {
+		req.AddCookie(&http.Cookie{Name: "payment_id", Value: paymentID})
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "payment_id" && cookie.Value != paymentID {
+			return served, cookie.Value
+		}
+	}
+	return served, ""
+}
+
+func TestWithRoute(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	opts := []MiddlewareOption{WithRoute(
+		RouteConfig{Name: "premium", PathPrefix: "/premium/", PriceInBTC: 0.005, PaymentTimeout: 10 * time.Minute, MinConfirmations: 3},
+		RouteConfig{Name: "premium-video", PathPrefix: "/premium/video/", PriceInBTC: 0.01},
+	)}
+
+	tests := []struct {
+		path          string
+		route         string
+		price         float64
+		timeout       time.Duration
+		confirmations int
+	}{
+		{path: "/premium/article", route: "premium", price: 0.005, timeout: 10 * time.Minute, confirmations: 3},
+		{path: "/premium/video/1", route: "premium-video", price: 0.01, timeout: time.Hour},
+		{path: "/basic/article", route: "", price: 0.001, timeout: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			served, paymentID := routeTestRequest(t, pw, opts, tt.path, "")
+			if served || paymentID == "" {
+				t.Fatalf("served = %v, payment cookie = %q, want payment page with a new payment", served, paymentID)
+			}
+			payment, _ := pw.Store.GetPayment(paymentID)
+			if payment.Route != tt.route || payment.Amounts[wallet.Bitcoin] != tt.price || payment.MinConfirmations != tt.confirmations {
+				t.Errorf("payment route %q, price %.8f, confirmations %d, want %q, %.8f, %d",
+					payment.Route, payment.Amounts[wallet.Bitcoin], payment.MinConfirmations, tt.route, tt.price, tt.confirmations)
+			}
+			if timeout := payment.ExpiresAt.Sub(payment.CreatedAt); timeout != tt.timeout {
+				t.Errorf("payment timeout = %v, want %v", timeout, tt.timeout)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithRoute with duplicate names did not panic")
+		}
+	}()
+	WithRoute(RouteConfig{Name: "premium", PriceInBTC: 0.005}, RouteConfig{Name: "premium", PathPrefix: "/a/", PriceInBTC: 0.005})
+}
+
+func TestWithRoute_PaymentOnlyUnlocksItsRoute(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	premium := []MiddlewareOption{WithRoute(RouteConfig{Name: "premium", PriceInBTC: 0.005})}
+
+	confirm := func(paymentID string) {
+		payment, _ := pw.Store.GetPayment(paymentID)
+		payment.Status = StatusConfirmed
+		if err := pw.Store.UpdatePayment(payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, basicID := routeTestRequest(t, pw, nil, "/article", "")
+	confirm(basicID)
+	if served, _ := routeTestRequest(t, pw, nil, "/article", basicID); !served {
+		t.Error("basic payment does not unlock the basic route")
+	}
+	served, premiumID := routeTestRequest(t, pw, premium, "/premium", basicID)
+	if served || premiumID == "" {
+		t.Fatalf("basic payment: served = %v, new payment = %q, want a premium payment page", served, premiumID)
+	}
+
+	confirm(premiumID)
+	if served, _ := routeTestRequest(t, pw, premium, "/premium", premiumID); !served {
+		t.Error("premium payment does not unlock the premium route")
+	}
+	if served, _ := routeTestRequest(t, pw, nil, "/article", premiumID); served {
+		t.Error("premium payment unlocks the basic route")
+	}
+}
+
+// minConfClient is a chain client that counts funds by confirmations
+type minConfClient struct {
+	mockCryptoClient
+	minConf int
+}
+
+func (c *minConfClient) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	c.minConf = minConf
+	return c.balance, nil
+}
+
+func TestCheckWalletPayment_RouteConfirmations(t *testing.T) {
+	store := NewMemoryStore()
+	client := &minConfClient{mockCryptoClient: mockCryptoClient{balance: 0.005}}
+	monitor := &CryptoChainMonitor{
+		paywall: &Paywall{Store: store, minConfirmations: 1, logger: NewStructuredLogger(io.Discard, LogLevelError, true)},
+		client:  map[wallet.WalletType]CryptoClient{wallet.Bitcoin: client},
+	}
+
+	for _, tt := range []struct {
+		id            string
+		confirmations int
+		wantMinConf   int
+	}{
+		{id: "default-route", confirmations: 0, wantMinConf: 0},
+		{id: "premium-route", confirmations: 6, wantMinConf: 6},
+	} {
+		client.minConf = 0
+		payment := &Payment{
+			ID:               tt.id,
+			Addresses:        map[wallet.WalletType]string{wallet.Bitcoin: tt.id + "-address"},
+			Amounts:          map[wallet.WalletType]float64{wallet.Bitcoin: 0.005},
+			ExpiresAt:        time.Now().Add(time.Hour),
+			Status:           StatusPending,
+			MinConfirmations: tt.confirmations,
+		}
+		store.CreatePayment(payment)
+		if err := monitor.checkWalletPayment(payment, wallet.Bitcoin, &sync.Mutex{}); err != nil {
+			t.Fatalf("%s: checkWalletPayment() error = %v", tt.id, err)
+		}
+		if client.minConf != tt.wantMinConf {
+			t.Errorf("%s: balance counted with %d confirmations, want %d", tt.id, client.minConf, tt.wantMinConf)
+		}
+		stored, _ := store.GetPayment(tt.id)
+		if want := max(tt.confirmations, 1); stored.Status != StatusConfirmed || stored.Confirmations != want {
+			t.Errorf("%s: status %s with %d confirmations, want confirmed with %d", tt.id, stored.Status, stored.Confirmations, want)
+		}
+	}
+}
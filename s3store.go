@@ -0,0 +1,928 @@
+package paywall
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// s3RequestTimeout bounds a single object storage request
+	s3RequestTimeout = 30 * time.Second
+	// s3IndexRetries is how often an address index update is retried after a
+	// concurrent writer changed the index
+	s3IndexRetries = 10
+	// s3ListConcurrency bounds parallel object reads while listing payments
+	s3ListConcurrency = 8
+	// s3AddressIndex is the object mapping payment addresses to payment IDs
+	s3AddressIndex = "index/addresses.json"
+	// s3AddressCounters is the object holding the next address index of
+	// every wallet, see ReserveAddressIndex
+	s3AddressCounters = "index/address-counters.json"
+)
+
+// errS3PreconditionFailed is returned for writes whose If-Match or If-None-Match
+// condition failed because another writer got there first
+var errS3PreconditionFailed = errors.New("object storage precondition failed")
+
+// S3StoreConfig configures an S3Store
+type S3StoreConfig struct {
+	// Endpoint is the S3-compatible API URL, e.g. "https://s3.eu-west-1.amazonaws.com",
+	// "https://<account>.r2.cloudflarestorage.com" or "http://localhost:9000" (MinIO)
+	Endpoint string
+	// Bucket holds the payment objects; it must exist
+	Bucket string
+	// Region is the signing region. Optional: defaults to $AWS_REGION, then "us-east-1"
+	Region string
+	// Prefix is prepended to every object key, e.g. "paywall/" to share a bucket
+	Prefix string
+	// AccessKeyID, SecretAccessKey and SessionToken sign requests.
+	// Optional: default to $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and
+	// $AWS_SESSION_TOKEN, which AWS Lambda sets for the function's role.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// VirtualHostedStyle addresses the bucket as <bucket>.<endpoint host> instead
+	// of <endpoint>/<bucket>. Optional: path-style works with AWS, R2, MinIO and
+	// most other implementations.
+	VirtualHostedStyle bool
+	// HTTPClient sends the requests. Optional: defaults to a client with a 30 second timeout.
+	HTTPClient *http.Client
+	// ValidateAddresses rejects payments whose addresses fail
+	// ValidatePaymentAddresses on CreatePayment and UpdatePayment.
+	// Optional: defaults to false.
+	ValidateAddresses bool
+}
+
+// S3Store is a PaymentStore backed by S3-compatible object storage, for
+// serverless deployments (AWS Lambda, Cloud Run) whose local disk is ephemeral.
+// Each payment is a JSON object; an address index object maps payment addresses
+// to payment IDs for GetPaymentByAddress.
+//
+// Concurrent writers are detected with conditional writes: CreatePayment never
+// overwrites an existing payment (If-None-Match), and UpdatePayment only replaces
+// the version it read (If-Match on the ETag), returning ErrVersionConflict
+// otherwise. The storage service must support conditional writes, as AWS S3,
+// Cloudflare R2 and MinIO do.
+//
+// S3Store does not implement PaymentLocker; when several instances share a
+// bucket, configure Config.PaymentLocker to serialize payment updates.
+//
+// Related: PaymentStore, PaymentLister, APIKeyStore, StatsStore
+type S3Store struct {
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	prefix          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	virtualHosted   bool
+	client          *http.Client
+	// validateAddresses is S3StoreConfig.ValidateAddresses
+	validateAddresses bool
+}
+
+// NewS3Store creates a payment store in an S3-compatible bucket.
+//
+// Parameters:
+//   - config: Endpoint, bucket and credentials
+//
+// Returns:
+//   - *S3Store: The store; the bucket is not contacted until first use
+//   - error: If the endpoint, bucket or credentials are missing or invalid
+func NewS3Store(config S3StoreConfig) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3 endpoint must be an http(s) URL, got: %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_REGION")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.AccessKeyID == "" && config.SecretAccessKey == "" {
+		config.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		config.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if config.SessionToken == "" {
+			config.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required (hint: set AccessKeyID and SecretAccessKey, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: s3RequestTimeout}
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	return &S3Store{
+		endpoint:          endpoint,
+		bucket:            config.Bucket,
+		region:            config.Region,
+		prefix:            config.Prefix,
+		accessKeyID:       config.AccessKeyID,
+		secretAccessKey:   config.SecretAccessKey,
+		sessionToken:      config.SessionToken,
+		virtualHosted:     config.VirtualHostedStyle,
+		client:            config.HTTPClient,
+		validateAddresses: config.ValidateAddresses,
+	}, nil
+}
+
+// paymentKey returns the object key of a payment
+func (s *S3Store) paymentKey(id string) string {
+	return s.prefix + "payments/" + id + ".json"
+}
+
+// apiKeyKey returns the object key of an API key
+func (s *S3Store) apiKeyKey(id string) string {
+	return s.prefix + apiKeyDir + "/" + id + ".json"
+}
+
+// checkPayment validates a payment's ID and, with ValidateAddresses, its addresses
+func (s *S3Store) checkPayment(p *Payment) error {
+	if err := validateObjectID(p.ID); err != nil {
+		return err
+	}
+	if s.validateAddresses {
+		return ValidatePaymentAddresses(p)
+	}
+	return nil
+}
+
+// CreatePayment stores a new payment and indexes its addresses.
+//
+// Returns:
+//   - error: If the payment already exists or a request fails
+func (s *S3Store) CreatePayment(p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+	if _, err := s.putObject(s.paymentKey(p.ID), data, "", true); err != nil {
+		if errors.Is(err, errS3PreconditionFailed) {
+			return fmt.Errorf("payment %s already exists", p.ID)
+		}
+		return fmt.Errorf("store payment: %w", err)
+	}
+	if err := s.indexAddresses(p); err != nil {
+		return fmt.Errorf("index payment addresses: %w", err)
+	}
+	return nil
+}
+
+// GetPayment retrieves a payment by ID.
+//
+// Returns:
+//   - *Payment: The payment, nil if not found
+//   - error: Request, unmarshaling or migration errors
+func (s *S3Store) GetPayment(id string) (*Payment, error) {
+	if validateObjectID(id) != nil {
+		return nil, nil
+	}
+	payment, _, err := s.getPayment(s.paymentKey(id))
+	return payment, err
+}
+
+// getPayment reads a payment object and its ETag, nil if it does not exist
+func (s *S3Store) getPayment(key string) (*Payment, string, error) {
+	data, etag, err := s.getObject(key)
+	if err != nil || data == nil {
+		return nil, "", err
+	}
+	var payment Payment
+	if err := json.Unmarshal(data, &payment); err != nil {
+		return nil, "", fmt.Errorf("unmarshal payment: %w", err)
+	}
+	if err := MigratePayment(&payment); err != nil {
+		return nil, "", fmt.Errorf("migrate payment: %w", err)
+	}
+	return &payment, etag, nil
+}
+
+// UpdatePayment replaces a payment if it still has the version p was read at.
+//
+// Returns:
+//   - error: ErrVersionConflict if another writer changed the payment, request errors otherwise
+func (s *S3Store) UpdatePayment(p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	existing, etag, err := s.getPayment(s.paymentKey(p.ID))
+	if err != nil {
+		return fmt.Errorf("read payment: %w", err)
+	}
+	if existing != nil && existing.Version != p.Version {
+		return ErrVersionConflict
+	}
+
+	p.Version++
+	data, err := json.Marshal(p)
+	if err != nil {
+		p.Version--
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+	// Replace exactly the version read above, or create the payment if it vanished
+	if _, err := s.putObject(s.paymentKey(p.ID), data, etag, existing == nil); err != nil {
+		p.Version--
+		if errors.Is(err, errS3PreconditionFailed) {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("store payment: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentByAddress retrieves a payment by one of its addresses using the address index.
+//
+// Returns:
+//   - *Payment: The payment, nil if no payment has the address
+//   - error: Request errors
+func (s *S3Store) GetPaymentByAddress(addr string) (*Payment, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	index, _, err := s.readAddressIndex()
+	if err != nil {
+		return nil, err
+	}
+	id, ok := index[addr]
+	if !ok {
+		return nil, nil
+	}
+	return s.GetPayment(id)
+}
+
+// ListPendingPayments returns all payments with less than 1 confirmation.
+func (s *S3Store) ListPendingPayments() ([]*Payment, error) {
+	return s.listPayments(func(p *Payment) bool { return p.Confirmations < 1 })
+}
+
+// ListPayments returns every stored payment.
+func (s *S3Store) ListPayments() ([]*Payment, error) {
+	return s.listPayments(func(*Payment) bool { return true })
+}
+
+// GetPendingMultisigPayments returns all pending payments that have multisig enabled.
+func (s *S3Store) GetPendingMultisigPayments() ([]*Payment, error) {
+	return s.listPayments(func(p *Payment) bool {
+		return p.MultisigEnabled && p.Status == StatusPending
+	})
+}
+
+// GetEscrowsExpiringBefore returns funded or disputed escrows expiring before deadline.
+func (s *S3Store) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
+	return s.listPayments(func(p *Payment) bool {
+		return p.MultisigEnabled &&
+			(p.EscrowState == EscrowFunded || p.EscrowState == EscrowDisputed) &&
+			!p.EscrowTimeout.IsZero() && p.EscrowTimeout.Before(deadline)
+	})
+}
+
+// DeletePayment deletes a payment object and removes its addresses from the
+// address index.
+//
+// Returns:
+//   - error: Request errors; deleting a missing payment succeeds
+func (s *S3Store) DeletePayment(id string) error {
+	if err := validateObjectID(id); err != nil {
+		return err
+	}
+	payment, _, err := s.getPayment(s.paymentKey(id))
+	if err != nil || payment == nil {
+		return err
+	}
+	if err := s.deleteObject(s.paymentKey(id)); err != nil {
+		return fmt.Errorf("delete payment: %w", err)
+	}
+	if err := s.unindexAddresses(payment); err != nil {
+		return fmt.Errorf("unindex payment addresses: %w", err)
+	}
+	return nil
+}
+
+// ArchivePayments moves the payments ArchivablePayment selects for olderThan
+// from payments/ to archive/ under the key prefix and removes their addresses
+// from the address index.
+//
+// Returns:
+//   - int: How many payments were moved
+//   - error: Listing or request errors
+func (s *S3Store) ArchivePayments(olderThan time.Time) (int, error) {
+	old, err := s.listPayments(func(p *Payment) bool { return ArchivablePayment(p, olderThan) })
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range old {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return i, fmt.Errorf("marshal payment: %w", err)
+		}
+		if _, err := s.putObject(s.prefix+archiveDir+"/"+p.ID+".json", data, "", false); err != nil {
+			return i, fmt.Errorf("archive payment: %w", err)
+		}
+		if err := s.DeletePayment(p.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(old), nil
+}
+
+// listPayments reads every payment object and returns those matching keep.
+// Objects that cannot be read or parsed are logged and skipped, like FileStore does.
+func (s *S3Store) listPayments(keep func(*Payment) bool) ([]*Payment, error) {
+	keys, err := s.listKeys(s.prefix + "payments/")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		payments []*Payment
+		sem      = make(chan struct{}, s3ListConcurrency)
+	)
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".json") {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			payment, _, err := s.getPayment(key)
+			if err != nil {
+				log.Printf("Error reading object %s: %v", key, err)
+				return
+			}
+			if payment != nil && keep(payment) {
+				mu.Lock()
+				payments = append(payments, payment)
+				mu.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	return payments, nil
+}
+
+// StreamPayments calls fn for every payment matching filter, one listing page
+// (up to 1000 objects) at a time, so memory use does not grow with the bucket.
+// Implements PaymentStreamer. Objects that cannot be read or parsed are logged
+// and skipped.
+//
+// Returns:
+//   - error: Listing errors, or fn's error other than ErrStopStream
+func (s *S3Store) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	err := s.listKeyPages(s.prefix+"payments/"+partitionIDPrefix(filter.Partition), func(keys []string) error {
+		payments := make([]*Payment, len(keys))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, s3ListConcurrency)
+		for i, key := range keys {
+			if !strings.HasSuffix(key, ".json") {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, key string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				payment, _, err := s.getPayment(key)
+				if err != nil {
+					log.Printf("Error reading object %s: %v", key, err)
+					return
+				}
+				payments[i] = payment
+			}(i, key)
+		}
+		wg.Wait()
+
+		for _, payment := range payments {
+			if payment == nil || !filter.Matches(payment) {
+				continue
+			}
+			if err := fn(payment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return streamEnded(err)
+}
+
+// SaveAPIKey creates or replaces an API key record.
+func (s *S3Store) SaveAPIKey(key *APIKey) error {
+	if err := validateObjectID(key.ID); err != nil {
+		return err
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal API key: %w", err)
+	}
+	if _, err := s.putObject(s.apiKeyKey(key.ID), data, "", false); err != nil {
+		return fmt.Errorf("store API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves an API key record by ID, nil if not found.
+func (s *S3Store) GetAPIKey(id string) (*APIKey, error) {
+	if validateObjectID(id) != nil {
+		return nil, nil
+	}
+	data, _, err := s.getObject(s.apiKeyKey(id))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns all API key records, skipping unreadable ones.
+func (s *S3Store) ListAPIKeys() ([]*APIKey, error) {
+	keys, err := s.listKeys(s.prefix + apiKeyDir + "/")
+	if err != nil {
+		return nil, err
+	}
+	var apiKeys []*APIKey
+	for _, objectKey := range keys {
+		data, _, err := s.getObject(objectKey)
+		if err != nil || data == nil {
+			log.Printf("Error reading object %s: %v", objectKey, err)
+			continue
+		}
+		var key APIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			log.Printf("Error parsing object %s: %v", objectKey, err)
+			continue
+		}
+		apiKeys = append(apiKeys, &key)
+	}
+	return apiKeys, nil
+}
+
+// SaveStatsSnapshot stores a stats snapshot under stats/, named after its time.
+func (s *S3Store) SaveStatsSnapshot(snapshot *StatsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal stats snapshot: %w", err)
+	}
+	if _, err := s.putObject(s.prefix+statsDir+"/"+statsSnapshotName(snapshot.Time), data, "", false); err != nil {
+		return fmt.Errorf("store stats snapshot: %w", err)
+	}
+	return nil
+}
+
+// ListStatsSnapshots returns the stats snapshots taken in [from, to), oldest
+// first, skipping unreadable ones. Only the objects in the range are read.
+func (s *S3Store) ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error) {
+	keys, err := s.listKeys(s.prefix + statsDir + "/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	var snapshots []*StatsSnapshot
+	for _, objectKey := range keys {
+		taken, ok := statsSnapshotTime(path.Base(objectKey))
+		if !ok || taken.Before(from) || !taken.Before(to) {
+			continue
+		}
+		data, _, err := s.getObject(objectKey)
+		if err != nil || data == nil {
+			log.Printf("Error reading object %s: %v", objectKey, err)
+			continue
+		}
+		var snapshot StatsSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			log.Printf("Error parsing object %s: %v", objectKey, err)
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// DeleteStatsSnapshotsBefore deletes the stats snapshot objects taken before t.
+func (s *S3Store) DeleteStatsSnapshotsBefore(t time.Time) error {
+	keys, err := s.listKeys(s.prefix + statsDir + "/")
+	if err != nil {
+		return err
+	}
+	for _, objectKey := range keys {
+		taken, ok := statsSnapshotTime(path.Base(objectKey))
+		if !ok || !taken.Before(t) {
+			continue
+		}
+		if err := s.deleteObject(objectKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAddressIndex returns the address index and its ETag, empty if it does not exist yet
+func (s *S3Store) readAddressIndex() (map[string]string, string, error) {
+	data, etag, err := s.getObject(s.prefix + s3AddressIndex)
+	if err != nil {
+		return nil, "", fmt.Errorf("read address index: %w", err)
+	}
+	index := make(map[string]string)
+	if data != nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, "", fmt.Errorf("unmarshal address index: %w", err)
+		}
+	}
+	return index, etag, nil
+}
+
+// indexAddresses adds the payment's addresses to the address index
+func (s *S3Store) indexAddresses(p *Payment) error {
+	if len(p.Addresses) == 0 {
+		return nil
+	}
+	return s.updateAddressIndex(func(index map[string]string) {
+		for _, address := range p.Addresses {
+			if address != "" {
+				index[address] = p.ID
+			}
+		}
+	})
+}
+
+// unindexAddresses removes the payment's addresses from the address index
+func (s *S3Store) unindexAddresses(p *Payment) error {
+	if len(p.Addresses) == 0 {
+		return nil
+	}
+	return s.updateAddressIndex(func(index map[string]string) {
+		for _, address := range p.Addresses {
+			if index[address] == p.ID {
+				delete(index, address)
+			}
+		}
+	})
+}
+
+// updateAddressIndex applies change to the address index, retrying when a
+// concurrent writer changed the index in between
+func (s *S3Store) updateAddressIndex(change func(index map[string]string)) error {
+	for attempt := 0; attempt < s3IndexRetries; attempt++ {
+		index, etag, err := s.readAddressIndex()
+		if err != nil {
+			return err
+		}
+		change(index)
+		data, err := json.Marshal(index)
+		if err != nil {
+			return fmt.Errorf("marshal address index: %w", err)
+		}
+		_, err = s.putObject(s.prefix+s3AddressIndex, data, etag, etag == "")
+		if !errors.Is(err, errS3PreconditionFailed) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * 20 * time.Millisecond)
+	}
+	return fmt.Errorf("address index kept changing after %d attempts", s3IndexRetries)
+}
+
+// ReserveAddressIndex implements AddressIndexReserver with one object holding
+// every wallet's next index, replaced only if it did not change since it was
+// read (If-Match), like the address index.
+//
+// Returns:
+//   - uint32: The reserved index, at least floor
+//   - error: Request errors, or errAddressIndexConflict after repeated races
+func (s *S3Store) ReserveAddressIndex(ctx context.Context, key string, floor uint32) (uint32, error) {
+	for attempt := 0; attempt < s3IndexRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		data, etag, err := s.getObject(s.prefix + s3AddressCounters)
+		if err != nil {
+			return 0, fmt.Errorf("read address indexes: %w", err)
+		}
+		next := make(map[string]uint32)
+		if data != nil {
+			if err := json.Unmarshal(data, &next); err != nil {
+				return 0, fmt.Errorf("unmarshal address indexes: %w", err)
+			}
+		}
+		index := max(next[key], floor)
+		next[key] = index + 1
+		if data, err = json.Marshal(next); err != nil {
+			return 0, fmt.Errorf("marshal address indexes: %w", err)
+		}
+		_, err = s.putObject(s.prefix+s3AddressCounters, data, etag, etag == "")
+		if err == nil {
+			return index, nil
+		}
+		if !errors.Is(err, errS3PreconditionFailed) {
+			return 0, fmt.Errorf("write address indexes: %w", err)
+		}
+		time.Sleep(time.Duration(attempt+1) * 20 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("%w after %d attempts", errAddressIndexConflict, s3IndexRetries)
+}
+
+// s3ListResult is the part of a ListObjectsV2 response used by listKeys
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listKeys returns the keys of all objects under prefix
+func (s *S3Store) listKeys(prefix string) ([]string, error) {
+	var keys []string
+	err := s.listKeyPages(prefix, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+	return keys, err
+}
+
+// listKeyPages calls fn with each page of object keys under prefix, as
+// returned by ListObjectsV2; an error from fn ends the listing
+func (s *S3Store) listKeyPages(prefix string, fn func([]string) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return fmt.Errorf("list objects: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("list objects: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("list objects: %s", s3ErrorMessage(resp.StatusCode, body))
+		}
+		var result s3ListResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("parse object list: %w", err)
+		}
+		page := make([]string, 0, len(result.Contents))
+		for _, object := range result.Contents {
+			page = append(page, object.Key)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// getObject reads an object and its ETag; data is nil when the object does not exist
+func (s *S3Store) getObject(key string) (data []byte, etag string, err error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read object %s: %w", key, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, resp.Header.Get("ETag"), nil
+	case http.StatusNotFound:
+		return nil, "", nil
+	default:
+		return nil, "", fmt.Errorf("get object %s: %s", key, s3ErrorMessage(resp.StatusCode, body))
+	}
+}
+
+// putObject writes an object. With ifMatch set it only replaces that ETag; with
+// ifNoneMatch it only creates the object. Failed conditions return
+// errS3PreconditionFailed.
+func (s *S3Store) putObject(key string, data []byte, ifMatch string, ifNoneMatch bool) (string, error) {
+	header := http.Header{"Content-Type": {"application/json"}}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	} else if ifNoneMatch {
+		header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do(http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("ETag"), nil
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		// 409 is returned for a conditional write racing another one
+		return "", errS3PreconditionFailed
+	default:
+		return "", fmt.Errorf("put object %s: %s", key, s3ErrorMessage(resp.StatusCode, body))
+	}
+}
+
+// deleteObject deletes an object; deleting a missing object succeeds
+func (s *S3Store) deleteObject(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("delete object %s: %s", key, s3ErrorMessage(resp.StatusCode, body))
+	}
+}
+
+// do sends a signed request for key ("" for the bucket itself)
+func (s *S3Store) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	if s.virtualHosted {
+		u.Host = s.bucket + "." + u.Host
+		u.Path += "/" + key
+	} else {
+		u.Path += "/" + s.bucket + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	signS3Request(req, body, s.accessKeyID, s.secretAccessKey, s.sessionToken, s.region, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// s3ErrorMessage summarizes an error response
+func s3ErrorMessage(status int, body []byte) string {
+	var errResp struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &errResp) == nil && errResp.Code != "" {
+		return fmt.Sprintf("%d %s: %s", status, errResp.Code, errResp.Message)
+	}
+	return fmt.Sprintf("status %d", status)
+}
+
+// signS3Request adds AWS Signature Version 4 headers to req. It signs the host,
+// every x-amz-* header and the Content-Type, Range, If-Match and If-None-Match
+// headers that are present.
+func signS3Request(req *http.Request, body []byte, accessKeyID, secretAccessKey, sessionToken, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "x-amz-"), lower == "content-type", lower == "range",
+			lower == "if-match", lower == "if-none-match":
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// s3EscapePath URI-encodes each segment of path as Signature Version 4 requires
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes query sorted by key, as Signature Version 4 requires
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except RFC 3986 unreserved characters
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,164 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinSearchLength is the shortest fragment SearchPayments accepts; shorter
+	// ones match too many addresses to be useful
+	MinSearchLength = 4
+	// DefaultSearchLimit is used by SearchPayments when limit is not positive
+	DefaultSearchLimit = 20
+)
+
+// ErrSearchTooShort is returned by SearchPayments for fragments shorter than
+// MinSearchLength
+var ErrSearchTooShort = errors.New("search fragment must have at least 4 characters")
+
+// PaymentSearcher is an optional PaymentStore extension for stores that can
+// search addresses and transaction IDs with an index, such as a database with
+// prefix and reversed-suffix indexes. Stores without it are searched by
+// streaming their payments through PaymentFilter.Search.
+type PaymentSearcher interface {
+	// SearchPayments returns up to limit payments matching fragment as
+	// described by MatchesFragment, newest first
+	SearchPayments(fragment string, limit int) ([]*Payment, error)
+}
+
+// MatchesFragment reports whether one of the payment's addresses or
+// transaction IDs (Payment.DetectedTxID, Payment.TransactionID) starts or ends
+// with fragment, ignoring case and surrounding whitespace. Customers usually
+// paste the first or last characters their wallet shows.
+func MatchesFragment(payment *Payment, fragment string) bool {
+	fragment = strings.ToLower(strings.TrimSpace(fragment))
+	if fragment == "" {
+		return false
+	}
+	candidates := []string{payment.DetectedTxID, payment.TransactionID}
+	for _, address := range payment.Addresses {
+		candidates = append(candidates, address)
+	}
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(candidate)
+		if candidate != "" && (strings.HasPrefix(candidate, fragment) || strings.HasSuffix(candidate, fragment)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchPayments finds payments by a fragment of one of their addresses or
+// transaction IDs, so support staff can find a payment from what a customer
+// pasted from their wallet, typos at the other end included.
+//
+// Parameters:
+//   - store: The payment store; PaymentSearcher implementations are used
+//     directly, other stores are streamed (see StreamPayments)
+//   - fragment: Start or end of an address or transaction ID, at least
+//     MinSearchLength characters
+//   - limit: Maximum number of results; DefaultSearchLimit when not positive
+//
+// Returns:
+//   - []*Payment: Matching payments, newest first
+//   - error: ErrSearchTooShort, or if the store cannot be searched
+//
+// Related: PaymentFilter.Search, MatchesFragment
+func SearchPayments(store PaymentStore, fragment string, limit int) ([]*Payment, error) {
+	fragment = strings.TrimSpace(fragment)
+	if len(fragment) < MinSearchLength {
+		return nil, ErrSearchTooShort
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if searcher, ok := store.(PaymentSearcher); ok {
+		return searcher.SearchPayments(fragment, limit)
+	}
+
+	var results []*Payment
+	err := StreamPayments(store, PaymentFilter{Search: fragment}, func(payment *Payment) error {
+		results = append(results, payment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search payments: %w", err)
+	}
+	slices.SortFunc(results, func(a, b *Payment) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// PaymentSearchResponse is the JSON body returned by HandlePaymentSearch
+type PaymentSearchResponse struct {
+	// Query is the searched fragment
+	Query string `json:"query"`
+	// Payments are the matches, newest first
+	Payments []*Payment `json:"payments"`
+}
+
+// HandlePaymentSearch searches payments by a fragment of an address or
+// transaction ID (GET ?q=<fragment>, optional &limit=), see SearchPayments.
+// It performs no authentication of its own: mount it on an admin listener or
+// behind your admin authentication.
+//
+// Responses:
+//   - 200 with a PaymentSearchResponse
+//   - 400 Bad Request for fragments shorter than MinSearchLength or an invalid limit
+//   - 405 Method Not Allowed for non-GET requests
+//   - 500 Internal Server Error if the store cannot be searched
+func (p *Paywall) HandlePaymentSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fragment := strings.TrimSpace(query.Get("q"))
+	payments, err := SearchPayments(p.Store, fragment, limit)
+	if errors.Is(err, ErrSearchTooShort) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "payment_search_failed",
+			Message: fmt.Sprintf("Failed to search payments: %v", err),
+		})
+		http.Error(w, "Failed to search payments", http.StatusInternalServerError)
+		return
+	}
+
+	resp := PaymentSearchResponse{Query: fragment, Payments: payments}
+	if resp.Payments == nil {
+		resp.Payments = []*Payment{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode payment search response: %v", err),
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package paywall
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestSearchPayments(t *testing.T) {
+	s3Store, _ := newTestS3Store(t)
+	stores := map[string]PaymentStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+		"s3":     s3Store,
+	}
+	now := time.Now()
+	payments := []*Payment{
+		{
+			ID:        "search01",
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "tb1qsearchfirst0000000000000000000aaaa"},
+			CreatedAt: now.Add(-2 * time.Hour),
+		},
+		{
+			ID:           "search02",
+			Addresses:    map[wallet.WalletType]string{wallet.Bitcoin: "tb1qsearchsecond000000000000000000bbbb"},
+			DetectedTxID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+			CreatedAt:    now.Add(-time.Hour),
+		},
+		{
+			ID:        "search03",
+			Addresses: map[wallet.WalletType]string{wallet.Monero: "4AdUndXHHZ6cfufTMvppY6JwXNouMBzSkbLYfpAV5Usx3skxNgYeYTRj5UzqtReoS44qo9mtmXCqY45DJ852K5Jv2684Rge"},
+			CreatedAt: now,
+		},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, payment := range payments {
+				payment.Amounts = map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}
+				payment.ExpiresAt = now.Add(time.Hour)
+				payment.Status = StatusPending
+				if err := store.CreatePayment(payment); err != nil {
+					t.Fatalf("CreatePayment(%s) error = %v", payment.ID, err)
+				}
+			}
+
+			tests := []struct {
+				name     string
+				fragment string
+				want     []string
+			}{
+				{"address prefix", "tb1qsearch", []string{"search02", "search01"}},
+				{"address suffix", "0bbbb", []string{"search02"}},
+				{"ignores case and whitespace", "  TB1QSEARCHFIRST ", []string{"search01"}},
+				{"txid prefix", "4a5e1e4b", []string{"search02"}},
+				{"txid suffix", "fdeda33b", []string{"search02"}},
+				{"monero suffix", "684Rge", []string{"search03"}},
+				{"middle of an address", "searchsecond", nil},
+				{"no match", "zzzzzz", nil},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					got, err := SearchPayments(store, tt.fragment, 0)
+					if err != nil {
+						t.Fatalf("SearchPayments() error = %v", err)
+					}
+					var ids []string
+					for _, payment := range got {
+						ids = append(ids, payment.ID)
+					}
+					if len(ids) != len(tt.want) || (len(ids) > 0 && ids[0] != tt.want[0]) {
+						t.Errorf("SearchPayments(%q) = %v, want %v", tt.fragment, ids, tt.want)
+					}
+				})
+			}
+
+			if got, _ := SearchPayments(store, "tb1qsearch", 1); len(got) != 1 || got[0].ID != "search02" {
+				t.Errorf("SearchPayments() with limit 1 = %v, want the newest match", got)
+			}
+			if _, err := SearchPayments(store, "tb1", 0); !errors.Is(err, ErrSearchTooShort) {
+				t.Errorf("SearchPayments() with a short fragment error = %v, want ErrSearchTooShort", err)
+			}
+		})
+	}
+}
+
+// indexedSearchStore is a store that searches with its own index
+type indexedSearchStore struct {
+	*MemoryStore
+	searched string
+}
+
+func (s *indexedSearchStore) SearchPayments(fragment string, limit int) ([]*Payment, error) {
+	s.searched = fragment
+	return []*Payment{{ID: "indexed"}}, nil
+}
+
+func TestHandlePaymentSearch(t *testing.T) {
+	store := &indexedSearchStore{MemoryStore: NewMemoryStore()}
+	pw := newTestPaywall(t, Config{Store: store, APIKeysEnabled: true})
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{"match", "/admin/search?q=+tb1qabcd", http.StatusOK},
+		{"too short", "/admin/search?q=tb1", http.StatusBadRequest},
+		{"bad limit", "/admin/search?q=tb1qabcd&limit=x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			pw.HandlePaymentSearch(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp PaymentSearchResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Query != "tb1qabcd" || len(resp.Payments) != 1 || resp.Payments[0].ID != "indexed" || store.searched != "tb1qabcd" {
+				t.Errorf("response = %+v, searched %q; want the indexed store's result", resp, store.searched)
+			}
+		})
+	}
+}
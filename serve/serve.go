@@ -0,0 +1,240 @@
+// Package serve runs a paywalled handler as a production HTTP(S) server:
+// automatic Let's Encrypt certificates, HTTP to HTTPS redirects and graceful
+// shutdown on SIGINT/SIGTERM.
+//
+// Usage:
+//
+//	pw, _ := paywall.NewPaywall(config)
+//	err := serve.ListenAndServe(serve.Config{
+//	    Domain:  "example.com",
+//	    Email:   "admin@example.com",
+//	    Paywall: pw,
+//	}, pw.Middleware(content))
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/opd-ai/paywall"
+	wileedot "github.com/opd-ai/wileedot"
+)
+
+// Defaults applied by ListenAndServe and Serve
+const (
+	DefaultHTTPAddr          = ":80"
+	DefaultHTTPSAddr         = ":443"
+	DefaultPlainAddr         = ":8080"
+	DefaultCertDir           = "./certs"
+	DefaultShutdownTimeout   = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+)
+
+// Config configures the server
+type Config struct {
+	// Domain is the public hostname. When set, the handler is served over HTTPS
+	// with certificates obtained from Let's Encrypt, and plain HTTP redirects to
+	// HTTPS. When empty, the handler is served over plain HTTP on HTTPAddr, e.g.
+	// behind a TLS-terminating proxy or for local development.
+	Domain string
+	// AltDomains are additional hostnames on the certificate (e.g. "www.example.com")
+	AltDomains []string
+	// Email is the contact address given to Let's Encrypt for expiry notices
+	Email string
+	// CertDir caches certificates and the ACME account key.
+	// Optional: defaults to DefaultCertDir. Keep it across restarts to avoid rate limits.
+	CertDir string
+
+	// HTTPAddr is the plain HTTP listen address.
+	// Optional: defaults to DefaultHTTPAddr with Domain (redirects only) and to
+	// DefaultPlainAddr without it. Set to "-" to disable the redirect listener.
+	HTTPAddr string
+	// HTTPSAddr is the HTTPS listen address used with Domain.
+	// Optional: defaults to DefaultHTTPSAddr.
+	HTTPSAddr string
+
+	// ShutdownTimeout bounds how long in-flight requests may finish after a
+	// shutdown signal. Optional: defaults to DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout and IdleTimeout protect against slow clients.
+	// Optional: default to DefaultReadHeaderTimeout and DefaultIdleTimeout.
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+
+	// Paywall is closed once the servers have shut down, stopping its monitor
+	// after the last request was answered. Optional.
+	Paywall *paywall.Paywall
+}
+
+// ListenAndServe serves handler until SIGINT or SIGTERM, then shuts down
+// gracefully.
+//
+// Parameters:
+//   - cfg: Server configuration
+//   - handler: The application handler, typically wrapped with Paywall.Middleware
+//
+// Returns:
+//   - error: Listener or certificate setup errors, or a shutdown that did not
+//     finish within ShutdownTimeout; nil after a clean shutdown
+func ListenAndServe(cfg Config, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Serve(ctx, cfg, handler)
+}
+
+// Serve is ListenAndServe with a caller-controlled lifetime: it serves handler
+// until ctx is done, then shuts down gracefully.
+func Serve(ctx context.Context, cfg Config, handler http.Handler) error {
+	cfg.applyDefaults()
+
+	var servers []*http.Server
+	var listeners []net.Listener
+	closeListeners := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	if cfg.Domain != "" {
+		base, err := net.Listen("tcp", cfg.HTTPSAddr)
+		if err != nil {
+			return fmt.Errorf("listen on %s: %w", cfg.HTTPSAddr, err)
+		}
+		tlsListener, err := wileedot.New(wileedot.Config{
+			Domain:         cfg.Domain,
+			AllowedDomains: cfg.AltDomains,
+			CertDir:        cfg.CertDir,
+			Email:          cfg.Email,
+			BaseListener:   base,
+		})
+		if err != nil {
+			base.Close()
+			return fmt.Errorf("set up automatic HTTPS for %s: %w", cfg.Domain, err)
+		}
+		listeners = append(listeners, tlsListener)
+		servers = append(servers, cfg.newServer(handler))
+
+		if cfg.HTTPAddr != "-" {
+			plain, err := net.Listen("tcp", cfg.HTTPAddr)
+			if err != nil {
+				closeListeners()
+				return fmt.Errorf("listen on %s: %w", cfg.HTTPAddr, err)
+			}
+			listeners = append(listeners, plain)
+			servers = append(servers, cfg.newServer(RedirectToHTTPS(cfg.HTTPSAddr)))
+		}
+	} else {
+		plain, err := net.Listen("tcp", cfg.HTTPAddr)
+		if err != nil {
+			return fmt.Errorf("listen on %s: %w", cfg.HTTPAddr, err)
+		}
+		listeners = append(listeners, plain)
+		servers = append(servers, cfg.newServer(handler))
+	}
+
+	serveErr := make(chan error, len(servers))
+	for i, server := range servers {
+		go func(server *http.Server, l net.Listener) {
+			log.Printf("Serving on %s", l.Addr())
+			if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("serve on %s: %w", l.Addr(), err)
+				return
+			}
+			serveErr <- nil
+		}(server, listeners[i])
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+	if shutdownErr := shutdown(servers, cfg.ShutdownTimeout); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+	if cfg.Paywall != nil {
+		cfg.Paywall.Close()
+	}
+	return err
+}
+
+// RedirectToHTTPS returns a handler that permanently redirects every request to
+// the same host and path over HTTPS.
+//
+// Parameters:
+//   - httpsAddr: The HTTPS listen address; its port is kept in the redirect
+//     unless it is 443
+func RedirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		w.Header().Set("Connection", "close")
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// shutdown stops servers gracefully, waiting at most timeout for in-flight requests
+func shutdown(servers []*http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var errs []error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+			server.Close()
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("graceful shutdown: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// newServer returns an http.Server for handler with the configured timeouts
+func (cfg *Config) newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// applyDefaults fills in unset optional fields
+func (cfg *Config) applyDefaults() {
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = DefaultPlainAddr
+		if cfg.Domain != "" {
+			cfg.HTTPAddr = DefaultHTTPAddr
+		}
+	}
+	if cfg.HTTPSAddr == "" {
+		cfg.HTTPSAddr = DefaultHTTPSAddr
+	}
+	if cfg.CertDir == "" {
+		cfg.CertDir = DefaultCertDir
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if cfg.ReadHeaderTimeout <= 0 {
+		cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+}
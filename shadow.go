@@ -0,0 +1,76 @@
+package paywall
+
+import (
+	"fmt"
+	"time"
+)
+
+// shadowTransition reports a transition the monitor would make in
+// Config.MonitorShadow mode: it is logged and published as EventShadowDecision
+// instead of passing the payment hooks and the store. The transition is
+// applied to the in-memory payment only, so the rest of the check sees the
+// state a real monitor would. Each payment's decision is reported once.
+//
+// Parameters:
+//   - t: Transition the monitor would make
+func (m *CryptoChainMonitor) shadowTransition(t *PaymentTransition) {
+	t.Payment.Status = t.To
+
+	m.shadowMu.Lock()
+	if m.shadowReported == nil {
+		m.shadowReported = make(map[string]TransitionEvent)
+	}
+	reported := m.shadowReported[t.Payment.ID] == t.Event
+	m.shadowReported[t.Payment.ID] = t.Event
+	m.shadowMu.Unlock()
+	if reported {
+		return
+	}
+
+	message := fmt.Sprintf("Shadow mode: would %s payment (%s -> %s)", t.Event, t.From, t.To)
+	if t.Currency != "" {
+		message += fmt.Sprintf(", %.12g %s received", t.Amount, t.Currency)
+	}
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelInfo,
+		Event:     "shadow_" + string(t.Event),
+		Message:   message,
+		PaymentID: t.Payment.ID,
+		Amount:    t.Amount,
+		Currency:  t.Currency,
+	})
+	data := map[string]interface{}{
+		"action": t.Event,
+		"from":   t.From,
+		"to":     t.To,
+	}
+	if t.Currency != "" {
+		data["currency"] = t.Currency
+		data["amount"] = t.Amount
+	}
+	m.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventShadowDecision,
+		PaymentID: t.Payment.ID,
+		Timestamp: time.Now(),
+		Data:      data,
+	})
+}
+
+// pruneShadowReports forgets the decisions of payments no longer listed as
+// pending, e.g. because the production monitor confirmed them
+func (m *CryptoChainMonitor) pruneShadowReports(payments []*Payment) {
+	m.shadowMu.Lock()
+	defer m.shadowMu.Unlock()
+	if len(m.shadowReported) == 0 {
+		return
+	}
+	listed := make(map[string]bool, len(payments))
+	for _, payment := range payments {
+		listed[payment.ID] = true
+	}
+	for id := range m.shadowReported {
+		if !listed[id] {
+			delete(m.shadowReported, id)
+		}
+	}
+}
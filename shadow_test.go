@@ -0,0 +1,97 @@
+package paywall
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestMonitorShadow(t *testing.T) {
+	store := NewMemoryStore()
+	logger := NewStructuredLogger(io.Discard, LogLevelError, true)
+	publisher := &recordingPublisher{}
+	pw := &Paywall{
+		Store:            store,
+		minConfirmations: 1,
+		logger:           logger,
+		monitorShadow:    true,
+		eventSink:        newEventSink(EventSinkConfig{Publisher: publisher}, logger, nil),
+	}
+	hookCalls := 0
+	pw.Use(func(next TransitionFunc) TransitionFunc {
+		return func(t *PaymentTransition) error {
+			hookCalls++
+			return next(t)
+		}
+	})
+	client := &mockCryptoClient{}
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: client,
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+
+	newPayment := func(id string, amount float64, expiresAt time.Time) *Payment {
+		store.CreatePayment(&Payment{
+			ID:        id,
+			Addresses: map[wallet.WalletType]string{wallet.Bitcoin: id + "-address"},
+			Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: amount},
+			CreatedAt: time.Now().Add(-time.Hour),
+			ExpiresAt: expiresAt,
+			Status:    StatusPending,
+		})
+		stored, _ := store.GetPayment(id)
+		return stored
+	}
+	client.balance = 0.001
+	paid := newPayment("paid", 0.001, time.Now().Add(time.Hour))
+	// The balance never covers this one
+	newPayment("expired", 1, time.Now().Add(-time.Minute))
+
+	// Two cycles: decisions are reported once
+	for i := 0; i < 2; i++ {
+		if err := monitor.checkPendingPayments(); err != nil {
+			t.Fatalf("checkPendingPayments() error = %v", err)
+		}
+	}
+
+	for _, id := range []string{"paid", "expired"} {
+		payment, _ := store.GetPayment(id)
+		if payment.Status != StatusPending {
+			t.Errorf("%s status = %s, want pending", id, payment.Status)
+		}
+		if payment.CheckCount != 0 {
+			t.Errorf("%s check count = %d, want 0 (nothing written)", id, payment.CheckCount)
+		}
+	}
+	if stored, _ := store.GetPayment("paid"); stored.Version != paid.Version {
+		t.Errorf("paid version = %d, want %d", stored.Version, paid.Version)
+	}
+	if hookCalls != 0 {
+		t.Errorf("payment hooks called %d times in shadow mode", hookCalls)
+	}
+
+	pw.eventSink.close()
+	actions := make(map[string]string)
+	for _, msg := range publisher.messages {
+		var event PaymentEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			t.Fatalf("unmarshal event: %v", err)
+		}
+		if event.Event != EventShadowDecision {
+			t.Errorf("event = %s, want %s", event.Event, EventShadowDecision)
+		}
+		if _, dup := actions[event.PaymentID]; dup {
+			t.Errorf("decision for %s reported twice", event.PaymentID)
+		}
+		actions[event.PaymentID], _ = event.Data["action"].(string)
+	}
+	if actions["paid"] != string(TransitionConfirm) || actions["expired"] != string(TransitionExpire) {
+		t.Errorf("shadow decisions = %v, want paid: confirm, expired: expire", actions)
+	}
+}
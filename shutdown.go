@@ -0,0 +1,171 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// closeTimeout bounds how long Close waits for background work to drain
+const closeTimeout = 30 * time.Second
+
+// workerGroup tracks the paywall's background work - monitor cycles, expiry
+// sweeps and final checks - so that CloseContext can wait for it. The zero
+// value is ready to use.
+type workerGroup struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stop    chan struct{}
+	closing bool
+}
+
+// start registers a unit of work, reporting false once the group is closing.
+// Each successful start must be followed by done.
+func (g *workerGroup) start() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+// done ends a unit of work registered with start
+func (g *workerGroup) done() {
+	g.wg.Done()
+}
+
+// stopping returns a channel that is closed once the group is closing
+func (g *workerGroup) stopping() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stop == nil {
+		g.stop = make(chan struct{})
+	}
+	return g.stop
+}
+
+// stopped reports whether the group is closing
+func (g *workerGroup) stopped() bool {
+	select {
+	case <-g.stopping():
+		return true
+	default:
+		return false
+	}
+}
+
+// close refuses new work and asks the running work to stop at its next
+// opportunity
+func (g *workerGroup) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return
+	}
+	g.closing = true
+	if g.stop == nil {
+		g.stop = make(chan struct{})
+	}
+	close(g.stop)
+}
+
+// wait blocks until the registered work is done or ctx ends
+func (g *workerGroup) wait(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseContext shuts the paywall down gracefully, e.g. when systemd or
+// Kubernetes stops the process. It stops the monitor and the other
+// background workers from starting new work and waits for the payment checks
+// in progress to store their results. Then it applies the changes still in
+// the monitor log (Config.MonitorWALPath), saves the Bitcoin wallet's next
+// address index (ConstructPaywall's wallet), and publishes the events and
+// notifications still queued.
+//
+// Parameters:
+//   - ctx: Bounds the wait for the checks in progress. When it ends first,
+//     their chain and store calls are cancelled; confirmations already
+//     recorded in the monitor log are applied on the next start.
+//
+// Returns:
+//   - error: ctx's error if the work did not drain in time, or the error of
+//     saving the wallet
+//
+// Related: Close
+func (p *Paywall) CloseContext(ctx context.Context) error {
+	// Stop price refreshes before the event publishers close
+	if p.priceRefresher != nil {
+		p.priceRefresher.Stop()
+	}
+	if p.statsRecorder != nil {
+		p.statsRecorder.Stop()
+	}
+	if p.retentionPurger != nil {
+		p.retentionPurger.Stop()
+	}
+	// Stop timeout monitor if running
+	if p.timeoutMonitor != nil {
+		p.timeoutMonitor.Stop()
+	}
+	// Stop consensus manager if running
+	if p.consensusManager != nil {
+		p.consensusManager.Stop()
+	}
+	// Stop webhook dispatcher if running
+	if p.webhookDispatcher != nil {
+		p.webhookDispatcher.Close()
+	}
+
+	// Let the checks in progress finish, then store what the monitor decided
+	// but could not store
+	p.workers.close()
+	var errs []error
+	if err := p.workers.wait(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain payment checks: %w", err))
+	} else if p.monitor != nil {
+		p.monitor.gmux.Lock()
+		p.monitor.replayWAL()
+		p.monitor.gmux.Unlock()
+	}
+	p.cancel()
+	p.monitorWAL.close()
+	if err := p.persistWallets(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Publish the events still queued
+	if p.eventSink != nil {
+		p.eventSink.close()
+	}
+	for _, notifier := range p.notifiers {
+		notifier.close()
+	}
+	return errors.Join(errs...)
+}
+
+// Close is CloseContext waiting at most 30 seconds, with its error logged
+func (p *Paywall) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := p.CloseContext(ctx); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "shutdown_incomplete",
+			Message: fmt.Sprintf("Paywall did not shut down cleanly: %v", err),
+		})
+	}
+}
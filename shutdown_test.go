@@ -0,0 +1,116 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// blockingCryptoClient reports balance once release is closed
+type blockingCryptoClient struct {
+	balance float64
+	once    sync.Once
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCryptoClient) GetAddressBalance(address string) (float64, error) {
+	c.once.Do(func() { close(c.called) })
+	<-c.release
+	return c.balance, nil
+}
+
+func TestCloseContext_DrainsChecks(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		timeout time.Duration
+		wantErr error
+		want    PaymentStatus
+	}{
+		{"drained", time.Minute, nil, StatusConfirmed},
+		{"deadline", 20 * time.Millisecond, context.DeadlineExceeded, StatusPending},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: store})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			payment, err := pw.CreatePayment()
+			if err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			client := &blockingCryptoClient{balance: 0.001, called: make(chan struct{}), release: make(chan struct{})}
+			pw.monitor.client = map[wallet.WalletType]CryptoClient{wallet.Bitcoin: client}
+
+			// A monitor cycle in progress, as the monitor's loop runs it
+			if !pw.workers.start() {
+				t.Fatal("workers refused work before Close")
+			}
+			go func() {
+				defer pw.workers.done()
+				pw.monitor.checkPendingPayments()
+			}()
+			<-client.called
+
+			closed := make(chan error, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+				defer cancel()
+				closed <- pw.CloseContext(ctx)
+			}()
+			if tt.wantErr == nil {
+				select {
+				case err := <-closed:
+					t.Fatalf("CloseContext() = %v before the check finished", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				close(client.release)
+			}
+			if err := <-closed; tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("CloseContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				close(client.release)
+			}
+
+			if pw.workers.start() {
+				t.Error("workers accepted work after Close")
+			}
+			if tt.wantErr != nil {
+				// The abandoned check finishes against a cancelled context
+				time.Sleep(20 * time.Millisecond)
+			}
+			if stored, _ := store.GetPayment(payment.ID); stored.Status != tt.want {
+				t.Errorf("payment status after Close = %s, want %s", stored.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseContext_SavesWallet(t *testing.T) {
+	pw, err := ConstructPaywall(t.TempDir())
+	if err != nil {
+		t.Fatalf("ConstructPaywall() error = %v", err)
+	}
+	btcWallet := pw.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet)
+	for range 3 {
+		if _, err := btcWallet.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+	}
+	if err := pw.CloseContext(context.Background()); err != nil {
+		t.Fatalf("CloseContext() error = %v", err)
+	}
+	loaded, err := wallet.LoadFromFile(*pw.walletStorage)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if got, want := loaded.GetNextIndex(), btcWallet.GetNextIndex(); got != want {
+		t.Errorf("saved next index = %d, want %d", got, want)
+	}
+}
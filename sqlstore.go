@@ -0,0 +1,810 @@
+package paywall
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects the SQL syntax SQLStore uses
+type SQLDialect string
+
+const (
+	// DialectPostgres is PostgreSQL, e.g. with github.com/jackc/pgx/v5/stdlib
+	DialectPostgres SQLDialect = "postgres"
+	// DialectMySQL is MySQL 8 or MariaDB, e.g. with github.com/go-sql-driver/mysql
+	DialectMySQL SQLDialect = "mysql"
+	// DialectSQLite is SQLite 3.24 or newer, e.g. with modernc.org/sqlite
+	DialectSQLite SQLDialect = "sqlite"
+)
+
+const (
+	// defaultSQLTablePrefix is the default SQLStoreConfig.TablePrefix
+	defaultSQLTablePrefix = "paywall_"
+	// sqlStreamPageSize is how many payments StreamPayments reads per query
+	sqlStreamPageSize = 500
+)
+
+// sqlTablePrefixPattern restricts table prefixes to identifier characters,
+// as the prefix is part of every statement
+var sqlTablePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+// SQLStoreConfig configures a SQLStore
+type SQLStoreConfig struct {
+	// Dialect is the database's SQL dialect: DialectPostgres, DialectMySQL or
+	// DialectSQLite
+	Dialect SQLDialect
+	// TablePrefix is prepended to the store's table names, to share a database.
+	// Optional: defaults to "paywall_".
+	TablePrefix string
+	// SkipMigrations leaves the schema alone in NewSQLStore, for deployments
+	// that run Migrate from a single job before starting instances.
+	// Optional: defaults to false, migrating on creation.
+	SkipMigrations bool
+	// ValidateAddresses rejects payments whose addresses fail
+	// ValidatePaymentAddresses on CreatePayment and UpdatePayment.
+	// Optional: defaults to false.
+	ValidateAddresses bool
+}
+
+// SQLStore is a PaymentStore backed by a SQL database through database/sql,
+// so several instances can share payment state. Import the driver of your
+// database yourself; the store only needs the *sql.DB.
+//
+// Payments are stored as JSON documents next to the columns that are queried:
+// status, creation time and version. A separate table indexes payment
+// addresses for GetPaymentByAddress. Creates and updates run in transactions,
+// and UpdatePayment only replaces the version it was read at, returning
+// ErrVersionConflict otherwise.
+//
+// SQLStore does not implement PaymentLocker; when several instances share a
+// database, configure Config.PaymentLocker to serialize payment updates.
+//
+// Related: PaymentStore, PaymentLister, PaymentStreamer, APIKeyStore, StatsStore
+type SQLStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	prefix  string
+	// validateAddresses is SQLStoreConfig.ValidateAddresses
+	validateAddresses bool
+}
+
+// NewSQLStore creates a payment store in db and migrates its schema to the
+// current version, unless config.SkipMigrations is set.
+//
+// Parameters:
+//   - db: Open database handle
+//   - config: Dialect and options
+//
+// Returns:
+//   - *SQLStore: The store
+//   - error: If the configuration is invalid or the migration fails
+//
+// Example:
+//
+//	db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+//	store, err := paywall.NewSQLStore(db, paywall.SQLStoreConfig{Dialect: paywall.DialectPostgres})
+func NewSQLStore(db *sql.DB, config SQLStoreConfig) (*SQLStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("SQL store requires a database handle")
+	}
+	switch config.Dialect {
+	case DialectPostgres, DialectMySQL, DialectSQLite:
+	default:
+		return nil, fmt.Errorf("unknown SQL dialect %q (hint: use DialectPostgres, DialectMySQL or DialectSQLite)", config.Dialect)
+	}
+	if config.TablePrefix == "" {
+		config.TablePrefix = defaultSQLTablePrefix
+	}
+	if !sqlTablePrefixPattern.MatchString(config.TablePrefix) {
+		return nil, fmt.Errorf("SQL table prefix %q may only contain letters, digits and underscores", config.TablePrefix)
+	}
+	s := &SQLStore{
+		db:                db,
+		dialect:           config.Dialect,
+		prefix:            config.TablePrefix,
+		validateAddresses: config.ValidateAddresses,
+	}
+	if !config.SkipMigrations {
+		if err := s.Migrate(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// table returns the full name of one of the store's tables
+func (s *SQLStore) table(name string) string {
+	return s.prefix + name
+}
+
+// rebind rewrites ? placeholders to the dialect's syntax
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// documentType is the column type of JSON documents
+func (s *SQLStore) documentType() string {
+	if s.dialect == DialectMySQL {
+		return "LONGTEXT"
+	}
+	return "TEXT"
+}
+
+// upsert returns an INSERT of key and data into table that replaces the data
+// of an existing row with the same key
+func (s *SQLStore) upsert(table, key string) string {
+	query := "INSERT INTO " + s.table(table) + " (" + key + ", data) VALUES (?, ?)"
+	if s.dialect == DialectMySQL {
+		return query + " ON DUPLICATE KEY UPDATE data = VALUES(data)"
+	}
+	return query + " ON CONFLICT (" + key + ") DO UPDATE SET data = excluded.data"
+}
+
+// migrations returns the schema migrations, oldest first; migration i brings
+// the schema to version i+1. Append new migrations, never change old ones.
+func (s *SQLStore) migrations() [][]string {
+	doc := s.documentType()
+	return [][]string{{
+		"CREATE TABLE IF NOT EXISTS " + s.table("payments") + " (" +
+			"id VARCHAR(191) NOT NULL PRIMARY KEY, " +
+			"status VARCHAR(32) NOT NULL, " +
+			"created_at BIGINT NOT NULL, " +
+			"version INTEGER NOT NULL, " +
+			"data " + doc + " NOT NULL)",
+		"CREATE INDEX " + s.table("payments_status") + " ON " + s.table("payments") + " (status)",
+		"CREATE INDEX " + s.table("payments_created_at") + " ON " + s.table("payments") + " (created_at)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("payment_addresses") + " (" +
+			"address VARCHAR(191) NOT NULL PRIMARY KEY, " +
+			"payment_id VARCHAR(191) NOT NULL)",
+		"CREATE INDEX " + s.table("payment_addresses_payment_id") + " ON " + s.table("payment_addresses") + " (payment_id)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("api_keys") + " (" +
+			"id VARCHAR(191) NOT NULL PRIMARY KEY, " +
+			"data " + doc + " NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("stats_snapshots") + " (" +
+			"taken_at BIGINT NOT NULL PRIMARY KEY, " +
+			"data " + doc + " NOT NULL)",
+	}, {
+		"CREATE TABLE IF NOT EXISTS " + s.table("payments_archive") + " (" +
+			"id VARCHAR(191) NOT NULL PRIMARY KEY, " +
+			"data " + doc + " NOT NULL)",
+	}, {
+		"CREATE TABLE IF NOT EXISTS " + s.table("address_indexes") + " (" +
+			"name VARCHAR(191) NOT NULL PRIMARY KEY, " +
+			"next_index BIGINT NOT NULL)",
+	}}
+}
+
+// Migrate brings the schema to the current version, applying each missing
+// migration in its own transaction and recording it in the
+// <prefix>schema_migrations table. It is safe to call on every start; when
+// instances start at once and race for a migration, the losers accept it once
+// the winner recorded it.
+//
+// Returns:
+//   - error: If a migration fails
+func (s *SQLStore) Migrate() error {
+	_, err := s.db.Exec("CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (" +
+		"version INTEGER NOT NULL PRIMARY KEY, " +
+		"applied_at BIGINT NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	for i, statements := range s.migrations() {
+		version := i + 1
+		if version <= current {
+			continue
+		}
+		if err := s.applyMigration(version, statements); err != nil {
+			if applied, verr := s.SchemaVersion(); verr == nil && applied >= version {
+				continue // another instance applied it
+			}
+			return fmt.Errorf("apply SQL schema migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs the statements of a migration and records its version
+func (s *SQLStore) applyMigration(version int, statements []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	insert := s.rebind("INSERT INTO " + s.table("schema_migrations") + " (version, applied_at) VALUES (?, ?)")
+	if _, err := tx.Exec(insert, version, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the number of applied schema migrations
+//
+// Returns:
+//   - int: The schema version, 0 for an empty database
+//   - error: If the schema_migrations table cannot be read
+func (s *SQLStore) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRow("SELECT MAX(version) FROM " + s.table("schema_migrations")).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read SQL schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// checkPayment validates a payment's ID and, with ValidateAddresses, its addresses
+func (s *SQLStore) checkPayment(p *Payment) error {
+	if p.ID == "" {
+		return fmt.Errorf("payment ID is required")
+	}
+	if s.validateAddresses {
+		return ValidatePaymentAddresses(p)
+	}
+	return nil
+}
+
+// CreatePayment stores a new payment and indexes its addresses in one transaction.
+//
+// Returns:
+//   - error: If the payment or one of its addresses already exists, or the database fails
+func (s *SQLStore) CreatePayment(p *Payment) error {
+	return s.CreatePaymentContext(context.Background(), p)
+}
+
+// CreatePaymentContext is CreatePayment with a context that cancels the
+// database calls
+func (s *SQLStore) CreatePaymentContext(ctx context.Context, p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	insert := s.rebind("INSERT INTO " + s.table("payments") + " (id, status, created_at, version, data) VALUES (?, ?, ?, ?, ?)")
+	if _, err := tx.ExecContext(ctx, insert, p.ID, string(p.Status), p.CreatedAt.UnixMilli(), p.Version, string(data)); err != nil {
+		return fmt.Errorf("store payment: %w", err)
+	}
+	if err := s.indexAddresses(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit payment: %w", err)
+	}
+	return nil
+}
+
+// indexAddresses adds the addresses of p to the address table
+func (s *SQLStore) indexAddresses(ctx context.Context, tx *sql.Tx, p *Payment) error {
+	insert := s.rebind("INSERT INTO " + s.table("payment_addresses") + " (address, payment_id) VALUES (?, ?)")
+	for _, address := range p.Addresses {
+		if address == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert, address, p.ID); err != nil {
+			return fmt.Errorf("index payment address: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPayment retrieves a payment by ID.
+//
+// Returns:
+//   - *Payment: The payment, nil if not found
+//   - error: Database, unmarshaling or migration errors
+func (s *SQLStore) GetPayment(id string) (*Payment, error) {
+	return s.GetPaymentContext(context.Background(), id)
+}
+
+// GetPaymentContext is GetPayment with a context
+func (s *SQLStore) GetPaymentContext(ctx context.Context, id string) (*Payment, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind("SELECT data FROM "+s.table("payments")+" WHERE id = ?"), id)
+	return scanPayment(row)
+}
+
+// GetPaymentByAddress retrieves a payment by one of its addresses using the address table.
+//
+// Returns:
+//   - *Payment: The payment, nil if no payment has the address
+//   - error: Database errors
+func (s *SQLStore) GetPaymentByAddress(addr string) (*Payment, error) {
+	return s.GetPaymentByAddressContext(context.Background(), addr)
+}
+
+// GetPaymentByAddressContext is GetPaymentByAddress with a context
+func (s *SQLStore) GetPaymentByAddressContext(ctx context.Context, addr string) (*Payment, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	row := s.db.QueryRowContext(ctx, s.rebind("SELECT p.data FROM "+s.table("payments")+" p JOIN "+
+		s.table("payment_addresses")+" a ON a.payment_id = p.id WHERE a.address = ?"), addr)
+	return scanPayment(row)
+}
+
+// scanPayment decodes the payment document of row, nil if there is no row
+func scanPayment(row *sql.Row) (*Payment, error) {
+	var data string
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read payment: %w", err)
+	}
+	return decodeSQLPayment(data)
+}
+
+// decodeSQLPayment unmarshals and migrates a payment document
+func decodeSQLPayment(data string) (*Payment, error) {
+	var payment Payment
+	if err := json.Unmarshal([]byte(data), &payment); err != nil {
+		return nil, fmt.Errorf("unmarshal payment: %w", err)
+	}
+	if err := MigratePayment(&payment); err != nil {
+		return nil, fmt.Errorf("migrate payment: %w", err)
+	}
+	return &payment, nil
+}
+
+// UpdatePayment replaces a payment if it still has the version p was read at,
+// and re-indexes its addresses, in one transaction.
+//
+// Returns:
+//   - error: ErrVersionConflict if another writer changed the payment, an
+//     error if the payment does not exist, database errors otherwise
+func (s *SQLStore) UpdatePayment(p *Payment) error {
+	return s.UpdatePaymentContext(context.Background(), p)
+}
+
+// UpdatePaymentContext is UpdatePayment with a context that cancels the
+// database calls
+func (s *SQLStore) UpdatePaymentContext(ctx context.Context, p *Payment) error {
+	if err := s.checkPayment(p); err != nil {
+		return err
+	}
+	p.Version++
+	data, err := json.Marshal(p)
+	p.Version--
+	if err != nil {
+		return fmt.Errorf("marshal payment: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	update := s.rebind("UPDATE " + s.table("payments") + " SET status = ?, version = ?, data = ? WHERE id = ? AND version = ?")
+	result, err := tx.ExecContext(ctx, update, string(p.Status), p.Version+1, string(data), p.ID, p.Version)
+	if err != nil {
+		return fmt.Errorf("update payment: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("update payment: %w", err)
+	} else if n == 0 {
+		var version int
+		err := tx.QueryRowContext(ctx, s.rebind("SELECT version FROM "+s.table("payments")+" WHERE id = ?"), p.ID).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("payment %s not found", p.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("read payment version: %w", err)
+		}
+		return ErrVersionConflict
+	}
+
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM "+s.table("payment_addresses")+" WHERE payment_id = ?"), p.ID); err != nil {
+		return fmt.Errorf("remove payment addresses: %w", err)
+	}
+	if err := s.indexAddresses(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit payment: %w", err)
+	}
+	p.Version++
+	return nil
+}
+
+// ListPendingPayments returns all payments with less than 1 confirmation.
+func (s *SQLStore) ListPendingPayments() ([]*Payment, error) {
+	return s.listPayments(PaymentFilter{}, func(p *Payment) bool { return p.Confirmations < 1 })
+}
+
+// ListPayments returns every stored payment.
+func (s *SQLStore) ListPayments() ([]*Payment, error) {
+	return s.listPayments(PaymentFilter{}, nil)
+}
+
+// GetPendingMultisigPayments returns all pending payments that have multisig enabled.
+func (s *SQLStore) GetPendingMultisigPayments() ([]*Payment, error) {
+	return s.listPayments(PaymentFilter{Statuses: []PaymentStatus{StatusPending}}, func(p *Payment) bool {
+		return p.MultisigEnabled
+	})
+}
+
+// DeletePayment removes a payment and its addresses in one transaction.
+//
+// Returns:
+//   - error: Database errors; deleting a missing payment succeeds
+func (s *SQLStore) DeletePayment(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(s.rebind("DELETE FROM "+s.table("payment_addresses")+" WHERE payment_id = ?"), id); err != nil {
+		return fmt.Errorf("remove payment addresses: %w", err)
+	}
+	if _, err := tx.Exec(s.rebind("DELETE FROM "+s.table("payments")+" WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("delete payment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit payment deletion: %w", err)
+	}
+	return nil
+}
+
+// ArchivePayments moves the payments ArchivablePayment selects for olderThan
+// to the <prefix>payments_archive table, each in its own transaction. A
+// payment changed since it was read is left for the next call.
+//
+// Returns:
+//   - int: How many payments were moved
+//   - error: Database errors
+func (s *SQLStore) ArchivePayments(olderThan time.Time) (int, error) {
+	old, err := s.listPayments(PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed, StatusExpired}}, func(p *Payment) bool {
+		return ArchivablePayment(p, olderThan)
+	})
+	if err != nil {
+		return 0, err
+	}
+	archived := 0
+	for _, p := range old {
+		moved, err := s.archivePayment(p)
+		if err != nil {
+			return archived, err
+		}
+		if moved {
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// archivePayment copies p to the archive table and removes it and its
+// addresses, unless its version changed since it was read
+func (s *SQLStore) archivePayment(p *Payment) (bool, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return false, fmt.Errorf("marshal payment: %w", err)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(s.rebind("DELETE FROM "+s.table("payments")+" WHERE id = ? AND version = ?"), p.ID, p.Version)
+	if err != nil {
+		return false, fmt.Errorf("delete payment: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.Exec(s.rebind("DELETE FROM "+s.table("payment_addresses")+" WHERE payment_id = ?"), p.ID); err != nil {
+		return false, fmt.Errorf("remove payment addresses: %w", err)
+	}
+	if _, err := tx.Exec(s.rebind(s.upsert("payments_archive", "id")), p.ID, string(data)); err != nil {
+		return false, fmt.Errorf("archive payment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit payment archive: %w", err)
+	}
+	return true, nil
+}
+
+// GetEscrowsExpiringBefore returns funded or disputed escrows expiring before deadline.
+func (s *SQLStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error) {
+	return s.listPayments(PaymentFilter{}, func(p *Payment) bool {
+		return p.MultisigEnabled &&
+			(p.EscrowState == EscrowFunded || p.EscrowState == EscrowDisputed) &&
+			!p.EscrowTimeout.IsZero() && p.EscrowTimeout.Before(deadline)
+	})
+}
+
+// listPayments returns the payments matching filter and keep (which may be nil)
+func (s *SQLStore) listPayments(filter PaymentFilter, keep func(*Payment) bool) ([]*Payment, error) {
+	var payments []*Payment
+	err := s.StreamPayments(filter, func(p *Payment) error {
+		if keep == nil || keep(p) {
+			payments = append(payments, p)
+		}
+		return nil
+	})
+	return payments, err
+}
+
+// StreamPayments calls fn for every payment matching filter. Statuses,
+// creation times and partitions are selected by the database; payments are
+// read in pages of 500 ordered by ID, and no query is open while fn runs.
+// Implements PaymentStreamer. Documents that cannot be parsed are logged and
+// skipped.
+//
+// Returns:
+//   - error: Database errors, or fn's error other than ErrStopStream
+func (s *SQLStore) StreamPayments(filter PaymentFilter, fn func(*Payment) error) error {
+	where, args := s.filterClause(filter)
+	after := ""
+	for {
+		query := "SELECT id, data FROM " + s.table("payments") + " WHERE id > ?" + where +
+			" ORDER BY id LIMIT " + strconv.Itoa(sqlStreamPageSize)
+		page, last, err := s.readPage(query, append([]interface{}{after}, args...))
+		if err != nil {
+			return err
+		}
+		for _, payment := range page {
+			if !filter.Matches(payment) {
+				continue
+			}
+			if err := fn(payment); err != nil {
+				return streamEnded(err)
+			}
+		}
+		if last == "" {
+			return nil
+		}
+		after = last
+	}
+}
+
+// filterClause returns the conditions selecting a superset of the payments
+// matching filter, to be appended to a WHERE clause, and their arguments
+func (s *SQLStore) filterClause(filter PaymentFilter) (string, []interface{}) {
+	var where strings.Builder
+	var args []interface{}
+	if len(filter.Statuses) > 0 {
+		where.WriteString(" AND status IN (?" + strings.Repeat(", ?", len(filter.Statuses)-1) + ")")
+		for _, status := range filter.Statuses {
+			args = append(args, string(status))
+		}
+	}
+	// Timestamps are stored in milliseconds; the bounds are rounded outwards
+	// and filter.Matches checks them exactly
+	if !filter.CreatedFrom.IsZero() {
+		where.WriteString(" AND created_at >= ?")
+		args = append(args, filter.CreatedFrom.UnixMilli())
+	}
+	if !filter.CreatedTo.IsZero() {
+		where.WriteString(" AND created_at <= ?")
+		args = append(args, filter.CreatedTo.UnixMilli())
+	}
+	if filter.Partition != "" {
+		where.WriteString(" AND id LIKE ?")
+		args = append(args, partitionIDPrefix(filter.Partition)+"%")
+	}
+	return where.String(), args
+}
+
+// readPage runs a page query of StreamPayments
+//
+// Returns:
+//   - []*Payment: The payments of the page
+//   - string: The last ID of a full page, "" if this was the last page
+//   - error: Database errors
+func (s *SQLStore) readPage(query string, args []interface{}) ([]*Payment, string, error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("list payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*Payment
+	var id, last string
+	n := 0
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, "", fmt.Errorf("list payments: %w", err)
+		}
+		n++
+		last = id
+		payment, err := decodeSQLPayment(data)
+		if err != nil {
+			log.Printf("Error reading payment %s: %v", id, err)
+			continue
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("list payments: %w", err)
+	}
+	if n < sqlStreamPageSize {
+		last = ""
+	}
+	return payments, last, nil
+}
+
+// SaveAPIKey creates or replaces an API key record.
+func (s *SQLStore) SaveAPIKey(key *APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal API key: %w", err)
+	}
+	if _, err := s.db.Exec(s.rebind(s.upsert("api_keys", "id")), key.ID, string(data)); err != nil {
+		return fmt.Errorf("store API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves an API key record by ID, nil if not found.
+func (s *SQLStore) GetAPIKey(id string) (*APIKey, error) {
+	var data string
+	err := s.db.QueryRow(s.rebind("SELECT data FROM "+s.table("api_keys")+" WHERE id = ?"), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read API key: %w", err)
+	}
+	var key APIKey
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return nil, fmt.Errorf("unmarshal API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns all API key records, skipping unreadable ones.
+func (s *SQLStore) ListAPIKeys() ([]*APIKey, error) {
+	rows, err := s.db.Query("SELECT id, data FROM " + s.table("api_keys"))
+	if err != nil {
+		return nil, fmt.Errorf("list API keys: %w", err)
+	}
+	defer rows.Close()
+	var keys []*APIKey
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("list API keys: %w", err)
+		}
+		var key APIKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			log.Printf("Error parsing API key %s: %v", id, err)
+			continue
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// SaveStatsSnapshot stores a stats snapshot under its time, replacing one
+// taken in the same millisecond.
+func (s *SQLStore) SaveStatsSnapshot(snapshot *StatsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal stats snapshot: %w", err)
+	}
+	if _, err := s.db.Exec(s.rebind(s.upsert("stats_snapshots", "taken_at")), snapshot.Time.UnixMilli(), string(data)); err != nil {
+		return fmt.Errorf("store stats snapshot: %w", err)
+	}
+	return nil
+}
+
+// ListStatsSnapshots returns the stats snapshots taken in [from, to), oldest
+// first, skipping unreadable ones.
+func (s *SQLStore) ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error) {
+	rows, err := s.db.Query(s.rebind("SELECT data FROM "+s.table("stats_snapshots")+
+		" WHERE taken_at >= ? AND taken_at < ? ORDER BY taken_at"), from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("list stats snapshots: %w", err)
+	}
+	defer rows.Close()
+	var snapshots []*StatsSnapshot
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("list stats snapshots: %w", err)
+		}
+		var snapshot StatsSnapshot
+		if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+			log.Printf("Error parsing stats snapshot: %v", err)
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list stats snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// DeleteStatsSnapshotsBefore deletes the stats snapshots taken before t.
+func (s *SQLStore) DeleteStatsSnapshotsBefore(t time.Time) error {
+	if _, err := s.db.Exec(s.rebind("DELETE FROM "+s.table("stats_snapshots")+" WHERE taken_at < ?"), t.UnixMilli()); err != nil {
+		return fmt.Errorf("delete stats snapshots: %w", err)
+	}
+	return nil
+}
+
+// sqlReserveAttempts bounds the retries of ReserveAddressIndex when other
+// instances reserve indexes of the same wallet at the same time
+const sqlReserveAttempts = 16
+
+// ReserveAddressIndex implements AddressIndexReserver with a row per wallet
+// in <prefix>address_indexes, advanced by a compare-and-swap update so it
+// works the same on every supported database.
+//
+// Returns:
+//   - uint32: The reserved index, at least floor
+//   - error: Query errors, or errAddressIndexConflict after repeated races
+func (s *SQLStore) ReserveAddressIndex(ctx context.Context, key string, floor uint32) (uint32, error) {
+	query := s.rebind("SELECT next_index FROM " + s.table("address_indexes") + " WHERE name = ?")
+	insert := s.rebind("INSERT INTO " + s.table("address_indexes") + " (name, next_index) VALUES (?, ?)")
+	update := s.rebind("UPDATE " + s.table("address_indexes") + " SET next_index = ? WHERE name = ? AND next_index = ?")
+	for attempt := 0; attempt < sqlReserveAttempts; attempt++ {
+		var stored int64
+		err := s.db.QueryRowContext(ctx, query, key).Scan(&stored)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Another instance inserting the row first fails the insert on
+			// the primary key; the next attempt updates its row
+			if _, err := s.db.ExecContext(ctx, insert, key, int64(floor)+1); err == nil {
+				return floor, nil
+			} else if ctx.Err() != nil {
+				return 0, fmt.Errorf("reserve address index: %w", ctx.Err())
+			}
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read address index: %w", err)
+		}
+		index := max(uint32(stored), floor)
+		result, err := s.db.ExecContext(ctx, update, int64(index)+1, key, stored)
+		if err != nil {
+			return 0, fmt.Errorf("update address index: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return 0, fmt.Errorf("update address index: %w", err)
+		} else if n == 1 {
+			return index, nil
+		}
+	}
+	return 0, errAddressIndexConflict
+}
@@ -0,0 +1,597 @@
+package paywall
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// fakeSQLDriver is a minimal in-memory database understanding the statements
+// SQLStore issues: CREATE TABLE/INDEX, INSERT (with upserts), UPDATE and
+// DELETE with simple conditions, and SELECT of columns with conditions,
+// ORDER BY, LIMIT, MAX() and SQLStore's payment/address join. Statements are
+// accepted with ? and $n placeholders.
+type fakeSQLDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeSQLDB
+}
+
+var fakeSQL = &fakeSQLDriver{dbs: make(map[string]*fakeSQLDB)}
+
+func init() { sql.Register("paywallfake", fakeSQL) }
+
+// fakeSQLDB holds the tables of one DSN
+type fakeSQLDB struct {
+	mu      sync.Mutex
+	tables  map[string][]map[string]driver.Value
+	indexes map[string]bool
+	// failOn makes statements containing it fail, to test rollbacks
+	failOn string
+}
+
+func (d *fakeSQLDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, ok := d.dbs[dsn]
+	if !ok {
+		db = &fakeSQLDB{tables: make(map[string][]map[string]driver.Value), indexes: make(map[string]bool)}
+		d.dbs[dsn] = db
+	}
+	return &fakeSQLConn{db: db}, nil
+}
+
+type fakeSQLConn struct {
+	db *fakeSQLDB
+	// snapshot is the state at Begin, restored on Rollback
+	snapshot map[string][]map[string]driver.Value
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{conn: c, query: query}, nil
+}
+func (c *fakeSQLConn) Close() error { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.snapshot = make(map[string][]map[string]driver.Value, len(c.db.tables))
+	for name, rows := range c.db.tables {
+		copied := make([]map[string]driver.Value, len(rows))
+		for i, row := range rows {
+			copied[i] = make(map[string]driver.Value, len(row))
+			for k, v := range row {
+				copied[i][k] = v
+			}
+		}
+		c.snapshot[name] = copied
+	}
+	return c, nil
+}
+func (c *fakeSQLConn) Commit() error { c.snapshot = nil; return nil }
+func (c *fakeSQLConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.snapshot != nil {
+		c.db.tables = c.snapshot
+		c.snapshot = nil
+	}
+	return nil
+}
+
+type fakeSQLStmt struct {
+	conn  *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error  { return nil }
+func (s *fakeSQLStmt) NumInput() int { return -1 }
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.conn.db.run(s.query, args)
+	return driver.RowsAffected(n), err
+}
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.conn.db.run(s.query, args)
+	return rows, err
+}
+
+type fakeSQLRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string { return r.columns }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var (
+	fakeSQLPlaceholder = regexp.MustCompile(`\$\d+`)
+	fakeSQLCreateTable = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) `)
+	fakeSQLCreateIndex = regexp.MustCompile(`^CREATE INDEX (\w+) ON (\w+) `)
+	fakeSQLInsert      = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \([^)]*\)( ON .*)?$`)
+	fakeSQLUpdate      = regexp.MustCompile(`^UPDATE (\w+) SET (.*?) WHERE (.*)$`)
+	fakeSQLDelete      = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.*)$`)
+	fakeSQLJoin        = regexp.MustCompile(`^SELECT p\.data FROM (\w+) p JOIN (\w+) a ON a\.payment_id = p\.id WHERE a\.address = \?$`)
+	fakeSQLSelect      = regexp.MustCompile(`^SELECT (.*?) FROM (\w+)(?: WHERE (.*?))?(?: ORDER BY (\w+))?(?: LIMIT (\d+))?$`)
+	fakeSQLCondition   = regexp.MustCompile(`^(\w+) (=|>=|<=|>|<|LIKE|IN) (.*)$`)
+)
+
+// run executes query, returning rows for SELECTs and the affected row count otherwise
+func (db *fakeSQLDB) run(query string, args []driver.Value) (*fakeSQLRows, int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	query = fakeSQLPlaceholder.ReplaceAllString(query, "?")
+	if db.failOn != "" && strings.Contains(query, db.failOn) {
+		return nil, 0, errors.New("injected failure")
+	}
+
+	if m := fakeSQLCreateTable.FindStringSubmatch(query); m != nil {
+		if _, ok := db.tables[m[1]]; !ok {
+			db.tables[m[1]] = nil
+		}
+		return nil, 0, nil
+	}
+	if m := fakeSQLCreateIndex.FindStringSubmatch(query); m != nil {
+		if db.indexes[m[1]] {
+			return nil, 0, fmt.Errorf("index %s already exists", m[1])
+		}
+		db.indexes[m[1]] = true
+		return nil, 0, nil
+	}
+	if m := fakeSQLInsert.FindStringSubmatch(query); m != nil {
+		columns := strings.Split(m[2], ", ")
+		row := make(map[string]driver.Value, len(columns))
+		for i, column := range columns {
+			row[column] = args[i]
+		}
+		key := columns[0]
+		for _, existing := range db.tables[m[1]] {
+			if existing[key] == row[key] {
+				if m[3] == "" {
+					return nil, 0, fmt.Errorf("duplicate key %v in %s", row[key], m[1])
+				}
+				existing["data"] = row["data"]
+				return nil, 1, nil
+			}
+		}
+		db.tables[m[1]] = append(db.tables[m[1]], row)
+		return nil, 1, nil
+	}
+	if m := fakeSQLUpdate.FindStringSubmatch(query); m != nil {
+		assignments := strings.Split(m[2], ", ")
+		match, err := fakeSQLWhere(m[3], args[len(assignments):])
+		if err != nil {
+			return nil, 0, err
+		}
+		var n int64
+		for _, row := range db.tables[m[1]] {
+			if match(row) {
+				for i, assignment := range assignments {
+					row[strings.TrimSuffix(assignment, " = ?")] = args[i]
+				}
+				n++
+			}
+		}
+		return nil, n, nil
+	}
+	if m := fakeSQLDelete.FindStringSubmatch(query); m != nil {
+		match, err := fakeSQLWhere(m[2], args)
+		if err != nil {
+			return nil, 0, err
+		}
+		var kept []map[string]driver.Value
+		for _, row := range db.tables[m[1]] {
+			if !match(row) {
+				kept = append(kept, row)
+			}
+		}
+		n := int64(len(db.tables[m[1]]) - len(kept))
+		db.tables[m[1]] = kept
+		return nil, n, nil
+	}
+	if m := fakeSQLJoin.FindStringSubmatch(query); m != nil {
+		rows := &fakeSQLRows{columns: []string{"data"}}
+		for _, address := range db.tables[m[2]] {
+			if address["address"] != args[0] {
+				continue
+			}
+			for _, payment := range db.tables[m[1]] {
+				if payment["id"] == address["payment_id"] {
+					rows.values = append(rows.values, []driver.Value{payment["data"]})
+				}
+			}
+		}
+		return rows, 0, nil
+	}
+	if m := fakeSQLSelect.FindStringSubmatch(query); m != nil {
+		table, ok := db.tables[m[2]]
+		if !ok {
+			return nil, 0, fmt.Errorf("no such table: %s", m[2])
+		}
+		match, err := fakeSQLWhere(m[3], args)
+		if err != nil {
+			return nil, 0, err
+		}
+		var selected []map[string]driver.Value
+		for _, row := range table {
+			if match(row) {
+				selected = append(selected, row)
+			}
+		}
+		if m[4] != "" {
+			sort.SliceStable(selected, func(i, j int) bool { return fakeSQLLess(selected[i][m[4]], selected[j][m[4]]) })
+		}
+		if m[5] != "" {
+			if limit, _ := strconv.Atoi(m[5]); len(selected) > limit {
+				selected = selected[:limit]
+			}
+		}
+		if aggregate, ok := strings.CutPrefix(m[1], "MAX("); ok {
+			column := strings.TrimSuffix(aggregate, ")")
+			var max driver.Value
+			for _, row := range selected {
+				if max == nil || fakeSQLLess(max, row[column]) {
+					max = row[column]
+				}
+			}
+			return &fakeSQLRows{columns: []string{m[1]}, values: [][]driver.Value{{max}}}, 0, nil
+		}
+		columns := strings.Split(m[1], ", ")
+		rows := &fakeSQLRows{columns: columns}
+		for _, row := range selected {
+			values := make([]driver.Value, len(columns))
+			for i, column := range columns {
+				values[i] = row[column]
+			}
+			rows.values = append(rows.values, values)
+		}
+		return rows, 0, nil
+	}
+	return nil, 0, fmt.Errorf("fake SQL driver does not understand: %s", query)
+}
+
+// fakeSQLWhere compiles a conjunction of simple conditions
+func fakeSQLWhere(where string, args []driver.Value) (func(map[string]driver.Value) bool, error) {
+	if where == "" {
+		return func(map[string]driver.Value) bool { return true }, nil
+	}
+	var conditions []func(map[string]driver.Value) bool
+	for _, clause := range strings.Split(where, " AND ") {
+		m := fakeSQLCondition.FindStringSubmatch(clause)
+		if m == nil {
+			return nil, fmt.Errorf("fake SQL driver does not understand condition: %s", clause)
+		}
+		column, op := m[1], m[2]
+		n := strings.Count(m[3], "?")
+		if n > len(args) {
+			return nil, fmt.Errorf("missing arguments for %s", clause)
+		}
+		values := args[:n]
+		args = args[n:]
+		conditions = append(conditions, func(row map[string]driver.Value) bool {
+			v := row[column]
+			switch op {
+			case "=":
+				return v == values[0]
+			case ">":
+				return fakeSQLLess(values[0], v)
+			case ">=":
+				return !fakeSQLLess(v, values[0])
+			case "<":
+				return fakeSQLLess(v, values[0])
+			case "<=":
+				return !fakeSQLLess(values[0], v)
+			case "LIKE":
+				return strings.HasPrefix(fmt.Sprint(v), strings.TrimSuffix(fmt.Sprint(values[0]), "%"))
+			case "IN":
+				for _, value := range values {
+					if v == value {
+						return true
+					}
+				}
+			}
+			return false
+		})
+	}
+	return func(row map[string]driver.Value) bool {
+		for _, condition := range conditions {
+			if !condition(row) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func fakeSQLLess(a, b driver.Value) bool {
+	if x, ok := a.(int64); ok {
+		y, _ := b.(int64)
+		return x < y
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func newTestSQLStore(t *testing.T, dialect SQLDialect) (*SQLStore, *fakeSQLDB) {
+	t.Helper()
+	db, err := sql.Open("paywallfake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One connection: a query left open while the store is called again would deadlock
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	store, err := NewSQLStore(db, SQLStoreConfig{Dialect: dialect})
+	if err != nil {
+		t.Fatalf("NewSQLStore() error = %v", err)
+	}
+	fakeSQL.mu.Lock()
+	defer fakeSQL.mu.Unlock()
+	return store, fakeSQL.dbs[t.Name()]
+}
+
+func newSQLTestPayment(id, btcAddress string) *Payment {
+	return &Payment{
+		ID:        id,
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: btcAddress},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+}
+
+func TestSQLStore_PaymentLifecycle(t *testing.T) {
+	for _, dialect := range []SQLDialect{DialectSQLite, DialectPostgres, DialectMySQL} {
+		t.Run(string(dialect), func(t *testing.T) {
+			store, _ := newTestSQLStore(t, dialect)
+			payment := newSQLTestPayment("pay1", "tb1qaddress1")
+			if err := store.CreatePayment(payment); err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			if err := store.CreatePayment(payment); err == nil {
+				t.Error("CreatePayment() of an existing payment succeeded")
+			}
+
+			got, err := store.GetPayment("pay1")
+			if err != nil || got == nil || got.Addresses[wallet.Bitcoin] != "tb1qaddress1" {
+				t.Fatalf("GetPayment() = %+v, %v", got, err)
+			}
+			if missing, err := store.GetPayment("nope"); missing != nil || err != nil {
+				t.Errorf("GetPayment(missing) = %v, %v, want nil, nil", missing, err)
+			}
+			byAddress, err := store.GetPaymentByAddress("tb1qaddress1")
+			if err != nil || byAddress == nil || byAddress.ID != "pay1" {
+				t.Errorf("GetPaymentByAddress() = %+v, %v", byAddress, err)
+			}
+
+			// Updates bump the version; stale copies conflict
+			stale, _ := store.GetPayment("pay1")
+			got.Status = StatusConfirmed
+			got.Confirmations = 1
+			if err := store.UpdatePayment(got); err != nil {
+				t.Fatalf("UpdatePayment() error = %v", err)
+			}
+			if got.Version != 1 {
+				t.Errorf("version after update = %d, want 1", got.Version)
+			}
+			if err := store.UpdatePayment(stale); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("UpdatePayment(stale) error = %v, want ErrVersionConflict", err)
+			}
+			reread, _ := store.GetPayment("pay1")
+			if reread.Status != StatusConfirmed || reread.Version != 1 {
+				t.Errorf("stored payment = %s v%d, want confirmed v1", reread.Status, reread.Version)
+			}
+			if err := store.UpdatePayment(newSQLTestPayment("nope", "x")); err == nil {
+				t.Error("UpdatePayment() of a missing payment succeeded")
+			}
+		})
+	}
+}
+
+func TestSQLStore_UpdateIsAtomic(t *testing.T) {
+	store, db := newTestSQLStore(t, DialectSQLite)
+	payment := newSQLTestPayment("pay1", "tb1qold")
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failure while re-indexing addresses rolls the status change back
+	payment.Status = StatusConfirmed
+	payment.Addresses[wallet.Bitcoin] = "tb1qnew"
+	db.failOn = "INSERT INTO paywall_payment_addresses"
+	if err := store.UpdatePayment(payment); err == nil {
+		t.Fatal("UpdatePayment() succeeded despite the failure")
+	}
+	db.failOn = ""
+	if payment.Version != 0 {
+		t.Errorf("version after failed update = %d, want 0", payment.Version)
+	}
+	stored, _ := store.GetPayment("pay1")
+	if stored.Status != StatusPending {
+		t.Errorf("status after failed update = %s, want pending", stored.Status)
+	}
+	if byAddress, _ := store.GetPaymentByAddress("tb1qold"); byAddress == nil {
+		t.Error("old address lost after failed update")
+	}
+
+	if err := store.UpdatePayment(payment); err != nil {
+		t.Fatalf("UpdatePayment() error = %v", err)
+	}
+	if old, _ := store.GetPaymentByAddress("tb1qold"); old != nil {
+		t.Error("old address still indexed after update")
+	}
+	if byAddress, _ := store.GetPaymentByAddress("tb1qnew"); byAddress == nil || byAddress.Status != StatusConfirmed {
+		t.Errorf("GetPaymentByAddress(new) = %+v", byAddress)
+	}
+}
+
+func TestSQLStore_StreamPayments(t *testing.T) {
+	store, _ := newTestSQLStore(t, DialectPostgres)
+	base := time.Now().Add(-time.Hour)
+	total := sqlStreamPageSize + 20
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("p%04d", i)
+		if i%2 == 1 {
+			id = "shop_" + id
+		}
+		payment := newSQLTestPayment(id, "addr"+id)
+		payment.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if i%3 == 0 {
+			payment.Status = StatusConfirmed
+			payment.Confirmations = 1
+		}
+		if err := store.CreatePayment(payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(filter PaymentFilter) int {
+		n := 0
+		// The callback calls the store, which must not deadlock on the one connection
+		err := store.StreamPayments(filter, func(p *Payment) error {
+			if _, err := store.GetPayment(p.ID); err != nil {
+				return err
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamPayments(%+v) error = %v", filter, err)
+		}
+		return n
+	}
+	if got := count(PaymentFilter{}); got != total {
+		t.Errorf("all payments = %d, want %d", got, total)
+	}
+	if got := count(PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}}); got != (total+2)/3 {
+		t.Errorf("confirmed payments = %d, want %d", got, (total+2)/3)
+	}
+	if got := count(PaymentFilter{Partition: "shop"}); got != total/2 {
+		t.Errorf("partition payments = %d, want %d", got, total/2)
+	}
+	if got := count(PaymentFilter{CreatedFrom: base.Add(10 * time.Second), CreatedTo: base.Add(20 * time.Second)}); got != 10 {
+		t.Errorf("payments in time range = %d, want 10", got)
+	}
+
+	n := 0
+	store.StreamPayments(PaymentFilter{}, func(*Payment) error {
+		n++
+		if n == 3 {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if n != 3 {
+		t.Errorf("stream visited %d payments after ErrStopStream, want 3", n)
+	}
+
+	pending, err := store.ListPendingPayments()
+	if err != nil || len(pending) != total-(total+2)/3 {
+		t.Errorf("ListPendingPayments() = %d payments, %v", len(pending), err)
+	}
+}
+
+func TestSQLStore_APIKeysAndStats(t *testing.T) {
+	store, _ := newTestSQLStore(t, DialectMySQL)
+	key := &APIKey{ID: "k1", Label: "first"}
+	if err := store.SaveAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	key.Label = "renamed"
+	if err := store.SaveAPIKey(key); err != nil {
+		t.Fatalf("SaveAPIKey() replace error = %v", err)
+	}
+	got, err := store.GetAPIKey("k1")
+	if err != nil || got == nil || got.Label != "renamed" {
+		t.Errorf("GetAPIKey() = %+v, %v", got, err)
+	}
+	if missing, err := store.GetAPIKey("nope"); missing != nil || err != nil {
+		t.Errorf("GetAPIKey(missing) = %v, %v", missing, err)
+	}
+	if keys, err := store.ListAPIKeys(); err != nil || len(keys) != 1 {
+		t.Errorf("ListAPIKeys() = %d keys, %v", len(keys), err)
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := store.SaveStatsSnapshot(&StatsSnapshot{Time: now.Add(time.Duration(i) * time.Minute), Pending: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := store.ListStatsSnapshots(now, now.Add(2*time.Minute))
+	if err != nil || len(snapshots) != 2 || snapshots[0].Pending != 0 {
+		t.Errorf("ListStatsSnapshots() = %d snapshots, %v", len(snapshots), err)
+	}
+	if err := store.DeleteStatsSnapshotsBefore(now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := store.ListStatsSnapshots(now, now.Add(time.Hour)); len(snapshots) != 2 {
+		t.Errorf("snapshots after delete = %d, want 2", len(snapshots))
+	}
+}
+
+func TestSQLStore_Migrate(t *testing.T) {
+	store, _ := newTestSQLStore(t, DialectSQLite)
+	version, err := store.SchemaVersion()
+	if err != nil || version != len(store.migrations()) {
+		t.Fatalf("SchemaVersion() = %d, %v, want %d", version, err, len(store.migrations()))
+	}
+	// Migrating again (another instance starting) is a no-op
+	if err := store.Migrate(); err != nil {
+		t.Errorf("second Migrate() error = %v", err)
+	}
+
+	db, _ := sql.Open("paywallfake", t.Name()+"-config")
+	defer db.Close()
+	tests := []struct {
+		name   string
+		config SQLStoreConfig
+	}{
+		{"unknown dialect", SQLStoreConfig{Dialect: "oracle"}},
+		{"unsafe prefix", SQLStoreConfig{Dialect: DialectSQLite, TablePrefix: "x; DROP TABLE y"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewSQLStore(db, tt.config); err == nil {
+			t.Errorf("%s: NewSQLStore() succeeded", tt.name)
+		}
+	}
+}
+
+func TestSQLStore_Paywall(t *testing.T) {
+	store, _ := newTestSQLStore(t, DialectSQLite)
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          store,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	pending, err := pw.ListPendingForDisplay()
+	if err != nil || len(pending) != 1 || pending[0].ID != payment.ID {
+		t.Errorf("ListPendingForDisplay() = %v, %v", pending, err)
+	}
+}
@@ -0,0 +1,365 @@
+package paywall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+const (
+	// defaultStatsRetention is the default Config.StatsRetention
+	defaultStatsRetention = 90 * 24 * time.Hour
+	// defaultStatsHistoryRange is the range HandleStatsHistory serves without "from"
+	defaultStatsHistoryRange = 24 * time.Hour
+	// DefaultStatsHistoryPath is the suggested mount point of HandleStatsHistory
+	DefaultStatsHistoryPath = "/paywall/api/stats/history"
+	// statsSnapshotLayout names stored snapshots; it sorts chronologically
+	statsSnapshotLayout = "20060102T150405.000000000Z"
+)
+
+// StatsSnapshot is a point-in-time summary of the paywall's key counters,
+// recorded every Config.StatsInterval for dashboards
+// Related: Paywall.CurrentStats, Paywall.StatsHistory, HandleStatsHistory
+type StatsSnapshot struct {
+	// Time is when the snapshot was taken (UTC)
+	Time time.Time `json:"time"`
+	// Pending counts unexpired payments awaiting funds or confirmations
+	Pending int `json:"pending"`
+	// Detected counts the Pending payments whose transaction awaits confirmations
+	Detected int `json:"detected"`
+	// ConfirmedToday counts payments confirmed since 00:00 UTC
+	ConfirmedToday int `json:"confirmed_today"`
+	// RevenueToday is the amount of ConfirmedToday per currency
+	RevenueToday map[wallet.WalletType]float64 `json:"revenue_today"`
+	// FiatRevenueToday is RevenueToday in FiatCurrency at the current rate,
+	// zero without a PriceOracle
+	FiatRevenueToday float64 `json:"fiat_revenue_today,omitempty"`
+	// FiatCurrency is the currency of FiatRevenueToday
+	FiatCurrency string `json:"fiat_currency,omitempty"`
+	// MonitorChecks and MonitorErrors count the monitor's payment checks, and
+	// those that failed, since the previous snapshot
+	MonitorChecks int64 `json:"monitor_checks"`
+	MonitorErrors int64 `json:"monitor_errors"`
+	// ErrorRate is MonitorErrors / MonitorChecks, 0 without checks
+	ErrorRate float64 `json:"error_rate"`
+	// StoreRetries counts the monitor's retried store writes, and
+	// StoreFailures the writes that failed after retries, since the previous
+	// snapshot (see Config.MonitorStoreRetries)
+	StoreRetries  int64 `json:"store_retries"`
+	StoreFailures int64 `json:"store_failures"`
+}
+
+// statsCounters counts monitor checks and store writes between snapshots
+type statsCounters struct {
+	checks        atomic.Int64
+	failures      atomic.Int64
+	storeRetries  atomic.Int64
+	storeFailures atomic.Int64
+	// recorded* are the totals at the last recorded snapshot
+	mu                    sync.Mutex
+	recordedChecks        int64
+	recordedFailures      int64
+	recordedStoreRetries  int64
+	recordedStoreFailures int64
+}
+
+// recordCheck counts a monitor check of a payment
+func (c *statsCounters) recordCheck(failed bool) {
+	c.checks.Add(1)
+	if failed {
+		c.failures.Add(1)
+	}
+}
+
+// CurrentStats takes a StatsSnapshot now, without storing it.
+//
+// Returns:
+//   - *StatsSnapshot: The current counters
+//   - error: If the store cannot stream payments
+//
+// Payments are streamed from the store, which must implement PaymentStreamer
+// or PaymentLister.
+func (p *Paywall) CurrentStats() (*StatsSnapshot, error) {
+	now := time.Now().UTC()
+	dayStart := now.Truncate(24 * time.Hour)
+	snapshot := &StatsSnapshot{
+		Time:         now,
+		RevenueToday: make(map[wallet.WalletType]float64),
+	}
+
+	awaiting := PaymentFilter{Statuses: []PaymentStatus{StatusPending, StatusDetected}}
+	err := StreamPayments(p.Store, awaiting, func(payment *Payment) error {
+		if now.Before(payment.ExpiresAt) {
+			snapshot.Pending++
+			if payment.Status == StatusDetected {
+				snapshot.Detected++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream pending payments: %w", err)
+	}
+
+	// Payments confirmed today were created at most PaymentTimeout before
+	// midnight, except late payments to expired ones
+	confirmed := PaymentFilter{Statuses: []PaymentStatus{StatusConfirmed}, CreatedFrom: dayStart.Add(-p.paymentTimeout)}
+	err = StreamPayments(p.Store, confirmed, func(payment *Payment) error {
+		confirmedAt := payment.ConfirmedAt
+		if confirmedAt.IsZero() {
+			confirmedAt = payment.CreatedAt
+		}
+		if confirmedAt.Before(dayStart) {
+			return nil
+		}
+		snapshot.ConfirmedToday++
+		if currency, ok := settledCurrency(payment); ok {
+			snapshot.RevenueToday[currency] += payment.Amounts[currency]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream confirmed payments: %w", err)
+	}
+
+	if p.priceOracle != nil {
+		snapshot.FiatCurrency = p.fiatCurrency
+		for currency, amount := range snapshot.RevenueToday {
+			rate, err := p.priceOracle.FiatPrice(rateCurrency(currency), p.fiatCurrency, now)
+			if err != nil {
+				p.logger.log(LogEntry{
+					Level:   LogLevelWarn,
+					Event:   "price_oracle_failed",
+					Message: fmt.Sprintf("Price oracle failed for %s/%s: %v", currency, p.fiatCurrency, err),
+				})
+				continue
+			}
+			snapshot.FiatRevenueToday += amount * rate
+		}
+	}
+
+	p.stats.mu.Lock()
+	snapshot.MonitorChecks = p.stats.checks.Load() - p.stats.recordedChecks
+	snapshot.MonitorErrors = p.stats.failures.Load() - p.stats.recordedFailures
+	snapshot.StoreRetries = p.stats.storeRetries.Load() - p.stats.recordedStoreRetries
+	snapshot.StoreFailures = p.stats.storeFailures.Load() - p.stats.recordedStoreFailures
+	p.stats.mu.Unlock()
+	if snapshot.MonitorChecks > 0 {
+		snapshot.ErrorRate = float64(snapshot.MonitorErrors) / float64(snapshot.MonitorChecks)
+	}
+	return snapshot, nil
+}
+
+// recordStats takes a snapshot, stores it and deletes snapshots older than
+// Config.StatsRetention
+func (p *Paywall) recordStats() error {
+	store, ok := p.Store.(StatsStore)
+	if !ok {
+		return fmt.Errorf("payment store %T does not support stats snapshots", p.Store)
+	}
+	snapshot, err := p.CurrentStats()
+	if err != nil {
+		return err
+	}
+	if err := store.SaveStatsSnapshot(snapshot); err != nil {
+		return fmt.Errorf("save stats snapshot: %w", err)
+	}
+
+	p.stats.mu.Lock()
+	p.stats.recordedChecks += snapshot.MonitorChecks
+	p.stats.recordedFailures += snapshot.MonitorErrors
+	p.stats.recordedStoreRetries += snapshot.StoreRetries
+	p.stats.recordedStoreFailures += snapshot.StoreFailures
+	p.stats.mu.Unlock()
+
+	if err := store.DeleteStatsSnapshotsBefore(snapshot.Time.Add(-p.statsRetention)); err != nil {
+		return fmt.Errorf("delete old stats snapshots: %w", err)
+	}
+	return nil
+}
+
+// StatsHistory returns the snapshots recorded in a time range.
+//
+// Parameters:
+//   - from: Inclusive start of the range
+//   - to: Exclusive end of the range
+//
+// Returns:
+//   - []*StatsSnapshot: Snapshots in chronological order
+//   - error: If the store does not implement StatsStore or reading fails
+func (p *Paywall) StatsHistory(from, to time.Time) ([]*StatsSnapshot, error) {
+	store, ok := p.Store.(StatsStore)
+	if !ok {
+		return nil, fmt.Errorf("payment store %T does not support stats snapshots", p.Store)
+	}
+	snapshots, err := store.ListStatsSnapshots(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list stats snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// StatsHistoryResponse is the JSON body served by HandleStatsHistory
+type StatsHistoryResponse struct {
+	// From and To are the served range
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Snapshots are the recorded snapshots in the range, oldest first
+	Snapshots []*StatsSnapshot `json:"snapshots"`
+}
+
+// HandleStatsHistory serves the snapshots recorded every Config.StatsInterval
+// as JSON, for Grafana (JSON or Infinity datasource) or an admin page. It
+// performs no authentication of its own: mount it on an admin listener or
+// behind your admin authentication, e.g. at DefaultStatsHistoryPath.
+//
+// The "from" and "to" query parameters select the range as RFC 3339 times or
+// Unix milliseconds (Grafana's ${__from} and ${__to}); they default to the
+// last 24 hours.
+//
+// Responses:
+//   - 200 with a StatsHistoryResponse
+//   - 400 Bad Request for malformed or empty ranges
+//   - 405 Method Not Allowed for non-GET requests
+//   - 500 Internal Server Error on storage failures
+func (p *Paywall) HandleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	to, err := parseStatsTime(query.Get("to"), time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseStatsTime(query.Get("from"), to.Add(-defaultStatsHistoryRange))
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	snapshots, err := p.StatsHistory(from, to)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "stats_history_failed",
+			Message: fmt.Sprintf("Failed to load stats history: %v", err),
+		})
+		http.Error(w, "Failed to load stats history", http.StatusInternalServerError)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []*StatsSnapshot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(StatsHistoryResponse{From: from, To: to, Snapshots: snapshots}); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode stats history: %v", err),
+		})
+	}
+}
+
+// parseStatsTime parses an RFC 3339 time or Unix milliseconds, def when empty
+func parseStatsTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want RFC 3339 or Unix milliseconds, got %q", value)
+	}
+	return t.UTC(), nil
+}
+
+// copyStatsSnapshot copies a snapshot, including its RevenueToday map
+func copyStatsSnapshot(snapshot *StatsSnapshot) *StatsSnapshot {
+	cp := *snapshot
+	cp.RevenueToday = make(map[wallet.WalletType]float64, len(snapshot.RevenueToday))
+	for currency, amount := range snapshot.RevenueToday {
+		cp.RevenueToday[currency] = amount
+	}
+	return &cp
+}
+
+// statsSnapshotName is the file or object name of a snapshot taken at t
+func statsSnapshotName(t time.Time) string {
+	return t.UTC().Format(statsSnapshotLayout) + ".json"
+}
+
+// statsSnapshotTime parses a name returned by statsSnapshotName
+func statsSnapshotTime(name string) (time.Time, bool) {
+	if len(name) != len(statsSnapshotLayout)+len(".json") {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(statsSnapshotLayout, name[:len(statsSnapshotLayout)])
+	return t, err == nil
+}
+
+// statsRecorder records a StatsSnapshot every Config.StatsInterval
+type statsRecorder struct {
+	paywall  *Paywall
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newStatsRecorder creates a recorder for the paywall's stats
+func newStatsRecorder(p *Paywall, interval time.Duration) *statsRecorder {
+	return &statsRecorder{
+		paywall:  p,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start records a snapshot every interval until Stop
+func (r *statsRecorder) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if err := r.paywall.recordStats(); err != nil {
+					r.paywall.logger.log(LogEntry{
+						Level:   LogLevelError,
+						Event:   "stats_snapshot_failed",
+						Message: fmt.Sprintf("Failed to record stats snapshot: %v", err),
+					})
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the recording loop and waits for a running snapshot to finish
+func (r *statsRecorder) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
@@ -0,0 +1,146 @@
+package paywall
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestCurrentStats(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:          store,
+		paymentTimeout: time.Hour,
+		logger:         NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	now := time.Now()
+	payments := []*Payment{
+		{ID: "pending", Status: StatusPending, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "detected", Status: StatusDetected, CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "stale", Status: StatusPending, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+		{ID: "paid", Status: StatusConfirmed, CreatedAt: now, ExpiresAt: now.Add(time.Hour), ConfirmedAt: now, PaidCurrency: wallet.Bitcoin},
+		{ID: "paid-earlier", Status: StatusConfirmed, CreatedAt: now.Add(-72 * time.Hour), ExpiresAt: now.Add(-71 * time.Hour), ConfirmedAt: now.Add(-72 * time.Hour), PaidCurrency: wallet.Bitcoin},
+	}
+	for _, payment := range payments {
+		payment.Addresses = map[wallet.WalletType]string{wallet.Bitcoin: payment.ID + "-address"}
+		payment.Amounts = map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}
+		if err := store.CreatePayment(payment); err != nil {
+			t.Fatalf("CreatePayment(%s) error = %v", payment.ID, err)
+		}
+	}
+	for _, failed := range []bool{false, false, false, true} {
+		pw.stats.recordCheck(failed)
+	}
+
+	snapshot, err := pw.CurrentStats()
+	if err != nil {
+		t.Fatalf("CurrentStats() error = %v", err)
+	}
+	if snapshot.Pending != 2 || snapshot.Detected != 1 {
+		t.Errorf("pending = %d, detected = %d, want 2 and 1", snapshot.Pending, snapshot.Detected)
+	}
+	if snapshot.ConfirmedToday != 1 || snapshot.RevenueToday[wallet.Bitcoin] != 0.001 {
+		t.Errorf("confirmed today = %d with %v, want 1 with 0.001 BTC", snapshot.ConfirmedToday, snapshot.RevenueToday)
+	}
+	if snapshot.MonitorChecks != 4 || snapshot.ErrorRate != 0.25 {
+		t.Errorf("monitor checks = %d at error rate %v, want 4 at 0.25", snapshot.MonitorChecks, snapshot.ErrorRate)
+	}
+
+	// Recording starts the monitor counters over
+	if err := pw.recordStats(); err != nil {
+		t.Fatalf("recordStats() error = %v", err)
+	}
+	pw.stats.recordCheck(true)
+	snapshot, _ = pw.CurrentStats()
+	if snapshot.MonitorChecks != 1 || snapshot.MonitorErrors != 1 {
+		t.Errorf("after recording: checks = %d, errors = %d, want 1 and 1", snapshot.MonitorChecks, snapshot.MonitorErrors)
+	}
+	history, err := pw.StatsHistory(now.Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil || len(history) != 1 || history[0].MonitorChecks != 4 {
+		t.Errorf("StatsHistory() = %v, %v, want the recorded snapshot", history, err)
+	}
+}
+
+func TestStatsStores(t *testing.T) {
+	s3Store, _ := newTestS3Store(t)
+	stores := map[string]StatsStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+		"s3":     s3Store,
+	}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			// Saved out of order
+			for _, minutes := range []int{10, 0, 5} {
+				snapshot := &StatsSnapshot{Time: base.Add(time.Duration(minutes) * time.Minute), Pending: minutes}
+				if err := store.SaveStatsSnapshot(snapshot); err != nil {
+					t.Fatalf("SaveStatsSnapshot() error = %v", err)
+				}
+			}
+
+			snapshots, err := store.ListStatsSnapshots(base, base.Add(10*time.Minute))
+			if err != nil {
+				t.Fatalf("ListStatsSnapshots() error = %v", err)
+			}
+			if len(snapshots) != 2 || snapshots[0].Pending != 0 || snapshots[1].Pending != 5 {
+				t.Errorf("ListStatsSnapshots() = %+v, want the 0 and 5 minute snapshots", snapshots)
+			}
+
+			if err := store.DeleteStatsSnapshotsBefore(base.Add(5 * time.Minute)); err != nil {
+				t.Fatalf("DeleteStatsSnapshotsBefore() error = %v", err)
+			}
+			snapshots, _ = store.ListStatsSnapshots(base, base.Add(time.Hour))
+			if len(snapshots) != 2 || snapshots[0].Pending != 5 || !snapshots[0].Time.Equal(base.Add(5*time.Minute)) {
+				t.Errorf("after delete: %+v, want the 5 and 10 minute snapshots", snapshots)
+			}
+		})
+	}
+}
+
+func TestHandleStatsHistory(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{Store: store, logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		store.SaveStatsSnapshot(&StatsSnapshot{Time: base.Add(time.Duration(i) * time.Hour), Pending: i})
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{name: "rfc3339", query: "?from=2026-03-01T12:00:00Z&to=2026-03-01T14:00:00Z", wantCode: http.StatusOK, wantCount: 2},
+		{name: "unix milliseconds", query: "?from=" + strconv.FormatInt(base.Add(time.Hour).UnixMilli(), 10) + "&to=" + strconv.FormatInt(base.Add(3*time.Hour).UnixMilli(), 10), wantCode: http.StatusOK, wantCount: 2},
+		{name: "last 24 hours", wantCode: http.StatusOK, wantCount: 0},
+		{name: "malformed", query: "?from=yesterday", wantCode: http.StatusBadRequest},
+		{name: "empty range", query: "?from=2026-03-01T14:00:00Z&to=2026-03-01T12:00:00Z", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			pw.HandleStatsHistory(rec, httptest.NewRequest(http.MethodGet, DefaultStatsHistoryPath+tt.query, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp StatsHistoryResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Snapshots) != tt.wantCount {
+				t.Errorf("got %d snapshots, want %d", len(resp.Snapshots), tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package paywall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultStatusPollInterval is how often the payment page polls Config.StatusPath
+const defaultStatusPollInterval = 10 * time.Second
+
+// PaymentStatusResponse is the JSON body served by HandlePaymentStatus
+type PaymentStatusResponse struct {
+	// Status is the payment status; payments past ExpiresAt report StatusExpired
+	Status PaymentStatus `json:"status"`
+	// Confirmations is the number of blockchain confirmations recorded
+	Confirmations int `json:"confirmations"`
+	// ExpiresAt is when the payment (or, once confirmed, the access) expires
+	ExpiresAt time.Time `json:"expires_at"`
+	// FiatEstimate is the current fiat value of the amounts to pay while the
+	// payment is unpaid, when Config.FiatEstimates is set
+	FiatEstimate *FiatEstimate `json:"fiat_estimate,omitempty"`
+	// PageCurrent is set when the request carries a page nonce: false means the
+	// payment page that sent it is out of date and should reload
+	PageCurrent *bool `json:"page_current,omitempty"`
+	// AccessToken is an access grant to send as "Authorization: Bearer
+	// <token>", set for confirmed payments with Config.BearerTokensEnabled
+	AccessToken string `json:"access_token,omitempty"`
+	// AccessTokenExpiresAt is when AccessToken expires; ask for the status
+	// again before then for a fresh token while access lasts
+	AccessTokenExpiresAt *time.Time `json:"access_token_expires_at,omitempty"`
+}
+
+// HandlePaymentStatus reports the status of a payment. The payment page listens
+// to it (when Config.StatusPath is set) and returns the visitor to their
+// content as soon as the payment confirms.
+//
+// Mount it at Config.StatusPath, e.g. http.HandleFunc("/paywall/status", pw.HandlePaymentStatus).
+// The payment is the one in the payment cookie, or the one named in the path
+// for clients without cookies:
+//
+//	mux.HandleFunc("GET /paywall/status/{paymentID}", pw.HandlePaymentStatus)
+//
+// Paths below Config.StatusPath ("/paywall/status/<id>") are understood
+// without a pattern too, e.g. when mounted with http.Handle("/paywall/status/", ...).
+//
+// Requests accepting text/event-stream get Server-Sent Events instead of a
+// single response: a "status" event with a PaymentStatusResponse right away
+// and on every change, until the payment is confirmed or expired. Changes made
+// by this process's monitor are sent the moment they are stored; others are
+// picked up every Config.StatusPollInterval.
+//
+// The payment page passes its signed nonce as the "page" query parameter; the
+// response's PageCurrent then says whether that page still shows the visitor's
+// current payment.
+//
+// With Config.BearerTokensEnabled, confirmed payments come with an
+// AccessToken for clients without cookies. Anyone knowing the payment ID can
+// fetch it, just as they could use the payment cookie.
+//
+// Responses:
+//   - 200 with a PaymentStatusResponse, or an event stream
+//   - 404 Not Found if the request names no payment or the payment is unknown
+//   - 405 Method Not Allowed for non-GET requests
+//   - 429 Too Many Requests (with Retry-After) beyond Config.RateLimits
+//   - 500 Internal Server Error on storage failures
+func (p *Paywall) HandlePaymentStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allowRequest(w, r, RateLimitStatus) {
+		return
+	}
+
+	paymentID, err := p.statusPaymentID(r)
+	if err != nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	payment, err := p.Store.GetPayment(paymentID)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "payment_status_failed",
+			Message:   fmt.Sprintf("Failed to load payment status: %v", err),
+			PaymentID: paymentID,
+		})
+		http.Error(w, "Failed to load payment", http.StatusInternalServerError)
+		return
+	}
+	if payment == nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+
+	if wantsEventStream(r) {
+		p.streamPaymentStatus(w, r, payment)
+		return
+	}
+	p.writePaymentStatus(w, p.paymentStatus(r, payment, time.Now()))
+}
+
+// statusPaymentID returns the payment a status request asks about: the one in
+// the path, or else the one in the payment cookie
+func (p *Paywall) statusPaymentID(r *http.Request) (string, error) {
+	if id := r.PathValue("paymentID"); id != "" {
+		return id, nil
+	}
+	if p.statusPath != "" {
+		if id, ok := strings.CutPrefix(r.URL.Path, strings.TrimSuffix(p.statusPath, "/")+"/"); ok && id != "" {
+			return id, nil
+		}
+	}
+	return paymentIDFromCookie(r)
+}
+
+// paymentStatus describes payment as of now for a status request
+func (p *Paywall) paymentStatus(r *http.Request, payment *Payment, now time.Time) PaymentStatusResponse {
+	resp := PaymentStatusResponse{
+		Status:        payment.Status,
+		Confirmations: payment.Confirmations,
+		ExpiresAt:     payment.ExpiresAt,
+	}
+	if payment.Status == StatusConfirmed {
+		resp.ExpiresAt = payment.AccessEnds()
+	}
+	if !now.Before(resp.ExpiresAt) {
+		resp.Status = StatusExpired
+	}
+	if resp.Status == StatusPending || resp.Status == StatusDetected {
+		resp.FiatEstimate = p.fiatEstimate(payment)
+	}
+	if p.bearerTokens && payment.GrantsAccess(now) {
+		token, grant := p.mintAccessGrant(payment, now)
+		resp.AccessToken, resp.AccessTokenExpiresAt = token, &grant.ExpiresAt
+	}
+	if nonce := r.URL.Query().Get("page"); nonce != "" {
+		current := p.pageIsCurrent(nonce, payment, now)
+		resp.PageCurrent = &current
+	}
+	return resp
+}
+
+// writePaymentStatus sends resp as a JSON response
+func (p *Paywall) writePaymentStatus(w http.ResponseWriter, resp PaymentStatusResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelError,
+			Event:   "response_encoding_failed",
+			Message: fmt.Sprintf("Failed to encode payment status response: %v", err),
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package paywall
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestHandlePaymentStatus(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	pending, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	confirmed := confirmedTestPayment(t, pw)
+	expired, _ := pw.CreatePayment()
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	pw.Store.UpdatePayment(expired)
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		wantCode   int
+		wantStatus PaymentStatus
+	}{
+		{"pending", http.MethodGet, pending.ID, http.StatusOK, StatusPending},
+		{"confirmed", http.MethodGet, confirmed.ID, http.StatusOK, StatusConfirmed},
+		{"past expiry reports expired", http.MethodGet, expired.ID, http.StatusOK, StatusExpired},
+		{"unknown payment", http.MethodGet, "missing", http.StatusNotFound, ""},
+		{"no cookie", http.MethodGet, "", http.StatusNotFound, ""},
+		{"wrong method", http.MethodPost, pending.ID, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/paywall/status", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			pw.HandlePaymentStatus(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+			var resp PaymentStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", resp.Status, tt.wantStatus)
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Error("status responses must not be cached")
+			}
+		})
+	}
+}
+
+func TestHandlePaymentStatus_PaymentIDInPath(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	pw.statusPath = "/paywall/status"
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/payments/{paymentID}/status", pw.HandlePaymentStatus)
+	mux.HandleFunc("/paywall/status/", pw.HandlePaymentStatus)
+
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+	}{
+		{"path pattern", "/api/payments/" + payment.ID + "/status", http.StatusOK},
+		{"below status path", "/paywall/status/" + payment.ID, http.StatusOK},
+		{"unknown payment", "/paywall/status/missing", http.StatusNotFound},
+		{"traversal", "/paywall/status/..%2f..%2fetc%2fpasswd", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp PaymentStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Status != StatusPending {
+				t.Errorf("response = %+v, %v, want pending", resp, err)
+			}
+		})
+	}
+}
+
+func TestHandlePaymentStatus_EventStream(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	// Only the confirmation itself can wake the stream within the test
+	pw.statusPollInterval = time.Hour
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(pw.HandlePaymentStatus))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/paywall/status", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+	events := bufio.NewReader(resp.Body)
+	nextStatus := func() PaymentStatus {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read event: %v", err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var status PaymentStatusResponse
+				if err := json.Unmarshal([]byte(data), &status); err != nil {
+					t.Fatalf("decode event %q: %v", data, err)
+				}
+				return status.Status
+			}
+		}
+	}
+
+	if got := nextStatus(); got != StatusPending {
+		t.Fatalf("first event status = %s, want pending", got)
+	}
+	pw.markConfirmed(payment, time.Now())
+	if err := pw.Store.UpdatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+	monitor := &CryptoChainMonitor{paywall: pw}
+	monitor.announceConfirmed(payment, 0.001, wallet.Bitcoin)
+	if got := nextStatus(); got != StatusConfirmed {
+		t.Fatalf("status after confirmation = %s, want confirmed", got)
+	}
+	// The stream ends once there is nothing left to wait for
+	if rest, err := io.ReadAll(events); err != nil || strings.Contains(string(rest), "data:") {
+		t.Errorf("stream after confirmation = %q, %v, want it closed", rest, err)
+	}
+}
+
+func TestPaymentPage_StatusPoller(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusPath string
+		wantPoller bool
+	}{
+		{"enabled", "/paywall/status", true},
+		{"disabled", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, err := NewPaywall(Config{
+				PriceInBTC:         0.001,
+				PaymentTimeout:     time.Hour,
+				TestNet:            true,
+				Store:              NewMemoryStore(),
+				StatusPath:         tt.statusPath,
+				StatusPollInterval: 5 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			defer pw.Close()
+
+			rec := httptest.NewRecorder()
+			pw.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upload", nil))
+			body := rec.Body.String()
+			if got := strings.Contains(body, `id="status-poller"`); got != tt.wantPoller {
+				t.Fatalf("poller present = %v, want %v", got, tt.wantPoller)
+			}
+			if tt.wantPoller {
+				for _, want := range []string{`\/paywall\/status`, "5000", `'POST'`, "EventSource"} {
+					if !strings.Contains(body, want) {
+						t.Errorf("payment page missing %q", want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePaymentStatus_PageNonce(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	other, _ := pw.CreatePayment()
+	now := time.Now()
+	current := pw.pageNonce(payment, now)
+
+	extended := *payment
+	extended.ExpiresAt = payment.ExpiresAt.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		nonce string
+		want  *bool
+	}{
+		{"no nonce", "", nil},
+		{"current page", current, boolPtr(true)},
+		{"page for another payment", pw.pageNonce(other, now), boolPtr(false)},
+		{"page before expiry changed", pw.pageNonce(&extended, now), boolPtr(false)},
+		{"tampered", current + "x", boolPtr(false)},
+		{"malformed", "not-a-nonce", boolPtr(false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/paywall/status"
+			if tt.nonce != "" {
+				target += "?page=" + url.QueryEscape(tt.nonce)
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+			rec := httptest.NewRecorder()
+			pw.HandlePaymentStatus(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status code = %d, want 200", rec.Code)
+			}
+			var resp PaymentStatusResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			switch {
+			case tt.want == nil && resp.PageCurrent != nil:
+				t.Errorf("page_current = %v, want omitted", *resp.PageCurrent)
+			case tt.want != nil && (resp.PageCurrent == nil || *resp.PageCurrent != *tt.want):
+				t.Errorf("page_current = %v, want %v", resp.PageCurrent, *tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentPage_NotCacheable(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+		StatusPath:     "/paywall/status",
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	rec := httptest.NewRecorder()
+	pw.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/article", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`id="page-freshness"`, "page="} {
+		if !strings.Contains(body, want) {
+			t.Errorf("payment page missing %q", want)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,152 @@
+package paywall
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// DefaultPaymentIDHeader is the upstream request header used by WithPaymentIDHeader
+const DefaultPaymentIDHeader = "X-Paywall-Payment-Id"
+
+// PaymentResponder writes the response for a request the paywall does not let
+// through, in place of the HTML payment page.
+//
+// Parameters:
+//   - w, r: The protected request
+//   - payment: The visitor's payment; its Status may be StatusPending or
+//     StatusDetected, or any status for StatusExpired responders (including
+//     confirmed payments whose access window ended)
+//
+// Related: WithStatusResponse, StatusCodeResponder
+type PaymentResponder func(w http.ResponseWriter, r *http.Request, payment *Payment)
+
+// WithStatusResponse replaces the payment page for one payment outcome on a route,
+// so API integrators can answer with status codes their clients understand, e.g.
+//
+//	pw.MiddlewareWithOptions(api,
+//	    paywall.WithStatusResponse(paywall.StatusPending, paywall.StatusCodeResponder(http.StatusPaymentRequired, 30*time.Second)),
+//	    paywall.WithStatusResponse(paywall.StatusExpired, paywall.StatusCodeResponder(http.StatusGone, 0)),
+//	)
+//
+// Outcomes:
+//   - StatusPending: A new or existing unpaid payment (the cookie is still set)
+//   - StatusDetected: A payment whose transaction awaits confirmations
+//   - StatusExpired: The visitor's payment expired, or its access window ended.
+//     Without a StatusExpired responder the middleware silently starts a new payment.
+//
+// Confirmed payments always pass through; see WithPaymentIDHeader.
+func WithStatusResponse(status PaymentStatus, responder PaymentResponder) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if cfg.responders == nil {
+			cfg.responders = make(map[PaymentStatus]PaymentResponder)
+		}
+		cfg.responders[status] = responder
+	}
+}
+
+// WithPaymentIDHeader adds the paying payment's ID to requests passed to the
+// protected handler, so an upstream application behind a reverse proxy can
+// attribute access. Any value sent by the client under the same header is replaced.
+//
+// Parameters:
+//   - name: Header name, "" for DefaultPaymentIDHeader
+func WithPaymentIDHeader(name string) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if name == "" {
+			name = DefaultPaymentIDHeader
+		}
+		cfg.paymentIDHeader = http.CanonicalHeaderKey(name)
+	}
+}
+
+// paymentStatusResponse is the JSON body written by StatusCodeResponder
+type paymentStatusResponse struct {
+	PaymentID string                        `json:"payment_id"`
+	Status    PaymentStatus                 `json:"status"`
+	Addresses map[wallet.WalletType]string  `json:"addresses,omitempty"`
+	Amounts   map[wallet.WalletType]float64 `json:"amounts,omitempty"`
+	ExpiresAt time.Time                     `json:"expires_at"`
+}
+
+// StatusCodeResponder returns a PaymentResponder that answers with code and a JSON
+// description of the payment (ID, status, and for unpaid payments the addresses
+// and amounts to pay).
+//
+// Parameters:
+//   - code: HTTP status code, e.g. http.StatusPaymentRequired or http.StatusGone
+//   - retryAfter: Sets a Retry-After header when positive
+func StatusCodeResponder(code int, retryAfter time.Duration) PaymentResponder {
+	return func(w http.ResponseWriter, r *http.Request, payment *Payment) {
+		body := paymentStatusResponse{
+			PaymentID: payment.ID,
+			Status:    payment.Status,
+			ExpiresAt: payment.ExpiresAt,
+		}
+		if payment.Status == StatusConfirmed {
+			body.ExpiresAt = payment.AccessEnds()
+		}
+		if payment.Status == StatusPending && time.Now().Before(payment.ExpiresAt) {
+			body.Addresses = payment.Addresses
+			body.Amounts = payment.Amounts
+		} else if !time.Now().Before(body.ExpiresAt) {
+			body.Status = StatusExpired
+		}
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// respond shows the visitor's unpaid payment, using the route's responder for
+// the payment's status when one is configured, JSON for API clients (see
+// PaymentRequiredResponse) and the payment page otherwise
+func (p *Paywall) respond(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, payment *Payment) {
+	if responder, ok := cfg.responders[payment.Status]; ok {
+		responder(w, r, payment)
+		return
+	}
+	if p.wantsJSON(r) {
+		p.respondPaymentRequired(w, r, payment)
+		return
+	}
+	p.renderPaymentPageFor(w, r, payment)
+}
+
+// respondExpired answers for a visitor whose payment expired when the route has a
+// StatusExpired responder
+//
+// Returns:
+//   - bool: true if a response was written
+func (cfg *middlewareConfig) respondExpired(w http.ResponseWriter, r *http.Request, payment *Payment) bool {
+	responder, ok := cfg.responders[StatusExpired]
+	if !ok {
+		return false
+	}
+	responder(w, r, payment)
+	return true
+}
+
+// forward passes a paid request to next, attaching the payment to its context
+// (see FromContext), the payment ID and handoff headers, and revalidating the
+// grant during the response when configured
+func (p *Paywall) forward(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, info *PaymentInfo, check grantCheck, next http.Handler) {
+	r = withPaymentInfo(r, info)
+	r = cfg.withHandoffHeaders(r, HandoffStatusPaid, info.PaymentID)
+	if cfg.paymentIDHeader != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(cfg.paymentIDHeader, info.PaymentID)
+	}
+	if cfg.revalidateInterval > 0 {
+		p.serveRevalidated(w, r, cfg.revalidateInterval, info.PaymentID, check, next)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
@@ -0,0 +1,104 @@
+package paywall
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_StatusResponses(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	var upstreamID string
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get(DefaultPaymentIDHeader)
+		w.Write([]byte("paid content"))
+	}),
+		WithStatusResponse(StatusPending, StatusCodeResponder(http.StatusPaymentRequired, 30*time.Second)),
+		WithStatusResponse(StatusExpired, StatusCodeResponder(http.StatusGone, 0)),
+		WithPaymentIDHeader(""),
+	)
+
+	confirmed := confirmedTestPayment(t, pw)
+	expired := confirmedTestPayment(t, pw)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	pw.Store.UpdatePayment(expired)
+
+	tests := []struct {
+		name           string
+		cookie         string
+		spoofHeader    string
+		wantStatus     int
+		wantRetryAfter string
+		wantBody       PaymentStatus
+		wantUpstream   string
+	}{
+		{name: "new visitor", wantStatus: http.StatusPaymentRequired, wantRetryAfter: "30", wantBody: StatusPending},
+		{name: "confirmed passes through with header", cookie: confirmed.ID, spoofHeader: "forged", wantStatus: http.StatusOK, wantUpstream: confirmed.ID},
+		{name: "expired access", cookie: expired.ID, wantStatus: http.StatusGone, wantBody: StatusExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamID = ""
+			req := httptest.NewRequest(http.MethodGet, "/api/data", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: tt.cookie})
+			}
+			if tt.spoofHeader != "" {
+				req.Header.Set(DefaultPaymentIDHeader, tt.spoofHeader)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if upstreamID != tt.wantUpstream {
+				t.Errorf("upstream %s = %q, want %q", DefaultPaymentIDHeader, upstreamID, tt.wantUpstream)
+			}
+			if tt.wantBody != "" {
+				var body paymentStatusResponse
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				if body.Status != tt.wantBody {
+					t.Errorf("body status = %s, want %s", body.Status, tt.wantBody)
+				}
+				if tt.wantBody == StatusPending && len(body.Addresses) == 0 {
+					t.Error("pending response should include payment addresses")
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware_ExpiredWithoutResponderStartsNewPayment(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	handler := pw.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expired payment must not grant access")
+	}))
+	expired := confirmedTestPayment(t, pw)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	pw.Store.UpdatePayment(expired)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "payment_id", Value: expired.ID})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want payment page", rec.Code)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "payment_id" && c.Value == expired.ID {
+			continue
+		}
+		if c.Name == "payment_id" {
+			return
+		}
+	}
+	t.Error("expected a new payment cookie")
+}
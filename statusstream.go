@@ -0,0 +1,166 @@
+package paywall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusStreamHeartbeat is how often an idle status stream sends a comment,
+// keeping proxies from closing it and noticing visitors who left
+const statusStreamHeartbeat = 15 * time.Second
+
+// statusWatchers wakes the status streams of payments whose status changed in
+// this process. Streams also re-read the store every poll interval, which
+// catches changes made by other instances (Config.ExternalMonitor).
+type statusWatchers struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan struct{}]struct{}
+}
+
+// watch returns a channel signalled when paymentID changes and a function
+// that stops watching
+func (s *statusWatchers) watch(paymentID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchers == nil {
+		s.watchers = make(map[string]map[chan struct{}]struct{})
+	}
+	if s.watchers[paymentID] == nil {
+		s.watchers[paymentID] = make(map[chan struct{}]struct{})
+	}
+	s.watchers[paymentID][ch] = struct{}{}
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers[paymentID], ch)
+		if len(s.watchers[paymentID]) == 0 {
+			delete(s.watchers, paymentID)
+		}
+	}
+}
+
+// notify wakes the streams watching paymentID
+func (s *statusWatchers) notify(paymentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.watchers[paymentID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// notifyStatusWatchers wakes the status streams of the payment an event
+// changed
+func (p *Paywall) notifyStatusWatchers(payload WebhookPayload) {
+	switch payload.Event {
+	case EventPaymentDetected, EventPaymentConfirmed, EventPaymentExpired:
+		p.statusWatchers.notify(payload.PaymentID)
+	}
+}
+
+// wantsEventStream reports whether a status request asks for Server-Sent Events
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamPaymentStatus serves the status of payment as Server-Sent Events: a
+// "status" event carrying a PaymentStatusResponse right away and again on
+// every change, until the payment is confirmed or expired, the client goes
+// away or the paywall closes
+func (p *Paywall) streamPaymentStatus(w http.ResponseWriter, r *http.Request, payment *Payment) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		p.writePaymentStatus(w, p.paymentStatus(r, payment, time.Now()))
+		return
+	}
+	changed, stop := p.statusWatchers.watch(payment.ID)
+	defer stop()
+
+	interval := p.statusPollInterval
+	if interval <= 0 {
+		interval = defaultStatusPollInterval
+	}
+	poll := time.NewTicker(interval)
+	defer poll.Stop()
+	var closed <-chan struct{}
+	if p.ctx != nil {
+		closed = p.ctx.Done()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Keep nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Browsers reconnect after the poll interval when the connection drops
+	fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds())
+
+	var last []byte
+	lastWrite := time.Now()
+	for {
+		resp := p.paymentStatus(r, payment, time.Now())
+		data, err := json.Marshal(resp)
+		if err != nil {
+			p.logger.log(LogEntry{
+				Level:     LogLevelError,
+				Event:     "response_encoding_failed",
+				Message:   fmt.Sprintf("Failed to encode payment status event: %v", err),
+				PaymentID: payment.ID,
+			})
+			return
+		}
+		switch {
+		case string(data) != string(last):
+			if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			last, lastWrite = data, time.Now()
+		case time.Since(lastWrite) >= statusStreamHeartbeat:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		flusher.Flush()
+		if resp.Status == StatusConfirmed || resp.Status == StatusExpired || (resp.PageCurrent != nil && !*resp.PageCurrent) {
+			return
+		}
+
+		// Wake up in time to report the expiry
+		expiry := time.NewTimer(time.Until(resp.ExpiresAt))
+		select {
+		case <-changed:
+		case <-poll.C:
+		case <-expiry.C:
+		case <-r.Context().Done():
+			expiry.Stop()
+			return
+		case <-closed:
+			expiry.Stop()
+			return
+		}
+		expiry.Stop()
+
+		current, err := p.Store.GetPayment(payment.ID)
+		if err != nil {
+			p.logger.log(LogEntry{
+				Level:     LogLevelWarn,
+				Event:     "payment_status_failed",
+				Message:   fmt.Sprintf("Failed to reload payment status: %v", err),
+				PaymentID: payment.ID,
+			})
+			continue
+		}
+		if current == nil {
+			return
+		}
+		payment = current
+	}
+}
@@ -0,0 +1,110 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StoreTimeoutPolicy decides how Middleware answers when the payment store
+// exceeds Config.StoreTimeout
+type StoreTimeoutPolicy string
+
+const (
+	// StoreFailClosed answers 503 Service Unavailable with a Retry-After header
+	StoreFailClosed StoreTimeoutPolicy = "closed"
+	// StoreFailOpen serves the protected content without payment
+	StoreFailOpen StoreTimeoutPolicy = "open"
+)
+
+// storeTimeoutRetryAfter is the Retry-After (in seconds) sent by StoreFailClosed
+const storeTimeoutRetryAfter = 30
+
+// ErrStoreTimeout is returned when the payment store does not answer within
+// Config.StoreTimeout
+var ErrStoreTimeout = errors.New("payment store did not answer in time")
+
+// storeResult carries a store call's result out of its goroutine
+type storeResult[T any] struct {
+	value T
+	err   error
+}
+
+// withStoreBudget runs fn, a store call on a visitor's request, within
+// Config.StoreTimeout. fn gets ctx bounded by the timeout, which cancels the
+// call for stores implementing ContextPaymentStore. For other stores a call
+// that times out keeps running in the background and its result is
+// discarded, so a hung store costs one goroutine per request instead of the
+// request itself. When ctx ends first (the visitor went away), ctx's error
+// is returned.
+//
+// Parameters:
+//   - ctx: The request's context
+//   - p: The paywall
+//   - op: Operation name for errors and logs
+//   - fn: The store call
+//
+// Returns:
+//   - T: fn's result
+//   - error: fn's error, ErrStoreTimeout, or ctx's error
+func withStoreBudget[T any](ctx context.Context, p *Paywall, op string, fn func(ctx context.Context) (T, error)) (T, error) {
+	timed := func(ctx context.Context) (T, error) {
+		start := time.Now()
+		value, err := fn(ctx)
+		p.metrics.observeStore(op, time.Since(start), err)
+		return value, err
+	}
+	if p.storeTimeout <= 0 {
+		return timed(ctx)
+	}
+	budget, cancel := context.WithTimeout(ctx, p.storeTimeout)
+	defer cancel()
+	done := make(chan storeResult[T], 1)
+	go func() {
+		// Timed to the end, so that the metrics show how slow the store is
+		value, err := timed(budget)
+		done <- storeResult[T]{value: value, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if ctx.Err() != nil || !errors.Is(result.err, context.DeadlineExceeded) {
+			return result.value, result.err
+		}
+		// A ContextPaymentStore gave up at the deadline
+	case <-budget.Done():
+	}
+
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, fmt.Errorf("%s: %w", op, err)
+	}
+	p.logger.log(LogEntry{
+		Level:   LogLevelWarn,
+		Event:   "store_timeout",
+		Message: fmt.Sprintf("Payment store did not %s within %v", op, p.storeTimeout),
+	})
+	return zero, fmt.Errorf("%s: %w", op, ErrStoreTimeout)
+}
+
+// getPaymentInBudget is Store.GetPayment within Config.StoreTimeout, for the
+// request with context ctx
+func (p *Paywall) getPaymentInBudget(ctx context.Context, id string) (*Payment, error) {
+	return withStoreBudget(ctx, p, "get payment", func(ctx context.Context) (*Payment, error) {
+		return getPaymentContext(ctx, p.Store, id)
+	})
+}
+
+// respondStoreTimeout answers a request whose store call timed out according
+// to Config.StoreTimeoutPolicy
+func (p *Paywall) respondStoreTimeout(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, next http.Handler) {
+	if p.storeTimeoutPolicy == StoreFailOpen {
+		next.ServeHTTP(w, cfg.withHandoffHeaders(r, HandoffStatusFree, ""))
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(storeTimeoutRetryAfter))
+	http.Error(w, "Payments are temporarily unavailable. Please try again later.", http.StatusServiceUnavailable)
+}
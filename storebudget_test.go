@@ -0,0 +1,136 @@
+package paywall
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// hangingStore is a MemoryStore whose request-path calls block until release is closed
+type hangingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s *hangingStore) GetPayment(id string) (*Payment, error) {
+	<-s.release
+	return s.MemoryStore.GetPayment(id)
+}
+
+func (s *hangingStore) CreatePayment(payment *Payment) error {
+	<-s.release
+	return s.MemoryStore.CreatePayment(payment)
+}
+
+func TestMiddleware_StoreTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     StoreTimeoutPolicy
+		cookie     bool
+		wantCode   int
+		wantServed bool
+	}{
+		{name: "lookup fail closed", policy: StoreFailClosed, cookie: true, wantCode: http.StatusServiceUnavailable},
+		{name: "lookup fail open", policy: StoreFailOpen, cookie: true, wantCode: http.StatusOK, wantServed: true},
+		{name: "create fail closed", policy: StoreFailClosed, wantCode: http.StatusServiceUnavailable},
+		{name: "create fail open", policy: StoreFailOpen, wantCode: http.StatusOK, wantServed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &hangingStore{MemoryStore: NewMemoryStore(), release: make(chan struct{})}
+			t.Cleanup(func() { close(store.release) })
+			pw, err := NewPaywall(Config{
+				PriceInBTC:         0.001,
+				PaymentTimeout:     time.Hour,
+				TestNet:            true,
+				Store:              store,
+				StoreTimeout:       20 * time.Millisecond,
+				StoreTimeoutPolicy: tt.policy,
+			})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			t.Cleanup(pw.Close)
+
+			served := false
+			handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+				if got := r.Header.Get("X-Paywall-Status"); got != HandoffStatusFree {
+					t.Errorf("X-Paywall-Status = %q, want %q", got, HandoffStatusFree)
+				}
+			}), WithUpstreamHandoff(""))
+			req := httptest.NewRequest(http.MethodGet, "/article", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: "payment_id", Value: "3f2a9c1b7d4e5f60"})
+			}
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			handler.ServeHTTP(rec, req)
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("request took %v despite StoreTimeout", elapsed)
+			}
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if served != tt.wantServed {
+				t.Errorf("content served = %v, want %v", served, tt.wantServed)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("503 without Retry-After")
+			}
+		})
+	}
+}
+
+func TestWithStoreBudget(t *testing.T) {
+	pw := &Paywall{storeTimeout: 10 * time.Millisecond, logger: NewStructuredLogger(io.Discard, LogLevelError, true)}
+	release := make(chan struct{})
+	defer close(release)
+
+	_, err := withStoreBudget(context.Background(), pw, "get payment", func(context.Context) (*Payment, error) {
+		<-release
+		return nil, nil
+	})
+	if !errors.Is(err, ErrStoreTimeout) {
+		t.Errorf("hung call error = %v, want ErrStoreTimeout", err)
+	}
+
+	got, err := withStoreBudget(context.Background(), pw, "get payment", func(context.Context) (string, error) {
+		return "fast", nil
+	})
+	if err != nil || got != "fast" {
+		t.Errorf("fast call = %q, %v, want \"fast\", nil", got, err)
+	}
+
+	// A store that honors the context gives up at the deadline
+	cancelled := make(chan struct{})
+	_, err = withStoreBudget(context.Background(), pw, "get payment", func(ctx context.Context) (*Payment, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, ErrStoreTimeout) {
+		t.Errorf("cancelled call error = %v, want ErrStoreTimeout", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("store call context not cancelled at the timeout")
+	}
+
+	// A visitor who went away is not reported as a store timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = withStoreBudget(ctx, pw, "get payment", func(ctx context.Context) (*Payment, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrStoreTimeout) {
+		t.Errorf("abandoned request error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,183 @@
+package paywall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultMonitorStoreRetries is the default Config.MonitorStoreRetries
+	defaultMonitorStoreRetries = 3
+	// defaultMonitorStoreRetryBackoff is the default Config.MonitorStoreRetryBackoff
+	defaultMonitorStoreRetryBackoff = 250 * time.Millisecond
+)
+
+// StoreErrorClass tells whether retrying a failed store write can succeed
+// Related: ClassifyStoreError, Config.MonitorStoreRetries
+type StoreErrorClass string
+
+const (
+	// StoreErrorTransient errors may pass on their own, e.g. timeouts, dropped
+	// connections or a database failing over; the write is retried after a
+	// backoff
+	StoreErrorTransient StoreErrorClass = "transient"
+	// StoreErrorConflict means the payment was changed concurrently
+	// (ErrVersionConflict); the write is retried at once on the current version
+	StoreErrorConflict StoreErrorClass = "conflict"
+	// StoreErrorPermanent errors fail again on retry, e.g. an unencodable
+	// payment, a permission error or a vetoed transition
+	StoreErrorPermanent StoreErrorClass = "permanent"
+)
+
+// ClassifyStoreError decides whether a store error is worth retrying. Stores
+// can classify their own errors by returning errors with a Temporary() bool
+// method, as net.Error does; errors the function does not recognize are
+// considered transient, so a store hiccup never loses a write for want of a
+// retry.
+//
+// Parameters:
+//   - err: Error returned by a PaymentStore method, not nil
+//
+// Returns:
+//   - StoreErrorClass: How the monitor treats the error
+func ClassifyStoreError(err error) StoreErrorClass {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return StoreErrorTransient
+		}
+		if temporary.Temporary() {
+			return StoreErrorTransient
+		}
+		return StoreErrorPermanent
+	}
+
+	var (
+		unsupportedType  *json.UnsupportedTypeError
+		unsupportedValue *json.UnsupportedValueError
+		marshalerErr     *json.MarshalerError
+	)
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		return StoreErrorConflict
+	case errors.Is(err, ErrTransitionVetoed), errors.Is(err, context.Canceled),
+		errors.Is(err, os.ErrPermission), errors.Is(err, syscall.EROFS),
+		errors.As(err, &unsupportedType), errors.As(err, &unsupportedValue), errors.As(err, &marshalerErr):
+		return StoreErrorPermanent
+	}
+	// Timeouts (ErrStoreTimeout, context.DeadlineExceeded), refused or reset
+	// connections, torn replies and driver errors
+	return StoreErrorTransient
+}
+
+// permanentStoreError marks an error that must not be retried
+type permanentStoreError struct{ err error }
+
+func (e permanentStoreError) Error() string   { return e.err.Error() }
+func (e permanentStoreError) Unwrap() error   { return e.err }
+func (e permanentStoreError) Temporary() bool { return false }
+
+// retryStoreWrite runs write until it succeeds, retrying transient errors up
+// to Config.MonitorStoreRetries times with a doubling backoff and conflicts at
+// once. A write that still fails is counted in the stats, logged and
+// published as EventStoreWriteFailed, unless it failed because the payment
+// moved on (a version conflict marked permanent).
+//
+// Parameters:
+//   - action: What is written, e.g. "confirm", for logs and events
+//   - paymentID: Payment being written
+//   - write: The write; it must leave the payment as it was when it fails
+//
+// Returns:
+//   - error: The last error of write, nil once it succeeded
+func (p *Paywall) retryStoreWrite(action, paymentID string, write func() error) error {
+	backoff := p.monitorStoreRetryBackoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := write()
+		p.metrics.observeStore("update payment", time.Since(start), err)
+		if err == nil {
+			if attempt > 1 {
+				if p.logger != nil {
+					p.logger.log(LogEntry{
+						Level:     LogLevelInfo,
+						Event:     "store_write_recovered",
+						Message:   fmt.Sprintf("Stored %s after %d attempts", action, attempt),
+						PaymentID: paymentID,
+					})
+				}
+			}
+			return nil
+		}
+
+		class := ClassifyStoreError(err)
+		if class == StoreErrorPermanent && errors.Is(err, ErrVersionConflict) {
+			// The payment moved on concurrently: the write no longer applies
+			// and nothing is lost
+			return err
+		}
+		if class == StoreErrorPermanent || attempt > p.monitorStoreRetries || p.shuttingDown() {
+			p.stats.storeFailures.Add(1)
+			if p.logger != nil {
+				p.logger.log(LogEntry{
+					Level:     LogLevelError,
+					Event:     "store_write_failed",
+					Message:   fmt.Sprintf("Failed to store %s after %d attempts (%s error): %v", action, attempt, class, err),
+					PaymentID: paymentID,
+				})
+			}
+			p.dispatchEvent(WebhookPayload{
+				Event:     EventStoreWriteFailed,
+				PaymentID: paymentID,
+				Timestamp: time.Now(),
+				Data: map[string]interface{}{
+					"action":   action,
+					"class":    class,
+					"attempts": attempt,
+					"error":    err.Error(),
+				},
+			})
+			return err
+		}
+
+		p.stats.storeRetries.Add(1)
+		if p.logger != nil {
+			p.logger.log(LogEntry{
+				Level:     LogLevelWarn,
+				Event:     "store_write_retry",
+				Message:   fmt.Sprintf("Retrying %s after %s store error (attempt %d): %v", action, class, attempt, err),
+				PaymentID: paymentID,
+			})
+		}
+		if class == StoreErrorTransient {
+			p.sleepUnlessClosed(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// shuttingDown reports whether Close was called
+func (p *Paywall) shuttingDown() bool {
+	return p.ctx != nil && p.ctx.Err() != nil
+}
+
+// sleepUnlessClosed waits for d, or until the paywall is closed
+func (p *Paywall) sleepUnlessClosed(d time.Duration) {
+	if p.ctx == nil {
+		time.Sleep(d)
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-p.ctx.Done():
+	}
+}
@@ -0,0 +1,223 @@
+package paywall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestClassifyStoreError(t *testing.T) {
+	_, jsonErr := json.Marshal(math.Inf(1))
+	tests := []struct {
+		name string
+		err  error
+		want StoreErrorClass
+	}{
+		{name: "version conflict", err: fmt.Errorf("update: %w", ErrVersionConflict), want: StoreErrorConflict},
+		{name: "store timeout", err: ErrStoreTimeout, want: StoreErrorTransient},
+		{name: "deadline", err: context.DeadlineExceeded, want: StoreErrorTransient},
+		{name: "unknown driver error", err: errors.New("connection refused"), want: StoreErrorTransient},
+		{name: "redis loading", err: redisError("LOADING Redis is loading the dataset in memory"), want: StoreErrorTransient},
+		{name: "redis wrong type", err: redisError("WRONGTYPE Operation against a key holding the wrong kind of value"), want: StoreErrorPermanent},
+		{name: "permission", err: fmt.Errorf("write payment: %w", os.ErrPermission), want: StoreErrorPermanent},
+		{name: "unencodable", err: jsonErr, want: StoreErrorPermanent},
+		{name: "canceled", err: context.Canceled, want: StoreErrorPermanent},
+		{name: "marked permanent", err: permanentStoreError{ErrVersionConflict}, want: StoreErrorPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyStoreError(tt.err); got != tt.want {
+				t.Errorf("ClassifyStoreError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// failingUpdateStore fails the next failures payment updates with err, and
+// runs beforeUpdate before each update
+type failingUpdateStore struct {
+	*MemoryStore
+	mu           sync.Mutex
+	failures     int
+	err          error
+	updates      int
+	beforeUpdate func(*Payment)
+}
+
+func (s *failingUpdateStore) UpdatePayment(p *Payment) error {
+	s.mu.Lock()
+	s.updates++
+	failing := s.failures > 0
+	if failing {
+		s.failures--
+	}
+	before := s.beforeUpdate
+	s.beforeUpdate = nil
+	s.mu.Unlock()
+	if before != nil {
+		before(p)
+	}
+	if failing {
+		return s.err
+	}
+	return s.MemoryStore.UpdatePayment(p)
+}
+
+func newRetryTestMonitor(store PaymentStore, logs io.Writer) *CryptoChainMonitor {
+	return &CryptoChainMonitor{
+		paywall: &Paywall{
+			Store:                    store,
+			minConfirmations:         1,
+			logger:                   NewStructuredLogger(logs, LogLevelInfo, true),
+			monitorStoreRetries:      3,
+			monitorStoreRetryBackoff: time.Millisecond,
+		},
+		client: map[wallet.WalletType]CryptoClient{wallet.Bitcoin: &mockCryptoClient{balance: 0.001}},
+	}
+}
+
+func createRetryTestPayment(t *testing.T, store PaymentStore) *Payment {
+	t.Helper()
+	payment := &Payment{
+		ID:        "retry-payment",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	if err := store.CreatePayment(payment); err != nil {
+		t.Fatal(err)
+	}
+	// The monitor works on payments read from the store
+	stored, _ := store.GetPayment(payment.ID)
+	return stored
+}
+
+func TestRetryStoreWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		err         error
+		wantStatus  PaymentStatus
+		wantUpdates int
+		wantRetries int64
+		wantFailed  bool
+	}{
+		{name: "transient errors recover", failures: 2, err: errors.New("connection reset by peer"), wantStatus: StatusConfirmed, wantUpdates: 3, wantRetries: 2},
+		{name: "retries exhausted", failures: 10, err: ErrStoreTimeout, wantStatus: StatusPending, wantUpdates: 4, wantRetries: 3, wantFailed: true},
+		{name: "permanent error", failures: 1, err: fmt.Errorf("write payment: %w", os.ErrPermission), wantStatus: StatusPending, wantUpdates: 1, wantFailed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &failingUpdateStore{MemoryStore: NewMemoryStore(), failures: tt.failures, err: tt.err}
+			payment := createRetryTestPayment(t, store)
+			var logs bytes.Buffer
+			monitor := newRetryTestMonitor(store, &logs)
+
+			err := monitor.checkWalletPayment(payment, wallet.Bitcoin, &sync.Mutex{})
+			if (err != nil) != tt.wantFailed {
+				t.Fatalf("checkWalletPayment() error = %v, want failure %v", err, tt.wantFailed)
+			}
+			if stored, _ := store.GetPayment(payment.ID); stored.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if store.updates != tt.wantUpdates {
+				t.Errorf("updates = %d, want %d", store.updates, tt.wantUpdates)
+			}
+			stats := &monitor.paywall.stats
+			if stats.storeRetries.Load() != tt.wantRetries || (stats.storeFailures.Load() == 1) != tt.wantFailed {
+				t.Errorf("stats: %d retries, %d failures", stats.storeRetries.Load(), stats.storeFailures.Load())
+			}
+			if failedLogged := strings.Contains(logs.String(), `"store_write_failed"`); failedLogged != tt.wantFailed {
+				t.Errorf("store_write_failed logged = %v, want %v", failedLogged, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestRetryStoreWrite_ConflictAppliesToStoredVersion(t *testing.T) {
+	store := &failingUpdateStore{MemoryStore: NewMemoryStore()}
+	payment := createRetryTestPayment(t, store)
+	// An operator tags the payment while the monitor is confirming it
+	store.beforeUpdate = func(*Payment) {
+		tagged, _ := store.MemoryStore.GetPayment(payment.ID)
+		copied := *tagged
+		copied.Tags = []string{"vip"}
+		if err := store.MemoryStore.UpdatePayment(&copied); err != nil {
+			t.Error(err)
+		}
+	}
+	monitor := newRetryTestMonitor(store, io.Discard)
+
+	if err := monitor.checkWalletPayment(payment, wallet.Bitcoin, &sync.Mutex{}); err != nil {
+		t.Fatalf("checkWalletPayment() error = %v", err)
+	}
+	stored, _ := store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || len(stored.Tags) != 1 {
+		t.Errorf("stored payment = %s with tags %v, want confirmed and tagged", stored.Status, stored.Tags)
+	}
+
+	// A payment another process already expired is left alone
+	store.beforeUpdate = func(*Payment) {
+		moved, _ := store.MemoryStore.GetPayment("moved-on")
+		copied := *moved
+		copied.Status = StatusExpired
+		store.MemoryStore.UpdatePayment(&copied)
+	}
+	movedOn := &Payment{ID: "moved-on", Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-moved"}, Amounts: map[wallet.WalletType]float64{wallet.Bitcoin: 0.001}, ExpiresAt: time.Now().Add(time.Hour), Status: StatusPending}
+	store.CreatePayment(movedOn)
+	movedOn, _ = store.GetPayment(movedOn.ID)
+	err := monitor.checkWalletPayment(movedOn, wallet.Bitcoin, &sync.Mutex{})
+	if !errors.Is(err, ErrVersionConflict) || ClassifyStoreError(err) != StoreErrorPermanent {
+		t.Errorf("checkWalletPayment() error = %v, want a permanent version conflict", err)
+	}
+	if stored, _ := store.GetPayment("moved-on"); stored.Status != StatusExpired {
+		t.Errorf("stored status = %s, want expired", stored.Status)
+	}
+	if failures := monitor.paywall.stats.storeFailures.Load(); failures != 0 {
+		t.Errorf("store failures = %d, want 0 for a superseded write", failures)
+	}
+}
+
+func TestMonitorStoreRetries_Config(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pw.Close()
+	if pw.monitorStoreRetries != defaultMonitorStoreRetries || pw.monitorStoreRetryBackoff != defaultMonitorStoreRetryBackoff {
+		t.Errorf("defaults = %d retries after %v", pw.monitorStoreRetries, pw.monitorStoreRetryBackoff)
+	}
+
+	disabled, err := NewPaywall(Config{
+		PriceInBTC:          0.001,
+		PaymentTimeout:      time.Hour,
+		TestNet:             true,
+		Store:               NewMemoryStore(),
+		MonitorStoreRetries: -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disabled.Close()
+	if disabled.monitorStoreRetries != 0 {
+		t.Errorf("MonitorStoreRetries -1 gives %d retries, want 0", disabled.monitorStoreRetries)
+	}
+}
@@ -0,0 +1,128 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// ErrStopStream can be returned by a StreamPayments callback to end the
+// stream early; StreamPayments then returns nil
+var ErrStopStream = errors.New("stop streaming payments")
+
+// PaymentFilter selects the payments StreamPayments passes to its callback.
+// The zero value selects every payment.
+type PaymentFilter struct {
+	// Statuses selects payments in any of these statuses.
+	// Optional: if empty, payments in every status are selected.
+	Statuses []PaymentStatus
+	// CreatedFrom is the inclusive lower bound of CreatedAt. Optional.
+	CreatedFrom time.Time
+	// CreatedTo is the exclusive upper bound of CreatedAt. Optional.
+	CreatedTo time.Time
+	// Tag selects payments carrying the tag (see Payment.HasTag). Optional.
+	Tag string
+	// Partition selects payments created in the partition (see WithPartition).
+	// Optional.
+	Partition string
+	// Search selects payments with an address or transaction ID that starts or
+	// ends with it, ignoring case (see MatchesFragment). Optional.
+	Search string
+	// Currency selects payments paid in it (Payment.PaidCurrency) and unpaid
+	// payments offering it. Optional.
+	Currency wallet.WalletType
+}
+
+// Matches reports whether payment is selected by the filter
+func (f PaymentFilter) Matches(payment *Payment) bool {
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, payment.Status) {
+		return false
+	}
+	if !f.CreatedFrom.IsZero() && payment.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+	if !f.CreatedTo.IsZero() && !payment.CreatedAt.Before(f.CreatedTo) {
+		return false
+	}
+	if f.Partition != "" && PaymentPartition(payment.ID) != f.Partition {
+		return false
+	}
+	if f.Search != "" && !MatchesFragment(payment, f.Search) {
+		return false
+	}
+	if f.Currency != "" && !paysIn(payment, f.Currency) {
+		return false
+	}
+	return f.Tag == "" || payment.HasTag(f.Tag)
+}
+
+// paysIn reports whether payment was paid in currency or, unpaid, offers it
+func paysIn(payment *Payment, currency wallet.WalletType) bool {
+	if payment.PaidCurrency != "" {
+		return payment.PaidCurrency == currency
+	}
+	_, offered := payment.Addresses[currency]
+	return offered
+}
+
+// PaymentStreamer is an optional PaymentStore extension that visits payments
+// one at a time instead of returning them all in one slice, so exports and
+// reports over millions of payments run in constant memory. MemoryStore,
+// FileStore, EncryptedFileStore, S3Store, SQLStore, RedisStore and
+// ReplicatedStore implement it.
+type PaymentStreamer interface {
+	// StreamPayments calls fn for every payment matching filter, in no
+	// particular order. The store is not locked while fn runs, so fn may
+	// call the store; payments created or changed during the stream may or
+	// may not be visited. Returning an error from fn ends the stream with
+	// that error, except ErrStopStream, which ends it without error.
+	StreamPayments(filter PaymentFilter, fn func(*Payment) error) error
+}
+
+// StreamPayments calls fn for every payment in store matching filter. Stores
+// implementing PaymentStreamer stream their payments; for stores that only
+// implement PaymentLister the payments are listed first.
+//
+// Parameters:
+//   - store: The payment store
+//   - filter: Selects the payments passed to fn
+//   - fn: Called once per payment; return ErrStopStream to stop early
+//
+// Returns:
+//   - error: If the store can neither stream nor list payments, reading fails,
+//     or fn returns an error other than ErrStopStream
+//
+// Related: PaymentStreamer, PaymentFilter
+func StreamPayments(store PaymentStore, filter PaymentFilter, fn func(*Payment) error) error {
+	if streamer, ok := store.(PaymentStreamer); ok {
+		return streamer.StreamPayments(filter, fn)
+	}
+	lister, ok := store.(PaymentLister)
+	if !ok {
+		return fmt.Errorf("payment store %T does not support listing payments", store)
+	}
+	payments, err := lister.ListPayments()
+	if err != nil {
+		return fmt.Errorf("list payments: %w", err)
+	}
+	for _, payment := range payments {
+		if !filter.Matches(payment) {
+			continue
+		}
+		if err := fn(payment); err != nil {
+			return streamEnded(err)
+		}
+	}
+	return nil
+}
+
+// streamEnded returns the error a stream ends with after its callback failed
+func streamEnded(err error) error {
+	if errors.Is(err, ErrStopStream) {
+		return nil
+	}
+	return err
+}
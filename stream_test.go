@@ -0,0 +1,126 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func TestStreamPayments(t *testing.T) {
+	encrypted, err := NewEncryptedFileStore(t.TempDir()+"/store.key", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEncryptedFileStore() error = %v", err)
+	}
+	s3, _ := newTestS3Store(t)
+	memory := NewMemoryStore()
+	stores := map[string]PaymentStore{
+		"memory":    memory,
+		"file":      NewFileStore(t.TempDir()),
+		"encrypted": encrypted,
+		"s3":        s3,
+		// A store that can only list is streamed from its listing
+		"lister": struct {
+			PaymentStore
+			PaymentLister
+		}{memory, memory},
+	}
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, store := range stores {
+		if name == "lister" {
+			continue // shares the memory store's payments
+		}
+		for i := 0; i < 6; i++ {
+			payment := &Payment{
+				ID:        fmt.Sprintf("pay%d", i),
+				Addresses: map[wallet.WalletType]string{wallet.Bitcoin: fmt.Sprintf("addr%d", i)},
+				Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+				Status:    StatusPending,
+				CreatedAt: base.Add(time.Duration(i) * 24 * time.Hour),
+			}
+			if i%2 == 0 {
+				payment.Status = StatusConfirmed
+				payment.Tags = []string{"vip"}
+			}
+			if err := store.CreatePayment(payment); err != nil {
+				t.Fatalf("%s: CreatePayment() error = %v", name, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter PaymentFilter
+		want   []string
+	}{
+		{"all", PaymentFilter{}, []string{"pay0", "pay1", "pay2", "pay3", "pay4", "pay5"}},
+		{"status", PaymentFilter{Statuses: []PaymentStatus{StatusPending}}, []string{"pay1", "pay3", "pay5"}},
+		{"created range", PaymentFilter{CreatedFrom: base.Add(24 * time.Hour), CreatedTo: base.Add(3 * 24 * time.Hour)}, []string{"pay1", "pay2"}},
+		{"tag", PaymentFilter{Tag: "VIP"}, []string{"pay0", "pay2", "pay4"}},
+	}
+	for name, store := range stores {
+		for _, tt := range tests {
+			var got []string
+			err := StreamPayments(store, tt.filter, func(payment *Payment) error {
+				got = append(got, payment.ID)
+				return nil
+			})
+			sort.Strings(got)
+			if err != nil || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("%s/%s: StreamPayments() = %v, %v, want %v", name, tt.name, got, err, tt.want)
+			}
+		}
+
+		visited := 0
+		err := StreamPayments(store, PaymentFilter{}, func(*Payment) error {
+			visited++
+			return ErrStopStream
+		})
+		if err != nil || visited != 1 {
+			t.Errorf("%s: StreamPayments() stopped after %d payments, error = %v, want 1, nil", name, visited, err)
+		}
+
+		failure := errors.New("callback failed")
+		if err := StreamPayments(store, PaymentFilter{}, func(*Payment) error { return failure }); !errors.Is(err, failure) {
+			t.Errorf("%s: StreamPayments() error = %v, want the callback's error", name, err)
+		}
+	}
+}
+
+func TestStreamPayments_CallbackUpdatesStore(t *testing.T) {
+	for name, store := range map[string]PaymentStore{"memory": NewMemoryStore(), "file": NewFileStore(t.TempDir())} {
+		if err := store.CreatePayment(fileLockTestPayment("pay")); err != nil {
+			t.Fatalf("%s: CreatePayment() error = %v", name, err)
+		}
+		// The store must not be locked while the callback runs
+		done := make(chan error, 1)
+		go func() {
+			done <- StreamPayments(store, PaymentFilter{}, func(payment *Payment) error {
+				payment.Status = StatusExpired
+				return store.UpdatePayment(payment)
+			})
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%s: StreamPayments() error = %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: UpdatePayment() from the callback deadlocked", name)
+		}
+		if payment, _ := store.GetPayment("pay"); payment.Status != StatusExpired {
+			t.Errorf("%s: Status = %s, want %s", name, payment.Status, StatusExpired)
+		}
+	}
+}
+
+func TestStreamPayments_Unsupported(t *testing.T) {
+	store := struct{ PaymentStore }{NewMemoryStore()}
+	if err := StreamPayments(store, PaymentFilter{}, func(*Payment) error { return nil }); err == nil {
+		t.Error("StreamPayments() on a store without listing succeeded")
+	}
+}
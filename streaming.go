@@ -0,0 +1,189 @@
+package paywall
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrGrantExpired is returned by writes to a revalidated response once the
+// visitor's access has expired or been revoked mid-connection
+var ErrGrantExpired = errors.New("paywall: access expired during the response")
+
+// grantCheck reports whether a forwarded request's access is still valid and,
+// if known, when it ends (zero for no fixed end)
+type grantCheck func() (until time.Time, valid bool)
+
+// WithRevalidation re-checks access while a response is in progress, for
+// long-lived protected responses such as SSE streams, WebSockets and large
+// downloads. Without it, access is only checked when the connection starts.
+//
+// Every interval, and exactly when the payment's access window ends, the grant
+// (payment cookie, query token or API key) is checked again. When it has expired
+// or was revoked the request context is cancelled, further writes fail with
+// ErrGrantExpired and hijacked connections (WebSockets) are closed. Handlers
+// should stop when r.Context() is done.
+//
+// Parameters:
+//   - interval: How often to re-check; must be positive to enable revalidation
+func WithRevalidation(interval time.Duration) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.revalidateInterval = interval
+	}
+}
+
+// paymentGrant checks that a payment still grants access
+func (p *Paywall) paymentGrant(paymentID string) grantCheck {
+	return func() (time.Time, bool) {
+		payment, err := p.Store.GetPayment(paymentID)
+		if err != nil {
+			// Transient storage errors should not cut off paying visitors
+			return time.Time{}, true
+		}
+		if payment == nil || payment.Status != StatusConfirmed {
+			return time.Time{}, false
+		}
+		return payment.AccessEnds(), time.Now().Before(payment.AccessEnds())
+	}
+}
+
+// apiKeyGrant checks that an API key is still active and, for keys issued for a
+// payment, that the payment is still confirmed
+func (p *Paywall) apiKeyGrant(keyID string) grantCheck {
+	return func() (time.Time, bool) {
+		store, err := p.apiKeyStore()
+		if err != nil {
+			return time.Time{}, false
+		}
+		key, err := store.GetAPIKey(keyID)
+		if err != nil {
+			return time.Time{}, true
+		}
+		if key == nil || !key.Active(time.Now()) {
+			return time.Time{}, false
+		}
+		if key.PaymentID != "" {
+			payment, err := p.Store.GetPayment(key.PaymentID)
+			if err == nil && (payment == nil || payment.Status != StatusConfirmed) {
+				return time.Time{}, false
+			}
+		}
+		return key.ExpiresAt, true
+	}
+}
+
+// serveRevalidated calls next while periodically re-running check, ending the
+// response when the grant lapses
+func (p *Paywall) serveRevalidated(w http.ResponseWriter, r *http.Request, interval time.Duration, paymentID string, check grantCheck, next http.Handler) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	rw := &revalidatingWriter{ResponseWriter: w}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			until, valid := check()
+			wait := interval
+			if !until.IsZero() {
+				if d := time.Until(until); d < wait {
+					wait = d
+				}
+			}
+			if !valid || wait <= 0 {
+				p.logger.log(LogEntry{
+					Level:     LogLevelInfo,
+					Event:     "grant_expired_mid_connection",
+					Message:   fmt.Sprintf("Access ended during response to %s, closing connection", r.URL.Path),
+					PaymentID: paymentID,
+				})
+				rw.revoke()
+				cancel()
+				return
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	next.ServeHTTP(rw, r.WithContext(ctx))
+}
+
+// revalidatingWriter refuses writes once the grant has lapsed and closes
+// hijacked connections at that point
+type revalidatingWriter struct {
+	http.ResponseWriter
+	mu      sync.Mutex
+	revoked bool
+	conn    net.Conn
+}
+
+func (rw *revalidatingWriter) revoke() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.revoked = true
+	if rw.conn != nil {
+		rw.conn.Close()
+	}
+}
+
+func (rw *revalidatingWriter) isRevoked() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.revoked
+}
+
+func (rw *revalidatingWriter) Write(b []byte) (int, error) {
+	if rw.isRevoked() {
+		return 0, ErrGrantExpired
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush supports streaming responses such as server-sent events
+func (rw *revalidatingWriter) Flush() {
+	if rw.isRevoked() {
+		return
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades; the connection is closed when the grant lapses
+func (rw *revalidatingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("paywall: response writer does not support hijacking")
+	}
+	conn, buf, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.revoked {
+		conn.Close()
+		return nil, nil, ErrGrantExpired
+	}
+	rw.conn = conn
+	return conn, buf, nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *revalidatingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,102 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithRevalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		lapse  func(pw *Paywall, payment *Payment)
+		within time.Duration
+	}{
+		{
+			name: "access window ends",
+			lapse: func(pw *Paywall, payment *Payment) {
+				payment.ExpiresAt = time.Now().Add(100 * time.Millisecond)
+				pw.Store.UpdatePayment(payment)
+			},
+			within: time.Second,
+		},
+		{
+			name: "payment no longer confirmed",
+			lapse: func(pw *Paywall, payment *Payment) {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					latest, _ := pw.Store.GetPayment(payment.ID)
+					latest.Status = StatusExpired
+					pw.Store.UpdatePayment(latest)
+				}()
+			},
+			within: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+			payment := confirmedTestPayment(t, pw)
+			tt.lapse(pw, payment)
+
+			writeErr := make(chan error, 1)
+			handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-r.Context().Done():
+						_, err := w.Write([]byte("data: late\n\n"))
+						writeErr <- err
+						return
+					case <-ticker.C:
+						w.Write([]byte("data: tick\n\n"))
+						w.(http.Flusher).Flush()
+					}
+				}
+			}), WithRevalidation(50*time.Millisecond))
+
+			req := httptest.NewRequest(http.MethodGet, "/live", nil)
+			req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+			finished := make(chan struct{})
+			go func() {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+				close(finished)
+			}()
+
+			select {
+			case <-finished:
+			case <-time.After(tt.within):
+				t.Fatal("stream was not ended after access lapsed")
+			}
+			if err := <-writeErr; !errors.Is(err, ErrGrantExpired) {
+				t.Errorf("write after lapse error = %v, want ErrGrantExpired", err)
+			}
+		})
+	}
+}
+
+func TestWithRevalidation_ValidGrantKeepsStreaming(t *testing.T) {
+	pw := newTestPaywall(t, Config{APIKeysEnabled: true})
+	payment := confirmedTestPayment(t, pw)
+
+	handler := pw.MiddlewareWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			t.Error("context cancelled while access is valid")
+		case <-time.After(150 * time.Millisecond):
+		}
+		w.Write([]byte("done"))
+	}), WithRevalidation(20*time.Millisecond))
+
+	req := httptest.NewRequest(http.MethodGet, "/live", nil)
+	req.AddCookie(&http.Cookie{Name: "payment_id", Value: payment.ID})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Body.String() != "done" {
+		t.Errorf("body = %q, want done", rec.Body.String())
+	}
+}
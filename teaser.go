@@ -0,0 +1,337 @@
+package paywall
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// defaultTeaserParagraphs is how many paragraphs a teaser shows by default
+	defaultTeaserParagraphs = 3
+	// defaultTeaserMaxBytes bounds upstream pages buffered for a teaser
+	defaultTeaserMaxBytes = 5 << 20
+	// teaserWidgetClass is the class of the element holding the payment widget
+	teaserWidgetClass = "paywall-teaser-widget"
+)
+
+// HTMLTransformer rewrites the teaser document of an unpaid request after it was
+// truncated and before the payment widget is inserted, e.g. to drop comment
+// sections or add a "continue reading" fade. Returning an error shows the plain
+// payment page instead.
+type HTMLTransformer func(doc *html.Node, r *http.Request) error
+
+// TeaserConfig configures WithTeaser
+type TeaserConfig struct {
+	// Selector picks the element holding the article text: a tag ("article"),
+	// an ID ("#content"), a class (".post-body") or a tag with an ID or class
+	// ("div.entry"). Optional: defaults to "body"; the first match is used.
+	Selector string
+	// Paragraphs is how many <p> elements of the article to show.
+	// Optional: defaults to 3 when Percent is not set either.
+	Paragraphs int
+	// Percent shows this share (1-100) of the article's paragraphs instead,
+	// rounded up. Used when Paragraphs is 0.
+	Percent int
+	// Transformers run in order on every teaser document
+	Transformers []HTMLTransformer
+	// MaxBytes bounds the upstream page; larger pages get the plain payment page.
+	// Optional: defaults to 5 MiB.
+	MaxBytes int64
+}
+
+// WithTeaser lets unpaid visitors see the start of the protected page instead
+// of a full-page block: the protected handler (e.g. a reverse proxy) is called,
+// its HTML response is cut after the first paragraphs of the article, and the
+// payment widget is inserted below them. At least the last paragraph is always
+// withheld, so a teaser never gives away a whole article.
+//
+// Only GET requests whose protected response is a 200 text/html page are
+// rewritten; other requests, non-HTML responses, compressed or oversized pages
+// and pages without a Selector match get the plain payment page, never the
+// upstream content. Routes with a WithStatusResponse responder for the payment's
+// status keep using it.
+func WithTeaser(config TeaserConfig) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		if config.Selector == "" {
+			config.Selector = "body"
+		}
+		if config.Paragraphs <= 0 && config.Percent <= 0 {
+			config.Paragraphs = defaultTeaserParagraphs
+		}
+		if config.Percent > 100 {
+			config.Percent = 100
+		}
+		if config.MaxBytes <= 0 {
+			config.MaxBytes = defaultTeaserMaxBytes
+		}
+		cfg.teaser = &config
+	}
+}
+
+// respondUnpaid shows an unpaid visitor their payment: the protected handler
+// itself when the route hands unpaid requests off, the route's teaser when
+// configured and possible, the responder or payment page otherwise
+func (p *Paywall) respondUnpaid(w http.ResponseWriter, r *http.Request, cfg *middlewareConfig, payment *Payment, next http.Handler) {
+	if p.handoff(w, r, cfg, payment, next) {
+		return
+	}
+	if _, ok := cfg.responders[payment.Status]; !ok && cfg.teaser != nil && r.Method == http.MethodGet && !p.wantsJSON(r) {
+		if page, ok := p.teaserPage(r, cfg.teaser, payment, next); ok {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write(page)
+			return
+		}
+	}
+	p.respond(w, r, cfg, payment)
+}
+
+// teaserPage fetches the protected page from next and turns it into a teaser
+// with the payment widget
+//
+// Returns:
+//   - []byte: The rendered teaser page
+//   - bool: false if the page cannot be turned into a teaser
+func (p *Paywall) teaserPage(r *http.Request, teaser *TeaserConfig, payment *Payment, next http.Handler) ([]byte, bool) {
+	upstream := r.Clone(r.Context())
+	// Ask for the full, uncompressed page so it can be rewritten
+	for _, header := range []string{"Accept-Encoding", "Range", "If-None-Match", "If-Modified-Since"} {
+		upstream.Header.Del(header)
+	}
+	rec := &teaserRecorder{header: make(http.Header), limit: teaser.MaxBytes}
+	next.ServeHTTP(rec, upstream)
+
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	if rec.status() != http.StatusOK || mediaType != "text/html" || rec.overflow || rec.header.Get("Content-Encoding") != "" {
+		return nil, false
+	}
+	doc, err := html.Parse(&rec.body)
+	if err != nil {
+		return nil, false
+	}
+	article := findElement(doc, teaser.Selector)
+	if article == nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelDebug,
+			Event:     "teaser_selector_missing",
+			Message:   fmt.Sprintf("No %q element in %s, showing the payment page", teaser.Selector, r.URL.Path),
+			PaymentID: payment.ID,
+		})
+		return nil, false
+	}
+	truncateParagraphs(article, teaser.Paragraphs, teaser.Percent)
+	for _, transform := range teaser.Transformers {
+		if err := transform(doc, r); err != nil {
+			p.logger.log(LogEntry{
+				Level:     LogLevelWarn,
+				Event:     "teaser_transform_failed",
+				Message:   fmt.Sprintf("Teaser transformer failed for %s: %v", r.URL.Path, err),
+				PaymentID: payment.ID,
+			})
+			return nil, false
+		}
+	}
+
+	widget, err := p.paymentWidget(r, payment)
+	if err != nil {
+		return nil, false
+	}
+	article.AppendChild(widget)
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// paymentWidget renders the payment page and returns its styles, scripts and
+// body content wrapped in a <div class="paywall-teaser-widget">
+func (p *Paywall) paymentWidget(r *http.Request, payment *Payment) (*html.Node, error) {
+	page, err := p.executePaymentPage(r, payment)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "template_render_failed",
+			Message:   fmt.Sprintf("Failed to render payment widget: %v", err),
+			PaymentID: payment.ID,
+		})
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	widget := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+		Attr:     []html.Attribute{{Key: "class", Val: teaserWidgetClass}},
+	}
+	if head := findElement(doc, "head"); head != nil {
+		for _, style := range childElements(head, atom.Style) {
+			head.RemoveChild(style)
+			widget.AppendChild(style)
+		}
+	}
+	if body := findElement(doc, "body"); body != nil {
+		for child := body.FirstChild; child != nil; child = body.FirstChild {
+			body.RemoveChild(child)
+			widget.AppendChild(child)
+		}
+	}
+	return widget, nil
+}
+
+// truncateParagraphs keeps the first paragraphs of article (or percent of
+// them) and removes everything after them, always withholding at least the
+// last paragraph
+func truncateParagraphs(article *html.Node, paragraphs, percent int) {
+	var all []*html.Node
+	walkElements(article, func(n *html.Node) bool {
+		if n.DataAtom == atom.P {
+			all = append(all, n)
+			return false
+		}
+		return true
+	})
+
+	keep := paragraphs
+	if keep <= 0 {
+		keep = (len(all)*percent + 99) / 100
+	}
+	keep = min(keep, len(all)-1)
+	if keep <= 0 {
+		for child := article.FirstChild; child != nil; child = article.FirstChild {
+			article.RemoveChild(child)
+		}
+		return
+	}
+
+	// Remove everything following the last kept paragraph, up to the article
+	for n := all[keep-1]; n != article; n = n.Parent {
+		for sibling := n.NextSibling; sibling != nil; sibling = n.NextSibling {
+			n.Parent.RemoveChild(sibling)
+		}
+	}
+}
+
+// findElement returns the first element in document order matching a simple
+// selector: "tag", "#id", ".class", "tag#id" or "tag.class"
+func findElement(root *html.Node, selector string) *html.Node {
+	tag, id, class := selector, "", ""
+	if i := strings.IndexAny(selector, "#."); i >= 0 {
+		tag = selector[:i]
+		if selector[i] == '#' {
+			id = selector[i+1:]
+		} else {
+			class = selector[i+1:]
+		}
+	}
+
+	var found *html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if (tag == "" || n.Data == tag) && (id == "" || attr(n, "id") == id) &&
+			(class == "" || containsField(attr(n, "class"), class)) {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// walkElements calls visit for every element below root in document order,
+// descending into an element's children while visit returns true
+func walkElements(root *html.Node, visit func(*html.Node) bool) {
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && !visit(child) {
+			continue
+		}
+		walkElements(child, visit)
+	}
+}
+
+// childElements returns the direct children of n with the given atom
+func childElements(n *html.Node, a atom.Atom) []*html.Node {
+	var children []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == a {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// attr returns the value of n's attribute key, "" if absent
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// containsField reports whether the space-separated list contains field
+func containsField(list, field string) bool {
+	for _, f := range strings.Fields(list) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// teaserRecorder buffers the protected handler's response for rewriting
+type teaserRecorder struct {
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+// Header implements http.ResponseWriter
+func (rec *teaserRecorder) Header() http.Header {
+	return rec.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rec *teaserRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+// Write implements http.ResponseWriter, discarding everything once the limit is exceeded
+func (rec *teaserRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	if rec.overflow {
+		return len(b), nil
+	}
+	if int64(rec.body.Len()+len(b)) > rec.limit {
+		rec.overflow = true
+		rec.body.Reset()
+		return len(b), nil
+	}
+	return rec.body.Write(b)
+}
+
+// status returns the recorded status code
+func (rec *teaserRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
@@ -0,0 +1,172 @@
+package paywall
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const teaserArticle = `<!DOCTYPE html><html><head><title>Post</title></head><body>
+<nav>Home</nav>
+<article class="post">
+<h1>Title</h1>
+<p>First paragraph.</p>
+<div class="section"><p>Second paragraph.</p><p>Third paragraph.</p></div>
+<figure>chart</figure>
+<p>Fourth paragraph.</p>
+</article>
+<footer>Footer</footer>
+</body></html>`
+
+func TestTruncateParagraphs(t *testing.T) {
+	tests := []struct {
+		name       string
+		paragraphs int
+		percent    int
+		want       []string
+		withheld   []string
+	}{
+		{"first paragraph", 1, 0, []string{"Title", "First"}, []string{"Second", "chart", "Fourth"}},
+		{"nested paragraph", 2, 0, []string{"First", "Second"}, []string{"Third", "chart", "Fourth"}},
+		{"percent rounds up", 0, 50, []string{"Second"}, []string{"Third", "Fourth"}},
+		{"last paragraph withheld", 10, 0, []string{"Third"}, []string{"chart", "Fourth"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, _ := html.Parse(strings.NewReader(teaserArticle))
+			article := findElement(doc, "article.post")
+			if article == nil {
+				t.Fatal("findElement(article.post) = nil")
+			}
+			truncateParagraphs(article, tt.paragraphs, tt.percent)
+			var out strings.Builder
+			html.Render(&out, doc)
+			page := out.String()
+			for _, s := range append(tt.want, "Home", "Footer") {
+				if !strings.Contains(page, s) {
+					t.Errorf("teaser lacks %q", s)
+				}
+			}
+			for _, s := range tt.withheld {
+				if strings.Contains(page, s) {
+					t.Errorf("teaser gives away %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestFindElement(t *testing.T) {
+	doc, _ := html.Parse(strings.NewReader(`<body><div id="main" class="a b"><p class="lead">x</p></div></body>`))
+	tests := []struct {
+		selector string
+		wantTag  string
+	}{
+		{"#main", "div"},
+		{".b", "div"},
+		{"div#main", "div"},
+		{"p.lead", "p"},
+		{"p", "p"},
+		{"span", ""},
+		{"div.lead", ""},
+	}
+	for _, tt := range tests {
+		got := findElement(doc, tt.selector)
+		if (got == nil && tt.wantTag != "") || (got != nil && got.Data != tt.wantTag) {
+			t.Errorf("findElement(%q) = %v, want <%s>", tt.selector, got, tt.wantTag)
+		}
+	}
+}
+
+func TestWithTeaser(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          NewMemoryStore(),
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "" {
+			t.Error("upstream asked for a compressed page")
+		}
+		switch r.URL.Path {
+		case "/feed.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"secret":"Fourth paragraph."}`))
+		case "/missing":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<p>Fourth paragraph.</p>"))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(teaserArticle))
+		}
+	})
+	dropFigures := func(doc *html.Node, r *http.Request) error {
+		if figure := findElement(doc, "figure"); figure != nil {
+			figure.Parent.RemoveChild(figure)
+		}
+		return nil
+	}
+	failing := func(*html.Node, *http.Request) error { return errors.New("broken") }
+
+	tests := []struct {
+		name        string
+		path        string
+		method      string
+		config      TeaserConfig
+		wantTeaser  bool
+		wantMissing string
+	}{
+		{"teaser", "/post", http.MethodGet, TeaserConfig{Selector: "article", Paragraphs: 1, Transformers: []HTMLTransformer{dropFigures}}, true, "chart"},
+		{"non-HTML response", "/feed.json", http.MethodGet, TeaserConfig{}, false, ""},
+		{"error response", "/missing", http.MethodGet, TeaserConfig{}, false, ""},
+		{"selector without match", "/post", http.MethodGet, TeaserConfig{Selector: "#nope"}, false, ""},
+		{"failing transformer", "/post", http.MethodGet, TeaserConfig{Transformers: []HTMLTransformer{failing}}, false, ""},
+		{"oversized page", "/post", http.MethodGet, TeaserConfig{MaxBytes: 100}, false, ""},
+		{"non-GET request", "/post", http.MethodPost, TeaserConfig{}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := pw.MiddlewareWithOptions(upstream, WithTeaser(tt.config))
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			body := rec.Body.String()
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if strings.Contains(body, "Fourth paragraph.") {
+				t.Error("unpaid visitor received the withheld content")
+			}
+			if got := strings.Contains(body, teaserWidgetClass); got != tt.wantTeaser {
+				t.Errorf("teaser widget present = %v, want %v", got, tt.wantTeaser)
+			}
+			if tt.wantTeaser {
+				if !strings.Contains(body, "First paragraph.") || !strings.Contains(body, "<nav>Home</nav>") {
+					t.Error("teaser lacks the start of the page")
+				}
+				if tt.wantMissing != "" && strings.Contains(body, tt.wantMissing) {
+					t.Errorf("transformer did not remove %q", tt.wantMissing)
+				}
+				if !strings.Contains(body, "payment-details") || rec.Header().Get("Set-Cookie") == "" {
+					t.Error("teaser lacks the payment widget or payment cookie")
+				}
+			} else if !strings.Contains(body, "<title>Payment Required</title>") {
+				t.Error("fallback is not the payment page")
+			}
+		})
+	}
+}
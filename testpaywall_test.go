@@ -0,0 +1,33 @@
+package paywall
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// testSigningKey is a SigningKey for tests that sign tokens
+var testSigningKey = bytes.Repeat([]byte{0x42}, minSigningKeyLength)
+
+// newTestPaywall creates a testnet Paywall from config and closes it when the
+// test ends. PriceInBTC, PaymentTimeout and Store default to 0.001 BTC, an
+// hour and a new MemoryStore when zero.
+func newTestPaywall(t *testing.T, config Config) *Paywall {
+	t.Helper()
+	config.TestNet = true
+	if config.PriceInBTC == 0 {
+		config.PriceInBTC = 0.001
+	}
+	if config.PaymentTimeout == 0 {
+		config.PaymentTimeout = time.Hour
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+	return pw
+}
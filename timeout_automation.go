@@ -191,6 +191,12 @@ func (tm *TimeoutMonitor) processTimeout(paymentID string) error {
 // executeAutomaticRefund performs an automatic refund for a timed-out escrow
 // This is called when AutoRefund is enabled in the configuration
 func (tm *TimeoutMonitor) executeAutomaticRefund(paymentID string) error {
+	unlock, err := tm.em.paywall.lockPayment(paymentID, paymentLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Get payment details
 	payment, err := tm.em.paywall.Store.GetPayment(paymentID)
 	if err != nil {
@@ -303,7 +309,7 @@ func (tm *TimeoutMonitor) getBlockchainTimestamp() (time.Time, error) {
 	// Try Monero first (since it's easier - wallet RPC provides height)
 	xmrWallet, hasXMR := tm.em.paywall.HDWallets[wallet.Monero]
 	if hasXMR {
-		if mw, ok := xmrWallet.(*wallet.MoneroHDWallet); ok {
+		if mw, ok := xmrWallet.(BlockchainTimestampProvider); ok {
 			blockTime, err := mw.GetLatestBlockTime()
 			if err == nil {
 				return blockTime, nil
@@ -470,8 +476,8 @@ func (mtp *MoneroTimestampProvider) GetLatestBlockTime() (time.Time, error) {
 		return time.Time{}, fmt.Errorf("monero rpc client not configured")
 	}
 
-	// Type assert to MoneroHDWallet to use the GetLatestBlockTime method
-	if mw, ok := mtp.rpcClient.(*wallet.MoneroHDWallet); ok {
+	// Type assert to any Monero backend (wallet-rpc or light-wallet server) providing block time
+	if mw, ok := mtp.rpcClient.(BlockchainTimestampProvider); ok {
 		return mw.GetLatestBlockTime()
 	}
 
@@ -2,6 +2,7 @@
 package paywall
 
 import (
+	"context"
 	"errors"
 	"html/template"
 	"time"
@@ -19,6 +20,9 @@ type PaymentStatus string
 const (
 	// StatusPending indicates a payment has been created but not yet confirmed
 	StatusPending PaymentStatus = "pending"
+	// StatusDetected indicates a paying transaction has been seen (for example
+	// submitted by the payer) but does not have the required confirmations yet
+	StatusDetected PaymentStatus = "detected"
 	// StatusConfirmed indicates a payment has been verified on the blockchain
 	StatusConfirmed PaymentStatus = "confirmed"
 	// StatusExpired indicates the payment window has elapsed without confirmation
@@ -45,6 +49,16 @@ type Payment struct {
 	// Version is used for optimistic locking to prevent concurrent modifications
 	// This field is incremented on each update to detect race conditions
 	Version int `json:"version"`
+	// WalletIDs records which of Config.BTCWallets derived the address, per
+	// currency; empty for payments from the default wallet
+	WalletIDs map[wallet.WalletType]string `json:"wallet_ids,omitempty"`
+	// Route is the RouteConfig.Name of the route the payment was created on,
+	// empty for payments priced by the paywall's Config. A payment only grants
+	// access on routes with the same name.
+	Route string `json:"route,omitempty"`
+	// MinConfirmations is the route's RouteConfig.MinConfirmations; 0 uses
+	// Config.MinConfirmations
+	MinConfirmations int `json:"min_confirmations,omitempty"`
 
 	// Multisig fields (optional - zero values indicate single-signature payment)
 
@@ -92,6 +106,77 @@ type Payment struct {
 	// StateTransitionHistory records all state changes for this payment
 	// Provides an audit trail of escrow state transitions
 	StateTransitionHistory []StateTransitionHistory `json:"state_transition_history,omitempty"`
+
+	// Feed access (optional - for per-subscriber feed URLs)
+
+	// FeedTokenGeneration is embedded in signed feed tokens. Incrementing it
+	// (RegenerateFeedToken, RevokeFeedToken) invalidates all previously issued feed URLs.
+	FeedTokenGeneration int `json:"feed_token_generation,omitempty"`
+
+	// PaidCurrency records which currency settled the payment when it was confirmed.
+	// Empty for pending payments and for payments confirmed before it was tracked.
+	PaidCurrency wallet.WalletType `json:"paid_currency,omitempty"`
+
+	// DetectedTxID is the paying transaction submitted by the payer, set when the
+	// payment moves to StatusDetected
+	DetectedTxID string `json:"detected_txid,omitempty"`
+
+	// CreditedAPIKey is the metered API key this payment topped up, so a payment
+	// is converted into credits only once
+	CreditedAPIKey string `json:"credited_api_key,omitempty"`
+	// CreditsUsed counts the Config.PaymentCredits spent by requests made
+	// with this payment, when the default CreditStore is used
+	CreditsUsed int64 `json:"credits_used,omitempty"`
+
+	// ConfirmedAt is when the payment was confirmed, zero while unpaid
+	ConfirmedAt time.Time `json:"confirmed_at,omitempty"`
+	// AccessExpiresAt is when the access bought by a confirmed payment ends
+	// (ConfirmedAt + Config.AccessDuration). Zero means access ends at ExpiresAt,
+	// as for payments confirmed without an AccessDuration.
+	AccessExpiresAt time.Time `json:"access_expires_at,omitempty"`
+
+	// Operator annotations (optional - set with AnnotatePayment)
+
+	// Notes are free-form operator notes, oldest first
+	Notes []PaymentNote `json:"notes,omitempty"`
+	// Tags are operator labels such as "vip" or "refunded", lowercase and sorted
+	Tags []string `json:"tags,omitempty"`
+
+	// Refunds (optional - see Config.Refunds)
+
+	// RefundAddresses are the buyer's refund addresses per currency, set with
+	// RefundManager.SetRefundAddress
+	RefundAddresses map[wallet.WalletType]string `json:"refund_addresses,omitempty"`
+	// Overpayment is set when the payment received more than its price
+	Overpayment *Overpayment `json:"overpayment,omitempty"`
+
+	// Monitor bookkeeping (maintained by the blockchain monitor for unpaid payments)
+
+	// LastCheckedAt is when the monitor last checked the payment's addresses
+	LastCheckedAt time.Time `json:"last_checked_at,omitempty"`
+	// LastBalanceSeen is the balance last seen per payment address
+	LastBalanceSeen map[wallet.WalletType]float64 `json:"last_balance_seen,omitempty"`
+	// LastActivityAt is when the monitor last saw a payment address balance change
+	LastActivityAt time.Time `json:"last_activity_at,omitempty"`
+	// CheckCount is the number of monitor cycles that checked the payment
+	CheckCount int `json:"check_count,omitempty"`
+	// CheckErrors is the number of consecutive failed checks, 0 when the last check succeeded
+	CheckErrors int `json:"check_errors,omitempty"`
+}
+
+// AccessEnds returns when a confirmed payment stops granting access:
+// AccessExpiresAt when set, ExpiresAt otherwise
+func (payment *Payment) AccessEnds() time.Time {
+	if !payment.AccessExpiresAt.IsZero() {
+		return payment.AccessExpiresAt
+	}
+	return payment.ExpiresAt
+}
+
+// GrantsAccess reports whether the payment is confirmed and its access window
+// has not ended at now
+func (payment *Payment) GrantsAccess(now time.Time) bool {
+	return payment.Status == StatusConfirmed && now.Before(payment.AccessEnds())
 }
 
 // EscrowState represents the current state of an escrow transaction
@@ -150,6 +235,10 @@ type PaymentStore interface {
 	UpdatePayment(payment *Payment) error
 	// ListPendingPayments returns all payments in pending status
 	// Returns error if retrieval fails
+	//
+	// The paywall only calls it for stores that implement neither
+	// PaymentStreamer nor PaymentLister; use Paywall.ListForMonitoring or
+	// Paywall.ListPendingForDisplay, whose selection is the same for every store.
 	ListPendingPayments() ([]*Payment, error)
 
 	// Multisig operations (optional - implementations may return empty results)
@@ -166,6 +255,92 @@ type PaymentStore interface {
 	// Implementations may internally batch, but must return the full result set.
 	// Returns error if retrieval fails. Returns empty slice if no expiring escrows.
 	GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, error)
+
+	// Retention operations (see Config.RetentionPolicy)
+
+	// DeletePayment removes a payment and its address index entries.
+	// Returns nil if the payment does not exist, error if removal fails.
+	DeletePayment(id string) error
+
+	// ArchivePayments moves the payments ArchivablePayment selects for
+	// olderThan out of the store, into an archive where the store supports
+	// one (a directory, table or key prefix) and deleting them otherwise.
+	// Archived payments are no longer returned by any other method.
+	// Returns how many payments were archived, error if listing or moving fails.
+	ArchivePayments(olderThan time.Time) (int, error)
+}
+
+// PaymentLister is an optional PaymentStore extension for reporting features
+// (revenue, statistics) that need every payment regardless of status.
+// MemoryStore, FileStore, EncryptedFileStore, SQLStore and RedisStore
+// implement it.
+type PaymentLister interface {
+	// ListPayments returns all stored payments
+	// Returns error if retrieval fails
+	ListPayments() ([]*Payment, error)
+}
+
+// ContextPaymentStore is an optional PaymentStore extension whose point reads
+// and writes a context cancels. Middleware passes the visitor's request
+// context, bounded by Config.StoreTimeout, and the monitor its own, which
+// ends with Paywall.Close. Stores without it are not called once the context
+// is done, but a call in progress runs to its end.
+// SQLStore and ReplicatedStore, which hands the context to its primary,
+// implement it.
+type ContextPaymentStore interface {
+	// CreatePaymentContext is PaymentStore.CreatePayment with a context
+	CreatePaymentContext(ctx context.Context, payment *Payment) error
+	// GetPaymentContext is PaymentStore.GetPayment with a context
+	GetPaymentContext(ctx context.Context, id string) (*Payment, error)
+	// GetPaymentByAddressContext is PaymentStore.GetPaymentByAddress with a context
+	GetPaymentByAddressContext(ctx context.Context, address string) (*Payment, error)
+	// UpdatePaymentContext is PaymentStore.UpdatePayment with a context
+	UpdatePaymentContext(ctx context.Context, payment *Payment) error
+}
+
+// APIKeyStore is an optional PaymentStore extension that persists API keys
+// alongside payments. Required when Config.APIKeysEnabled is set.
+// MemoryStore, FileStore, EncryptedFileStore and SQLStore implement it.
+type APIKeyStore interface {
+	// SaveAPIKey creates or replaces an API key record
+	SaveAPIKey(key *APIKey) error
+	// GetAPIKey retrieves an API key by ID
+	// Returns nil, nil if the key does not exist
+	GetAPIKey(id string) (*APIKey, error)
+	// ListAPIKeys returns all stored API keys, including revoked ones
+	ListAPIKeys() ([]*APIKey, error)
+}
+
+// StatsStore is an optional PaymentStore extension that persists stats
+// snapshots. Required when Config.StatsInterval is set.
+// MemoryStore, FileStore, EncryptedFileStore, S3Store and SQLStore implement
+// it.
+type StatsStore interface {
+	// SaveStatsSnapshot stores a snapshot under its Time, replacing one taken
+	// at the same instant
+	SaveStatsSnapshot(snapshot *StatsSnapshot) error
+	// ListStatsSnapshots returns the snapshots taken in [from, to), oldest first
+	ListStatsSnapshots(from, to time.Time) ([]*StatsSnapshot, error)
+	// DeleteStatsSnapshotsBefore deletes the snapshots taken before t
+	DeleteStatsSnapshotsBefore(t time.Time) error
+}
+
+// PaymentOption is a currency a payment page offers beyond the ones with
+// fields of their own, see PaymentPageData.Tokens and PaymentPageData.Coins
+type PaymentOption struct {
+	// Currency is the currency code, e.g. USDC or LTC
+	Currency wallet.WalletType `json:"currency"`
+	// Address is where the payment is sent
+	Address string `json:"address"`
+	// Amount is the required amount in whole coins or tokens
+	Amount float64 `json:"amount"`
+	// PaymentURI is the payment link, see PaymentPageData.BTCPaymentURI;
+	// empty for currencies without a URI scheme
+	PaymentURI template.URL `json:"-"`
+	// QRCode is the server-rendered QR code image, see PaymentPageData.BTCQRCode
+	QRCode template.URL `json:"-"`
+	// Fiat is the estimated value of Amount, see PaymentPageData.FiatBTC
+	Fiat float64 `json:"fiat,omitempty"`
 }
 
 // PaymentPageData contains the data needed to render the payment page template
@@ -185,6 +360,102 @@ type PaymentPageData struct {
 	PaymentID string `json:"payment_id"`
 	// QrcodeJs contains the JS code for generating the QR cde
 	QrcodeJs template.JS
+	// QrcodeScriptURL is the fingerprinted URL of the QR code library, set
+	// instead of QrcodeJs when Config.AssetPath is configured
+	QrcodeScriptURL string `json:"-"`
+	// BTCQRCode is the server-rendered Bitcoin QR code image (data: URI or HandleQRCode link)
+	// of BTCPaymentURI, shown when the QR script cannot run (JavaScript disabled or blocked by CSP)
+	BTCQRCode template.URL `json:"-"`
+	// XMRQRCode is the server-rendered Monero QR code image, see BTCQRCode
+	XMRQRCode template.URL `json:"-"`
+	// BTCPaymentURI is the BIP21 bitcoin: payment link, opening the visitor's default
+	// wallet with the amount and Config.PaymentLabel filled in. The QR codes encode it.
+	BTCPaymentURI template.URL `json:"-"`
+	// XMRPaymentURI is the monero: payment link with tx_amount and recipient_name,
+	// see BTCPaymentURI
+	XMRPaymentURI template.URL `json:"-"`
+	// BTCWalletLinks are deep links into Bitcoin wallet apps for the visitor's
+	// mobile platform, empty on desktops (see Config.WalletApps)
+	BTCWalletLinks []WalletLink `json:"-"`
+	// XMRWalletLinks are deep links into Monero wallet apps, see BTCWalletLinks
+	XMRWalletLinks []WalletLink `json:"-"`
+	// LNInvoice is the BOLT11 Lightning invoice, empty unless Config.Lightning is set
+	LNInvoice string `json:"ln_invoice,omitempty"`
+	// AmountLN is the amount of LNInvoice in Bitcoin
+	AmountLN float64 `json:"amount_ln,omitempty"`
+	// AmountLNSats is AmountLN in satoshis, as Lightning wallets show it
+	AmountLNSats float64 `json:"-"`
+	// LNQRCode is the server-rendered Lightning QR code image, see BTCQRCode
+	LNQRCode template.URL `json:"-"`
+	// LNPaymentURI is the lightning: payment link, see BTCPaymentURI
+	LNPaymentURI template.URL `json:"-"`
+	// ETHAddress is the Ethereum address Ether is paid to, empty unless
+	// Config.Ethereum is set with a PriceInETH
+	ETHAddress string `json:"eth_address,omitempty"`
+	// AmountETH is the required payment amount in Ether
+	AmountETH float64 `json:"amount_eth,omitempty"`
+	// ETHQRCode is the server-rendered Ethereum QR code image, see BTCQRCode
+	ETHQRCode template.URL `json:"-"`
+	// ETHPaymentURI is the EIP-681 ethereum: payment link, see BTCPaymentURI
+	ETHPaymentURI template.URL `json:"-"`
+	// Tokens are the ERC-20 tokens the payment accepts (Config.ERC20), by symbol
+	Tokens []PaymentOption `json:"tokens,omitempty"`
+	// Coins are the other Bitcoin-derived chains the payment accepts
+	// (Config.UTXOChains)
+	Coins []PaymentOption `json:"coins,omitempty"`
+	// QRScriptUnavailable is true when the QR script could not be loaded and only
+	// the server-rendered images are available
+	QRScriptUnavailable bool `json:"-"`
+	// Detected is true once a paying transaction was seen and awaits confirmations
+	Detected bool `json:"detected,omitempty"`
+	// DetectedTxID is the detected transaction, see Payment.DetectedTxID
+	DetectedTxID string `json:"detected_txid,omitempty"`
+	// BTCTxSubmitURL is where the payer can submit their signed transaction or txid,
+	// empty unless Config.BTCTxSubmitPath is set
+	BTCTxSubmitURL string `json:"-"`
+	// RefundURL is where the payer can leave a refund address, empty unless
+	// Config.Refunds.AddressPath is set
+	RefundURL string `json:"-"`
+	// RefundCurrencies are the currencies the refund address form offers
+	RefundCurrencies []wallet.WalletType `json:"-"`
+	// RefundAddresses are the refund addresses the payer already left
+	RefundAddresses map[wallet.WalletType]string `json:"-"`
+	// FaucetURL is where the payer can request test coins, empty unless
+	// Config.TestnetFaucetURL and Config.TestnetFaucetPath are set
+	FaucetURL string `json:"-"`
+	// StatusURL is polled by the page for confirmation, empty unless Config.StatusPath is set
+	StatusURL string `json:"-"`
+	// StatusPollMillis is the polling interval for StatusURL in milliseconds
+	StatusPollMillis int64 `json:"-"`
+	// RequestMethod is the method of the request that was shown the payment page.
+	// The poller only re-issues GET requests; other methods ask the visitor to resubmit.
+	RequestMethod string `json:"-"`
+	// PageNonce is a signature over the payment ID, expiry and render time. The page
+	// sends it to StatusURL, which reports whether the page still matches the
+	// visitor's payment, so stale cached copies reload themselves.
+	PageNonce string `json:"-"`
+	// PageIssuedMillis is when the page was rendered (Unix milliseconds); pages that
+	// load long after it are treated as cached copies and reloaded
+	PageIssuedMillis int64 `json:"-"`
+	// PageMaxAgeMillis is how old the page may be when it loads, see PageIssuedMillis
+	PageMaxAgeMillis int64 `json:"-"`
+	// Maintenance is true while the paywall is in ModeReadOnly; the page shows a notice
+	Maintenance bool `json:"-"`
+	// FiatBTC is the estimated value of AmountBTC in FiatCurrency, zero unless
+	// Config.FiatEstimates is set and the oracle has a rate
+	FiatBTC float64 `json:"fiat_btc,omitempty"`
+	// FiatXMR is the estimated value of AmountXMR, see FiatBTC
+	FiatXMR float64 `json:"fiat_xmr,omitempty"`
+	// FiatLN is the estimated value of AmountLN, see FiatBTC
+	FiatLN float64 `json:"fiat_ln,omitempty"`
+	// FiatETH is the estimated value of AmountETH, see FiatBTC
+	FiatETH float64 `json:"fiat_eth,omitempty"`
+	// FiatCurrency is the ISO 4217 code of FiatBTC, FiatXMR, FiatLN, FiatETH
+	// and the Fiat of Tokens and Coins
+	FiatCurrency string `json:"fiat_currency,omitempty"`
+	// Extra holds integrator-supplied values for custom templates, set by
+	// Config.PageDataHook, e.g. {{.Extra.ReturnURL}}
+	Extra map[string]any `json:"extra,omitempty"`
 
 	// Multisig-specific fields (optional)
 
@@ -334,3 +334,27 @@ func TestPaymentStruct_WalletTypeMaps_MultipleWalletTypes(t *testing.T) {
 		t.Errorf("Expected 2 amounts in map, got %d", len(payment.Amounts))
 	}
 }
+
+// TestPayment_GrantsAccess verifies the access window falls back to ExpiresAt
+// when no AccessExpiresAt was recorded
+func TestPayment_GrantsAccess(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		payment Payment
+		want    bool
+	}{
+		{"confirmed within payment window", Payment{Status: StatusConfirmed, ExpiresAt: now.Add(time.Minute)}, true},
+		{"confirmed after payment window", Payment{Status: StatusConfirmed, ExpiresAt: now.Add(-time.Minute)}, false},
+		{"access window outlives payment window", Payment{Status: StatusConfirmed, ExpiresAt: now.Add(-time.Minute), AccessExpiresAt: now.Add(time.Hour)}, true},
+		{"access window ended", Payment{Status: StatusConfirmed, ExpiresAt: now.Add(time.Hour), AccessExpiresAt: now.Add(-time.Second)}, false},
+		{"pending", Payment{Status: StatusPending, ExpiresAt: now.Add(time.Hour), AccessExpiresAt: now.Add(time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payment.GrantsAccess(now); got != tt.want {
+				t.Errorf("GrantsAccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package paywall
+
+import (
+	"fmt"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// utxoChainTypes are the currencies Config.UTXOChains can enable
+var utxoChainTypes = []wallet.WalletType{wallet.Litecoin, wallet.Dogecoin, wallet.BitcoinCash}
+
+// UTXOChain enables a Bitcoin-derived chain, see Config.UTXOChains
+type UTXOChain struct {
+	// Type is wallet.Litecoin, wallet.Dogecoin or wallet.BitcoinCash
+	Type wallet.WalletType
+	// Price is the amount in the chain's coin required for access
+	Price float64
+	// RPC is the chain's node (litecoind, dogecoind, bitcoind-compatible
+	// Bitcoin Cash nodes), asked for received amounts and confirmations.
+	// Addresses are watched with getreceivedbyaddress, so the node's wallet
+	// must know them, as with Bitcoin.
+	RPC wallet.UTXORPCConfig
+}
+
+// validateUTXOChains checks Config.UTXOChains and UTXOSeed
+func validateUTXOChains(config Config) error {
+	if len(config.UTXOChains) == 0 {
+		return nil
+	}
+	if len(config.UTXOSeed) < 16 || len(config.UTXOSeed) > 64 {
+		return fmt.Errorf("UTXOSeed must be between 16 and 64 bytes, got %d (hint: generate 32 random bytes once and keep them secret; they control the received funds)", len(config.UTXOSeed))
+	}
+	seen := make(map[wallet.WalletType]bool)
+	for i, chain := range config.UTXOChains {
+		if _, err := wallet.UTXOChainParamsFor(chain.Type, config.TestNet); err != nil {
+			return fmt.Errorf("UTXOChains[%d]: %w (hint: use wallet.Litecoin, wallet.Dogecoin or wallet.BitcoinCash)", i, err)
+		}
+		if seen[chain.Type] {
+			return fmt.Errorf("UTXOChains[%d]: %s is listed twice", i, chain.Type)
+		}
+		seen[chain.Type] = true
+		if chain.Price <= priceDustLimits[chain.Type] {
+			return fmt.Errorf("UTXOChains[%d]: %s price %.8f is below the dust limit (minimum: %g)", i, chain.Type, chain.Price, priceDustLimits[chain.Type])
+		}
+		if chain.RPC.Host == "" {
+			return fmt.Errorf("UTXOChains[%d]: %s RPC.Host is required (hint: the node's RPC address, e.g. \"localhost:9332\" for litecoind)", i, chain.Type)
+		}
+	}
+	return nil
+}
+
+// initializeUTXOChainWallets creates the wallets of Config.UTXOChains, derived
+// from Config.UTXOSeed along m/44'/coin'/0'/0/i. Derivation continues after
+// the addresses of stored payments, so a restart does not hand out an address
+// again.
+//
+// Returns:
+//   - map[wallet.WalletType]wallet.HDWallet: The wallets
+//   - map[wallet.WalletType]float64: Their prices
+//   - error: If a wallet cannot be created
+func initializeUTXOChainWallets(config Config) (map[wallet.WalletType]wallet.HDWallet, map[wallet.WalletType]float64, error) {
+	hdWallets := make(map[wallet.WalletType]wallet.HDWallet)
+	prices := make(map[wallet.WalletType]float64)
+	for _, chain := range config.UTXOChains {
+		params, err := wallet.UTXOChainParamsFor(chain.Type, config.TestNet)
+		if err != nil {
+			return nil, nil, err
+		}
+		rpc := chain.RPC
+		utxoWallet, err := wallet.NewUTXOHDWallet(config.UTXOSeed, params, &rpc, config.MinConfirmations)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create %s wallet: %w", chain.Type, err)
+		}
+		hdWallets[chain.Type] = utxoWallet
+		prices[chain.Type] = chain.Price
+	}
+	if len(hdWallets) > 0 {
+		restoreAddressIndexes(config, "UTXO chain", hdWallets)
+	}
+	return hdWallets, prices, nil
+}
+
+// coinPaymentOptions lists the Config.UTXOChains currencies a payment
+// accepts, with payment URIs naming label as the recipient
+func coinPaymentOptions(payment *Payment, label string) []PaymentOption {
+	var coins []PaymentOption
+	for _, currency := range utxoChainTypes {
+		address := payment.Addresses[currency]
+		if address == "" {
+			continue
+		}
+		amount := payment.Amounts[currency]
+		coins = append(coins, PaymentOption{
+			Currency:   currency,
+			Address:    address,
+			Amount:     amount,
+			PaymentURI: paymentLink(currency, address, amount, label),
+			QRCode:     qrCodeDataURI(currency, address, amount, label),
+		})
+	}
+	return coins
+}
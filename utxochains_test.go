@@ -0,0 +1,104 @@
+package paywall
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// utxoChainsTestConfig enables Litecoin and Bitcoin Cash payments
+func utxoChainsTestConfig(store PaymentStore) Config {
+	return Config{
+		Store:    store,
+		Logger:   NewStructuredLogger(io.Discard, LogLevelError, true),
+		UTXOSeed: bytes.Repeat([]byte{9}, 32),
+		UTXOChains: []UTXOChain{
+			{Type: wallet.Litecoin, Price: 0.05, RPC: wallet.UTXORPCConfig{Host: "127.0.0.1:19332", DisableTLS: true}},
+			{Type: wallet.BitcoinCash, Price: 0.002, RPC: wallet.UTXORPCConfig{Host: "127.0.0.1:18332", DisableTLS: true}},
+		},
+	}
+}
+
+func TestUTXOChains_PaymentConfirms(t *testing.T) {
+	pw := newTestPaywall(t, utxoChainsTestConfig(NewMemoryStore()))
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	ltc, bch := payment.Addresses[wallet.Litecoin], payment.Addresses[wallet.BitcoinCash]
+	if valid, network := wallet.IsLitecoinAddress(ltc); !valid || network != "testnet" {
+		t.Errorf("Litecoin address %q is not a testnet address", ltc)
+	}
+	if !strings.HasPrefix(bch, "bchtest:") {
+		t.Errorf("Bitcoin Cash address %q is not a testnet cash address", bch)
+	}
+	if payment.Amounts[wallet.Litecoin] != 0.05 || payment.Addresses[wallet.Dogecoin] != "" {
+		t.Errorf("payment = %v %v, want 0.05 LTC and no DOGE", payment.Addresses, payment.Amounts)
+	}
+
+	rec := httptest.NewRecorder()
+	pw.renderPaymentPageFor(rec, httptest.NewRequest(http.MethodGet, "/", nil), payment)
+	for _, want := range []string{"litecoin:" + ltc + "?amount=0.05", bch + "?amount=0.002", "Payment Option(Choose only one) - LTC"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("payment page missing %q", want)
+		}
+	}
+
+	monitor := &CryptoChainMonitor{paywall: pw, client: map[wallet.WalletType]CryptoClient{
+		wallet.Bitcoin:     &mockCryptoClient{},
+		wallet.Litecoin:    &mockCryptoClient{balance: 0.05},
+		wallet.BitcoinCash: &mockCryptoClient{},
+	}}
+	monitor.checkPayment(payment)
+	stored, _ := pw.Store.GetPayment(payment.ID)
+	if stored.Status != StatusConfirmed || stored.PaidCurrency != wallet.Litecoin {
+		t.Errorf("status = %s paid in %q, want confirmed in LTC", stored.Status, stored.PaidCurrency)
+	}
+}
+
+func TestUTXOChains_RestartSkipsUsedAddresses(t *testing.T) {
+	store := NewMemoryStore()
+	first, err := newTestPaywall(t, utxoChainsTestConfig(store)).CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	second, err := newTestPaywall(t, utxoChainsTestConfig(store)).CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() after restart error = %v", err)
+	}
+	if first.Addresses[wallet.Litecoin] == second.Addresses[wallet.Litecoin] {
+		t.Errorf("Litecoin address %s handed out again after a restart", first.Addresses[wallet.Litecoin])
+	}
+}
+
+func TestUTXOChains_Config(t *testing.T) {
+	seed := bytes.Repeat([]byte{9}, 32)
+	rpc := wallet.UTXORPCConfig{Host: "127.0.0.1:9332"}
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"no seed", Config{UTXOChains: []UTXOChain{{Type: wallet.Litecoin, Price: 0.05, RPC: rpc}}}, "UTXOSeed"},
+		{"unknown chain", Config{UTXOSeed: seed, UTXOChains: []UTXOChain{{Type: wallet.Monero, Price: 0.05, RPC: rpc}}}, "no UTXO chain parameters"},
+		{"listed twice", Config{UTXOSeed: seed, UTXOChains: []UTXOChain{{Type: wallet.Dogecoin, Price: 50, RPC: rpc}, {Type: wallet.Dogecoin, Price: 60, RPC: rpc}}}, "listed twice"},
+		{"dust price", Config{UTXOSeed: seed, UTXOChains: []UTXOChain{{Type: wallet.Litecoin, Price: 0.00001, RPC: rpc}}}, "dust limit"},
+		{"no node", Config{UTXOSeed: seed, UTXOChains: []UTXOChain{{Type: wallet.Litecoin, Price: 0.05}}}, "RPC.Host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.PaymentTimeout = time.Hour
+			tt.config.Store = NewMemoryStore()
+			_, err := NewPaywall(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewPaywall() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,13 +3,28 @@ package paywall
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/opd-ai/paywall/wallet"
 )
 
+const (
+	// monitorInterval is how often the monitor checks unpaid payments
+	monitorInterval = 10 * time.Second
+	// expiryPriorityWindow is how close to expiry a payment must be for the
+	// monitor to check it ahead of all others
+	expiryPriorityWindow = 2 * time.Minute
+	// finalCheckLead is how long before expiry a payment gets its final check
+	// when the next regular cycle would come too late
+	finalCheckLead = 2 * time.Second
+	// defaultWatchDecayMaxInterval is the default Config.WatchDecayMaxInterval
+	defaultWatchDecayMaxInterval = 5 * monitorInterval
+)
+
 // BlockchainMonitor manages periodic verification of Bitcoin payments
 // It polls the blockchain for payment confirmations and updates payment status
 // Related types: Paywall, BitcoinClient, Payment
@@ -18,7 +33,22 @@ type CryptoChainMonitor struct {
 	client  map[wallet.WalletType]CryptoClient
 	btcMux  sync.Mutex
 	xmrMux  sync.Mutex
+	lnMux   sync.Mutex
+	ethMux  sync.Mutex
+	utxoMux sync.Mutex
 	gmux    sync.Mutex
+	// ctx is the context Start was called with; it cancels chain and store
+	// calls in progress
+	ctx context.Context
+
+	// finalChecks holds the IDs of payments with a scheduled final check
+	finalChecks   map[string]bool
+	finalChecksMu sync.Mutex
+
+	// shadowReported holds the decision last reported per payment in
+	// Config.MonitorShadow mode
+	shadowReported map[string]TransitionEvent
+	shadowMu       sync.Mutex
 }
 
 // BitcoinClient defines the interface for interacting with the Bitcoin network
@@ -33,6 +63,19 @@ type CryptoClient interface {
 	GetAddressBalance(address string) (float64, error)
 }
 
+// ContextCryptoClient is a CryptoClient whose balance queries a context
+// cancels. The monitor passes its context, which ends with Paywall.Close, so
+// a hung node or explorer does not hold up shutdown. *wallet.EsploraClient,
+// *wallet.MoneroLWSWallet, *wallet.ETHHDWallet and *wallet.LightningWallet
+// implement it; the node RPC clients of the other wallets cannot be
+// cancelled, and are not asked once the context is done.
+type ContextCryptoClient interface {
+	CryptoClient
+	// GetAddressBalanceContext is GetAddressBalance, abandoned with ctx's
+	// error once ctx is done
+	GetAddressBalanceContext(ctx context.Context, address string) (float64, error)
+}
+
 // Start begins monitoring the blockchain for payment confirmations
 // It runs in a separate goroutine and checks pending payments every minute
 // Parameters:
@@ -41,17 +84,30 @@ type CryptoClient interface {
 // The monitor will run until the context is cancelled
 // Related methods: checkPendingPayments
 func (m *CryptoChainMonitor) Start(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
+	m.ctx = ctx
+	stop := m.paywall.workers.stopping()
+	if !m.paywall.workers.start() {
+		return
+	}
+	ticker := time.NewTicker(monitorInterval)
 	consecutiveFailures := 0
 	maxBackoffInterval := 5 * time.Minute
 
 	go func() {
+		defer m.paywall.workers.done()
 		defer ticker.Stop()
+		// Store what the last run decided but could not store
+		m.gmux.Lock()
+		m.replayWAL()
+		m.gmux.Unlock()
 		for {
 			select {
 			case <-ctx.Done():
 				ticker.Stop()
 				return
+			case <-stop:
+				// Paywall.CloseContext waits for the cycle in progress
+				return
 			case <-ticker.C:
 				if err := m.checkPendingPayments(); err != nil {
 					consecutiveFailures++
@@ -70,7 +126,7 @@ func (m *CryptoChainMonitor) Start(ctx context.Context) {
 					// Reset on success
 					if consecutiveFailures > 0 {
 						consecutiveFailures = 0
-						ticker.Reset(10 * time.Second)
+						ticker.Reset(monitorInterval)
 						m.paywall.logger.log(LogEntry{
 							Level:   LogLevelInfo,
 							Event:   "payment_monitoring_recovered",
@@ -83,8 +139,21 @@ func (m *CryptoChainMonitor) Start(ctx context.Context) {
 	}()
 }
 
-// checkPendingPayments verifies all pending payments against the blockchain
-// For each pending payment, it:
+// context returns the context of the monitor's chain and store calls: the one
+// passed to Start, or the paywall's for checks run without Start
+func (m *CryptoChainMonitor) context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	if m.paywall.ctx != nil {
+		return m.paywall.ctx
+	}
+	return context.Background()
+}
+
+// checkPendingPayments verifies unpaid payments against the blockchain, in the
+// order and selection of ListForMonitoring
+// For each payment due for a check, it:
 // 1. Checks if the required amount has been received at the payment address
 // 2. Verifies the number of confirmations meets the minimum requirement
 // 3. Updates payment status to confirmed when requirements are met
@@ -97,31 +166,33 @@ func (m *CryptoChainMonitor) Start(ctx context.Context) {
 func (m *CryptoChainMonitor) checkPendingPayments() error {
 	m.gmux.Lock()
 	defer m.gmux.Unlock()
-	payments, err := m.paywall.Store.ListPendingPayments()
+	m.replayWAL()
+	ctx := m.context()
+	now := time.Now()
+	payments, err := m.paywall.monitoredPayments(ctx, now)
 	if err != nil {
-		return fmt.Errorf("failed to list pending payments: %w", err)
+		return err
 	}
+	m.paywall.metrics.setMonitorPayments(len(payments))
 
 	hasErrors := false
 	for _, payment := range payments {
-		if err := m.CheckBTCPayments(payment); err != nil {
-			m.paywall.logger.log(LogEntry{
-				Level:     LogLevelError,
-				Event:     "check_btc_payments_error",
-				Message:   fmt.Sprintf("CheckBTCPayments error: %v", err),
-				PaymentID: payment.ID,
-			})
-			hasErrors = true
+		if ctx.Err() != nil {
+			// Shutting down: the remaining payments wait for the next start
+			return ctx.Err()
 		}
-		if err := m.CheckXMRPayments(payment); err != nil {
-			m.paywall.logger.log(LogEntry{
-				Level:     LogLevelError,
-				Event:     "check_xmr_payments_error",
-				Message:   fmt.Sprintf("CheckXMRPayments error: %v", err),
-				PaymentID: payment.ID,
-			})
-			hasErrors = true
+		if m.paywall.workers.stopped() {
+			return nil
+		}
+		if !m.paywall.dueForCheck(payment, now) {
+			continue
 		}
+		failed := m.checkPaymentLocked(payment)
+		hasErrors = hasErrors || failed
+	}
+	m.scheduleFinalChecks(payments, time.Now())
+	if m.paywall.monitorShadow {
+		m.pruneShadowReports(payments)
 	}
 
 	if hasErrors {
@@ -130,18 +201,226 @@ func (m *CryptoChainMonitor) checkPendingPayments() error {
 	return nil
 }
 
-// checkWalletPayment is a helper that checks payment balance for a specific wallet type.
-// Updates payment status to confirmed if balance meets requirement.
+// checkPayment checks every currency of payment and records the check
+//
+// Returns:
+//   - bool: true if any currency check failed
+func (m *CryptoChainMonitor) checkPayment(payment *Payment) bool {
+	start := time.Now()
+	defer func() { m.paywall.metrics.observeMonitorCheck(time.Since(start)) }()
+	awaiting := payment.Status == StatusPending || payment.Status == StatusDetected
+	confirmations := payment.Confirmations
+	failed := false
+	if err := m.CheckBTCPayments(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "check_btc_payments_error",
+			Message:   fmt.Sprintf("CheckBTCPayments error: %v", err),
+			PaymentID: payment.ID,
+		})
+		failed = true
+	}
+	if err := m.CheckXMRPayments(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "check_xmr_payments_error",
+			Message:   fmt.Sprintf("CheckXMRPayments error: %v", err),
+			PaymentID: payment.ID,
+		})
+		failed = true
+	}
+	if err := m.CheckLightningPayments(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "check_ln_payments_error",
+			Message:   fmt.Sprintf("CheckLightningPayments error: %v", err),
+			PaymentID: payment.ID,
+		})
+		failed = true
+	}
+	if err := m.CheckUTXOChainPayments(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "check_utxo_payments_error",
+			Message:   fmt.Sprintf("CheckUTXOChainPayments error: %v", err),
+			PaymentID: payment.ID,
+		})
+		failed = true
+	}
+	if err := m.CheckEthereumPayments(payment); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "check_eth_payments_error",
+			Message:   fmt.Sprintf("CheckEthereumPayments error: %v", err),
+			PaymentID: payment.ID,
+		})
+		failed = true
+	}
+	if awaiting && (payment.Status == StatusPending || payment.Status == StatusDetected) && !time.Now().Before(payment.ExpiresAt) {
+		m.expirePayment(payment)
+	}
+	m.paywall.stats.recordCheck(failed)
+	if awaiting && !m.paywall.monitorShadow {
+		m.recordCheck(payment, failed)
+		if payment.Status != StatusConfirmed && payment.Confirmations != confirmations {
+			// Status streams show the progress
+			m.paywall.statusWatchers.notify(payment.ID)
+		}
+	}
+	return failed
+}
+
+// expirePayment marks an unpaid payment past its ExpiresAt as StatusExpired,
+// unless a payment hook vetoes it. Expired payments are still checked, so a
+// late payment confirms them.
+func (m *CryptoChainMonitor) expirePayment(payment *Payment) {
+	expired := &monitorWALEntry{
+		PaymentID: payment.ID,
+		Event:     TransitionExpire,
+		From:      payment.Status,
+		To:        StatusExpired,
+		At:        time.Now(),
+	}
+	if m.paywall.monitorShadow {
+		m.shadowTransition(expired.transition(payment))
+		return
+	}
+	// Store failures are logged by retryStoreWrite; the next cycle expires
+	// the payment again
+	if err := m.decide(expired, payment); err == nil {
+		m.announceExpired(payment)
+	}
+}
+
+// dueForCheck reports whether the monitor should check payment in the cycle at
+// now. With Config.WatchDecayAfter set, payments that have seen no funds for a
+// long time are checked at growing intervals: every cycle until WatchDecayAfter,
+// then every 2, 4, 8... cycles' worth of time (one doubling per further
+// WatchDecayAfter), capped at WatchDecayMaxInterval.
+func (p *Paywall) dueForCheck(payment *Payment, now time.Time) bool {
+	if p.watchDecayAfter <= 0 || payment.Status != StatusPending || !payment.LastActivityAt.IsZero() || payment.LastCheckedAt.IsZero() {
+		return true
+	}
+	if payment.ExpiresAt.Before(now.Add(expiryPriorityWindow)) {
+		return true
+	}
+	idle := now.Sub(payment.CreatedAt)
+	if idle < p.watchDecayAfter {
+		return true
+	}
+
+	interval := monitorInterval
+	for steps := idle / p.watchDecayAfter; steps > 0 && interval < p.watchDecayMaxInterval; steps-- {
+		interval *= 2
+	}
+	if interval > p.watchDecayMaxInterval {
+		interval = p.watchDecayMaxInterval
+	}
+	// Cycles drift by a few milliseconds; allow half a cycle of slack
+	return now.Sub(payment.LastCheckedAt) >= interval-monitorInterval/2
+}
+
+// scheduleFinalChecks gives unpaid payments that expire before the next cycle
+// one more check finalCheckLead before they expire, so a payment that arrives
+// at the last minute is not expired for want of a check
+func (m *CryptoChainMonitor) scheduleFinalChecks(payments []*Payment, now time.Time) {
+	for _, payment := range payments {
+		if payment.Status != StatusPending && payment.Status != StatusDetected {
+			continue
+		}
+		at := payment.ExpiresAt.Add(-finalCheckLead)
+		if !payment.ExpiresAt.After(now) || !at.Before(now.Add(monitorInterval)) {
+			continue
+		}
+
+		m.finalChecksMu.Lock()
+		if m.finalChecks == nil {
+			m.finalChecks = make(map[string]bool)
+		}
+		scheduled := m.finalChecks[payment.ID]
+		m.finalChecks[payment.ID] = true
+		m.finalChecksMu.Unlock()
+		if scheduled {
+			continue
+		}
+
+		paymentID := payment.ID
+		time.AfterFunc(at.Sub(now), func() {
+			if !m.paywall.workers.start() {
+				return
+			}
+			defer m.paywall.workers.done()
+			m.finalCheck(paymentID)
+		})
+	}
+}
+
+// finalCheck checks a payment about to expire, unless it was paid in the
+// meantime or the paywall is shutting down
+func (m *CryptoChainMonitor) finalCheck(paymentID string) {
+	defer func() {
+		m.finalChecksMu.Lock()
+		delete(m.finalChecks, paymentID)
+		m.finalChecksMu.Unlock()
+	}()
+	ctx := m.context()
+	if ctx.Err() != nil {
+		return
+	}
+	payment, err := getPaymentContext(ctx, m.paywall.Store, paymentID)
+	if err != nil || payment == nil || (payment.Status != StatusPending && payment.Status != StatusDetected) {
+		return
+	}
+	m.paywall.logger.log(LogEntry{
+		Level:     LogLevelDebug,
+		Event:     "payment_final_check",
+		Message:   "Checking payment right before it expires",
+		PaymentID: paymentID,
+	})
+	m.checkPaymentLocked(payment)
+}
+
+// checkPaymentLocked checks payment while holding its lock. With a
+// PaymentLocker the payment is read again under the lock, so changes another
+// process made since it was listed are not overwritten; payments another
+// process is working on are skipped until the next cycle. A shadow monitor
+// writes nothing and takes no lock, so it never holds up the real one.
+//
+// Returns:
+//   - bool: true if any currency check failed
+func (m *CryptoChainMonitor) checkPaymentLocked(payment *Payment) bool {
+	if m.paywall.monitorShadow {
+		return m.checkPayment(payment)
+	}
+	unlock, err := m.paywall.lockPayment(payment.ID, monitorLockWait)
+	if err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelDebug,
+			Event:     "payment_check_skipped",
+			Message:   fmt.Sprintf("Skipping payment check: %v", err),
+			PaymentID: payment.ID,
+		})
+		return false
+	}
+	defer unlock()
+
+	if m.paywall.locker != nil {
+		fresh, err := getPaymentContext(m.context(), m.paywall.Store, payment.ID)
+		if err != nil || fresh == nil {
+			return err != nil
+		}
+		payment = fresh
+	}
+	return m.checkPayment(payment)
+}
+
+// checkWalletPayment is a helper that checks payment for a specific wallet type.
+// Updates payment status to confirmed if the currency's ConfirmationStrategy reports it paid.
 // For multisig payments, verifies script hash matches expected redeem script.
 func (m *CryptoChainMonitor) checkWalletPayment(payment *Payment, walletType wallet.WalletType, mux *sync.Mutex) error {
 	mux.Lock()
 	defer mux.Unlock()
 
-	client, exists := m.client[walletType]
-	if !exists {
-		return fmt.Errorf("%s client not found", walletType)
-	}
-
 	// Get address for this wallet type
 	address, hasAddress := payment.Addresses[walletType]
 	if !hasAddress {
@@ -181,15 +460,39 @@ func (m *CryptoChainMonitor) checkWalletPayment(payment *Payment, walletType wal
 		}
 	}
 
-	balance, err := client.GetAddressBalance(address)
+	requiredAmount := payment.Amounts[walletType]
+	result, err := m.paywall.confirmationStrategy(walletType).Confirm(ConfirmationCheck{
+		Payment:          payment,
+		Currency:         walletType,
+		Address:          address,
+		Required:         requiredAmount,
+		MinConfirmations: m.paywall.requiredConfirmations(payment),
+		Client:           m.client[walletType],
+		Context:          m.context(),
+	})
+	m.paywall.metrics.observeChainCheck(walletType, err)
 	if err != nil {
 		return err
 	}
+	balance := result.Received
+	recordBalance(payment, walletType, balance)
+	if !result.Confirmed && payment.Status != StatusConfirmed && result.Confirmations > 0 && result.Confirmations != payment.Confirmations {
+		// Progress towards the required confirmations, stored by recordCheck
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelDebug,
+			Event:     "payment_confirmations",
+			Message:   fmt.Sprintf("Payment has %d of %d confirmations", result.Confirmations, m.paywall.requiredConfirmations(payment)),
+			PaymentID: payment.ID,
+			Currency:  walletType,
+		})
+		payment.Confirmations = result.Confirmations
+	}
 
-	requiredAmount := payment.Amounts[walletType]
-	if balance >= requiredAmount {
-		// Payment confirmed by balance
-		// Confirmations are checked inline during GetAddressBalance
+	if result.Confirmed && payment.Status != StatusConfirmed {
+		confirmations := result.Confirmations
+		if confirmations <= 0 {
+			confirmations = m.paywall.requiredConfirmations(payment)
+		}
 		if payment.MultisigEnabled {
 			m.paywall.logger.LogPaymentConfirmed(payment.ID, payment.Confirmations, "")
 			m.paywall.logger.log(LogEntry{
@@ -201,29 +504,219 @@ func (m *CryptoChainMonitor) checkWalletPayment(payment *Payment, walletType wal
 				Currency:  walletType,
 			})
 		}
-		payment.Status = StatusConfirmed
-		payment.Confirmations = m.paywall.minConfirmations
-		m.paywall.Store.UpdatePayment(payment)
-		if m.paywall.logger != nil {
-			m.paywall.logger.LogPaymentConfirmed(payment.ID, payment.Confirmations, "")
+		confirmed := &monitorWALEntry{
+			PaymentID:     payment.ID,
+			Event:         TransitionConfirm,
+			From:          payment.Status,
+			To:            StatusConfirmed,
+			Currency:      walletType,
+			Amount:        balance,
+			TxID:          result.TxID,
+			Confirmations: confirmations,
+			At:            time.Now(),
 		}
-		// Dispatch webhook for payment confirmation
-		if m.paywall.webhookDispatcher != nil {
-			m.paywall.webhookDispatcher.Dispatch(WebhookPayload{
-				Event:     EventPaymentConfirmed,
-				PaymentID: payment.ID,
-				Timestamp: time.Now(),
-				Data: map[string]interface{}{
-					"confirmations": payment.Confirmations,
-					"amount":        balance,
-					"currency":      walletType,
-				},
-			})
+		if m.paywall.monitorShadow {
+			m.shadowTransition(confirmed.transition(payment))
+			return nil
+		}
+		err := m.decide(confirmed, payment)
+		if errors.Is(err, ErrTransitionVetoed) {
+			// The hooks are asked again on the next check
+			return nil
+		}
+		if err != nil {
+			// With a monitor log the confirmation is replayed next cycle;
+			// without one the next check detects it again
+			return fmt.Errorf("store confirmation of payment %s: %w", payment.ID, err)
+		}
+		m.announceConfirmed(payment, balance, walletType)
+		if m.paywall.refunds != nil {
+			m.paywall.refunds.checkOverpayment(payment, walletType, balance)
 		}
 	}
 	return nil
 }
 
+// decide applies a transition the monitor decided, recording it in the
+// monitor log (Config.MonitorWALPath) first so that it survives a crash or a
+// store failure before it is stored
+//
+// Parameters:
+//   - entry: The change, applied to payment
+//   - payment: Payment as last read from the store
+//
+// Returns:
+//   - error: ErrTransitionVetoed if a hook vetoed the change, or the store error
+func (m *CryptoChainMonitor) decide(entry *monitorWALEntry, payment *Payment) error {
+	if err := m.paywall.monitorWAL.begin(entry); err != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelError,
+			Event:     "monitor_wal_write_failed",
+			Message:   fmt.Sprintf("Failed to log %s before storing it, a crash now loses it: %v", entry.Event, err),
+			PaymentID: payment.ID,
+		})
+	}
+	err := m.applyTransition(entry.transition(payment), entry)
+	settled := err == nil || errors.Is(err, ErrTransitionVetoed)
+	if endErr := m.paywall.monitorWAL.end(entry, settled); endErr != nil {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "monitor_wal_write_failed",
+			Message:   fmt.Sprintf("Failed to mark %s done: %v", entry.Event, endErr),
+			PaymentID: payment.ID,
+		})
+	}
+	return err
+}
+
+// applyTransition passes a confirm or expire transition through the payment
+// hooks and writes it to the store, retrying failed writes (see
+// retryStoreWrite). When the payment was changed concurrently, the change is
+// applied again to the stored version as long as that is still in the status
+// the change starts from. If the store fails, the payment is left as it was.
+func (m *CryptoChainMonitor) applyTransition(t *PaymentTransition, entry *monitorWALEntry) error {
+	return m.paywall.transition(t, func(t *PaymentTransition) error {
+		return m.paywall.retryStoreWrite(string(t.Event), t.Payment.ID, func() error {
+			previous := *t.Payment
+			if t.Event == TransitionConfirm {
+				m.paywall.markConfirmed(t.Payment, entry.At)
+				t.Payment.Confirmations = entry.Confirmations
+				t.Payment.PaidCurrency = entry.Currency
+			} else {
+				t.Payment.Status = t.To
+			}
+			err := updatePaymentContext(m.context(), m.paywall.Store, t.Payment)
+			if err == nil {
+				return nil
+			}
+			*t.Payment = previous
+			if errors.Is(err, ErrVersionConflict) {
+				return m.refreshForRetry(t, err)
+			}
+			return err
+		})
+	})
+}
+
+// refreshForRetry reloads the payment of t after the version conflict err,
+// so the transition can be applied to the stored version
+//
+// Returns:
+//   - error: err, marked permanent when the stored payment has left t.From
+//     and the transition no longer applies
+func (m *CryptoChainMonitor) refreshForRetry(t *PaymentTransition, err error) error {
+	fresh, getErr := getPaymentContext(m.context(), m.paywall.Store, t.Payment.ID)
+	if getErr != nil {
+		// Retried like the read error; the next write conflicts again and
+		// reloads the payment
+		return fmt.Errorf("reload payment after version conflict: %w", getErr)
+	}
+	if fresh == nil || fresh.Status != t.From {
+		return permanentStoreError{err}
+	}
+	*t.Payment = *fresh
+	return err
+}
+
+// announceConfirmed logs and publishes a stored confirmation
+func (m *CryptoChainMonitor) announceConfirmed(payment *Payment, amount float64, currency wallet.WalletType) {
+	if m.paywall.logger != nil {
+		m.paywall.logger.LogPaymentConfirmed(payment.ID, payment.Confirmations, "")
+	}
+	m.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentConfirmed,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"confirmations": payment.Confirmations,
+			"amount":        amount,
+			"currency":      currency,
+		},
+	})
+}
+
+// announceExpired publishes a stored expiry
+func (m *CryptoChainMonitor) announceExpired(payment *Payment) {
+	m.paywall.dispatchEvent(WebhookPayload{
+		Event:     EventPaymentExpired,
+		PaymentID: payment.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"amounts":  payment.Amounts,
+			"received": payment.LastBalanceSeen,
+		},
+	})
+}
+
+// markConfirmed moves payment to StatusConfirmed at now and opens its access
+// window when Config.AccessDuration is set
+func (p *Paywall) markConfirmed(payment *Payment, now time.Time) {
+	payment.Status = StatusConfirmed
+	payment.ConfirmedAt = now
+	if p.accessDuration > 0 {
+		payment.AccessExpiresAt = now.Add(p.accessDuration)
+	}
+}
+
+// recordBalance notes the balance seen for a payment address, marking the
+// payment active when the balance changed
+func recordBalance(payment *Payment, walletType wallet.WalletType, balance float64) {
+	if payment.LastBalanceSeen == nil {
+		payment.LastBalanceSeen = make(map[wallet.WalletType]float64)
+	}
+	if previous, seen := payment.LastBalanceSeen[walletType]; balance != previous || (!seen && balance > 0) {
+		payment.LastActivityAt = time.Now()
+	}
+	payment.LastBalanceSeen[walletType] = balance
+}
+
+// recordCheck persists the monitor bookkeeping of a checked payment.
+// It is best effort: a concurrent update of the payment wins, and the
+// bookkeeping is recorded again on the next cycle.
+func (m *CryptoChainMonitor) recordCheck(payment *Payment, failed bool) {
+	payment.LastCheckedAt = time.Now()
+	payment.CheckCount++
+	if failed {
+		payment.CheckErrors++
+	} else {
+		payment.CheckErrors = 0
+	}
+	if err := updatePaymentContext(m.context(), m.paywall.Store, payment); err != nil && !errors.Is(err, ErrVersionConflict) {
+		m.paywall.logger.log(LogEntry{
+			Level:     LogLevelWarn,
+			Event:     "monitor_bookkeeping_failed",
+			Message:   fmt.Sprintf("Failed to record payment check: %v", err),
+			PaymentID: payment.ID,
+		})
+	}
+}
+
+// sortByMonitorPriority orders payments so that those expiring within
+// expiryPriorityWindow come first (nearest expiry first), then never-checked
+// payments, then recently active ones (a balance change was seen), then the
+// ones checked longest ago. A slow or rate-limited cycle thus spends its budget
+// where a payment is about to be lost or is most likely to arrive.
+func sortByMonitorPriority(payments []*Payment, now time.Time) {
+	expiringSoon := func(payment *Payment) bool {
+		return payment.ExpiresAt.After(now) && payment.ExpiresAt.Before(now.Add(expiryPriorityWindow))
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		a, b := payments[i], payments[j]
+		if soonA, soonB := expiringSoon(a), expiringSoon(b); soonA != soonB {
+			return soonA
+		} else if soonA && !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		if a.LastCheckedAt.IsZero() != b.LastCheckedAt.IsZero() {
+			return a.LastCheckedAt.IsZero()
+		}
+		if !a.LastActivityAt.Equal(b.LastActivityAt) {
+			return a.LastActivityAt.After(b.LastActivityAt)
+		}
+		return a.LastCheckedAt.Before(b.LastCheckedAt)
+	})
+}
+
 func (m *CryptoChainMonitor) CheckXMRPayments(payment *Payment) error {
 	return m.checkWalletPayment(payment, wallet.Monero, &m.xmrMux)
 }
@@ -232,8 +725,40 @@ func (m *CryptoChainMonitor) CheckBTCPayments(payment *Payment) error {
 	return m.checkWalletPayment(payment, wallet.Bitcoin, &m.btcMux)
 }
 
-// Close stops the blockchain monitor
-// It cancels the context and waits for the monitor goroutine to exit
+// CheckLightningPayments confirms payment once its Lightning invoice is settled
+func (m *CryptoChainMonitor) CheckLightningPayments(payment *Payment) error {
+	return m.checkWalletPayment(payment, wallet.Lightning, &m.lnMux)
+}
+
+// CheckUTXOChainPayments confirms payment once one of its Config.UTXOChains
+// addresses (Litecoin, Dogecoin, Bitcoin Cash) received the price
+func (m *CryptoChainMonitor) CheckUTXOChainPayments(payment *Payment) error {
+	var errs []error
+	for _, walletType := range utxoChainTypes {
+		if err := m.checkWalletPayment(payment, walletType, &m.utxoMux); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", walletType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckEthereumPayments confirms payment once its Ether or one of its ERC-20
+// token addresses holds the price
+func (m *CryptoChainMonitor) CheckEthereumPayments(payment *Payment) error {
+	var errs []error
+	for walletType, client := range m.client {
+		if _, ok := client.(*wallet.ETHHDWallet); !ok {
+			continue
+		}
+		if err := m.checkWalletPayment(payment, walletType, &m.ethMux); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", walletType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the blockchain monitor by cancelling the paywall's context,
+// abandoning the check in progress. Paywall.CloseContext waits for it instead.
 func (m *CryptoChainMonitor) Close() {
 	m.paywall.cancel()
 }
@@ -3,6 +3,7 @@ package paywall
 import (
 	"context"
 	"errors"
+	"io"
 	"sync"
 	"testing"
 	"time"
@@ -184,8 +185,8 @@ func TestCheckWalletPayment_GetBalanceError(t *testing.T) {
 	}
 }
 
-// TestCheckWalletPayment_UpdatePaymentError tests that errors from UpdatePayment
-// are silently ignored (as per current implementation)
+// TestCheckWalletPayment_UpdatePaymentError tests that a confirmation the store
+// fails to take is reported instead of being treated as stored
 func TestCheckWalletPayment_UpdatePaymentError(t *testing.T) {
 	mockStore := &mockStore{
 		updateError: errors.New("storage error"),
@@ -213,12 +214,13 @@ func TestCheckWalletPayment_UpdatePaymentError(t *testing.T) {
 
 	var mux sync.Mutex
 	err := monitor.checkWalletPayment(payment, wallet.Bitcoin, &mux)
-	// Current implementation doesn't check UpdatePayment error
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
+	// The failed confirmation is reported, and the payment left pending so the
+	// next check (or the monitor log) confirms it again
+	if err == nil {
+		t.Fatal("Expected the storage error")
 	}
-	if payment.Status != StatusConfirmed {
-		t.Errorf("Expected status to be confirmed, got %s", payment.Status)
+	if payment.Status != StatusPending || !payment.ConfirmedAt.IsZero() {
+		t.Errorf("Expected status to stay pending, got %s", payment.Status)
 	}
 }
 
@@ -257,6 +259,14 @@ func (m *mockStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Payment, er
 	return nil, nil
 }
 
+func (m *mockStore) DeletePayment(id string) error {
+	return nil
+}
+
+func (m *mockStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return 0, nil
+}
+
 func (m *mockStore) Close() error {
 	return nil
 }
@@ -306,6 +316,208 @@ func (m *mockFailingStore) GetEscrowsExpiringBefore(deadline time.Time) ([]*Paym
 	return nil, errors.New("mock store error")
 }
 
+func (m *mockFailingStore) DeletePayment(id string) error {
+	return errors.New("mock store error")
+}
+
+func (m *mockFailingStore) ArchivePayments(olderThan time.Time) (int, error) {
+	return 0, errors.New("mock store error")
+}
+
 func (m *mockFailingStore) Close() error {
 	return nil
 }
+
+// TestCheckPendingPayments_Bookkeeping tests that the monitor records when and
+// what it checked for unpaid payments
+func TestCheckPendingPayments_Bookkeeping(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:            store,
+		minConfirmations: 1,
+		logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	client := &mockCryptoClient{balance: 0.0004}
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: client,
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+	payment := &Payment{
+		ID:        "bookkeeping",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		ExpiresAt: time.Now().Add(time.Hour),
+		Status:    StatusPending,
+	}
+	store.CreatePayment(payment)
+
+	if err := monitor.checkPendingPayments(); err != nil {
+		t.Fatalf("checkPendingPayments() error = %v", err)
+	}
+	first, _ := store.GetPayment(payment.ID)
+	if first.CheckCount != 1 || first.LastCheckedAt.IsZero() || first.CheckErrors != 0 {
+		t.Errorf("after first check: count = %d, last checked = %v, errors = %d", first.CheckCount, first.LastCheckedAt, first.CheckErrors)
+	}
+	if first.LastBalanceSeen[wallet.Bitcoin] != 0.0004 || first.LastActivityAt.IsZero() {
+		t.Errorf("partial payment not recorded: balance = %v, activity = %v", first.LastBalanceSeen, first.LastActivityAt)
+	}
+
+	client.err = errors.New("api unavailable")
+	monitor.checkPendingPayments()
+	second, _ := store.GetPayment(payment.ID)
+	if second.CheckCount != 2 || second.CheckErrors != 1 {
+		t.Errorf("after failed check: count = %d, errors = %d, want 2 and 1", second.CheckCount, second.CheckErrors)
+	}
+	if !second.LastActivityAt.Equal(first.LastActivityAt) {
+		t.Error("a failed check must not count as activity")
+	}
+}
+
+func TestSortByMonitorPriority(t *testing.T) {
+	now := time.Now()
+	payments := []*Payment{
+		{ID: "idle-recent", LastCheckedAt: now},
+		{ID: "idle-stale", LastCheckedAt: now.Add(-time.Hour)},
+		{ID: "active", LastCheckedAt: now, LastActivityAt: now.Add(-time.Minute)},
+		{ID: "never-checked"},
+	}
+	sortByMonitorPriority(payments, now)
+
+	want := []string{"never-checked", "active", "idle-stale", "idle-recent"}
+	for i, id := range want {
+		if payments[i].ID != id {
+			t.Errorf("position %d = %s, want %s", i, payments[i].ID, id)
+		}
+	}
+}
+
+func TestSortByMonitorPriority_ExpiringFirst(t *testing.T) {
+	now := time.Now()
+	payments := []*Payment{
+		{ID: "never-checked", ExpiresAt: now.Add(time.Hour)},
+		{ID: "expires-in-90s", LastCheckedAt: now, ExpiresAt: now.Add(90 * time.Second)},
+		{ID: "expired", ExpiresAt: now.Add(-time.Second)},
+		{ID: "expires-in-30s", LastCheckedAt: now, ExpiresAt: now.Add(30 * time.Second)},
+	}
+	sortByMonitorPriority(payments, now)
+
+	want := []string{"expires-in-30s", "expires-in-90s", "never-checked", "expired"}
+	for i, id := range want {
+		if payments[i].ID != id {
+			t.Errorf("position %d = %s, want %s", i, payments[i].ID, id)
+		}
+	}
+}
+
+// TestScheduleFinalChecks tests that a payment expiring before the next cycle
+// is checked once more right before it expires
+func TestScheduleFinalChecks(t *testing.T) {
+	store := NewMemoryStore()
+	pw := &Paywall{
+		Store:            store,
+		minConfirmations: 1,
+		logger:           NewStructuredLogger(io.Discard, LogLevelError, true),
+	}
+	monitor := &CryptoChainMonitor{
+		paywall: pw,
+		client: map[wallet.WalletType]CryptoClient{
+			wallet.Bitcoin: &mockCryptoClient{balance: 0.001},
+			wallet.Monero:  &mockCryptoClient{},
+		},
+	}
+	now := time.Now()
+	expiring := &Payment{
+		ID:        "expiring",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		ExpiresAt: now.Add(finalCheckLead + 50*time.Millisecond),
+		Status:    StatusPending,
+	}
+	later := &Payment{
+		ID:        "later",
+		Addresses: map[wallet.WalletType]string{wallet.Bitcoin: "btc-address-2"},
+		Amounts:   map[wallet.WalletType]float64{wallet.Bitcoin: 0.001},
+		ExpiresAt: now.Add(time.Hour),
+		Status:    StatusPending,
+	}
+	store.CreatePayment(expiring)
+	store.CreatePayment(later)
+
+	monitor.scheduleFinalChecks([]*Payment{expiring, later}, now)
+	monitor.finalChecksMu.Lock()
+	scheduled := len(monitor.finalChecks)
+	monitor.finalChecksMu.Unlock()
+	if scheduled != 1 {
+		t.Fatalf("scheduled %d final checks, want 1", scheduled)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, _ := store.GetPayment(expiring.ID)
+		if got.Status == StatusConfirmed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("final check did not confirm the expiring payment")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, _ := store.GetPayment(later.ID); got.Status != StatusPending {
+		t.Errorf("payment not due for a final check was checked: %s", got.Status)
+	}
+}
+
+func TestDueForCheck_WatchDecay(t *testing.T) {
+	now := time.Now()
+	pw := &Paywall{watchDecayAfter: 10 * time.Minute, watchDecayMaxInterval: 50 * time.Second}
+	unfunded := func(age, sinceCheck time.Duration) *Payment {
+		return &Payment{
+			Status:        StatusPending,
+			CreatedAt:     now.Add(-age),
+			ExpiresAt:     now.Add(time.Hour),
+			LastCheckedAt: now.Add(-sinceCheck),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		paywall *Paywall
+		payment *Payment
+		want    bool
+	}{
+		{"decay disabled", &Paywall{}, unfunded(time.Hour, time.Second), true},
+		{"young payment every cycle", pw, unfunded(5*time.Minute, 10*time.Second), true},
+		{"after decay: checked 10s ago", pw, unfunded(11*time.Minute, 10*time.Second), false},
+		{"after decay: checked 20s ago", pw, unfunded(11*time.Minute, 20*time.Second), true},
+		{"twice decayed: checked 30s ago", pw, unfunded(21*time.Minute, 30*time.Second), false},
+		{"twice decayed: checked 40s ago", pw, unfunded(21*time.Minute, 40*time.Second), true},
+		{"capped: checked 50s ago", pw, unfunded(2*time.Hour, 50*time.Second), true},
+		{"capped: checked 30s ago", pw, unfunded(2*time.Hour, 30*time.Second), false},
+		{"never checked", pw, &Payment{Status: StatusPending, CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}, true},
+		{"funds seen", pw, func() *Payment {
+			p := unfunded(time.Hour, time.Second)
+			p.LastActivityAt = now.Add(-30 * time.Minute)
+			return p
+		}(), true},
+		{"detected", pw, func() *Payment {
+			p := unfunded(time.Hour, time.Second)
+			p.Status = StatusDetected
+			return p
+		}(), true},
+		{"about to expire", pw, func() *Payment {
+			p := unfunded(time.Hour, time.Second)
+			p.ExpiresAt = now.Add(time.Minute)
+			return p
+		}(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.paywall.dueForCheck(tt.payment, now); got != tt.want {
+				t.Errorf("dueForCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
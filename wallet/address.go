@@ -1,8 +1,13 @@
 package wallet
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"strings"
 
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/btcsuite/btcd/chaincfg"
 )
 
@@ -77,11 +82,20 @@ func (a Address) IsForNet(params *chaincfg.Params) bool {
 	if networkType == "testnet" && params.Name == chaincfg.TestNet3Params.Name {
 		return true
 	}
+	if networkType == "regtest" && params.Name == chaincfg.RegressionNetParams.Name {
+		return true
+	}
 	return false
 }
 
 // IsBitcoinAddress checks if a string is a valid Bitcoin address
-// and returns whether it's a mainnet or testnet address, or "invalid" if the address is not valid.
+// and returns whether it's a mainnet, testnet or regtest (bcrt1) address, or
+// "invalid" if the address is not valid. SegWit (bc1q) and Taproot (bc1p)
+// addresses are decoded, so their checksum, encoding (bech32 for witness
+// version 0, bech32m for later versions) and program length are verified;
+// they must be lowercase. For base58 addresses only the format is checked;
+// base58 regtest addresses share the testnet format and are reported as
+// testnet. Use ValidateAddress to verify base58 checksums as well.
 func IsBitcoinAddress(address string) (bool, string) {
 	// Base58 mainnet addresses start with 1 or 3
 	mainnetRegex := regexp.MustCompile("^(1|3)[a-km-zA-HJ-NP-Z1-9]{25,34}$")
@@ -89,17 +103,226 @@ func IsBitcoinAddress(address string) (bool, string) {
 	// Base58 testnet addresses start with m, n or 2
 	testnetRegex := regexp.MustCompile("^(m|n|2)[a-km-zA-HJ-NP-Z1-9]{25,34}$")
 
-	// Bech32 mainnet addresses start with bc1
-	mainnetBech32Regex := regexp.MustCompile("^bc1[a-z0-9]{25,90}$")
+	if hrp, ok := decodeSegWitAddress(address); ok {
+		if address != strings.ToLower(address) {
+			return false, "invalid"
+		}
+		if network := map[string]string{"bc": "mainnet", "tb": "testnet", "bcrt": "regtest"}[hrp]; network != "" {
+			return true, network
+		}
+		return false, "invalid"
+	}
+
+	if mainnetRegex.MatchString(address) {
+		return true, "mainnet"
+	} else if testnetRegex.MatchString(address) {
+		return true, "testnet"
+	}
+
+	return false, "invalid"
+}
+
+// decodeSegWitAddress decodes a SegWit address of any coin and returns its
+// human-readable part. ok is false unless the checksum is valid, witness
+// version 0 uses bech32 and later versions bech32m (BIP350), and the witness
+// program has a valid length (BIP141).
+func decodeSegWitAddress(address string) (hrp string, ok bool) {
+	hrp, data, version, err := bech32.DecodeGeneric(address)
+	if err != nil || len(data) == 0 || data[0] > 16 {
+		return "", false
+	}
+	if (data[0] == 0) != (version == bech32.Version0) {
+		return "", false
+	}
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil || len(program) < 2 || len(program) > 40 || (data[0] == 0 && len(program) != 20 && len(program) != 32) {
+		return "", false
+	}
+	return strings.ToLower(hrp), true
+}
+
+// IsBitcoinCashAddress checks if a string is a valid Bitcoin Cash address and returns
+// whether it's a mainnet or testnet address, or "invalid" if the address is not valid.
+// Both cashaddr ("bitcoincash:qp...", "bchtest:qp...", prefix optional) and legacy
+// base58 formats are accepted; checksums are verified.
+func IsBitcoinCashAddress(address string) (bool, string) {
+	for _, candidate := range []struct{ prefix, network string }{
+		{"bitcoincash", "mainnet"},
+		{"bchtest", "testnet"},
+	} {
+		prefix, addrType, _, err := DecodeCashAddr(address, candidate.prefix)
+		if err == nil && prefix == candidate.prefix && (addrType == cashAddrP2PKH || addrType == cashAddrP2SH) {
+			return true, candidate.network
+		}
+	}
 
-	// Bech32 testnet addresses start with tb1
-	testnetBech32Regex := regexp.MustCompile("^tb1[a-z0-9]{25,90}$")
+	version, _, err := base58CheckDecode(address)
+	if err != nil {
+		return false, "invalid"
+	}
+	switch version {
+	case 0x00, 0x05: // Legacy P2PKH/P2SH, shared with Bitcoin mainnet
+		return true, "mainnet"
+	case 0x6f, 0xc4:
+		return true, "testnet"
+	}
+	return false, "invalid"
+}
 
-	if mainnetRegex.MatchString(address) || mainnetBech32Regex.MatchString(address) {
+// IsDogecoinAddress checks if a string is a valid Dogecoin address and returns
+// whether it's a mainnet or testnet address, or "invalid" if the address is not valid.
+// Mainnet addresses start with D (P2PKH) or 9/A (P2SH); testnet with n (P2PKH) or 2 (P2SH).
+func IsDogecoinAddress(address string) (bool, string) {
+	version, _, err := base58CheckDecode(address)
+	if err != nil {
+		return false, "invalid"
+	}
+	switch version {
+	case 0x1e, 0x16:
 		return true, "mainnet"
-	} else if testnetRegex.MatchString(address) || testnetBech32Regex.MatchString(address) {
+	case 0x71, 0xc4:
 		return true, "testnet"
 	}
+	return false, "invalid"
+}
+
+// IsLitecoinAddress checks if a string is a valid Litecoin address and returns
+// whether it's a mainnet or testnet address, or "invalid" if the address is not valid.
+// Mainnet addresses start with L (P2PKH), M or 3 (P2SH) or ltc1 (SegWit); testnet
+// with m or n (P2PKH), Q or 2 (P2SH) or tltc1. Checksums are verified.
+func IsLitecoinAddress(address string) (bool, string) {
+	if hrp, ok := decodeSegWitAddress(address); ok {
+		network := map[string]string{"ltc": "mainnet", "tltc": "testnet"}[hrp]
+		if network == "" {
+			return false, "invalid"
+		}
+		return true, network
+	}
 
+	version, _, err := base58CheckDecode(address)
+	if err != nil {
+		return false, "invalid"
+	}
+	switch version {
+	case 0x30, 0x32, 0x05: // P2PKH, P2SH and legacy P2SH
+		return true, "mainnet"
+	case 0x6f, 0x3a, 0xc4:
+		return true, "testnet"
+	}
 	return false, "invalid"
 }
+
+// ErrInvalidAddress is wrapped by every AddressError
+var ErrInvalidAddress = errors.New("invalid payment address")
+
+// AddressError reports a payment address its currency's validator rejected
+type AddressError struct {
+	// Currency is the address's currency
+	Currency WalletType
+	// Address is the rejected address
+	Address string
+	// Reason says what is wrong with it
+	Reason string
+}
+
+// Error implements error
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid %s address %q: %s", e.Currency, e.Address, e.Reason)
+}
+
+// Unwrap returns ErrInvalidAddress
+func (e *AddressError) Unwrap() error {
+	return ErrInvalidAddress
+}
+
+// addressValidators check the addresses of each currency, returning the reason
+// an address is invalid
+var addressValidators = map[WalletType]func(address string) error{
+	Bitcoin:     validateBitcoinAddress,
+	Monero:      validateMoneroAddress,
+	Litecoin:    validateNetworkAddress(IsLitecoinAddress),
+	Dogecoin:    validateNetworkAddress(IsDogecoinAddress),
+	BitcoinCash: validateNetworkAddress(IsBitcoinCashAddress),
+	Ethereum:    validateEthereumAddress,
+	USDC:        validateEthereumAddress,
+	USDT:        validateEthereumAddress,
+	DAI:         validateEthereumAddress,
+}
+
+// validateNetworkAddress adapts an Is<Coin>Address function, which also
+// verifies checksums, to addressValidators
+func validateNetworkAddress(is func(address string) (bool, string)) func(address string) error {
+	return func(address string) error {
+		if valid, _ := is(address); !valid {
+			return errors.New("not a valid address or bad checksum")
+		}
+		return nil
+	}
+}
+
+// validateEthereumAddress checks the format and EIP-55 checksum of an address
+func validateEthereumAddress(address string) error {
+	if !IsEthereumAddress(address) {
+		return errors.New("not a 0x-prefixed 20-byte address with a valid checksum")
+	}
+	return nil
+}
+
+// ValidateAddress checks an address with its currency's validator, including
+// checksums. Addresses of currencies without a validator are accepted.
+//
+// Parameters:
+//   - currency: The address's currency
+//   - address: The address to check
+//
+// Returns:
+//   - error: An *AddressError (wrapping ErrInvalidAddress) if the address is malformed
+func ValidateAddress(currency WalletType, address string) error {
+	validate, ok := addressValidators[currency]
+	if !ok {
+		return nil
+	}
+	if address == "" {
+		return &AddressError{Currency: currency, Address: address, Reason: "empty address"}
+	}
+	if err := validate(address); err != nil {
+		return &AddressError{Currency: currency, Address: address, Reason: err.Error()}
+	}
+	return nil
+}
+
+// validateBitcoinAddress checks the format with IsBitcoinAddress, then the
+// checksum by decoding the address for its network
+func validateBitcoinAddress(address string) error {
+	valid, network := IsBitcoinAddress(address)
+	if !valid {
+		return errors.New("not a Bitcoin address")
+	}
+	params := &chaincfg.MainNetParams
+	switch network {
+	case "testnet":
+		params = &chaincfg.TestNet3Params
+	case "regtest":
+		params = &chaincfg.RegressionNetParams
+	}
+	if _, err := btcutil.DecodeAddress(address, params); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateMoneroAddress checks an address with IsMoneroAddress, reporting
+// why decoding failed
+func validateMoneroAddress(address string) error {
+	if valid, _ := IsMoneroAddress(address); valid {
+		return nil
+	}
+	addr, err := decodeMoneroAddress(address)
+	if err != nil {
+		return err
+	}
+	if _, ok := moneroNetworks[addr.netByte]; !ok {
+		return fmt.Errorf("unknown Monero network byte %d", addr.netByte)
+	}
+	return errors.New("payment ID does not match address type")
+}
@@ -1,9 +1,11 @@
 package wallet
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 )
 
@@ -239,10 +241,35 @@ func TestIsBitcoinAddress(t *testing.T) {
 		},
 		{
 			name:        "valid mainnet bech32 long address",
-			address:     "bc1qrp33g0q4c70qt8d6u56c8f6x8sa9dnhxpx8dqt6",
+			address:     "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3",
 			wantValid:   true,
 			wantNetwork: "mainnet",
 		},
+		// Taproot addresses (start with bc1p, tb1p)
+		{
+			name:        "valid mainnet taproot address",
+			address:     "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+			wantValid:   true,
+			wantNetwork: "mainnet",
+		},
+		{
+			name:        "valid mainnet witness version 1 address",
+			address:     "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			wantValid:   true,
+			wantNetwork: "mainnet",
+		},
+		{
+			name:        "valid testnet taproot address",
+			address:     "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c",
+			wantValid:   true,
+			wantNetwork: "testnet",
+		},
+		{
+			name:        "valid regtest bech32 address",
+			address:     "bcrt1qs758ursh4q9z627kt3pp5yysm78ddny6txaqgw",
+			wantValid:   true,
+			wantNetwork: "regtest",
+		},
 		// Testnet addresses (start with m, n)
 		{
 			name:        "valid testnet address starting with m",
@@ -272,7 +299,7 @@ func TestIsBitcoinAddress(t *testing.T) {
 		},
 		{
 			name:        "valid testnet bech32 long address",
-			address:     "tb1qrp33g0q4c70qt8d6u56c8f6x8sa9dnhxpx8dqt6",
+			address:     "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7",
 			wantValid:   true,
 			wantNetwork: "testnet",
 		},
@@ -331,6 +358,36 @@ func TestIsBitcoinAddress(t *testing.T) {
 			wantValid:   false,
 			wantNetwork: "invalid",
 		},
+		{
+			name:        "bech32 checksum error",
+			address:     "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",
+			wantValid:   false,
+			wantNetwork: "invalid",
+		},
+		{
+			name:        "witness version 1 with bech32 checksum",
+			address:     "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+			wantValid:   false,
+			wantNetwork: "invalid",
+		},
+		{
+			name:        "witness version 0 with bech32m checksum",
+			address:     "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",
+			wantValid:   false,
+			wantNetwork: "invalid",
+		},
+		{
+			name:        "witness version 0 with invalid program length",
+			address:     "bc1qr508d6qejxtdg4y5r3zarvaryvqyzf3du",
+			wantValid:   false,
+			wantNetwork: "invalid",
+		},
+		{
+			name:        "litecoin segwit address",
+			address:     "ltc1qg82d2zk5xl6ycp3ezj42rwfh7ezqumvtw5ay2j",
+			wantValid:   false,
+			wantNetwork: "invalid",
+		},
 		{
 			name:        "malformed address",
 			address:     "not-a-bitcoin-address",
@@ -397,10 +454,10 @@ func TestIsBitcoinAddress_EdgeCases(t *testing.T) {
 			wantNetwork: "invalid",
 		},
 		{
-			name:        "minimum valid bech32 testnet length (28 chars total)",
+			name:        "bech32 shape without a valid checksum",
 			address:     "tb1" + strings.Repeat("q", 25),
-			wantValid:   true,
-			wantNetwork: "testnet",
+			wantValid:   false,
+			wantNetwork: "invalid",
 		},
 		{
 			name:        "too short base58 mainnet address",
@@ -428,3 +485,51 @@ func TestIsBitcoinAddress_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateAddress(t *testing.T) {
+	regtest, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressWitnessPubKeyHash() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		currency WalletType
+		address  string
+		wantErr  bool
+	}{
+		{name: "bitcoin p2pkh", currency: Bitcoin, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{name: "bitcoin bech32", currency: Bitcoin, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{name: "bitcoin testnet bech32", currency: Bitcoin, address: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{name: "bitcoin regtest bech32", currency: Bitcoin, address: regtest.EncodeAddress()},
+		{name: "bitcoin bad checksum", currency: Bitcoin, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", wantErr: true},
+		{name: "bitcoin bech32 for another network", currency: Bitcoin, address: "tb1qrp33g0q4c70qt8d6u56c8f6x8sa9dnhxpx8dqt6", wantErr: true},
+		{name: "bitcoin garbage", currency: Bitcoin, address: "btc-address", wantErr: true},
+		{name: "bitcoin empty", currency: Bitcoin, address: "", wantErr: true},
+		{name: "monero standard", currency: Monero, address: testXMRAddress},
+		{name: "monero bad checksum", currency: Monero, address: testXMRAddress[:94] + "B", wantErr: true},
+		{name: "monero truncated", currency: Monero, address: testXMRAddress[:90], wantErr: true},
+		{name: "litecoin", currency: Litecoin, address: "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez"},
+		{name: "litecoin bad checksum", currency: Litecoin, address: "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ey", wantErr: true},
+		{name: "dogecoin given a bitcoin address", currency: Dogecoin, address: "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", wantErr: true},
+		{name: "currency without validator", currency: Lightning, address: "anything"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAddress(tt.currency, tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateAddress(%s, %q) error = %v, wantErr %v", tt.currency, tt.address, err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			var addrErr *AddressError
+			if !errors.As(err, &addrErr) || addrErr.Currency != tt.currency || addrErr.Address != tt.address || !errors.Is(err, ErrInvalidAddress) {
+				t.Errorf("ValidateAddress() error = %#v, want an *AddressError for the address", err)
+			}
+		})
+	}
+
+	if valid, network := IsBitcoinAddress(regtest.EncodeAddress()); !valid || network != "regtest" {
+		t.Errorf("IsBitcoinAddress(regtest) = %v, %q, want true, regtest", valid, network)
+	}
+}
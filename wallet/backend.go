@@ -0,0 +1,403 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+)
+
+const (
+	// defaultBackendFailureThreshold is how many consecutive failures open a
+	// backend's circuit
+	defaultBackendFailureThreshold = 3
+	// defaultBackendCooldown is how long an open circuit keeps a backend out
+	defaultBackendCooldown = time.Minute
+)
+
+// ErrNoBackendAvailable is returned when no registered backend may be asked:
+// none is registered, or every circuit is open
+var ErrNoBackendAvailable = errors.New("no blockchain backend available (hint: register a node or explorer with BackendRegistry.Register)")
+
+// BlockchainBackend is a source of Bitcoin chain data payments are verified
+// with: a node, an explorer or a service of your own. *NodeBackend and
+// *EsploraClient implement it.
+type BlockchainBackend interface {
+	// Name identifies the backend in the registry, logs and errors
+	Name() string
+	// GetAddressBalanceMinConf returns the amount in BTC address received in
+	// transactions with at least minConf confirmations
+	GetAddressBalanceMinConf(address string, minConf int) (float64, error)
+	// GetTransactionConfirmations returns the confirmations of a transaction,
+	// 0 while it is in the mempool
+	GetTransactionConfirmations(txID string) (int, error)
+	// HealthCheck returns an error when the backend cannot answer queries
+	HealthCheck() error
+}
+
+// ContextBlockchainBackend is a BlockchainBackend whose queries a context
+// cancels, as *EsploraClient's are. The registry's ...Context methods pass
+// their context to such backends; other backends are only not asked once the
+// context is done.
+type ContextBlockchainBackend interface {
+	BlockchainBackend
+	GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error)
+	GetTransactionConfirmationsContext(ctx context.Context, txID string) (int, error)
+}
+
+// BackendRegistryConfig tunes the circuit breaking of a BackendRegistry
+type BackendRegistryConfig struct {
+	// FailureThreshold is how many consecutive failed queries or health
+	// checks take a backend out of rotation. Defaults to 3.
+	FailureThreshold int
+	// Cooldown is how long a failing backend stays out of rotation before it
+	// is asked again. Defaults to one minute.
+	Cooldown time.Duration
+}
+
+// BackendStatus describes a registered backend
+type BackendStatus struct {
+	Name     string
+	Priority int
+	// Available reports whether queries are sent to the backend: its circuit
+	// is closed, or its cooldown has passed and it gets another try
+	Available bool
+	// Failures counts the consecutive failed queries and health checks
+	Failures int
+	// LastError is the error of the last failure, "" once the backend answers
+	LastError string
+	// OpenUntil is when an open circuit lets the backend be tried again
+	OpenUntil time.Time
+}
+
+// backendEntry is a registered backend and the state of its circuit
+type backendEntry struct {
+	backend   BlockchainBackend
+	priority  int
+	failures  int
+	lastError error
+	openUntil time.Time
+}
+
+// BackendRegistry sends Bitcoin chain queries to the registered backends in
+// priority order, the lowest value first. A backend that fails
+// FailureThreshold times in a row is skipped for Cooldown (its circuit opens),
+// then tried again; one successful query or health check closes the circuit.
+// Backends can be registered and removed while the registry is in use.
+//
+// Related: BlockchainBackend, NodeBackend, EsploraClient
+type BackendRegistry struct {
+	mu               sync.Mutex
+	entries          []*backendEntry // Sorted by priority
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+}
+
+// NewBackendRegistry creates an empty registry
+//
+// Parameters:
+//   - config: Circuit breaking settings; zero values use the defaults
+//
+// Returns:
+//   - *BackendRegistry: Registry to Register backends with
+func NewBackendRegistry(config BackendRegistryConfig) *BackendRegistry {
+	r := &BackendRegistry{
+		failureThreshold: config.FailureThreshold,
+		cooldown:         config.Cooldown,
+		now:              time.Now,
+	}
+	if r.failureThreshold <= 0 {
+		r.failureThreshold = defaultBackendFailureThreshold
+	}
+	if r.cooldown <= 0 {
+		r.cooldown = defaultBackendCooldown
+	}
+	return r
+}
+
+// Register adds a backend. Backends with a lower priority are asked first;
+// backends of equal priority in registration order.
+//
+// Parameters:
+//   - backend: The backend
+//   - priority: Position in the query order, e.g. 0 for your own node and
+//     10 for a public explorer
+//
+// Returns:
+//   - error: If backend is nil or a backend with its name is registered
+func (r *BackendRegistry) Register(backend BlockchainBackend, priority int) error {
+	if backend == nil {
+		return errors.New("backend is nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.backend.Name() == backend.Name() {
+			return fmt.Errorf("backend %q is already registered", backend.Name())
+		}
+	}
+	r.entries = append(r.entries, &backendEntry{backend: backend, priority: priority})
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		return r.entries[i].priority < r.entries[j].priority
+	})
+	return nil
+}
+
+// Unregister removes the backend with the given name, reporting whether it
+// was registered
+func (r *BackendRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.entries {
+		if e.backend.Name() == name {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the registered backends in query order
+func (r *BackendRegistry) Status() []BackendStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	statuses := make([]BackendStatus, len(r.entries))
+	for i, e := range r.entries {
+		statuses[i] = BackendStatus{
+			Name:      e.backend.Name(),
+			Priority:  e.priority,
+			Available: r.available(e, now),
+			Failures:  e.failures,
+			OpenUntil: e.openUntil,
+		}
+		if e.lastError != nil {
+			statuses[i].LastError = e.lastError.Error()
+		}
+	}
+	return statuses
+}
+
+// CheckHealth runs the health check of every backend, including those whose
+// circuit is open, so a recovered backend returns before its cooldown ends
+func (r *BackendRegistry) CheckHealth() {
+	r.mu.Lock()
+	entries := append([]*backendEntry(nil), r.entries...)
+	r.mu.Unlock()
+	for _, e := range entries {
+		r.record(e, e.backend.HealthCheck())
+	}
+}
+
+// RunHealthChecks calls CheckHealth every interval until ctx is done
+func (r *BackendRegistry) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckHealth()
+		}
+	}
+}
+
+// available reports whether e may be queried at now. Callers must hold r.mu.
+func (r *BackendRegistry) available(e *backendEntry, now time.Time) bool {
+	return e.failures < r.failureThreshold || !now.Before(e.openUntil)
+}
+
+// record updates e's circuit after a query or health check
+func (r *BackendRegistry) record(e *backendEntry, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		e.failures = 0
+		e.lastError = nil
+		e.openUntil = time.Time{}
+		return
+	}
+	e.failures++
+	e.lastError = err
+	if e.failures >= r.failureThreshold {
+		e.openUntil = r.now().Add(r.cooldown)
+	}
+}
+
+// queryBackends asks the available backends in priority order until one
+// answers or ctx is done. Failures caused by ctx do not count against a
+// backend's circuit.
+func queryBackends[T any](ctx context.Context, r *BackendRegistry, query func(BlockchainBackend) (T, error)) (T, error) {
+	r.mu.Lock()
+	now := r.now()
+	var candidates []*backendEntry
+	for _, e := range r.entries {
+		if r.available(e, now) {
+			candidates = append(candidates, e)
+		}
+	}
+	r.mu.Unlock()
+
+	var zero T
+	if len(candidates) == 0 {
+		return zero, ErrNoBackendAvailable
+	}
+	var errs []error
+	for _, e := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		result, err := query(e.backend)
+		if err != nil && ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.backend.Name(), err))
+			break
+		}
+		r.record(e, err)
+		if err == nil {
+			return result, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.backend.Name(), err))
+	}
+	return zero, errors.Join(errs...)
+}
+
+// GetAddressBalanceMinConf returns the amount in BTC address received with
+// at least minConf confirmations, as reported by the first backend that answers
+func (r *BackendRegistry) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return r.GetAddressBalanceMinConfContext(context.Background(), address, minConf)
+}
+
+// GetAddressBalanceMinConfContext is GetAddressBalanceMinConf with a context
+func (r *BackendRegistry) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	return queryBackends(ctx, r, func(b BlockchainBackend) (float64, error) {
+		if cb, ok := b.(ContextBlockchainBackend); ok {
+			return cb.GetAddressBalanceMinConfContext(ctx, address, minConf)
+		}
+		return b.GetAddressBalanceMinConf(address, minConf)
+	})
+}
+
+// GetAddressConfirmations returns how many confirmations the funds paying
+// amount to address have, counting up to maxConf. Backends with a
+// GetAddressConfirmations method of their own answer with it; for the others
+// the count is searched with GetAddressBalanceMinConf.
+func (r *BackendRegistry) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	return r.GetAddressConfirmationsContext(context.Background(), address, amount, maxConf)
+}
+
+// GetAddressConfirmationsContext is GetAddressConfirmations with a context
+func (r *BackendRegistry) GetAddressConfirmationsContext(ctx context.Context, address string, amount float64, maxConf int) (int, error) {
+	return queryBackends(ctx, r, func(b BlockchainBackend) (int, error) {
+		if counter, ok := b.(interface {
+			GetAddressConfirmationsContext(ctx context.Context, address string, amount float64, maxConf int) (int, error)
+		}); ok {
+			return counter.GetAddressConfirmationsContext(ctx, address, amount, maxConf)
+		}
+		if counter, ok := b.(interface {
+			GetAddressConfirmations(address string, amount float64, maxConf int) (int, error)
+		}); ok {
+			return counter.GetAddressConfirmations(address, amount, maxConf)
+		}
+		return searchConfirmations(func(minConf int) (float64, error) {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			return b.GetAddressBalanceMinConf(address, minConf)
+		}, amount, maxConf)
+	})
+}
+
+// GetTransactionConfirmations returns the confirmations of a transaction as
+// reported by the first backend that answers
+func (r *BackendRegistry) GetTransactionConfirmations(txID string) (int, error) {
+	return r.GetTransactionConfirmationsContext(context.Background(), txID)
+}
+
+// GetTransactionConfirmationsContext is GetTransactionConfirmations with a
+// context
+func (r *BackendRegistry) GetTransactionConfirmationsContext(ctx context.Context, txID string) (int, error) {
+	return queryBackends(ctx, r, func(b BlockchainBackend) (int, error) {
+		if cb, ok := b.(ContextBlockchainBackend); ok {
+			return cb.GetTransactionConfirmationsContext(ctx, txID)
+		}
+		return b.GetTransactionConfirmations(txID)
+	})
+}
+
+// NodeBackend is a BlockchainBackend on a bitcoind-compatible node RPC. The
+// node's wallet must watch the payment addresses (importaddress or a
+// descriptor wallet) for getreceivedbyaddress to see their payments.
+type NodeBackend struct {
+	name   string
+	client *rpcclient.Client
+}
+
+// NewNodeBackend creates a backend for the node at rpc.Host
+//
+// Parameters:
+//   - name: Name in the registry, e.g. "local-node"; defaults to rpc.Host
+//   - rpc: Node RPC address and credentials
+//
+// Returns:
+//   - *NodeBackend: Backend; no request is made yet
+//   - error: If rpc.Host is empty or the RPC client cannot be created
+func NewNodeBackend(name string, rpc UTXORPCConfig) (*NodeBackend, error) {
+	if rpc.Host == "" {
+		return nil, errors.New("node RPC host is required (hint: localhost:8332)")
+	}
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         rpc.Host,
+		User:         rpc.User,
+		Pass:         rpc.Pass,
+		HTTPPostMode: true,
+		DisableTLS:   rpc.DisableTLS,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create node RPC client: %w", err)
+	}
+	if name == "" {
+		name = rpc.Host
+	}
+	return &NodeBackend{name: name, client: client}, nil
+}
+
+// Name implements BlockchainBackend
+func (n *NodeBackend) Name() string {
+	return n.name
+}
+
+// GetAddressBalanceMinConf implements BlockchainBackend with getreceivedbyaddress
+func (n *NodeBackend) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	received, err := n.client.GetReceivedByAddressMinConf(Address(address), minConf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get address balance: %w", err)
+	}
+	return received.ToBTC(), nil
+}
+
+// GetTransactionConfirmations implements BlockchainBackend with gettransaction,
+// falling back to getrawtransaction for transactions the node's wallet does
+// not know
+func (n *NodeBackend) GetTransactionConfirmations(txID string) (int, error) {
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil || len(txID) != 64 {
+		return 0, fmt.Errorf("invalid transaction ID: %s", txID)
+	}
+	return nodeTransactionConfirmations(n.client, hash)
+}
+
+// HealthCheck implements BlockchainBackend with getblockcount
+func (n *NodeBackend) HealthCheck() error {
+	if _, err := n.client.GetBlockCount(); err != nil {
+		return fmt.Errorf("node %s: %w", n.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeBackend answers with a fixed balance, or err while it is set
+type fakeBackend struct {
+	name     string
+	received map[int]float64 // Received amount per minConf
+	err      error
+	queries  int
+}
+
+func (f *fakeBackend) Name() string { return f.name }
+
+func (f *fakeBackend) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	f.queries++
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.received[minConf], nil
+}
+
+func (f *fakeBackend) GetTransactionConfirmations(txID string) (int, error) {
+	f.queries++
+	return 3, f.err
+}
+
+func (f *fakeBackend) HealthCheck() error { return f.err }
+
+func TestBackendRegistry_Failover(t *testing.T) {
+	now := time.Now()
+	r := NewBackendRegistry(BackendRegistryConfig{FailureThreshold: 2, Cooldown: time.Minute})
+	r.now = func() time.Time { return now }
+	node := &fakeBackend{name: "node", received: map[int]float64{1: 0.5}}
+	explorer := &fakeBackend{name: "explorer", received: map[int]float64{1: 0.25}}
+
+	if _, err := r.GetAddressBalanceMinConf("addr", 1); !errors.Is(err, ErrNoBackendAvailable) {
+		t.Errorf("empty registry error = %v, want ErrNoBackendAvailable", err)
+	}
+	r.Register(explorer, 10)
+	r.Register(node, 0)
+	if err := r.Register(&fakeBackend{name: "node"}, 5); err == nil {
+		t.Error("Register() accepted a duplicate name")
+	}
+
+	if got, err := r.GetAddressBalanceMinConf("addr", 1); err != nil || got != 0.5 {
+		t.Fatalf("balance = %v, %v, want the node's 0.5", got, err)
+	}
+
+	// A failing node is skipped once its circuit opens
+	node.err = errors.New("connection refused")
+	for range 2 {
+		if got, _ := r.GetAddressBalanceMinConf("addr", 1); got != 0.25 {
+			t.Errorf("balance with the node down = %v, want the explorer's 0.25", got)
+		}
+	}
+	node.queries = 0
+	r.GetAddressBalanceMinConf("addr", 1)
+	if node.queries != 0 {
+		t.Error("node with an open circuit was queried")
+	}
+	if status := r.Status(); status[0].Name != "node" || status[0].Available || status[0].LastError != "connection refused" {
+		t.Errorf("Status()[0] = %+v, want the unavailable node first", status[0])
+	}
+
+	// After the cooldown it gets another try, and one success closes the circuit
+	now = now.Add(time.Minute)
+	node.err = nil
+	if got, _ := r.GetAddressBalanceMinConf("addr", 1); got != 0.5 {
+		t.Errorf("balance after the cooldown = %v, want the node's 0.5", got)
+	}
+	if status := r.Status(); !status[0].Available || status[0].Failures != 0 {
+		t.Errorf("Status()[0] after recovery = %+v", status[0])
+	}
+
+	// Every backend down
+	node.err, explorer.err = errors.New("down"), errors.New("rate limited")
+	if _, err := r.GetTransactionConfirmations("tx"); err == nil {
+		t.Error("GetTransactionConfirmations() succeeded with every backend down")
+	}
+	r.CheckHealth()
+	if _, err := r.GetTransactionConfirmations("tx"); !errors.Is(err, ErrNoBackendAvailable) {
+		t.Errorf("error with every circuit open = %v, want ErrNoBackendAvailable", err)
+	}
+	// A passing health check brings a backend back before its cooldown ends
+	explorer.err = nil
+	r.CheckHealth()
+	if got, err := r.GetTransactionConfirmations("tx"); err != nil || got != 3 {
+		t.Errorf("GetTransactionConfirmations() after a passing health check = %d, %v", got, err)
+	}
+
+	if !r.Unregister("explorer") || r.Unregister("explorer") {
+		t.Error("Unregister() should remove the explorer once")
+	}
+	if len(r.Status()) != 1 {
+		t.Errorf("Status() = %+v, want only the node", r.Status())
+	}
+}
+
+func TestBackendRegistry_GetAddressConfirmations(t *testing.T) {
+	r := NewBackendRegistry(BackendRegistryConfig{})
+	r.Register(&fakeBackend{name: "node", received: map[int]float64{0: 1, 1: 1, 2: 1, 3: 0.4}}, 0)
+	if got, err := r.GetAddressConfirmations("addr", 1, 6); err != nil || got != 2 {
+		t.Errorf("GetAddressConfirmations() = %d, %v, want 2 searched from balances", got, err)
+	}
+}
+
+// hangingBackend blocks its queries until their context is done
+type hangingBackend struct {
+	fakeBackend
+}
+
+func (h *hangingBackend) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	h.queries++
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func (h *hangingBackend) GetTransactionConfirmationsContext(ctx context.Context, txID string) (int, error) {
+	h.queries++
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestBackendRegistry_Context(t *testing.T) {
+	r := NewBackendRegistry(BackendRegistryConfig{FailureThreshold: 1})
+	hanging := &hangingBackend{fakeBackend{name: "hanging"}}
+	explorer := &fakeBackend{name: "explorer", received: map[int]float64{1: 0.25}}
+	r.Register(hanging, 0)
+	r.Register(explorer, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.GetAddressBalanceMinConfContext(ctx, "addr", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if explorer.queries != 0 {
+		t.Error("next backend asked after the context was done")
+	}
+	// The caller's deadline is not the backend's failure
+	if status := r.Status(); !status[0].Available || status[0].Failures != 0 {
+		t.Errorf("Status()[0] = %+v, want the circuit closed", status[0])
+	}
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// BTCAddressType is the script type of the Bitcoin addresses a wallet derives
+type BTCAddressType string
+
+const (
+	// AddressP2PKH is a legacy address (1... on mainnet), BIP44
+	AddressP2PKH BTCAddressType = "p2pkh"
+	// AddressP2SHP2WPKH is a SegWit address nested in P2SH (3...), BIP49
+	AddressP2SHP2WPKH BTCAddressType = "p2sh-p2wpkh"
+	// AddressP2WPKH is a native SegWit address (bc1q...), BIP84
+	AddressP2WPKH BTCAddressType = "p2wpkh"
+	// AddressP2TR is a Taproot address (bc1p...) with a key path only, BIP86
+	AddressP2TR BTCAddressType = "p2tr"
+)
+
+// Valid reports whether t is one of the address types above
+func (t BTCAddressType) Valid() bool {
+	switch t {
+	case AddressP2PKH, AddressP2SHP2WPKH, AddressP2WPKH, AddressP2TR:
+		return true
+	}
+	return false
+}
+
+// purpose returns the purpose level of the derivation path of t: 44, 49, 84
+// or 86, as the BIP defining the address type specifies
+func (t BTCAddressType) purpose() uint32 {
+	switch t {
+	case AddressP2SHP2WPKH:
+		return 49
+	case AddressP2WPKH:
+		return 84
+	case AddressP2TR:
+		return 86
+	default:
+		return purposeBIP44
+	}
+}
+
+// encodeBTCAddress encodes the address of type addressType paying to
+// pubKey. An empty addressType is AddressP2PKH.
+//
+// Parameters:
+//   - pubKey: Public key of the derived child
+//   - addressType: Script type of the address
+//   - network: Network selecting the version byte or bech32 prefix
+//
+// Returns:
+//   - string: Base58Check (P2PKH, P2SH) or bech32/bech32m (SegWit, Taproot) address
+//   - error: If the witness program cannot be encoded
+func encodeBTCAddress(pubKey *btcec.PublicKey, addressType BTCAddressType, network *chaincfg.Params) (string, error) {
+	var address btcutil.Address
+	var err error
+	switch addressType {
+	case AddressP2SHP2WPKH:
+		redeemScript := append([]byte{0x00, 0x14}, hash160(pubKey.SerializeCompressed())...)
+		return base58CheckEncode(network.ScriptHashAddrID, hash160(redeemScript)), nil
+	case AddressP2WPKH:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(hash160(pubKey.SerializeCompressed()), network)
+	case AddressP2TR:
+		// BIP86 tweaks the key with an empty script tree, so the output can
+		// only be spent with the key path
+		outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		address, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), network)
+	default:
+		return base58CheckEncode(network.PubKeyHashAddrID, hash160(pubKey.SerializeCompressed())), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("address generation failed: %w", err)
+	}
+	return address.EncodeAddress(), nil
+}
@@ -1,25 +1,19 @@
 // Package wallet implements Bitcoin HD (Hierarchical Deterministic) wallet functionality
-// according to BIP32, BIP44, and BIP49 specifications.
+// according to BIP32, BIP44, BIP49, BIP84 and BIP86 specifications.
 package wallet
 
 import (
-	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
-	"net/http"
-	"strings"
 	"sync"
-	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/rpcclient"
-	"golang.org/x/crypto/ripemd160"
 )
 
 const (
@@ -29,91 +23,11 @@ const (
 	coinTypeBTC      = 0          // Bitcoin coin type
 	accountDefault   = 0          // Default account index
 	changeExternal   = 0          // External chain for receiving addresses
-)
-
-var testnetAPIEndpoints = []string{
-	// BlockCypher endpoints
-	"api.blockcypher.com/v1/btc/test3",
-	"tbtc.blockdozer.com",
-
-	// Bitcoin Core compatible endpoints
-	"testnet.bitcoin.criptolayer.net",
-	"testnet3.blockchain.info",
-	"testnet-btc.cointools.io",
-	"btc-testnet.greyh.at",
-	"testnet.bitcoinrpc.io",
-	"testnet.blockchain.info",
-	"testnet-api.smartbit.com.au/v1/blockchain",
-	"tchain.api.btc.com/v3",
-	"test-insight.bitpay.com/api",
-	"testnet.blockexplorer.com/api",
-	"tbtc1.trezor.io",
-	"tbtc2.trezor.io",
-	"bitcoin-testnet-api.blockcypher.com",
-	"testnet-api.blockchain.info",
-	"testnet.blockstream.info/api",
-	"testnet.bitcoinexplorer.org",
-	"testnet.bitaps.com",
-	"testnet-api.smartbit.com.au",
-	"testnet.chain.so/api/v2",
-	"testnet.blockchair.com/bitcoin/testnet",
-}
-
-var mainnetAPIEndpoints = []string{
-	// BlockCypher endpoints
-	"api.blockcypher.com/v1/btc/main",
-
-	// Bitcoin Core compatible endpoints
-	"mainnet.bitcoin.criptolayer.net",
-	"blockchain.info",
-	"btc.cointools.io",
-	"main.btc.greyh.at",
-	"bitcoin.bitcoinrpc.io",
-	"api.blockchain.info",
-	"api.smartbit.com.au/v1/blockchain",
-	"chain.api.btc.com/v3",
-	"insight.bitpay.com/api",
-	"blockexplorer.com/api",
-	"btc1.trezor.io",
-	"btc2.trezor.io",
-	"bitcoin-mainnet-api.blockcypher.com",
-	"api.blockchain.info",
-	"blockstream.info/api",
-	"bitcoinexplorer.org",
-	"api.bitaps.com",
-	"api.smartbit.com.au",
-	"chain.so/api/v2",
-	"api.blockchair.com/bitcoin",
-	"mempool.space/api",
-	"blockbook.bitcoin.org",
-}
-
-// Usage considerations:
-// 1. Many of these endpoints may implement rate limiting
-// 2. Some endpoints might require HTTPS
-// 3. Consider implementing fallback logic between endpoints
-// 4. Monitor endpoint health in production
-// 5. Some endpoints might have slightly different API structures
-
-// Example helper function to validate endpoints
-func validateEndpoint(endpoint string) bool {
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-
-	// Add https:// if not present
-	if !strings.HasPrefix(endpoint, "http") {
-		endpoint = "https://" + endpoint
-	}
-
-	resp, err := client.Get(endpoint)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
-}
+	// DefaultGapLimit is how many unused addresses in a row RecoverNextIndex
+	// scans past the last used one, the gap limit of BIP44
+	DefaultGapLimit = 20
+)
 
 func Intn(n int) int {
 	if n <= 0 {
@@ -126,49 +40,13 @@ func Intn(n int) int {
 	r, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
 	if err != nil {
 		// CRITICAL: crypto/rand failure is fatal. We cannot use math/rand for
-		// security-sensitive operations like endpoint selection and payment ID generation.
+		// security-sensitive operations like payment ID generation.
 		// Panic here rather than silently degrading to predictable randomness.
 		panic(fmt.Sprintf("crypto/rand.Int failed: %v - cannot initialize wallet securely", err))
 	}
 	return int(r.Int64())
 }
 
-func randomInt(min, max int) int {
-	return min + Intn(max-min)
-}
-
-func randomElement(list []string) string {
-	min := 0
-	max := len(list)
-	index := randomInt(min, max)
-	return list[index]
-}
-
-func randomEndpoint(testnet bool) string {
-	retries := 10
-	count := 0
-	if testnet {
-		endpoint := randomElement(testnetAPIEndpoints)
-		for !validateEndpoint(endpoint) {
-			endpoint = randomElement(testnetAPIEndpoints)
-			count++
-			if count == retries {
-				return ""
-			}
-		}
-		return endpoint
-	}
-	endpoint := randomElement(mainnetAPIEndpoints)
-	for !validateEndpoint(endpoint) {
-		endpoint = randomElement(mainnetAPIEndpoints)
-		count++
-		if count == retries {
-			return ""
-		}
-	}
-	return endpoint
-}
-
 // BTCHDWallet represents a hierarchical deterministic Bitcoin wallet
 // implementing BIP32 and BIP44 standards.
 type BTCHDWallet struct {
@@ -180,6 +58,8 @@ type BTCHDWallet struct {
 	mu             sync.RWMutex      // Mutex for thread safety
 	minConf        int               // Minimum confirmations for balance queries
 	multisigConfig *MultisigConfig   // Optional multisig configuration
+	mnemonic       string            // BIP39 mnemonic the wallet was created from, if any
+	addressType    BTCAddressType    // Script type of derived addresses, P2PKH when empty
 }
 
 // NewHDWallet creates a new HD wallet from a seed.
@@ -203,19 +83,30 @@ func NewBTCHDWallet(seed []byte, testnet bool, minConf int) (*BTCHDWallet, error
 	}
 
 	// Generate master key and chain code
-	hmac := hmac.New(sha512.New, []byte("Bitcoin seed"))
-	hmac.Write(seed)
-	sum := hmac.Sum(nil)
-
-	masterKey := sum[:32]
-	chainCode := sum[32:]
+	masterKey, chainCode := masterKeyFromSeed(seed)
 
 	network := &chaincfg.MainNetParams
 	if testnet {
 		network = &chaincfg.TestNet3Params
 	}
 
-	// Try local node first
+	client, err := newLocalNodeClient(testnet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BTCHDWallet{
+		masterKey: masterKey,
+		chainCode: chainCode,
+		network:   network,
+		nextIndex: 0,
+		rpcClient: client,
+		minConf:   minConf,
+	}, nil
+}
+
+// newLocalNodeClient returns a client of the Bitcoin node on localhost
+func newLocalNodeClient(testnet bool) (*rpcclient.Client, error) {
 	port := "8332"
 	if testnet {
 		port = "18332"
@@ -229,39 +120,54 @@ func NewBTCHDWallet(seed []byte, testnet bool, minConf int) (*BTCHDWallet, error
 
 	client, err := rpcclient.New(localConfig, nil)
 	if err != nil {
-		// Fall back to public node if local fails
-		publicHost := randomEndpoint(testnet)
-
-		publicConfig := &rpcclient.ConnConfig{
-			Host:         publicHost,
-			HTTPPostMode: true,
-			DisableTLS:   false,
-			// Confirmations: 1, // Default confirmations
-		}
+		return nil, fmt.Errorf("create local node client: %w", err)
+	}
+	return client, nil
+}
 
-		client, err = rpcclient.New(publicConfig, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to both local and public nodes: %v", err)
-		}
+// SetAddressType selects the script type of the addresses the wallet derives
+// from now on. Each type has its own derivation path, m/44'/0'/0'/0/i for
+// AddressP2PKH (the default), 49' for AddressP2SHP2WPKH, 84' for
+// AddressP2WPKH and 86' for AddressP2TR, so a wallet restored from the same
+// seed must use the same type to find its addresses. The next index is kept;
+// addresses of the new path below it are skipped, not reused.
+//
+// Parameters:
+//   - addressType: One of the BTCAddressType constants
+//
+// Returns:
+//   - error: If addressType is unknown
+//
+// Related: AddressType, DeriveNextAddress
+func (w *BTCHDWallet) SetAddressType(addressType BTCAddressType) error {
+	if !addressType.Valid() {
+		return fmt.Errorf("unsupported address type %q (hint: use p2pkh, p2sh-p2wpkh, p2wpkh or p2tr)", addressType)
 	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.addressType = addressType
+	return nil
+}
 
-	return &BTCHDWallet{
-		masterKey: masterKey,
-		chainCode: chainCode,
-		network:   network,
-		nextIndex: 0,
-		rpcClient: client,
-	}, nil
+// AddressType returns the script type of the derived addresses
+func (w *BTCHDWallet) AddressType() BTCAddressType {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.addressType == "" {
+		return AddressP2PKH
+	}
+	return w.addressType
 }
 
-// DeriveNextAddress derives the next Bitcoin address using BIP44 path m/44'/0'/0'/0/index
+// DeriveNextAddress derives the next Bitcoin address using the path of the
+// wallet's address type, m/44'/0'/0'/0/index for legacy addresses
 //
 // Returns:
-//   - string: Base58Check encoded Bitcoin address
+//   - string: Address of the wallet's address type
 //   - error: If key derivation or address generation fails
 //
 // Path components:
-//   - 44' : BIP44 purpose
+//   - 44' : Purpose, 49', 84' or 86' for the SegWit and Taproot types
 //   - 0'  : Bitcoin coin type
 //   - 0'  : Account 0
 //   - 0   : External chain
@@ -271,13 +177,76 @@ func NewBTCHDWallet(seed []byte, testnet bool, minConf int) (*BTCHDWallet, error
 func (w *BTCHDWallet) DeriveNextAddress() (string, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	// Derive BIP44 path: m/44'/0'/0'/0/index
+	address, err := w.deriveAddress(w.addressType, w.nextIndex)
+	if err != nil {
+		return "", err
+	}
+
+	w.nextIndex++
+	return address, nil
+}
+
+// AddressAt derives the receiving address at index without advancing the
+// wallet's next index, for callers that count indexes elsewhere
+//
+// Parameters:
+//   - index: Address index on the external chain
+//
+// Returns:
+//   - string: Address of the wallet's address type
+//   - error: If key derivation or address generation fails
+func (w *BTCHDWallet) AddressAt(index uint32) (string, error) {
+	return w.addressAt(index)
+}
+
+// DeriveAddressAt derives the receiving address at index, handed out by a
+// caller that counts indexes elsewhere, and moves the next index past it
+// unless it is already further
+//
+// Parameters:
+//   - index: Address index on the external chain
+//
+// Returns:
+//   - string: Address of the wallet's address type
+//   - error: If key derivation or address generation fails
+func (w *BTCHDWallet) DeriveAddressAt(index uint32) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	address, err := w.deriveAddress(w.addressType, index)
+	if err != nil {
+		return "", err
+	}
+	w.nextIndex = max(w.nextIndex, index+1)
+	return address, nil
+}
+
+// addressAt derives the receiving address at index with the wallet's
+// address type, e.g. m/84'/0'/0'/0/index for AddressP2WPKH
+func (w *BTCHDWallet) addressAt(index uint32) (string, error) {
+	return w.deriveAddress(w.AddressType(), index)
+}
+
+// deriveAddress derives the receiving address of addressType at index. The
+// keys and network it reads do not change after the wallet is created.
+func (w *BTCHDWallet) deriveAddress(addressType BTCAddressType, index uint32) (string, error) {
+	privKey, err := w.privateKeyAt(addressType, index)
+	if err != nil {
+		return "", err
+	}
+
+	// Generate address from public key
+	return encodeBTCAddress(privKey.PubKey(), addressType, w.network)
+}
+
+// privateKeyAt derives the private key of the receiving address of
+// addressType at index
+func (w *BTCHDWallet) privateKeyAt(addressType BTCAddressType, index uint32) (*btcec.PrivateKey, error) {
 	path := []uint32{
-		purposeBIP44 | hardenedKeyStart,
+		addressType.purpose() | hardenedKeyStart,
 		coinTypeBTC | hardenedKeyStart,
 		accountDefault | hardenedKeyStart,
 		changeExternal,
-		w.nextIndex,
+		index,
 	}
 
 	key := w.masterKey
@@ -287,23 +256,12 @@ func (w *BTCHDWallet) DeriveNextAddress() (string, error) {
 		var err error
 		key, chainCode, err = w.deriveKey(key, chainCode, segment)
 		if err != nil {
-			return "", fmt.Errorf("key derivation failed: %w", err)
+			return nil, fmt.Errorf("key derivation failed: %w", err)
 		}
 	}
 
-	// Generate public key
 	privKey, _ := btcec.PrivKeyFromBytes(key)
-	pubKey := privKey.PubKey()
-	pubKeyBytes := pubKey.SerializeCompressed()
-
-	// Generate address from public key
-	address, err := w.pubKeyToAddress(pubKeyBytes)
-	if err != nil {
-		return "", fmt.Errorf("address generation failed: %w", err)
-	}
-
-	w.nextIndex++
-	return address, nil
+	return privKey, nil
 }
 
 // deriveKey derives a child key from a parent key and chain code.
@@ -322,46 +280,7 @@ func (w *BTCHDWallet) DeriveNextAddress() (string, error) {
 //   - Implements BIP32 key derivation
 //   - Validates derived keys against curve order
 func (w *BTCHDWallet) deriveKey(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
-	var data []byte
-	if index >= hardenedKeyStart {
-		// Hardened derivation
-		data = append([]byte{0x00}, key...)
-	} else {
-		// Normal derivation
-		privKey, _ := btcec.PrivKeyFromBytes(key)
-		data = privKey.PubKey().SerializeCompressed()
-	}
-
-	indexBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(indexBytes, index)
-	data = append(data, indexBytes...)
-
-	hmac := hmac.New(sha512.New, chainCode)
-	hmac.Write(data)
-	sum := hmac.Sum(nil)
-
-	// Generate child key
-	childKey := sum[:32]
-	childChainCode := sum[32:]
-
-	// Add parent key to child key (mod curve order)
-	parentInt := new(big.Int).SetBytes(key)
-	childInt := new(big.Int).SetBytes(childKey)
-	curveOrder := btcec.S256().N
-
-	childInt.Add(childInt, parentInt)
-	childInt.Mod(childInt, curveOrder)
-
-	// Check for invalid keys
-	if childInt.Sign() == 0 {
-		return nil, nil, errors.New("invalid child key")
-	}
-
-	childKeyBytes := make([]byte, 32)
-	childIntBytes := childInt.Bytes()
-	copy(childKeyBytes[32-len(childIntBytes):], childIntBytes)
-
-	return childKeyBytes, childChainCode, nil
+	return deriveChildKey(key, chainCode, index)
 }
 
 // pubKeyToAddress converts a public key to a Bitcoin address.
@@ -382,30 +301,7 @@ func (w *BTCHDWallet) deriveKey(key, chainCode []byte, index uint32) ([]byte, []
 //
 // Related: base58Encode
 func (w *BTCHDWallet) pubKeyToAddress(pubKey []byte) (string, error) {
-	// SHA256
-	sha256Hash := sha256.Sum256(pubKey)
-
-	// RIPEMD160
-	ripemd160Hash := ripemd160.New()
-	ripemd160Hash.Write(sha256Hash[:])
-	pubKeyHash := ripemd160Hash.Sum(nil)
-
-	// Add version byte
-	version := w.network.PubKeyHashAddrID
-	versionedPayload := append([]byte{version}, pubKeyHash...)
-
-	// Double SHA256 for checksum
-	firstSHA := sha256.Sum256(versionedPayload)
-	secondSHA := sha256.Sum256(firstSHA[:])
-	checksum := secondSHA[:4]
-
-	// Combine version, payload, and checksum
-	fullPayload := append(versionedPayload, checksum...)
-
-	// Base58 encode
-	address := Base58Encode(fullPayload)
-
-	return address, nil
+	return base58CheckEncode(w.network.PubKeyHashAddrID, hash160(pubKey)), nil
 }
 
 // GetAddress returns the next available Bitcoin address.
@@ -453,6 +349,20 @@ func (w *BTCHDWallet) Currency() string {
 //
 // Related: GetTransactionConfirmations, CreateP2SHAddress, CreateP2WSHAddress
 func (w *BTCHDWallet) GetAddressBalance(address string) (float64, error) {
+	return w.GetAddressBalanceMinConf(address, w.minConf)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only funds with at
+// least minConf confirmations instead of the wallet's minimum
+//
+// Parameters:
+//   - address: Bitcoin address to check (single-sig or multisig)
+//   - minConf: Confirmations a transaction needs to count
+//
+// Returns:
+//   - float64: Confirmed balance in BTC
+//   - error: If address is invalid or query fails
+func (w *BTCHDWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
 	// Validate address format (supports all Bitcoin address types including multisig)
 	if address == "" {
 		return 0, fmt.Errorf("invalid bitcoin address: address is empty")
@@ -483,7 +393,7 @@ func (w *BTCHDWallet) GetAddressBalance(address string) (float64, error) {
 	// confirmations are reached. This simplifies balance checking by avoiding
 	// the need to parse transactions.
 	// Note: This does not include unconfirmed transactions.
-	balance, err := w.rpcClient.GetReceivedByAddressMinConf(Address(address), w.minConf)
+	balance, err := w.rpcClient.GetReceivedByAddressMinConf(Address(address), minConf)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get address balance: %w", err)
 	}
@@ -494,67 +404,67 @@ func (w *BTCHDWallet) GetAddressBalance(address string) (float64, error) {
 	return btcBalance, nil
 }
 
-// recoverNextIndex is an internal helper for potential future wallet recovery features.
-// It scans the blockchain for previously-used addresses and sets nextIndex to avoid address reuse
-// when importing a wallet seed on a new device (BIP44 recovery pattern).
-//
-// Currently unused and not integrated into the wallet initialization flow.
-// Present for future implementation if seed-based wallet recovery becomes a required feature.
-func (w *BTCHDWallet) recoverNextIndex() error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	// Query the blockchain for the highest used index
-	// This is a placeholder implementation
-	highestIndex := uint32(0)
-	for i := uint32(0); i < 1000; i++ {
-		path := []uint32{
-			purposeBIP44 | hardenedKeyStart,
-			coinTypeBTC | hardenedKeyStart,
-			accountDefault | hardenedKeyStart,
-			changeExternal,
-			i,
-		}
-		// Derive address and check if it has been used
-		// If used, update highestIndex
-		key := w.masterKey
-		chainCode := w.chainCode
-
-		// Derive address at index i
-		for _, segment := range path {
-			var err error
-			key, chainCode, err = w.deriveKey(key, chainCode, segment)
-			if err != nil {
-				return fmt.Errorf("key derivation failed: %w", err)
-			}
-		}
+// ReceivedBalanceSource reports what an address has received, as the
+// wallet's node (*BTCHDWallet), *EsploraClient and *BackendRegistry do
+type ReceivedBalanceSource interface {
+	// GetAddressBalanceMinConf returns the amount in BTC address received in
+	// transactions with at least minConf confirmations
+	GetAddressBalanceMinConf(address string, minConf int) (float64, error)
+}
 
-		// Generate public key and address
-		privKey, _ := btcec.PrivKeyFromBytes(key)
-		pubKey := privKey.PubKey()
-		pubKeyBytes := pubKey.SerializeCompressed()
+// RecoverNextIndex moves the next address index past the addresses that have
+// received funds, e.g. after restoring the wallet from its seed or from a
+// backup older than its last payments. Addresses are checked from index 0
+// until gapLimit of them in a row have received nothing (BIP44 account
+// discovery), so an address paid after fewer than gapLimit abandoned
+// payments is still found.
+//
+// Parameters:
+//   - source: Reports what an address received, including unconfirmed
+//     transactions; nil asks the wallet's node, which only knows the
+//     addresses it watches
+//   - gapLimit: Unused addresses in a row that end the scan; 0 or less uses
+//     DefaultGapLimit
+//
+// Returns:
+//   - uint32: The next index after recovery. It never moves back, so
+//     addresses handed out but not paid yet are not reused.
+//   - error: If an address cannot be checked; the index is left unchanged
+//
+// Related: GetNextIndex, LoadBTCHDWallet
+func (w *BTCHDWallet) RecoverNextIndex(source ReceivedBalanceSource, gapLimit int) (uint32, error) {
+	if source == nil {
+		source = w
+	}
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
 
-		address, err := w.pubKeyToAddress(pubKeyBytes)
+	// Scanning without the lock keeps payments coming while the source is
+	// asked; the index is only raised at the end
+	var next uint32
+	for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+		address, err := w.addressAt(index)
 		if err != nil {
-			return fmt.Errorf("address generation failed: %w", err)
+			return w.GetNextIndex(), err
 		}
-
-		// Check if address has been used by checking transaction history
-		// An address is "used" if it has ANY transaction history (received OR sent)
-		// Check both confirmed and unconfirmed received amounts
-		receivedAll, err := w.rpcClient.GetReceivedByAddressMinConf(Address(address), 0)
+		// An address is used once anything was sent to it, even if it has
+		// been spent since
+		received, err := source.GetAddressBalanceMinConf(address, 0)
 		if err != nil {
-			return fmt.Errorf("failed to check address transaction history: %w", err)
+			return w.GetNextIndex(), fmt.Errorf("check address %d: %w", index, err)
 		}
-
-		// If address has received any amount (even if now spent), update highestIndex
-		if receivedAll > 0 {
-			highestIndex = i
+		if received > 0 {
+			next, gap = index+1, 0
+		} else {
+			gap++
 		}
 	}
 
-	w.nextIndex = highestIndex + 1
-	return nil
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextIndex = max(w.nextIndex, next)
+	return w.nextIndex, nil
 }
 
 // RollbackLastAddress decrements the next index counter
@@ -574,20 +484,16 @@ func (w *BTCHDWallet) RollbackLastAddress() {
 //   - txID: Transaction ID to check confirmations for
 //
 // Returns:
-//   - int: Number of confirmations (0 if transaction not found)
-//   - error: If API query fails or transaction ID is invalid
-//
-// The method queries multiple Bitcoin API endpoints to determine transaction confirmations:
-//   - Uses random endpoint selection for load distribution
-//   - Implements fallback logic between endpoints
-//   - Returns 0 confirmations for unconfirmed transactions
+//   - int: Number of confirmations, 0 while the transaction is in the mempool
+//   - error: If the transaction ID is invalid, no RPC client is configured or
+//     the node does not know the transaction
 //
-// Error handling:
-//   - Returns 0 confirmations if transaction not found
-//   - Returns error for network/API failures
-//   - Validates transaction ID format
+// The node's wallet is asked first (gettransaction), which knows the
+// transactions paying the watched addresses. Transactions it does not know are
+// looked up with getrawtransaction, which needs -txindex for transactions
+// that are no longer in the mempool.
 //
-// Related: GetAddressBalance, wallet API endpoints
+// Related: GetAddressConfirmations, GetAddressBalance
 func (w *BTCHDWallet) GetTransactionConfirmations(txID string) (int, error) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -596,19 +502,77 @@ func (w *BTCHDWallet) GetTransactionConfirmations(txID string) (int, error) {
 	if len(txID) != 64 {
 		return 0, fmt.Errorf("invalid transaction ID length: expected 64 characters, got %d", len(txID))
 	}
-
-	// For now, return minimum confirmations if we have RPC client
-	// In production, this should query the actual transaction
-	if w.rpcClient != nil {
-		// This is a simplified implementation
-		// In production, would query: w.rpcClient.GetTransaction(txID)
-		// For now, assume transactions have sufficient confirmations
-		return w.minConf, nil
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid transaction ID: %w", err)
 	}
 
 	// Without RPC client, cannot verify transaction confirmations
-	// Return 0 to indicate unconfirmed
-	return 0, fmt.Errorf("no RPC client available for transaction confirmation")
+	if w.rpcClient == nil {
+		return 0, fmt.Errorf("no RPC client available for transaction confirmation")
+	}
+
+	return nodeTransactionConfirmations(w.rpcClient, hash)
+}
+
+// nodeTransactionConfirmations asks the node's wallet for the confirmations of
+// a transaction, then the node itself for transactions the wallet does not know
+func nodeTransactionConfirmations(client *rpcclient.Client, hash *chainhash.Hash) (int, error) {
+	tx, walletErr := client.GetTransaction(hash)
+	if walletErr == nil {
+		if tx.Confirmations < 0 {
+			// Conflicted with a confirmed transaction
+			return 0, fmt.Errorf("transaction %s conflicts with the chain (%d confirmations)", hash, tx.Confirmations)
+		}
+		return int(tx.Confirmations), nil
+	}
+	raw, err := client.GetRawTransactionVerbose(hash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction: %w", errors.Join(walletErr, err))
+	}
+	return int(raw.Confirmations), nil
+}
+
+// GetAddressConfirmations returns how many confirmations the funds paying
+// amount to address have: the highest count n for which the node reports at
+// least amount received with n confirmations. Counting stops at maxConf, so a
+// payment needing 6 confirmations costs at most three getreceivedbyaddress
+// calls.
+//
+// Parameters:
+//   - address: Bitcoin address to check
+//   - amount: Amount in BTC the funds must add up to
+//   - maxConf: Highest count of interest, usually the confirmations required
+//
+// Returns:
+//   - int: Confirmations, 0 while amount has not been received or is unconfirmed
+//   - error: If address is invalid or a query fails
+//
+// Related: GetAddressBalanceMinConf, GetTransactionConfirmations
+func (w *BTCHDWallet) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	return searchConfirmations(func(minConf int) (float64, error) {
+		return w.GetAddressBalanceMinConf(address, minConf)
+	}, amount, maxConf)
+}
+
+// searchConfirmations finds the highest count n in [0, maxConf] for which
+// received(n), the amount received with at least n confirmations, still
+// covers amount. received(n) shrinks as n grows, so it is a binary search.
+func searchConfirmations(received func(minConf int) (float64, error), amount float64, maxConf int) (int, error) {
+	low, high := 0, maxConf
+	for low < high {
+		mid := (low + high + 1) / 2
+		got, err := received(mid)
+		if err != nil {
+			return 0, err
+		}
+		if got >= amount {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low, nil
 }
 
 // GetNextIndex returns the current next index value for testing purposes
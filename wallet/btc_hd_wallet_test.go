@@ -5,6 +5,7 @@ package wallet
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"strings"
 	"sync"
 	"testing"
@@ -312,6 +313,70 @@ func TestBTCHDWallet_DeriveNextAddress(t *testing.T) {
 	}
 }
 
+// TestBTCHDWallet_SetAddressType checks the first receiving address of each
+// address type against the test vectors of BIP44, BIP49, BIP84 and BIP86 for
+// the "abandon ... about" mnemonic
+func TestBTCHDWallet_SetAddressType(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	tests := []struct {
+		addressType BTCAddressType
+		want        string
+	}{
+		{AddressP2PKH, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{AddressP2SHP2WPKH, "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{AddressP2WPKH, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{AddressP2TR, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.addressType), func(t *testing.T) {
+			w, err := NewBTCHDWalletFromMnemonic(mnemonic, "", false, 1)
+			if err != nil {
+				t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+			}
+			if err := w.SetAddressType(tt.addressType); err != nil {
+				t.Fatalf("SetAddressType() error = %v", err)
+			}
+			if got := w.AddressType(); got != tt.addressType {
+				t.Errorf("AddressType() = %s, want %s", got, tt.addressType)
+			}
+			addr, err := w.DeriveNextAddress()
+			if err != nil || addr != tt.want {
+				t.Errorf("DeriveNextAddress() = %s, %v, want %s", addr, err, tt.want)
+			}
+			if valid, network := IsBitcoinAddress(addr); !valid || network != "mainnet" {
+				t.Errorf("IsBitcoinAddress(%s) = %v, %s", addr, valid, network)
+			}
+		})
+	}
+
+	t.Run("default and invalid types", func(t *testing.T) {
+		w, err := NewBTCHDWalletFromMnemonic(mnemonic, "", true, 1)
+		if err != nil {
+			t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+		}
+		if got := w.AddressType(); got != AddressP2PKH {
+			t.Errorf("AddressType() = %s, want %s", got, AddressP2PKH)
+		}
+		if err := w.SetAddressType("p2wsh"); err == nil {
+			t.Error("SetAddressType(p2wsh) succeeded, want error")
+		}
+		if _, err := w.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+		if err := w.SetAddressType(AddressP2TR); err != nil {
+			t.Fatalf("SetAddressType() error = %v", err)
+		}
+		addr, err := w.DeriveNextAddress()
+		if err != nil || !strings.HasPrefix(addr, "tb1p") {
+			t.Errorf("DeriveNextAddress() = %s, %v, want a tb1p address", addr, err)
+		}
+		if w.GetNextIndex() != 2 {
+			t.Errorf("GetNextIndex() = %d, want 2; changing the type keeps the index", w.GetNextIndex())
+		}
+	})
+}
+
 // TestBTCHDWallet_GetAddress tests the GetAddress method
 func TestBTCHDWallet_GetAddress(t *testing.T) {
 	wallet := &BTCHDWallet{
@@ -602,89 +667,6 @@ func TestBTCHDWallet_HDWalletInterface(t *testing.T) {
 	}
 }
 
-// TestUtilityFunctions tests the utility functions
-func TestValidateEndpoint(t *testing.T) {
-	tests := []struct {
-		name     string
-		endpoint string
-		expected bool
-	}{
-		{
-			name:     "Valid HTTPS endpoint",
-			endpoint: "https://httpbin.org/status/200",
-			expected: true,
-		},
-		{
-			name:     "Valid HTTP endpoint",
-			endpoint: "http://httpbin.org/status/200",
-			expected: true,
-		},
-		{
-			name:     "Endpoint without protocol",
-			endpoint: "httpbin.org/status/200",
-			expected: false,
-		},
-		{
-			name:     "Invalid endpoint",
-			endpoint: "https://invalid-endpoint-that-does-not-exist.com",
-			expected: false,
-		},
-		{
-			name:     "Empty endpoint",
-			endpoint: "",
-			expected: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := validateEndpoint(tt.endpoint)
-			if result != tt.expected {
-				t.Errorf("validateEndpoint(%q) = %v, want %v", tt.endpoint, result, tt.expected)
-			}
-		})
-	}
-}
-
-// TestRandomFunctions tests the random utility functions
-func TestRandomFunctions(t *testing.T) {
-	t.Run("randomInt", func(t *testing.T) {
-		min, max := 1, 10
-		for i := 0; i < 100; i++ {
-			result := randomInt(min, max)
-			if result < min || result >= max {
-				t.Errorf("randomInt(%d, %d) = %d, want value in range [%d, %d)", min, max, result, min, max)
-			}
-		}
-	})
-
-	t.Run("randomElement", func(t *testing.T) {
-		list := []string{"a", "b", "c", "d", "e"}
-		for i := 0; i < 50; i++ {
-			result := randomElement(list)
-			found := false
-			for _, item := range list {
-				if item == result {
-					found = true
-					break
-				}
-			}
-			if !found {
-				t.Errorf("randomElement() returned %q which is not in the list", result)
-			}
-		}
-	})
-
-	t.Run("randomElement empty list", func(t *testing.T) {
-		defer func() {
-			if r := recover(); r == nil {
-				t.Error("Expected panic for empty list")
-			}
-		}()
-		randomElement([]string{})
-	})
-}
-
 // Benchmark tests for performance validation
 func BenchmarkBTCHDWallet_DeriveNextAddress(b *testing.B) {
 	wallet := &BTCHDWallet{
@@ -722,3 +704,66 @@ func BenchmarkBTCHDWallet_pubKeyToAddress(b *testing.B) {
 		}
 	}
 }
+
+// receivedBalances is a ReceivedBalanceSource answering from a map
+type receivedBalances struct {
+	received map[string]float64
+	err      error
+}
+
+func (r receivedBalances) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return r.received[address], r.err
+}
+
+func TestBTCHDWallet_RecoverNextIndex(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 32)
+	reference, err := NewBTCHDWallet(seed, true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet() error = %v", err)
+	}
+	addresses := make([]string, 40)
+	for i := range addresses {
+		if addresses[i], err = reference.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+	}
+	used := func(indexes ...int) map[string]float64 {
+		received := make(map[string]float64)
+		for _, i := range indexes {
+			received[addresses[i]] = 0.001
+		}
+		return received
+	}
+
+	tests := []struct {
+		name     string
+		start    uint32
+		source   receivedBalances
+		gapLimit int
+		want     uint32
+		wantErr  bool
+	}{
+		{"nothing used", 0, receivedBalances{received: used()}, 0, 0, false},
+		{"within the gap", 0, receivedBalances{received: used(0, 3, 19)}, 0, 20, false},
+		{"beyond the gap", 0, receivedBalances{received: used(0, 25)}, 20, 1, false},
+		{"wider gap", 0, receivedBalances{received: used(0, 25)}, 30, 26, false},
+		{"never moves back", 10, receivedBalances{received: used(2)}, 0, 10, false},
+		{"source fails", 5, receivedBalances{err: errors.New("explorer down")}, 0, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewBTCHDWallet(seed, true, 1)
+			if err != nil {
+				t.Fatalf("NewBTCHDWallet() error = %v", err)
+			}
+			w.nextIndex = tt.start
+			got, err := w.RecoverNextIndex(tt.source, tt.gapLimit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecoverNextIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || w.GetNextIndex() != tt.want {
+				t.Errorf("RecoverNextIndex() = %d, next index %d, want %d", got, w.GetNextIndex(), tt.want)
+			}
+		})
+	}
+}
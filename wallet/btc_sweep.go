@@ -0,0 +1,144 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// sweepDustLimit is the smallest output SweepTransaction creates, in
+// satoshis; smaller outputs are not relayed by nodes
+const sweepDustLimit = 546
+
+// ErrSweepTooSmall is returned by SweepTransaction when the inputs do not
+// cover the fee and a relayable output
+var ErrSweepTooSmall = errors.New("inputs do not cover the fee")
+
+// SweepInput is an unspent output paid to one of the wallet's receiving
+// addresses, e.g. from EsploraClient.ListAddressUTXOs
+type SweepInput struct {
+	// TxID and Vout identify the output
+	TxID string
+	Vout uint32
+	// Value is the output's amount in satoshis
+	Value int64
+	// Index is the address index of the receiving address it pays to
+	Index uint32
+}
+
+// sweepInputVBytes is the virtual size of an input of each address type
+// spent with one signature, rounded up
+var sweepInputVBytes = map[BTCAddressType]int64{
+	AddressP2PKH:      148,
+	AddressP2SHP2WPKH: 91,
+	AddressP2WPKH:     68,
+	AddressP2TR:       58,
+}
+
+// SweepTransaction builds and signs a transaction sending the inputs, less
+// the fee, to destination in a single output. The inputs must pay to
+// receiving addresses of the wallet's address type.
+//
+// Parameters:
+//   - inputs: Outputs to spend
+//   - destination: Address receiving the funds, e.g. of a hardware wallet
+//   - satPerVByte: Fee rate
+//
+// Returns:
+//   - *wire.MsgTx: The signed transaction, ready to broadcast
+//   - int64: The fee paid, in satoshis
+//   - error: ErrSweepTooSmall, an invalid destination, or signing errors
+func (w *BTCHDWallet) SweepTransaction(inputs []SweepInput, destination string, satPerVByte int64) (*wire.MsgTx, int64, error) {
+	if len(inputs) == 0 {
+		return nil, 0, errors.New("no inputs to sweep")
+	}
+	if satPerVByte <= 0 {
+		return nil, 0, fmt.Errorf("fee rate must be positive, got %d sat/vB", satPerVByte)
+	}
+	destAddr, err := btcutil.DecodeAddress(destination, w.network)
+	if err != nil || !destAddr.IsForNet(w.network) {
+		return nil, 0, fmt.Errorf("invalid destination address %q for %s", destination, w.network.Name)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("destination script: %w", err)
+	}
+	addressType := w.AddressType()
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut, len(inputs))
+	pkScripts := make([][]byte, len(inputs))
+	var total int64
+	for i, input := range inputs {
+		hash, err := chainhash.NewHashFromStr(input.TxID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("input %d: invalid txid: %w", i, err)
+		}
+		address, err := w.addressAt(input.Index)
+		if err != nil {
+			return nil, 0, err
+		}
+		decoded, err := btcutil.DecodeAddress(address, w.network)
+		if err != nil {
+			return nil, 0, fmt.Errorf("input %d: %w", i, err)
+		}
+		if pkScripts[i], err = txscript.PayToAddrScript(decoded); err != nil {
+			return nil, 0, fmt.Errorf("input %d: %w", i, err)
+		}
+		outPoint := wire.NewOutPoint(hash, input.Vout)
+		tx.AddTxIn(wire.NewTxIn(outPoint, nil, nil))
+		prevOuts[*outPoint] = wire.NewTxOut(input.Value, pkScripts[i])
+		total += input.Value
+	}
+
+	// Version, locktime, counts and the one output, plus the segwit marker
+	fee := (11 + 43 + sweepInputVBytes[addressType]*int64(len(inputs))) * satPerVByte
+	if total-fee < sweepDustLimit {
+		return nil, 0, fmt.Errorf("%w: %d sat in, %d sat fee", ErrSweepTooSmall, total, fee)
+	}
+	tx.AddTxOut(wire.NewTxOut(total-fee, destScript))
+
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts))
+	for i, input := range inputs {
+		privKey, err := w.privateKeyAt(addressType, input.Index)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch addressType {
+		case AddressP2SHP2WPKH:
+			program := append([]byte{0x00, 0x14}, hash160(privKey.PubKey().SerializeCompressed())...)
+			witness, err := txscript.WitnessSignature(tx, sigHashes, i, input.Value, program, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, 0, fmt.Errorf("sign input %d: %w", i, err)
+			}
+			sigScript, err := txscript.NewScriptBuilder().AddData(program).Script()
+			if err != nil {
+				return nil, 0, fmt.Errorf("sign input %d: %w", i, err)
+			}
+			tx.TxIn[i].Witness, tx.TxIn[i].SignatureScript = witness, sigScript
+		case AddressP2WPKH:
+			witness, err := txscript.WitnessSignature(tx, sigHashes, i, input.Value, pkScripts[i], txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, 0, fmt.Errorf("sign input %d: %w", i, err)
+			}
+			tx.TxIn[i].Witness = witness
+		case AddressP2TR:
+			witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, i, input.Value, pkScripts[i], txscript.SigHashDefault, privKey)
+			if err != nil {
+				return nil, 0, fmt.Errorf("sign input %d: %w", i, err)
+			}
+			tx.TxIn[i].Witness = witness
+		default:
+			sigScript, err := txscript.SignatureScript(tx, i, pkScripts[i], txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return nil, 0, fmt.Errorf("sign input %d: %w", i, err)
+			}
+			tx.TxIn[i].SignatureScript = sigScript
+		}
+	}
+	return tx, fee, nil
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBTCHDWallet_SweepTransaction(t *testing.T) {
+	destination := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+	for _, addressType := range []BTCAddressType{AddressP2PKH, AddressP2SHP2WPKH, AddressP2WPKH, AddressP2TR} {
+		t.Run(string(addressType), func(t *testing.T) {
+			w, err := NewBTCHDWallet(bytes.Repeat([]byte{7}, 32), true, 1)
+			if err != nil {
+				t.Fatalf("NewBTCHDWallet() error = %v", err)
+			}
+			if err := w.SetAddressType(addressType); err != nil {
+				t.Fatalf("SetAddressType() error = %v", err)
+			}
+			inputs := []SweepInput{
+				{TxID: strings.Repeat("ab", 32), Vout: 1, Value: 50000, Index: 0},
+				{TxID: strings.Repeat("cd", 32), Vout: 0, Value: 20000, Index: 3},
+			}
+			tx, fee, err := w.SweepTransaction(inputs, destination, 2)
+			if err != nil {
+				t.Fatalf("SweepTransaction() error = %v", err)
+			}
+			if len(tx.TxOut) != 1 || tx.TxOut[0].Value != 70000-fee || fee <= 0 {
+				t.Fatalf("outputs = %+v with fee %d, want one output of 70000 sat less the fee", tx.TxOut, fee)
+			}
+
+			// Every input must pass script verification against the output it spends
+			prevOuts := txscript.NewMultiPrevOutFetcher(nil)
+			for i, input := range inputs {
+				address, _ := w.AddressAt(input.Index)
+				decoded, err := btcutil.DecodeAddress(address, &chaincfg.TestNet3Params)
+				if err != nil {
+					t.Fatalf("DecodeAddress() error = %v", err)
+				}
+				pkScript, _ := txscript.PayToAddrScript(decoded)
+				prevOuts.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(input.Value, pkScript))
+			}
+			sigHashes := txscript.NewTxSigHashes(tx, prevOuts)
+			for i, input := range inputs {
+				prevOut := prevOuts.FetchPrevOutput(tx.TxIn[i].PreviousOutPoint)
+				engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, input.Value, prevOuts)
+				if err != nil {
+					t.Fatalf("NewEngine() error = %v", err)
+				}
+				if err := engine.Execute(); err != nil {
+					t.Errorf("input %d does not verify: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBTCHDWallet_SweepTransaction_Errors(t *testing.T) {
+	w, err := NewBTCHDWallet(bytes.Repeat([]byte{7}, 32), true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet() error = %v", err)
+	}
+	input := []SweepInput{{TxID: strings.Repeat("ab", 32), Value: 1000}}
+	if _, _, err := w.SweepTransaction(input, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", 10); !errors.Is(err, ErrSweepTooSmall) {
+		t.Errorf("dust sweep error = %v, want ErrSweepTooSmall", err)
+	}
+	if _, _, err := w.SweepTransaction(input, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kxv8f3t4", 1); err == nil {
+		t.Error("SweepTransaction() accepted a mainnet destination for a testnet wallet")
+	}
+	if _, _, err := w.SweepTransaction(nil, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", 1); err == nil {
+		t.Error("SweepTransaction() accepted no inputs")
+	}
+}
@@ -2,9 +2,14 @@ package wallet
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/rpcclient"
 )
 
 // TestBTCHDWallet_GetTransactionConfirmations tests the newly implemented GetTransactionConfirmations method
@@ -109,3 +114,120 @@ func TestBTCHDWallet_GetTransactionConfirmations(t *testing.T) {
 		}
 	})
 }
+
+// fakeBitcoind answers the bitcoind RPC calls of BTCHDWallet
+type fakeBitcoind struct {
+	walletTxs map[string]int64 // gettransaction: txid -> confirmations
+	rawTxs    map[string]int64 // getrawtransaction: txid -> confirmations
+	received  []float64        // getreceivedbyaddress: minconf -> BTC
+}
+
+func (f *fakeBitcoind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	var result interface{}
+	var rpcErr interface{}
+	notFound := map[string]interface{}{"code": -5, "message": "Invalid or non-wallet transaction id"}
+	switch req.Method {
+	case "gettransaction", "getrawtransaction":
+		var txID string
+		json.Unmarshal(req.Params[0], &txID)
+		txs := f.walletTxs
+		if req.Method == "getrawtransaction" {
+			txs = f.rawTxs
+		}
+		if confirmations, ok := txs[txID]; ok {
+			result = map[string]interface{}{"txid": txID, "confirmations": confirmations}
+		} else {
+			rpcErr = notFound
+		}
+	case "getreceivedbyaddress":
+		var minConf int
+		json.Unmarshal(req.Params[1], &minConf)
+		result = 0.0
+		if minConf < len(f.received) {
+			result = f.received[minConf]
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result, "error": rpcErr})
+}
+
+// newFakeBitcoindWallet returns a testnet wallet talking to node
+func newFakeBitcoindWallet(t *testing.T, node *fakeBitcoind) *BTCHDWallet {
+	t.Helper()
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("rpcclient.New() error = %v", err)
+	}
+	t.Cleanup(client.Shutdown)
+	return &BTCHDWallet{network: &chaincfg.TestNet3Params, rpcClient: client, minConf: 3}
+}
+
+func TestBTCHDWallet_GetTransactionConfirmations_RPC(t *testing.T) {
+	walletTx := strings.Repeat("ab", 32)
+	rawTx := strings.Repeat("cd", 32)
+	conflicted := strings.Repeat("ef", 32)
+	w := newFakeBitcoindWallet(t, &fakeBitcoind{
+		walletTxs: map[string]int64{walletTx: 2, conflicted: -1},
+		rawTxs:    map[string]int64{rawTx: 5},
+	})
+
+	tests := []struct {
+		name    string
+		txID    string
+		want    int
+		wantErr bool
+	}{
+		{"wallet transaction", walletTx, 2, false},
+		{"other transaction", rawTx, 5, false},
+		{"conflicted", conflicted, 0, true},
+		{"unknown", strings.Repeat("01", 32), 0, true},
+		{"not hex", strings.Repeat("zz", 32), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.GetTransactionConfirmations(tt.txID)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("GetTransactionConfirmations() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBTCHDWallet_GetAddressConfirmations(t *testing.T) {
+	address := "mkpZhYtJu2r87Js3pDiWJDmPte2NRZ8bJV"
+	tests := []struct {
+		name     string
+		received []float64 // by minconf
+		maxConf  int
+		want     int
+	}{
+		{"nothing received", nil, 6, 0},
+		{"unconfirmed", []float64{0.001}, 6, 0},
+		{"two confirmations", []float64{0.001, 0.001, 0.001}, 6, 2},
+		{"topped up later", []float64{0.001, 0.001, 0.0006, 0.0006}, 6, 1},
+		{"capped", []float64{0.001, 0.001, 0.001, 0.001, 0.001}, 3, 3},
+		{"underpaid", []float64{0.0005, 0.0005}, 6, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newFakeBitcoindWallet(t, &fakeBitcoind{received: tt.received})
+			got, err := w.GetAddressConfirmations(address, 0.001, tt.maxConf)
+			if err != nil || got != tt.want {
+				t.Errorf("GetAddressConfirmations() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package wallet
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// extendedPublicKeyVersions maps the version bytes of extended public keys
+// (SLIP-132) to their network and address type
+var extendedPublicKeyVersions = map[uint32]struct {
+	network     *chaincfg.Params
+	addressType BTCAddressType
+}{
+	0x0488b21e: {&chaincfg.MainNetParams, AddressP2PKH},       // xpub
+	0x049d7cb2: {&chaincfg.MainNetParams, AddressP2SHP2WPKH},  // ypub
+	0x04b24746: {&chaincfg.MainNetParams, AddressP2WPKH},      // zpub
+	0x043587cf: {&chaincfg.TestNet3Params, AddressP2PKH},      // tpub
+	0x044a5262: {&chaincfg.TestNet3Params, AddressP2SHP2WPKH}, // upub
+	0x045f1cf6: {&chaincfg.TestNet3Params, AddressP2WPKH},     // vpub
+}
+
+// ChainSource answers the chain queries a watch-only wallet cannot answer
+// itself. *EsploraClient and *BackendRegistry implement it.
+type ChainSource interface {
+	ReceivedBalanceSource
+	// GetTransactionConfirmations returns the confirmations of a transaction,
+	// 0 while it is in the mempool
+	GetTransactionConfirmations(txID string) (int, error)
+}
+
+// BTCWatchWallet is a watch-only Bitcoin wallet: it derives receiving
+// addresses from an account's extended public key and verifies payments with
+// a ChainSource, usually block explorers. The private keys stay in the wallet
+// the key was exported from, which spends the payments.
+//
+// Related: NewBTCWatchWallet, BTCHDWallet
+type BTCWatchWallet struct {
+	external    *hdkeychain.ExtendedKey // Account key of the external chain (account/0)
+	network     *chaincfg.Params        // Network of the extended key
+	addressType BTCAddressType          // Script type selected by the key's version
+	source      ChainSource             // Balance and confirmation queries
+	nextIndex   uint32                  // Next address index to derive
+	mu          sync.RWMutex            // Mutex for thread safety
+	minConf     int                     // Minimum confirmations for balance queries
+}
+
+// Ensure BTCWatchWallet implements HDWallet interface
+var _ HDWallet = (*BTCWatchWallet)(nil)
+
+// NewBTCWatchWallet creates a watch-only wallet from an account-level
+// extended public key, as wallets export it for m/44'/0'/0' (xpub),
+// m/49'/0'/0' (ypub) or m/84'/0'/0' (zpub). The key's version selects the
+// network and address type; tpub, upub and vpub are their testnet versions.
+//
+// Parameters:
+//   - extendedKey: Base58Check encoded extended public key of the account
+//   - source: Answers balance and confirmation queries, e.g. an
+//     *EsploraClient; nil to only derive addresses
+//   - minConf: Minimum confirmations for balance queries
+//
+// Returns:
+//   - *BTCWatchWallet: Wallet deriving account/0/i
+//   - error: If the key is malformed, private or of an unknown version
+//
+// Security:
+//   - Anyone with the key can link the paywall's payments together, so keep
+//     it out of logs and repositories, but it cannot spend them
+//
+// Related: ChainSource, EsploraClient
+func NewBTCWatchWallet(extendedKey string, source ChainSource, minConf int) (*BTCWatchWallet, error) {
+	key, err := hdkeychain.NewKeyFromString(extendedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended public key: %w", err)
+	}
+	if key.IsPrivate() {
+		return nil, errors.New("extended key is private (hint: export the account's xpub, ypub or zpub; private keys do not belong on the server)")
+	}
+	version, ok := extendedPublicKeyVersions[binary.BigEndian.Uint32(key.Version())]
+	if !ok {
+		return nil, fmt.Errorf("unsupported extended public key version %x (hint: use an xpub, ypub or zpub)", key.Version())
+	}
+	external, err := key.Derive(changeExternal)
+	if err != nil {
+		return nil, fmt.Errorf("derive external chain: %w", err)
+	}
+	return &BTCWatchWallet{
+		external:    external,
+		network:     version.network,
+		addressType: version.addressType,
+		source:      source,
+		minConf:     minConf,
+	}, nil
+}
+
+// Network returns "mainnet" or "testnet", the network of the extended key
+func (w *BTCWatchWallet) Network() string {
+	if w.network.Name == chaincfg.MainNetParams.Name {
+		return "mainnet"
+	}
+	return "testnet"
+}
+
+// AddressType returns the script type of the derived addresses
+func (w *BTCWatchWallet) AddressType() BTCAddressType {
+	return w.addressType
+}
+
+// DeriveNextAddress derives the next receiving address, account/0/index
+//
+// Returns:
+//   - string: Address of the key's address type
+//   - error: If key derivation fails
+func (w *BTCWatchWallet) DeriveNextAddress() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	address, err := w.addressAt(w.nextIndex)
+	if err != nil {
+		return "", err
+	}
+	w.nextIndex++
+	return address, nil
+}
+
+// AddressAt derives the receiving address at index without advancing the
+// wallet's next index, for callers that count indexes elsewhere
+func (w *BTCWatchWallet) AddressAt(index uint32) (string, error) {
+	return w.addressAt(index)
+}
+
+// DeriveAddressAt derives the receiving address at index, handed out by a
+// caller that counts indexes elsewhere, and moves the next index past it
+// unless it is already further
+func (w *BTCWatchWallet) DeriveAddressAt(index uint32) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	address, err := w.addressAt(index)
+	if err != nil {
+		return "", err
+	}
+	w.nextIndex = max(w.nextIndex, index+1)
+	return address, nil
+}
+
+// addressAt derives the receiving address at the given index
+func (w *BTCWatchWallet) addressAt(index uint32) (string, error) {
+	child, err := w.external.Derive(index)
+	if err != nil {
+		return "", fmt.Errorf("key derivation failed: %w", err)
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		return "", fmt.Errorf("key derivation failed: %w", err)
+	}
+	return encodeBTCAddress(pubKey, w.addressType, w.network)
+}
+
+// GetAddress returns the next available address
+func (w *BTCWatchWallet) GetAddress() (string, error) {
+	address, err := w.DeriveNextAddress()
+	if err != nil {
+		return "", fmt.Errorf("failed to derive address: %w", err)
+	}
+	return address, nil
+}
+
+// Currency implements HDWallet interface
+func (w *BTCWatchWallet) Currency() string {
+	return string(Bitcoin)
+}
+
+// GetAddressBalance implements paywall.CryptoClient with the wallet's minimum
+// confirmations
+func (w *BTCWatchWallet) GetAddressBalance(address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, w.minConf)
+}
+
+// GetAddressBalanceContext is GetAddressBalance with a context
+func (w *BTCWatchWallet) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(ctx, address, w.minConf)
+}
+
+// GetAddressBalanceMinConf returns what address received in transactions with
+// at least minConf confirmations, as the chain source reports it
+func (w *BTCWatchWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, minConf)
+}
+
+// GetAddressBalanceMinConfContext is GetAddressBalanceMinConf with a context,
+// which cancels the query when the chain source accepts one
+func (w *BTCWatchWallet) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	if w.source == nil {
+		return 0, errors.New("watch-only wallet has no chain source")
+	}
+	if source, ok := w.source.(interface {
+		GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error)
+	}); ok {
+		return source.GetAddressBalanceMinConfContext(ctx, address, minConf)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.source.GetAddressBalanceMinConf(address, minConf)
+}
+
+// GetAddressConfirmations returns how many confirmations the funds paying
+// amount to address have, counting up to maxConf
+func (w *BTCWatchWallet) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	if source, ok := w.source.(interface {
+		GetAddressConfirmations(address string, amount float64, maxConf int) (int, error)
+	}); ok {
+		return source.GetAddressConfirmations(address, amount, maxConf)
+	}
+	return searchConfirmations(func(minConf int) (float64, error) {
+		return w.GetAddressBalanceMinConf(address, minConf)
+	}, amount, maxConf)
+}
+
+// GetTransactionConfirmations returns the confirmations of a transaction as
+// the chain source reports them
+func (w *BTCWatchWallet) GetTransactionConfirmations(txID string) (int, error) {
+	if w.source == nil {
+		return 0, errors.New("watch-only wallet has no chain source")
+	}
+	return w.source.GetTransactionConfirmations(txID)
+}
+
+// RollbackLastAddress decrements the next index counter after a failed payment creation
+func (w *BTCWatchWallet) RollbackLastAddress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nextIndex > 0 {
+		w.nextIndex--
+	}
+}
+
+// SkipUsedAddresses continues address derivation after the highest address
+// in used, so addresses handed out before a restart are not handed out again.
+// The search ends addressGapLimit addresses after the last used one.
+//
+// Parameters:
+//   - used: Addresses handed out before
+//
+// Returns:
+//   - uint32: The next address index
+//   - error: If key derivation fails
+func (w *BTCWatchWallet) SkipUsedAddresses(used map[string]bool) (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next, err := nextUnusedIndex(w.nextIndex, used, w.addressAt)
+	w.nextIndex = next
+	return next, err
+}
+
+// GetNextIndex returns the next address index to be derived
+func (w *BTCWatchWallet) GetNextIndex() uint32 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.nextIndex
+}
+
+// IsMultisigEnabled implements HDWallet; a watch-only wallet has no keys to sign with
+func (w *BTCWatchWallet) IsMultisigEnabled() bool {
+	return false
+}
+
+// GetMultisigConfig implements HDWallet; always returns ErrMultisigNotSupported
+func (w *BTCWatchWallet) GetMultisigConfig() (*MultisigConfig, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// DeriveMultisigAddress implements HDWallet; always returns ErrMultisigNotSupported
+func (w *BTCWatchWallet) DeriveMultisigAddress(pubKeys [][]byte, requiredSigs int) (string, *MultisigMetadata, error) {
+	return "", nil, ErrMultisigNotSupported
+}
+
+// CreateRedeemScript implements HDWallet; always returns ErrMultisigNotSupported
+func (w *BTCWatchWallet) CreateRedeemScript(pubKeys [][]byte, requiredSigs int) ([]byte, error) {
+	return nil, ErrMultisigNotSupported
+}
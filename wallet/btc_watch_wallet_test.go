@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Compile-time checks that explorers and registries can back a watch wallet
+var (
+	_ ChainSource = (*EsploraClient)(nil)
+	_ ChainSource = (*BackendRegistry)(nil)
+)
+
+// stubChainSource answers chain queries from maps
+type stubChainSource struct {
+	received      map[string]float64
+	confirmations map[string]int
+}
+
+func (s stubChainSource) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return s.received[address], nil
+}
+
+func (s stubChainSource) GetTransactionConfirmations(txID string) (int, error) {
+	confirmations, ok := s.confirmations[txID]
+	if !ok {
+		return 0, errors.New("unknown transaction")
+	}
+	return confirmations, nil
+}
+
+func TestNewBTCWatchWallet_Addresses(t *testing.T) {
+	// Account keys of the "abandon ... about" mnemonic from BIP44, BIP49 and
+	// BIP84, with their first receiving addresses
+	tests := []struct {
+		name        string
+		key         string
+		addressType BTCAddressType
+		want        string
+	}{
+		{"xpub", "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj", AddressP2PKH, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{"ypub", "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP", AddressP2SHP2WPKH, "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{"zpub", "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", AddressP2WPKH, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewBTCWatchWallet(tt.key, nil, 1)
+			if err != nil {
+				t.Fatalf("NewBTCWatchWallet() error = %v", err)
+			}
+			if w.AddressType() != tt.addressType || w.Network() != "mainnet" {
+				t.Errorf("NewBTCWatchWallet() = %s on %s, want %s on mainnet", w.AddressType(), w.Network(), tt.addressType)
+			}
+			if got, err := w.DeriveNextAddress(); err != nil || got != tt.want {
+				t.Errorf("DeriveNextAddress() = %s, %v, want %s", got, err, tt.want)
+			}
+			if valid, _ := IsBitcoinAddress(tt.want); !valid {
+				t.Errorf("IsBitcoinAddress(%s) = false", tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBTCWatchWallet_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{"private key", "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi", "extended key is private"},
+		{"malformed", "xpub123", "invalid extended public key"},
+		{"bad checksum", "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdk", "invalid extended public key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBTCWatchWallet(tt.key, nil, 1); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewBTCWatchWallet() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBTCWatchWallet_ChainQueries(t *testing.T) {
+	source := stubChainSource{
+		received:      map[string]float64{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu": 0.002},
+		confirmations: map[string]int{"tx": 3},
+	}
+	w, err := NewBTCWatchWallet("zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", source, 1)
+	if err != nil {
+		t.Fatalf("NewBTCWatchWallet() error = %v", err)
+	}
+	address, _ := w.DeriveNextAddress()
+	if got, err := w.GetAddressBalance(address); err != nil || got != 0.002 {
+		t.Errorf("GetAddressBalance() = %v, %v, want 0.002", got, err)
+	}
+	if got, err := w.GetTransactionConfirmations("tx"); err != nil || got != 3 {
+		t.Errorf("GetTransactionConfirmations() = %d, %v, want 3", got, err)
+	}
+
+	// A restart continues after the addresses handed out before
+	next, _ := w.DeriveNextAddress()
+	restarted, _ := NewBTCWatchWallet("zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", source, 1)
+	if got, err := restarted.SkipUsedAddresses(map[string]bool{next: true}); err != nil || got != 2 {
+		t.Errorf("SkipUsedAddresses() = %d, %v, want 2", got, err)
+	}
+
+	unsourced, _ := NewBTCWatchWallet("zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", nil, 1)
+	if _, err := unsourced.GetAddressBalance(address); err == nil {
+		t.Error("GetAddressBalance() without a chain source succeeded")
+	}
+}
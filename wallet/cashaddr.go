@@ -0,0 +1,155 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// cashAddrCharset is the base32 alphabet used by Bitcoin Cash cashaddr addresses
+const cashAddrCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Cashaddr type bits (upper nibble of the version byte)
+const (
+	cashAddrP2PKH byte = 0
+	cashAddrP2SH  byte = 1
+)
+
+// cashAddrPolymod computes the BCH cashaddr checksum over 5-bit values
+func cashAddrPolymod(values []byte) uint64 {
+	c := uint64(1)
+	for _, d := range values {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		if c0&0x01 != 0 {
+			c ^= 0x98f2bc8e61
+		}
+		if c0&0x02 != 0 {
+			c ^= 0x79b76d99e2
+		}
+		if c0&0x04 != 0 {
+			c ^= 0xf33e5fb3c4
+		}
+		if c0&0x08 != 0 {
+			c ^= 0xae2eabe2a8
+		}
+		if c0&0x10 != 0 {
+			c ^= 0x1e4f43e470
+		}
+	}
+	return c ^ 1
+}
+
+// cashAddrPrefixValues expands the human-readable prefix for checksum computation
+func cashAddrPrefixValues(prefix string) []byte {
+	values := make([]byte, 0, len(prefix)+1)
+	for i := 0; i < len(prefix); i++ {
+		values = append(values, prefix[i]&0x1f)
+	}
+	return append(values, 0)
+}
+
+// convertBits regroups a byte slice from fromBits-wide to toBits-wide values
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc, bits := uint(0), uint(0)
+	maxv := uint(1)<<toBits - 1
+	var out []byte
+	for _, value := range data {
+		if uint(value)>>fromBits != 0 {
+			return nil, errors.New("invalid data range")
+		}
+		acc = acc<<fromBits | uint(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
+
+// EncodeCashAddr encodes a 20-byte hash as a Bitcoin Cash cashaddr address.
+//
+// Parameters:
+//   - prefix: Network prefix ("bitcoincash" or "bchtest")
+//   - addrType: cashAddrP2PKH (0) or cashAddrP2SH (1)
+//   - hash: 20-byte HASH160 of the public key or script
+//
+// Returns:
+//   - string: Address including the prefix, e.g. "bitcoincash:qp..."
+//   - error: If the hash length is not 20 bytes
+func EncodeCashAddr(prefix string, addrType byte, hash []byte) (string, error) {
+	if len(hash) != 20 {
+		return "", fmt.Errorf("cashaddr hash must be 20 bytes, got %d", len(hash))
+	}
+	// Version byte: type in bits 3-6, size code 0 (160 bits) in bits 0-2
+	payload, err := convertBits(append([]byte{addrType << 3}, hash...), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	checksumInput := append(cashAddrPrefixValues(prefix), payload...)
+	checksumInput = append(checksumInput, make([]byte, 8)...)
+	mod := cashAddrPolymod(checksumInput)
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteByte(':')
+	for _, v := range payload {
+		b.WriteByte(cashAddrCharset[v])
+	}
+	for i := 0; i < 8; i++ {
+		b.WriteByte(cashAddrCharset[(mod>>(5*(7-i)))&0x1f])
+	}
+	return b.String(), nil
+}
+
+// DecodeCashAddr decodes and verifies a cashaddr address.
+// The prefix may be omitted, in which case defaultPrefix is assumed.
+//
+// Returns:
+//   - prefix: Network prefix of the address
+//   - addrType: cashAddrP2PKH (0) or cashAddrP2SH (1)
+//   - hash: 20-byte HASH160
+//   - error: If the address is malformed or the checksum does not match
+func DecodeCashAddr(address, defaultPrefix string) (prefix string, addrType byte, hash []byte, err error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return "", 0, nil, errors.New("cashaddr must not mix upper and lower case")
+	}
+	address = strings.ToLower(address)
+	prefix, data, found := strings.Cut(address, ":")
+	if !found {
+		prefix, data = defaultPrefix, address
+	}
+	if len(data) < 8 {
+		return "", 0, nil, errors.New("cashaddr too short")
+	}
+
+	values := make([]byte, len(data))
+	for i := 0; i < len(data); i++ {
+		pos := strings.IndexByte(cashAddrCharset, data[i])
+		if pos < 0 {
+			return "", 0, nil, fmt.Errorf("invalid cashaddr character %q", data[i])
+		}
+		values[i] = byte(pos)
+	}
+	if cashAddrPolymod(append(cashAddrPrefixValues(prefix), values...)) != 0 {
+		return "", 0, nil, errors.New("invalid cashaddr checksum")
+	}
+
+	decoded, err := convertBits(values[:len(values)-8], 5, 8, false)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("decode cashaddr payload: %w", err)
+	}
+	if len(decoded) != 21 || decoded[0]&0x07 != 0 {
+		return "", 0, nil, errors.New("unsupported cashaddr hash size")
+	}
+	return prefix, decoded[0] >> 3, decoded[1:], nil
+}
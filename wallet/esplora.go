@@ -0,0 +1,508 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Public Esplora API base URLs
+const (
+	EsploraMempoolSpace        = "https://mempool.space/api"
+	EsploraMempoolSpaceTestnet = "https://mempool.space/testnet/api"
+	EsploraBlockstream         = "https://blockstream.info/api"
+	EsploraBlockstreamTestnet  = "https://blockstream.info/testnet/api"
+)
+
+const (
+	// defaultEsploraTimeout bounds each request to an explorer
+	defaultEsploraTimeout = 15 * time.Second
+	// esploraChainPageSize is how many confirmed transactions Esplora lists per page
+	esploraChainPageSize = 25
+	// esploraMaxChainPages bounds the transaction history read per address;
+	// payment addresses are used once, so it is only reached by reused addresses
+	esploraMaxChainPages = 40
+	// maxEsploraResponseBytes bounds the size of a single explorer response
+	maxEsploraResponseBytes = 8 << 20
+)
+
+// EsploraConfig selects the Esplora block explorer APIs (mempool.space,
+// blockstream.info or a self-hosted electrs/esplora) Bitcoin payments are
+// verified with.
+type EsploraConfig struct {
+	// URLs are the API base URLs, e.g. "https://mempool.space/api", tried in
+	// order: when one fails, the next one answers. Defaults to mempool.space
+	// and blockstream.info for the network.
+	URLs []string
+	// Timeout bounds each request. Defaults to 15 seconds when zero.
+	Timeout time.Duration
+}
+
+// Validate checks that every URL is an absolute http or https URL
+func (c EsploraConfig) Validate() error {
+	for _, raw := range c.URLs {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("explorer URL %q must be an absolute http or https URL (hint: %s)", raw, EsploraMempoolSpace)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("explorer Timeout must not be negative, got: %v", c.Timeout)
+	}
+	return nil
+}
+
+// EsploraTxStatus is the confirmation state of a transaction
+type EsploraTxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight uint64 `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockTime   int64  `json:"block_time,omitempty"`
+}
+
+// EsploraOutput is a transaction output
+type EsploraOutput struct {
+	// Address is the output's address, empty for non-standard scripts
+	Address string `json:"scriptpubkey_address"`
+	// Value is the output amount in satoshis
+	Value uint64 `json:"value"`
+}
+
+// EsploraTransaction is a transaction from an address history
+type EsploraTransaction struct {
+	TxID   string          `json:"txid"`
+	Vout   []EsploraOutput `json:"vout"`
+	Status EsploraTxStatus `json:"status"`
+}
+
+// EsploraUTXO is an unspent output of an address
+type EsploraUTXO struct {
+	TxID   string          `json:"txid"`
+	Vout   uint32          `json:"vout"`
+	Value  uint64          `json:"value"`
+	Status EsploraTxStatus `json:"status"`
+}
+
+// esploraReceipt is an amount an address received in one transaction
+type esploraReceipt struct {
+	txID          string
+	value         uint64
+	confirmations int
+}
+
+// EsploraClient verifies Bitcoin payments with the Esplora REST API of block
+// explorers instead of a wallet-enabled node. It implements
+// paywall.CryptoClient, MinConfBalanceClient and ConfirmationCountClient, so
+// it works against pruned nodes' explorers and public endpoints alike.
+//
+// Balances are the amounts received by an address, like the node's
+// getreceivedbyaddress: spending from a payment address does not undo the
+// payment.
+//
+// Every query has a ...Context variant; the context cancels the HTTP
+// requests and stops the fallback to the next explorer.
+type EsploraClient struct {
+	client    *http.Client
+	baseURLs  []string
+	network   string
+	minConf   int
+	mu        sync.Mutex
+	preferred int // Index of the URL that answered last
+}
+
+// NewEsploraClient creates a client for the explorers in config.
+//
+// Parameters:
+//   - config: Explorer URLs and timeout
+//   - testnet: Whether addresses and the default URLs are testnet ones
+//   - minConf: Minimum confirmations before funds count towards a balance
+//
+// Returns:
+//   - *EsploraClient: Ready-to-use client; no request is made yet
+//   - error: If config is invalid
+func NewEsploraClient(config EsploraConfig, testnet bool, minConf int) (*EsploraClient, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	urls := config.URLs
+	if len(urls) == 0 {
+		urls = []string{EsploraMempoolSpace, EsploraBlockstream}
+		if testnet {
+			urls = []string{EsploraMempoolSpaceTestnet, EsploraBlockstreamTestnet}
+		}
+	}
+	baseURLs := make([]string, len(urls))
+	for i, u := range urls {
+		baseURLs[i] = strings.TrimRight(u, "/")
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultEsploraTimeout
+	}
+	network := "mainnet"
+	if testnet {
+		network = "testnet"
+	}
+	return &EsploraClient{
+		client:   &http.Client{Timeout: timeout},
+		baseURLs: baseURLs,
+		network:  network,
+		minConf:  minConf,
+	}, nil
+}
+
+// get reads path from the first explorer that answers, starting with the one
+// that answered last
+func (c *EsploraClient) get(ctx context.Context, path string) ([]byte, error) {
+	c.mu.Lock()
+	start := c.preferred
+	c.mu.Unlock()
+
+	var errs []error
+	for i := range c.baseURLs {
+		index := (start + i) % len(c.baseURLs)
+		body, err := c.getFrom(ctx, c.baseURLs[index], path)
+		if err == nil {
+			c.mu.Lock()
+			c.preferred = index
+			c.mu.Unlock()
+			return body, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			// The other explorers would fail the same way
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// getFrom reads path from the explorer at baseURL
+func (c *EsploraClient) getFrom(ctx context.Context, baseURL, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create %s request: %w", path, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("request %s%s: unexpected status %d: %s", baseURL, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEsploraResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s response: %w", path, err)
+	}
+	if len(body) > maxEsploraResponseBytes {
+		return nil, fmt.Errorf("%s response exceeds %d bytes", path, maxEsploraResponseBytes)
+	}
+	return body, nil
+}
+
+// getJSON reads path and decodes it into out
+func (c *EsploraClient) getJSON(ctx context.Context, path string, out interface{}) error {
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// checkAddress rejects invalid addresses and those of the other network
+func (c *EsploraClient) checkAddress(address string) error {
+	valid, network := IsBitcoinAddress(address)
+	if !valid {
+		return fmt.Errorf("invalid bitcoin address format: %s", address)
+	}
+	if network != c.network {
+		return fmt.Errorf("address network mismatch: expected %s, got %s", c.network, network)
+	}
+	return nil
+}
+
+// TipHeight returns the height of the explorer's best block
+func (c *EsploraClient) TipHeight() (uint64, error) {
+	return c.tipHeight(context.Background())
+}
+
+// tipHeight is TipHeight with a context
+func (c *EsploraClient) tipHeight(ctx context.Context) (uint64, error) {
+	body, err := c.get(ctx, "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseUint(strings.TrimSpace(string(body)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("decode tip height: %w", err)
+	}
+	return height, nil
+}
+
+// Name implements BlockchainBackend with the host of the first explorer URL
+func (c *EsploraClient) Name() string {
+	if u, err := url.Parse(c.baseURLs[0]); err == nil && u.Host != "" {
+		return strings.TrimSuffix(u.Host+u.Path, "/")
+	}
+	return c.baseURLs[0]
+}
+
+// HealthCheck implements BlockchainBackend by reading the tip height
+func (c *EsploraClient) HealthCheck() error {
+	_, err := c.TipHeight()
+	return err
+}
+
+// ListAddressTransactions returns the transactions paying or spending from
+// address: the unconfirmed ones first, then the confirmed ones newest first.
+//
+// Parameters:
+//   - address: Bitcoin address of the client's network
+//
+// Returns:
+//   - []EsploraTransaction: Address history
+//   - error: If address is invalid, no explorer answers or the history is
+//     longer than the client reads
+func (c *EsploraClient) ListAddressTransactions(address string) ([]EsploraTransaction, error) {
+	return c.listAddressTransactions(context.Background(), address)
+}
+
+// listAddressTransactions is ListAddressTransactions with a context
+func (c *EsploraClient) listAddressTransactions(ctx context.Context, address string) ([]EsploraTransaction, error) {
+	if err := c.checkAddress(address); err != nil {
+		return nil, err
+	}
+	base := "/address/" + url.PathEscape(address) + "/txs"
+
+	// The first page holds the mempool transactions and the first page of
+	// confirmed ones; later pages continue after the last confirmed txid
+	var txs []EsploraTransaction
+	if err := c.getJSON(ctx, base, &txs); err != nil {
+		return nil, err
+	}
+	page := txs
+	for pages := 0; ; pages++ {
+		var confirmed []EsploraTransaction
+		for _, tx := range page {
+			if tx.Status.Confirmed {
+				confirmed = append(confirmed, tx)
+			}
+		}
+		if len(confirmed) < esploraChainPageSize {
+			return txs, nil
+		}
+		if pages == esploraMaxChainPages {
+			return nil, fmt.Errorf("address %s has more than %d confirmed transactions", address, esploraMaxChainPages*esploraChainPageSize)
+		}
+		page = nil
+		if err := c.getJSON(ctx, base+"/chain/"+confirmed[len(confirmed)-1].TxID, &page); err != nil {
+			return nil, err
+		}
+		txs = append(txs, page...)
+	}
+}
+
+// ListAddressUTXOs returns the unspent outputs of address, including
+// unconfirmed ones
+func (c *EsploraClient) ListAddressUTXOs(address string) ([]EsploraUTXO, error) {
+	if err := c.checkAddress(address); err != nil {
+		return nil, err
+	}
+	var utxos []EsploraUTXO
+	if err := c.getJSON(context.Background(), "/address/"+url.PathEscape(address)+"/utxo", &utxos); err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
+
+// BroadcastTransaction submits a signed transaction to the first explorer
+// that accepts it (POST /tx)
+//
+// Parameters:
+//   - txHex: Hex-encoded transaction
+//
+// Returns:
+//   - string: The transaction ID the explorer returned
+//   - error: If no explorer accepted the transaction
+func (c *EsploraClient) BroadcastTransaction(txHex string) (string, error) {
+	var errs []error
+	for _, baseURL := range c.baseURLs {
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/tx", strings.NewReader(txHex))
+		if err != nil {
+			return "", fmt.Errorf("create /tx request: %w", err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := c.client.Do(req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("request %s/tx: %w", baseURL, err))
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s/tx response: %w", baseURL, err))
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			errs = append(errs, fmt.Errorf("broadcast to %s: status %d: %s", baseURL, resp.StatusCode, strings.TrimSpace(string(body))))
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			// A rejected transaction is rejected by every explorer
+			return "", fmt.Errorf("broadcast rejected by %s: status %d: %s", baseURL, resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return strings.TrimSpace(string(body)), nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// receipts returns what each transaction in address's history paid to it,
+// with its confirmations
+func (c *EsploraClient) receipts(ctx context.Context, address string) ([]esploraReceipt, error) {
+	txs, err := c.listAddressTransactions(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	var tip uint64
+	var receipts []esploraReceipt
+	for _, tx := range txs {
+		var value uint64
+		for _, out := range tx.Vout {
+			if out.Address == address {
+				value += out.Value
+			}
+		}
+		if value == 0 {
+			continue
+		}
+		receipt := esploraReceipt{txID: tx.TxID, value: value}
+		if tx.Status.Confirmed {
+			if tip == 0 {
+				if tip, err = c.tipHeight(ctx); err != nil {
+					return nil, err
+				}
+			}
+			receipt.confirmations = esploraConfirmations(tip, tx.Status.BlockHeight)
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// esploraConfirmations counts the blocks from height to tip, both included
+func esploraConfirmations(tip, height uint64) int {
+	if height == 0 || tip < height {
+		// The tip was read from an explorer that is behind
+		return 1
+	}
+	return int(tip - height + 1)
+}
+
+// GetAddressBalance implements paywall.CryptoClient by summing what address
+// received in transactions with at least the client's minimum confirmations
+func (c *EsploraClient) GetAddressBalance(address string) (float64, error) {
+	return c.GetAddressBalanceMinConfContext(context.Background(), address, c.minConf)
+}
+
+// GetAddressBalanceContext is GetAddressBalance with a context
+func (c *EsploraClient) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	return c.GetAddressBalanceMinConfContext(ctx, address, c.minConf)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only transactions
+// with at least minConf confirmations instead of the client's minimum
+func (c *EsploraClient) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return c.GetAddressBalanceMinConfContext(context.Background(), address, minConf)
+}
+
+// GetAddressBalanceMinConfContext is GetAddressBalanceMinConf with a context
+func (c *EsploraClient) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	receipts, err := c.receipts(ctx, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get address balance: %w", err)
+	}
+	var total uint64
+	for _, r := range receipts {
+		if r.confirmations >= minConf {
+			total += r.value
+		}
+	}
+	return float64(total) / 1e8, nil // Convert satoshis to BTC
+}
+
+// GetAddressConfirmations returns how many confirmations the funds paying
+// amount to address have: the highest count n for which the transactions
+// with at least n confirmations paid address amount.
+//
+// Parameters:
+//   - address: Bitcoin address to check
+//   - amount: Amount in BTC the funds must add up to
+//   - maxConf: Highest count of interest, usually the confirmations required
+//
+// Returns:
+//   - int: Confirmations, 0 while amount has not been received or is unconfirmed
+//   - error: If address is invalid or no explorer answers
+func (c *EsploraClient) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	return c.GetAddressConfirmationsContext(context.Background(), address, amount, maxConf)
+}
+
+// GetAddressConfirmationsContext is GetAddressConfirmations with a context
+func (c *EsploraClient) GetAddressConfirmationsContext(ctx context.Context, address string, amount float64, maxConf int) (int, error) {
+	receipts, err := c.receipts(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].confirmations > receipts[j].confirmations
+	})
+	var total uint64
+	for _, r := range receipts {
+		total += r.value
+		if float64(total)/1e8 >= amount {
+			return min(r.confirmations, maxConf), nil
+		}
+	}
+	return 0, nil
+}
+
+// GetTransactionConfirmations returns the confirmations of a transaction, 0
+// while it is in the mempool
+func (c *EsploraClient) GetTransactionConfirmations(txID string) (int, error) {
+	return c.GetTransactionConfirmationsContext(context.Background(), txID)
+}
+
+// GetTransactionConfirmationsContext is GetTransactionConfirmations with a
+// context
+func (c *EsploraClient) GetTransactionConfirmationsContext(ctx context.Context, txID string) (int, error) {
+	if len(txID) != 64 {
+		return 0, fmt.Errorf("invalid transaction ID length: expected 64 characters, got %d", len(txID))
+	}
+	var status EsploraTxStatus
+	if err := c.getJSON(ctx, "/tx/"+url.PathEscape(txID)+"/status", &status); err != nil {
+		return 0, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	if !status.Confirmed {
+		return 0, nil
+	}
+	tip, err := c.tipHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return esploraConfirmations(tip, status.BlockHeight), nil
+}
@@ -0,0 +1,266 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testEsploraAddress = "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+
+// fakeEsplora serves an address history over the Esplora REST API
+type fakeEsplora struct {
+	tip      uint64
+	txs      []EsploraTransaction // Mempool first, then confirmed newest first
+	requests []string
+}
+
+func (f *fakeEsplora) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.URL.Path)
+	base := "/address/" + testEsploraAddress + "/txs"
+	switch {
+	case r.URL.Path == "/blocks/tip/height":
+		fmt.Fprint(w, f.tip)
+	case r.URL.Path == base:
+		// Mempool transactions and the first page of confirmed ones
+		var page []EsploraTransaction
+		confirmed := 0
+		for _, tx := range f.txs {
+			if tx.Status.Confirmed {
+				if confirmed == esploraChainPageSize {
+					break
+				}
+				confirmed++
+			}
+			page = append(page, tx)
+		}
+		json.NewEncoder(w).Encode(page)
+	case strings.HasPrefix(r.URL.Path, base+"/chain/"):
+		after := strings.TrimPrefix(r.URL.Path, base+"/chain/")
+		page := []EsploraTransaction{}
+		seen := false
+		for _, tx := range f.txs {
+			if seen && len(page) < esploraChainPageSize {
+				page = append(page, tx)
+			}
+			seen = seen || tx.TxID == after
+		}
+		json.NewEncoder(w).Encode(page)
+	case strings.HasPrefix(r.URL.Path, "/tx/"):
+		txID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/tx/"), "/status")
+		for _, tx := range f.txs {
+			if tx.TxID == txID {
+				json.NewEncoder(w).Encode(tx.Status)
+				return
+			}
+		}
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// esploraTx pays sats to the test address, mined at height or unconfirmed at 0
+func esploraTx(txID string, sats, height uint64) EsploraTransaction {
+	return EsploraTransaction{
+		TxID: txID,
+		Vout: []EsploraOutput{
+			{Address: "n2eMqTT929pb1RDNuqEnxdaLau1rxy3efi", Value: 99999},
+			{Address: testEsploraAddress, Value: sats},
+		},
+		Status: EsploraTxStatus{Confirmed: height > 0, BlockHeight: height},
+	}
+}
+
+func TestEsploraClient_Balance(t *testing.T) {
+	explorer := &fakeEsplora{tip: 110, txs: []EsploraTransaction{
+		esploraTx(strings.Repeat("a", 64), 50000, 0),   // mempool
+		esploraTx(strings.Repeat("b", 64), 30000, 109), // 2 confirmations
+		esploraTx(strings.Repeat("c", 64), 20000, 100), // 11 confirmations
+	}}
+	server := httptest.NewServer(explorer)
+	defer server.Close()
+	c, err := NewEsploraClient(EsploraConfig{URLs: []string{server.URL + "/"}}, true, 1)
+	if err != nil {
+		t.Fatalf("NewEsploraClient() error = %v", err)
+	}
+
+	if balance, err := c.GetAddressBalance(testEsploraAddress); err != nil || balance != 0.0005 {
+		t.Errorf("GetAddressBalance() = %v, %v, want 0.0005 (confirmed receipts)", balance, err)
+	}
+	tests := []struct {
+		minConf int
+		want    float64
+	}{
+		{0, 0.001},
+		{2, 0.0005},
+		{3, 0.0002},
+		{12, 0},
+	}
+	for _, tt := range tests {
+		if got, err := c.GetAddressBalanceMinConf(testEsploraAddress, tt.minConf); err != nil || got != tt.want {
+			t.Errorf("GetAddressBalanceMinConf(%d) = %v, %v, want %v", tt.minConf, got, err, tt.want)
+		}
+	}
+
+	confs := []struct {
+		amount  float64
+		maxConf int
+		want    int
+	}{
+		{0.0002, 6, 6},
+		{0.0002, 20, 11},
+		{0.0005, 6, 2},
+		{0.001, 6, 0},
+		{0.002, 6, 0},
+	}
+	for _, tt := range confs {
+		if got, err := c.GetAddressConfirmations(testEsploraAddress, tt.amount, tt.maxConf); err != nil || got != tt.want {
+			t.Errorf("GetAddressConfirmations(%v, %d) = %d, %v, want %d", tt.amount, tt.maxConf, got, err, tt.want)
+		}
+	}
+
+	if got, err := c.GetTransactionConfirmations(strings.Repeat("c", 64)); err != nil || got != 11 {
+		t.Errorf("GetTransactionConfirmations(mined) = %d, %v, want 11", got, err)
+	}
+	if got, err := c.GetTransactionConfirmations(strings.Repeat("a", 64)); err != nil || got != 0 {
+		t.Errorf("GetTransactionConfirmations(mempool) = %d, %v, want 0", got, err)
+	}
+	if _, err := c.GetTransactionConfirmations(strings.Repeat("d", 64)); err == nil {
+		t.Error("GetTransactionConfirmations() of an unknown transaction succeeded")
+	}
+
+	if _, err := c.GetAddressBalance("1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"); err == nil {
+		t.Error("GetAddressBalance() accepted a mainnet address on testnet")
+	}
+}
+
+func TestEsploraClient_History(t *testing.T) {
+	explorer := &fakeEsplora{tip: 1000}
+	for i := range 60 {
+		explorer.txs = append(explorer.txs, esploraTx(fmt.Sprintf("%064d", i), 1000, uint64(900-i)))
+	}
+	server := httptest.NewServer(explorer)
+	defer server.Close()
+	c, _ := NewEsploraClient(EsploraConfig{URLs: []string{server.URL}}, true, 1)
+
+	txs, err := c.ListAddressTransactions(testEsploraAddress)
+	if err != nil || len(txs) != 60 {
+		t.Fatalf("ListAddressTransactions() = %d transactions, %v, want all 60", len(txs), err)
+	}
+	if got := len(explorer.requests); got != 3 {
+		t.Errorf("history read with %d requests, want 3 pages", got)
+	}
+	if balance, _ := c.GetAddressBalance(testEsploraAddress); balance != 0.0006 {
+		t.Errorf("GetAddressBalance() = %v, want 0.0006 across pages", balance)
+	}
+}
+
+func TestEsploraClient_Fallback(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer down.Close()
+	explorer := &fakeEsplora{tip: 100, txs: []EsploraTransaction{esploraTx(strings.Repeat("a", 64), 10000, 100)}}
+	up := httptest.NewServer(explorer)
+	defer up.Close()
+
+	c, _ := NewEsploraClient(EsploraConfig{URLs: []string{down.URL, up.URL}}, true, 1)
+	for range 2 {
+		if balance, err := c.GetAddressBalance(testEsploraAddress); err != nil || balance != 0.0001 {
+			t.Fatalf("GetAddressBalance() = %v, %v, want 0.0001 from the second explorer", balance, err)
+		}
+	}
+	c.mu.Lock()
+	preferred := c.preferred
+	c.mu.Unlock()
+	if preferred != 1 {
+		t.Errorf("preferred explorer = %d, want the one that answered", preferred)
+	}
+
+	down.Close()
+	up.Close()
+	if _, err := c.GetAddressBalance(testEsploraAddress); err == nil {
+		t.Error("GetAddressBalance() succeeded with every explorer down")
+	}
+}
+
+func TestEsploraConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config  EsploraConfig
+		wantErr bool
+	}{
+		{EsploraConfig{}, false},
+		{EsploraConfig{URLs: []string{EsploraMempoolSpace, "http://127.0.0.1:3002"}}, false},
+		{EsploraConfig{URLs: []string{"mempool.space/api"}}, true},
+		{EsploraConfig{URLs: []string{"ftp://example.com"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%v) error = %v, wantErr %v", tt.config.URLs, err, tt.wantErr)
+		}
+	}
+
+	c, _ := NewEsploraClient(EsploraConfig{}, false, 1)
+	if c.baseURLs[0] != EsploraMempoolSpace {
+		t.Errorf("default mainnet explorers = %v", c.baseURLs)
+	}
+}
+
+func TestEsploraClient_Context(t *testing.T) {
+	explorer := &fakeEsplora{tip: 100, txs: []EsploraTransaction{esploraTx(strings.Repeat("a", 64), 10000, 100)}}
+	first := httptest.NewServer(explorer)
+	defer first.Close()
+	second := httptest.NewServer(explorer)
+	defer second.Close()
+	c, _ := NewEsploraClient(EsploraConfig{URLs: []string{first.URL, second.URL}}, true, 1)
+
+	if balance, err := c.GetAddressBalanceContext(context.Background(), testEsploraAddress); err != nil || balance != 0.0001 {
+		t.Fatalf("GetAddressBalanceContext() = %v, %v, want 0.0001", balance, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	explorer.requests = nil
+	if _, err := c.GetAddressBalanceContext(ctx, testEsploraAddress); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAddressBalanceContext() with a done context error = %v, want context.Canceled", err)
+	}
+	if len(explorer.requests) != 0 {
+		t.Errorf("explorers got %d requests after the context was done", len(explorer.requests))
+	}
+}
+
+func TestEsploraClient_BroadcastTransaction(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	var posted string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posted = string(body)
+		if r.Method != http.MethodPost || r.URL.Path != "/tx" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if posted == "bad" {
+			http.Error(w, "sendrawtransaction RPC error: bad-txns", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(strings.Repeat("f", 64)))
+	}))
+	defer up.Close()
+	c, _ := NewEsploraClient(EsploraConfig{URLs: []string{down.URL, up.URL}}, true, 1)
+
+	if txID, err := c.BroadcastTransaction("0200"); err != nil || txID != strings.Repeat("f", 64) || posted != "0200" {
+		t.Errorf("BroadcastTransaction() = %q, %v (posted %q), want the txid from the second explorer", txID, err, posted)
+	}
+	if _, err := c.BroadcastTransaction("bad"); err == nil || !strings.Contains(err.Error(), "bad-txns") {
+		t.Errorf("BroadcastTransaction() error = %v, want the rejection", err)
+	}
+}
@@ -0,0 +1,493 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Ethereum wallet types: Ether and the stablecoins with built-in ERC20Token values
+const (
+	Ethereum WalletType = "ETH"
+	USDC     WalletType = "USDC"
+	USDT     WalletType = "USDT"
+	DAI      WalletType = "DAI"
+)
+
+const (
+	// coinTypeETH is the SLIP-44 coin type of Ethereum
+	coinTypeETH = 60
+	// etherDecimals are the decimal places of Ether (wei)
+	etherDecimals = 18
+	// defaultETHTimeout bounds each JSON-RPC request
+	defaultETHTimeout = 30 * time.Second
+	// erc20BalanceOf is the selector of balanceOf(address)
+	erc20BalanceOf = "70a08231"
+)
+
+// ERC20Token identifies a token contract an ETHHDWallet is paid in
+type ERC20Token struct {
+	// Symbol is the wallet type the token's payments are keyed by, e.g. USDC
+	Symbol WalletType
+	// Contract is the token contract address
+	Contract string
+	// Decimals are the token's decimal places, as returned by decimals()
+	Decimals int
+}
+
+// Ethereum mainnet stablecoins. Testnets and other EVM chains use other
+// contracts: declare an ERC20Token for them.
+var (
+	USDCMainnet = ERC20Token{Symbol: USDC, Contract: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Decimals: 6}
+	USDTMainnet = ERC20Token{Symbol: USDT, Contract: "0xdAC17F958D2ee523a2206206994597C13D831ec7", Decimals: 6}
+	DAIMainnet  = ERC20Token{Symbol: DAI, Contract: "0x6B175474E89094C44Da98b954EedeAC495271d0F", Decimals: 18}
+)
+
+// Validate checks the token definition
+//
+// Returns:
+//   - error: If the symbol is empty, the contract is not an address or the
+//     decimals are out of range
+func (t ERC20Token) Validate() error {
+	if t.Symbol == "" {
+		return errors.New("ERC-20 token has no Symbol")
+	}
+	if !IsEthereumAddress(t.Contract) {
+		return fmt.Errorf("ERC-20 token %s: contract %q is not an Ethereum address", t.Symbol, t.Contract)
+	}
+	if t.Decimals < 0 || t.Decimals > 36 {
+		return fmt.Errorf("ERC-20 token %s: Decimals must be between 0 and 36, got %d", t.Symbol, t.Decimals)
+	}
+	return nil
+}
+
+// ETHRPCConfig configures the Ethereum JSON-RPC endpoint used for balance and
+// confirmation queries: a node (geth, Nethermind, ...) or a provider
+type ETHRPCConfig struct {
+	// URL is the JSON-RPC endpoint, e.g. "http://127.0.0.1:8545"
+	URL string
+	// Timeout bounds each request. Optional: defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// ETHHDWallet is a BIP32/BIP44 HD wallet for Ethereum (m/44'/60'/account'/0/i),
+// paid in Ether or, with a token, in an ERC-20 token. Balances are read with
+// eth_getBalance or the token's balanceOf at the block that gives funds the
+// required confirmations.
+//
+// Wallets for several currencies derived from one seed should use distinct
+// accounts, so no address is handed out for two payments.
+//
+// Related: ERC20Token, NewETHHDWallet
+type ETHHDWallet struct {
+	chainKey  []byte       // Private key of m/44'/60'/account'/0
+	chainCode []byte       // Chain code of m/44'/60'/account'/0
+	account   uint32       // BIP44 account
+	token     *ERC20Token  // Token paid in, nil for Ether
+	nextIndex uint32       // Next address index to derive
+	rpcURL    string       // JSON-RPC endpoint, empty to disable queries
+	client    *http.Client // JSON-RPC client
+	requestID atomic.Int64 // JSON-RPC request IDs
+	mu        sync.RWMutex // Mutex for thread safety
+	minConf   int          // Minimum confirmations for balance queries
+}
+
+// Ensure ETHHDWallet implements HDWallet interface
+var _ HDWallet = (*ETHHDWallet)(nil)
+
+// NewETHHDWallet creates an HD wallet for Ether or an ERC-20 token.
+//
+// Parameters:
+//   - seed: Seed bytes (must be 16-64 bytes); keep it, it controls the funds
+//   - account: BIP44 account, distinct per currency sharing the seed
+//   - token: Token paid in, nil for Ether
+//   - rpc: JSON-RPC endpoint for balances and confirmations (optional; nil disables queries)
+//   - minConf: Minimum confirmations for balance queries
+//
+// Returns:
+//   - *ETHHDWallet: Initialized wallet instance
+//   - error: If the seed length, token or endpoint is invalid
+func NewETHHDWallet(seed []byte, account uint32, token *ERC20Token, rpc *ETHRPCConfig, minConf int) (*ETHHDWallet, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 16 and 64 bytes")
+	}
+	if account >= hardenedKeyStart {
+		return nil, fmt.Errorf("account %d out of range", account)
+	}
+	if token != nil {
+		if err := token.Validate(); err != nil {
+			return nil, err
+		}
+		copied := *token
+		token = &copied
+	}
+	// Derive the external chain once; addresses are its children
+	key, chainCode := masterKeyFromSeed(seed)
+	for _, segment := range []uint32{
+		purposeBIP44 | hardenedKeyStart,
+		coinTypeETH | hardenedKeyStart,
+		account | hardenedKeyStart,
+		changeExternal,
+	} {
+		var err error
+		key, chainCode, err = deriveChildKey(key, chainCode, segment)
+		if err != nil {
+			return nil, fmt.Errorf("key derivation failed: %w", err)
+		}
+	}
+
+	w := &ETHHDWallet{
+		chainKey:  key,
+		chainCode: chainCode,
+		account:   account,
+		token:     token,
+		minConf:   minConf,
+	}
+	if rpc != nil && rpc.URL != "" {
+		u, err := url.Parse(rpc.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("Ethereum RPC URL %q must be an absolute http or https URL", rpc.URL)
+		}
+		timeout := rpc.Timeout
+		if timeout <= 0 {
+			timeout = defaultETHTimeout
+		}
+		w.rpcURL = rpc.URL
+		w.client = &http.Client{Timeout: timeout}
+	}
+	return w, nil
+}
+
+// DeriveNextAddress derives the next address using BIP44 path m/44'/60'/account'/0/index
+//
+// Returns:
+//   - string: EIP-55 checksummed address
+//   - error: If key derivation fails
+func (w *ETHHDWallet) DeriveNextAddress() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	address, err := w.addressAt(w.nextIndex)
+	if err != nil {
+		return "", err
+	}
+	w.nextIndex++
+	return address, nil
+}
+
+// addressAt derives the receiving address at the given index
+func (w *ETHHDWallet) addressAt(index uint32) (string, error) {
+	key, _, err := deriveChildKey(w.chainKey, w.chainCode, index)
+	if err != nil {
+		return "", fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(key)
+	// The address is the last 20 bytes of the Keccak-256 hash of the
+	// uncompressed public key without its 0x04 prefix
+	hash := keccak256(privKey.PubKey().SerializeUncompressed()[1:])
+	return checksumETHAddress(hash[12:]), nil
+}
+
+// GetAddress returns the next available address
+func (w *ETHHDWallet) GetAddress() (string, error) {
+	address, err := w.DeriveNextAddress()
+	if err != nil {
+		return "", fmt.Errorf("failed to derive address: %w", err)
+	}
+	return address, nil
+}
+
+// Currency implements HDWallet interface: ETH, or the token's symbol
+func (w *ETHHDWallet) Currency() string {
+	if w.token != nil {
+		return string(w.token.Symbol)
+	}
+	return string(Ethereum)
+}
+
+// GetAddressBalance returns the balance of address with at least minConf
+// confirmations, in Ether or whole tokens.
+//
+// Parameters:
+//   - address: Ethereum address
+//
+// Returns:
+//   - float64: Balance
+//   - error: If the address is invalid or the RPC query fails
+func (w *ETHHDWallet) GetAddressBalance(address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, w.minConf)
+}
+
+// GetAddressBalanceContext is GetAddressBalance with a context that cancels
+// the RPC requests
+func (w *ETHHDWallet) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(ctx, address, w.minConf)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only funds with at
+// least minConf confirmations instead of the wallet's minimum: the balance is
+// read at the block minConf-1 below the chain head
+func (w *ETHHDWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, minConf)
+}
+
+// GetAddressBalanceMinConfContext is GetAddressBalanceMinConf with a context
+func (w *ETHHDWallet) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	if !IsEthereumAddress(address) {
+		return 0, fmt.Errorf("invalid Ethereum address format: %s", address)
+	}
+	if w.rpcURL == "" {
+		return 0, fmt.Errorf("%s RPC client not configured", w.Currency())
+	}
+
+	block := "latest"
+	if minConf > 1 {
+		head, err := w.blockNumber(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if head < uint64(minConf-1) {
+			return 0, nil
+		}
+		block = "0x" + strconv.FormatUint(head-uint64(minConf-1), 16)
+	}
+
+	var result string
+	decimals := etherDecimals
+	if w.token == nil {
+		if err := w.call(ctx, "eth_getBalance", []interface{}{address, block}, &result); err != nil {
+			return 0, fmt.Errorf("failed to get address balance: %w", err)
+		}
+	} else {
+		data := "0x" + erc20BalanceOf + strings.Repeat("0", 24) + strings.ToLower(address[2:])
+		call := map[string]string{"to": w.token.Contract, "data": data}
+		if err := w.call(ctx, "eth_call", []interface{}{call, block}, &result); err != nil {
+			return 0, fmt.Errorf("failed to get token balance: %w", err)
+		}
+		decimals = w.token.Decimals
+	}
+	units, err := parseQuantity(result)
+	if err != nil {
+		return 0, fmt.Errorf("invalid balance %q: %w", result, err)
+	}
+	balance, _ := new(big.Float).Quo(new(big.Float).SetInt(units), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))).Float64()
+	return balance, nil
+}
+
+// GetTransactionConfirmations returns the confirmations of a transaction:
+// 0 while it is pending, and 0 with an error if it failed
+func (w *ETHHDWallet) GetTransactionConfirmations(txID string) (int, error) {
+	if len(txID) != 66 || !strings.HasPrefix(txID, "0x") {
+		return 0, fmt.Errorf("invalid transaction ID: %s", txID)
+	}
+	if w.rpcURL == "" {
+		return 0, fmt.Errorf("%s RPC client not configured", w.Currency())
+	}
+	var receipt *struct {
+		BlockNumber string `json:"blockNumber"`
+		Status      string `json:"status"`
+	}
+	if err := w.call(context.Background(), "eth_getTransactionReceipt", []interface{}{txID}, &receipt); err != nil {
+		return 0, fmt.Errorf("failed to get transaction receipt: %w", err)
+	}
+	if receipt == nil || receipt.BlockNumber == "" {
+		return 0, nil
+	}
+	if receipt.Status == "0x0" {
+		return 0, fmt.Errorf("transaction %s failed", txID)
+	}
+	included, err := parseQuantity(receipt.BlockNumber)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number %q: %w", receipt.BlockNumber, err)
+	}
+	head, err := w.blockNumber(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if head < included.Uint64() {
+		return 0, nil
+	}
+	return int(head-included.Uint64()) + 1, nil
+}
+
+// blockNumber returns the number of the chain head
+func (w *ETHHDWallet) blockNumber(ctx context.Context) (uint64, error) {
+	var result string
+	if err := w.call(ctx, "eth_blockNumber", []interface{}{}, &result); err != nil {
+		return 0, fmt.Errorf("failed to get block number: %w", err)
+	}
+	number, err := parseQuantity(result)
+	if err != nil || !number.IsUint64() {
+		return 0, fmt.Errorf("invalid block number %q", result)
+	}
+	return number.Uint64(), nil
+}
+
+// call sends a JSON-RPC request and decodes its result into out
+func (w *ETHHDWallet) call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      w.requestID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.rpcURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request %s: unexpected status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var body struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	if body.Error != nil {
+		return fmt.Errorf("%s: RPC error %d: %s", method, body.Error.Code, body.Error.Message)
+	}
+	if err := json.Unmarshal(body.Result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+// RollbackLastAddress decrements the next index counter after a failed payment creation
+func (w *ETHHDWallet) RollbackLastAddress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nextIndex > 0 {
+		w.nextIndex--
+	}
+}
+
+// SkipUsedAddresses continues address derivation after the highest address
+// in used, so addresses handed out before a restart are not handed out again.
+// The search ends addressGapLimit addresses after the last used one.
+//
+// Parameters:
+//   - used: Addresses handed out before, in any letter case
+//
+// Returns:
+//   - uint32: The next address index
+//   - error: If key derivation fails
+func (w *ETHHDWallet) SkipUsedAddresses(used map[string]bool) (uint32, error) {
+	lowered := make(map[string]bool, len(used))
+	for address := range used {
+		lowered[strings.ToLower(address)] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next, err := nextUnusedIndex(w.nextIndex, lowered, func(index uint32) (string, error) {
+		address, err := w.addressAt(index)
+		return strings.ToLower(address), err
+	})
+	w.nextIndex = next
+	return next, err
+}
+
+// GetNextIndex returns the next address index to be derived
+func (w *ETHHDWallet) GetNextIndex() uint32 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.nextIndex
+}
+
+// IsMultisigEnabled implements HDWallet; multisig is not supported on Ethereum
+func (w *ETHHDWallet) IsMultisigEnabled() bool {
+	return false
+}
+
+// GetMultisigConfig implements HDWallet; always returns ErrMultisigNotSupported
+func (w *ETHHDWallet) GetMultisigConfig() (*MultisigConfig, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// DeriveMultisigAddress implements HDWallet; always returns ErrMultisigNotSupported
+func (w *ETHHDWallet) DeriveMultisigAddress(pubKeys [][]byte, requiredSigs int) (string, *MultisigMetadata, error) {
+	return "", nil, ErrMultisigNotSupported
+}
+
+// CreateRedeemScript implements HDWallet; always returns ErrMultisigNotSupported
+func (w *ETHHDWallet) CreateRedeemScript(pubKeys [][]byte, requiredSigs int) ([]byte, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// IsEthereumAddress reports whether address is a 0x-prefixed 20-byte hex
+// address whose EIP-55 checksum, if it has mixed case, is valid
+func IsEthereumAddress(address string) bool {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return false
+	}
+	raw, err := hex.DecodeString(address[2:])
+	if err != nil {
+		return false
+	}
+	digits := address[2:]
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		return true
+	}
+	return checksumETHAddress(raw) == address
+}
+
+// checksumETHAddress encodes a 20-byte address with the EIP-55 mixed-case checksum
+func checksumETHAddress(raw []byte) string {
+	digits := []byte(hex.EncodeToString(raw))
+	hash := keccak256(digits)
+	for i, c := range digits {
+		// Letters whose hash nibble is 8 or more are upper case
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			digits[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(digits)
+}
+
+// parseQuantity decodes a JSON-RPC hex quantity or 32-byte word
+func parseQuantity(s string) (*big.Int, error) {
+	digits := strings.TrimPrefix(s, "0x")
+	if digits == "" {
+		return new(big.Int), nil
+	}
+	n, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, errors.New("not a hex quantity")
+	}
+	return n, nil
+}
@@ -0,0 +1,209 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testETHSeed is the BIP39 seed of "abandon abandon ... about"
+const testETHSeed = "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"
+
+// fakeETHNode is a minimal Ethereum JSON-RPC endpoint for tests
+type fakeETHNode struct {
+	mu       sync.Mutex
+	head     uint64
+	balances map[string]string // block tag -> balance quantity
+	calls    []map[string]interface{}
+	receipts map[string]map[string]string
+}
+
+func (f *fakeETHNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = "0x" + strconv.FormatUint(f.head, 16)
+	case "eth_getBalance", "eth_call":
+		var block string
+		json.Unmarshal(req.Params[1], &block)
+		if req.Method == "eth_call" {
+			var call map[string]interface{}
+			json.Unmarshal(req.Params[0], &call)
+			f.calls = append(f.calls, call)
+		}
+		result = f.balances[block]
+		if result == "" {
+			result = "0x0"
+		}
+	case "eth_getTransactionReceipt":
+		var hash string
+		json.Unmarshal(req.Params[0], &hash)
+		if receipt, ok := f.receipts[hash]; ok {
+			result = receipt
+		}
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32601, "message": "method not found"}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func TestETHHDWallet_DeriveNextAddress(t *testing.T) {
+	seed, _ := hex.DecodeString(testETHSeed)
+	w, err := NewETHHDWallet(seed, 0, nil, nil, 1)
+	if err != nil {
+		t.Fatalf("NewETHHDWallet() error = %v", err)
+	}
+	// m/44'/60'/0'/0/0 and m/44'/60'/0'/0/1 of the BIP39 test mnemonic
+	for _, want := range []string{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94", "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"} {
+		got, err := w.DeriveNextAddress()
+		if err != nil || got != want {
+			t.Errorf("DeriveNextAddress() = %q, %v, want %q", got, err, want)
+		}
+	}
+	w.RollbackLastAddress()
+	if w.GetNextIndex() != 1 {
+		t.Errorf("GetNextIndex() after rollback = %d, want 1", w.GetNextIndex())
+	}
+
+	token, err := NewETHHDWallet(seed, 1, &USDCMainnet, nil, 1)
+	if err != nil {
+		t.Fatalf("NewETHHDWallet(USDC) error = %v", err)
+	}
+	address, _ := token.DeriveNextAddress()
+	if token.Currency() != "USDC" || address == "0x9858EfFD232B4033E47d90003D41EC34EcaEda94" {
+		t.Errorf("token wallet = %s at %s, want USDC on its own account", token.Currency(), address)
+	}
+}
+
+func TestETHHDWallet_SkipUsedAddresses(t *testing.T) {
+	seed, _ := hex.DecodeString(testETHSeed)
+	w, _ := NewETHHDWallet(seed, 0, nil, nil, 1)
+	var addresses []string
+	for i := 0; i < 5; i++ {
+		address, _ := w.DeriveNextAddress()
+		addresses = append(addresses, address)
+	}
+
+	restarted, _ := NewETHHDWallet(seed, 0, nil, nil, 1)
+	// Index 3 was handed out, index 2 was rolled back or its payment deleted
+	used := map[string]bool{addresses[0]: true, addresses[1]: true, strings.ToLower(addresses[3]): true}
+	next, err := restarted.SkipUsedAddresses(used)
+	if err != nil || next != 4 {
+		t.Fatalf("SkipUsedAddresses() = %d, %v, want 4", next, err)
+	}
+	if address, _ := restarted.DeriveNextAddress(); address != addresses[4] {
+		t.Errorf("DeriveNextAddress() after restart = %s, want %s", address, addresses[4])
+	}
+}
+
+func TestETHHDWallet_Balance(t *testing.T) {
+	node := &fakeETHNode{head: 100, balances: map[string]string{
+		"latest": "0x1bc16d674ec80000", // 2 ETH or 2e18 token units
+		"0x62":   "0xde0b6b3a7640000",  // 1 ETH at block 98
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	seed, _ := hex.DecodeString(testETHSeed)
+	address := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+
+	ether, err := NewETHHDWallet(seed, 0, nil, &ETHRPCConfig{URL: server.URL}, 1)
+	if err != nil {
+		t.Fatalf("NewETHHDWallet() error = %v", err)
+	}
+	if balance, err := ether.GetAddressBalance(address); err != nil || balance != 2 {
+		t.Errorf("GetAddressBalance() = %v, %v, want 2", balance, err)
+	}
+	if balance, err := ether.GetAddressBalanceMinConf(address, 3); err != nil || balance != 1 {
+		t.Errorf("GetAddressBalanceMinConf(3) = %v, %v, want 1 (balance at head-2)", balance, err)
+	}
+	if _, err := ether.GetAddressBalance("0x9858effd232b4033e47d90003d41ec34ecaeda9"); err == nil {
+		t.Error("GetAddressBalance() accepted a short address")
+	}
+
+	dai, _ := NewETHHDWallet(seed, 1, &DAIMainnet, &ETHRPCConfig{URL: server.URL}, 1)
+	if balance, err := dai.GetAddressBalance(address); err != nil || balance != 2 {
+		t.Errorf("DAI GetAddressBalance() = %v, %v, want 2", balance, err)
+	}
+	usdt, _ := NewETHHDWallet(seed, 2, &USDTMainnet, &ETHRPCConfig{URL: server.URL}, 1)
+	if balance, err := usdt.GetAddressBalance(address); err != nil || balance != 2e12 {
+		t.Errorf("USDT GetAddressBalance() = %v, %v, want 2e12 (6 decimals)", balance, err)
+	}
+	call := node.calls[len(node.calls)-1]
+	wantData := "0x70a08231000000000000000000000000" + strings.ToLower(address[2:])
+	if call["to"] != USDTMainnet.Contract || call["data"] != wantData {
+		t.Errorf("eth_call = %v, want balanceOf(%s) on %s", call, address, USDTMainnet.Contract)
+	}
+}
+
+func TestETHHDWallet_GetTransactionConfirmations(t *testing.T) {
+	mined := "0x" + strings.Repeat("ab", 32)
+	reverted := "0x" + strings.Repeat("cd", 32)
+	node := &fakeETHNode{head: 100, receipts: map[string]map[string]string{
+		mined:    {"blockNumber": "0x60", "status": "0x1"},
+		reverted: {"blockNumber": "0x60", "status": "0x0"},
+	}}
+	server := httptest.NewServer(node)
+	defer server.Close()
+	seed, _ := hex.DecodeString(testETHSeed)
+	w, _ := NewETHHDWallet(seed, 0, nil, &ETHRPCConfig{URL: server.URL}, 1)
+
+	tests := []struct {
+		name    string
+		txID    string
+		want    int
+		wantErr bool
+	}{
+		{"mined", mined, 5, false},
+		{"pending", "0x" + strings.Repeat("ef", 32), 0, false},
+		{"reverted", reverted, 0, true},
+		{"malformed", "0x1234", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.GetTransactionConfirmations(tt.txID)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("GetTransactionConfirmations() = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEthereumAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94", true},
+		{"0x9858effd232b4033e47d90003d41ec34ecaeda94", true},
+		{"0x9858EFFD232B4033E47D90003D41EC34ECAEDA94", true},
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEdA94", false}, // bad checksum
+		{"9858EfFD232B4033E47d90003D41EC34EcaEda94", false},
+		{"0x9858EfFD232B4033E47d90003D41EC34EcaEda", false},
+		{"0xZZ58EfFD232B4033E47d90003D41EC34EcaEda94", false},
+	}
+	for _, tt := range tests {
+		if got := IsEthereumAddress(tt.address); got != tt.want {
+			t.Errorf("IsEthereumAddress(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+	if err := ValidateAddress(USDC, "0x9858EfFD232B4033E47d90003D41EC34EcaEdA94"); err == nil {
+		t.Error("ValidateAddress(USDC) accepted a bad checksum")
+	}
+}
@@ -62,3 +62,24 @@ const (
 	Bitcoin WalletType = "BTC"
 	Monero  WalletType = "XMR"
 )
+
+// addressGapLimit is how many unused addresses SkipUsedAddresses derives past
+// the last used one before it stops looking
+const addressGapLimit = 1000
+
+// nextUnusedIndex returns the index after the highest address in used,
+// deriving addresses with addressAt until addressGapLimit addresses after the
+// last one found, and at least next
+func nextUnusedIndex(next uint32, used map[string]bool, addressAt func(index uint32) (string, error)) (uint32, error) {
+	for index, found := uint32(0), 0; index < next+addressGapLimit && found < len(used); index++ {
+		address, err := addressAt(index)
+		if err != nil {
+			return next, err
+		}
+		if used[address] {
+			found++
+			next = max(next, index+1)
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,433 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// Lightning is the Lightning Network. Amounts are in BTC like Bitcoin's, with
+// millisatoshi precision.
+const Lightning WalletType = "LN"
+
+// defaultLightningTimeout bounds each request to the Lightning node
+const defaultLightningTimeout = 30 * time.Second
+
+// ErrInvoiceRequired is returned by LightningWallet.DeriveNextAddress:
+// Lightning payments are billed with an invoice for their amount, see
+// InvoiceWallet
+var ErrInvoiceRequired = errors.New("lightning payments need an invoice for their amount")
+
+// LightningBackend selects the REST API a LightningWallet speaks
+type LightningBackend string
+
+const (
+	// LightningLND is LND's REST proxy (lnd --restlisten), authenticated with a macaroon
+	LightningLND LightningBackend = "lnd"
+	// LightningCLN is Core Lightning's clnrest plugin, authenticated with a rune
+	LightningCLN LightningBackend = "cln"
+)
+
+// InvoiceWallet is an HDWallet that bills each payment with an invoice for
+// its amount instead of deriving a reusable address. The paywall stores the
+// invoice as the payment's address, and GetAddressBalance(invoice) reports
+// the amount paid once the invoice is settled.
+type InvoiceWallet interface {
+	HDWallet
+	// CreateInvoice returns a payment request for amount that expires after expiry
+	CreateInvoice(amount float64, expiry time.Duration) (string, error)
+}
+
+// LightningConfig holds the connection details of a Lightning node
+type LightningConfig struct {
+	// Backend is the node software, LightningLND or LightningCLN
+	Backend LightningBackend
+	// URL is the base URL of the node's REST API (e.g. "https://127.0.0.1:8080")
+	URL string
+	// Macaroon is the hex-encoded LND macaroon; an invoice macaroon
+	// (invoice.macaroon) is enough. Required for LightningLND.
+	Macaroon string
+	// Rune is the Core Lightning rune, restricted to the invoice and
+	// listinvoices methods where possible. Required for LightningCLN.
+	Rune string
+	// TLSCertPath is the PEM certificate of the node, for nodes serving a
+	// self-signed certificate (LND's tls.cert). Optional: the system roots
+	// are used when empty.
+	TLSCertPath string
+	// Description is the invoice description shown in the payer's wallet.
+	// Optional: defaults to "Paywall access".
+	Description string
+	// Timeout bounds each request. Optional: defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// Validate checks the configuration without contacting the node
+//
+// Returns:
+//   - error: If the backend is unknown, the URL is not absolute or the
+//     credentials for the backend are missing
+func (c LightningConfig) Validate() error {
+	switch c.Backend {
+	case LightningLND:
+		if _, err := hex.DecodeString(c.Macaroon); err != nil || c.Macaroon == "" {
+			return errors.New("LND needs the hex-encoded Macaroon (hint: xxd -ps -c 1000 invoice.macaroon)")
+		}
+	case LightningCLN:
+		if c.Rune == "" {
+			return errors.New("Core Lightning needs a Rune (hint: lightning-cli createrune)")
+		}
+	default:
+		return fmt.Errorf("unknown Lightning backend %q (hint: use LightningLND or LightningCLN)", c.Backend)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Lightning URL %q must be an absolute http or https URL", c.URL)
+	}
+	return nil
+}
+
+// LightningWallet implements the HDWallet interface on top of a Lightning
+// node's REST API. Each payment gets a BOLT11 invoice for its amount; the
+// invoice is settled, and the payment paid, when the node receives the
+// payment. Settlement is final, so there are no confirmations to wait for.
+type LightningWallet struct {
+	client      *http.Client
+	baseURL     string
+	backend     LightningBackend
+	macaroon    string
+	rune        string
+	description string
+}
+
+// lnAmount decodes millisatoshi amounts, which nodes encode as numbers, JSON
+// strings or (older clnrest) strings with an "msat" suffix
+type lnAmount uint64
+
+func (a *lnAmount) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSuffix(strings.Trim(string(data), `"`), "msat")
+	if s == "" || s == "null" {
+		*a = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s: %w", data, err)
+	}
+	*a = lnAmount(v)
+	return nil
+}
+
+// NewLightningWallet creates a wallet billing payments with invoices of the
+// configured node. The node is asked for its info during construction, which
+// checks the URL and credentials.
+//
+// Parameters:
+//   - config: Node backend, REST URL and credentials
+//
+// Returns:
+//   - *LightningWallet: Ready-to-use wallet
+//   - error: If the configuration is invalid or the node cannot be reached
+func NewLightningWallet(config LightningConfig) (*LightningWallet, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultLightningTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+	if config.TLSCertPath != "" {
+		pem, err := os.ReadFile(config.TLSCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("read Lightning TLS certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", config.TLSCertPath)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		client.Transport = transport
+	}
+	description := config.Description
+	if description == "" {
+		description = "Paywall access"
+	}
+	w := &LightningWallet{
+		client:      client,
+		baseURL:     strings.TrimRight(config.URL, "/"),
+		backend:     config.Backend,
+		macaroon:    config.Macaroon,
+		rune:        config.Rune,
+		description: description,
+	}
+
+	var err error
+	if w.backend == LightningLND {
+		err = w.call(context.Background(), http.MethodGet, "/v1/getinfo", nil, nil)
+	} else {
+		err = w.call(context.Background(), http.MethodPost, "/v1/getinfo", map[string]interface{}{}, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lightning node unreachable: %w", err)
+	}
+	return w, nil
+}
+
+// call sends an authenticated request and decodes the JSON response into out (if non-nil)
+func (w *LightningWallet) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.baseURL+path, payload)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if w.backend == LightningLND {
+		req.Header.Set("Grpc-Metadata-macaroon", w.macaroon)
+	} else {
+		req.Header.Set("Rune", w.rune)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request %s: unexpected status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// Currency implements HDWallet interface
+func (w *LightningWallet) Currency() string {
+	return string(Lightning)
+}
+
+// CreateInvoice implements InvoiceWallet by adding an invoice to the node
+//
+// Parameters:
+//   - amount: Amount in BTC, rounded to the millisatoshi
+//   - expiry: How long the invoice can be paid, usually the payment timeout
+//
+// Returns:
+//   - string: The BOLT11 payment request
+//   - error: If the amount is below one millisatoshi or the node fails
+func (w *LightningWallet) CreateInvoice(amount float64, expiry time.Duration) (string, error) {
+	msat := uint64(math.Round(amount * 1e11))
+	if msat == 0 {
+		return "", fmt.Errorf("invoice amount %.11f BTC is below one millisatoshi", amount)
+	}
+	seconds := int64(math.Ceil(expiry.Seconds()))
+
+	if w.backend == LightningLND {
+		var resp struct {
+			PaymentRequest string `json:"payment_request"`
+		}
+		req := map[string]interface{}{
+			"value_msat": strconv.FormatUint(msat, 10),
+			"memo":       w.description,
+			"expiry":     strconv.FormatInt(seconds, 10),
+		}
+		if err := w.call(context.Background(), http.MethodPost, "/v1/invoices", req, &resp); err != nil {
+			return "", fmt.Errorf("add invoice failed: %w", err)
+		}
+		return resp.PaymentRequest, nil
+	}
+
+	// Core Lightning needs a unique label per invoice
+	label := make([]byte, 16)
+	if _, err := rand.Read(label); err != nil {
+		return "", fmt.Errorf("generate invoice label: %w", err)
+	}
+	var resp struct {
+		Bolt11 string `json:"bolt11"`
+	}
+	req := map[string]interface{}{
+		"amount_msat": msat,
+		"label":       "paywall-" + hex.EncodeToString(label),
+		"description": w.description,
+		"expiry":      seconds,
+	}
+	if err := w.call(context.Background(), http.MethodPost, "/v1/invoice", req, &resp); err != nil {
+		return "", fmt.Errorf("add invoice failed: %w", err)
+	}
+	return resp.Bolt11, nil
+}
+
+// settled looks up the invoice with paymentHash and returns the amount
+// received in millisatoshi, 0 while it is unpaid
+func (w *LightningWallet) settled(ctx context.Context, paymentHash string) (uint64, error) {
+	if w.backend == LightningLND {
+		var resp struct {
+			State       string   `json:"state"`
+			AmtPaidMsat lnAmount `json:"amt_paid_msat"`
+		}
+		if err := w.call(ctx, http.MethodGet, "/v1/invoice/"+paymentHash, nil, &resp); err != nil {
+			return 0, fmt.Errorf("lookup invoice failed: %w", err)
+		}
+		if resp.State != "SETTLED" {
+			return 0, nil
+		}
+		return uint64(resp.AmtPaidMsat), nil
+	}
+
+	var resp struct {
+		Invoices []struct {
+			Status             string   `json:"status"`
+			AmountReceivedMsat lnAmount `json:"amount_received_msat"`
+		} `json:"invoices"`
+	}
+	req := map[string]interface{}{"payment_hash": paymentHash}
+	if err := w.call(ctx, http.MethodPost, "/v1/listinvoices", req, &resp); err != nil {
+		return 0, fmt.Errorf("list invoices failed: %w", err)
+	}
+	if len(resp.Invoices) == 0 {
+		return 0, fmt.Errorf("invoice %s not found", paymentHash)
+	}
+	if resp.Invoices[0].Status != "paid" {
+		return 0, nil
+	}
+	return uint64(resp.Invoices[0].AmountReceivedMsat), nil
+}
+
+// GetAddressBalance implements HDWallet interface for an invoice: the amount
+// paid in BTC once the invoice is settled, 0 until then
+func (w *LightningWallet) GetAddressBalance(invoice string) (float64, error) {
+	return w.GetAddressBalanceContext(context.Background(), invoice)
+}
+
+// GetAddressBalanceContext is GetAddressBalance with a context that cancels
+// the node request
+func (w *LightningWallet) GetAddressBalanceContext(ctx context.Context, invoice string) (float64, error) {
+	hash, err := InvoicePaymentHash(invoice)
+	if err != nil {
+		return 0, err
+	}
+	msat, err := w.settled(ctx, hash)
+	if err != nil {
+		return 0, err
+	}
+	return float64(msat) / 1e11, nil // Convert millisatoshi to BTC
+}
+
+// GetTransactionConfirmations implements HDWallet interface for the payment
+// hash of an invoice: 1 once the invoice is settled, 0 until then
+func (w *LightningWallet) GetTransactionConfirmations(txID string) (int, error) {
+	msat, err := w.settled(context.Background(), txID)
+	if err != nil {
+		return 0, err
+	}
+	if msat == 0 {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// DeriveNextAddress returns ErrInvoiceRequired, see CreateInvoice
+func (w *LightningWallet) DeriveNextAddress() (string, error) {
+	return "", ErrInvoiceRequired
+}
+
+// GetAddress returns ErrInvoiceRequired, see CreateInvoice
+func (w *LightningWallet) GetAddress() (string, error) {
+	return "", ErrInvoiceRequired
+}
+
+// InvoicePaymentHash returns the hex-encoded payment hash of a BOLT11 invoice,
+// which identifies the invoice on the node. The invoice signature is not
+// checked: invoices come from the paywall's own node.
+//
+// Parameters:
+//   - invoice: BOLT11 payment request, optionally prefixed with "lightning:"
+//
+// Returns:
+//   - string: 64 hex characters
+//   - error: If the invoice cannot be decoded or has no payment hash
+func InvoicePaymentHash(invoice string) (string, error) {
+	invoice = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(invoice)), "lightning:")
+	hrp, data, err := bech32.DecodeNoLimit(invoice)
+	if err != nil {
+		return "", fmt.Errorf("decode invoice: %w", err)
+	}
+	if !strings.HasPrefix(hrp, "ln") {
+		return "", fmt.Errorf("not a Lightning invoice: prefix %q", hrp)
+	}
+	// 35-bit timestamp, tagged fields, 520-bit signature (5-bit words)
+	const timestampWords, signatureWords = 7, 104
+	if len(data) < timestampWords+signatureWords {
+		return "", errors.New("invoice too short")
+	}
+	fields := data[timestampWords : len(data)-signatureWords]
+	for len(fields) >= 3 {
+		tag, length := fields[0], int(fields[1])<<5|int(fields[2])
+		if len(fields) < 3+length {
+			return "", errors.New("invoice field overruns the invoice")
+		}
+		// 'p' (1) is the payment hash, 52 words for 256 bits
+		if tag == 1 && length == 52 {
+			hash, err := bech32.ConvertBits(fields[3:3+length], 5, 8, false)
+			if err != nil {
+				return "", fmt.Errorf("decode payment hash: %w", err)
+			}
+			return hex.EncodeToString(hash), nil
+		}
+		fields = fields[3+length:]
+	}
+	return "", errors.New("invoice has no payment hash")
+}
+
+// Multisig operations do not apply to Lightning invoices
+
+// IsMultisigEnabled always returns false for Lightning wallets
+func (w *LightningWallet) IsMultisigEnabled() bool {
+	return false
+}
+
+// GetMultisigConfig returns ErrMultisigNotSupported
+func (w *LightningWallet) GetMultisigConfig() (*MultisigConfig, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// DeriveMultisigAddress returns ErrMultisigNotSupported
+func (w *LightningWallet) DeriveMultisigAddress(pubKeys [][]byte, requiredSigs int) (string, *MultisigMetadata, error) {
+	return "", nil, ErrMultisigNotSupported
+}
+
+// CreateRedeemScript returns ErrMultisigNotSupported
+func (w *LightningWallet) CreateRedeemScript(pubKeys [][]byte, requiredSigs int) ([]byte, error) {
+	return nil, ErrMultisigNotSupported
+}
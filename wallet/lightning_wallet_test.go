@@ -0,0 +1,180 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+const (
+	testMacaroon = "0201036c6e64"
+	testRune     = "test-rune"
+)
+
+// testInvoice encodes an unsigned BOLT11 invoice with paymentHash
+func testInvoice(t *testing.T, paymentHash []byte) string {
+	t.Helper()
+	words := make([]byte, 7) // timestamp
+	// A description field before the payment hash, which must be skipped
+	words = append(words, 13, 0, 2, 1, 2)
+	hashWords, err := bech32.ConvertBits(paymentHash, 8, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words = append(words, 1, byte(len(hashWords)>>5), byte(len(hashWords)&31))
+	words = append(words, hashWords...)
+	words = append(words, make([]byte, 104)...) // signature
+	invoice, err := bech32.Encode("lntb10u", words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return invoice
+}
+
+// fakeLightningNode is a minimal LND and Core Lightning REST API for tests
+type fakeLightningNode struct {
+	t        *testing.T
+	mu       sync.Mutex
+	invoices map[string]uint64 // payment hash -> amount in msat
+	paid     map[string]bool
+	created  []map[string]interface{}
+}
+
+func (f *fakeLightningNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Grpc-Metadata-macaroon") != testMacaroon && r.Header.Get("Rune") != testRune {
+		http.Error(w, "permission denied", http.StatusUnauthorized)
+		return
+	}
+	var req map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	switch {
+	case r.URL.Path == "/v1/getinfo":
+		json.NewEncoder(w).Encode(map[string]interface{}{"alias": "test"})
+	case r.URL.Path == "/v1/invoices" || r.URL.Path == "/v1/invoice":
+		f.created = append(f.created, req)
+		hash := bytes.Repeat([]byte{byte(len(f.created))}, 32)
+		invoice := testInvoice(f.t, hash)
+		f.invoices[hex.EncodeToString(hash)] = 0
+		json.NewEncoder(w).Encode(map[string]string{"payment_request": invoice, "bolt11": invoice})
+	case strings.HasPrefix(r.URL.Path, "/v1/invoice/"):
+		hash := strings.TrimPrefix(r.URL.Path, "/v1/invoice/")
+		resp := map[string]interface{}{"state": "OPEN", "amt_paid_msat": "0"}
+		if f.paid[hash] {
+			resp = map[string]interface{}{"state": "SETTLED", "amt_paid_msat": "100000000"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.URL.Path == "/v1/listinvoices":
+		hash, _ := req["payment_hash"].(string)
+		invoice := map[string]interface{}{"status": "unpaid"}
+		if f.paid[hash] {
+			invoice = map[string]interface{}{"status": "paid", "amount_received_msat": "100000000msat"}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"invoices": []interface{}{invoice}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestLightningWallet(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LightningConfig
+		amount string
+	}{
+		{"lnd", LightningConfig{Backend: LightningLND, Macaroon: testMacaroon}, "value_msat"},
+		{"core lightning", LightningConfig{Backend: LightningCLN, Rune: testRune}, "amount_msat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &fakeLightningNode{t: t, invoices: map[string]uint64{}, paid: map[string]bool{}}
+			server := httptest.NewServer(node)
+			defer server.Close()
+			tt.config.URL = server.URL
+
+			w, err := NewLightningWallet(tt.config)
+			if err != nil {
+				t.Fatalf("NewLightningWallet() error = %v", err)
+			}
+			if _, err := w.DeriveNextAddress(); err != ErrInvoiceRequired {
+				t.Errorf("DeriveNextAddress() error = %v, want ErrInvoiceRequired", err)
+			}
+			invoice, err := w.CreateInvoice(0.001, time.Hour)
+			if err != nil {
+				t.Fatalf("CreateInvoice() error = %v", err)
+			}
+			if got := node.created[0][tt.amount]; got != "100000000" && got != float64(100000000) {
+				t.Errorf("invoice amount = %v, want 100000000 msat", got)
+			}
+
+			if balance, err := w.GetAddressBalance(invoice); err != nil || balance != 0 {
+				t.Fatalf("unpaid balance = %v, %v, want 0", balance, err)
+			}
+			hash, _ := InvoicePaymentHash(invoice)
+			node.paid[hash] = true
+			if balance, err := w.GetAddressBalance(invoice); err != nil || balance != 0.001 {
+				t.Errorf("settled balance = %v, %v, want 0.001", balance, err)
+			}
+			if confirmations, err := w.GetTransactionConfirmations(hash); err != nil || confirmations != 1 {
+				t.Errorf("confirmations = %d, %v, want 1", confirmations, err)
+			}
+		})
+	}
+}
+
+func TestLightningConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LightningConfig
+		wantErr string
+	}{
+		{"lnd", LightningConfig{Backend: LightningLND, URL: "https://127.0.0.1:8080", Macaroon: testMacaroon}, ""},
+		{"cln", LightningConfig{Backend: LightningCLN, URL: "http://127.0.0.1:3010", Rune: testRune}, ""},
+		{"unknown backend", LightningConfig{Backend: "eclair", URL: "https://127.0.0.1:8080"}, "unknown Lightning backend"},
+		{"macaroon not hex", LightningConfig{Backend: LightningLND, URL: "https://127.0.0.1:8080", Macaroon: "xyz"}, "Macaroon"},
+		{"no rune", LightningConfig{Backend: LightningCLN, URL: "https://127.0.0.1:3010"}, "Rune"},
+		{"relative url", LightningConfig{Backend: LightningCLN, URL: "/node", Rune: testRune}, "absolute"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvoicePaymentHash(t *testing.T) {
+	hash := bytes.Repeat([]byte{0xab}, 32)
+	invoice := testInvoice(t, hash)
+	tests := []struct {
+		name    string
+		invoice string
+		want    string
+		wantErr bool
+	}{
+		{"invoice", invoice, hex.EncodeToString(hash), false},
+		{"uppercase uri", "LIGHTNING:" + strings.ToUpper(invoice), hex.EncodeToString(hash), false},
+		{"bitcoin address", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", "", true},
+		{"garbage", "lntb1garbage", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InvoicePaymentHash(tt.invoice)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("InvoicePaymentHash() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
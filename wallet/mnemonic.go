@@ -2,12 +2,18 @@
 package wallet
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"strings"
 
 	"github.com/tyler-smith/go-bip39"
 )
 
+// ErrNoMnemonic is returned by ExportMnemonic for wallets not created from a
+// mnemonic
+var ErrNoMnemonic = errors.New("wallet was not created from a mnemonic (hint: back it up with ExportExtendedKey)")
+
 // MnemonicStrength represents the entropy strength for mnemonic generation.
 type MnemonicStrength int
 
@@ -53,12 +59,23 @@ func GenerateMnemonic(strength MnemonicStrength) (string, error) {
 		return "", err
 	}
 
-	mnemonic, err := bip39.NewMnemonic(entropy)
-	if err != nil {
-		return "", err
-	}
+	return NewMnemonic(entropy)
+}
 
-	return mnemonic, nil
+// NewMnemonic encodes entropy as a BIP39 mnemonic phrase, for entropy from a
+// source of your own. GenerateMnemonic reads it from crypto/rand.
+//
+// Parameters:
+//   - entropy: 16 bytes for 12 words or 32 bytes for 24 words (or 20, 24, 28
+//     bytes for 15, 18, 21 words)
+//
+// Returns:
+//   - string: Space-separated mnemonic phrase
+//   - error: If the entropy length is not allowed by BIP39
+//
+// Related: GenerateMnemonic
+func NewMnemonic(entropy []byte) (string, error) {
+	return bip39.NewMnemonic(entropy)
 }
 
 // ImportFromMnemonic converts a BIP39 mnemonic phrase to a seed suitable for wallet creation.
@@ -83,15 +100,11 @@ func GenerateMnemonic(strength MnemonicStrength) (string, error) {
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
-//	wallet, err := NewBTCHDWallet(seed[:32], testnet, 1)
+//	wallet, err := NewBTCHDWallet(seed, testnet, 1)
 //
 // Related: GenerateMnemonic, ValidateMnemonic
 func ImportFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
-	// Normalize whitespace: trim and collapse multiple spaces
-	mnemonic = strings.TrimSpace(mnemonic)
-	words := strings.Fields(mnemonic)
-	mnemonic = strings.Join(words, " ")
-
+	mnemonic = normalizeMnemonic(mnemonic)
 	if !bip39.IsMnemonicValid(mnemonic) {
 		return nil, errors.New("invalid mnemonic: checksum failed or unrecognized word")
 	}
@@ -124,11 +137,13 @@ func ImportFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
 //
 // Related: GenerateMnemonic, ImportFromMnemonic
 func ValidateMnemonic(mnemonic string) bool {
-	// Normalize whitespace: trim and collapse multiple spaces
-	mnemonic = strings.TrimSpace(mnemonic)
-	words := strings.Fields(mnemonic)
-	mnemonic = strings.Join(words, " ")
-	return bip39.IsMnemonicValid(mnemonic)
+	return bip39.IsMnemonicValid(normalizeMnemonic(mnemonic))
+}
+
+// normalizeMnemonic trims a mnemonic and collapses the whitespace between its
+// words to single spaces
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(mnemonic), " ")
 }
 
 // MnemonicToSeed converts a validated mnemonic to a seed without passphrase.
@@ -147,7 +162,7 @@ func ValidateMnemonic(mnemonic string) bool {
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
-//	wallet, err := NewBTCHDWallet(seed[:32], false, 6)
+//	wallet, err := NewBTCHDWallet(seed, false, 6)
 //
 // Related: ImportFromMnemonic
 func MnemonicToSeed(mnemonic string) ([]byte, error) {
@@ -171,6 +186,15 @@ func MnemonicToSeed(mnemonic string) ([]byte, error) {
 //   - Supports BIP39 passphrase (25th word)
 //   - Creates deterministic wallet (same mnemonic → same addresses)
 //
+// Compatibility:
+//   - Keys are derived from the full 64-byte BIP39 seed, so any BIP44 wallet
+//     (Electrum, Sparrow, hardware wallets) restoring the mnemonic with the
+//     path m/44'/0'/0' finds the same addresses and can sweep their funds
+//   - Wallets created from a mnemonic by earlier versions used the first 32
+//     bytes of the seed and get different addresses here; restore those with
+//     NewLegacyBTCHDWalletFromMnemonic
+//   - The wallet keeps the mnemonic, not the passphrase, for ExportMnemonic
+//
 // Usage:
 //
 //	wallet, err := NewBTCHDWalletFromMnemonic(
@@ -180,12 +204,102 @@ func MnemonicToSeed(mnemonic string) ([]byte, error) {
 //	    1,     // 1 confirmation
 //	)
 //
-// Related: GenerateMnemonic, ImportFromMnemonic, NewBTCHDWallet
+// Related: GenerateMnemonic, ImportFromMnemonic, NewBTCHDWallet, ExportMnemonic
 func NewBTCHDWalletFromMnemonic(mnemonic, passphrase string, testnet bool, minConf int) (*BTCHDWallet, error) {
 	seed, err := ImportFromMnemonic(mnemonic, passphrase)
 	if err != nil {
 		return nil, err
 	}
 
+	w, err := NewBTCHDWallet(seed, testnet, minConf)
+	if err != nil {
+		return nil, err
+	}
+	w.mnemonic = normalizeMnemonic(mnemonic)
+	return w, nil
+}
+
+// NewLegacyBTCHDWalletFromMnemonic restores a wallet that
+// NewBTCHDWalletFromMnemonic created before it derived keys from the full
+// BIP39 seed: the master key comes from the first 32 bytes of the seed only.
+// Use it to reach the addresses, and the funds, of such a wallet; create new
+// wallets with NewBTCHDWalletFromMnemonic.
+//
+// Parameters:
+//   - mnemonic: Space-separated BIP39 mnemonic phrase
+//   - passphrase: BIP39 passphrase the wallet was created with (can be empty)
+//   - testnet: Boolean flag for testnet/mainnet network selection
+//   - minConf: Minimum confirmations required for balance queries
+//
+// Returns:
+//   - *BTCHDWallet: The restored wallet
+//   - error: If mnemonic is invalid or wallet creation fails
+//
+// Compatibility:
+//   - BIP44 wallets restoring the mnemonic do not find these addresses, so
+//     the wallet does not keep the mnemonic and ExportMnemonic returns
+//     ErrNoMnemonic; back it up with ExportExtendedKey, or sweep its funds
+//
+// Related: NewBTCHDWalletFromMnemonic
+func NewLegacyBTCHDWalletFromMnemonic(mnemonic, passphrase string, testnet bool, minConf int) (*BTCHDWallet, error) {
+	seed, err := ImportFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
 	return NewBTCHDWallet(seed[:32], testnet, minConf)
 }
+
+// ExportMnemonic returns the BIP39 mnemonic the wallet was created from, to
+// back it up or to restore it in another BIP44 wallet. The mnemonic is kept
+// by SaveToFile; the passphrase is not and must be backed up separately.
+//
+// Returns:
+//   - string: Space-separated mnemonic phrase
+//   - error: ErrNoMnemonic for wallets created from a seed
+//
+// Security:
+//   - The mnemonic spends every payment the wallet received; never log it
+//
+// Related: NewBTCHDWalletFromMnemonic, ExportExtendedKey
+func (w *BTCHDWallet) ExportMnemonic() (string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.mnemonic == "" {
+		return "", ErrNoMnemonic
+	}
+	return w.mnemonic, nil
+}
+
+// ExportExtendedKey returns the wallet's BIP32 master private key as an
+// extended key (xprv on mainnet, tprv on testnet), which BIP32 wallets such
+// as Electrum and Sparrow import. It backs up wallets created from a seed,
+// which have no mnemonic. The addresses are found at m/<purpose>'/0'/0'/0/i,
+// where purpose follows the wallet's BTCAddressType: 44 for AddressP2PKH, 49
+// for AddressP2SHP2WPKH, 84 for AddressP2WPKH and 86 for AddressP2TR.
+//
+// Returns:
+//   - string: Base58Check encoded extended private key
+//
+// Security:
+//   - The key spends every payment the wallet received; never log it
+//
+// Related: ExportMnemonic
+func (w *BTCHDWallet) ExportExtendedKey() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	// version || depth 0 || parent fingerprint 0 || child number 0 ||
+	// chain code || 0x00 || key
+	data := make([]byte, 0, 82)
+	data = append(data, w.network.HDPrivateKeyID[:]...)
+	data = append(data, 0)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = append(data, w.chainCode...)
+	data = append(data, 0)
+	data = append(data, w.masterKey...)
+
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return Base58Encode(append(data, second[:4]...))
+}
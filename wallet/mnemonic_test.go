@@ -1,6 +1,9 @@
 package wallet
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -324,3 +327,122 @@ func TestMnemonic_WhitespaceHandling(t *testing.T) {
 		t.Error("Whitespace handling produced different seeds")
 	}
 }
+
+func TestNewBTCHDWalletFromMnemonic_BIP44Vector(t *testing.T) {
+	// The first receiving address of the "abandon ... about" mnemonic in every
+	// BIP44 wallet
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	w, err := NewBTCHDWalletFromMnemonic(mnemonic, "", false, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+	}
+	if addr, err := w.DeriveNextAddress(); err != nil || addr != "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA" {
+		t.Errorf("DeriveNextAddress() = %s, %v, want 1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", addr, err)
+	}
+}
+
+func TestNewLegacyBTCHDWalletFromMnemonic(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	seed, err := ImportFromMnemonic(mnemonic, "secret")
+	if err != nil {
+		t.Fatalf("ImportFromMnemonic failed: %v", err)
+	}
+	// How NewBTCHDWalletFromMnemonic created wallets before
+	earlier, err := NewBTCHDWallet(seed[:32], false, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet failed: %v", err)
+	}
+	legacy, err := NewLegacyBTCHDWalletFromMnemonic(mnemonic, "secret", false, 1)
+	if err != nil {
+		t.Fatalf("NewLegacyBTCHDWalletFromMnemonic failed: %v", err)
+	}
+	current, err := NewBTCHDWalletFromMnemonic(mnemonic, "secret", false, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+	}
+
+	want, _ := earlier.AddressAt(0)
+	if got, _ := legacy.AddressAt(0); got != want {
+		t.Errorf("legacy address = %s, want %s", got, want)
+	}
+	if got, _ := current.AddressAt(0); got == want {
+		t.Error("full seed derivation gave the legacy address")
+	}
+	if _, err := legacy.ExportMnemonic(); !errors.Is(err, ErrNoMnemonic) {
+		t.Errorf("ExportMnemonic() error = %v, want ErrNoMnemonic", err)
+	}
+	if _, err := NewLegacyBTCHDWalletFromMnemonic("abandon about", "", false, 1); err == nil {
+		t.Error("NewLegacyBTCHDWalletFromMnemonic accepted an invalid mnemonic")
+	}
+}
+
+func TestBTCHDWallet_ExportMnemonic(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	fromMnemonic, err := NewBTCHDWalletFromMnemonic("  "+strings.ReplaceAll(mnemonic, " ", "  ")+"\n", "secret", true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+	}
+	fromSeed, err := NewBTCHDWallet(bytes.Repeat([]byte{1}, 32), true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet failed: %v", err)
+	}
+
+	config := StorageConfig{DataDir: t.TempDir(), EncryptionKey: bytes.Repeat([]byte{2}, 32)}
+	if err := fromMnemonic.SaveToFile(config); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	loaded, err := LoadFromFile(config)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		wallet  *BTCHDWallet
+		want    string
+		wantErr error
+	}{
+		{"from mnemonic", fromMnemonic, mnemonic, nil},
+		{"saved and loaded", loaded, mnemonic, nil},
+		{"from seed", fromSeed, "", ErrNoMnemonic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.wallet.ExportMnemonic()
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("ExportMnemonic() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// The exported mnemonic and the passphrase restore the same wallet
+	restored, err := NewBTCHDWalletFromMnemonic(mnemonic, "secret", true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWalletFromMnemonic failed: %v", err)
+	}
+	want, _ := fromMnemonic.DeriveNextAddress()
+	if got, _ := restored.DeriveNextAddress(); got != want {
+		t.Errorf("restored wallet derived %s, want %s", got, want)
+	}
+}
+
+func TestBTCHDWallet_ExportExtendedKey(t *testing.T) {
+	// BIP32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	w, err := NewBTCHDWallet(seed, false, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet failed: %v", err)
+	}
+	want := "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	if got := w.ExportExtendedKey(); got != want {
+		t.Errorf("ExportExtendedKey() = %s, want %s", got, want)
+	}
+
+	testnet, err := NewBTCHDWallet(seed, true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet failed: %v", err)
+	}
+	if got := testnet.ExportExtendedKey(); !strings.HasPrefix(got, "tprv") {
+		t.Errorf("ExportExtendedKey() on testnet = %s, want a tprv key", got)
+	}
+}
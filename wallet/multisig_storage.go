@@ -20,15 +20,19 @@ import (
 //   - DataDir: Directory path where multisig wallet files will be stored
 //   - EncryptionKey: 32-byte key used for AES-256-GCM encryption (optional)
 //   - WalletType: The type of wallet (Bitcoin, Monero)
+//   - DirMode: Mode of DataDir when created (optional, default 0700)
+//   - FileMode: Mode of the wallet files (optional, default 0600)
 //
 // Security:
-//   - DataDir should have appropriate filesystem permissions (0700)
+//   - Modes are applied exactly, regardless of the process umask
 //   - EncryptionKey is optional but recommended for production
 //   - If EncryptionKey is nil, data is stored in plaintext JSON
 type MultisigStorageConfig struct {
 	DataDir       string
 	EncryptionKey []byte     // Optional: 32-byte key for AES-256
 	WalletType    WalletType // BTC or XMR
+	DirMode       os.FileMode
+	FileMode      os.FileMode
 }
 
 // MultisigWalletData contains the serializable state of a multisig wallet.
@@ -126,7 +130,8 @@ func (s *MultisigStorage) SaveMultisigWallet(data *MultisigWalletData) error {
 	}
 
 	// Ensure directory exists
-	if err := os.MkdirAll(s.config.DataDir, 0o700); err != nil {
+	dirMode, fileMode := StorageConfig{DirMode: s.config.DirMode, FileMode: s.config.FileMode}.modes()
+	if err := MkdirMode(s.config.DataDir, dirMode); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
@@ -136,7 +141,7 @@ func (s *MultisigStorage) SaveMultisigWallet(data *MultisigWalletData) error {
 
 	// Atomic write: write to temp file, then rename
 	tempPath := filePath + ".tmp"
-	if err := os.WriteFile(tempPath, finalData, 0o600); err != nil {
+	if err := WriteFileMode(tempPath, finalData, fileMode); err != nil {
 		return fmt.Errorf("failed to write temporary file: %w", err)
 	}
 
@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+const (
+	// DefaultDirMode is the default mode of directories holding wallets,
+	// payments and keys: accessible by the owner only
+	DefaultDirMode os.FileMode = 0o700
+	// DefaultFileMode is the default mode of wallet, payment and key files:
+	// readable and writable by the owner only
+	DefaultFileMode os.FileMode = 0o600
+)
+
+// PermissionProblem describes a file or directory that is accessible more
+// broadly than its configured mode allows
+type PermissionProblem struct {
+	// Path is the offending file or directory
+	Path string
+	// Mode is the permission bits found on Path
+	Mode os.FileMode
+	// Want is the configured mode
+	Want os.FileMode
+	// Repaired reports whether Path was changed to Want
+	Repaired bool
+}
+
+// String describes the problem for log messages
+func (p *PermissionProblem) String() string {
+	if p.Repaired {
+		return fmt.Sprintf("%s had mode %04o, more permissive than %04o; permissions tightened", p.Path, p.Mode, p.Want)
+	}
+	return fmt.Sprintf("%s has mode %04o, more permissive than %04o; run chmod %04o %s", p.Path, p.Mode, p.Want, p.Want, p.Path)
+}
+
+// CheckPermissions reports whether path grants permission bits outside mode,
+// such as group or world access to a file that should be private. With repair
+// the path is changed to mode. Permission bits are not checked on Windows,
+// where they do not control access.
+//
+// Parameters:
+//   - path: File or directory to check
+//   - mode: Maximum permission bits path may have
+//   - repair: Change path to mode when it is too permissive
+//
+// Returns:
+//   - *PermissionProblem: Description of the problem, nil if path is missing or fine
+//   - error: If path cannot be inspected or repaired
+//
+// Related: DefaultDirMode, DefaultFileMode
+func CheckPermissions(path string, mode os.FileMode, repair bool) (*PermissionProblem, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("check permissions: %w", err)
+	}
+	perm := info.Mode().Perm()
+	if perm&^mode.Perm() == 0 {
+		return nil, nil
+	}
+	problem := &PermissionProblem{Path: path, Mode: perm, Want: mode.Perm()}
+	if repair {
+		if err := os.Chmod(path, mode.Perm()); err != nil {
+			return problem, fmt.Errorf("repair permissions of %s: %w", path, err)
+		}
+		problem.Repaired = true
+	}
+	return problem, nil
+}
+
+// MkdirMode creates dir and any missing parents like os.MkdirAll, then sets
+// dir itself to mode when it was created, so the result does not depend on
+// the process umask
+//
+// Parameters:
+//   - dir: Directory to create
+//   - mode: Permission bits of dir
+//
+// Returns:
+//   - error: If creating the directory or setting its mode fails
+func MkdirMode(dir string, mode os.FileMode) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return os.Chmod(dir, mode.Perm())
+}
+
+// WriteFileMode writes data to path like os.WriteFile and sets path to mode,
+// so the result does not depend on the process umask or the mode of a file
+// it replaces
+//
+// Parameters:
+//   - path: File to write
+//   - data: File contents
+//   - mode: Permission bits of the file
+//
+// Returns:
+//   - error: If writing the file or setting its mode fails
+func WriteFileMode(path string, data []byte, mode os.FileMode) error {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return os.Chmod(path, mode.Perm())
+}
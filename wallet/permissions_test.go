@@ -0,0 +1,86 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	tests := []struct {
+		name       string
+		mode       os.FileMode
+		want       os.FileMode
+		repair     bool
+		wantIssue  bool
+		wantResult os.FileMode
+	}{
+		{"private file", 0o600, 0o600, false, false, 0o600},
+		{"stricter than configured", 0o400, 0o600, false, false, 0o400},
+		{"world readable, warn only", 0o644, 0o600, false, true, 0o644},
+		{"world readable, repaired", 0o644, 0o600, true, true, 0o600},
+		{"group shared store", 0o640, 0o640, true, false, 0o640},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wallet.dat")
+			if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(path, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			problem, err := CheckPermissions(path, tt.want, tt.repair)
+			if err != nil {
+				t.Fatalf("CheckPermissions() error = %v", err)
+			}
+			if (problem != nil) != tt.wantIssue {
+				t.Errorf("CheckPermissions() = %v, want problem %v", problem, tt.wantIssue)
+			}
+			if problem != nil && problem.Repaired != tt.repair {
+				t.Errorf("Repaired = %v, want %v", problem.Repaired, tt.repair)
+			}
+			info, _ := os.Stat(path)
+			if got := info.Mode().Perm(); got != tt.wantResult {
+				t.Errorf("mode = %04o, want %04o", got, tt.wantResult)
+			}
+		})
+	}
+
+	if problem, err := CheckPermissions(filepath.Join(t.TempDir(), "missing"), 0o600, true); problem != nil || err != nil {
+		t.Errorf("CheckPermissions(missing) = %v, %v, want nil, nil", problem, err)
+	}
+}
+
+func TestStorageConfig_Modes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	dataDir := filepath.Join(t.TempDir(), "wallet")
+	key := []byte("test_key_32_bytes_long__________")
+	w := &BTCHDWallet{masterKey: make([]byte, 32), chainCode: make([]byte, 32)}
+
+	// A group-shared wallet keeps its modes even under a restrictive umask
+	if err := w.SaveToFile(StorageConfig{DataDir: dataDir, EncryptionKey: key, DirMode: 0o750, FileMode: 0o640}); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+	for path, want := range map[string]os.FileMode{dataDir: 0o750, filepath.Join(dataDir, "wallet.dat"): 0o640} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s mode = %v, %v, want %04o", path, info.Mode().Perm(), err, want)
+		}
+	}
+
+	// Loading with the default modes and RepairPermissions tightens them
+	if _, err := LoadFromFile(StorageConfig{DataDir: dataDir, EncryptionKey: key, RepairPermissions: true}); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	for path, want := range map[string]os.FileMode{dataDir: DefaultDirMode, filepath.Join(dataDir, "wallet.dat"): DefaultFileMode} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s mode after repair = %v, %v, want %04o", path, info.Mode().Perm(), err, want)
+		}
+	}
+}
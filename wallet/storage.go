@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -19,13 +20,33 @@ import (
 // Fields:
 //   - DataDir: Directory path where wallet files will be stored
 //   - EncryptionKey: 32-byte key used for AES-256 encryption
+//   - DirMode: Mode of DataDir when created (optional, default 0700)
+//   - FileMode: Mode of wallet.dat (optional, default 0600)
+//   - RepairPermissions: Tighten DataDir and wallet.dat when LoadFromFile finds
+//     them more permissive than DirMode and FileMode (optional, default is to
+//     log a warning only)
 //
 // Security:
-//   - DataDir should have appropriate filesystem permissions
+//   - Modes are applied exactly, regardless of the process umask
 //   - EncryptionKey must be securely generated and stored
 type StorageConfig struct {
-	DataDir       string
-	EncryptionKey []byte // 32-byte key for AES-256
+	DataDir           string
+	EncryptionKey     []byte // 32-byte key for AES-256
+	DirMode           os.FileMode
+	FileMode          os.FileMode
+	RepairPermissions bool
+}
+
+// modes returns the configured directory and file modes with defaults applied
+func (c StorageConfig) modes() (dirMode, fileMode os.FileMode) {
+	dirMode, fileMode = c.DirMode, c.FileMode
+	if dirMode == 0 {
+		dirMode = DefaultDirMode
+	}
+	if fileMode == 0 {
+		fileMode = DefaultFileMode
+	}
+	return dirMode, fileMode
 }
 
 // SaveToFile encrypts and saves the wallet to a file.
@@ -39,7 +60,11 @@ type StorageConfig struct {
 // Security:
 //   - Uses AES-256-GCM for encryption
 //   - Generates random nonce for each save
-//   - Sets restrictive file permissions (0600)
+//   - Stores the mnemonic of wallets created from one, as sensitive as the
+//     keys themselves
+//   - Sets restrictive file permissions (0600 unless configured)
+//   - Replaces wallet.dat atomically, so a crash while saving leaves the
+//     previous wallet intact
 //
 // Related: LoadFromFile
 func (w *BTCHDWallet) SaveToFile(config StorageConfig) error {
@@ -49,11 +74,13 @@ func (w *BTCHDWallet) SaveToFile(config StorageConfig) error {
 		return errors.New("encryption key must be 32 bytes")
 	}
 
-	// Prepare wallet data for encryption
-	data := make([]byte, len(w.masterKey)+len(w.chainCode)+4)
+	// Prepare wallet data for encryption; the mnemonic follows the index, so
+	// earlier versions still read the keys
+	data := make([]byte, len(w.masterKey)+len(w.chainCode)+4, len(w.masterKey)+len(w.chainCode)+4+len(w.mnemonic))
 	copy(data, w.masterKey)
 	copy(data[len(w.masterKey):], w.chainCode)
 	binary.BigEndian.PutUint32(data[len(w.masterKey)+len(w.chainCode):], w.nextIndex)
+	data = append(data, w.mnemonic...)
 
 	// Create AES cipher
 	block, err := aes.NewCipher(config.EncryptionKey)
@@ -80,13 +107,22 @@ func (w *BTCHDWallet) SaveToFile(config StorageConfig) error {
 	finalData := append(nonce, ciphertext...)
 
 	// Ensure directory exists
-	if err := os.MkdirAll(config.DataDir, 0o700); err != nil {
+	dirMode, fileMode := config.modes()
+	if err := MkdirMode(config.DataDir, dirMode); err != nil {
 		return err
 	}
 
-	// Write to file
+	// Atomic write: write to temp file, then rename
 	filePath := filepath.Join(config.DataDir, "wallet.dat")
-	return os.WriteFile(filePath, finalData, 0o600)
+	tempPath := filePath + ".tmp"
+	if err := WriteFileMode(tempPath, finalData, fileMode); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		os.Remove(tempPath) // Clean up temp file on error
+		return err
+	}
+	return nil
 }
 
 // LoadFromFile loads and decrypts a wallet from a file.
@@ -102,6 +138,8 @@ func (w *BTCHDWallet) SaveToFile(config StorageConfig) error {
 //   - Validates data integrity using AES-GCM authentication
 //   - Verifies minimum data length requirements
 //   - Returns errors for any decryption failures
+//   - Logs a warning when DataDir or wallet.dat is more permissive than
+//     configured, and tightens them with RepairPermissions
 //
 // Related: SaveToFile
 func LoadFromFile(config StorageConfig) (*BTCHDWallet, error) {
@@ -111,6 +149,19 @@ func LoadFromFile(config StorageConfig) (*BTCHDWallet, error) {
 
 	// Read encrypted data
 	filePath := filepath.Join(config.DataDir, "wallet.dat")
+	dirMode, fileMode := config.modes()
+	for _, check := range []struct {
+		path string
+		mode os.FileMode
+	}{{config.DataDir, dirMode}, {filePath, fileMode}} {
+		problem, err := CheckPermissions(check.path, check.mode, config.RepairPermissions)
+		if problem != nil {
+			log.Printf("Warning: %s", problem)
+		}
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -155,8 +206,44 @@ func LoadFromFile(config StorageConfig) (*BTCHDWallet, error) {
 
 	copy(w.masterKey, plaintext[:32])
 	copy(w.chainCode, plaintext[32:64])
-	w.nextIndex = binary.BigEndian.Uint32(plaintext[64:])
+	w.nextIndex = binary.BigEndian.Uint32(plaintext[64:68])
+	if mnemonic := string(plaintext[68:]); mnemonic != "" {
+		if !ValidateMnemonic(mnemonic) {
+			return nil, errors.New("invalid wallet data: corrupt mnemonic")
+		}
+		w.mnemonic = mnemonic
+	}
+
+	return w, nil
+}
 
+// LoadBTCHDWallet loads a wallet saved with SaveToFile and connects it to the
+// Bitcoin node on localhost, as NewBTCHDWallet does. Unlike LoadFromFile,
+// whose wallet is a mainnet wallet without a node, it can verify payments.
+//
+// Parameters:
+//   - config: StorageConfig containing storage location and encryption key
+//   - testnet: Boolean flag for testnet/mainnet network selection
+//   - minConf: Minimum confirmations for balance queries
+//
+// Returns:
+//   - *BTCHDWallet: Wallet continuing at the saved next address index
+//   - error: As LoadFromFile; errors.Is(err, fs.ErrNotExist) when no wallet
+//     has been saved yet
+//
+// Related: SaveToFile, RecoverNextIndex
+func LoadBTCHDWallet(config StorageConfig, testnet bool, minConf int) (*BTCHDWallet, error) {
+	w, err := LoadFromFile(config)
+	if err != nil {
+		return nil, err
+	}
+	if testnet {
+		w.network = &chaincfg.TestNet3Params
+	}
+	if w.rpcClient, err = newLocalNodeClient(testnet); err != nil {
+		return nil, err
+	}
+	w.minConf = minConf
 	return w, nil
 }
 
@@ -3,7 +3,9 @@ package wallet
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -504,3 +506,39 @@ func TestEncryptionSecurity(t *testing.T) {
 		t.Error("Decrypted next indices should be identical")
 	}
 }
+
+func TestLoadBTCHDWallet(t *testing.T) {
+	config := StorageConfig{DataDir: t.TempDir(), EncryptionKey: []byte("valid_32_byte_encryption_key____")}
+
+	if _, err := LoadBTCHDWallet(config, true, 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("LoadBTCHDWallet() without a wallet error = %v, want fs.ErrNotExist", err)
+	}
+
+	original, err := NewBTCHDWallet(bytes.Repeat([]byte{3}, 32), true, 2)
+	if err != nil {
+		t.Fatalf("NewBTCHDWallet() error = %v", err)
+	}
+	for range 3 {
+		if _, err := original.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+	}
+	if err := original.SaveToFile(config); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.DataDir, "wallet.dat.tmp")); !os.IsNotExist(err) {
+		t.Errorf("SaveToFile() left its temporary file behind: %v", err)
+	}
+
+	loaded, err := LoadBTCHDWallet(config, true, 2)
+	if err != nil {
+		t.Fatalf("LoadBTCHDWallet() error = %v", err)
+	}
+	if loaded.network != &chaincfg.TestNet3Params || loaded.minConf != 2 || loaded.rpcClient == nil {
+		t.Errorf("LoadBTCHDWallet() = network %s, minConf %d, node client %v", loaded.network.Name, loaded.minConf, loaded.rpcClient != nil)
+	}
+	want, _ := original.DeriveNextAddress()
+	if got, err := loaded.DeriveNextAddress(); err != nil || got != want {
+		t.Errorf("DeriveNextAddress() after load = %s, %v, want %s", got, err, want)
+	}
+}
@@ -0,0 +1,419 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Additional Bitcoin-derived wallet types served by UTXOHDWallet
+const (
+	Litecoin    WalletType = "LTC"
+	BitcoinCash WalletType = "BCH"
+	Dogecoin    WalletType = "DOGE"
+)
+
+// UTXOChainParams describes a Bitcoin-derived chain for UTXOHDWallet:
+// its BIP44 coin type and address encoding.
+type UTXOChainParams struct {
+	// Type is the wallet type reported by Currency()
+	Type WalletType
+	// Network is "mainnet" or "testnet", matching the address validators
+	Network string
+	// CoinType is the SLIP-44 coin type used in m/44'/coin'/0'/0/i
+	CoinType uint32
+	// PubKeyHashAddrID is the base58check version byte for P2PKH addresses
+	PubKeyHashAddrID byte
+	// ScriptHashAddrID is the base58check version byte for P2SH addresses
+	ScriptHashAddrID byte
+	// CashAddrPrefix, when set, encodes addresses as cashaddr (Bitcoin Cash)
+	CashAddrPrefix string
+	// Validate checks that an address belongs to this chain, returning its network
+	Validate func(address string) (bool, string)
+}
+
+var (
+	// LitecoinMainNetParams are the Litecoin mainnet parameters (addresses start with L)
+	LitecoinMainNetParams = UTXOChainParams{
+		Type: Litecoin, Network: "mainnet", CoinType: 2,
+		PubKeyHashAddrID: 0x30, ScriptHashAddrID: 0x32,
+		Validate: IsLitecoinAddress,
+	}
+	// LitecoinTestNetParams are the Litecoin testnet parameters (addresses start with m or n)
+	LitecoinTestNetParams = UTXOChainParams{
+		Type: Litecoin, Network: "testnet", CoinType: 1,
+		PubKeyHashAddrID: 0x6f, ScriptHashAddrID: 0x3a,
+		Validate: IsLitecoinAddress,
+	}
+	// BitcoinCashMainNetParams are the Bitcoin Cash mainnet parameters (cashaddr "bitcoincash:")
+	BitcoinCashMainNetParams = UTXOChainParams{
+		Type: BitcoinCash, Network: "mainnet", CoinType: 145,
+		PubKeyHashAddrID: 0x00, ScriptHashAddrID: 0x05,
+		CashAddrPrefix: "bitcoincash", Validate: IsBitcoinCashAddress,
+	}
+	// BitcoinCashTestNetParams are the Bitcoin Cash testnet parameters (cashaddr "bchtest:")
+	BitcoinCashTestNetParams = UTXOChainParams{
+		Type: BitcoinCash, Network: "testnet", CoinType: 1,
+		PubKeyHashAddrID: 0x6f, ScriptHashAddrID: 0xc4,
+		CashAddrPrefix: "bchtest", Validate: IsBitcoinCashAddress,
+	}
+	// DogecoinMainNetParams are the Dogecoin mainnet parameters (addresses start with D)
+	DogecoinMainNetParams = UTXOChainParams{
+		Type: Dogecoin, Network: "mainnet", CoinType: 3,
+		PubKeyHashAddrID: 0x1e, ScriptHashAddrID: 0x16,
+		Validate: IsDogecoinAddress,
+	}
+	// DogecoinTestNetParams are the Dogecoin testnet parameters (addresses start with n)
+	DogecoinTestNetParams = UTXOChainParams{
+		Type: Dogecoin, Network: "testnet", CoinType: 1,
+		PubKeyHashAddrID: 0x71, ScriptHashAddrID: 0xc4,
+		Validate: IsDogecoinAddress,
+	}
+)
+
+// UTXOChainParamsFor returns the built-in parameters for a wallet type and network
+//
+// Returns:
+//   - *UTXOChainParams: Copy of the matching parameters
+//   - error: If the wallet type is not served by UTXOHDWallet
+func UTXOChainParamsFor(walletType WalletType, testnet bool) (*UTXOChainParams, error) {
+	var params UTXOChainParams
+	switch {
+	case walletType == Litecoin && testnet:
+		params = LitecoinTestNetParams
+	case walletType == Litecoin:
+		params = LitecoinMainNetParams
+	case walletType == BitcoinCash && testnet:
+		params = BitcoinCashTestNetParams
+	case walletType == BitcoinCash:
+		params = BitcoinCashMainNetParams
+	case walletType == Dogecoin && testnet:
+		params = DogecoinTestNetParams
+	case walletType == Dogecoin:
+		params = DogecoinMainNetParams
+	default:
+		return nil, fmt.Errorf("no UTXO chain parameters for %s", walletType)
+	}
+	return &params, nil
+}
+
+// UTXORPCConfig configures the node RPC used for balance and confirmation queries
+type UTXORPCConfig struct {
+	// Host is the node RPC address, e.g. "localhost:8332" (bitcoind-compatible API)
+	Host string
+	// User and Pass authenticate against the node
+	User string
+	Pass string
+	// DisableTLS connects over plain HTTP (local nodes only)
+	DisableTLS bool
+}
+
+// UTXOHDWallet is a BIP32/BIP44 HD wallet for Bitcoin-derived chains
+// (Litecoin, Bitcoin Cash, Dogecoin, ...) that share Bitcoin's key derivation and differ
+// only in coin type and address encoding.
+//
+// Related: UTXOChainParams, NewUTXOHDWallet, BTCHDWallet
+type UTXOHDWallet struct {
+	masterKey []byte            // Master private key
+	chainCode []byte            // Master chain code for key derivation
+	params    UTXOChainParams   // Chain parameters
+	nextIndex uint32            // Next address index to derive
+	rpcClient *rpcclient.Client // Optional node RPC client
+	mu        sync.RWMutex      // Mutex for thread safety
+	minConf   int               // Minimum confirmations for balance queries
+}
+
+// Ensure UTXOHDWallet implements HDWallet interface
+var _ HDWallet = (*UTXOHDWallet)(nil)
+
+// NewUTXOHDWallet creates an HD wallet for a Bitcoin-derived chain.
+//
+// Parameters:
+//   - seed: Random seed bytes (must be 16-64 bytes)
+//   - params: Chain parameters, e.g. &DogecoinMainNetParams
+//   - rpc: Node RPC for balances and confirmations (optional; nil disables queries)
+//   - minConf: Minimum confirmations for balance queries
+//
+// Returns:
+//   - *UTXOHDWallet: Initialized wallet instance
+//   - error: If the seed length is invalid or the RPC client cannot be created
+func NewUTXOHDWallet(seed []byte, params *UTXOChainParams, rpc *UTXORPCConfig, minConf int) (*UTXOHDWallet, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 16 and 64 bytes")
+	}
+	if params == nil {
+		return nil, errors.New("chain parameters are required")
+	}
+	masterKey, chainCode := masterKeyFromSeed(seed)
+
+	w := &UTXOHDWallet{
+		masterKey: masterKey,
+		chainCode: chainCode,
+		params:    *params,
+		minConf:   minConf,
+	}
+	if rpc != nil && rpc.Host != "" {
+		client, err := rpcclient.New(&rpcclient.ConnConfig{
+			Host:         rpc.Host,
+			User:         rpc.User,
+			Pass:         rpc.Pass,
+			HTTPPostMode: true,
+			DisableTLS:   rpc.DisableTLS,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create %s RPC client: %w", params.Type, err)
+		}
+		w.rpcClient = client
+	}
+	return w, nil
+}
+
+// DeriveNextAddress derives the next address using BIP44 path m/44'/coin'/0'/0/index
+//
+// Returns:
+//   - string: Address in the chain's native encoding (cashaddr or base58check)
+//   - error: If key derivation or address encoding fails
+func (w *UTXOHDWallet) DeriveNextAddress() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	address, err := w.addressAt(w.nextIndex)
+	if err != nil {
+		return "", err
+	}
+	w.nextIndex++
+	return address, nil
+}
+
+// addressAt derives the receiving address at the given index
+func (w *UTXOHDWallet) addressAt(index uint32) (string, error) {
+	path := []uint32{
+		purposeBIP44 | hardenedKeyStart,
+		w.params.CoinType | hardenedKeyStart,
+		accountDefault | hardenedKeyStart,
+		changeExternal,
+		index,
+	}
+	key, chainCode := w.masterKey, w.chainCode
+	for _, segment := range path {
+		var err error
+		key, chainCode, err = deriveChildKey(key, chainCode, segment)
+		if err != nil {
+			return "", fmt.Errorf("key derivation failed: %w", err)
+		}
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(key)
+	pubKeyHash := hash160(privKey.PubKey().SerializeCompressed())
+	if w.params.CashAddrPrefix != "" {
+		return EncodeCashAddr(w.params.CashAddrPrefix, cashAddrP2PKH, pubKeyHash)
+	}
+	return base58CheckEncode(w.params.PubKeyHashAddrID, pubKeyHash), nil
+}
+
+// GetAddress returns the next available address
+func (w *UTXOHDWallet) GetAddress() (string, error) {
+	address, err := w.DeriveNextAddress()
+	if err != nil {
+		return "", fmt.Errorf("failed to derive address: %w", err)
+	}
+	return address, nil
+}
+
+// Currency implements HDWallet interface
+func (w *UTXOHDWallet) Currency() string {
+	return string(w.params.Type)
+}
+
+// GetAddressBalance returns the amount received by address with at least
+// minConf confirmations, in whole coins.
+//
+// Parameters:
+//   - address: Address on this wallet's chain and network
+//
+// Returns:
+//   - float64: Received amount (coins, 8 decimal places)
+//   - error: If the address is invalid for this chain or the RPC query fails
+func (w *UTXOHDWallet) GetAddressBalance(address string) (float64, error) {
+	return w.GetAddressBalanceMinConf(address, w.minConf)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only funds with at
+// least minConf confirmations instead of the wallet's minimum
+func (w *UTXOHDWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	valid, network := w.params.Validate(address)
+	if !valid {
+		return 0, fmt.Errorf("invalid %s address format: %s", w.params.Type, address)
+	}
+	if network != w.params.Network {
+		return 0, fmt.Errorf("address network mismatch: expected %s, got %s", w.params.Network, network)
+	}
+	if w.rpcClient == nil {
+		return 0, fmt.Errorf("%s RPC client not configured", w.params.Type)
+	}
+
+	received, err := w.rpcClient.GetReceivedByAddressMinConf(Address(address), minConf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get address balance: %w", err)
+	}
+	return received.ToBTC(), nil
+}
+
+// GetTransactionConfirmations returns the confirmation count of a transaction
+// as reported by the node's getrawtransaction RPC
+func (w *UTXOHDWallet) GetTransactionConfirmations(txID string) (int, error) {
+	hash, err := chainhash.NewHashFromStr(txID)
+	if err != nil || len(txID) != 64 {
+		return 0, fmt.Errorf("invalid transaction ID: %s", txID)
+	}
+	if w.rpcClient == nil {
+		return 0, fmt.Errorf("%s RPC client not configured", w.params.Type)
+	}
+	tx, err := w.rpcClient.GetRawTransactionVerbose(hash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	return int(tx.Confirmations), nil
+}
+
+// RollbackLastAddress decrements the next index counter after a failed payment creation
+func (w *UTXOHDWallet) RollbackLastAddress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nextIndex > 0 {
+		w.nextIndex--
+	}
+}
+
+// SkipUsedAddresses continues address derivation after the highest address
+// in used, so addresses handed out before a restart are not handed out again.
+// The search ends addressGapLimit addresses after the last used one.
+//
+// Parameters:
+//   - used: Addresses handed out before
+//
+// Returns:
+//   - uint32: The next address index
+//   - error: If key derivation fails
+func (w *UTXOHDWallet) SkipUsedAddresses(used map[string]bool) (uint32, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next, err := nextUnusedIndex(w.nextIndex, used, w.addressAt)
+	w.nextIndex = next
+	return next, err
+}
+
+// GetNextIndex returns the next address index to be derived
+func (w *UTXOHDWallet) GetNextIndex() uint32 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.nextIndex
+}
+
+// IsMultisigEnabled implements HDWallet; multisig is not supported for these chains
+func (w *UTXOHDWallet) IsMultisigEnabled() bool {
+	return false
+}
+
+// GetMultisigConfig implements HDWallet; always returns ErrMultisigNotSupported
+func (w *UTXOHDWallet) GetMultisigConfig() (*MultisigConfig, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// DeriveMultisigAddress implements HDWallet; always returns ErrMultisigNotSupported
+func (w *UTXOHDWallet) DeriveMultisigAddress(pubKeys [][]byte, requiredSigs int) (string, *MultisigMetadata, error) {
+	return "", nil, ErrMultisigNotSupported
+}
+
+// CreateRedeemScript implements HDWallet; always returns ErrMultisigNotSupported
+func (w *UTXOHDWallet) CreateRedeemScript(pubKeys [][]byte, requiredSigs int) ([]byte, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// Shared BIP32 and address helpers for Bitcoin-derived chains
+
+// masterKeyFromSeed derives the BIP32 master key and chain code from a seed
+func masterKeyFromSeed(seed []byte) (masterKey, chainCode []byte) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// deriveChildKey implements BIP32 private child key derivation.
+// Indexes >= hardenedKeyStart produce hardened children.
+func deriveChildKey(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	var data []byte
+	if index >= hardenedKeyStart {
+		// Hardened derivation
+		data = append([]byte{0x00}, key...)
+	} else {
+		// Normal derivation
+		privKey, _ := btcec.PrivKeyFromBytes(key)
+		data = privKey.PubKey().SerializeCompressed()
+	}
+
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	// Add parent key to child key (mod curve order)
+	childInt := new(big.Int).SetBytes(sum[:32])
+	childInt.Add(childInt, new(big.Int).SetBytes(key))
+	childInt.Mod(childInt, btcec.S256().N)
+	if childInt.Sign() == 0 {
+		return nil, nil, errors.New("invalid child key")
+	}
+
+	childKey := make([]byte, 32)
+	childBytes := childInt.Bytes()
+	copy(childKey[32-len(childBytes):], childBytes)
+	return childKey, sum[32:], nil
+}
+
+// hash160 returns RIPEMD160(SHA256(data))
+func hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
+
+// base58CheckEncode encodes version || payload || checksum in base58
+func base58CheckEncode(version byte, payload []byte) string {
+	versioned := append([]byte{version}, payload...)
+	first := sha256.Sum256(versioned)
+	second := sha256.Sum256(first[:])
+	return Base58Encode(append(versioned, second[:4]...))
+}
+
+// base58CheckDecode decodes a 25-byte base58check address and verifies its checksum
+func base58CheckDecode(address string) (version byte, payload []byte, err error) {
+	decoded, err := Base58Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(decoded) != 25 {
+		return 0, nil, fmt.Errorf("invalid address length: %d bytes", len(decoded))
+	}
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+	if !hmac.Equal(second[:4], decoded[21:]) {
+		return 0, nil, errors.New("invalid address checksum")
+	}
+	return decoded[0], decoded[1:21], nil
+}
@@ -0,0 +1,236 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+func TestCashAddr_RoundTrip(t *testing.T) {
+	// Test vector from the cashaddr specification
+	const legacy = "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"
+	const cashaddr = "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"
+
+	_, hash, err := base58CheckDecode(legacy)
+	if err != nil {
+		t.Fatalf("base58CheckDecode() error = %v", err)
+	}
+	encoded, err := EncodeCashAddr("bitcoincash", cashAddrP2PKH, hash)
+	if err != nil {
+		t.Fatalf("EncodeCashAddr() error = %v", err)
+	}
+	if encoded != cashaddr {
+		t.Errorf("EncodeCashAddr() = %s, want %s", encoded, cashaddr)
+	}
+
+	prefix, addrType, decoded, err := DecodeCashAddr(strings.TrimPrefix(cashaddr, "bitcoincash:"), "bitcoincash")
+	if err != nil {
+		t.Fatalf("DecodeCashAddr() error = %v", err)
+	}
+	if prefix != "bitcoincash" || addrType != cashAddrP2PKH || !bytes.Equal(decoded, hash) {
+		t.Errorf("DecodeCashAddr() = %s, %d, %x", prefix, addrType, decoded)
+	}
+}
+
+func TestIsBitcoinCashAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		wantValid   bool
+		wantNetwork string
+	}{
+		{"cashaddr with prefix", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", true, "mainnet"},
+		{"cashaddr without prefix", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", true, "mainnet"},
+		{"cashaddr upper case", "BITCOINCASH:QPM2QSZNHKS23Z7629MMS6S4CWEF74VCWVY22GDX6A", true, "mainnet"},
+		{"legacy mainnet", "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", true, "mainnet"},
+		{"bad checksum", "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6b", false, "invalid"},
+		{"mixed case", "bitcoincash:Qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false, "invalid"},
+		{"wrong prefix", "bchtest:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", false, "invalid"},
+		{"empty", "", false, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, network := IsBitcoinCashAddress(tt.address)
+			if valid != tt.wantValid || network != tt.wantNetwork {
+				t.Errorf("IsBitcoinCashAddress(%q) = %v, %s; want %v, %s", tt.address, valid, network, tt.wantValid, tt.wantNetwork)
+			}
+		})
+	}
+}
+
+func TestIsDogecoinAddress(t *testing.T) {
+	_, hash, err := base58CheckDecode("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu")
+	if err != nil {
+		t.Fatalf("base58CheckDecode() error = %v", err)
+	}
+	mainnet := base58CheckEncode(0x1e, hash)
+	testnet := base58CheckEncode(0x71, hash)
+	tampered := mainnet[:len(mainnet)-1] + "z"
+
+	tests := []struct {
+		name        string
+		address     string
+		wantValid   bool
+		wantNetwork string
+	}{
+		{"mainnet P2PKH", mainnet, true, "mainnet"},
+		{"testnet P2PKH", testnet, true, "testnet"},
+		{"bitcoin address", "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", false, "invalid"},
+		{"bad checksum", tampered, false, "invalid"},
+		{"garbage", "D0OIl", false, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, network := IsDogecoinAddress(tt.address)
+			if valid != tt.wantValid || network != tt.wantNetwork {
+				t.Errorf("IsDogecoinAddress(%q) = %v, %s; want %v, %s", tt.address, valid, network, tt.wantValid, tt.wantNetwork)
+			}
+		})
+	}
+	if !strings.HasPrefix(mainnet, "D") {
+		t.Errorf("mainnet Dogecoin address %s should start with D", mainnet)
+	}
+}
+
+func TestIsLitecoinAddress(t *testing.T) {
+	_, hash, err := base58CheckDecode("LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez")
+	if err != nil {
+		t.Fatalf("base58CheckDecode() error = %v", err)
+	}
+	program, _ := bech32.ConvertBits(hash, 8, 5, true)
+	segwit, _ := bech32.Encode("ltc", append([]byte{0}, program...))
+	segwitTestnet, _ := bech32.Encode("tltc", append([]byte{0}, program...))
+	segwitWrongVariant, _ := bech32.EncodeM("ltc", append([]byte{0}, program...))
+
+	tests := []struct {
+		name        string
+		address     string
+		wantValid   bool
+		wantNetwork string
+	}{
+		{"mainnet P2PKH", "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez", true, "mainnet"},
+		{"mainnet P2SH", base58CheckEncode(0x32, hash), true, "mainnet"},
+		{"testnet P2PKH", "mkpZhYtJu2r87Js3pDiWJDmPte2NRZ8bJV", true, "testnet"},
+		{"mainnet segwit", segwit, true, "mainnet"},
+		{"testnet segwit", segwitTestnet, true, "testnet"},
+		{"segwit v0 with bech32m", segwitWrongVariant, false, "invalid"},
+		{"bitcoin segwit", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false, "invalid"},
+		{"dogecoin address", base58CheckEncode(0x1e, hash), false, "invalid"},
+		{"bad checksum", "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ey", false, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, network := IsLitecoinAddress(tt.address)
+			if valid != tt.wantValid || network != tt.wantNetwork {
+				t.Errorf("IsLitecoinAddress(%q) = %v, %s; want %v, %s", tt.address, valid, network, tt.wantValid, tt.wantNetwork)
+			}
+		})
+	}
+}
+
+func TestUTXOHDWallet_LitecoinVector(t *testing.T) {
+	// m/44'/2'/0'/0/0 of the BIP39 test mnemonic "abandon ... about"
+	seed, _ := hex.DecodeString(testETHSeed)
+	w, err := NewUTXOHDWallet(seed, &LitecoinMainNetParams, nil, 1)
+	if err != nil {
+		t.Fatalf("NewUTXOHDWallet() error = %v", err)
+	}
+	if address, _ := w.DeriveNextAddress(); address != "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez" {
+		t.Errorf("DeriveNextAddress() = %s, want LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez", address)
+	}
+	second, _ := w.DeriveNextAddress()
+
+	restarted, _ := NewUTXOHDWallet(seed, &LitecoinMainNetParams, nil, 1)
+	if next, err := restarted.SkipUsedAddresses(map[string]bool{second: true}); err != nil || next != 2 {
+		t.Errorf("SkipUsedAddresses() = %d, %v, want 2", next, err)
+	}
+}
+
+func TestUTXOHDWallet_DeriveAddresses(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x01}, 32)
+	tests := []struct {
+		walletType WalletType
+		testnet    bool
+		prefix     string
+		validate   func(string) (bool, string)
+		network    string
+	}{
+		{Litecoin, false, "L", IsLitecoinAddress, "mainnet"},
+		{Litecoin, true, "m", IsLitecoinAddress, "testnet"},
+		{BitcoinCash, false, "bitcoincash:q", IsBitcoinCashAddress, "mainnet"},
+		{BitcoinCash, true, "bchtest:q", IsBitcoinCashAddress, "testnet"},
+		{Dogecoin, false, "D", IsDogecoinAddress, "mainnet"},
+		{Dogecoin, true, "n", IsDogecoinAddress, "testnet"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.walletType)+"/"+tt.network, func(t *testing.T) {
+			params, err := UTXOChainParamsFor(tt.walletType, tt.testnet)
+			if err != nil {
+				t.Fatalf("UTXOChainParamsFor() error = %v", err)
+			}
+			w, err := NewUTXOHDWallet(seed, params, nil, 1)
+			if err != nil {
+				t.Fatalf("NewUTXOHDWallet() error = %v", err)
+			}
+			if w.Currency() != string(tt.walletType) {
+				t.Errorf("Currency() = %s", w.Currency())
+			}
+			first, err := w.GetAddress()
+			if err != nil {
+				t.Fatalf("GetAddress() error = %v", err)
+			}
+			second, _ := w.GetAddress()
+			if first == second {
+				t.Error("consecutive addresses should differ")
+			}
+			if !strings.HasPrefix(first, tt.prefix) {
+				t.Errorf("address %s should start with %s", first, tt.prefix)
+			}
+			if valid, network := tt.validate(first); !valid || network != tt.network {
+				t.Errorf("derived address %s validates as %v, %s", first, valid, network)
+			}
+
+			// Derivation is deterministic for the same seed
+			again, _ := NewUTXOHDWallet(seed, params, nil, 1)
+			if replay, _ := again.GetAddress(); replay != first {
+				t.Errorf("derivation not deterministic: %s != %s", replay, first)
+			}
+
+			w.RollbackLastAddress()
+			if w.GetNextIndex() != 1 {
+				t.Errorf("GetNextIndex() after rollback = %d, want 1", w.GetNextIndex())
+			}
+		})
+	}
+}
+
+func TestUTXOHDWallet_Errors(t *testing.T) {
+	if _, err := NewUTXOHDWallet([]byte("short"), &DogecoinMainNetParams, nil, 1); err == nil {
+		t.Error("NewUTXOHDWallet() should reject short seeds")
+	}
+	if _, err := UTXOChainParamsFor(Monero, false); err == nil {
+		t.Error("UTXOChainParamsFor(XMR) should fail")
+	}
+
+	w, err := NewUTXOHDWallet(bytes.Repeat([]byte{0x02}, 32), &DogecoinMainNetParams, nil, 1)
+	if err != nil {
+		t.Fatalf("NewUTXOHDWallet() error = %v", err)
+	}
+	address, _ := w.GetAddress()
+	if _, err := w.GetAddressBalance("1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"); err == nil {
+		t.Error("GetAddressBalance() should reject foreign addresses")
+	}
+	if _, err := w.GetAddressBalance(address); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("GetAddressBalance() without RPC error = %v", err)
+	}
+	if _, err := w.GetTransactionConfirmations("abc"); err == nil {
+		t.Error("GetTransactionConfirmations() should reject malformed IDs")
+	}
+	if _, err := w.GetMultisigConfig(); !errors.Is(err, ErrMultisigNotSupported) {
+		t.Errorf("GetMultisigConfig() error = %v", err)
+	}
+}
@@ -0,0 +1,375 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Monero address network bytes
+const (
+	moneroMainnetStandard   = 18
+	moneroMainnetIntegrated = 19
+	moneroMainnetSubaddress = 42
+	moneroTestnetStandard   = 53
+	moneroTestnetIntegrated = 54
+	moneroTestnetSubaddress = 63
+	moneroStagenetStandard  = 24
+	moneroStagenetIntegrate = 25
+	moneroStagenetSubaddr   = 36
+)
+
+// moneroBase58BlockSizes maps a trailing block's byte length to its encoded length
+var moneroBase58BlockSizes = []int{0, 2, 3, 5, 6, 7, 9, 10, 11}
+
+// moneroAddress is a decoded Monero address
+type moneroAddress struct {
+	netByte   byte
+	spendKey  []byte // Public spend key (32 bytes)
+	viewKey   []byte // Public view key (32 bytes)
+	paymentID []byte // 8-byte payment ID for integrated addresses
+}
+
+// keccak256 returns the Keccak-256 (pre-standard SHA3) digest used throughout Monero
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// moneroBase58Encode encodes data with Monero's block-wise base58 variant
+func moneroBase58Encode(data []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i += 8 {
+		end := i + 8
+		if end > len(data) {
+			end = len(data)
+		}
+		block := data[i:end]
+		num := new(big.Int).SetBytes(block)
+		encoded := make([]byte, moneroBase58BlockSizes[len(block)])
+		for j := len(encoded) - 1; j >= 0; j-- {
+			mod := new(big.Int)
+			num.DivMod(num, big.NewInt(58), mod)
+			encoded[j] = base58Alphabet[mod.Int64()]
+		}
+		b.Write(encoded)
+	}
+	return b.String()
+}
+
+// moneroBase58Decode decodes Monero's block-wise base58 variant
+func moneroBase58Decode(s string) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(s); i += 11 {
+		end := i + 11
+		if end > len(s) {
+			end = len(s)
+		}
+		chunk := s[i:end]
+		size := -1
+		for n, encodedLen := range moneroBase58BlockSizes {
+			if encodedLen == len(chunk) {
+				size = n
+			}
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid base58 block length %d", len(chunk))
+		}
+		num := new(big.Int)
+		for _, r := range chunk {
+			pos := strings.IndexRune(base58Alphabet, r)
+			if pos < 0 {
+				return nil, errors.New("invalid base58 character")
+			}
+			num.Mul(num, big.NewInt(58))
+			num.Add(num, big.NewInt(int64(pos)))
+		}
+		if num.BitLen() > size*8 {
+			return nil, errors.New("base58 block overflow")
+		}
+		block := make([]byte, size)
+		num.FillBytes(block)
+		out = append(out, block...)
+	}
+	return out, nil
+}
+
+// encodeMoneroAddress builds an address string from its network byte and keys
+func encodeMoneroAddress(netByte byte, spendKey, viewKey, paymentID []byte) string {
+	data := append([]byte{netByte}, spendKey...)
+	data = append(data, viewKey...)
+	data = append(data, paymentID...)
+	return moneroBase58Encode(append(data, keccak256(data)[:4]...))
+}
+
+// decodeMoneroAddress parses and checksums a standard, integrated or subaddress
+func decodeMoneroAddress(address string) (*moneroAddress, error) {
+	data, err := moneroBase58Decode(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 69 && len(data) != 77 {
+		return nil, fmt.Errorf("invalid Monero address length: %d bytes", len(data))
+	}
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(keccak256(body)[:4], checksum) {
+		return nil, errors.New("invalid Monero address checksum")
+	}
+	addr := &moneroAddress{netByte: body[0], spendKey: body[1:33], viewKey: body[33:65]}
+	if len(body) == 73 {
+		addr.paymentID = body[65:73]
+	}
+	return addr, nil
+}
+
+// moneroNetworks maps address network bytes to their network
+var moneroNetworks = map[byte]string{
+	moneroMainnetStandard:   "mainnet",
+	moneroMainnetIntegrated: "mainnet",
+	moneroMainnetSubaddress: "mainnet",
+	moneroStagenetStandard:  "stagenet",
+	moneroStagenetIntegrate: "stagenet",
+	moneroStagenetSubaddr:   "stagenet",
+	moneroTestnetStandard:   "testnet",
+	moneroTestnetIntegrated: "testnet",
+	moneroTestnetSubaddress: "testnet",
+}
+
+// IsMoneroAddress checks if a string is a valid Monero standard, integrated
+// or subaddress, checksum included, and returns whether it's a mainnet,
+// stagenet or testnet address, or "invalid" if the address is not valid.
+func IsMoneroAddress(address string) (bool, string) {
+	addr, err := decodeMoneroAddress(address)
+	if err != nil {
+		return false, "invalid"
+	}
+	network, ok := moneroNetworks[addr.netByte]
+	if !ok || isMoneroIntegrated(addr.netByte) != (addr.paymentID != nil) {
+		return false, "invalid"
+	}
+	return true, network
+}
+
+// isMoneroIntegrated reports whether netByte is an integrated address network byte
+func isMoneroIntegrated(netByte byte) bool {
+	return netByte == moneroMainnetIntegrated || netByte == moneroStagenetIntegrate || netByte == moneroTestnetIntegrated
+}
+
+// moneroSubaddressNetByte returns the subaddress network byte matching a standard address network byte
+func moneroSubaddressNetByte(standard byte) (byte, error) {
+	switch standard {
+	case moneroMainnetStandard:
+		return moneroMainnetSubaddress, nil
+	case moneroTestnetStandard:
+		return moneroTestnetSubaddress, nil
+	case moneroStagenetStandard:
+		return moneroStagenetSubaddr, nil
+	}
+	return 0, fmt.Errorf("not a standard Monero address (network byte %d)", standard)
+}
+
+// MoneroSubaddress derives the subaddress (major, minor) of a wallet from its
+// primary address and private view key, without contacting a wallet.
+//
+// Parameters:
+//   - primaryAddress: The wallet's standard (primary) address
+//   - privateViewKey: 32-byte private view key
+//   - major: Account index
+//   - minor: Subaddress index within the account (0/0 is the primary address)
+//
+// Returns:
+//   - string: Encoded subaddress for the same network as primaryAddress
+//   - error: If the address or key is invalid
+//
+// Derivation (Monero subaddress scheme):
+//
+//	m = Hs("SubAddr\0" || a || major || minor)
+//	D = B + m*G, C = a*D
+func MoneroSubaddress(primaryAddress string, privateViewKey []byte, major, minor uint32) (string, error) {
+	primary, err := decodeMoneroAddress(primaryAddress)
+	if err != nil {
+		return "", fmt.Errorf("decode primary address: %w", err)
+	}
+	if len(privateViewKey) != 32 {
+		return "", fmt.Errorf("private view key must be 32 bytes, got %d", len(privateViewKey))
+	}
+	subNetByte, err := moneroSubaddressNetByte(primary.netByte)
+	if err != nil {
+		return "", err
+	}
+	if major == 0 && minor == 0 {
+		return primaryAddress, nil
+	}
+
+	viewScalar := scalarFromLE(privateViewKey)
+	if !bytes.Equal(edEncode(edScalarMultBase(viewScalar)), primary.viewKey) {
+		return "", errors.New("private view key does not match the primary address")
+	}
+
+	B, err := edDecode(primary.spendKey)
+	if err != nil {
+		return "", fmt.Errorf("decode spend key: %w", err)
+	}
+	indexes := make([]byte, 8)
+	binary.LittleEndian.PutUint32(indexes[:4], major)
+	binary.LittleEndian.PutUint32(indexes[4:], minor)
+	m := hashToScalar([]byte("SubAddr\x00"), privateViewKey, indexes)
+
+	D := edAdd(B, edScalarMultBase(m))
+	C := edScalarMult(viewScalar, D)
+	return encodeMoneroAddress(subNetByte, edEncode(D), edEncode(C), nil), nil
+}
+
+// Minimal ed25519 group arithmetic (extended coordinates over math/big).
+// Only public data is processed here, so constant-time execution is not required.
+
+var (
+	edP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	edL = func() *big.Int {
+		l, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+		return l
+	}()
+	edD = func() *big.Int {
+		d := new(big.Int).ModInverse(big.NewInt(121666), edP)
+		d.Mul(d, big.NewInt(-121665))
+		return d.Mod(d, edP)
+	}()
+	edSqrtM1 = new(big.Int).Exp(big.NewInt(2), new(big.Int).Div(new(big.Int).Sub(edP, big.NewInt(1)), big.NewInt(4)), edP)
+	edBase   = func() edPoint {
+		y := new(big.Int).Mul(big.NewInt(4), new(big.Int).ModInverse(big.NewInt(5), edP))
+		y.Mod(y, edP)
+		x, _ := edRecoverX(y, 0)
+		return edPoint{x, y, big.NewInt(1), new(big.Int).Mod(new(big.Int).Mul(x, y), edP)}
+	}()
+)
+
+// edPoint is a point in extended twisted Edwards coordinates (X:Y:Z:T)
+type edPoint struct {
+	x, y, z, t *big.Int
+}
+
+func edIdentity() edPoint {
+	return edPoint{big.NewInt(0), big.NewInt(1), big.NewInt(1), big.NewInt(0)}
+}
+
+func edMod(v *big.Int) *big.Int {
+	return v.Mod(v, edP)
+}
+
+// edAdd adds two points (add-2008-hwcd-3 for a = -1)
+func edAdd(p, q edPoint) edPoint {
+	a := edMod(new(big.Int).Mul(new(big.Int).Sub(p.y, p.x), new(big.Int).Sub(q.y, q.x)))
+	b := edMod(new(big.Int).Mul(new(big.Int).Add(p.y, p.x), new(big.Int).Add(q.y, q.x)))
+	c := edMod(new(big.Int).Mul(new(big.Int).Mul(big.NewInt(2), edD), new(big.Int).Mul(p.t, q.t)))
+	d := edMod(new(big.Int).Mul(big.NewInt(2), new(big.Int).Mul(p.z, q.z)))
+	e := new(big.Int).Sub(b, a)
+	f := new(big.Int).Sub(d, c)
+	g := new(big.Int).Add(d, c)
+	h := new(big.Int).Add(b, a)
+	return edPoint{
+		edMod(new(big.Int).Mul(e, f)),
+		edMod(new(big.Int).Mul(g, h)),
+		edMod(new(big.Int).Mul(f, g)),
+		edMod(new(big.Int).Mul(e, h)),
+	}
+}
+
+// edScalarMult computes k*P by double-and-add
+func edScalarMult(k *big.Int, p edPoint) edPoint {
+	result := edIdentity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = edAdd(result, result)
+		if k.Bit(i) == 1 {
+			result = edAdd(result, p)
+		}
+	}
+	return result
+}
+
+func edScalarMultBase(k *big.Int) edPoint {
+	return edScalarMult(k, edBase)
+}
+
+// edEncode serializes a point as 32 little-endian bytes of y with the sign of x in the top bit
+func edEncode(p edPoint) []byte {
+	zInv := new(big.Int).ModInverse(p.z, edP)
+	x := edMod(new(big.Int).Mul(p.x, zInv))
+	y := edMod(new(big.Int).Mul(p.y, zInv))
+	out := make([]byte, 32)
+	y.FillBytes(out)
+	reverseBytes(out)
+	out[31] |= byte(x.Bit(0) << 7)
+	return out
+}
+
+// edDecode parses a compressed point
+func edDecode(encoded []byte) (edPoint, error) {
+	if len(encoded) != 32 {
+		return edPoint{}, errors.New("point must be 32 bytes")
+	}
+	buf := append([]byte(nil), encoded...)
+	sign := uint(buf[31] >> 7)
+	buf[31] &= 0x7f
+	reverseBytes(buf)
+	y := new(big.Int).SetBytes(buf)
+	if y.Cmp(edP) >= 0 {
+		return edPoint{}, errors.New("point y coordinate out of range")
+	}
+	x, err := edRecoverX(y, sign)
+	if err != nil {
+		return edPoint{}, err
+	}
+	return edPoint{x, y, big.NewInt(1), edMod(new(big.Int).Mul(x, y))}, nil
+}
+
+// edRecoverX solves the curve equation for x given y and the sign bit
+func edRecoverX(y *big.Int, sign uint) (*big.Int, error) {
+	y2 := edMod(new(big.Int).Mul(y, y))
+	u := edMod(new(big.Int).Sub(y2, big.NewInt(1)))
+	v := edMod(new(big.Int).Add(new(big.Int).Mul(edD, y2), big.NewInt(1)))
+	x2 := edMod(new(big.Int).Mul(u, new(big.Int).ModInverse(v, edP)))
+	if x2.Sign() == 0 {
+		if sign == 1 {
+			return nil, errors.New("invalid point encoding")
+		}
+		return big.NewInt(0), nil
+	}
+	// x = x2^((p+3)/8), corrected by sqrt(-1) when needed
+	x := new(big.Int).Exp(x2, new(big.Int).Div(new(big.Int).Add(edP, big.NewInt(3)), big.NewInt(8)), edP)
+	if edMod(new(big.Int).Sub(new(big.Int).Mul(x, x), x2)).Sign() != 0 {
+		x = edMod(x.Mul(x, edSqrtM1))
+	}
+	if edMod(new(big.Int).Sub(new(big.Int).Mul(x, x), x2)).Sign() != 0 {
+		return nil, errors.New("point not on curve")
+	}
+	if x.Bit(0) != sign {
+		x.Sub(edP, x)
+	}
+	return x, nil
+}
+
+// scalarFromLE interprets little-endian bytes as an integer
+func scalarFromLE(b []byte) *big.Int {
+	buf := append([]byte(nil), b...)
+	reverseBytes(buf)
+	return new(big.Int).SetBytes(buf)
+}
+
+// hashToScalar is Monero's Hs: Keccak-256 reduced modulo the group order
+func hashToScalar(data ...[]byte) *big.Int {
+	return new(big.Int).Mod(scalarFromLE(keccak256(data...)), edL)
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
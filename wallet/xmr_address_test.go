@@ -0,0 +1,149 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+)
+
+// Monero project donation address and its published private view key
+const (
+	testXMRAddress = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+	testXMRViewKey = "f359631075708155cc3d92a32b75a7d02a5dcf27756707b47a2b31b21c389501"
+)
+
+func TestEdScalarMultBase_MatchesStdlib(t *testing.T) {
+	for _, fill := range []byte{1, 7, 0xfe} {
+		seed := bytes.Repeat([]byte{fill}, ed25519.SeedSize)
+		want := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+		h := sha512.Sum512(seed)
+		scalar := h[:32]
+		scalar[0] &= 248
+		scalar[31] &= 127
+		scalar[31] |= 64
+
+		got := edEncode(edScalarMultBase(scalarFromLE(scalar)))
+		if !bytes.Equal(got, want) {
+			t.Errorf("seed %x: got %x, want %x", fill, got, []byte(want))
+		}
+
+		decoded, err := edDecode(want)
+		if err != nil {
+			t.Fatalf("edDecode() error = %v", err)
+		}
+		if !bytes.Equal(edEncode(decoded), want) {
+			t.Errorf("decode/encode round trip mismatch for seed %x", fill)
+		}
+	}
+}
+
+func TestDecodeMoneroAddress(t *testing.T) {
+	addr, err := decodeMoneroAddress(testXMRAddress)
+	if err != nil {
+		t.Fatalf("decodeMoneroAddress() error = %v", err)
+	}
+	if addr.netByte != moneroMainnetStandard {
+		t.Errorf("netByte = %d, want %d", addr.netByte, moneroMainnetStandard)
+	}
+	if got := encodeMoneroAddress(addr.netByte, addr.spendKey, addr.viewKey, nil); got != testXMRAddress {
+		t.Errorf("re-encoded address = %s, want %s", got, testXMRAddress)
+	}
+
+	viewKey, _ := hex.DecodeString(testXMRViewKey)
+	if !bytes.Equal(edEncode(edScalarMultBase(scalarFromLE(viewKey))), addr.viewKey) {
+		t.Error("public view key does not match private view key")
+	}
+
+	corrupted := testXMRAddress[:len(testXMRAddress)-1] + "B"
+	if _, err := decodeMoneroAddress(corrupted); err == nil {
+		t.Error("expected checksum error for corrupted address")
+	}
+}
+
+func TestMoneroSubaddress(t *testing.T) {
+	viewKey, _ := hex.DecodeString(testXMRViewKey)
+
+	primary, err := MoneroSubaddress(testXMRAddress, viewKey, 0, 0)
+	if err != nil || primary != testXMRAddress {
+		t.Fatalf("MoneroSubaddress(0, 0) = %q, %v; want primary address", primary, err)
+	}
+
+	sub1, err := MoneroSubaddress(testXMRAddress, viewKey, 0, 1)
+	if err != nil {
+		t.Fatalf("MoneroSubaddress(0, 1) error = %v", err)
+	}
+	if sub1[0] != '8' {
+		t.Errorf("mainnet subaddress should start with 8, got %s", sub1)
+	}
+	decoded, err := decodeMoneroAddress(sub1)
+	if err != nil || decoded.netByte != moneroMainnetSubaddress {
+		t.Fatalf("subaddress did not decode as mainnet subaddress: %v", err)
+	}
+
+	sub2, _ := MoneroSubaddress(testXMRAddress, viewKey, 0, 2)
+	if sub1 == sub2 {
+		t.Error("different indexes produced the same subaddress")
+	}
+	again, _ := MoneroSubaddress(testXMRAddress, viewKey, 0, 1)
+	if again != sub1 {
+		t.Error("subaddress derivation is not deterministic")
+	}
+
+	wrongKey := bytes.Repeat([]byte{1}, 32)
+	if _, err := MoneroSubaddress(testXMRAddress, wrongKey, 0, 1); err == nil {
+		t.Error("expected error for mismatched view key")
+	}
+	if _, err := MoneroSubaddress(sub1, viewKey, 0, 1); err == nil {
+		t.Error("expected error when deriving from a subaddress")
+	}
+}
+
+func TestIsMoneroAddress(t *testing.T) {
+	primary, err := decodeMoneroAddress(testXMRAddress)
+	if err != nil {
+		t.Fatalf("decodeMoneroAddress() error = %v", err)
+	}
+	viewKey, _ := hex.DecodeString(testXMRViewKey)
+	subaddress, err := MoneroSubaddress(testXMRAddress, viewKey, 0, 1)
+	if err != nil {
+		t.Fatalf("MoneroSubaddress() error = %v", err)
+	}
+	paymentID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	encode := func(netByte byte, paymentID []byte) string {
+		return encodeMoneroAddress(netByte, primary.spendKey, primary.viewKey, paymentID)
+	}
+
+	tests := []struct {
+		name        string
+		address     string
+		wantValid   bool
+		wantNetwork string
+	}{
+		{"mainnet standard", testXMRAddress, true, "mainnet"},
+		{"mainnet integrated", encode(moneroMainnetIntegrated, paymentID), true, "mainnet"},
+		{"mainnet subaddress", subaddress, true, "mainnet"},
+		{"stagenet standard", encode(moneroStagenetStandard, nil), true, "stagenet"},
+		{"stagenet integrated", encode(moneroStagenetIntegrate, paymentID), true, "stagenet"},
+		{"stagenet subaddress", encode(moneroStagenetSubaddr, nil), true, "stagenet"},
+		{"testnet standard", encode(moneroTestnetStandard, nil), true, "testnet"},
+		{"testnet integrated", encode(moneroTestnetIntegrated, paymentID), true, "testnet"},
+		{"testnet subaddress", encode(moneroTestnetSubaddress, nil), true, "testnet"},
+		{"unknown network byte", encode(7, nil), false, "invalid"},
+		{"integrated without payment ID", encode(moneroMainnetIntegrated, nil), false, "invalid"},
+		{"standard with payment ID", encode(moneroMainnetStandard, paymentID), false, "invalid"},
+		{"bad checksum", testXMRAddress[:len(testXMRAddress)-1] + "B", false, "invalid"},
+		{"bitcoin address", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", false, "invalid"},
+		{"empty", "", false, "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, network := IsMoneroAddress(tt.address)
+			if valid != tt.wantValid || network != tt.wantNetwork {
+				t.Errorf("IsMoneroAddress() = %v, %q, want %v, %q", valid, network, tt.wantValid, tt.wantNetwork)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package wallet
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,9 +16,11 @@ type MoneroHDWallet struct {
 	client           monero.Client
 	mu               sync.Mutex
 	nextIndex        uint32
+	accountIndex     uint64
 	minConfirmations int
-	multisigConfig   *MultisigConfig // Stores multisig configuration when enabled
-	multisigAddress  string          // The multisig address for this wallet
+	subaddrIndex     map[string]uint64 // Payment subaddress -> minor index in accountIndex
+	multisigConfig   *MultisigConfig   // Stores multisig configuration when enabled
+	multisigAddress  string            // The multisig address for this wallet
 }
 
 // MoneroConfig holds Monero wallet RPC connection details
@@ -24,28 +28,40 @@ type MoneroConfig struct {
 	RPCURL      string
 	RPCUser     string
 	RPCPassword string
+	// AccountIndex is the wallet account payment subaddresses are created in and
+	// received transfers are read from. Defaults to the primary account (0).
+	AccountIndex uint32
 }
 
-// NewMoneroWallet creates a new Monero wallet instance
+// NewMoneroWallet creates a new Monero wallet instance.
+// Subaddress numbering resumes after the highest subaddress the wallet already
+// holds in the configured account, so payment labels stay unique across restarts.
 func NewMoneroWallet(config MoneroConfig, minConf int) (*MoneroHDWallet, error) {
 	client := monero.New(monero.Config{
 		Address: config.RPCURL,
 	})
+	return newMoneroWallet(client, uint64(config.AccountIndex), minConf)
+}
 
+// newMoneroWallet checks the connection, detects multisig wallets and restores
+// the subaddress index using client
+func newMoneroWallet(client monero.Client, accountIndex uint64, minConf int) (*MoneroHDWallet, error) {
 	w := &MoneroHDWallet{
 		client:           client,
 		nextIndex:        0,
+		accountIndex:     accountIndex,
 		minConfirmations: minConf,
+		subaddrIndex:     make(map[string]uint64),
 	}
 
 	// Test connection by getting balance
-	_, err := client.GetBalance(&monero.RequestGetBalance{AccountIndex: 0})
+	_, err := client.GetBalance(&monero.RequestGetBalance{AccountIndex: accountIndex})
 	if err != nil {
 		return nil, fmt.Errorf("monero RPC connection failed: %w", err)
 	}
 
 	// Check if wallet is already multisig and populate config
-	if resp, err := client.IsMultisig(); err == nil && resp.Multisig {
+	if resp, err := client.IsMultisig(); err == nil && resp != nil && resp.Multisig {
 		w.multisigConfig = &MultisigConfig{
 			Enabled:      true,
 			RequiredSigs: int(resp.Threshold),
@@ -54,11 +70,16 @@ func NewMoneroWallet(config MoneroConfig, minConf int) (*MoneroHDWallet, error)
 
 		// Try to get the multisig address by getting the current address
 		// In Monero, multisig wallets have a single address
-		if addrResp, err := client.GetAddress(&monero.RequestGetAddress{AccountIndex: 0}); err == nil {
+		if addrResp, err := client.GetAddress(&monero.RequestGetAddress{AccountIndex: accountIndex}); err == nil {
 			w.multisigAddress = addrResp.Address
 		}
 	}
 
+	if err := w.restoreNextIndex(); err != nil {
+		// Not fatal: addresses stay unique (wallet-rpc assigns them), only labels may repeat
+		log.Printf("Monero subaddress index not restored, labels restart at payment-0: %v", err)
+	}
+
 	return w, nil
 }
 
@@ -104,7 +125,7 @@ func (w *MoneroHDWallet) DeriveNextAddress() (string, error) {
 	defer w.mu.Unlock()
 
 	req := &monero.RequestCreateAddress{
-		AccountIndex: 0,
+		AccountIndex: w.accountIndex,
 		Label:        fmt.Sprintf("payment-%d", w.nextIndex),
 	}
 
@@ -113,10 +134,113 @@ func (w *MoneroHDWallet) DeriveNextAddress() (string, error) {
 		return "", fmt.Errorf("create address failed: %w", err)
 	}
 
+	if w.subaddrIndex == nil {
+		w.subaddrIndex = make(map[string]uint64)
+	}
+	w.subaddrIndex[resp.Address] = resp.AddressIndex
 	w.nextIndex++
 	return resp.Address, nil
 }
 
+// addressIndex returns the subaddress index of address in the wallet's
+// account. Subaddresses created by this instance are cached; others, such as
+// those of payments created before a restart, are looked up with
+// get_address_index.
+func (w *MoneroHDWallet) addressIndex(address string) (uint64, error) {
+	w.mu.Lock()
+	index, ok := w.subaddrIndex[address]
+	w.mu.Unlock()
+	if ok {
+		return index, nil
+	}
+
+	resp, err := w.client.GetAddressIndex(&monero.RequestGetAddressIndex{Address: address})
+	if err != nil {
+		return 0, fmt.Errorf("get address index: %w", err)
+	}
+	if resp == nil {
+		return 0, errors.New("get address index: empty response")
+	}
+	if resp.Index.Major != w.accountIndex {
+		return 0, fmt.Errorf("address %s belongs to account %d, not %d", address, resp.Index.Major, w.accountIndex)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subaddrIndex == nil {
+		w.subaddrIndex = make(map[string]uint64)
+	}
+	w.subaddrIndex[address] = resp.Index.Minor
+	return resp.Index.Minor, nil
+}
+
+// incomingTransfers returns the transfers received on address, including
+// those still in the transaction pool. Only the address's subaddress is
+// queried, so payments sharing the account never count towards each other.
+// Transfers whose key images were seen in another transaction are dropped.
+func (w *MoneroHDWallet) incomingTransfers(address string) ([]*monero.Transfer, error) {
+	index, err := w.addressIndex(address)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.GetTransfers(&monero.RequestGetTransfers{
+		In:             true,
+		Pool:           true,
+		AccountIndex:   w.accountIndex,
+		SubaddrIndices: []uint64{index},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get transfers failed: %w", err)
+	}
+
+	var transfers []*monero.Transfer
+	for _, tx := range resp.In {
+		if tx.Address == address && !tx.DoubleSpendSeen {
+			transfers = append(transfers, tx)
+		}
+	}
+	for _, tx := range resp.Pool {
+		if tx.Address == address && !tx.DoubleSpendSeen {
+			// Confirmations of pool transfers is the height they are expected
+			// in, not a count
+			unconfirmed := *tx
+			unconfirmed.Confirmations = 0
+			transfers = append(transfers, &unconfirmed)
+		}
+	}
+	return transfers, nil
+}
+
+// restoreNextIndex continues subaddress numbering after the highest subaddress
+// the wallet holds in the account. Subaddress N carries the label payment-(N-1).
+func (w *MoneroHDWallet) restoreNextIndex() error {
+	resp, err := w.client.GetAddress(&monero.RequestGetAddress{AccountIndex: w.accountIndex})
+	if err != nil {
+		return fmt.Errorf("list subaddresses: %w", err)
+	}
+	if resp == nil {
+		return errors.New("list subaddresses: empty response")
+	}
+	var highest uint64
+	for _, addr := range resp.Addresses {
+		if addr.AddressIndex > highest {
+			highest = addr.AddressIndex
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextIndex = uint32(highest)
+	return nil
+}
+
+// NextIndex returns the label index the next subaddress will be created with
+func (w *MoneroHDWallet) NextIndex() uint32 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.nextIndex
+}
+
 // GetAddress implements HDWallet interface by deriving next address
 func (w *MoneroHDWallet) GetAddress() (string, error) {
 	address, err := w.DeriveNextAddress()
@@ -126,74 +250,79 @@ func (w *MoneroHDWallet) GetAddress() (string, error) {
 	return address, nil
 }
 
-// GetAddressBalance implements paywall.CryptoClient by getting balance for specific address.
+// GetAddressBalance implements paywall.CryptoClient by summing the transfers
+// received on the given subaddress with at least minConfirmations confirmations.
 //
-// Unlike Bitcoin which queries address-level balance directly from blockchain explorers,
-// Monero uses account-level transfer queries and filters by subaddress. This method:
-//  1. Calls GetTransfers() to retrieve all incoming transfers for account 0
-//  2. Filters transfers by the specific address parameter
-//  3. Sums the amounts for matching transfers
-//
-// This approach is necessary because Monero's RPC provides account-level balance data,
-// not per-subaddress balances. Each payment receives a unique subaddress, and this method
-// ensures payment-to-address binding security by filtering transfers to verify that
-// the specific payment address received funds, preventing false positive confirmations
-// where payment A (address X, unpaid) could incorrectly confirm if payment B (address Y, paid)
-// exists in the same account.
+// Each payment receives its own subaddress, and transfers are read with
+// get_transfers filtered to that subaddress's index, so a payment is only ever
+// confirmed by funds sent to its own address: payment A (address X, unpaid)
+// cannot confirm because payment B (address Y, paid) exists in the same
+// account. Transfers that are not yet confirmed enough do not count.
 //
 // Returns 0 balance if no transfers found for the specified address.
+//
+// Related: GetAddressBalanceMinConf, GetAddressConfirmations
 func (w *MoneroHDWallet) GetAddressBalance(address string) (float64, error) {
-	// Get all incoming transfers for the account
-	resp, err := w.client.GetTransfers(&monero.RequestGetTransfers{
-		In:           true,
-		AccountIndex: 0,
-	})
+	return w.GetAddressBalanceMinConf(address, w.minConfirmations)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only transfers with
+// at least minConf confirmations instead of the wallet's minimum
+func (w *MoneroHDWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	transfers, err := w.incomingTransfers(address)
 	if err != nil {
-		return 0, fmt.Errorf("get transfers failed: %w", err)
+		return 0, err
 	}
 
-	// Filter transfers to the specific address and sum their balance
-	var addressBalance uint64
-	var confirmations uint64
-	found := false
-
-	for _, tx := range resp.In {
-		// Check if transfer is to the requested address
-		// The Address field in monero Transfer indicates the destination subaddress
-		if tx.Address == address {
-			addressBalance += tx.Amount
-			// Store the confirmations for confirmation checking
-			// Use the first matching transaction's data
-			if !found {
-				confirmations = tx.Confirmations
-				found = true
-			}
+	var total uint64
+	for _, tx := range transfers {
+		if int(tx.Confirmations) >= minConf {
+			total += tx.Amount
 		}
 	}
+	return float64(total) / 1e12, nil // Convert atomic units to XMR
+}
 
-	// If no transfer found for this address, return 0 balance
-	if !found {
-		return 0, nil
+// GetAddressConfirmations returns how many confirmations the funds paying
+// amount to address have: the highest count n for which the transfers to the
+// subaddress with at least n confirmations add up to amount.
+//
+// Parameters:
+//   - address: Payment subaddress to check
+//   - amount: Amount in XMR the transfers must add up to
+//   - maxConf: Highest count of interest, usually the confirmations required
+//
+// Returns:
+//   - int: Confirmations, 0 while amount has not been received or is unconfirmed
+//   - error: If the subaddress is unknown or the RPC call fails
+//
+// Related: GetAddressBalanceMinConf, GetTransactionConfirmations
+func (w *MoneroHDWallet) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	transfers, err := w.incomingTransfers(address)
+	if err != nil {
+		return 0, err
 	}
 
-	// Check confirmations meet minimum requirement, but still return balance
-	if int(confirmations) < w.minConfirmations {
-		// Return actual balance but log insufficient confirmations
-		// This allows payment detection while noting confirmation status
-		log.Printf("Monero payment to address %s received but insufficient confirmations: %d/%d", address, confirmations, w.minConfirmations)
-		balance := float64(addressBalance) / 1e12 // Convert atomic units to XMR
-		return balance, nil
+	// Take the best confirmed transfers first until they cover amount; the
+	// last one taken bounds the confirmations of the whole payment
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Confirmations > transfers[j].Confirmations
+	})
+	var total uint64
+	for _, tx := range transfers {
+		total += tx.Amount
+		if float64(total)/1e12 >= amount {
+			return min(int(tx.Confirmations), maxConf), nil
+		}
 	}
-
-	balance := float64(addressBalance) / 1e12 // Convert atomic units to XMR
-	return balance, nil
+	return 0, nil
 }
 
 // GetTransactionConfirmations implements paywall.CryptoClient.
 func (w *MoneroHDWallet) GetTransactionConfirmations(txID string) (int, error) {
 	resp, err := w.client.GetTransfers(&monero.RequestGetTransfers{
 		In:           true,
-		AccountIndex: 0,
+		AccountIndex: w.accountIndex,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("get transfers failed: %w", err)
@@ -208,12 +337,47 @@ func (w *MoneroHDWallet) GetTransactionConfirmations(txID string) (int, error) {
 	return 0, fmt.Errorf("transaction %s not found", txID)
 }
 
+// GetTransactionIDForAddress finds the transaction that paid a payment
+// subaddress: the best confirmed incoming transfer to address of at least
+// amount XMR.
+//
+// Parameters:
+//   - address: Payment subaddress
+//   - amount: Amount in XMR the transfer must carry
+//
+// Returns:
+//   - string: Transaction ID
+//   - error: If no such transfer exists or the RPC call fails
+func (w *MoneroHDWallet) GetTransactionIDForAddress(address string, amount float64) (string, error) {
+	transfers, err := w.incomingTransfers(address)
+	if err != nil {
+		return "", err
+	}
+
+	var best *monero.Transfer
+	for _, tx := range transfers {
+		if float64(tx.Amount)/1e12 < amount {
+			continue
+		}
+		if best == nil || tx.Confirmations > best.Confirmations {
+			best = tx
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no transfer of at least %f XMR to %s", amount, address)
+	}
+	return best.TxID, nil
+}
+
 // GetTransactionIDByAmount finds the transaction ID for an incoming transfer of the specified amount
 // Returns the transaction ID of the first incoming transfer that meets or exceeds the specified amount
+//
+// Deprecated: any transfer to the account matches, including one paying a
+// different payment. Use GetTransactionIDForAddress.
 func (w *MoneroHDWallet) GetTransactionIDByAmount(amount float64) (string, error) {
 	resp, err := w.client.GetTransfers(&monero.RequestGetTransfers{
 		In:           true,
-		AccountIndex: 0,
+		AccountIndex: w.accountIndex,
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to get transfers: %w", err)
@@ -263,6 +427,79 @@ func (w *MoneroHDWallet) GetLatestBlockTime() (time.Time, error) {
 	return estimatedTime, nil
 }
 
+// ErrInvalidTxProof is returned when a Monero transaction proof signature does not verify
+var ErrInvalidTxProof = errors.New("transaction proof is invalid")
+
+// MoneroTxProof is the wallet-verified outcome of a Monero payment proof
+type MoneroTxProof struct {
+	// Received is the amount the transaction sent to the address, in XMR
+	Received float64
+	// Confirmations is the number of blocks mined on top of the transaction
+	Confirmations int
+	// InPool reports whether the transaction is still in the mempool
+	InPool bool
+}
+
+// CheckTxKey verifies, using the sender's transaction secret key, how much a
+// transaction sent to an address. This lets a payer prove a payment without
+// waiting for the wallet to scan it.
+//
+// Parameters:
+//   - txID: Transaction hash
+//   - txKey: Transaction secret key from the sender's wallet (get_tx_key)
+//   - address: Destination address the payment was made to
+//
+// Returns:
+//   - *MoneroTxProof: Amount received by address and confirmation state
+//   - error: If the RPC call fails or the key does not match the transaction
+func (w *MoneroHDWallet) CheckTxKey(txID, txKey, address string) (*MoneroTxProof, error) {
+	resp, err := w.client.CheckTxKey(&monero.RequestCheckTxKey{
+		TxID:    txID,
+		TxKey:   txKey,
+		Address: address,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("check tx key: %w", err)
+	}
+	return &MoneroTxProof{
+		Received:      float64(resp.Received) / 1e12, // Convert atomic units to XMR
+		Confirmations: int(resp.Confirmations),
+		InPool:        resp.InPool,
+	}, nil
+}
+
+// CheckTxProof verifies a transaction proof signature (get_tx_proof on the
+// sender's side) for a payment to address.
+//
+// Parameters:
+//   - txID: Transaction hash
+//   - address: Destination address the payment was made to
+//   - message: Optional message the proof was signed with
+//   - signature: The "OutProofV..." proof string
+//
+// Returns:
+//   - *MoneroTxProof: Amount received by address and confirmation state
+//   - error: ErrInvalidTxProof if the signature does not verify, or RPC errors
+func (w *MoneroHDWallet) CheckTxProof(txID, address, message, signature string) (*MoneroTxProof, error) {
+	resp, err := w.client.CheckTxProof(&monero.RequestCheckTxProof{
+		TxID:      txID,
+		Address:   address,
+		Message:   message,
+		Signature: signature,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("check tx proof: %w", err)
+	}
+	if !resp.Good {
+		return nil, ErrInvalidTxProof
+	}
+	return &MoneroTxProof{
+		Received:      float64(resp.Received) / 1e12, // Convert atomic units to XMR
+		Confirmations: int(resp.Confirmations),
+		InPool:        resp.InPool,
+	}, nil
+}
+
 // Multisig operations
 
 // IsMultisigEnabled returns true if this wallet is configured for multisig operations.
@@ -2,6 +2,8 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	monero "github.com/monero-ecosystem/go-monero-rpc-client/wallet"
@@ -12,6 +14,11 @@ type MockMoneroClient struct {
 	GetBalanceFunc    func(*monero.RequestGetBalance) (*monero.ResponseGetBalance, error)
 	CreateAddressFunc func(*monero.RequestCreateAddress) (*monero.ResponseCreateAddress, error)
 	GetTransfersFunc  func(*monero.RequestGetTransfers) (*monero.ResponseGetTransfers, error)
+	CheckTxKeyFunc    func(*monero.RequestCheckTxKey) (*monero.ResponseCheckTxKey, error)
+	CheckTxProofFunc  func(*monero.RequestCheckTxProof) (*monero.ResponseCheckTxProof, error)
+	GetAddressFunc    func(*monero.RequestGetAddress) (*monero.ResponseGetAddress, error)
+
+	GetAddressIndexFunc func(*monero.RequestGetAddressIndex) (*monero.ResponseGetAddressIndex, error)
 }
 
 func (m *MockMoneroClient) GetBalance(req *monero.RequestGetBalance) (*monero.ResponseGetBalance, error) {
@@ -46,12 +53,18 @@ func (m *MockMoneroClient) GetTransfers(req *monero.RequestGetTransfers) (*moner
 }
 
 // Stub implementations for other Client interface methods to satisfy the interface
-func (m *MockMoneroClient) GetAddress(*monero.RequestGetAddress) (*monero.ResponseGetAddress, error) {
+func (m *MockMoneroClient) GetAddress(req *monero.RequestGetAddress) (*monero.ResponseGetAddress, error) {
+	if m.GetAddressFunc != nil {
+		return m.GetAddressFunc(req)
+	}
 	return nil, nil
 }
 
-func (m *MockMoneroClient) GetAddressIndex(*monero.RequestGetAddressIndex) (*monero.ResponseGetAddressIndex, error) {
-	return nil, nil
+func (m *MockMoneroClient) GetAddressIndex(req *monero.RequestGetAddressIndex) (*monero.ResponseGetAddressIndex, error) {
+	if m.GetAddressIndexFunc != nil {
+		return m.GetAddressIndexFunc(req)
+	}
+	return &monero.ResponseGetAddressIndex{}, nil // Every address is in account 0
 }
 func (m *MockMoneroClient) LabelAddress(*monero.RequestLabelAddress) error { return nil }
 func (m *MockMoneroClient) ValidateAddress(*monero.RequestValidateAddress) (*monero.ResponseValidateAddress, error) {
@@ -143,7 +156,10 @@ func (m *MockMoneroClient) GetTxKey(*monero.RequestGetTxKey) (*monero.ResponseGe
 	return nil, nil
 }
 
-func (m *MockMoneroClient) CheckTxKey(*monero.RequestCheckTxKey) (*monero.ResponseCheckTxKey, error) {
+func (m *MockMoneroClient) CheckTxKey(req *monero.RequestCheckTxKey) (*monero.ResponseCheckTxKey, error) {
+	if m.CheckTxKeyFunc != nil {
+		return m.CheckTxKeyFunc(req)
+	}
 	return nil, nil
 }
 
@@ -151,7 +167,10 @@ func (m *MockMoneroClient) GetTxProof(*monero.RequestGetTxProof) (*monero.Respon
 	return nil, nil
 }
 
-func (m *MockMoneroClient) CheckTxProof(*monero.RequestCheckTxProof) (*monero.ResponseCheckTxProof, error) {
+func (m *MockMoneroClient) CheckTxProof(req *monero.RequestCheckTxProof) (*monero.ResponseCheckTxProof, error) {
+	if m.CheckTxProofFunc != nil {
+		return m.CheckTxProofFunc(req)
+	}
 	return nil, nil
 }
 
@@ -579,14 +598,20 @@ func TestMoneroHDWallet_GetAddressBalance_InsufficientConfirmations(t *testing.T
 	wallet.minConfirmations = 3 // Require 3 confirmations
 
 	balance, err := wallet.GetAddressBalance(testAddress)
-	// Should return actual balance, not zero
 	if err != nil {
 		t.Fatalf("GetAddressBalance() should not error with insufficient confirmations, got: %v", err)
 	}
+	if balance != 0 {
+		t.Errorf("GetAddressBalance() = %v, want 0 until the transfer has 3 confirmations", balance)
+	}
 
-	expectedBalanceXMR := float64(expectedBalance) / 1e12
-	if balance != expectedBalanceXMR {
-		t.Errorf("GetAddressBalance() = %v, want %v (should return actual balance despite insufficient confirmations)", balance, expectedBalanceXMR)
+	// The transfer counts once fewer confirmations are asked for
+	balance, err = wallet.GetAddressBalanceMinConf(testAddress, expectedConfirmations)
+	if err != nil {
+		t.Fatalf("GetAddressBalanceMinConf() error = %v", err)
+	}
+	if want := float64(expectedBalance) / 1e12; balance != want {
+		t.Errorf("GetAddressBalanceMinConf() = %v, want %v", balance, want)
 	}
 }
 
@@ -756,3 +781,217 @@ func TestMoneroHDWallet_GetAddressBalance_MultipleAddresses(t *testing.T) {
 		t.Errorf("Sum of individual balances = %v, want %v", totalBalance, expectedTotal)
 	}
 }
+
+func TestMoneroHDWallet_CheckTxKey(t *testing.T) {
+	var gotReq *monero.RequestCheckTxKey
+	mockClient := &MockMoneroClient{
+		CheckTxKeyFunc: func(req *monero.RequestCheckTxKey) (*monero.ResponseCheckTxKey, error) {
+			gotReq = req
+			return &monero.ResponseCheckTxKey{Received: 250000000000, Confirmations: 3}, nil
+		},
+	}
+	w := createMockMoneroWallet(mockClient)
+
+	proof, err := w.CheckTxKey("tx1", "key1", "8addr")
+	if err != nil {
+		t.Fatalf("CheckTxKey() error = %v", err)
+	}
+	if gotReq.TxID != "tx1" || gotReq.TxKey != "key1" || gotReq.Address != "8addr" {
+		t.Errorf("CheckTxKey() request = %+v", gotReq)
+	}
+	if proof.Received != 0.25 || proof.Confirmations != 3 || proof.InPool {
+		t.Errorf("CheckTxKey() = %+v, want 0.25 XMR with 3 confirmations", proof)
+	}
+
+	mockClient.CheckTxKeyFunc = func(*monero.RequestCheckTxKey) (*monero.ResponseCheckTxKey, error) {
+		return nil, errors.New("rpc error")
+	}
+	if _, err := w.CheckTxKey("tx1", "bad", "8addr"); err == nil {
+		t.Error("CheckTxKey() should return RPC errors")
+	}
+}
+
+func TestMoneroHDWallet_CheckTxProof(t *testing.T) {
+	good := true
+	mockClient := &MockMoneroClient{
+		CheckTxProofFunc: func(req *monero.RequestCheckTxProof) (*monero.ResponseCheckTxProof, error) {
+			return &monero.ResponseCheckTxProof{Good: good, Received: 1000000000000, InPool: true}, nil
+		},
+	}
+	w := createMockMoneroWallet(mockClient)
+
+	proof, err := w.CheckTxProof("tx1", "8addr", "", "OutProofV2...")
+	if err != nil {
+		t.Fatalf("CheckTxProof() error = %v", err)
+	}
+	if proof.Received != 1 || !proof.InPool || proof.Confirmations != 0 {
+		t.Errorf("CheckTxProof() = %+v, want 1 XMR in pool", proof)
+	}
+
+	good = false
+	if _, err := w.CheckTxProof("tx1", "8addr", "", "forged"); !errors.Is(err, ErrInvalidTxProof) {
+		t.Errorf("CheckTxProof() error = %v, want ErrInvalidTxProof", err)
+	}
+}
+
+func TestNewMoneroWallet_RestoresNextIndex(t *testing.T) {
+	listing := func(indexes ...uint64) *monero.ResponseGetAddress {
+		resp := &monero.ResponseGetAddress{}
+		for _, i := range indexes {
+			resp.Addresses = append(resp.Addresses, struct {
+				Address      string `json:"address"`
+				Label        string `json:"label"`
+				AddressIndex uint64 `json:"address_index"`
+				Used         bool   `json:"used"`
+			}{AddressIndex: i})
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name      string
+		resp      *monero.ResponseGetAddress
+		err       error
+		wantIndex uint32
+	}{
+		{"fresh wallet", listing(0), nil, 0},
+		{"existing subaddresses", listing(0, 1, 2, 7), nil, 7},
+		{"listing fails", nil, errors.New("rpc down"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAccount uint64
+			var gotLabel string
+			mock := &MockMoneroClient{
+				GetAddressFunc: func(req *monero.RequestGetAddress) (*monero.ResponseGetAddress, error) {
+					gotAccount = req.AccountIndex
+					return tt.resp, tt.err
+				},
+				CreateAddressFunc: func(req *monero.RequestCreateAddress) (*monero.ResponseCreateAddress, error) {
+					gotLabel = req.Label
+					return &monero.ResponseCreateAddress{Address: "48new"}, nil
+				},
+			}
+			w, err := newMoneroWallet(mock, 3, 1)
+			if err != nil {
+				t.Fatalf("newMoneroWallet() error = %v", err)
+			}
+			if gotAccount != 3 {
+				t.Errorf("listed account %d, want 3", gotAccount)
+			}
+			if got := w.NextIndex(); got != tt.wantIndex {
+				t.Errorf("NextIndex() = %d, want %d", got, tt.wantIndex)
+			}
+			if _, err := w.DeriveNextAddress(); err != nil {
+				t.Fatalf("DeriveNextAddress() error = %v", err)
+			}
+			if want := fmt.Sprintf("payment-%d", tt.wantIndex); gotLabel != want {
+				t.Errorf("label = %q, want %q", gotLabel, want)
+			}
+		})
+	}
+}
+
+func TestMoneroHDWallet_SubaddressTransfers(t *testing.T) {
+	const paid, unpaid = "8paid", "8unpaid"
+	var gotReqs []*monero.RequestGetTransfers
+	lookups := 0
+	mock := &MockMoneroClient{
+		CreateAddressFunc: func(req *monero.RequestCreateAddress) (*monero.ResponseCreateAddress, error) {
+			if strings.HasSuffix(req.Label, "-0") {
+				return &monero.ResponseCreateAddress{Address: paid, AddressIndex: 4}, nil
+			}
+			return &monero.ResponseCreateAddress{Address: unpaid, AddressIndex: 5}, nil
+		},
+		GetAddressIndexFunc: func(req *monero.RequestGetAddressIndex) (*monero.ResponseGetAddressIndex, error) {
+			lookups++
+			resp := &monero.ResponseGetAddressIndex{}
+			resp.Index.Major, resp.Index.Minor = 2, 9
+			return resp, nil
+		},
+		GetTransfersFunc: func(req *monero.RequestGetTransfers) (*monero.ResponseGetTransfers, error) {
+			gotReqs = append(gotReqs, req)
+			resp := &monero.ResponseGetTransfers{}
+			if len(req.SubaddrIndices) == 1 && req.SubaddrIndices[0] == 4 {
+				resp.In = []*monero.Transfer{
+					{TxID: "tx-old", Address: paid, Amount: 400000000000, Confirmations: 12},
+					{TxID: "tx-new", Address: paid, Amount: 600000000000, Confirmations: 3},
+					{TxID: "tx-replayed", Address: paid, Amount: 900000000000, Confirmations: 20, DoubleSpendSeen: true},
+				}
+				resp.Pool = []*monero.Transfer{
+					{TxID: "tx-pool", Address: paid, Amount: 500000000000, Confirmations: 3100000},
+				}
+			}
+			return resp, nil
+		},
+	}
+	w := createMockMoneroWallet(mock)
+	w.accountIndex = 2
+	w.minConfirmations = 10
+	w.subaddrIndex = make(map[string]uint64)
+	for range 2 {
+		if _, err := w.DeriveNextAddress(); err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		minConf int
+		want    float64
+	}{
+		{10, 0.4},
+		{3, 1.0},
+		{0, 1.5},
+	}
+	for _, tt := range tests {
+		if got, err := w.GetAddressBalanceMinConf(paid, tt.minConf); err != nil || got != tt.want {
+			t.Errorf("GetAddressBalanceMinConf(%d) = %v, %v, want %v", tt.minConf, got, err, tt.want)
+		}
+	}
+	if got := gotReqs[0]; !got.In || !got.Pool || got.AccountIndex != 2 || len(got.SubaddrIndices) != 1 {
+		t.Errorf("GetTransfers() request = %+v, want incoming and pool transfers of one subaddress in account 2", got)
+	}
+	// The other payment's subaddress sees none of it
+	if got, _ := w.GetAddressBalanceMinConf(unpaid, 0); got != 0 {
+		t.Errorf("unpaid subaddress balance = %v, want 0", got)
+	}
+	if lookups != 0 {
+		t.Errorf("created subaddresses were looked up %d times, want cached", lookups)
+	}
+
+	confs := []struct {
+		amount  float64
+		maxConf int
+		want    int
+	}{
+		{0.4, 10, 10},
+		{0.4, 20, 12},
+		{1.0, 20, 3},
+		{1.5, 20, 0},
+		{2.0, 20, 0},
+	}
+	for _, tt := range confs {
+		if got, err := w.GetAddressConfirmations(paid, tt.amount, tt.maxConf); err != nil || got != tt.want {
+			t.Errorf("GetAddressConfirmations(%v, %d) = %d, %v, want %d", tt.amount, tt.maxConf, got, err, tt.want)
+		}
+	}
+
+	if txID, err := w.GetTransactionIDForAddress(paid, 0.5); err != nil || txID != "tx-new" {
+		t.Errorf("GetTransactionIDForAddress() = %q, %v, want tx-new", txID, err)
+	}
+	if _, err := w.GetTransactionIDForAddress(unpaid, 0.1); err == nil {
+		t.Error("GetTransactionIDForAddress() of an unpaid subaddress succeeded")
+	}
+
+	// Subaddresses from before a restart are looked up, and must be in the account
+	if _, err := w.GetAddressBalance("8restored"); err != nil || lookups != 1 {
+		t.Errorf("GetAddressBalance() of an unknown subaddress = %v after %d lookups", err, lookups)
+	}
+	if got := gotReqs[len(gotReqs)-1].SubaddrIndices; len(got) != 1 || got[0] != 9 {
+		t.Errorf("restored subaddress queried with indices %v, want [9]", got)
+	}
+	w.accountIndex = 0
+	if _, err := w.GetAddressBalance("8elsewhere"); err == nil {
+		t.Error("GetAddressBalance() of another account's address succeeded")
+	}
+}
@@ -0,0 +1,422 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLWSTimeout bounds each request to the light-wallet server
+const defaultLWSTimeout = 30 * time.Second
+
+// MoneroLWSConfig holds the connection details for a Monero light-wallet server
+// (monero-lws or a compatible OpenMonero/MyMonero REST API).
+//
+// Unlike monero-wallet-rpc, the light-wallet server only needs the wallet's
+// primary address and private view key: subaddresses are derived locally and
+// registered with the server, which scans for them without holding spend keys.
+type MoneroLWSConfig struct {
+	// URL is the base URL of the light-wallet server (e.g. "http://127.0.0.1:8443")
+	URL string
+	// Address is the wallet's primary (standard) address
+	Address string
+	// ViewKey is the hex-encoded 32-byte private view key
+	ViewKey string
+	// Timeout bounds each request. Defaults to 30 seconds when zero.
+	Timeout time.Duration
+}
+
+// MoneroLWSWallet implements the HDWallet interface on top of a Monero
+// light-wallet server. Each payment receives a fresh subaddress (account 0,
+// minor index 1, 2, ...) which is registered with the server via upsert_subaddrs.
+//
+// Balances are computed from get_unspent_outs, attributing each output to the
+// subaddress reported in its "recipient" field. Servers that do not report
+// recipients attribute every output to the primary address, so subaddress
+// balances stay at zero rather than producing false positives.
+type MoneroLWSWallet struct {
+	client           *http.Client
+	baseURL          string
+	address          string
+	viewKey          []byte
+	viewKeyHex       string
+	mu               sync.Mutex
+	nextIndex        uint32
+	minConfirmations int
+	subaddrs         map[[2]uint32]string // (major, minor) -> derived subaddress cache
+}
+
+// lwsAmount decodes amounts that servers encode either as JSON strings or numbers
+type lwsAmount uint64
+
+func (a *lwsAmount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*a = 0
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s: %w", data, err)
+	}
+	*a = lwsAmount(v)
+	return nil
+}
+
+// lwsSubaddrIndex identifies the subaddress an output was received on
+type lwsSubaddrIndex struct {
+	Major uint32 `json:"maj_i"`
+	Minor uint32 `json:"min_i"`
+}
+
+// lwsOutput is a single output from get_unspent_outs
+type lwsOutput struct {
+	Amount    lwsAmount        `json:"amount"`
+	TxHash    string           `json:"tx_hash"`
+	Height    uint64           `json:"height"`
+	Recipient *lwsSubaddrIndex `json:"recipient,omitempty"`
+}
+
+// lwsTransaction is a single transaction from get_address_txs
+type lwsTransaction struct {
+	Hash          string    `json:"hash"`
+	Height        uint64    `json:"height"`
+	Mempool       bool      `json:"mempool"`
+	TotalReceived lwsAmount `json:"total_received"`
+}
+
+// NewMoneroLWSWallet creates a Monero wallet backed by a light-wallet server.
+// The account is logged in (and created if necessary) during construction.
+//
+// Parameters:
+//   - config: Server URL, primary address and private view key
+//   - minConf: Minimum confirmations before an output counts towards a balance
+//
+// Returns:
+//   - *MoneroLWSWallet: Ready-to-use wallet
+//   - error: If the keys are invalid or the server rejects the login
+func NewMoneroLWSWallet(config MoneroLWSConfig, minConf int) (*MoneroLWSWallet, error) {
+	if config.URL == "" {
+		return nil, errors.New("light-wallet server URL is required")
+	}
+	viewKey, err := hex.DecodeString(config.ViewKey)
+	if err != nil || len(viewKey) != 32 {
+		return nil, errors.New("view key must be 64 hex characters")
+	}
+	// Deriving the first subaddress validates the address and that the view key belongs to it
+	first, err := MoneroSubaddress(config.Address, viewKey, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Monero address or view key: %w", err)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultLWSTimeout
+	}
+	w := &MoneroLWSWallet{
+		client:           &http.Client{Timeout: timeout},
+		baseURL:          strings.TrimRight(config.URL, "/"),
+		address:          config.Address,
+		viewKey:          viewKey,
+		viewKeyHex:       strings.ToLower(config.ViewKey),
+		minConfirmations: minConf,
+		subaddrs:         map[[2]uint32]string{{0, 0}: config.Address, {0, 1}: first},
+	}
+
+	login := map[string]interface{}{
+		"address":           w.address,
+		"view_key":          w.viewKeyHex,
+		"create_account":    true,
+		"generated_locally": true,
+	}
+	if err := w.post(context.Background(), "/login", login, nil); err != nil {
+		return nil, fmt.Errorf("monero light-wallet login failed: %w", err)
+	}
+	if err := w.restoreNextIndex(); err != nil {
+		// Servers without get_subaddrs cannot tell us which subaddresses earlier
+		// runs registered; numbering then restarts and addresses may be reused
+		log.Printf("Monero light-wallet subaddress index not restored, subaddresses may be reused: %v", err)
+	}
+	return w, nil
+}
+
+// restoreNextIndex continues after the highest account 0 subaddress registered
+// with the server, so a restarted process never hands out a subaddress again
+func (w *MoneroLWSWallet) restoreNextIndex() error {
+	var resp struct {
+		AllSubaddrs []struct {
+			Key   uint32      `json:"key"`
+			Value [][2]uint32 `json:"value"`
+		} `json:"all_subaddrs"`
+	}
+	if err := w.post(context.Background(), "/get_subaddrs", nil, &resp); err != nil {
+		return err
+	}
+	var highest uint32
+	for _, account := range resp.AllSubaddrs {
+		if account.Key != 0 {
+			continue
+		}
+		for _, r := range account.Value {
+			if r[1] > highest {
+				highest = r[1]
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextIndex = highest
+	return nil
+}
+
+// post sends an authenticated JSON request and decodes the response into out (if non-nil)
+func (w *MoneroLWSWallet) post(ctx context.Context, path string, body map[string]interface{}, out interface{}) error {
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+	body["address"] = w.address
+	body["view_key"] = w.viewKeyHex
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create %s request: %w", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request %s: unexpected status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// subaddress returns the cached or freshly derived subaddress for (major, minor).
+// Callers must hold w.mu.
+func (w *MoneroLWSWallet) subaddress(major, minor uint32) (string, error) {
+	key := [2]uint32{major, minor}
+	if addr, ok := w.subaddrs[key]; ok {
+		return addr, nil
+	}
+	addr, err := MoneroSubaddress(w.address, w.viewKey, major, minor)
+	if err != nil {
+		return "", err
+	}
+	w.subaddrs[key] = addr
+	return addr, nil
+}
+
+// Currency implements HDWallet interface
+func (w *MoneroLWSWallet) Currency() string {
+	return string(Monero)
+}
+
+// DeriveNextAddress implements HDWallet interface by deriving the next subaddress
+// locally and registering it with the light-wallet server so it is scanned.
+func (w *MoneroLWSWallet) DeriveNextAddress() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	minor := w.nextIndex + 1
+	addr, err := w.subaddress(0, minor)
+	if err != nil {
+		return "", fmt.Errorf("derive subaddress: %w", err)
+	}
+
+	req := map[string]interface{}{
+		"subaddrs": []map[string]interface{}{
+			{"key": 0, "value": [][2]uint32{{minor, minor}}},
+		},
+	}
+	if err := w.post(context.Background(), "/upsert_subaddrs", req, nil); err != nil {
+		return "", fmt.Errorf("register subaddress: %w", err)
+	}
+
+	w.nextIndex++
+	return addr, nil
+}
+
+// GetAddress implements HDWallet interface by deriving next address
+func (w *MoneroLWSWallet) GetAddress() (string, error) {
+	address, err := w.DeriveNextAddress()
+	if err != nil {
+		return "", fmt.Errorf("failed to derive address: %w", err)
+	}
+	return address, nil
+}
+
+// blockchainHeight returns the server's current chain height
+func (w *MoneroLWSWallet) blockchainHeight(ctx context.Context) (uint64, error) {
+	var info struct {
+		BlockchainHeight uint64 `json:"blockchain_height"`
+	}
+	if err := w.post(ctx, "/get_address_info", nil, &info); err != nil {
+		return 0, err
+	}
+	return info.BlockchainHeight, nil
+}
+
+// lwsConfirmations computes confirmations for a transaction mined at height (0 = unconfirmed)
+func lwsConfirmations(chainHeight, txHeight uint64) int {
+	if txHeight == 0 || chainHeight < txHeight {
+		return 0
+	}
+	return int(chainHeight - txHeight + 1)
+}
+
+// GetAddressBalance implements paywall.CryptoClient by summing the unspent outputs
+// received on the given subaddress with at least minConfirmations confirmations.
+//
+// Outputs that have since been spent by the merchant no longer count, so funds
+// should not be swept from payment subaddresses before payments confirm.
+func (w *MoneroLWSWallet) GetAddressBalance(address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, w.minConfirmations)
+}
+
+// GetAddressBalanceContext is GetAddressBalance with a context that cancels
+// the light-wallet server requests
+func (w *MoneroLWSWallet) GetAddressBalanceContext(ctx context.Context, address string) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(ctx, address, w.minConfirmations)
+}
+
+// GetAddressBalanceMinConf is GetAddressBalance counting only outputs with at
+// least minConf confirmations instead of the wallet's minimum
+func (w *MoneroLWSWallet) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	return w.GetAddressBalanceMinConfContext(context.Background(), address, minConf)
+}
+
+// GetAddressBalanceMinConfContext is GetAddressBalanceMinConf with a context
+func (w *MoneroLWSWallet) GetAddressBalanceMinConfContext(ctx context.Context, address string, minConf int) (float64, error) {
+	height, err := w.blockchainHeight(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("get blockchain height: %w", err)
+	}
+
+	var resp struct {
+		Outputs []lwsOutput `json:"outputs"`
+	}
+	req := map[string]interface{}{
+		"amount":         "0",
+		"mixin":          0,
+		"use_dust":       true,
+		"dust_threshold": "0",
+	}
+	if err := w.post(ctx, "/get_unspent_outs", req, &resp); err != nil {
+		return 0, fmt.Errorf("get unspent outputs failed: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var total uint64
+	for _, out := range resp.Outputs {
+		var recipient lwsSubaddrIndex
+		if out.Recipient != nil {
+			recipient = *out.Recipient
+		}
+		outAddr, err := w.subaddress(recipient.Major, recipient.Minor)
+		if err != nil {
+			return 0, fmt.Errorf("derive subaddress: %w", err)
+		}
+		if outAddr != address {
+			continue
+		}
+		if lwsConfirmations(height, out.Height) < minConf {
+			continue
+		}
+		total += uint64(out.Amount)
+	}
+
+	return float64(total) / 1e12, nil // Convert atomic units to XMR
+}
+
+// GetTransactionConfirmations implements paywall.CryptoClient.
+func (w *MoneroLWSWallet) GetTransactionConfirmations(txID string) (int, error) {
+	var resp struct {
+		BlockchainHeight uint64           `json:"blockchain_height"`
+		Transactions     []lwsTransaction `json:"transactions"`
+	}
+	if err := w.post(context.Background(), "/get_address_txs", nil, &resp); err != nil {
+		return 0, fmt.Errorf("get address transactions failed: %w", err)
+	}
+
+	for _, tx := range resp.Transactions {
+		if tx.Hash == txID {
+			if tx.Mempool {
+				return 0, nil
+			}
+			return lwsConfirmations(resp.BlockchainHeight, tx.Height), nil
+		}
+	}
+
+	return 0, fmt.Errorf("transaction %s not found", txID)
+}
+
+// RollbackLastAddress decrements the next index counter
+// This is used for atomic payment operations - when payment storage fails
+// after address generation, we need to rollback the address index
+func (w *MoneroLWSWallet) RollbackLastAddress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.nextIndex > 0 {
+		w.nextIndex--
+	}
+}
+
+// GetLatestBlockTime estimates the timestamp of the latest Monero block from the
+// server's chain height, using the same approximation as MoneroHDWallet
+func (w *MoneroLWSWallet) GetLatestBlockTime() (time.Time, error) {
+	height, err := w.blockchainHeight(context.Background())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get blockchain height: %w", err)
+	}
+	genesisTime := time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+	return genesisTime.Add(time.Duration(height) * 2 * time.Minute), nil
+}
+
+// Multisig operations are not available through a view-only light-wallet server
+
+// IsMultisigEnabled always returns false for light-wallet backed wallets
+func (w *MoneroLWSWallet) IsMultisigEnabled() bool {
+	return false
+}
+
+// GetMultisigConfig returns ErrMultisigNotSupported
+func (w *MoneroLWSWallet) GetMultisigConfig() (*MultisigConfig, error) {
+	return nil, ErrMultisigNotSupported
+}
+
+// DeriveMultisigAddress returns ErrMultisigNotSupported
+func (w *MoneroLWSWallet) DeriveMultisigAddress(pubKeys [][]byte, requiredSigs int) (string, *MultisigMetadata, error) {
+	return "", nil, ErrMultisigNotSupported
+}
+
+// CreateRedeemScript returns ErrMultisigNotSupported
+func (w *MoneroLWSWallet) CreateRedeemScript(pubKeys [][]byte, requiredSigs int) ([]byte, error) {
+	return nil, ErrMultisigNotSupported
+}
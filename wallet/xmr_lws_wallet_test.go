@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeLWS is a minimal light-wallet server for tests
+type fakeLWS struct {
+	mu       sync.Mutex
+	height   uint64
+	outputs  []map[string]interface{}
+	txs      []map[string]interface{}
+	upserted [][2]uint32
+	calls    map[string]int
+}
+
+func (f *fakeLWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[r.URL.Path]++
+
+	var req map[string]json.RawMessage
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	var viewKey string
+	_ = json.Unmarshal(req["view_key"], &viewKey)
+	if viewKey != testXMRViewKey {
+		http.Error(w, "bad view key", http.StatusForbidden)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/login":
+		json.NewEncoder(w).Encode(map[string]interface{}{"new_address": true, "start_height": 100})
+	case "/upsert_subaddrs":
+		var body struct {
+			Subaddrs []struct {
+				Key   uint32      `json:"key"`
+				Value [][2]uint32 `json:"value"`
+			} `json:"subaddrs"`
+		}
+		raw, _ := json.Marshal(req)
+		_ = json.Unmarshal(raw, &body)
+		for _, s := range body.Subaddrs {
+			f.upserted = append(f.upserted, s.Value...)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	case "/get_subaddrs":
+		if len(f.upserted) == 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"all_subaddrs": []interface{}{}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"all_subaddrs": []map[string]interface{}{{"key": 0, "value": f.upserted}},
+		})
+	case "/get_address_info":
+		json.NewEncoder(w).Encode(map[string]interface{}{"blockchain_height": f.height})
+	case "/get_unspent_outs":
+		json.NewEncoder(w).Encode(map[string]interface{}{"outputs": f.outputs})
+	case "/get_address_txs":
+		json.NewEncoder(w).Encode(map[string]interface{}{"blockchain_height": f.height, "transactions": f.txs})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestLWSWallet(t *testing.T, fake *fakeLWS, minConf int) *MoneroLWSWallet {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	w, err := NewMoneroLWSWallet(MoneroLWSConfig{
+		URL:     server.URL,
+		Address: testXMRAddress,
+		ViewKey: testXMRViewKey,
+	}, minConf)
+	if err != nil {
+		t.Fatalf("NewMoneroLWSWallet() error = %v", err)
+	}
+	return w
+}
+
+func TestNewMoneroLWSWallet_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MoneroLWSConfig
+	}{
+		{"MissingURL", MoneroLWSConfig{Address: testXMRAddress, ViewKey: testXMRViewKey}},
+		{"BadViewKeyHex", MoneroLWSConfig{URL: "http://127.0.0.1:1", Address: testXMRAddress, ViewKey: "zz"}},
+		{"WrongViewKey", MoneroLWSConfig{URL: "http://127.0.0.1:1", Address: testXMRAddress, ViewKey: "0101010101010101010101010101010101010101010101010101010101010101"}},
+		{"BadAddress", MoneroLWSConfig{URL: "http://127.0.0.1:1", Address: "not-an-address", ViewKey: testXMRViewKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMoneroLWSWallet(tt.config, 1); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestMoneroLWSWallet_DeriveAndBalance(t *testing.T) {
+	fake := &fakeLWS{height: 1000}
+	w := newTestLWSWallet(t, fake, 2)
+
+	if w.Currency() != string(Monero) {
+		t.Errorf("Currency() = %s, want %s", w.Currency(), Monero)
+	}
+
+	addr1, err := w.GetAddress()
+	if err != nil {
+		t.Fatalf("GetAddress() error = %v", err)
+	}
+	addr2, err := w.GetAddress()
+	if err != nil {
+		t.Fatalf("GetAddress() error = %v", err)
+	}
+	if addr1 == addr2 || addr1 == testXMRAddress {
+		t.Fatalf("expected distinct subaddresses, got %s and %s", addr1, addr2)
+	}
+	if len(fake.upserted) != 2 || fake.upserted[0] != [2]uint32{1, 1} || fake.upserted[1] != [2]uint32{2, 2} {
+		t.Errorf("upserted ranges = %v, want [[1 1] [2 2]]", fake.upserted)
+	}
+
+	fake.outputs = []map[string]interface{}{
+		// Confirmed payment to subaddress 1 (amount as string, as monero-lws sends it)
+		{"amount": "500000000000", "tx_hash": "aa", "height": 990, "recipient": map[string]int{"maj_i": 0, "min_i": 1}},
+		// Unconfirmed payment to subaddress 1 (1 confirmation < 2)
+		{"amount": 100000000000, "tx_hash": "bb", "height": 1000, "recipient": map[string]int{"maj_i": 0, "min_i": 1}},
+		// Output without recipient is attributed to the primary address
+		{"amount": "700000000000", "tx_hash": "cc", "height": 900},
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		want    float64
+	}{
+		{"ConfirmedOnly", addr1, 0.5},
+		{"OtherSubaddress", addr2, 0},
+		{"Primary", testXMRAddress, 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.GetAddressBalance(tt.address)
+			if err != nil {
+				t.Fatalf("GetAddressBalance() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetAddressBalance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	w.RollbackLastAddress()
+	addr2Again, err := w.DeriveNextAddress()
+	if err != nil || addr2Again != addr2 {
+		t.Errorf("after rollback DeriveNextAddress() = %s, %v; want %s", addr2Again, err, addr2)
+	}
+}
+
+func TestMoneroLWSWallet_GetTransactionConfirmations(t *testing.T) {
+	fake := &fakeLWS{height: 1000, txs: []map[string]interface{}{
+		{"hash": "mined", "height": 995, "total_received": "1"},
+		{"hash": "pending", "mempool": true, "total_received": "1"},
+	}}
+	w := newTestLWSWallet(t, fake, 1)
+
+	if got, err := w.GetTransactionConfirmations("mined"); err != nil || got != 6 {
+		t.Errorf("GetTransactionConfirmations(mined) = %d, %v; want 6", got, err)
+	}
+	if got, err := w.GetTransactionConfirmations("pending"); err != nil || got != 0 {
+		t.Errorf("GetTransactionConfirmations(pending) = %d, %v; want 0", got, err)
+	}
+	if _, err := w.GetTransactionConfirmations("missing"); err == nil {
+		t.Error("expected error for unknown transaction")
+	}
+}
+
+func TestMoneroLWSWallet_MultisigNotSupported(t *testing.T) {
+	w := newTestLWSWallet(t, &fakeLWS{}, 1)
+	if w.IsMultisigEnabled() {
+		t.Error("IsMultisigEnabled() = true, want false")
+	}
+	if _, err := w.GetMultisigConfig(); err != ErrMultisigNotSupported {
+		t.Errorf("GetMultisigConfig() error = %v, want ErrMultisigNotSupported", err)
+	}
+}
+
+func TestMoneroLWSWallet_RestoresNextIndexAfterRestart(t *testing.T) {
+	fake := &fakeLWS{height: 1000}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	config := MoneroLWSConfig{URL: server.URL, Address: testXMRAddress, ViewKey: testXMRViewKey}
+
+	first, err := NewMoneroLWSWallet(config, 1)
+	if err != nil {
+		t.Fatalf("NewMoneroLWSWallet() error = %v", err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		addr, err := first.DeriveNextAddress()
+		if err != nil {
+			t.Fatalf("DeriveNextAddress() error = %v", err)
+		}
+		seen[addr] = true
+	}
+
+	restarted, err := NewMoneroLWSWallet(config, 1)
+	if err != nil {
+		t.Fatalf("NewMoneroLWSWallet() after restart error = %v", err)
+	}
+	addr, err := restarted.DeriveNextAddress()
+	if err != nil {
+		t.Fatalf("DeriveNextAddress() error = %v", err)
+	}
+	if seen[addr] {
+		t.Fatalf("restarted wallet reused subaddress %s", addr)
+	}
+	if last := fake.upserted[len(fake.upserted)-1]; last != [2]uint32{3, 3} {
+		t.Errorf("registered %v after restart, want [3 3]", last)
+	}
+}
@@ -0,0 +1,173 @@
+package paywall
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// WalletRotation selects which of Config.BTCWallets receives a payment
+type WalletRotation string
+
+const (
+	// RotationRoundRobin gives each payment the next wallet in turn
+	RotationRoundRobin WalletRotation = "round_robin"
+	// RotationFill gives Config.BTCWalletFillAddresses consecutive payments
+	// to one wallet before moving to the next, returning to the first after
+	// the last
+	RotationFill WalletRotation = "fill"
+)
+
+// defaultWalletFillAddresses is the default Config.BTCWalletFillAddresses
+const defaultWalletFillAddresses = 100
+
+// NamedWallet is one of the wallets in Config.BTCWallets
+type NamedWallet struct {
+	// ID identifies the wallet in Payment.WalletIDs; keep it stable across
+	// restarts, e.g. "cold-1" or the seed's fingerprint
+	ID string
+	// Wallet derives the wallet's addresses, e.g. a *wallet.BTCHDWallet
+	// restored with wallet.LoadBTCHDWallet
+	Wallet wallet.HDWallet
+}
+
+// walletRotation spreads address derivation over several wallets of one
+// currency. It is installed as the currency's HDWallet; methods other than
+// address derivation use the first wallet, as balance and transaction lookups
+// do not depend on the wallet that derived an address.
+type walletRotation struct {
+	// HDWallet is the first wallet
+	wallet.HDWallet
+
+	wallets []NamedWallet
+	policy  WalletRotation
+	fill    int
+
+	mu sync.Mutex
+	// current is the index of the wallet in use
+	current int
+	// used counts the payments given to the current wallet (RotationFill)
+	used int
+	// last is the wallet that derived the last address, for rollback
+	last wallet.HDWallet
+}
+
+// newWalletRotation creates the rotation of config.BTCWallets
+func newWalletRotation(config Config) *walletRotation {
+	return &walletRotation{
+		HDWallet: config.BTCWallets[0].Wallet,
+		wallets:  config.BTCWallets,
+		policy:   config.BTCWalletRotation,
+		fill:     config.BTCWalletFillAddresses,
+		// Both policies move on to the first wallet for the first payment
+		current: len(config.BTCWallets) - 1,
+		used:    config.BTCWalletFillAddresses,
+	}
+}
+
+// GetAddressBalanceMinConf implements MinConfBalanceClient when the first
+// wallet does
+func (w *walletRotation) GetAddressBalanceMinConf(address string, minConf int) (float64, error) {
+	client, ok := w.HDWallet.(MinConfBalanceClient)
+	if !ok {
+		return w.HDWallet.GetAddressBalance(address)
+	}
+	return client.GetAddressBalanceMinConf(address, minConf)
+}
+
+// GetAddressConfirmations implements ConfirmationCountClient when the first
+// wallet does, and reports 0 confirmations otherwise
+func (w *walletRotation) GetAddressConfirmations(address string, amount float64, maxConf int) (int, error) {
+	client, ok := w.HDWallet.(ConfirmationCountClient)
+	if !ok {
+		return 0, nil
+	}
+	return client.GetAddressConfirmations(address, amount, maxConf)
+}
+
+// DeriveNextAddress implements wallet.HDWallet
+func (w *walletRotation) DeriveNextAddress() (string, error) {
+	_, address, err := w.deriveNext()
+	return address, err
+}
+
+// deriveNext derives an address from the wallet selected by the policy
+//
+// Returns:
+//   - string: ID of the wallet that derived the address
+//   - string: The address
+//   - error: If the wallet fails to derive an address
+func (w *walletRotation) deriveNext() (string, string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.policy == RotationFill {
+		if w.used >= w.fill {
+			w.current = (w.current + 1) % len(w.wallets)
+			w.used = 0
+		}
+	} else {
+		w.current = (w.current + 1) % len(w.wallets)
+	}
+	named := w.wallets[w.current]
+	address, err := named.Wallet.DeriveNextAddress()
+	if err != nil {
+		return "", "", fmt.Errorf("wallet %s: %w", named.ID, err)
+	}
+	w.used++
+	w.last = named.Wallet
+	return named.ID, address, nil
+}
+
+// RollbackLastAddress returns the last derived address to the wallet that
+// derived it, so the next payment reuses its index
+func (w *walletRotation) RollbackLastAddress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if rollback, ok := w.last.(interface{ RollbackLastAddress() }); ok {
+		rollback.RollbackLastAddress()
+		if w.used > 0 {
+			w.used--
+		}
+	}
+	w.last = nil
+}
+
+// validateBTCWallets checks the wallet rotation settings of config
+func validateBTCWallets(config *Config) error {
+	if len(config.BTCWallets) == 0 {
+		if config.BTCWalletRotation != "" || config.BTCWalletFillAddresses != 0 {
+			return fmt.Errorf("BTCWalletRotation and BTCWalletFillAddresses require BTCWallets (hint: list the wallets to rotate)")
+		}
+		return nil
+	}
+	if config.MultisigEnabled {
+		return fmt.Errorf("BTCWallets cannot be combined with MultisigEnabled (hint: multisig addresses are derived from ParticipantPubKeys)")
+	}
+	seen := make(map[string]bool, len(config.BTCWallets))
+	for i, named := range config.BTCWallets {
+		if named.ID == "" {
+			return fmt.Errorf("BTCWallets[%d]: ID is required (hint: use a stable name such as \"cold-1\")", i)
+		}
+		if seen[named.ID] {
+			return fmt.Errorf("BTCWallets[%d]: duplicate ID %q", i, named.ID)
+		}
+		seen[named.ID] = true
+		if named.Wallet == nil {
+			return fmt.Errorf("BTCWallets[%d] (%s): Wallet is required (hint: restore it with wallet.LoadBTCHDWallet)", i, named.ID)
+		}
+		if currency := named.Wallet.Currency(); currency != string(wallet.Bitcoin) {
+			return fmt.Errorf("BTCWallets[%d] (%s): %s wallet, want a Bitcoin wallet", i, named.ID, currency)
+		}
+	}
+	switch config.BTCWalletRotation {
+	case "", RotationRoundRobin, RotationFill:
+	default:
+		return fmt.Errorf("BTCWalletRotation: unknown policy %q (hint: use RotationRoundRobin or RotationFill)", config.BTCWalletRotation)
+	}
+	if config.BTCWalletFillAddresses < 0 {
+		return fmt.Errorf("BTCWalletFillAddresses must not be negative, got %d", config.BTCWalletFillAddresses)
+	}
+	return nil
+}
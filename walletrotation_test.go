@@ -0,0 +1,142 @@
+package paywall
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+func newRotationTestWallets(t *testing.T, ids ...string) []NamedWallet {
+	t.Helper()
+	var wallets []NamedWallet
+	for i, id := range ids {
+		w, err := wallet.NewBTCHDWallet(bytes.Repeat([]byte{byte(i + 1)}, 32), true, 1)
+		if err != nil {
+			t.Fatalf("NewBTCHDWallet() error = %v", err)
+		}
+		wallets = append(wallets, NamedWallet{ID: id, Wallet: w})
+	}
+	return wallets
+}
+
+// failingCreateStore rejects every new payment
+type failingCreateStore struct{ PaymentStore }
+
+func (failingCreateStore) CreatePayment(*Payment) error { return errors.New("disk full") }
+
+func TestWalletRotation_Policies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy WalletRotation
+		fill   int
+		want   []string
+	}{
+		{"round robin by default", "", 0, []string{"a", "b", "c", "a", "b"}},
+		{"fill", RotationFill, 2, []string{"a", "a", "b", "b", "c", "c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pw, err := NewPaywall(Config{
+				PriceInBTC:             0.001,
+				PaymentTimeout:         time.Hour,
+				TestNet:                true,
+				Store:                  NewMemoryStore(),
+				BTCWallets:             newRotationTestWallets(t, "a", "b", "c"),
+				BTCWalletRotation:      tt.policy,
+				BTCWalletFillAddresses: tt.fill,
+			})
+			if err != nil {
+				t.Fatalf("NewPaywall() error = %v", err)
+			}
+			t.Cleanup(pw.Close)
+
+			addresses := make(map[string]bool)
+			for i, want := range tt.want {
+				payment, err := pw.CreatePayment()
+				if err != nil {
+					t.Fatalf("CreatePayment() error = %v", err)
+				}
+				if got := payment.WalletIDs[wallet.Bitcoin]; got != want {
+					t.Errorf("payment %d wallet = %q, want %q", i, got, want)
+				}
+				address := payment.Addresses[wallet.Bitcoin]
+				if addresses[address] {
+					t.Errorf("payment %d reuses address %s", i, address)
+				}
+				addresses[address] = true
+
+				stored, _ := pw.Store.GetPayment(payment.ID)
+				if stored.WalletIDs[wallet.Bitcoin] != want {
+					t.Errorf("stored payment %d wallet = %q, want %q", i, stored.WalletIDs[wallet.Bitcoin], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWalletRotation_Rollback(t *testing.T) {
+	wallets := newRotationTestWallets(t, "a", "b")
+	pw, err := NewPaywall(Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          failingCreateStore{NewMemoryStore()},
+		BTCWallets:     wallets,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	t.Cleanup(pw.Close)
+
+	if _, err := pw.CreatePayment(); err == nil {
+		t.Fatal("CreatePayment() succeeded with a failing store")
+	}
+	for _, named := range wallets {
+		if next := named.Wallet.(*wallet.BTCHDWallet).GetNextIndex(); next != 0 {
+			t.Errorf("wallet %s next index = %d after rollback, want 0", named.ID, next)
+		}
+	}
+}
+
+func TestWalletRotation_Config(t *testing.T) {
+	wallets := newRotationTestWallets(t, "a", "b")
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing ID", func(c *Config) { c.BTCWallets = []NamedWallet{{Wallet: wallets[0].Wallet}} }, "ID is required"},
+		{"duplicate ID", func(c *Config) { c.BTCWallets = []NamedWallet{wallets[0], wallets[0]} }, "duplicate ID"},
+		{"missing wallet", func(c *Config) { c.BTCWallets = []NamedWallet{{ID: "x"}} }, "Wallet is required"},
+		{"unknown policy", func(c *Config) { c.BTCWalletRotation = "random" }, "unknown policy"},
+		{"negative fill", func(c *Config) { c.BTCWalletFillAddresses = -1 }, "must not be negative"},
+		{"policy without wallets", func(c *Config) { c.BTCWallets = nil; c.BTCWalletRotation = RotationFill }, "require BTCWallets"},
+		{"multisig", func(c *Config) { c.MultisigEnabled = true }, "MultisigEnabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{
+				PriceInBTC:     0.001,
+				PaymentTimeout: time.Hour,
+				Store:          NewMemoryStore(),
+				BTCWallets:     wallets,
+			}
+			tt.modify(&config)
+			err := validateBTCWallets(&config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateBTCWallets() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateBTCWallets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
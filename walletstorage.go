@@ -0,0 +1,171 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// validateBTCWalletStorage checks Config.BTCWalletStorage,
+// Config.BTCRecoveryGapLimit and Config.BTCMnemonic
+func validateBTCWalletStorage(config Config) error {
+	if config.BTCMnemonic != "" || config.BTCMnemonicPassphrase != "" {
+		if len(config.BTCWallets) > 0 {
+			return fmt.Errorf("BTCMnemonic and BTCMnemonicPassphrase cannot be combined with BTCWallets (hint: restore each rotated wallet with wallet.NewBTCHDWalletFromMnemonic)")
+		}
+		if config.BTCMnemonic != "" && !wallet.ValidateMnemonic(config.BTCMnemonic) {
+			return fmt.Errorf("BTCMnemonic is not a valid BIP39 mnemonic (hint: check the spelling and order of the words)")
+		}
+	}
+	if config.BTCMnemonicLegacySeed && config.BTCMnemonic == "" {
+		return fmt.Errorf("BTCMnemonicLegacySeed requires BTCMnemonic (hint: it only restores existing wallets; generated wallets use the full seed)")
+	}
+	if config.BTCWalletStorage == nil {
+		if !config.TestNet && config.PriceInBTC > 0 && config.BTCMnemonic == "" && config.BTCWatchKey == "" && len(config.BTCWallets) == 0 && !config.MultisigEnabled {
+			return fmt.Errorf("BTCWalletStorage is required for a mainnet Bitcoin wallet (hint: a wallet generated on every start loses the keys of earlier payments' addresses, and their funds; set BTCWalletStorage, BTCMnemonic or BTCWatchKey, or use ConstructPaywall)")
+		}
+		if config.BTCRecoveryGapLimit != 0 {
+			return fmt.Errorf("BTCRecoveryGapLimit requires BTCWalletStorage (hint: a wallet generated on start has no used addresses to recover)")
+		}
+		return nil
+	}
+	if len(config.BTCWallets) > 0 {
+		return fmt.Errorf("BTCWalletStorage cannot be combined with BTCWallets (hint: save each rotated wallet with SaveToFile and load it with wallet.LoadBTCHDWallet)")
+	}
+	if config.BTCWalletStorage.DataDir == "" {
+		return fmt.Errorf("BTCWalletStorage: DataDir is required (hint: a directory only the paywall's user can read, e.g. /var/lib/paywall)")
+	}
+	if len(config.BTCWalletStorage.EncryptionKey) != 32 {
+		return fmt.Errorf("BTCWalletStorage: EncryptionKey must be 32 bytes, got %d (hint: generate one once with wallet.GenerateEncryptionKey and keep it with your secrets)", len(config.BTCWalletStorage.EncryptionKey))
+	}
+	if config.BTCRecoveryGapLimit < 0 {
+		return fmt.Errorf("BTCRecoveryGapLimit must not be negative, got %d", config.BTCRecoveryGapLimit)
+	}
+	return nil
+}
+
+// initializeBTCWallet returns the Bitcoin wallet of NewPaywall: the wallet
+// saved in Config.BTCWalletStorage, or a new one from Config.BTCMnemonic or a
+// random mnemonic, saved there when storage is configured. A wallet that
+// exists but cannot be loaded is an error rather than replaced, since
+// replacing it loses its funds.
+func initializeBTCWallet(config Config) (*wallet.BTCHDWallet, error) {
+	var loaded *wallet.BTCHDWallet
+	if config.BTCWalletStorage != nil {
+		var err error
+		loaded, err = wallet.LoadBTCHDWallet(*config.BTCWalletStorage, config.TestNet, config.MinConfirmations)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("load bitcoin wallet from %s: %w", config.BTCWalletStorage.DataDir, err)
+		}
+		if loaded != nil && config.BTCMnemonic == "" {
+			return loaded, nil
+		}
+		if loaded == nil && config.BTCMnemonic == "" && config.ExternalMonitor {
+			return nil, fmt.Errorf("ExternalMonitor requires an existing wallet in %s (hint: every instance would generate its own; set BTCMnemonic or BTCWatchKey, or create the wallet once without ExternalMonitor)", config.BTCWalletStorage.DataDir)
+		}
+	}
+
+	mnemonic := config.BTCMnemonic
+	if mnemonic == "" {
+		entropy := make([]byte, 32)
+		if err := readRandom(config.Rand, entropy); err != nil {
+			return nil, fmt.Errorf("generate seed: %w", err)
+		}
+		var err error
+		if mnemonic, err = wallet.NewMnemonic(entropy); err != nil {
+			return nil, fmt.Errorf("generate mnemonic: %w", err)
+		}
+	}
+	newWallet := wallet.NewBTCHDWalletFromMnemonic
+	if config.BTCMnemonicLegacySeed {
+		newWallet = wallet.NewLegacyBTCHDWalletFromMnemonic
+	}
+	btcWallet, err := newWallet(mnemonic, config.BTCMnemonicPassphrase, config.TestNet, config.MinConfirmations)
+	if err != nil {
+		return nil, fmt.Errorf("create wallet: %w", err)
+	}
+	if loaded != nil {
+		// Keep the stored index of the wallet the mnemonic restores
+		if loaded.ExportExtendedKey() != btcWallet.ExportExtendedKey() {
+			return nil, fmt.Errorf("BTCMnemonic does not match the wallet in %s (hint: check BTCMnemonicPassphrase and BTCMnemonicLegacySeed, or move the old wallet.dat away after sweeping its funds)", config.BTCWalletStorage.DataDir)
+		}
+		return loaded, nil
+	}
+	if config.BTCWalletStorage != nil {
+		if err := btcWallet.SaveToFile(*config.BTCWalletStorage); err != nil {
+			return nil, fmt.Errorf("save bitcoin wallet: %w", err)
+		}
+	}
+	return btcWallet, nil
+}
+
+// recoverBTCWallet moves the next address index of the stored Bitcoin wallet
+// past the addresses that received funds, when Config.BTCRecoveryGapLimit is
+// set. The addresses are checked with the client payments are verified with.
+// A failed scan is logged and the saved index kept.
+func (p *Paywall) recoverBTCWallet(config Config) {
+	if config.BTCRecoveryGapLimit == 0 {
+		return
+	}
+	btcWallet, ok := p.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet)
+	if !ok {
+		return
+	}
+	var source wallet.ReceivedBalanceSource
+	if client, ok := initializeBitcoinClient(config).(wallet.ReceivedBalanceSource); ok {
+		source = client
+	}
+
+	before := btcWallet.GetNextIndex()
+	next, err := btcWallet.RecoverNextIndex(source, config.BTCRecoveryGapLimit)
+	if err != nil {
+		p.logger.log(LogEntry{
+			Level:   LogLevelWarn,
+			Event:   "btc_wallet_recovery_failed",
+			Message: fmt.Sprintf("Could not scan the Bitcoin wallet for used addresses, continuing at index %d: %v", before, err),
+		})
+		return
+	}
+	if next == before {
+		return
+	}
+	p.logger.log(LogEntry{
+		Level:   LogLevelWarn,
+		Event:   "btc_wallet_recovered",
+		Message: fmt.Sprintf("Bitcoin wallet had used addresses past its saved index; continuing at index %d instead of %d", next, before),
+	})
+	if err := p.persistWallets(); err != nil {
+		p.logWalletSaveFailure(err)
+	}
+}
+
+// persistWallets saves the Bitcoin wallet, and with it the next address
+// index, when the paywall was given wallet storage. Saves are serialized so
+// that an older index never overwrites a newer one.
+func (p *Paywall) persistWallets() error {
+	if p.walletStorage == nil {
+		return nil
+	}
+	btcWallet, ok := p.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet)
+	if !ok {
+		return nil
+	}
+	p.walletSaveMu.Lock()
+	defer p.walletSaveMu.Unlock()
+	if err := btcWallet.SaveToFile(*p.walletStorage); err != nil {
+		return fmt.Errorf("save bitcoin wallet: %w", err)
+	}
+	return nil
+}
+
+// logWalletSaveFailure logs a failed save of the Bitcoin wallet. The next
+// save, at the latest the one of Close, stores the index again.
+func (p *Paywall) logWalletSaveFailure(err error) {
+	p.logger.log(LogEntry{
+		Level:   LogLevelError,
+		Event:   "btc_wallet_save_failed",
+		Message: fmt.Sprintf("Bitcoin wallet index not saved; addresses may be handed out again after a restart: %v", err),
+	})
+}
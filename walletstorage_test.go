@@ -0,0 +1,198 @@
+package paywall
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// testMnemonic is the BIP39 test mnemonic
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestValidateBTCWalletStorage(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"not configured", Config{}, ""},
+		{"testnet without storage", Config{PriceInBTC: 0.001, TestNet: true}, ""},
+		{"mainnet without storage", Config{PriceInBTC: 0.001}, "BTCWalletStorage is required for a mainnet"},
+		{"mainnet watch key", Config{PriceInBTC: 0.001, BTCWatchKey: "xpub"}, ""},
+		{"configured", Config{BTCWalletStorage: &wallet.StorageConfig{DataDir: "w", EncryptionKey: key}, BTCRecoveryGapLimit: 20}, ""},
+		{"gap limit without storage", Config{BTCRecoveryGapLimit: 20}, "BTCRecoveryGapLimit requires BTCWalletStorage"},
+		{"no data dir", Config{BTCWalletStorage: &wallet.StorageConfig{EncryptionKey: key}}, "DataDir is required"},
+		{"short key", Config{BTCWalletStorage: &wallet.StorageConfig{DataDir: "w", EncryptionKey: key[:16]}}, "EncryptionKey must be 32 bytes"},
+		{"negative gap limit", Config{BTCWalletStorage: &wallet.StorageConfig{DataDir: "w", EncryptionKey: key}, BTCRecoveryGapLimit: -1}, "must not be negative"},
+		{"with rotation", Config{BTCWalletStorage: &wallet.StorageConfig{DataDir: "w", EncryptionKey: key}, BTCWallets: []NamedWallet{{ID: "a"}}}, "cannot be combined with BTCWallets"},
+		{"mnemonic", Config{BTCMnemonic: testMnemonic, BTCMnemonicPassphrase: "secret"}, ""},
+		{"invalid mnemonic", Config{BTCMnemonic: "abandon about"}, "not a valid BIP39 mnemonic"},
+		{"legacy seed", Config{BTCMnemonic: testMnemonic, BTCMnemonicLegacySeed: true}, ""},
+		{"legacy seed without mnemonic", Config{BTCMnemonicLegacySeed: true}, "BTCMnemonicLegacySeed requires BTCMnemonic"},
+		{"mnemonic with rotation", Config{BTCMnemonic: testMnemonic, BTCWallets: []NamedWallet{{ID: "a"}}}, "cannot be combined with BTCWallets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBTCWalletStorage(tt.config)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateBTCWalletStorage() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPaywall_BTCWalletStorage(t *testing.T) {
+	storage := &wallet.StorageConfig{DataDir: t.TempDir(), EncryptionKey: bytes.Repeat([]byte{1}, 32)}
+	newPaywall := func() *Paywall {
+		t.Helper()
+		pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), BTCWalletStorage: storage})
+		if err != nil {
+			t.Fatalf("NewPaywall() error = %v", err)
+		}
+		return pw
+	}
+
+	// A crash after handing out addresses, without Close
+	first := newPaywall()
+	issued := make(map[string]bool)
+	for range 3 {
+		payment, err := first.CreatePayment()
+		if err != nil {
+			t.Fatalf("CreatePayment() error = %v", err)
+		}
+		issued[payment.Addresses[wallet.Bitcoin]] = true
+	}
+	first.cancel()
+
+	restarted := newPaywall()
+	defer restarted.Close()
+	if got := restarted.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet).GetNextIndex(); got != 3 {
+		t.Errorf("next index after restart = %d, want 3", got)
+	}
+	payment, err := restarted.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if issued[payment.Addresses[wallet.Bitcoin]] {
+		t.Errorf("address %s handed out again after restart", payment.Addresses[wallet.Bitcoin])
+	}
+
+	// A wallet that cannot be decrypted is not replaced
+	wrongKey := *storage
+	wrongKey.EncryptionKey = bytes.Repeat([]byte{2}, 32)
+	if _, err := NewPaywall(Config{PriceInBTC: 0.001, TestNet: true, Store: NewMemoryStore(), BTCWalletStorage: &wrongKey}); err == nil {
+		t.Error("NewPaywall() with the wrong key succeeded")
+	}
+}
+
+func TestNewPaywall_BTCMnemonicLegacySeed(t *testing.T) {
+	config := Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), BTCMnemonic: testMnemonic, BTCMnemonicPassphrase: "secret", BTCMnemonicLegacySeed: true}
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	legacy, err := wallet.NewLegacyBTCHDWalletFromMnemonic(testMnemonic, "secret", true, 1)
+	if err != nil {
+		t.Fatalf("NewLegacyBTCHDWalletFromMnemonic() error = %v", err)
+	}
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if want, _ := legacy.AddressAt(0); payment.Addresses[wallet.Bitcoin] != want {
+		t.Errorf("payment address = %s, want the legacy derivation's %s", payment.Addresses[wallet.Bitcoin], want)
+	}
+}
+
+func TestCreatePayment_WalletSaveFailure(t *testing.T) {
+	storage := &wallet.StorageConfig{DataDir: t.TempDir(), EncryptionKey: bytes.Repeat([]byte{1}, 32)}
+	store := NewMemoryStore()
+	pw, err := NewPaywall(Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: store, BTCWalletStorage: storage})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+	btcWallet := pw.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet)
+
+	// A key the wallet cannot be saved with
+	pw.walletStorage = &wallet.StorageConfig{DataDir: storage.DataDir, EncryptionKey: []byte("short")}
+	if _, err := pw.CreatePayment(); err == nil || !strings.Contains(err.Error(), "save bitcoin address index") {
+		t.Fatalf("CreatePayment() error = %v, want a save failure", err)
+	}
+	if got := btcWallet.GetNextIndex(); got != 0 {
+		t.Errorf("next index after failed save = %d, want 0 (address rolled back)", got)
+	}
+	if payments, _ := store.ListPendingPayments(); len(payments) != 0 {
+		t.Errorf("stored %d payments after failed save, want none", len(payments))
+	}
+
+	pw.walletStorage = storage
+	payment, err := pw.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() after recovery error = %v", err)
+	}
+	if want, _ := btcWallet.AddressAt(0); payment.Addresses[wallet.Bitcoin] != want {
+		t.Errorf("address = %s, want the rolled back index 0 address %s", payment.Addresses[wallet.Bitcoin], want)
+	}
+}
+
+func TestNewPaywall_BTCMnemonic(t *testing.T) {
+	storage := &wallet.StorageConfig{DataDir: t.TempDir(), EncryptionKey: bytes.Repeat([]byte{1}, 32)}
+	config := Config{PriceInBTC: 0.001, PaymentTimeout: time.Hour, TestNet: true, Store: NewMemoryStore(), BTCWalletStorage: storage}
+
+	// A generated wallet can be backed up and restored from its mnemonic
+	generated, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer generated.Close()
+	mnemonic, err := generated.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet).ExportMnemonic()
+	if err != nil {
+		t.Fatalf("ExportMnemonic() error = %v", err)
+	}
+	payment, err := generated.CreatePayment()
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	restored, err := wallet.NewBTCHDWalletFromMnemonic(mnemonic, "", true, 1)
+	if err != nil {
+		t.Fatalf("NewBTCHDWalletFromMnemonic() error = %v", err)
+	}
+	if want, _ := restored.DeriveNextAddress(); payment.Addresses[wallet.Bitcoin] != want {
+		t.Errorf("payment address = %s, want %s from the exported mnemonic", payment.Addresses[wallet.Bitcoin], want)
+	}
+
+	tests := []struct {
+		name       string
+		mnemonic   string
+		passphrase string
+		wantErr    bool
+	}{
+		{"matches the stored wallet", mnemonic, "", false},
+		{"other passphrase", mnemonic, "secret", true},
+		{"other mnemonic", testMnemonic, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config
+			config.Store = NewMemoryStore()
+			config.BTCMnemonic, config.BTCMnemonicPassphrase = tt.mnemonic, tt.passphrase
+			pw, err := NewPaywall(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPaywall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer pw.cancel()
+			if got := pw.HDWallets[wallet.Bitcoin].(*wallet.BTCHDWallet).GetNextIndex(); got != 1 {
+				t.Errorf("next index = %d, want the stored 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package paywall
+
+import (
+	"fmt"
+
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// validateBTCWatchKey checks Config.BTCWatchKey: a public key of the
+// configured network, without the settings of a wallet with private keys
+func validateBTCWatchKey(config Config) error {
+	if config.BTCWatchKey == "" {
+		return nil
+	}
+	switch {
+	case len(config.BTCWallets) > 0:
+		return fmt.Errorf("BTCWatchKey cannot be combined with BTCWallets (hint: wrap each key with wallet.NewBTCWatchWallet and list them in BTCWallets)")
+	case config.MultisigEnabled:
+		return fmt.Errorf("BTCWatchKey cannot be combined with MultisigEnabled (hint: multisig addresses are derived from ParticipantPubKeys)")
+	case config.BTCWalletStorage != nil, config.BTCMnemonic != "", config.BTCMnemonicPassphrase != "":
+		return fmt.Errorf("BTCWatchKey cannot be combined with BTCWalletStorage, BTCMnemonic or BTCMnemonicPassphrase (hint: a watch-only wallet has no keys to store; its index is restored from the Store)")
+	}
+	watchWallet, err := wallet.NewBTCWatchWallet(config.BTCWatchKey, nil, 0)
+	if err != nil {
+		return fmt.Errorf("BTCWatchKey: %w", err)
+	}
+	if want := networkName(config.TestNet); watchWallet.Network() != want {
+		return fmt.Errorf("BTCWatchKey is a %s key but TestNet selects %s (hint: testnet keys start with tpub, upub or vpub)", watchWallet.Network(), want)
+	}
+	return nil
+}
+
+// networkName returns the network name of the address validators
+func networkName(testnet bool) string {
+	if testnet {
+		return "testnet"
+	}
+	return "mainnet"
+}
+
+// initializeBTCWatchWallet returns the watch-only wallet of
+// Config.BTCWatchKey, verifying payments with BTCBackends, BTCExplorer or the
+// default explorers, and continuing after the addresses of stored payments
+func initializeBTCWatchWallet(config Config) (*wallet.BTCWatchWallet, error) {
+	var source wallet.ChainSource
+	if config.BTCBackends != nil {
+		source = config.BTCBackends
+	} else {
+		explorerConfig := wallet.EsploraConfig{}
+		if config.BTCExplorer != nil {
+			explorerConfig = *config.BTCExplorer
+		}
+		explorer, err := wallet.NewEsploraClient(explorerConfig, config.TestNet, config.MinConfirmations)
+		if err != nil {
+			return nil, fmt.Errorf("create explorer for watch-only wallet: %w", err)
+		}
+		source = explorer
+	}
+
+	watchWallet, err := wallet.NewBTCWatchWallet(config.BTCWatchKey, source, config.MinConfirmations)
+	if err != nil {
+		return nil, fmt.Errorf("create watch-only wallet: %w", err)
+	}
+	restoreAddressIndexes(config, "Bitcoin watch-only", map[wallet.WalletType]wallet.HDWallet{wallet.Bitcoin: watchWallet})
+	return watchWallet, nil
+}
@@ -0,0 +1,104 @@
+package paywall
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/opd-ai/paywall/wallet"
+)
+
+// testWatchKey returns the tpub of account m/44'/0'/0' of a test seed
+func testWatchKey(t *testing.T) string {
+	t.Helper()
+	key, err := hdkeychain.NewMaster(bytes.Repeat([]byte{5}, 32), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewMaster() error = %v", err)
+	}
+	for _, index := range []uint32{44, 0, 0} {
+		if key, err = key.Derive(hdkeychain.HardenedKeyStart + index); err != nil {
+			t.Fatalf("Derive() error = %v", err)
+		}
+	}
+	public, err := key.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter() error = %v", err)
+	}
+	return public.String()
+}
+
+func TestValidateBTCWatchKey(t *testing.T) {
+	tpub := testWatchKey(t)
+	zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"not configured", Config{}, ""},
+		{"testnet key", Config{BTCWatchKey: tpub, TestNet: true}, ""},
+		{"mainnet key", Config{BTCWatchKey: zpub}, ""},
+		{"network mismatch", Config{BTCWatchKey: zpub, TestNet: true}, "mainnet key but TestNet selects testnet"},
+		{"malformed", Config{BTCWatchKey: "zpub123"}, "BTCWatchKey: invalid extended public key"},
+		{"with storage", Config{BTCWatchKey: zpub, BTCWalletStorage: &wallet.StorageConfig{}}, "cannot be combined with BTCWalletStorage"},
+		{"with mnemonic", Config{BTCWatchKey: zpub, BTCMnemonic: testMnemonic}, "cannot be combined with BTCWalletStorage"},
+		{"with multisig", Config{BTCWatchKey: zpub, MultisigEnabled: true}, "cannot be combined with MultisigEnabled"},
+		{"with rotation", Config{BTCWatchKey: zpub, BTCWallets: []NamedWallet{{ID: "a"}}}, "cannot be combined with BTCWallets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBTCWatchKey(tt.config)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateBTCWatchKey() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPaywall_BTCWatchKey(t *testing.T) {
+	key := testWatchKey(t)
+	store := NewMemoryStore()
+	config := Config{
+		PriceInBTC:     0.001,
+		PaymentTimeout: time.Hour,
+		TestNet:        true,
+		Store:          store,
+		BTCWatchKey:    key,
+		BTCExplorer:    &wallet.EsploraConfig{URLs: []string{"http://127.0.0.1:1"}},
+	}
+	reference, err := wallet.NewBTCWatchWallet(key, nil, 1)
+	if err != nil {
+		t.Fatalf("NewBTCWatchWallet() error = %v", err)
+	}
+
+	pw, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	if _, ok := pw.HDWallets[wallet.Bitcoin].(*wallet.BTCWatchWallet); !ok {
+		t.Fatalf("Bitcoin wallet is %T, want the watch-only wallet", pw.HDWallets[wallet.Bitcoin])
+	}
+	for range 2 {
+		payment, err := pw.CreatePayment()
+		if err != nil {
+			t.Fatalf("CreatePayment() error = %v", err)
+		}
+		if want, _ := reference.DeriveNextAddress(); payment.Addresses[wallet.Bitcoin] != want {
+			t.Errorf("payment address = %s, want %s derived from the key", payment.Addresses[wallet.Bitcoin], want)
+		}
+	}
+	pw.Close()
+
+	// A restart continues after the addresses of the stored payments
+	restarted, err := NewPaywall(config)
+	if err != nil {
+		t.Fatalf("NewPaywall() after restart error = %v", err)
+	}
+	defer restarted.Close()
+	if got := restarted.HDWallets[wallet.Bitcoin].(*wallet.BTCWatchWallet).GetNextIndex(); got != 2 {
+		t.Errorf("next index after restart = %d, want 2", got)
+	}
+}
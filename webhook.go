@@ -22,6 +22,11 @@ const (
 	EventPaymentCreated WebhookEventType = "payment_created"
 	// EventPaymentConfirmed is fired when a payment receives required confirmations
 	EventPaymentConfirmed WebhookEventType = "payment_confirmed"
+	// EventPaymentExpired is fired when the monitor or ExpirePayment expires an unpaid payment;
+	// Data holds the "amounts" asked and the "received" balances
+	EventPaymentExpired WebhookEventType = "payment_expired"
+	// EventPaymentDetected is fired when a paying transaction is seen before it is confirmed
+	EventPaymentDetected WebhookEventType = "payment_detected"
 	// EventEscrowFunded is fired when an escrow payment is funded
 	EventEscrowFunded WebhookEventType = "escrow_funded"
 	// EventDisputeResolved is fired when a dispute is resolved
@@ -30,6 +35,23 @@ const (
 	EventEscrowCompleted WebhookEventType = "escrow_completed"
 	// EventEscrowRefunded is fired when an escrow is refunded
 	EventEscrowRefunded WebhookEventType = "escrow_refunded"
+	// EventPriceChanged is fired when Config.PriceInFiat converts to a new
+	// crypto price; it carries no payment ID
+	EventPriceChanged WebhookEventType = "price_changed"
+	// EventShadowDecision is fired when a monitor in Config.MonitorShadow mode
+	// would confirm or expire a payment; Data["action"] is "confirm" or "expire"
+	EventShadowDecision WebhookEventType = "shadow_decision"
+	// EventStoreWriteFailed is fired when the monitor could not store a
+	// confirmation or expiry, after retries; Data holds the "action", the
+	// error "class" (see ClassifyStoreError), "attempts" and "error"
+	EventStoreWriteFailed WebhookEventType = "store_write_failed"
+	// EventPaymentOverpaid is fired when a confirmed payment received more
+	// than its price (see Config.Refunds); Data holds the "currency",
+	// "required", "received" and "excess" amounts and the refund "status"
+	EventPaymentOverpaid WebhookEventType = "payment_overpaid"
+	// EventPaymentRefunded is fired when an overpayment was refunded; Data is
+	// that of EventPaymentOverpaid with the "refund_txid"
+	EventPaymentRefunded WebhookEventType = "payment_refunded"
 )
 
 // WebhookConfig configures webhook notification behavior
@@ -206,7 +228,12 @@ func (wd *WebhookDispatcher) deliver(payload WebhookPayload) error {
 
 // generateSignature creates an HMAC-SHA256 signature for webhook authenticity
 func (wd *WebhookDispatcher) generateSignature(body []byte) string {
-	mac := hmac.New(sha256.New, []byte(wd.config.Secret))
+	return signWebhookBody(body, wd.config.Secret)
+}
+
+// signWebhookBody returns the hex encoded HMAC-SHA256 of body under secret
+func signWebhookBody(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(body)
 	return hex.EncodeToString(mac.Sum(nil))
 }
@@ -221,9 +248,7 @@ func (wd *WebhookDispatcher) Close() {
 // VerifyWebhookSignature verifies the HMAC signature of a webhook payload
 // This is a helper function for webhook receivers to validate authenticity
 func VerifyWebhookSignature(payload []byte, signature, secret string) bool {
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(payload)
-	expected := hex.EncodeToString(mac.Sum(nil))
+	expected := signWebhookBody(payload, secret)
 	return hmac.Equal([]byte(signature), []byte(expected))
 }
 
@@ -0,0 +1,166 @@
+package paywall
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Control message types sent by the WebSocket gateway
+const (
+	// ControlPaymentRequired is sent instead of delivering a message the API key
+	// cannot pay for; the socket stays open so the client can top up and retry
+	ControlPaymentRequired = "payment_required"
+	// ControlRateLimited is sent when the key exceeded its per-minute rate limit
+	ControlRateLimited = "rate_limited"
+	// ControlAccessRevoked is sent just before the gateway closes the socket
+	// because the key was revoked, expired or exhausted its usage limit
+	ControlAccessRevoked = "access_revoked"
+)
+
+// WebSocketControl is the JSON text message the gateway sends to clients when
+// an inbound message is not delivered
+type WebSocketControl struct {
+	// Type is ControlPaymentRequired, ControlRateLimited or ControlAccessRevoked
+	Type string `json:"type"`
+	// Cost is the price of the rejected message in credits
+	Cost int64 `json:"cost,omitempty"`
+	// Credits is the key's remaining balance
+	Credits int64 `json:"credits"`
+	// RetryAfter is the number of seconds until a rate-limited key may send again
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Message is a human-readable explanation
+	Message string `json:"message,omitempty"`
+}
+
+// MeteredConn is a WebSocket connection opened through WebSocketGateway.
+// Receive charges the connection's API key for every inbound message.
+type MeteredConn struct {
+	*websocket.Conn
+
+	paywall *Paywall
+	rawKey  string
+	cost    func(msg []byte) int64
+
+	mu      sync.Mutex
+	keyID   string
+	credits int64
+}
+
+// WebSocketGateway serves a pay-per-message WebSocket API. Clients connect with
+// a metered API key in the Config.APIKeyHeader header; every inbound message
+// is charged against the key's credits (see APIKeyOptions.Metered, TopUpAPIKey)
+// before handler sees it. When the balance cannot cover a message, the client
+// receives a ControlPaymentRequired message and the message is dropped.
+// Unmetered keys are only subject to their rate and usage limits.
+//
+// Parameters:
+//   - handler: Serves the connection; read messages with MeteredConn.Receive
+//   - messageCost: Credits per inbound message, nil for 1 credit each
+//
+// Returns:
+//   - http.Handler: Mount it at the WebSocket endpoint; requires Config.APIKeysEnabled
+//
+// Related: MeteredConn, WebSocketControl, WithRevalidation
+func (p *Paywall) WebSocketGateway(handler func(conn *MeteredConn), messageCost func(msg []byte) int64) http.Handler {
+	// The upgrade itself is free; messages are charged as they arrive
+	cfg := &middlewareConfig{requestCost: func(*http.Request) int64 { return 0 }}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.apiKeys == nil {
+			http.Error(w, "API keys are disabled", http.StatusNotImplemented)
+			return
+		}
+		rawKey := r.Header.Get(p.apiKeyHeader)
+		if rawKey == "" {
+			w.Header().Set("WWW-Authenticate", `APIKey header="`+p.apiKeyHeader+`"`)
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+
+		server := websocket.Server{Handler: func(ws *websocket.Conn) {
+			conn := &MeteredConn{Conn: ws, paywall: p, rawKey: rawKey, cost: messageCost}
+			conn.keyID, _, _ = parseAPIKey(rawKey)
+			handler(conn)
+		}}
+		p.serveAPIKey(w, r, cfg, rawKey, server)
+	})
+}
+
+// Receive reads the next inbound message and charges the API key for it.
+// Messages the key cannot pay for are answered with a WebSocketControl message
+// and skipped, so Receive only returns paid messages.
+//
+// Returns:
+//   - []byte: The message payload (text or binary)
+//   - error: Read errors, or ErrInvalidAPIKey / ErrAPIKeyUsageExhausted after
+//     the gateway closed the socket because access ended
+func (c *MeteredConn) Receive() ([]byte, error) {
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(c.Conn, &msg); err != nil {
+			return nil, err
+		}
+		cost := int64(1)
+		if c.cost != nil {
+			cost = c.cost(msg)
+			if cost < 0 {
+				cost = 0
+			}
+		}
+
+		key, retryAfter, err := c.paywall.authenticateAPIKey(c.rawKey, cost)
+		if key != nil {
+			c.mu.Lock()
+			c.credits = key.Credits
+			c.mu.Unlock()
+		}
+		switch {
+		case err == nil:
+			return msg, nil
+		case errors.Is(err, ErrInsufficientCredits):
+			if err := c.control(WebSocketControl{Type: ControlPaymentRequired, Cost: cost, Message: err.Error()}); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, ErrAPIKeyRateLimited):
+			control := WebSocketControl{Type: ControlRateLimited, Cost: cost, RetryAfter: int(math.Ceil(retryAfter.Seconds())), Message: err.Error()}
+			if err := c.control(control); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, ErrInvalidAPIKey), errors.Is(err, ErrAPIKeyUsageExhausted):
+			c.control(WebSocketControl{Type: ControlAccessRevoked, Message: err.Error()})
+			c.Close()
+			return nil, err
+		default:
+			c.paywall.logger.log(LogEntry{
+				Level:   LogLevelError,
+				Event:   "websocket_metering_failed",
+				Message: fmt.Sprintf("Failed to charge WebSocket message to API key %s: %v", c.keyID, err),
+			})
+			c.Close()
+			return nil, fmt.Errorf("charge message: %w", err)
+		}
+	}
+}
+
+// Credits returns the key's balance after the last charged message
+func (c *MeteredConn) Credits() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.credits
+}
+
+// KeyID returns the ID of the API key the connection is billed to
+func (c *MeteredConn) KeyID() string {
+	return c.keyID
+}
+
+// control sends a WebSocketControl message with the current balance
+func (c *MeteredConn) control(msg WebSocketControl) error {
+	msg.Credits = c.Credits()
+	return websocket.JSON.Send(c.Conn, msg)
+}
@@ -0,0 +1,114 @@
+package paywall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketGateway(t *testing.T) {
+	pw, err := NewPaywall(Config{
+		PriceInBTC:        0.001,
+		PaymentTimeout:    time.Hour,
+		TestNet:           true,
+		Store:             NewMemoryStore(),
+		APIKeysEnabled:    true,
+		CreditsPerPayment: 3,
+	})
+	if err != nil {
+		t.Fatalf("NewPaywall() error = %v", err)
+	}
+	defer pw.Close()
+
+	// Echo every paid message; messages starting with "big" cost 2 credits
+	gateway := pw.WebSocketGateway(func(conn *MeteredConn) {
+		for {
+			msg, err := conn.Receive()
+			if err != nil {
+				return
+			}
+			websocket.Message.Send(conn.Conn, "echo:"+string(msg))
+		}
+	}, func(msg []byte) int64 {
+		if strings.HasPrefix(string(msg), "big") {
+			return 2
+		}
+		return 1
+	})
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	payment := confirmedTestPayment(t, pw)
+	key, record, err := pw.IssueAPIKey(payment.ID, APIKeyOptions{Metered: true})
+	if err != nil {
+		t.Fatalf("IssueAPIKey() error = %v", err)
+	}
+
+	t.Run("rejects upgrade without key", func(t *testing.T) {
+		config, _ := websocket.NewConfig(wsURL, server.URL)
+		if _, err := websocket.DialConfig(config); err == nil {
+			t.Fatal("DialConfig() without API key should fail")
+		}
+	})
+
+	config, _ := websocket.NewConfig(wsURL, server.URL)
+	config.Header = http.Header{DefaultAPIKeyHeader: {key}}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("DialConfig() error = %v", err)
+	}
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	exchange := func(send string) string {
+		t.Helper()
+		if err := websocket.Message.Send(ws, send); err != nil {
+			t.Fatalf("Send(%q) error = %v", send, err)
+		}
+		var reply string
+		if err := websocket.Message.Receive(ws, &reply); err != nil {
+			t.Fatalf("Receive() after %q error = %v", send, err)
+		}
+		return reply
+	}
+
+	if got := exchange("big hello"); got != "echo:big hello" {
+		t.Errorf("reply = %q, want echo", got)
+	}
+	// 1 credit left: a 2-credit message is refused with a control message
+	if got := exchange("big again"); !strings.Contains(got, `"type":"payment_required"`) || !strings.Contains(got, `"credits":1`) {
+		t.Errorf("reply = %q, want payment_required control with 1 credit", got)
+	}
+	if got := exchange("small"); got != "echo:small" {
+		t.Errorf("reply = %q, want echo", got)
+	}
+	if got := exchange("small"); !strings.Contains(got, `"type":"payment_required"`) {
+		t.Errorf("reply = %q, want payment_required control", got)
+	}
+
+	// Topping up unblocks the open socket
+	topUp := confirmedTestPayment(t, pw)
+	if _, err := pw.TopUpAPIKey(record.ID, topUp.ID); err != nil {
+		t.Fatalf("TopUpAPIKey() error = %v", err)
+	}
+	if got := exchange("small"); got != "echo:small" {
+		t.Errorf("after top-up reply = %q, want echo", got)
+	}
+
+	// Revoking the key closes the socket
+	if err := pw.RevokeAPIKey(record.ID); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if got := exchange("small"); !strings.Contains(got, `"type":"access_revoked"`) {
+		t.Errorf("after revoke reply = %q, want access_revoked control", got)
+	}
+	var extra string
+	if err := websocket.Message.Receive(ws, &extra); err == nil {
+		t.Errorf("socket still open after revoke, received %q", extra)
+	}
+}